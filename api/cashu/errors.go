@@ -27,6 +27,7 @@ var (
 	ErrAmountlessInvoiceNotSupported = errors.New("Amount less invoices not supported")
 
 	ErrKeysetNotKnow = errors.New("keyset not known")
+	ErrKeysetExpired = errors.New("keyset is past its final expiry")
 )
 
 type ErrorCode uint
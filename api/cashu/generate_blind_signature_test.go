@@ -35,6 +35,7 @@ func TestGenerateBlindSignatureAndCheckSignature(t *testing.T) {
 		CreatedAt:      0,
 		Active:         false,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 
 	generatedKeysets, err := localsigner.GenerateKeysets(key, seed)
@@ -1,8 +1,10 @@
 package cashu
 
 import (
+	"cmp"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
 	"slices"
@@ -15,6 +17,10 @@ import (
 const LegacyMaxKeysetAmount int = 64
 const MaxKeysetAmount int = 32
 
+// ErrInvalidKeysetAmounts means the denominations asked for a new keyset can
+// not make up every amount or do not fit in a keyset.
+var ErrInvalidKeysetAmounts = errors.New("invalid keyset amounts")
+
 // Deprecated: Use DeriveKeysetIdV2 instead. This function generates V1 keyset IDs which are less unique.
 func DeriveKeysetId(keysets []*secp256k1.PublicKey) (string, error) {
 	concatBinaryArray := []byte{}
@@ -48,7 +54,7 @@ func sortPubkeyMapToOrganizedArray(pubkeyMap map[uint64]*secp256k1.PublicKey) []
 	}
 
 	slices.SortFunc(arrayPubkeys, func(a, b pubkeyWithAmount) int {
-		return int(a.Amount) - int(b.Amount)
+		return cmp.Compare(a.Amount, b.Amount)
 	})
 	return arrayPubkeys
 }
@@ -123,6 +129,56 @@ func GetAmountsForKeysets(max_order int) []uint64 {
 	return keys
 }
 
+// AmountsForMaxOrder is the powers of two of a keyset with max order keys,
+// from 1 to 2^(maxOrder-1).
+func AmountsForMaxOrder(maxOrder int) ([]uint64, error) {
+	if maxOrder < 1 || maxOrder > LegacyMaxKeysetAmount {
+		return nil, fmt.Errorf("%w: the max order has to be between 1 and %d", ErrInvalidKeysetAmounts, LegacyMaxKeysetAmount)
+	}
+	return GetAmountsForKeysets(maxOrder), nil
+}
+
+// KeysetAmounts checks the denominations of a new keyset and returns them
+// sorted. No amounts are the default powers of two. Every keyset needs a 1 so
+// any amount, like the change of a melt, can be made up from it.
+func KeysetAmounts(amounts []uint64) ([]uint64, error) {
+	if len(amounts) == 0 {
+		return GetAmountsForKeysets(MaxKeysetAmount), nil
+	}
+	if len(amounts) > LegacyMaxKeysetAmount {
+		return nil, fmt.Errorf("%w: a keyset can have at most %d amounts", ErrInvalidKeysetAmounts, LegacyMaxKeysetAmount)
+	}
+	sorted := slices.Clone(amounts)
+	slices.Sort(sorted)
+	if sorted[0] != 1 {
+		return nil, fmt.Errorf("%w: the smallest amount has to be 1", ErrInvalidKeysetAmounts)
+	}
+	if len(slices.Compact(slices.Clone(sorted))) != len(sorted) {
+		return nil, fmt.Errorf("%w: repeated amount", ErrInvalidKeysetAmounts)
+	}
+	return sorted, nil
+}
+
+// AmountSplitWithDenominations splits amount into the denominations of a
+// keyset, largest first, and returns at most maxParts of the parts, smallest
+// first. For powers of two it is the same as AmountSplit. denominations have
+// to be sorted and include 1. The parts are counted per denomination, with
+// gaps like [1, 2^40] a split can have more parts than fit in memory.
+func AmountSplitWithDenominations(amount uint64, denominations []uint64, maxParts int) []uint64 {
+	counts := make([]uint64, len(denominations))
+	for i := len(denominations) - 1; i >= 0 && amount > 0; i-- {
+		counts[i] = amount / denominations[i]
+		amount %= denominations[i]
+	}
+	rv := make([]uint64, 0)
+	for i, count := range counts {
+		for ; count > 0 && len(rv) < maxParts; count-- {
+			rv = append(rv, denominations[i])
+		}
+	}
+	return rv
+}
+
 // Given an amount, it returns list of amounts e.g 13 -> [1, 4, 8]
 // that can be used to build blinded messages or split operations.
 // from nutshell implementation
@@ -2,7 +2,9 @@ package cashu
 
 import (
 	"encoding/hex"
+	"errors"
 	"math/big"
+	"slices"
 	"testing"
 	"time"
 
@@ -260,3 +262,92 @@ func TestKeysetIdGenerationV2Vector3(t *testing.T) {
 		t.Errorf("keyset id is not correct.")
 	}
 }
+
+func TestKeysetAmounts(t *testing.T) {
+	amounts, err := KeysetAmounts(nil)
+	if err != nil {
+		t.Fatalf("KeysetAmounts(nil) %+v", err)
+	}
+	if len(amounts) != MaxKeysetAmount {
+		t.Errorf("expected the default %d amounts, got %d", MaxKeysetAmount, len(amounts))
+	}
+
+	amounts, err = KeysetAmounts([]uint64{100, 1, 10, 5, 2})
+	if err != nil {
+		t.Fatalf("KeysetAmounts(decimals) %+v", err)
+	}
+	if !slices.Equal(amounts, []uint64{1, 2, 5, 10, 100}) {
+		t.Errorf("expected the amounts sorted, got %v", amounts)
+	}
+
+	invalid := [][]uint64{
+		{2, 4, 8},
+		{0, 1, 2},
+		{1, 2, 2},
+		GetAmountsForKeysets(LegacyMaxKeysetAmount + 1),
+	}
+	for _, amounts := range invalid {
+		_, err := KeysetAmounts(amounts)
+		if !errors.Is(err, ErrInvalidKeysetAmounts) {
+			t.Errorf("KeysetAmounts(%v) expected ErrInvalidKeysetAmounts, got %v", amounts, err)
+		}
+	}
+}
+
+func TestAmountsForMaxOrder(t *testing.T) {
+	amounts, err := AmountsForMaxOrder(21)
+	if err != nil {
+		t.Fatalf("AmountsForMaxOrder(21) %+v", err)
+	}
+	if len(amounts) != 21 || amounts[0] != 1 || amounts[20] != 1<<20 {
+		t.Errorf("expected 1 to 2^20, got %v", amounts)
+	}
+
+	for _, maxOrder := range []int{0, LegacyMaxKeysetAmount + 1} {
+		_, err := AmountsForMaxOrder(maxOrder)
+		if !errors.Is(err, ErrInvalidKeysetAmounts) {
+			t.Errorf("AmountsForMaxOrder(%d) expected ErrInvalidKeysetAmounts, got %v", maxOrder, err)
+		}
+	}
+}
+
+func TestAmountSplitWithDenominations(t *testing.T) {
+	split := AmountSplitWithDenominations(13, GetAmountsForKeysets(MaxKeysetAmount), 64)
+	if !slices.Equal(split, AmountSplit(13)) {
+		t.Errorf("expected powers of two to split like AmountSplit, got %v", split)
+	}
+
+	split = AmountSplitWithDenominations(187, []uint64{1, 2, 5, 10, 20, 50, 100}, 64)
+	if !slices.Equal(split, []uint64{2, 5, 10, 20, 50, 100}) {
+		t.Errorf("unexpected decimal split %v", split)
+	}
+
+	split = AmountSplitWithDenominations(70, []uint64{1, 2, 5, 10, 20, 50, 100}, 2)
+	if !slices.Equal(split, []uint64{20, 50}) {
+		t.Errorf("expected the two smallest parts, got %v", split)
+	}
+
+	// one part per unit below the gap, stopped at maxParts
+	split = AmountSplitWithDenominations(1<<40-1, []uint64{1, 1 << 40}, 10)
+	if len(split) != 10 || split[0] != 1 || split[9] != 1 {
+		t.Errorf("expected 10 parts of 1, got %v", split)
+	}
+}
+
+func TestKeysetIdV2SortsLargeAmounts(t *testing.T) {
+	small, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("secp256k1.GeneratePrivateKey() %+v", err)
+	}
+	large, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("secp256k1.GeneratePrivateKey() %+v", err)
+	}
+	// 3<<62 is negative as an int
+	keys := map[uint64]*secp256k1.PublicKey{1: small.PubKey(), 3 << 62: large.PubKey()}
+
+	sorted := sortPubkeyMapToOrganizedArray(keys)
+	if sorted[0].Amount != 1 || sorted[1].Amount != 3<<62 {
+		t.Errorf("expected the amounts sorted ascending, got %d and %d", sorted[0].Amount, sorted[1].Amount)
+	}
+}
@@ -0,0 +1,296 @@
+package cashu
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// The keyset log is an append only Merkle tree, as in RFC 9162, of every
+// keyset the mint published. Wallets keep the last tree head they saw and ask
+// for a consistency proof to the next one, so a mint can not serve different
+// keys to different users without signing two heads that do not match.
+
+var (
+	ErrInvalidKeysetLogProof     = errors.New("keyset log proof is not valid")
+	ErrInvalidKeysetLogSignature = errors.New("keyset log root signature is not valid")
+)
+
+// keysetLogRootTag separates the signatures of tree heads from other messages
+// signed with the mint key.
+const keysetLogRootTag = "nutmix/keyset-log/v1"
+
+// KeysetLogEntry is a keyset as it is written in the log. Its leaf is the
+// compact JSON encoding of the entry, with object keys in the order of the
+// struct and the amounts of Keys sorted as strings, like encoding/json does.
+type KeysetLogEntry struct {
+	FinalExpiry *uint64           `json:"final_expiry,omitempty"`
+	Keys        map[uint64]string `json:"keys"`
+	Id          string            `json:"id"`
+	Unit        string            `json:"unit"`
+	InputFeePpk uint              `json:"input_fee_ppk"`
+}
+
+// Leaf is the data of the entry hashed into the tree.
+func (e KeysetLogEntry) Leaf() ([]byte, error) {
+	leaf, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(entry). %w", err)
+	}
+	return leaf, nil
+}
+
+// KeysetLogLeafHash is the hash of a leaf of the log.
+func KeysetLogLeafHash(leaf []byte) []byte {
+	hash := sha256.Sum256(append([]byte{0x00}, leaf...))
+	return hash[:]
+}
+
+func keysetLogNodeHash(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, 0x01)
+	data = append(data, left...)
+	data = append(data, right...)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// largestPowerOfTwoBelow is the split point k of RFC 9162, n has to be
+// bigger than 1.
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// KeysetLogRootHash is the Merkle tree hash of the leaf hashes.
+func KeysetLogRootHash(leafHashes [][]byte) []byte {
+	switch len(leafHashes) {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return leafHashes[0]
+	}
+	k := largestPowerOfTwoBelow(uint64(len(leafHashes)))
+	return keysetLogNodeHash(KeysetLogRootHash(leafHashes[:k]), KeysetLogRootHash(leafHashes[k:]))
+}
+
+// KeysetLogInclusionProof is the audit path of the leaf at index in the tree
+// of leafHashes.
+func KeysetLogInclusionProof(leafHashes [][]byte, index uint64) ([][]byte, error) {
+	if index >= uint64(len(leafHashes)) {
+		return nil, fmt.Errorf("%w: leaf %d is not in a tree of %d", ErrInvalidKeysetLogProof, index, len(leafHashes))
+	}
+	return inclusionPath(leafHashes, index), nil
+}
+
+func inclusionPath(leafHashes [][]byte, index uint64) [][]byte {
+	n := uint64(len(leafHashes))
+	if n <= 1 {
+		return [][]byte{}
+	}
+	k := largestPowerOfTwoBelow(n)
+	if index < k {
+		return append(inclusionPath(leafHashes[:k], index), KeysetLogRootHash(leafHashes[k:]))
+	}
+	return append(inclusionPath(leafHashes[k:], index-k), KeysetLogRootHash(leafHashes[:k]))
+}
+
+// KeysetLogConsistencyProof proves the tree of the first size leaves is the
+// start of the tree of leafHashes.
+func KeysetLogConsistencyProof(leafHashes [][]byte, size uint64) ([][]byte, error) {
+	if size > uint64(len(leafHashes)) {
+		return nil, fmt.Errorf("%w: a tree of %d can not come after one of %d", ErrInvalidKeysetLogProof, len(leafHashes), size)
+	}
+	if size == 0 {
+		return [][]byte{}, nil
+	}
+	return consistencySubproof(size, leafHashes, true), nil
+}
+
+func consistencySubproof(m uint64, leafHashes [][]byte, complete bool) [][]byte {
+	n := uint64(len(leafHashes))
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{KeysetLogRootHash(leafHashes)}
+	}
+	k := largestPowerOfTwoBelow(n)
+	if m <= k {
+		return append(consistencySubproof(m, leafHashes[:k], complete), KeysetLogRootHash(leafHashes[k:]))
+	}
+	return append(consistencySubproof(m-k, leafHashes[k:], false), KeysetLogRootHash(leafHashes[:k]))
+}
+
+// VerifyKeysetLogInclusion checks the leaf at index is in the tree of size
+// with root.
+func VerifyKeysetLogInclusion(leafHash []byte, index uint64, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return fmt.Errorf("%w: leaf %d is not in a tree of %d", ErrInvalidKeysetLogProof, index, size)
+	}
+	fn, sn := index, size-1
+	hash := leafHash
+	for _, node := range proof {
+		if sn == 0 {
+			return fmt.Errorf("%w: the path is too long", ErrInvalidKeysetLogProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			hash = keysetLogNodeHash(node, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = keysetLogNodeHash(hash, node)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(hash, root) {
+		return ErrInvalidKeysetLogProof
+	}
+	return nil
+}
+
+// VerifyKeysetLogConsistency checks the tree of firstSize with firstRoot is
+// the start of the tree of secondSize with secondRoot.
+func VerifyKeysetLogConsistency(firstSize uint64, secondSize uint64, firstRoot []byte, secondRoot []byte, proof [][]byte) error {
+	switch {
+	case firstSize > secondSize:
+		return fmt.Errorf("%w: a tree of %d can not come after one of %d", ErrInvalidKeysetLogProof, secondSize, firstSize)
+	case firstSize == 0:
+		// every tree starts with the empty one
+		return nil
+	case firstSize == secondSize:
+		if len(proof) != 0 || !bytes.Equal(firstRoot, secondRoot) {
+			return ErrInvalidKeysetLogProof
+		}
+		return nil
+	case len(proof) == 0:
+		return fmt.Errorf("%w: empty proof", ErrInvalidKeysetLogProof)
+	}
+
+	if firstSize&(firstSize-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+	fn, sn := firstSize-1, secondSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	firstHash, secondHash := proof[0], proof[0]
+	for _, node := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w: the path is too long", ErrInvalidKeysetLogProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			firstHash = keysetLogNodeHash(node, firstHash)
+			secondHash = keysetLogNodeHash(node, secondHash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			secondHash = keysetLogNodeHash(secondHash, node)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(firstHash, firstRoot) || !bytes.Equal(secondHash, secondRoot) {
+		return ErrInvalidKeysetLogProof
+	}
+	return nil
+}
+
+// KeysetLogRoot is a tree head of the log. Signature is a schnorr signature
+// with the mint pubkey over KeysetLogSignedHash, empty when the signer of the
+// mint can not sign messages.
+type KeysetLogRoot struct {
+	RootHash  string `json:"root_hash"`
+	Signature string `json:"signature,omitempty"`
+	TreeSize  uint64 `json:"tree_size"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// KeysetLogSignedHash is the hash signed for a tree head: the sha256 of the
+// tag, the tree size and timestamp as big endian uint64 and the root hash.
+func KeysetLogSignedHash(treeSize uint64, timestamp int64, rootHash []byte) []byte {
+	data := make([]byte, 0, len(keysetLogRootTag)+16+len(rootHash))
+	data = append(data, keysetLogRootTag...)
+	data = binary.BigEndian.AppendUint64(data, treeSize)
+	data = binary.BigEndian.AppendUint64(data, uint64(timestamp))
+	data = append(data, rootHash...)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// Verify checks the tree head was signed by the mint with pubkey, a
+// compressed hex public key like the one in the mint info.
+func (r KeysetLogRoot) Verify(pubkey string) error {
+	rootHash, err := hex.DecodeString(r.RootHash)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString(r.RootHash). %w", err)
+	}
+	err = verifyMintSignature(pubkey, r.Signature, KeysetLogSignedHash(r.TreeSize, r.Timestamp, rootHash))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidKeysetLogSignature, err)
+	}
+	return nil
+}
+
+// verifyMintSignature checks a hex schnorr signature of hash by the hex
+// compressed pubkey of the mint.
+func verifyMintSignature(pubkey string, signature string, hash []byte) error {
+	pubkeyBytes, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString(pubkey). %w", err)
+	}
+	key, err := btcec.ParsePubKey(pubkeyBytes)
+	if err != nil {
+		return fmt.Errorf("btcec.ParsePubKey(pubkeyBytes). %w", err)
+	}
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString(signature). %w", err)
+	}
+	parsed, err := schnorr.ParseSignature(signatureBytes)
+	if err != nil {
+		return fmt.Errorf("schnorr.ParseSignature(signatureBytes). %w", err)
+	}
+	if !parsed.Verify(hash, key) {
+		return errors.New("the signature does not match")
+	}
+	return nil
+}
+
+// GetKeysetLogEntriesResponse are the entries of the log from Start.
+type GetKeysetLogEntriesResponse struct {
+	Entries []KeysetLogEntry `json:"entries"`
+	Start   uint64           `json:"start"`
+}
+
+// GetKeysetLogInclusionResponse proves the keyset is the leaf at LeafIndex
+// of the tree of TreeSize.
+type GetKeysetLogInclusionResponse struct {
+	Entry     KeysetLogEntry `json:"entry"`
+	RootHash  string         `json:"root_hash"`
+	AuditPath []string       `json:"audit_path"`
+	LeafIndex uint64         `json:"leaf_index"`
+	TreeSize  uint64         `json:"tree_size"`
+}
+
+// GetKeysetLogConsistencyResponse proves the tree of First is the start of
+// the tree of Second.
+type GetKeysetLogConsistencyResponse struct {
+	Proof  []string `json:"proof"`
+	First  uint64   `json:"first"`
+	Second uint64   `json:"second"`
+}
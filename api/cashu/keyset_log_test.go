@@ -0,0 +1,113 @@
+package cashu
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func keysetLogLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = KeysetLogLeafHash([]byte(fmt.Sprintf("keyset %d", i)))
+	}
+	return leaves
+}
+
+func TestKeysetLogInclusionProofs(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := keysetLogLeaves(size)
+		root := KeysetLogRootHash(leaves)
+		for index := range leaves {
+			proof, err := KeysetLogInclusionProof(leaves, uint64(index))
+			if err != nil {
+				t.Fatalf("KeysetLogInclusionProof(%d, %d) %+v", size, index, err)
+			}
+			err = VerifyKeysetLogInclusion(leaves[index], uint64(index), uint64(size), proof, root)
+			if err != nil {
+				t.Errorf("leaf %d of %d: %+v", index, size, err)
+			}
+			other := leaves[(index+1)%size]
+			if size > 1 && VerifyKeysetLogInclusion(other, uint64(index), uint64(size), proof, root) == nil {
+				t.Errorf("leaf %d of %d: a different leaf verified", index, size)
+			}
+		}
+	}
+
+	_, err := KeysetLogInclusionProof(keysetLogLeaves(3), 3)
+	if !errors.Is(err, ErrInvalidKeysetLogProof) {
+		t.Errorf("expected ErrInvalidKeysetLogProof for a leaf outside the tree, got %v", err)
+	}
+}
+
+func TestKeysetLogConsistencyProofs(t *testing.T) {
+	for second := 1; second <= 17; second++ {
+		leaves := keysetLogLeaves(second)
+		secondRoot := KeysetLogRootHash(leaves)
+		for first := 0; first <= second; first++ {
+			firstRoot := KeysetLogRootHash(leaves[:first])
+			proof, err := KeysetLogConsistencyProof(leaves, uint64(first))
+			if err != nil {
+				t.Fatalf("KeysetLogConsistencyProof(%d, %d) %+v", second, first, err)
+			}
+			err = VerifyKeysetLogConsistency(uint64(first), uint64(second), firstRoot, secondRoot, proof)
+			if err != nil {
+				t.Errorf("%d to %d: %+v", first, second, err)
+			}
+			// a log that changed a published keyset
+			if first > 0 && first < second {
+				forked := keysetLogLeaves(second)
+				forked[0] = KeysetLogLeafHash([]byte("other keys"))
+				forkedProof, err := KeysetLogConsistencyProof(forked, uint64(first))
+				if err != nil {
+					t.Fatalf("KeysetLogConsistencyProof(forked) %+v", err)
+				}
+				err = VerifyKeysetLogConsistency(uint64(first), uint64(second), firstRoot, KeysetLogRootHash(forked), forkedProof)
+				if !errors.Is(err, ErrInvalidKeysetLogProof) {
+					t.Errorf("%d to %d: expected a forked log to fail, got %v", first, second, err)
+				}
+			}
+		}
+	}
+}
+
+func TestKeysetLogRootSignature(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("btcec.NewPrivateKey() %+v", err)
+	}
+	pubkey := hex.EncodeToString(key.PubKey().SerializeCompressed())
+	rootHash := KeysetLogRootHash(keysetLogLeaves(3))
+	signature, err := schnorr.Sign(key, KeysetLogSignedHash(3, 1700000000, rootHash))
+	if err != nil {
+		t.Fatalf("schnorr.Sign() %+v", err)
+	}
+	root := KeysetLogRoot{RootHash: hex.EncodeToString(rootHash), Signature: hex.EncodeToString(signature.Serialize()), TreeSize: 3, Timestamp: 1700000000}
+
+	err = root.Verify(pubkey)
+	if err != nil {
+		t.Fatalf("root.Verify(pubkey) %+v", err)
+	}
+	root.TreeSize = 2
+	err = root.Verify(pubkey)
+	if !errors.Is(err, ErrInvalidKeysetLogSignature) {
+		t.Errorf("expected ErrInvalidKeysetLogSignature for a changed tree size, got %v", err)
+	}
+}
+
+func TestKeysetLogEntryLeafIsStable(t *testing.T) {
+	expiry := uint64(1700000000)
+	entry := KeysetLogEntry{FinalExpiry: &expiry, Keys: map[uint64]string{2: "02bb", 1: "02aa"}, Id: "01ab", Unit: "sat", InputFeePpk: 100}
+	leaf, err := entry.Leaf()
+	if err != nil {
+		t.Fatalf("entry.Leaf() %+v", err)
+	}
+	expected := `{"final_expiry":1700000000,"keys":{"1":"02aa","2":"02bb"},"id":"01ab","unit":"sat","input_fee_ppk":100}`
+	if string(leaf) != expected {
+		t.Errorf("expected leaf %s, got %s", expected, leaf)
+	}
+}
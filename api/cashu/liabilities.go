@@ -0,0 +1,386 @@
+package cashu
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// A liability report commits, for every keyset, to the blind signatures the
+// mint issued and to the proofs it redeemed with two Merkle sum trees. Every
+// node carries the sum of the amounts under it, so the root sums are the
+// totals of the report and a wallet that gets an inclusion proof for its own
+// outputs and spent proofs knows they were counted.
+
+var (
+	ErrInvalidLiabilityProof     = errors.New("liability proof is not valid")
+	ErrInvalidLiabilitySignature = errors.New("liability report signature is not valid")
+	ErrLiabilitySumOverflow      = errors.New("liability sum overflows")
+)
+
+// liabilityReportTag separates the signatures of liability reports from other
+// messages signed with the mint key.
+const liabilityReportTag = "nutmix/liabilities/v1"
+
+// MerkleSumNode is a node of a Merkle sum tree. It is encoded in JSON with a
+// hex hash.
+type MerkleSumNode struct {
+	Hash []byte
+	Sum  uint64
+}
+
+type merkleSumNodeJSON struct {
+	Hash string `json:"hash"`
+	Sum  uint64 `json:"sum"`
+}
+
+func (n MerkleSumNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(merkleSumNodeJSON{Hash: hex.EncodeToString(n.Hash), Sum: n.Sum})
+}
+
+func (n *MerkleSumNode) UnmarshalJSON(b []byte) error {
+	var node merkleSumNodeJSON
+	err := json.Unmarshal(b, &node)
+	if err != nil {
+		return err
+	}
+	hash, err := hex.DecodeString(node.Hash)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString(node.Hash). %w", err)
+	}
+	n.Hash = hash
+	n.Sum = node.Sum
+	return nil
+}
+
+func liabilityLeaf(amount uint64, parts ...[]byte) MerkleSumNode {
+	data := binary.BigEndian.AppendUint64([]byte{0x00}, amount)
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	hash := sha256.Sum256(data)
+	return MerkleSumNode{Hash: hash[:], Sum: amount}
+}
+
+// IssuedLiabilityLeaf is the leaf of a blind signature C_ the mint made on
+// the blinded message B_.
+func IssuedLiabilityLeaf(amount uint64, B_ WrappedPublicKey, C_ WrappedPublicKey) MerkleSumNode {
+	return liabilityLeaf(amount, B_.SerializeCompressed(), C_.SerializeCompressed())
+}
+
+// RedeemedLiabilityLeaf is the leaf of a spent proof with Y.
+func RedeemedLiabilityLeaf(amount uint64, Y WrappedPublicKey) MerkleSumNode {
+	return liabilityLeaf(amount, Y.SerializeCompressed())
+}
+
+func merkleSumParent(left, right MerkleSumNode) (MerkleSumNode, error) {
+	if left.Sum > math.MaxUint64-right.Sum {
+		return MerkleSumNode{}, ErrLiabilitySumOverflow
+	}
+	data := make([]byte, 0, 1+16+len(left.Hash)+len(right.Hash))
+	data = append(data, 0x01)
+	data = binary.BigEndian.AppendUint64(data, left.Sum)
+	data = append(data, left.Hash...)
+	data = binary.BigEndian.AppendUint64(data, right.Sum)
+	data = append(data, right.Hash...)
+	hash := sha256.Sum256(data)
+	return MerkleSumNode{Hash: hash[:], Sum: left.Sum + right.Sum}, nil
+}
+
+// MerkleSumTree keeps every node of a tree, so the proofs of many of its
+// leaves are cheap. The tree has the shape of the RFC 9162 trees of the
+// keyset log.
+type MerkleSumTree struct {
+	leaves []MerkleSumNode
+	// nodes of the subtrees of more than one leaf, by their first leaf and size
+	nodes map[[2]uint64]MerkleSumNode
+	root  MerkleSumNode
+}
+
+func NewMerkleSumTree(leaves []MerkleSumNode) (*MerkleSumTree, error) {
+	tree := MerkleSumTree{leaves: leaves, nodes: make(map[[2]uint64]MerkleSumNode), root: MerkleSumNode{}}
+	if len(leaves) == 0 {
+		hash := sha256.Sum256(nil)
+		tree.root = MerkleSumNode{Hash: hash[:], Sum: 0}
+		return &tree, nil
+	}
+	root, err := tree.build(0, uint64(len(leaves)))
+	if err != nil {
+		return nil, err
+	}
+	tree.root = root
+	return &tree, nil
+}
+
+func (t *MerkleSumTree) build(start uint64, size uint64) (MerkleSumNode, error) {
+	if size == 1 {
+		return t.leaves[start], nil
+	}
+	k := largestPowerOfTwoBelow(size)
+	left, err := t.build(start, k)
+	if err != nil {
+		return MerkleSumNode{}, err
+	}
+	right, err := t.build(start+k, size-k)
+	if err != nil {
+		return MerkleSumNode{}, err
+	}
+	node, err := merkleSumParent(left, right)
+	if err != nil {
+		return MerkleSumNode{}, err
+	}
+	t.nodes[[2]uint64{start, size}] = node
+	return node, nil
+}
+
+func (t *MerkleSumTree) node(start uint64, size uint64) MerkleSumNode {
+	if size == 1 {
+		return t.leaves[start]
+	}
+	return t.nodes[[2]uint64{start, size}]
+}
+
+func (t *MerkleSumTree) Root() MerkleSumNode {
+	return t.root
+}
+
+func (t *MerkleSumTree) Size() uint64 {
+	return uint64(len(t.leaves))
+}
+
+// InclusionProof is the audit path of the leaf at index, from the leaf up.
+func (t *MerkleSumTree) InclusionProof(index uint64) ([]MerkleSumNode, error) {
+	if index >= t.Size() {
+		return nil, fmt.Errorf("%w: leaf %d is not in a tree of %d", ErrInvalidLiabilityProof, index, t.Size())
+	}
+	path := make([]MerkleSumNode, 0)
+	start, size := uint64(0), t.Size()
+	for size > 1 {
+		k := largestPowerOfTwoBelow(size)
+		if index < start+k {
+			path = append(path, t.node(start+k, size-k))
+			size = k
+		} else {
+			path = append(path, t.node(start, k))
+			start, size = start+k, size-k
+		}
+	}
+	slices.Reverse(path)
+	return path, nil
+}
+
+// MerkleSumRoot is the root of the tree of leaves.
+func MerkleSumRoot(leaves []MerkleSumNode) (MerkleSumNode, error) {
+	tree, err := NewMerkleSumTree(leaves)
+	if err != nil {
+		return MerkleSumNode{}, err
+	}
+	return tree.Root(), nil
+}
+
+// MerkleSumBuilder computes the root of a Merkle sum tree one leaf at a time.
+// It only keeps the roots of its full subtrees, so a tree of millions of
+// leaves does not have to be in memory.
+type MerkleSumBuilder struct {
+	// roots of the full subtrees from the largest to the smallest
+	subtrees []MerkleSumNode
+	sizes    []uint64
+	size     uint64
+}
+
+// Add appends leaf to the tree.
+func (b *MerkleSumBuilder) Add(leaf MerkleSumNode) error {
+	b.subtrees = append(b.subtrees, leaf)
+	b.sizes = append(b.sizes, 1)
+	b.size++
+	for n := len(b.subtrees); n > 1 && b.sizes[n-2] == b.sizes[n-1]; n = len(b.subtrees) {
+		parent, err := merkleSumParent(b.subtrees[n-2], b.subtrees[n-1])
+		if err != nil {
+			return err
+		}
+		b.subtrees = append(b.subtrees[:n-2], parent)
+		b.sizes = append(b.sizes[:n-2], b.sizes[n-2]*2)
+	}
+	return nil
+}
+
+// Root is the root of the leaves added so far, the same one NewMerkleSumTree
+// has for them.
+func (b *MerkleSumBuilder) Root() (MerkleSumNode, error) {
+	if len(b.subtrees) == 0 {
+		hash := sha256.Sum256(nil)
+		return MerkleSumNode{Hash: hash[:], Sum: 0}, nil
+	}
+	root := b.subtrees[len(b.subtrees)-1]
+	for i := len(b.subtrees) - 2; i >= 0; i-- {
+		var err error
+		root, err = merkleSumParent(b.subtrees[i], root)
+		if err != nil {
+			return MerkleSumNode{}, err
+		}
+	}
+	return root, nil
+}
+
+func (b *MerkleSumBuilder) Size() uint64 {
+	return b.size
+}
+
+// VerifyMerkleSumInclusion checks the leaf at index is in the tree of size
+// with root, hash and sum.
+func VerifyMerkleSumInclusion(leaf MerkleSumNode, index uint64, size uint64, proof []MerkleSumNode, root MerkleSumNode) error {
+	if index >= size {
+		return fmt.Errorf("%w: leaf %d is not in a tree of %d", ErrInvalidLiabilityProof, index, size)
+	}
+	fn, sn := index, size-1
+	node := leaf
+	var err error
+	for _, sibling := range proof {
+		if sn == 0 {
+			return fmt.Errorf("%w: the path is too long", ErrInvalidLiabilityProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			node, err = merkleSumParent(sibling, node)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			node, err = merkleSumParent(node, sibling)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidLiabilityProof, err)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || node.Sum != root.Sum || !bytes.Equal(node.Hash, root.Hash) {
+		return ErrInvalidLiabilityProof
+	}
+	return nil
+}
+
+// KeysetLiabilities are the roots of the issued and redeemed trees of a
+// keyset. The sums of the roots are the totals and the counts the number of
+// leaves.
+type KeysetLiabilities struct {
+	Issued        MerkleSumNode `json:"issued"`
+	Redeemed      MerkleSumNode `json:"redeemed"`
+	Id            string        `json:"id"`
+	Unit          string        `json:"unit"`
+	IssuedCount   uint64        `json:"issued_count"`
+	RedeemedCount uint64        `json:"redeemed_count"`
+}
+
+// Outstanding is the ecash of the keyset still in the hands of users.
+func (k KeysetLiabilities) Outstanding() int64 {
+	return int64(k.Issued.Sum) - int64(k.Redeemed.Sum)
+}
+
+// UnitLiabilities adds up the keysets of a unit.
+type UnitLiabilities struct {
+	Unit        string `json:"unit"`
+	Issued      uint64 `json:"issued"`
+	Redeemed    uint64 `json:"redeemed"`
+	Outstanding int64  `json:"outstanding"`
+}
+
+// LiabilityReport is a signed report of the liabilities of every keyset.
+// Signature is a schnorr signature with the mint pubkey over SignedHash,
+// empty when the signer of the mint can not sign messages. Units is derived
+// from the keysets and is not signed.
+type LiabilityReport struct {
+	Keysets   []KeysetLiabilities `json:"keysets"`
+	Units     []UnitLiabilities   `json:"units"`
+	Signature string              `json:"signature,omitempty"`
+	Id        uint64              `json:"id"`
+	Timestamp int64               `json:"timestamp"`
+}
+
+func appendLengthPrefixed(data []byte, value []byte) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
+
+// SignedHash is the sha256 of the tag, the timestamp as big endian uint64 and,
+// for every keyset in order, its id and unit prefixed with their length as
+// big endian uint16, then the hash, sum and count of the issued and of the
+// redeemed tree.
+func (r LiabilityReport) SignedHash() []byte {
+	data := []byte(liabilityReportTag)
+	data = binary.BigEndian.AppendUint64(data, uint64(r.Timestamp))
+	for _, keyset := range r.Keysets {
+		data = appendLengthPrefixed(data, []byte(keyset.Id))
+		data = appendLengthPrefixed(data, []byte(keyset.Unit))
+		data = append(data, keyset.Issued.Hash...)
+		data = binary.BigEndian.AppendUint64(data, keyset.Issued.Sum)
+		data = binary.BigEndian.AppendUint64(data, keyset.IssuedCount)
+		data = append(data, keyset.Redeemed.Hash...)
+		data = binary.BigEndian.AppendUint64(data, keyset.Redeemed.Sum)
+		data = binary.BigEndian.AppendUint64(data, keyset.RedeemedCount)
+	}
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// Verify checks the report was signed by the mint with pubkey, a compressed
+// hex public key like the one in the mint info.
+func (r LiabilityReport) Verify(pubkey string) error {
+	err := verifyMintSignature(pubkey, r.Signature, r.SignedHash())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLiabilitySignature, err)
+	}
+	return nil
+}
+
+// PostLiabilityInclusionRequest asks for the proofs that outputs with
+// BlindedMessages and spent proofs with Ys of a keyset are in a report, the
+// latest one when ReportId is 0.
+type PostLiabilityInclusionRequest struct {
+	BlindedMessages []WrappedPublicKey `json:"blinded_messages"`
+	Ys              []WrappedPublicKey `json:"Ys"`
+	KeysetId        string             `json:"keyset_id"`
+	ReportId        uint64             `json:"report_id"`
+}
+
+// IssuedLiabilityProof proves the blind signature C_ on B_ is a leaf of the
+// issued tree.
+type IssuedLiabilityProof struct {
+	B_        WrappedPublicKey `json:"B_"`
+	C_        WrappedPublicKey `json:"C_"`
+	AuditPath []MerkleSumNode  `json:"audit_path"`
+	Amount    uint64           `json:"amount"`
+	LeafIndex uint64           `json:"leaf_index"`
+}
+
+func (p IssuedLiabilityProof) Verify(keyset KeysetLiabilities) error {
+	return VerifyMerkleSumInclusion(IssuedLiabilityLeaf(p.Amount, p.B_, p.C_), p.LeafIndex, keyset.IssuedCount, p.AuditPath, keyset.Issued)
+}
+
+// RedeemedLiabilityProof proves the spent proof with Y is a leaf of the
+// redeemed tree.
+type RedeemedLiabilityProof struct {
+	Y         WrappedPublicKey `json:"Y"`
+	AuditPath []MerkleSumNode  `json:"audit_path"`
+	Amount    uint64           `json:"amount"`
+	LeafIndex uint64           `json:"leaf_index"`
+}
+
+func (p RedeemedLiabilityProof) Verify(keyset KeysetLiabilities) error {
+	return VerifyMerkleSumInclusion(RedeemedLiabilityLeaf(p.Amount, p.Y), p.LeafIndex, keyset.RedeemedCount, p.AuditPath, keyset.Redeemed)
+}
+
+// PostLiabilityInclusionResponse has a proof for every requested output and
+// spent proof the report has. The ones missing from it were not counted.
+type PostLiabilityInclusionResponse struct {
+	Issued   []IssuedLiabilityProof   `json:"issued"`
+	Redeemed []RedeemedLiabilityProof `json:"redeemed"`
+	KeysetId string                   `json:"keyset_id"`
+	ReportId uint64                   `json:"report_id"`
+}
@@ -0,0 +1,158 @@
+package cashu
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func randomPoint(t *testing.T) WrappedPublicKey {
+	t.Helper()
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("btcec.NewPrivateKey() %+v", err)
+	}
+	return WrappedPublicKey{PublicKey: key.PubKey()}
+}
+
+func TestMerkleSumInclusionProofs(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := make([]MerkleSumNode, size)
+		var total uint64
+		for i := range leaves {
+			leaves[i] = RedeemedLiabilityLeaf(uint64(1)<<(i%10), randomPoint(t))
+			total += leaves[i].Sum
+		}
+		tree, err := NewMerkleSumTree(leaves)
+		if err != nil {
+			t.Fatalf("NewMerkleSumTree(%d) %+v", size, err)
+		}
+		root := tree.Root()
+		if root.Sum != total {
+			t.Fatalf("expected a root sum of %d, got %d", total, root.Sum)
+		}
+		for index := range leaves {
+			proof, err := tree.InclusionProof(uint64(index))
+			if err != nil {
+				t.Fatalf("tree.InclusionProof(%d, %d) %+v", size, index, err)
+			}
+			err = VerifyMerkleSumInclusion(leaves[index], uint64(index), uint64(size), proof, root)
+			if err != nil {
+				t.Errorf("leaf %d of %d: %+v", index, size, err)
+			}
+			// a mint that counts an output for less than it signed
+			smaller := MerkleSumNode{Hash: leaves[index].Hash, Sum: leaves[index].Sum - 1}
+			if VerifyMerkleSumInclusion(smaller, uint64(index), uint64(size), proof, root) == nil {
+				t.Errorf("leaf %d of %d: a leaf with another amount verified", index, size)
+			}
+		}
+	}
+}
+
+func TestMerkleSumBuilderMatchesTree(t *testing.T) {
+	var builder MerkleSumBuilder
+	leaves := make([]MerkleSumNode, 0)
+	for size := range 40 {
+		root, err := builder.Root()
+		if err != nil {
+			t.Fatalf("builder.Root() %+v", err)
+		}
+		expected, err := MerkleSumRoot(leaves)
+		if err != nil {
+			t.Fatalf("MerkleSumRoot(%d) %+v", size, err)
+		}
+		if builder.Size() != uint64(size) || root.Sum != expected.Sum || hex.EncodeToString(root.Hash) != hex.EncodeToString(expected.Hash) {
+			t.Fatalf("size %d: expected the root %+v, got %+v", size, expected, root)
+		}
+		leaf := IssuedLiabilityLeaf(uint64(size+1), randomPoint(t), randomPoint(t))
+		leaves = append(leaves, leaf)
+		err = builder.Add(leaf)
+		if err != nil {
+			t.Fatalf("builder.Add(leaf) %+v", err)
+		}
+	}
+}
+
+func TestMerkleSumRootOverflow(t *testing.T) {
+	leaves := []MerkleSumNode{
+		{Hash: make([]byte, 32), Sum: math.MaxUint64},
+		{Hash: make([]byte, 32), Sum: 1},
+	}
+	_, err := MerkleSumRoot(leaves)
+	if !errors.Is(err, ErrLiabilitySumOverflow) {
+		t.Errorf("expected ErrLiabilitySumOverflow, got %v", err)
+	}
+}
+
+func TestLiabilityReportProofsAndSignature(t *testing.T) {
+	B_, C_ := randomPoint(t), randomPoint(t)
+	issued := []MerkleSumNode{IssuedLiabilityLeaf(8, randomPoint(t), randomPoint(t)), IssuedLiabilityLeaf(4, B_, C_), IssuedLiabilityLeaf(2, randomPoint(t), randomPoint(t))}
+	issuedTree, err := NewMerkleSumTree(issued)
+	if err != nil {
+		t.Fatalf("NewMerkleSumTree(issued) %+v", err)
+	}
+	issuedRoot := issuedTree.Root()
+	redeemedRoot, err := MerkleSumRoot(nil)
+	if err != nil {
+		t.Fatalf("MerkleSumRoot(nil) %+v", err)
+	}
+	report := LiabilityReport{
+		Keysets:   []KeysetLiabilities{{Issued: issuedRoot, Redeemed: redeemedRoot, Id: "00ab", Unit: "sat", IssuedCount: 3, RedeemedCount: 0}},
+		Units:     nil,
+		Signature: "",
+		Id:        1,
+		Timestamp: 1700000000,
+	}
+	if report.Keysets[0].Outstanding() != 14 {
+		t.Errorf("expected 14 outstanding, got %d", report.Keysets[0].Outstanding())
+	}
+
+	path, err := issuedTree.InclusionProof(1)
+	if err != nil {
+		t.Fatalf("issuedTree.InclusionProof(1) %+v", err)
+	}
+	proof := IssuedLiabilityProof{B_: B_, C_: C_, AuditPath: path, Amount: 4, LeafIndex: 1}
+	encoded, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("json.Marshal(proof) %+v", err)
+	}
+	var decoded IssuedLiabilityProof
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatalf("json.Unmarshal(proof) %+v", err)
+	}
+	err = decoded.Verify(report.Keysets[0])
+	if err != nil {
+		t.Errorf("decoded.Verify(keyset) %+v", err)
+	}
+	decoded.Amount = 8
+	err = decoded.Verify(report.Keysets[0])
+	if !errors.Is(err, ErrInvalidLiabilityProof) {
+		t.Errorf("expected ErrInvalidLiabilityProof for another amount, got %v", err)
+	}
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("btcec.NewPrivateKey() %+v", err)
+	}
+	signature, err := schnorr.Sign(key, report.SignedHash())
+	if err != nil {
+		t.Fatalf("schnorr.Sign() %+v", err)
+	}
+	report.Signature = hex.EncodeToString(signature.Serialize())
+	pubkey := hex.EncodeToString(key.PubKey().SerializeCompressed())
+	err = report.Verify(pubkey)
+	if err != nil {
+		t.Fatalf("report.Verify(pubkey) %+v", err)
+	}
+	report.Keysets[0].Redeemed.Sum = 2
+	err = report.Verify(pubkey)
+	if !errors.Is(err, ErrInvalidLiabilitySignature) {
+		t.Errorf("expected ErrInvalidLiabilitySignature for a changed total, got %v", err)
+	}
+}
@@ -480,13 +480,19 @@ func VerifyProofCondition(proof Proof) error {
 	return nil
 }
 
-// VerifyProofsSpendConditions verifies P2PK and HTLC conditions for each proof individually.
+// VerifyProofsSpendConditions verifies P2PK and HTLC conditions for each proof
+// individually, on the workers of crypto.ForEach.
 func VerifyProofsSpendConditions(proofs Proofs) error {
 	for _, proof := range proofs {
-		err := VerifyProofCondition(proof)
+		if proof.C.PublicKey == nil {
+			return ErrInvalidProof
+		}
+	}
+	return crypto.ForEach(len(proofs), func(i int) error {
+		err := VerifyProofCondition(proofs[i])
 		if err != nil {
 			return fmt.Errorf("VerifyProofCondition(proof). %w", err)
 		}
-	}
-	return nil
+		return nil
+	})
 }
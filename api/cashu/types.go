@@ -193,6 +193,9 @@ type Seed struct {
 	Version        uint32
 	Active         bool
 	Legacy         bool `json:"legacy" db:"legacy"`
+	// MasterKeyId is the id of the master key that derives the seed, empty
+	// for seeds from before master keys were tagged.
+	MasterKeyId string `json:"master_key_id" db:"master_key_id"`
 }
 
 type SwapMintMethod struct {
@@ -234,6 +237,7 @@ type GetInfoResponse struct {
 	DescriptionLong string         `json:"description_long"`
 	Motd            string         `json:"motd"`
 	Contact         []ContactInfo  `json:"contact"`
+	Urls            []string       `json:"urls,omitempty"`
 	Time            int64          `json:"time"`
 }
 
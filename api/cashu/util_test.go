@@ -33,6 +33,7 @@ func TestOrderKeysetByUnit(t *testing.T) {
 		Amounts:        cashu.GetAmountsForKeysets(cashu.LegacyMaxKeysetAmount),
 		Legacy:         true,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 
 	generatedKeysets, err := localsigner.GenerateKeysets(key, seed)
@@ -0,0 +1,141 @@
+// nutmix-signer keeps the master key of a mint in its own process. It serves
+// the keysets of a local signer over the gRPC signatory protocol, so a mint
+// with SIGNER_TYPE abstract_socket or network signs through it. The seeds are
+// stored in the database of DATABASE_URL, which should not be the one of the
+// mint.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/keystore"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	signerserver "github.com/lescuer97/nutmix/internal/signer/signer_server"
+	"github.com/lescuer97/nutmix/internal/tracing"
+	"github.com/lescuer97/nutmix/pkg/crypto"
+	"google.golang.org/grpc"
+)
+
+const TRACING_EXPORTER_ENV = "TRACING_EXPORTER"
+
+func main() {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Printf("Did not find any .env file using environment variables!")
+	}
+
+	opts := new(slog.HandlerOptions)
+	opts.Level = slog.LevelInfo
+	if os.Getenv("DEBUG") == "true" {
+		opts.Level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(tracing.NewLogHandler(slog.NewJSONHandler(os.Stdout, opts))))
+
+	err = crypto.SetWorkersFromEnv()
+	if err != nil {
+		log.Fatalf("crypto.SetWorkersFromEnv(): %+v", err)
+	}
+
+	startupCtx, startupCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer startupCancel()
+	appCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(startupCtx, os.Getenv(TRACING_EXPORTER_ENV), os.Stdout)
+	if err != nil {
+		log.Fatalf("tracing.Setup(startupCtx, os.Getenv(TRACING_EXPORTER_ENV), os.Stdout): %+v ", err)
+	}
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			slog.Warn("shutdownTracing(context.Background())", slog.Any("error", err))
+		}
+	}()
+
+	server, err := setupServer(startupCtx)
+	if err != nil {
+		log.Fatalf("setupServer(startupCtx): %+v", err)
+	}
+
+	<-appCtx.Done()
+	slog.Info("Shutting down the signer...")
+	server.GracefulStop()
+}
+
+// setupServer loads the signer and starts serving it.
+func setupServer(ctx context.Context) (*grpc.Server, error) {
+	creds, err := signerserver.Credentials(os.Getenv(signerserver.TlsCertEnv), os.Getenv(signerserver.TlsKeyEnv), os.Getenv(signerserver.CaCertEnv))
+	if err != nil {
+		return nil, fmt.Errorf("signerserver.Credentials(cert, key, ca). %w", err)
+	}
+
+	db, err := postgresql.DatabaseSetup(ctx, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("postgresql.DatabaseSetup(ctx, migrations). %w", err)
+	}
+
+	privateKey, previousKeys, err := masterKeys()
+	if err != nil {
+		return nil, err
+	}
+	local, err := localsigner.SetupLocalSignerWithKeys(db, privateKey, previousKeys)
+	if err != nil {
+		return nil, fmt.Errorf("localsigner.SetupLocalSignerWithKeys(db, privateKey, previousKeys). %w", err)
+	}
+	pubkey, err := local.GetSignerPubkey()
+	if err != nil {
+		return nil, fmt.Errorf("local.GetSignerPubkey(). %w", err)
+	}
+
+	address := os.Getenv(signerserver.ListenAddressEnv)
+	listener, err := signerserver.Listen(address)
+	if err != nil {
+		return nil, fmt.Errorf("signerserver.Listen(address). %w", err)
+	}
+	server := signerserver.NewGrpcServer(signerserver.NewServer(&local), creds)
+	go func() {
+		slog.Info("Signer served", slog.String("address", listener.Addr().String()), slog.String("pubkey", pubkey))
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			slog.Error("signer server failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}()
+	return server, nil
+}
+
+// masterKeys returns nil to read MINT_PRIVATE_KEY, or the keys of the
+// keystores in MINT_KEYSTORE_FILE and MINT_PREVIOUS_KEYSTORE_FILES. Nobody can
+// unlock the signer after it started, so the passphrase has to come from
+// MINT_KEYSTORE_PASSPHRASE_FD or the terminal.
+func masterKeys() ([]byte, [][]byte, error) {
+	path := os.Getenv(secrets.KeystoreFileEnv)
+	if path == "" {
+		return nil, nil, nil
+	}
+	current, previous, err := keystore.Read(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("keystore.Read(path). %w", err)
+	}
+
+	var privateKey []byte
+	var previousKeys [][]byte
+	err = keystore.Unlock(func(passphrase string) error {
+		privateKey, previousKeys, err = keystore.UnlockKeys(current, previous, passphrase)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("keystore.Unlock(unlock). %w", err)
+	}
+	return privateKey, previousKeys, nil
+}
@@ -363,6 +363,8 @@ func TestRotateKeyUpCall(t *testing.T) {
 		Fee:              100,
 		Unit:             cashu.Sat,
 		ExpireLimitHours: 24,
+		Amounts:          nil,
+		MaxOrder:         0,
 	}
 
 	jsonRequestBody, err = json.Marshal(rotatingFeeRequest)
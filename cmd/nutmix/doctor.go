@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/joho/godotenv"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database/goose"
+	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/keystore"
+	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/routes/admin"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	"github.com/lescuer97/nutmix/internal/signer"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	pkcs11signer "github.com/lescuer97/nutmix/internal/signer/pkcs11_signer"
+	remoteSigner "github.com/lescuer97/nutmix/internal/signer/remote_signer"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+// DoctorCommand runs the startup checks and prints a report instead of
+// starting the mint: nutmix doctor
+const DoctorCommand = "doctor"
+
+const doctorCheckTimeout = 20 * time.Second
+
+type DoctorStatus string
+
+const (
+	DoctorOk   DoctorStatus = "ok"
+	DoctorWarn DoctorStatus = "warn"
+	DoctorFail DoctorStatus = "fail"
+	DoctorSkip DoctorStatus = "skip"
+)
+
+type DoctorResult struct {
+	Name   string
+	Status DoctorStatus
+	Detail string
+}
+
+// doctor collects the results of the checks. Checks later in the list use
+// what the earlier ones loaded and are skipped when it is missing.
+type doctor struct {
+	db          *postgresql.Postgresql
+	config      *utils.Config
+	nostrConfig *utils.NostrNotificationConfig
+	signer      signer.Signer
+	seeds       []cashu.Seed
+	results     []DoctorResult
+	// pendingMigrations is set when tables the checks read may not exist yet
+	pendingMigrations bool
+}
+
+func (d *doctor) add(name string, status DoctorStatus, detail string, args ...any) {
+	// errors from pgx and grpc can span lines, keep one line per check
+	message := strings.ReplaceAll(fmt.Sprintf(detail, args...), "\n", "; ")
+	message = strings.Join(strings.Fields(message), " ")
+	d.results = append(d.results, DoctorResult{Name: name, Status: status, Detail: message})
+}
+
+func (d *doctor) failed() bool {
+	return slices.ContainsFunc(d.results, func(result DoctorResult) bool {
+		return result.Status == DoctorFail
+	})
+}
+
+// withTimeout runs calls that do not take a context, like the lightning and
+// signer setup, so a node that never answers shows up as a failed check.
+func withTimeout[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	ctx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value: value, err: err}
+	}()
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, fmt.Errorf("no answer after %v", doctorCheckTimeout)
+	}
+}
+
+// RunDoctor checks the configuration the mint needs to start and writes a
+// report to out. It does not serve traffic or run the migrations. It returns
+// the exit code: 1 when a check failed.
+func RunDoctor(ctx context.Context, out io.Writer) int {
+	var d doctor
+	defer func() {
+		if d.db != nil {
+			d.db.Close()
+		}
+	}()
+
+	d.checkDatabase(ctx)
+	d.checkEncryption(ctx)
+	d.checkConfig(ctx)
+	d.checkNetwork()
+	d.checkLightning(ctx)
+	d.checkSigner(ctx, os.Getenv("SIGNER_TYPE"))
+	d.checkKeysets()
+	d.checkTlsFiles()
+	d.checkOidc(ctx)
+	d.checkNostrNotifications()
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, result := range d.results {
+		_, _ = fmt.Fprintf(w, "[%s]\t%s\t%s\n", result.Status, result.Name, result.Detail)
+	}
+	_ = w.Flush()
+
+	if d.failed() {
+		_, _ = fmt.Fprintln(out, "\nnutmix will not start correctly, fix the failed checks above.")
+		return 1
+	}
+	_, _ = fmt.Fprintln(out, "\nall checks passed.")
+	return 0
+}
+
+func (d *doctor) checkDatabase(ctx context.Context) {
+	db, err := withTimeout(ctx, func() (postgresql.Postgresql, error) {
+		return postgresql.DatabaseConnect(ctx)
+	})
+	if err != nil {
+		d.add("database", DoctorFail, "could not connect: %v", err)
+		return
+	}
+	sqlDB := db.SqlDB()
+	defer func() {
+		_ = sqlDB.Close()
+	}()
+	// the pool connects lazily, so ping to find a wrong url or password now
+	pingCtx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	err = sqlDB.PingContext(pingCtx)
+	if err != nil {
+		db.Close()
+		d.add("database", DoctorFail, "could not connect: %v", err)
+		return
+	}
+	d.db = &db
+	d.add("database", DoctorOk, "connected")
+
+	current, latest, err := goose.MigrationVersion(sqlDB, goose.POSTGRES)
+	switch {
+	case err != nil:
+		d.add("migrations", DoctorFail, "could not read the migration version: %v", err)
+	case current > latest:
+		d.add("migrations", DoctorFail, "database is at version %d but this build only knows up to %d, it was migrated by a newer nutmix", current, latest)
+	case current < latest:
+		d.pendingMigrations = true
+		d.add("migrations", DoctorWarn, "database is at version %d, %d migrations will run on start", current, latest-current)
+	default:
+		d.add("migrations", DoctorOk, "database is at the latest version %d", current)
+	}
+}
+
+// checkEncryption unwraps the data keys so the config check can read the
+// encrypted credentials.
+func (d *doctor) checkEncryption(ctx context.Context) {
+	kms, err := secrets.KMSFromEnv()
+	if err != nil {
+		d.add("config encryption", DoctorFail, "%v", err)
+		return
+	}
+	if d.db == nil {
+		d.add("config encryption", DoctorSkip, "no database connection")
+		return
+	}
+	if d.pendingMigrations {
+		d.add("config encryption", DoctorSkip, "runs after the pending migrations")
+		return
+	}
+
+	envelope, err := withTimeout(ctx, func() (*secrets.Envelope, error) {
+		return secrets.LoadEnvelope(ctx, *d.db, kms)
+	})
+	switch {
+	case err != nil:
+		d.add("config encryption", DoctorFail, "%v", err)
+	case envelope == nil && kms == nil:
+		d.add("config encryption", DoctorWarn, "backend credentials are stored in plaintext, set %s or %s", secrets.PassphraseEnv, secrets.KeyFileEnv)
+	case envelope == nil:
+		d.add("config encryption", DoctorOk, "the credentials are encrypted with the %s kms on start", kms.Name())
+	default:
+		*d.db = d.db.WithEnvelope(envelope)
+		d.add("config encryption", DoctorOk, "data key %d unwrapped with the %s kms", envelope.ActiveKeyId(), kms.Name())
+	}
+}
+
+// checkConfig reads the stored config without the bootstrap of
+// mint.SetUpConfigDB, which writes a default config when there is none.
+func (d *doctor) checkConfig(ctx context.Context) {
+	if d.db == nil {
+		d.add("config", DoctorSkip, "no database connection")
+		return
+	}
+	tx, err := d.db.GetTx(ctx)
+	if err != nil {
+		d.add("config", DoctorFail, "d.db.GetTx(ctx): %v", err)
+		return
+	}
+	defer func() {
+		_ = d.db.Rollback(ctx, tx)
+	}()
+
+	config, err := d.db.GetConfig(tx)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		d.add("config", DoctorWarn, "no config stored yet, it will be created from %s or the defaults on first start", mint.ConfigFileName)
+		return
+	case err != nil:
+		d.add("config", DoctorFail, "could not read the config: %v", err)
+		return
+	}
+	d.config = &config
+	d.add("config", DoctorOk, "lightning backend %s on %s", config.MINT_LIGHTNING_BACKEND, config.NETWORK)
+
+	nostrConfig, err := d.db.GetNostrNotificationConfig(tx)
+	if err != nil {
+		d.add("nostr notifications", DoctorFail, "could not read the notification config: %v", err)
+		return
+	}
+	d.nostrConfig = nostrConfig
+}
+
+func (d *doctor) checkNetwork() {
+	if d.config == nil {
+		d.add("network", DoctorSkip, "no config")
+		return
+	}
+	_, err := mint.CheckChainParams(d.config.NETWORK)
+	if err != nil {
+		d.add("network", DoctorFail, "%v", err)
+		return
+	}
+	d.add("network", DoctorOk, "%s", d.config.NETWORK)
+}
+
+func (d *doctor) checkLightning(ctx context.Context) {
+	if d.config == nil {
+		d.add("lightning", DoctorSkip, "no config")
+		return
+	}
+	backend, err := mint.SetupLightningBackend(*d.config)
+	if err != nil {
+		d.add("lightning", DoctorFail, "could not set up %s: %v", d.config.MINT_LIGHTNING_BACKEND, err)
+		return
+	}
+	balance, err := withTimeout(ctx, backend.WalletBalance)
+	if err != nil {
+		d.add("lightning", DoctorFail, "%s is not reachable: %v", d.config.MINT_LIGHTNING_BACKEND, err)
+		return
+	}
+	d.add("lightning", DoctorOk, "%s reachable, balance %d msat", d.config.MINT_LIGHTNING_BACKEND, balance.Amount)
+
+	configured := backend.GetNetwork().Name
+	reporter, ok := backend.(lightning.NodeNetworkReporter)
+	if !ok {
+		d.add("lightning network", DoctorSkip, "%s does not report its network", d.config.MINT_LIGHTNING_BACKEND)
+		return
+	}
+	nodeNetwork, err := withTimeout(ctx, func() (string, error) {
+		return reporter.NodeNetwork(ctx)
+	})
+	switch {
+	case err != nil:
+		d.add("lightning network", DoctorFail, "could not ask the node for its network: %v", err)
+	case nodeNetwork != configured:
+		d.add("lightning network", DoctorFail, "NETWORK is %s but the node runs on %s", configured, nodeNetwork)
+	default:
+		d.add("lightning network", DoctorOk, "node runs on %s", nodeNetwork)
+	}
+}
+
+func (d *doctor) checkSigner(ctx context.Context, signerType string) {
+	if d.db != nil {
+		seeds, err := d.db.GetAllSeeds()
+		if err != nil {
+			d.add("signer", DoctorFail, "could not read the seeds: %v", err)
+			return
+		}
+		d.seeds = seeds
+	}
+
+	switch signerType {
+	case MemorySigner:
+		if d.db == nil {
+			d.add("signer", DoctorSkip, "no database connection to read the seeds")
+			return
+		}
+		privateKey, previousKeys, ok := d.keystoreKeys()
+		if !ok {
+			return
+		}
+		keySource := MINT_PRIVATE_KEY_ENV
+		if privateKey != nil {
+			keySource = "the keystore"
+		}
+		pubkey, err := localsigner.VerifySeeds(d.seeds, privateKey, previousKeys)
+		if err != nil {
+			d.add("signer", DoctorFail, "%s does not derive the stored keysets: %v", keySource, err)
+			return
+		}
+		d.add("signer", DoctorOk, "memory signer with pubkey %s derives the %d stored keysets", pubkey, len(d.seeds))
+	case Pkcs11Signer:
+		if d.db == nil {
+			d.add("signer", DoctorSkip, "no database connection to read the seeds")
+			return
+		}
+		config, err := pkcs11signer.ConfigFromEnv()
+		if err != nil {
+			d.add("signer", DoctorFail, "%v", err)
+			return
+		}
+		pubkey, err := pkcs11signer.VerifySeeds(config, d.seeds)
+		if err != nil {
+			d.add("signer", DoctorFail, "token %s does not have the stored keysets: %v", config.TokenLabel, err)
+			return
+		}
+		d.add("signer", DoctorOk, "pkcs11 signer with pubkey %s has the %d stored keysets", pubkey, len(d.seeds))
+	case AbstractSocketSigner, NetworkSigner:
+		tlsFiles := []string{remoteSigner.ClientTlsCertEnv, remoteSigner.ClientTlsKeyEnv}
+		if os.Getenv(remoteSigner.CaCertEnv) != "" {
+			tlsFiles = append(tlsFiles, remoteSigner.CaCertEnv)
+		}
+		err := checkFiles(tlsFiles...)
+		if err != nil {
+			d.add("signer tls", DoctorFail, "%v", err)
+			return
+		}
+		d.add("signer tls", DoctorOk, "client certificate and key are readable")
+
+		remote, err := withTimeout(ctx, func() (signer.Signer, error) {
+			// the remote signers do not use the database
+			return GetSignerFromValue(signerType, nil)
+		})
+		if err != nil {
+			d.add("signer", DoctorFail, "%s signer is not reachable: %v", signerType, err)
+			return
+		}
+		pubkey, err := remote.GetSignerPubkey()
+		if err != nil {
+			d.add("signer", DoctorFail, "remote.GetSignerPubkey(): %v", err)
+			return
+		}
+		d.signer = remote
+		d.add("signer", DoctorOk, "%s signer reachable with pubkey %s", signerType, pubkey)
+	case "":
+		d.add("signer", DoctorFail, "SIGNER_TYPE is not set, use %s, %s, %s or %s", MemorySigner, AbstractSocketSigner, NetworkSigner, Pkcs11Signer)
+	default:
+		d.add("signer", DoctorFail, "unknown SIGNER_TYPE %q", signerType)
+	}
+}
+
+// keystoreKeys unlocks the keystore of the memory signer when the passphrase
+// can be read without asking. It returns nil when MINT_PRIVATE_KEY is used.
+func (d *doctor) keystoreKeys() ([]byte, [][]byte, bool) {
+	path := os.Getenv(secrets.KeystoreFileEnv)
+	if path == "" {
+		return nil, nil, true
+	}
+	current, previous, err := keystore.Read(path)
+	if errors.Is(err, keystore.ErrKeystoreAndPrivateKey) {
+		d.add("signer", DoctorFail, "%v", err)
+		return nil, nil, false
+	}
+	if err != nil {
+		d.add("signer", DoctorFail, "could not read the keystores: %v", err)
+		return nil, nil, false
+	}
+	fd := os.Getenv(secrets.KeystorePassphraseFdEnv)
+	if fd == "" {
+		d.add("signer", DoctorWarn, "keystore with pubkey %s is locked, set %s to check it derives the %d stored keysets", current.Pubkey, secrets.KeystorePassphraseFdEnv, len(d.seeds))
+		return nil, nil, false
+	}
+	passphrase, err := secrets.PassphraseFromFd(fd)
+	if err != nil {
+		d.add("signer", DoctorFail, "could not read the keystore passphrase: %v", err)
+		return nil, nil, false
+	}
+	privateKey, previousKeys, err := keystore.UnlockKeys(current, previous, passphrase)
+	if err != nil {
+		d.add("signer", DoctorFail, "could not unlock the keystores: %v", err)
+		return nil, nil, false
+	}
+	return privateKey, previousKeys, true
+}
+
+// checkKeysets compares the keysets a remote signer serves with the seeds in
+// the database. The memory signer derives them from the seeds, which
+// checkSigner already verified.
+func (d *doctor) checkKeysets() {
+	if d.signer == nil {
+		return
+	}
+	keysets, err := d.signer.GetKeysets()
+	if err != nil {
+		d.add("keysets", DoctorFail, "d.signer.GetKeysets(): %v", err)
+		return
+	}
+	if len(keysets.Keysets) == 0 {
+		d.add("keysets", DoctorFail, "the signer has no keysets")
+		return
+	}
+	served := make(map[string]bool, len(keysets.Keysets))
+	for _, keyset := range keysets.Keysets {
+		served[keyset.Id] = true
+	}
+	var missing []string
+	for _, seed := range d.seeds {
+		if seed.Unit != cashu.AUTH.String() && !served[seed.Id] {
+			missing = append(missing, seed.Id)
+		}
+	}
+	if len(missing) > 0 {
+		d.add("keysets", DoctorFail, "the signer does not serve keysets stored in the database: %s", strings.Join(missing, ", "))
+		return
+	}
+	d.add("keysets", DoctorOk, "the signer serves %d keysets", len(keysets.Keysets))
+}
+
+// checkFiles checks that the files named by the env variables are set and
+// readable.
+func checkFiles(envs ...string) error {
+	var errs []error
+	for _, env := range envs {
+		path := os.Getenv(env)
+		if path == "" {
+			errs = append(errs, fmt.Errorf("%s is not set", env))
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			continue
+		}
+		_ = file.Close()
+	}
+	return errors.Join(errs...)
+}
+
+func (d *doctor) checkTlsFiles() {
+	if os.Getenv(admin.MintRpcAddressEnv) == "" {
+		return
+	}
+	_, err := admin.MintRpcCredentials(os.Getenv(admin.MintRpcTlsCertEnv), os.Getenv(admin.MintRpcTlsKeyEnv), os.Getenv(admin.MintRpcCaCertEnv))
+	if err != nil {
+		d.add("mint rpc tls", DoctorFail, "%v", err)
+		return
+	}
+	d.add("mint rpc tls", DoctorOk, "server certificate, key and CA are readable")
+}
+
+func (d *doctor) checkOidc(ctx context.Context) {
+	if d.config == nil {
+		d.add("oidc", DoctorSkip, "no config")
+		return
+	}
+	if !d.config.MINT_REQUIRE_AUTH {
+		d.add("oidc", DoctorSkip, "auth is not required")
+		return
+	}
+	if d.config.MINT_AUTH_OICD_URL == "" {
+		d.add("oidc", DoctorFail, "auth is required but there is no discovery url")
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	_, err := oidc.NewProvider(ctx, d.config.MINT_AUTH_OICD_URL)
+	if err != nil {
+		d.add("oidc", DoctorFail, "discovery url %s: %v", d.config.MINT_AUTH_OICD_URL, err)
+		return
+	}
+	d.add("oidc", DoctorOk, "discovery url %s", d.config.MINT_AUTH_OICD_URL)
+}
+
+func (d *doctor) checkNostrNotifications() {
+	if d.nostrConfig == nil {
+		d.add("nostr notifications", DoctorSkip, "no config")
+		return
+	}
+	if !d.nostrConfig.NOSTR_NOTIFICATIONS {
+		d.add("nostr notifications", DoctorSkip, "notifications are disabled")
+		return
+	}
+	_, err := utils.ReadNostrNotificationNsec()
+	if err != nil {
+		d.add("nostr notifications", DoctorFail, "notifications are enabled but the nsec is not usable: %v", err)
+		return
+	}
+	if len(d.nostrConfig.NOSTR_NOTIFICATION_NPUBS) == 0 {
+		d.add("nostr notifications", DoctorWarn, "the nsec is valid but there are no npubs to notify")
+		return
+	}
+	d.add("nostr notifications", DoctorOk, "the nsec is valid, %d npubs are notified", len(d.nostrConfig.NOSTR_NOTIFICATION_NPUBS))
+}
+
+func doctorMain() int {
+	err := godotenv.Load(".env")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "godotenv.Load(.env): %v\n", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	return RunDoctor(ctx, os.Stdout)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func TestDoctorWithoutDatabase(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("SIGNER_TYPE", NetworkSigner)
+	t.Setenv("SIGNER_CLIENT_TLS_CERT", filepath.Join(t.TempDir(), "missing.pem"))
+	t.Setenv("SIGNER_CLIENT_TLS_KEY", "")
+
+	var out bytes.Buffer
+	code := RunDoctor(t.Context(), &out)
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	report := out.String()
+	for _, expected := range []string{
+		"[fail]  database",
+		"[skip]  config",
+		"[fail]  signer tls",
+		"SIGNER_CLIENT_TLS_KEY is not set",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected %q in the report:\n%s", expected, report)
+		}
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	err := os.WriteFile(path, []byte("cert"), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	t.Setenv("DOCTOR_TEST_CERT", path)
+	t.Setenv("DOCTOR_TEST_MISSING", path+".missing")
+
+	err = checkFiles("DOCTOR_TEST_CERT")
+	if err != nil {
+		t.Errorf("expected a readable file to pass, got %v", err)
+	}
+	err = checkFiles("DOCTOR_TEST_CERT", "DOCTOR_TEST_MISSING")
+	if err == nil || !strings.Contains(err.Error(), "DOCTOR_TEST_MISSING") {
+		t.Errorf("expected the missing file to be reported, got %v", err)
+	}
+}
+
+func TestDoctorMemorySigner(t *testing.T) {
+	const posgrespassword = "password"
+	const postgresuser = "user"
+	ctx := context.Background()
+
+	postgresContainer, err := postgres.Run(ctx, "postgres:16.2",
+		postgres.WithDatabase("postgres"),
+		postgres.WithUsername(postgresuser),
+		postgres.WithPassword(posgrespassword),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Second)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connUri, err := postgresContainer.ConnectionString(ctx)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to get connection string: %w", err))
+	}
+
+	t.Setenv("DATABASE_URL", connUri)
+	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
+	t.Setenv("SIGNER_TYPE", MemorySigner)
+	t.Setenv("MINT_LIGHTNING_BACKEND", "FakeWallet")
+	t.Setenv("NETWORK", "regtest")
+
+	// stores the config and the first seed
+	_, _ = SetupRoutingForTesting(ctx, false)
+
+	var out bytes.Buffer
+	code := RunDoctor(ctx, &out)
+	if code != 0 {
+		t.Fatalf("expected every check to pass, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[ok]    signer") || !strings.Contains(out.String(), "[ok]    migrations") {
+		t.Errorf("expected the signer and migrations to pass:\n%s", out.String())
+	}
+
+	t.Setenv("MINT_PRIVATE_KEY", "0000000000000000000000000000000000000000000000000000000000000002")
+	out.Reset()
+	code = RunDoctor(ctx, &out)
+	if code != 1 || !strings.Contains(out.String(), "MINT_PRIVATE_KEY does not derive the stored keysets") {
+		t.Errorf("expected a different private key to fail the signer check, got:\n%s", out.String())
+	}
+}
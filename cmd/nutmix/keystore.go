@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/keystore"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+// SetupKeystoreSigner starts the memory signer from an encrypted keystore,
+// and the keystores of the previous master keys in MINT_PREVIOUS_KEYSTORE_FILES.
+// The passphrase is read from MINT_KEYSTORE_PASSPHRASE_FD or asked for on the
+// terminal. Without either the mint starts locked until an owner unlocks it
+// from the dashboard or the admin api.
+func SetupKeystoreSigner(db database.MintDB, path string) (*localsigner.LockedSigner, error) {
+	current, previous, err := keystore.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("keystore.Read(path). %w", err)
+	}
+	locked := localsigner.NewLockedSigner(db, current, previous...)
+
+	err = keystore.Unlock(locked.Unlock)
+	switch {
+	case errors.Is(err, keystore.ErrNoPassphrase):
+		slog.Warn("The mint starts locked. An owner can unlock it on the keysets page of the admin dashboard or with the admin api")
+	case errors.Is(err, keystore.ErrTooManyAttempts):
+		slog.Warn("Could not unlock the keystore, the mint starts locked")
+	case err != nil:
+		return nil, fmt.Errorf("keystore.Unlock(locked.Unlock). %w", err)
+	}
+	return locked, nil
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,19 +18,27 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/lescuer97/nutmix/api/cashu"
 	"github.com/lescuer97/nutmix/internal/database"
 	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/metrics"
 	"github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/routes"
 	"github.com/lescuer97/nutmix/internal/routes/admin"
 	"github.com/lescuer97/nutmix/internal/routes/middleware"
+	"github.com/lescuer97/nutmix/internal/scheduler"
+	"github.com/lescuer97/nutmix/internal/secrets"
 	"github.com/lescuer97/nutmix/internal/signer"
 	"github.com/lescuer97/nutmix/internal/stats"
+	"github.com/lescuer97/nutmix/internal/tracing"
 	"github.com/lightningnetwork/lnd/zpay32"
+	"google.golang.org/grpc"
 
 	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	pkcs11signer "github.com/lescuer97/nutmix/internal/signer/pkcs11_signer"
 	remoteSigner "github.com/lescuer97/nutmix/internal/signer/remote_signer"
 	"github.com/lescuer97/nutmix/internal/utils"
+	"github.com/lescuer97/nutmix/pkg/crypto"
 )
 
 var (
@@ -37,9 +46,24 @@ var (
 	MODE_ENV             = "MODE"
 	MINT_PRIVATE_KEY_ENV = "MINT_PRIVATE_KEY"
 	PORT                 = "PORT"
+	CACHE_BACKEND_ENV    = "CACHE_BACKEND"
+	WEBSOCKET_RELAY_ENV  = "WEBSOCKET_RELAY"
+	REDIS_ADDRESS_ENV    = "REDIS_ADDRESS"
+	REDIS_PASSWORD_ENV   = "REDIS_PASSWORD"
+	METRICS_ENABLED_ENV  = "METRICS_ENABLED"
+	METRICS_PORT_ENV     = "METRICS_PORT"
+	TRACING_EXPORTER_ENV = "TRACING_EXPORTER"
+	// comma separated relays the keyset log roots are published to
+	KEYSET_LOG_NOSTR_RELAYS_ENV = "KEYSET_LOG_NOSTR_RELAYS"
 )
 
+const responseCacheExpiration = 45 * time.Minute
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == DoctorCommand {
+		os.Exit(doctorMain())
+	}
+
 	logsdir, err := utils.GetLogsDirectory()
 	if err != nil {
 		log.Panicln("Could not get Logs directory")
@@ -82,13 +106,30 @@ func main() {
 		opts.AddSource = true
 	}
 
-	baseJSONHandler := slog.NewJSONHandler(w, opts)
+	baseJSONHandler := tracing.NewLogHandler(slog.NewJSONHandler(w, opts))
+
+	err = crypto.SetWorkersFromEnv()
+	if err != nil {
+		log.Fatalf("crypto.SetWorkersFromEnv(): %+v", err)
+	}
 
 	startupCtx, startupCancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer startupCancel()
 	appCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	shutdownTracing, err := tracing.Setup(startupCtx, os.Getenv(TRACING_EXPORTER_ENV), os.Stdout)
+	if err != nil {
+		log.Fatalf("tracing.Setup(startupCtx, os.Getenv(TRACING_EXPORTER_ENV), os.Stdout): %+v ", err)
+	}
+	defer func() {
+		// flush the spans still in the batcher
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			slog.Warn("shutdownTracing(context.Background())", slog.Any("error", err))
+		}
+	}()
+
 	db, err := postgresql.DatabaseSetup(startupCtx, "migrations")
 	if err != nil {
 		slog.Error("Error conecting to db", slog.Any("error", err))
@@ -96,18 +137,29 @@ func main() {
 	}
 	defer db.Close()
 
+	kms, err := secrets.KMSFromEnv()
+	if err != nil {
+		log.Fatalf("secrets.KMSFromEnv(): %+v ", err)
+	}
+	db, err = db.SetupEncryption(startupCtx, kms)
+	if err != nil {
+		log.Fatalf("db.SetupEncryption(startupCtx, kms): %+v ", err)
+	}
+
 	config, nostrNotificationConfig, err := mint.SetUpConfigDB(startupCtx, db)
 	if err != nil {
 		log.Fatalf("mint.SetUpConfigDB(ctx, db): %+v ", err)
 	}
 
-	signer, err := GetSignerFromValue(os.Getenv("SIGNER_TYPE"), db)
+	mintSigner, err := GetSignerFromValue(os.Getenv("SIGNER_TYPE"), db)
 	if err != nil {
 		log.Fatalf("signer.GetSignerFromValue(os.Getenv(), db): %+v ", err)
 	}
+	mintSigner = signer.Instrument(mintSigner)
+	metricsEnabled := os.Getenv(METRICS_ENABLED_ENV) == "true"
 
 	// remove mint private key from variable
-	mint, err := mint.SetUpMint(startupCtx, config, nostrNotificationConfig, db, signer)
+	mint, err := mint.SetUpMint(startupCtx, config, nostrNotificationConfig, db, mintSigner)
 
 	if err != nil {
 		slog.Warn("SetUpMint", slog.Any("error", err))
@@ -122,29 +174,56 @@ func main() {
 	r.Use(gin.LoggerWithWriter(w))
 
 	r.Use(cors.Default())
+
+	r.Use(tracing.GinMiddleware()...)
+
+	var metricsSrv *http.Server
+	if metricsEnabled {
+		err = RegisterMintMetrics(mint, db)
+		if err != nil {
+			slog.Error("RegisterMintMetrics(mint, db)", slog.Any("error", err))
+			return
+		}
+		r.Use(metrics.GinMiddleware())
+
+		metricsSrv, err = SetupMetricsServer(r, os.Getenv(METRICS_PORT_ENV))
+		if err != nil {
+			slog.Error("SetupMetricsServer(r, os.Getenv(METRICS_PORT_ENV))", slog.Any("error", err))
+			return
+		}
+	}
 	// // gzip compression
 	// r.Use(gzip.Gzip(gzip.DefaultCompression))
 
-	store := persistence.NewInMemoryStore(45 * time.Minute)
+	store, err := GetResponseStore(appCtx, os.Getenv(CACHE_BACKEND_ENV), db)
+	if err != nil {
+		slog.Error("GetResponseStore(appCtx, os.Getenv(CACHE_BACKEND_ENV), db)", slog.Any("error", err))
+		return
+	}
 
 	r.Use(middleware.CacheMiddleware(store))
 
+	if os.Getenv(WEBSOCKET_RELAY_ENV) == PostgresBackend {
+		mint.Observer.StartRelay(appCtx, db)
+	}
+
 	// Add per-request timeout middleware (sets context deadline for handlers)
 	r.Use(middleware.TimeoutMiddleware(90 * time.Second))
 
+	// pending melts are settled before the mint takes requests, the job below
+	// only keeps them settled afterwards
 	err = mint.ReconcilePendingMeltQuotes()
 	if err != nil {
-		slog.Error("SetUpMint", slog.Any("error", err))
+		slog.Error("mint.ReconcilePendingMeltQuotes()", slog.Any("error", err))
 		return
 	}
 
 	statsService := stats.Service{
-		DB:        db,
-		Now:       time.Now,
-		Logger:    nil,
-		NewTicker: nil,
+		DB:     db,
+		Now:    time.Now,
+		Logger: nil,
 		DecodeMintAmount: func(request string) (uint64, error) {
-			invoice, err := zpay32.Decode(request, mint.LightningBackend.GetNetwork())
+			invoice, err := zpay32.Decode(request, mint.LightningBackend().GetNetwork())
 			if err != nil {
 				return 0, err
 			}
@@ -154,11 +233,25 @@ func main() {
 			return uint64(invoice.MilliSat.ToSatoshis()), nil
 		},
 	}
-	go statsService.Run(appCtx, 15*time.Minute)
+
+	jobs := scheduler.New(db)
+	err = RegisterMintJobs(jobs, mint, statsService, keysetLogRelays(os.Getenv(KEYSET_LOG_NOSTR_RELAYS_ENV)))
+	if err != nil {
+		slog.Error("RegisterMintJobs(jobs, mint, statsService, relays)", slog.Any("error", err))
+		return
+	}
 
 	routes.V1Routes(r, mint)
 
-	admin.AdminRoutes(appCtx, r, mint)
+	admin.AdminRoutes(appCtx, r, mint, jobs)
+
+	jobs.Start(appCtx)
+
+	mintRpcServer, err := SetupMintRpcServer(mint, os.Getenv(admin.MintRpcAddressEnv))
+	if err != nil {
+		slog.Error("SetupMintRpcServer(mint, os.Getenv(admin.MintRpcAddressEnv))", slog.Any("error", err))
+		return
+	}
 
 	PORT = ":8081"
 	PORTStr := os.Getenv("PORT")
@@ -196,15 +289,32 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("server forced to shutdown", slog.Any("error", err))
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("metrics server forced to shutdown", slog.Any("error", err))
+		}
+	}
+	if mintRpcServer != nil {
+		mintRpcServer.GracefulStop()
+	}
 }
 
 const MemorySigner = "memory"
 const AbstractSocketSigner = "abstract_socket"
 const NetworkSigner = "network"
+const Pkcs11Signer = "pkcs11"
 
 func GetSignerFromValue(signerType string, db database.MintDB) (signer.Signer, error) {
 	switch signerType {
 	case MemorySigner:
+		keystorePath := os.Getenv(secrets.KeystoreFileEnv)
+		if keystorePath != "" {
+			signer, err := SetupKeystoreSigner(db, keystorePath)
+			if err != nil {
+				return nil, fmt.Errorf("SetupKeystoreSigner(db, keystorePath): %w", err)
+			}
+			return signer, nil
+		}
 		signer, err := localsigner.SetupLocalSigner(db)
 		if err != nil {
 			return &signer, fmt.Errorf("localsigner.SetupLocalSigner(db): %w", err)
@@ -213,18 +323,274 @@ func GetSignerFromValue(signerType string, db database.MintDB) (signer.Signer, e
 	case AbstractSocketSigner:
 		signer, err := remoteSigner.SetupRemoteSigner(false, os.Getenv("NETWORK_SIGNER_ADDRESS"))
 		if err != nil {
-			return &signer, fmt.Errorf("socketremotesigner.SetupSocketSigner(): %w", err)
+			return nil, fmt.Errorf("socketremotesigner.SetupSocketSigner(): %w", err)
 		}
-		return &signer, nil
+		return signer, nil
 
 	case NetworkSigner:
 		signer, err := remoteSigner.SetupRemoteSigner(true, os.Getenv("NETWORK_SIGNER_ADDRESS"))
 		if err != nil {
-			return &signer, fmt.Errorf("socketremotesigner.SetupSocketSigner(): %w", err)
+			return nil, fmt.Errorf("socketremotesigner.SetupSocketSigner(): %w", err)
 		}
-		return &signer, nil
+		return signer, nil
+
+	case Pkcs11Signer:
+		config, err := pkcs11signer.ConfigFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("pkcs11signer.ConfigFromEnv(): %w", err)
+		}
+		signer, err := pkcs11signer.SetupPkcs11Signer(db, config)
+		if err != nil {
+			return nil, fmt.Errorf("pkcs11signer.SetupPkcs11Signer(db, config): %w", err)
+		}
+		return signer, nil
 
 	default:
 		return nil, fmt.Errorf("no signer type has been selected")
 	}
 }
+
+const MemoryBackend = "memory"
+const PostgresBackend = "postgres"
+const RedisBackend = "redis"
+
+// GetResponseStore picks where NUT-19 responses are cached. Replicas behind a
+// load balancer need a postgres or redis cache so a retried request gets the
+// same response regardless of the instance it lands on.
+func GetResponseStore(ctx context.Context, backend string, db postgresql.Postgresql) (middleware.ResponseStore, error) {
+	switch backend {
+	case "", MemoryBackend:
+		return persistence.NewInMemoryStore(responseCacheExpiration), nil
+	case PostgresBackend:
+		cache := postgresql.NewResponseCache(db)
+		go cleanupResponseCache(ctx, cache, 15*time.Minute)
+		return cache, nil
+	case RedisBackend:
+		address := os.Getenv(REDIS_ADDRESS_ENV)
+		if address == "" {
+			return nil, fmt.Errorf("%v is needed for the redis cache", REDIS_ADDRESS_ENV)
+		}
+		return persistence.NewRedisCache(address, os.Getenv(REDIS_PASSWORD_ENV), responseCacheExpiration), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", backend)
+	}
+}
+
+func cleanupResponseCache(ctx context.Context, cache *postgresql.ResponseCache, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cache.DeleteExpired(ctx)
+			if err != nil {
+				slog.Warn("cache.DeleteExpired(ctx)", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+const StatsSnapshotJob = "stats-snapshot"
+const ReconcileMeltQuotesJob = "reconcile-melt-quotes"
+const KeysetLifecycleJob = "keyset-lifecycle"
+const KeysetLogJob = "keyset-log"
+const LiabilityReportJob = "liability-report"
+const ReserveCheckJob = "reserve-check"
+const ReserveSnapshotPruneJob = "reserve-snapshot-prune"
+
+func keysetLogRelays(value string) []string {
+	relays := make([]string, 0)
+	for relay := range strings.SplitSeq(value, ",") {
+		relay = strings.TrimSpace(relay)
+		if relay != "" {
+			relays = append(relays, relay)
+		}
+	}
+	return relays
+}
+
+// RegisterMintJobs adds the background work of the mint to the scheduler.
+// New keyset log roots are published to keysetLogRelays when there are any.
+func RegisterMintJobs(jobs *scheduler.Scheduler, mint *mint.Mint, statsService stats.Service, keysetLogRelays []string) error {
+	err := jobs.Register(scheduler.Job{
+		Name:       StatsSnapshotJob,
+		Interval:   15 * time.Minute,
+		RunOnStart: true,
+		Run:        statsService.RunOnce,
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(StatsSnapshotJob). %w", err)
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:       ReconcileMeltQuotesJob,
+		Interval:   5 * time.Minute,
+		RunOnStart: false,
+		Run: func(ctx context.Context) error {
+			return mint.ReconcilePendingMeltQuotes()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(ReconcileMeltQuotesJob). %w", err)
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:       KeysetLifecycleJob,
+		Interval:   5 * time.Minute,
+		RunOnStart: true,
+		Run:        mint.RunKeysetLifecycle,
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(KeysetLifecycleJob). %w", err)
+	}
+
+	// tree size of the last root sent to the relays
+	var publishedTreeSize uint64
+	err = jobs.Register(scheduler.Job{
+		Name:       KeysetLogJob,
+		Interval:   5 * time.Minute,
+		RunOnStart: true,
+		Run: func(ctx context.Context) error {
+			root, err := mint.SyncKeysetLog(ctx)
+			if err != nil {
+				return fmt.Errorf("mint.SyncKeysetLog(ctx). %w", err)
+			}
+			if len(keysetLogRelays) == 0 || root.TreeSize == publishedTreeSize {
+				return nil
+			}
+			err = mint.PublishKeysetLogRoot(ctx, root, keysetLogRelays)
+			if err != nil {
+				return fmt.Errorf("mint.PublishKeysetLogRoot(ctx, root, relays). %w", err)
+			}
+			publishedTreeSize = root.TreeSize
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(KeysetLogJob). %w", err)
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:       LiabilityReportJob,
+		Interval:   6 * time.Hour,
+		RunOnStart: true,
+		Run:        mint.RunLiabilityReport,
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(LiabilityReportJob). %w", err)
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:       ReserveCheckJob,
+		Interval:   5 * time.Minute,
+		RunOnStart: true,
+		Run:        mint.RunReserveCheck,
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(ReserveCheckJob). %w", err)
+	}
+	err = jobs.Register(scheduler.Job{
+		Name:       ReserveSnapshotPruneJob,
+		Interval:   24 * time.Hour,
+		RunOnStart: true,
+		Run:        mint.PruneReserveSnapshots,
+	})
+	if err != nil {
+		return fmt.Errorf("jobs.Register(ReserveSnapshotPruneJob). %w", err)
+	}
+	return nil
+}
+
+// RegisterMintMetrics adds the gauges that are read from the mint state on
+// every scrape.
+func RegisterMintMetrics(mint *mint.Mint, db postgresql.Postgresql) error {
+	err := metrics.RegisterCollector(metrics.NewPoolCollector(db.PoolStats))
+	if err != nil {
+		return fmt.Errorf("metrics.RegisterCollector(pool). %w", err)
+	}
+
+	err = metrics.RegisterGauge("melt", "pending_quotes", "Melt quotes waiting on a lightning payment.", func() float64 {
+		quotes, err := mint.MintDB.GetMeltQuotesByState(cashu.PENDING)
+		if err != nil {
+			slog.Warn("mint.MintDB.GetMeltQuotesByState(cashu.PENDING)", slog.Any("error", err))
+			return 0
+		}
+		return float64(len(quotes))
+	})
+	if err != nil {
+		return fmt.Errorf("metrics.RegisterGauge(pending_quotes). %w", err)
+	}
+
+	subscriptions := map[string]func() float64{
+		"proof_subscriptions": func() float64 {
+			proofs, _, _ := mint.Observer.SubscriptionCount()
+			return float64(proofs)
+		},
+		"mint_quote_subscriptions": func() float64 {
+			_, mintQuotes, _ := mint.Observer.SubscriptionCount()
+			return float64(mintQuotes)
+		},
+		"melt_quote_subscriptions": func() float64 {
+			_, _, meltQuotes := mint.Observer.SubscriptionCount()
+			return float64(meltQuotes)
+		},
+	}
+	for name, value := range subscriptions {
+		err = metrics.RegisterGauge("websocket", name, "Active websocket subscriptions on this instance.", value)
+		if err != nil {
+			return fmt.Errorf("metrics.RegisterGauge(%s). %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SetupMetricsServer serves /metrics on its own port when one is set, so it
+// can be kept off the public listener. Without a port it is added to r.
+func SetupMetricsServer(r *gin.Engine, port string) (*http.Server, error) {
+	if port == "" {
+		r.GET("/metrics", gin.WrapH(metrics.Handler()))
+		return nil, nil
+	}
+
+	portInt, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("strconv.ParseUint(port, 10, 16). %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	srv := new(http.Server)
+	srv.Addr = fmt.Sprintf(":%v", portInt)
+	srv.Handler = mux
+	srv.ReadTimeout = 3 * time.Second
+	srv.WriteTimeout = 10 * time.Second
+	go func() {
+		slog.Info("Metrics served in port", slog.String("port", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("metrics server failed", slog.Any("error", err))
+		}
+	}()
+	return srv, nil
+}
+
+// SetupMintRpcServer starts the cdk-mint-rpc compatible management server
+// when an address is set. Clients need a certificate signed by MINT_RPC_CA_CERT.
+func SetupMintRpcServer(mint *mint.Mint, address string) (*grpc.Server, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	creds, err := admin.MintRpcCredentials(os.Getenv(admin.MintRpcTlsCertEnv), os.Getenv(admin.MintRpcTlsKeyEnv), os.Getenv(admin.MintRpcCaCertEnv))
+	if err != nil {
+		return nil, fmt.Errorf("admin.MintRpcCredentials(cert, key, ca). %w", err)
+	}
+	server, err := admin.StartMintRpcServer(mint, address, creds)
+	if err != nil {
+		return nil, fmt.Errorf("admin.StartMintRpcServer(mint, address, creds). %w", err)
+	}
+	return server, nil
+}
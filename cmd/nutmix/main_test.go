@@ -23,6 +23,7 @@ import (
 	"github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/routes"
 	"github.com/lescuer97/nutmix/internal/routes/admin"
+	"github.com/lescuer97/nutmix/internal/scheduler"
 	"github.com/lescuer97/nutmix/internal/signer"
 	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
 	"github.com/lescuer97/nutmix/internal/utils"
@@ -760,7 +761,7 @@ func SetupRoutingForTesting(ctx context.Context, adminRoute bool) (*gin.Engine,
 	routes.V1Routes(r, mint)
 
 	if adminRoute {
-		admin.AdminRoutes(ctx, r, mint)
+		admin.AdminRoutes(ctx, r, mint, scheduler.New(mint.MintDB))
 	}
 
 	return r, mint
@@ -802,7 +803,7 @@ func SetupRoutingForTestingMockDb(ctx context.Context, adminRoute bool) (*gin.En
 	routes.V1Routes(r, mint)
 
 	if adminRoute {
-		admin.AdminRoutes(ctx, r, mint)
+		admin.AdminRoutes(ctx, r, mint, scheduler.New(mint.MintDB))
 	}
 
 	return r, mint
@@ -1835,7 +1836,9 @@ func TestConfigMeltMintLimit(t *testing.T) {
 	req := httptest.NewRequest("POST", "/v1/mint/quote/bolt11", strings.NewReader(string(jsonRequestBody)))
 
 	limit := 999
-	mint.Config.PEG_IN_LIMIT_SATS = &limit
+	config := mint.Config()
+	config.PEG_IN_LIMIT_SATS = &limit
+	mint.SetConfig(config)
 
 	router.ServeHTTP(w, req)
 
@@ -1861,7 +1864,8 @@ func TestConfigMeltMintLimit(t *testing.T) {
 
 	// Test mint ONLY PEGOUT check
 
-	mint.Config.PEG_OUT_ONLY = true
+	config.PEG_OUT_ONLY = true
+	mint.SetConfig(config)
 	req = httptest.NewRequest("POST", "/v1/mint/quote/bolt11", strings.NewReader(string(jsonRequestBody)))
 	router.ServeHTTP(w, req)
 
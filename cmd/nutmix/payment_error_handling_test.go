@@ -115,14 +115,14 @@ func TestPaymentFailureButPendingCheckPaymentMockDbFakeWallet(t *testing.T) {
 	w.Flush()
 	// errors to lightning to force payment checking
 	fakeWallet := lightning.FakeWallet{
-		Network:    *mint.LightningBackend.GetNetwork(),
+		Network:    *mint.LightningBackend().GetNetwork(),
 		InvoiceFee: 0,
 		UnpurposeErrors: []lightning.FakeWalletError{
 			lightning.FailPaymentFailed, lightning.FailQueryPending,
 		},
 	}
 
-	mint.LightningBackend = &fakeWallet
+	mint.SetLightningBackend(&fakeWallet)
 
 	meltProofs, err := GenerateProofs(postMintResponse.Signatures, activeKeys, mintingSecrets, mintingSecretKeys)
 	if err != nil {
@@ -282,14 +282,14 @@ func TestPaymentFailureButPendingCheckPaymentPostgresFakeWallet(t *testing.T) {
 	// try melting
 	// errors to lightning to force payment checking
 	fakeWallet := lightning.FakeWallet{
-		Network: *mintInstance.LightningBackend.GetNetwork(),
+		Network: *mintInstance.LightningBackend().GetNetwork(),
 		UnpurposeErrors: []lightning.FakeWalletError{
 			lightning.FailPaymentFailed, lightning.FailQueryPending,
 		},
 		InvoiceFee: 0,
 	}
 
-	mintInstance.LightningBackend = &fakeWallet
+	mintInstance.SetLightningBackend(&fakeWallet)
 
 	meltProofs, err := GenerateProofs(postMintResponse.Signatures, activeKeys, mintingSecrets, mintingSecretKeys)
 	if err != nil {
@@ -462,14 +462,14 @@ func TestPaymentPendingButPendingCheckPaymentMockDbFakeWallet(t *testing.T) {
 	w.Flush()
 	// errors to lightning to force payment checking
 	fakeWallet := lightning.FakeWallet{
-		Network:    *mint.LightningBackend.GetNetwork(),
+		Network:    *mint.LightningBackend().GetNetwork(),
 		InvoiceFee: 0,
 		UnpurposeErrors: []lightning.FakeWalletError{
 			lightning.FailPaymentFailed, lightning.FailQueryPending,
 		},
 	}
 
-	mint.LightningBackend = &fakeWallet
+	mint.SetLightningBackend(&fakeWallet)
 
 	meltProofs, err := GenerateProofs(postMintResponse.Signatures, activeKeys, mintingSecrets, mintingSecretKeys)
 	if err != nil {
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lescuer97/nutmix/internal/routes/admin"
+)
+
+const (
+	apiPath = "/admin/api/v1"
+	// defaultExpireLimitHours matches the default of the dashboard rotate form,
+	// the new keyset never expires.
+	defaultExpireLimitHours = 0
+	reconcileMeltQuotesJob  = "reconcile-melt-quotes"
+)
+
+var ErrMissingApiConfig = errors.New("the admin api needs " + API_URL_ENV + " and " + API_KEY_ENV)
+
+// apiClient calls the admin json api with an api key.
+type apiClient struct {
+	http    *http.Client
+	baseUrl string
+	key     string
+}
+
+func newApiClient(baseUrl string, key string) (*apiClient, error) {
+	if baseUrl == "" || key == "" {
+		return nil, ErrMissingApiConfig
+	}
+	parsed, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse(baseUrl). %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("api url should start with http:// or https://, got %s", baseUrl)
+	}
+	return &apiClient{
+		http:    &http.Client{Timeout: time.Minute},
+		baseUrl: strings.TrimSuffix(baseUrl, "/") + apiPath,
+		key:     key,
+	}, nil
+}
+
+// do sends body as json and decodes the response into result when it is not
+// nil. Error responses are returned with the message of the api.
+func (a *apiClient) do(ctx context.Context, method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(body). %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseUrl+path, reader)
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext(ctx, method, url, body). %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+a.key)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("a.http.Do(req). %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&apiErr)
+		if err != nil || apiErr.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, apiErr.Error)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("json.NewDecoder(resp.Body).Decode(result). %w", err)
+	}
+	return nil
+}
+
+// parseConfigChanges turns key=value arguments into the body of PATCH
+// /config. Values are read as json and fall back to a plain string, so
+// motd=hello and peg_in_limit_sats=null both work.
+func parseConfigChanges(args []string) (map[string]json.RawMessage, error) {
+	if len(args) == 0 {
+		return nil, ErrUsage
+	}
+	changes := make(map[string]json.RawMessage, len(args))
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("%w: %q should be key=value", ErrUsage, arg)
+		}
+		if json.Valid([]byte(value)) {
+			changes[key] = json.RawMessage(value)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal(value). %w", err)
+		}
+		changes[key] = encoded
+	}
+	return changes, nil
+}
+
+func configSetCmd(ctx context.Context, client *apiClient, args []string, out io.Writer) error {
+	changes, err := parseConfigChanges(args)
+	if err != nil {
+		return err
+	}
+	var config json.RawMessage
+	err = client.do(ctx, http.MethodPatch, "/config", changes, &config)
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	err = json.Indent(&indented, config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.Indent(config). %w", err)
+	}
+	_, err = fmt.Fprintln(out, indented.String())
+	return err
+}
+
+func keysetsRotateCmd(ctx context.Context, client *apiClient, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("keysets rotate", flag.ContinueOnError)
+	unit := flags.String("unit", "sat", "unit of the keyset")
+	fee := flags.Uint("fee", 0, "input fee in parts per thousand")
+	expireLimit := flags.Uint("expire-limit", defaultExpireLimitHours, "hours until the new keyset stops being accepted, 0 never expires")
+	amountsList := flags.String("amounts", "", "comma separated amounts of the new keyset, e.g. 1,2,5,10")
+	maxOrder := flags.Uint("max-order", 0, "number of powers of two in the new keyset, from 1")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+	var amounts []uint64
+	if *amountsList != "" {
+		for part := range strings.SplitSeq(*amountsList, ",") {
+			amount, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return errors.Join(ErrUsage, fmt.Errorf("-amounts %q. %w", *amountsList, err))
+			}
+			amounts = append(amounts, amount)
+		}
+	}
+
+	request := admin.ApiRotateRequest{
+		Unit:             *unit,
+		Amounts:          amounts,
+		Fee:              *fee,
+		ExpireLimitHours: *expireLimit,
+		MaxOrder:         *maxOrder,
+	}
+	err = client.do(ctx, http.MethodPost, "/keysets/rotate", request, nil)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "rotated the %s keyset\n", *unit)
+	return err
+}
+
+func meltsReconcileCmd(ctx context.Context, client *apiClient, out io.Writer) error {
+	err := client.do(ctx, http.MethodPost, "/jobs/"+reconcileMeltQuotesJob+"/run", nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, "pending melt quotes reconciled")
+	return err
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/backup"
+	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	"github.com/skip2/go-qrcode"
+)
+
+func backupCmd(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	switch args[0] {
+	case "split":
+		return backupSplitCmd(args[1:], out)
+	case "recover":
+		return backupRecoverCmd(ctx, args[1:], out)
+	default:
+		return ErrUsage
+	}
+}
+
+// masterKey reads the master key from the keystore at path, or from
+// MINT_PRIVATE_KEY when there is no keystore.
+func masterKey(path string, passphraseEnv string) ([]byte, error) {
+	if path == "" {
+		privateKey, err := hex.DecodeString(os.Getenv(mintPrivateKeyEnv))
+		if err != nil || len(privateKey) == 0 {
+			return nil, fmt.Errorf("set %s or %s, or pass -keystore", mintPrivateKeyEnv, secrets.KeystoreFileEnv)
+		}
+		return privateKey, nil
+	}
+	keystore, err := secrets.ReadKeystore(path)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := readPassphrase(passphraseEnv, "Keystore passphrase: ", false)
+	if err != nil {
+		return nil, err
+	}
+	return keystore.Unlock(passphrase)
+}
+
+// backupSplitCmd prints the master key as shares, and writes them as QR codes
+// with -qr.
+func backupSplitCmd(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("backup split", flag.ContinueOnError)
+	shares := flags.Int("shares", 0, "number of shares to create")
+	threshold := flags.Int("threshold", 0, "number of shares needed to recover the key")
+	qrDir := flags.String("qr", "", "also write every share as a png QR code to this directory")
+	keystorePath := flags.String("keystore", os.Getenv(secrets.KeystoreFileEnv), "keystore with the master key, MINT_PRIVATE_KEY is used without one")
+	passphraseEnv := flags.String("passphrase-env", "", "read the keystore passphrase from this environment variable")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 0 || *shares == 0 || *threshold == 0 {
+		return errors.Join(ErrUsage, err)
+	}
+
+	privateKey, err := masterKey(*keystorePath, *passphraseEnv)
+	if err != nil {
+		return err
+	}
+	defer clear(privateKey)
+	pubkey, err := localsigner.SignerPubkey(privateKey)
+	if err != nil {
+		return fmt.Errorf("localsigner.SignerPubkey(privateKey). %w", err)
+	}
+	split, err := backup.Split(privateKey, *shares, *threshold)
+	if err != nil {
+		return fmt.Errorf("backup.Split(privateKey, %d, %d). %w", *shares, *threshold, err)
+	}
+
+	_, err = fmt.Fprintf(out, "# backup of the master key with pubkey %s\n", pubkey)
+	if err != nil {
+		return err
+	}
+	for _, share := range split {
+		mnemonic := share.Mnemonic()
+		// a share that does not read back is worse than no backup
+		parsed, err := backup.ParseMnemonic(mnemonic)
+		if err != nil {
+			return fmt.Errorf("backup.ParseMnemonic(share %d). %w", share.Index, err)
+		}
+		if parsed.SetId != share.SetId || parsed.Threshold != share.Threshold || parsed.Index != share.Index || !bytes.Equal(parsed.Value, share.Value) {
+			return fmt.Errorf("%w: share %d does not read back", backup.ErrInvalidShare, share.Index)
+		}
+		_, err = fmt.Fprintf(out, "# share %d of %d, any %d recover the key\n%s\n", share.Index, *shares, *threshold, mnemonic)
+		if err != nil {
+			return err
+		}
+		if *qrDir != "" {
+			path := filepath.Join(*qrDir, fmt.Sprintf("share-%d.png", share.Index))
+			err = qrcode.WriteFile(mnemonic, qrcode.Medium, 512, path)
+			if err != nil {
+				return fmt.Errorf("qrcode.WriteFile(%s). %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readShares reads one share per line. Empty lines and lines starting with #
+// are skipped, so the output of backup split can be read back as is. With
+// stopAtEmpty an empty line after the first share ends the input, for typing
+// shares on a terminal.
+func readShares(in io.Reader, stopAtEmpty bool) ([]backup.Share, error) {
+	var shares []backup.Share
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" && stopAtEmpty && len(shares) > 0 {
+			break
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		share, err := backup.ParseMnemonic(line)
+		if err != nil {
+			return nil, fmt.Errorf("share %d. %w", len(shares)+1, err)
+		}
+		shares = append(shares, share)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Err(). %w", err)
+	}
+	return shares, nil
+}
+
+// backupRecoverCmd puts the master key back together and checks it against
+// the keysets in the database before writing it to a keystore.
+func backupRecoverCmd(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("backup recover", flag.ContinueOnError)
+	in := flags.String("in", "", "file with one share per line, stdin when empty")
+	keystorePath := flags.String("keystore", "", "write the recovered key to a new keystore at this path")
+	passphraseEnv := flags.String("passphrase-env", "", "read the new keystore passphrase from this environment variable")
+	printKey := flags.Bool("print", false, "print the recovered key in hex for MINT_PRIVATE_KEY")
+	noVerify := flags.Bool("no-verify", false, "do not check the key against the keysets in the database")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 0 || (*keystorePath == "") == !*printKey {
+		return errors.Join(ErrUsage, err)
+	}
+
+	reader := io.Reader(os.Stdin)
+	if *in != "" {
+		file, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("os.Open(%s). %w", *in, err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		reader = file
+	}
+	interactive := *in == "" && secrets.IsTerminal()
+	if interactive {
+		_, _ = fmt.Fprintln(os.Stderr, "Type one share per line and an empty line after the last one:")
+	}
+	shares, err := readShares(reader, interactive)
+	if err != nil {
+		return err
+	}
+	privateKey, err := backup.Combine(shares)
+	if err != nil {
+		return fmt.Errorf("backup.Combine(shares). %w", err)
+	}
+	defer clear(privateKey)
+	pubkey, err := localsigner.SignerPubkey(privateKey)
+	if err != nil {
+		return fmt.Errorf("localsigner.SignerPubkey(privateKey). %w", err)
+	}
+
+	if !*noVerify {
+		err = withDB(ctx, func(db postgresql.Postgresql) error {
+			seeds, err := db.GetAllSeeds()
+			if err != nil {
+				return fmt.Errorf("db.GetAllSeeds(). %w", err)
+			}
+			if len(seeds) == 0 {
+				log.Printf("there are no keysets in the database, the key could not be checked")
+			}
+			// keysets of previous master keys are not derived by this one
+			keyId, err := localsigner.MasterKeyId(privateKey)
+			if err != nil {
+				return fmt.Errorf("localsigner.MasterKeyId(privateKey). %w", err)
+			}
+			seeds = slices.DeleteFunc(seeds, func(seed cashu.Seed) bool {
+				return seed.MasterKeyId != "" && seed.MasterKeyId != keyId
+			})
+			_, err = localsigner.VerifySeeds(seeds, privateKey, nil)
+			if err != nil {
+				return fmt.Errorf("the recovered key does not derive the keysets of this mint. %w", err)
+			}
+			saveCliAudit(ctx, db, audit.ActionBackupRecover, nil, map[string]any{"pubkey": pubkey, "keysets": len(seeds)})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *printKey {
+		_, err = fmt.Fprintf(out, "recovered the master key with pubkey %s\n%s\n", pubkey, hex.EncodeToString(privateKey))
+		return err
+	}
+	passphrase, err := readPassphrase(*passphraseEnv, "New keystore passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	keystore, err := secrets.NewKeystore(privateKey, pubkey, passphrase)
+	if err != nil {
+		return fmt.Errorf("secrets.NewKeystore(privateKey, pubkey, passphrase). %w", err)
+	}
+	err = secrets.WriteKeystore(*keystorePath, keystore, false)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "recovered the master key with pubkey %s into %s. Set %s to it\n", pubkey, *keystorePath, secrets.KeystoreFileEnv)
+	return err
+}
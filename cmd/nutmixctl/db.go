@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/database/goose"
+	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/routes/admin"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// cliActor is the actor of the audit entries written by nutmixctl.
+const cliActor = "nutmixctl"
+
+// withDB connects without running the migrations, so the schema is only
+// changed by the migrate command.
+func withDB(ctx context.Context, fn func(db postgresql.Postgresql) error) error {
+	db, err := postgresql.DatabaseConnect(ctx)
+	if err != nil {
+		return fmt.Errorf("postgresql.DatabaseConnect(ctx). %w", err)
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// readTx runs fn in a transaction that is always rolled back.
+func readTx(ctx context.Context, db database.MintDB, fn func(tx pgx.Tx) error) error {
+	tx, err := db.GetTx(ctx)
+	if err != nil {
+		return fmt.Errorf("db.GetTx(ctx). %w", err)
+	}
+	defer func() {
+		_ = db.Rollback(ctx, tx)
+	}()
+	return fn(tx)
+}
+
+func printJSON(out io.Writer, value any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(value)
+	if err != nil {
+		return fmt.Errorf("encoder.Encode(value). %w", err)
+	}
+	return nil
+}
+
+func migrateCmd(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		sqlDB := db.SqlDB()
+		defer func() {
+			_ = sqlDB.Close()
+		}()
+
+		switch args[0] {
+		case "up":
+			err := goose.RunMigration(sqlDB, goose.POSTGRES)
+			if err != nil {
+				return fmt.Errorf("goose.RunMigration(sqlDB, goose.POSTGRES). %w", err)
+			}
+			_, err = fmt.Fprintln(out, "database is up to date")
+			return err
+		case "down":
+			return goose.RollbackMigration(sqlDB, goose.POSTGRES)
+		case "status":
+			return goose.MigrationStatus(sqlDB, goose.POSTGRES)
+		default:
+			return ErrUsage
+		}
+	})
+}
+
+func configShowCmd(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("config show", flag.ContinueOnError)
+	showSecrets := flags.Bool("secrets", false, "show lightning backend credentials")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		db, err := withEnvelope(ctx, db)
+		if err != nil {
+			return err
+		}
+		return readTx(ctx, db, func(tx pgx.Tx) error {
+			config, err := db.GetConfig(tx)
+			if err != nil {
+				return fmt.Errorf("db.GetConfig(tx). %w", err)
+			}
+
+			encoded, err := json.Marshal(config)
+			if err != nil {
+				return fmt.Errorf("json.Marshal(config). %w", err)
+			}
+			fields := make(map[string]any)
+			err = json.Unmarshal(encoded, &fields)
+			if err != nil {
+				return fmt.Errorf("json.Unmarshal(encoded, &fields). %w", err)
+			}
+			if !*showSecrets {
+				hideSecretFields(fields)
+			}
+			return printJSON(out, fields)
+		})
+	})
+}
+
+// hideSecretFields hides the set secrets of the config, the same fields the
+// audit log hides.
+func hideSecretFields(fields map[string]any) {
+	for field, value := range fields {
+		if value, ok := value.(string); ok && value != "" && audit.IsSecretField(field) {
+			fields[field] = "<hidden>"
+		}
+	}
+}
+
+func keysetsListCmd(ctx context.Context, out io.Writer) error {
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		seeds, err := db.GetAllSeeds()
+		if err != nil {
+			return fmt.Errorf("db.GetAllSeeds(). %w", err)
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tUNIT\tACTIVE\tFEE PPK\tVERSION\tMASTER KEY\tCREATED")
+		for _, seed := range seeds {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%d\t%s\t%s\n", seed.Id, seed.Unit, seed.Active, seed.InputFeePpk, seed.Version, seed.MasterKeyId, time.Unix(seed.CreatedAt, 0).UTC().Format(time.RFC3339))
+		}
+		return w.Flush()
+	})
+}
+
+type quoteDetails struct {
+	Mint   *cashu.MintRequestDB `json:"mint,omitempty"`
+	Melt   *cashu.MeltRequestDB `json:"melt,omitempty"`
+	Proofs cashu.Proofs         `json:"proofs,omitempty"`
+	Type   string               `json:"type"`
+}
+
+func quoteCmd(ctx context.Context, quoteId string, out io.Writer) error {
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		var details quoteDetails
+		// a failed query aborts the transaction, so each lookup gets its own
+		err := readTx(ctx, db, func(tx pgx.Tx) error {
+			mintRequest, err := db.GetMintRequestById(tx, quoteId)
+			if err != nil {
+				return err
+			}
+			details.Type = "mint"
+			details.Mint = &mintRequest
+			return nil
+		})
+		if err == nil {
+			return printJSON(out, details)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("db.GetMintRequestById(tx, quoteId). %w", err)
+		}
+
+		err = readTx(ctx, db, func(tx pgx.Tx) error {
+			meltRequest, err := db.GetMeltRequestById(tx, quoteId)
+			if err != nil {
+				return err
+			}
+			proofs, err := db.GetProofsFromQuote(tx, quoteId)
+			if err != nil {
+				return fmt.Errorf("db.GetProofsFromQuote(tx, quoteId). %w", err)
+			}
+			details.Type = "melt"
+			details.Melt = &meltRequest
+			details.Proofs = proofs
+			return nil
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("quote %s not found", quoteId)
+		}
+		if err != nil {
+			return fmt.Errorf("db.GetMeltRequestById(tx, quoteId). %w", err)
+		}
+		return printJSON(out, details)
+	})
+}
+
+func parseY(y string) (cashu.WrappedPublicKey, error) {
+	yBytes, err := hex.DecodeString(strings.TrimSpace(y))
+	if err != nil {
+		return cashu.WrappedPublicKey{}, fmt.Errorf("hex.DecodeString(y). %w", err)
+	}
+	pubkey, err := secp256k1.ParsePubKey(yBytes)
+	if err != nil {
+		return cashu.WrappedPublicKey{}, fmt.Errorf("secp256k1.ParsePubKey(yBytes). %w", err)
+	}
+	return cashu.WrappedPublicKey{PublicKey: pubkey}, nil
+}
+
+func proofCmd(ctx context.Context, yHex string, out io.Writer) error {
+	y, err := parseY(yHex)
+	if err != nil {
+		return err
+	}
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		return readTx(ctx, db, func(tx pgx.Tx) error {
+			proofs, err := db.GetProofsFromSecretCurve(tx, []cashu.WrappedPublicKey{y})
+			if err != nil {
+				return fmt.Errorf("db.GetProofsFromSecretCurve(tx, Ys). %w", err)
+			}
+			if len(proofs) == 0 {
+				return fmt.Errorf("no proof with Y %s", yHex)
+			}
+			return printJSON(out, proofs[0])
+		})
+	})
+}
+
+func statsExportCmd(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("stats export", flag.ContinueOnError)
+	since := flags.Int64("since", 0, "only snapshots that start after this unix time")
+	format := flags.String("format", "json", "json or csv")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("%w: format should be json or csv", ErrUsage)
+	}
+
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		snapshots, err := db.GetStatsSnapshotsBySince(ctx, *since)
+		if err != nil {
+			return fmt.Errorf("db.GetStatsSnapshotsBySince(ctx, since). %w", err)
+		}
+		if *format == "csv" {
+			return writeStatsCsv(out, snapshots)
+		}
+		return printJSON(out, snapshots)
+	})
+}
+
+// writeStatsCsv writes one row per snapshot, kind and unit so the export can be
+// loaded in a spreadsheet.
+func writeStatsCsv(out io.Writer, snapshots []database.StatsSnapshot) error {
+	w := csv.NewWriter(out)
+	err := w.Write([]string{"start_date", "end_date", "kind", "unit", "quantity", "amount", "fees"})
+	if err != nil {
+		return fmt.Errorf("w.Write(header). %w", err)
+	}
+	for _, snapshot := range snapshots {
+		kinds := []struct {
+			name  string
+			items []database.StatsSummaryItem
+		}{
+			{"mint", snapshot.MintSummary},
+			{"melt", snapshot.MeltSummary},
+			{"blind_sigs", snapshot.BlindSigsSummary},
+			{"proofs", snapshot.ProofsSummary},
+		}
+		for _, kind := range kinds {
+			for _, item := range kind.items {
+				err := w.Write([]string{
+					strconv.FormatInt(snapshot.StartDate, 10),
+					strconv.FormatInt(snapshot.EndDate, 10),
+					kind.name,
+					item.Unit,
+					strconv.FormatUint(item.Quantity, 10),
+					strconv.FormatUint(item.Amount, 10),
+					strconv.FormatUint(snapshot.Fees, 10),
+				})
+				if err != nil {
+					return fmt.Errorf("w.Write(row). %w", err)
+				}
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// adminPubkey accepts an npub or a hex pubkey, the admins table keeps hex.
+func adminPubkey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if !strings.HasPrefix(key, "npub") {
+		return key, nil
+	}
+	_, value, err := nip19.Decode(key)
+	if err != nil {
+		return "", fmt.Errorf("nip19.Decode(key). %w", err)
+	}
+	pubkey, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not an npub", key)
+	}
+	return pubkey, nil
+}
+
+func saveCliAudit(ctx context.Context, db database.MintDB, action string, before any, after any) {
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		return
+	}
+	_ = db.SaveAuditEntry(ctx, database.AuditEntry{
+		Diff:      diff,
+		Actor:     cliActor,
+		Action:    action,
+		Ip:        "",
+		Id:        0,
+		CreatedAt: time.Now().Unix(),
+	})
+}
+
+func adminsCmd(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		switch {
+		case args[0] == "list" && len(args) == 1:
+			admins, err := db.GetAdmins(ctx)
+			if err != nil {
+				return fmt.Errorf("db.GetAdmins(ctx). %w", err)
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NPUB\tROLE\tADDED BY\tCREATED")
+			for _, a := range admins {
+				npub, err := nip19.EncodePublicKey(a.Pubkey)
+				if err != nil {
+					npub = a.Pubkey
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", npub, a.Role, a.AddedBy, time.Unix(a.CreatedAt, 0).UTC().Format(time.RFC3339))
+			}
+			return w.Flush()
+
+		case args[0] == "add" && len(args) == 3:
+			newAdmin, err := admin.SaveNewAdmin(ctx, db, args[1], args[2], cliActor)
+			if err != nil {
+				return fmt.Errorf("admin.SaveNewAdmin(ctx, db, npub, role, actor). %w", err)
+			}
+			saveCliAudit(ctx, db, audit.ActionAdminAdd, nil, map[string]string{"pubkey": newAdmin.Pubkey, "role": string(newAdmin.Role)})
+			_, err = fmt.Fprintf(out, "added %s as %s\n", args[1], newAdmin.Role)
+			return err
+
+		case args[0] == "remove" && len(args) == 2:
+			pubkey, err := adminPubkey(args[1])
+			if err != nil {
+				return err
+			}
+			existing, err := db.GetAdmin(ctx, pubkey)
+			if err != nil {
+				return fmt.Errorf("db.GetAdmin(ctx, pubkey). %w", err)
+			}
+			if existing == nil {
+				return fmt.Errorf("%s is not an admin", args[1])
+			}
+			err = admin.RemoveAdminAccount(ctx, db, pubkey)
+			if err != nil {
+				return fmt.Errorf("admin.RemoveAdminAccount(ctx, db, pubkey). %w", err)
+			}
+			saveCliAudit(ctx, db, audit.ActionAdminRemove, map[string]string{"pubkey": pubkey}, nil)
+			_, err = fmt.Fprintf(out, "removed %s\n", args[1])
+			return err
+
+		default:
+			return ErrUsage
+		}
+	})
+}
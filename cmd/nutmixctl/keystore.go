@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+const mintPrivateKeyEnv = "MINT_PRIVATE_KEY"
+
+// readPassphrase reads a passphrase from the environment variable envName,
+// or asks for it on the terminal. New passphrases are asked for twice.
+func readPassphrase(envName string, prompt string, isNew bool) (string, error) {
+	if envName != "" {
+		passphrase := os.Getenv(envName)
+		if passphrase == "" {
+			return "", fmt.Errorf("%w: %s is empty", secrets.ErrEmptyPassphrase, envName)
+		}
+		return passphrase, nil
+	}
+	if isNew {
+		return secrets.PromptNewPassphrase(prompt)
+	}
+	return secrets.PromptPassphrase(prompt)
+}
+
+func keystoreCmd(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	switch args[0] {
+	case "create":
+		return keystoreCreateCmd(args[1:], out)
+	case "passwd":
+		return keystorePasswdCmd(args[1:], out)
+	default:
+		return ErrUsage
+	}
+}
+
+// keystoreCreateCmd encrypts MINT_PRIVATE_KEY into a keystore, or a new key
+// with -generate for a new mint.
+func keystoreCreateCmd(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("keystore create", flag.ContinueOnError)
+	generate := flags.Bool("generate", false, "create a new master key instead of reading MINT_PRIVATE_KEY")
+	passphraseEnv := flags.String("passphrase-env", "", "read the passphrase from this environment variable")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return ErrUsage
+	}
+	path := flags.Arg(0)
+
+	var privateKey []byte
+	if *generate {
+		privateKey = make([]byte, 32)
+		_, err = rand.Read(privateKey)
+		if err != nil {
+			return fmt.Errorf("rand.Read(privateKey). %w", err)
+		}
+	} else {
+		privateKey, err = hex.DecodeString(os.Getenv(mintPrivateKeyEnv))
+		if err != nil || len(privateKey) == 0 {
+			return fmt.Errorf("%s should hold the hex master key, or pass -generate for a new mint", mintPrivateKeyEnv)
+		}
+	}
+	defer clear(privateKey)
+
+	pubkey, err := localsigner.SignerPubkey(privateKey)
+	if err != nil {
+		return fmt.Errorf("localsigner.SignerPubkey(privateKey). %w", err)
+	}
+	passphrase, err := readPassphrase(*passphraseEnv, "New keystore passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	keystore, err := secrets.NewKeystore(privateKey, pubkey, passphrase)
+	if err != nil {
+		return fmt.Errorf("secrets.NewKeystore(privateKey, pubkey, passphrase). %w", err)
+	}
+	err = secrets.WriteKeystore(path, keystore, false)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "wrote the keystore for pubkey %s to %s. Set %s to it and remove %s\n", pubkey, path, secrets.KeystoreFileEnv, mintPrivateKeyEnv)
+	return err
+}
+
+func keystorePasswdCmd(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("keystore passwd", flag.ContinueOnError)
+	passphraseEnv := flags.String("passphrase-env", "", "read the current passphrase from this environment variable")
+	newPassphraseEnv := flags.String("new-passphrase-env", "", "read the new passphrase from this environment variable")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return ErrUsage
+	}
+	path := flags.Arg(0)
+
+	keystore, err := secrets.ReadKeystore(path)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(*passphraseEnv, "Current keystore passphrase: ", false)
+	if err != nil {
+		return err
+	}
+	// check the passphrase before asking for a new one
+	_, err = keystore.Unlock(passphrase)
+	if err != nil {
+		return err
+	}
+	newPassphrase, err := readPassphrase(*newPassphraseEnv, "New keystore passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	keystore, err = keystore.ChangePassphrase(passphrase, newPassphrase)
+	if err != nil {
+		return fmt.Errorf("keystore.ChangePassphrase(passphrase, newPassphrase). %w", err)
+	}
+	err = secrets.WriteKeystore(path, keystore, true)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "changed the passphrase of %s\n", path)
+	return err
+}
+
+// unlockCmd unlocks a mint that started locked through the admin api.
+func unlockCmd(ctx context.Context, client *apiClient, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	passphraseEnv := flags.String("passphrase-env", "", "read the passphrase from this environment variable")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 0 {
+		return errors.Join(ErrUsage, err)
+	}
+	passphrase, err := readPassphrase(*passphraseEnv, "Keystore passphrase: ", false)
+	if err != nil {
+		return err
+	}
+	err = client.do(ctx, http.MethodPost, "/signer/unlock", map[string]string{"passphrase": passphrase}, nil)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, "the mint is unlocked")
+	return err
+}
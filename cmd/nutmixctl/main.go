@@ -0,0 +1,169 @@
+// nutmixctl operates a nutmix mint from the command line. Most commands read
+// and write the database directly. Commands that change what a running mint
+// keeps in memory, like its config or active keysets, go through the admin api
+// so they apply without a restart.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	API_URL_ENV = "NUTMIX_API_URL"
+	API_KEY_ENV = "NUTMIX_API_KEY"
+)
+
+var ErrUsage = errors.New("wrong usage")
+
+const usage = `nutmixctl operates a nutmix mint.
+
+Usage:
+  nutmixctl [-api-url url] [-api-key key] <command> [arguments]
+
+Database commands, they need DATABASE_URL:
+  migrate up|down|status           run, roll back one or list the migrations
+  config show [-secrets]           print the mint config
+  keysets list                     list the keysets
+  quote <quote id>                 inspect a mint or melt quote
+  proof <Y>                        inspect a proof by its Y point in hex
+  stats export [-since unix] [-format json|csv]
+                                   export the stats snapshots
+  admins list                      list the dashboard admins
+  admins add <npub> <role>         add an admin as viewer, operator or owner
+  admins remove <npub|pubkey>      remove an admin and its sessions
+  secrets status                   list the data keys of the config encryption
+  secrets encrypt                  encrypt the stored backend credentials
+  secrets rotate                   encrypt the credentials with a new data key
+  secrets rewrap -key-file path | -passphrase-env NAME
+                                   wrap the data keys with a new key file or passphrase
+  secrets prune                    remove the data keys nothing uses, after every mint restarted
+  secrets keygen <path>            write a new key file for CONFIG_ENCRYPTION_KEY_FILE
+
+Keystore and backup commands, they use the keystore file or MINT_PRIVATE_KEY:
+  keystore create [-generate] [-passphrase-env NAME] <path>
+                                   encrypt MINT_PRIVATE_KEY, or a new key, into a keystore
+  keystore passwd [-passphrase-env NAME] [-new-passphrase-env NAME] <path>
+                                   change the passphrase of a keystore
+  backup split -shares N -threshold M [-qr dir] [-keystore path] [-passphrase-env NAME]
+                                   split the master key into shares, any M of them recover it
+  backup recover [-in path] [-no-verify] -keystore path [-passphrase-env NAME] | -print
+                                   put the master key back together from shares, checked
+                                   against the keysets in DATABASE_URL unless -no-verify
+  masterkeys id [-keystore path] [-passphrase-env NAME]
+                                   print the id the keysets of the master key are tagged with
+  masterkeys status                ecash still outstanding in the keysets of every master
+                                   key, a previous key can go once it is drained, needs DATABASE_URL
+
+Admin api commands, they need NUTMIX_API_URL and NUTMIX_API_KEY:
+  config set <key=value>...        change the config, e.g. motd="hello" peg_in_limit_sats=null
+  keysets rotate -unit sat [-fee ppk] [-expire-limit hours] [-amounts 1,2,5 | -max-order n]
+                                   rotate the active keyset of a unit, by default to
+                                   32 powers of two
+  melts reconcile                  check pending melt quotes against the lightning backend
+  unlock [-passphrase-env NAME]    unlock a mint that started with a locked keystore
+`
+
+func main() {
+	err := godotenv.Load(".env")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("godotenv.Load(.env): %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = run(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, ErrUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nutmixctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("nutmixctl", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	apiUrl := flags.String("api-url", os.Getenv(API_URL_ENV), "url of the mint, e.g. https://mint.example.com")
+	apiKey := flags.String("api-key", os.Getenv(API_KEY_ENV), "admin api key")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	api := func() (*apiClient, error) {
+		return newApiClient(*apiUrl, *apiKey)
+	}
+
+	command, args := args[0], args[1:]
+	subcommand := ""
+	if len(args) > 0 {
+		subcommand = args[0]
+	}
+
+	switch {
+	case command == "migrate":
+		return migrateCmd(ctx, args, out)
+	case command == "config" && subcommand == "show":
+		return configShowCmd(ctx, args[1:], out)
+	case command == "config" && subcommand == "set":
+		client, err := api()
+		if err != nil {
+			return err
+		}
+		return configSetCmd(ctx, client, args[1:], out)
+	case command == "keysets" && subcommand == "list":
+		return keysetsListCmd(ctx, out)
+	case command == "keysets" && subcommand == "rotate":
+		client, err := api()
+		if err != nil {
+			return err
+		}
+		return keysetsRotateCmd(ctx, client, args[1:], out)
+	case command == "quote" && len(args) == 1:
+		return quoteCmd(ctx, args[0], out)
+	case command == "proof" && len(args) == 1:
+		return proofCmd(ctx, args[0], out)
+	case command == "melts" && subcommand == "reconcile":
+		client, err := api()
+		if err != nil {
+			return err
+		}
+		return meltsReconcileCmd(ctx, client, out)
+	case command == "stats" && subcommand == "export":
+		return statsExportCmd(ctx, args[1:], out)
+	case command == "admins":
+		return adminsCmd(ctx, args, out)
+	case command == "secrets":
+		return secretsCmd(ctx, args, out)
+	case command == "keystore":
+		return keystoreCmd(args, out)
+	case command == "backup":
+		return backupCmd(ctx, args, out)
+	case command == "masterkeys":
+		return masterKeysCmd(ctx, args, out)
+	case command == "unlock":
+		client, err := api()
+		if err != nil {
+			return err
+		}
+		return unlockCmd(ctx, client, args, out)
+	default:
+		return ErrUsage
+	}
+}
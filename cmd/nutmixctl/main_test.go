@@ -0,0 +1,300 @@
+//nolint:exhaustruct
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lescuer97/nutmix/internal/backup"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+func TestParseConfigChanges(t *testing.T) {
+	changes, err := parseConfigChanges([]string{"motd=hello world", "peg_in_limit_sats=null", "peg_out_only=true", "name=\"nutmix\""})
+	if err != nil {
+		t.Fatalf("parseConfigChanges: %v", err)
+	}
+	expected := map[string]string{
+		"motd":              `"hello world"`,
+		"peg_in_limit_sats": `null`,
+		"peg_out_only":      `true`,
+		"name":              `"nutmix"`,
+	}
+	for key, value := range expected {
+		if string(changes[key]) != value {
+			t.Errorf("expected %s to be %s, got %s", key, value, changes[key])
+		}
+	}
+
+	_, err = parseConfigChanges([]string{"motd"})
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("expected an argument without = to be a usage error, got %v", err)
+	}
+	_, err = parseConfigChanges(nil)
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("expected no arguments to be a usage error, got %v", err)
+	}
+}
+
+func TestHideSecretFields(t *testing.T) {
+	fields := map[string]any{
+		"LND_MACAROON":   "abcd",
+		"LND_TLS_CERT":   "-----BEGIN CERTIFICATE-----",
+		"CLN_CLIENT_KEY": "",
+		"MOTD":           "hello",
+		"PEG_OUT_ONLY":   true,
+	}
+	hideSecretFields(fields)
+	if fields["LND_MACAROON"] != "<hidden>" || fields["LND_TLS_CERT"] != "<hidden>" {
+		t.Errorf("expected the secrets to be hidden, got %v", fields)
+	}
+	if fields["CLN_CLIENT_KEY"] != "" || fields["MOTD"] != "hello" || fields["PEG_OUT_ONLY"] != true {
+		t.Errorf("expected empty secrets and other fields to stay, got %v", fields)
+	}
+}
+
+func TestWriteStatsCsv(t *testing.T) {
+	snapshots := []database.StatsSnapshot{{
+		StartDate:   100,
+		EndDate:     200,
+		Fees:        3,
+		MintSummary: []database.StatsSummaryItem{{Unit: "sat", Quantity: 2, Amount: 1000}},
+		MeltSummary: []database.StatsSummaryItem{{Unit: "sat", Quantity: 1, Amount: 400}},
+	}}
+	var out bytes.Buffer
+	err := writeStatsCsv(&out, snapshots)
+	if err != nil {
+		t.Fatalf("writeStatsCsv: %v", err)
+	}
+	expected := "start_date,end_date,kind,unit,quantity,amount,fees\n" +
+		"100,200,mint,sat,2,1000,3\n" +
+		"100,200,melt,sat,1,400,3\n"
+	if out.String() != expected {
+		t.Errorf("unexpected csv:\n%s", out.String())
+	}
+}
+
+func TestApiCommands(t *testing.T) {
+	var requests []string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid api key"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		bodies = append(bodies, string(body))
+
+		switch r.URL.Path {
+		case apiPath + "/config":
+			_, _ = w.Write([]byte(`{"motd":"hello"}`))
+		case apiPath + "/jobs/" + reconcileMeltQuotesJob + "/run":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"job is running on another instance"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	err := run(t.Context(), []string{"-api-url", server.URL + "/", "-api-key", "test-key", "config", "set", "motd=hello"}, &out)
+	if err != nil {
+		t.Fatalf("config set: %v", err)
+	}
+	if !strings.Contains(out.String(), `"motd": "hello"`) {
+		t.Errorf("expected the updated config, got %s", out.String())
+	}
+
+	err = run(t.Context(), []string{"-api-url", server.URL, "-api-key", "test-key", "keysets", "rotate", "-unit", "sat", "-fee", "100", "-amounts", "1, 2,5,10"}, &out)
+	if err != nil {
+		t.Fatalf("keysets rotate: %v", err)
+	}
+	var rotate map[string]any
+	err = json.Unmarshal([]byte(bodies[1]), &rotate)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if rotate["unit"] != "sat" || rotate["fee"] != float64(100) || rotate["expire_limit_hours"] != float64(defaultExpireLimitHours) {
+		t.Errorf("unexpected rotate body %v", rotate)
+	}
+	if fmt.Sprint(rotate["amounts"]) != "[1 2 5 10]" {
+		t.Errorf("expected the amounts in the rotate body, got %v", rotate["amounts"])
+	}
+
+	err = run(t.Context(), []string{"-api-url", server.URL, "-api-key", "test-key", "melts", "reconcile"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "job is running on another instance") {
+		t.Errorf("expected the api error message, got %v", err)
+	}
+
+	t.Setenv("TEST_UNLOCK_PASSPHRASE", "passphrase")
+	err = run(t.Context(), []string{"-api-url", server.URL, "-api-key", "test-key", "unlock", "-passphrase-env", "TEST_UNLOCK_PASSPHRASE"}, &out)
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if bodies[3] != `{"passphrase":"passphrase"}` {
+		t.Errorf("unexpected unlock body %s", bodies[3])
+	}
+
+	expectedRequests := []string{
+		"PATCH " + apiPath + "/config",
+		"POST " + apiPath + "/keysets/rotate",
+		"POST " + apiPath + "/jobs/" + reconcileMeltQuotesJob + "/run",
+		"POST " + apiPath + "/signer/unlock",
+	}
+	if strings.Join(requests, ",") != strings.Join(expectedRequests, ",") {
+		t.Errorf("expected requests %v, got %v", expectedRequests, requests)
+	}
+
+	err = run(t.Context(), []string{"-api-url", server.URL, "-api-key", "wrong", "melts", "reconcile"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("expected the key to be rejected, got %v", err)
+	}
+	t.Setenv(API_URL_ENV, "")
+	t.Setenv(API_KEY_ENV, "")
+	err = run(t.Context(), []string{"melts", "reconcile"}, &out)
+	if !errors.Is(err, ErrMissingApiConfig) {
+		t.Errorf("expected a missing api config error, got %v", err)
+	}
+}
+
+func TestKeystoreCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	t.Setenv(mintPrivateKeyEnv, "0000000000000000000000000000000000000000000000000000000000000001")
+	t.Setenv("TEST_KEYSTORE_PASSPHRASE", "first")
+	t.Setenv("TEST_KEYSTORE_NEW_PASSPHRASE", "second")
+
+	var out bytes.Buffer
+	err := run(t.Context(), []string{"keystore", "create", "-passphrase-env", "TEST_KEYSTORE_PASSPHRASE", path}, &out)
+	if err != nil {
+		t.Fatalf("keystore create: %v", err)
+	}
+	err = run(t.Context(), []string{"keystore", "create", "-passphrase-env", "TEST_KEYSTORE_PASSPHRASE", path}, &out)
+	if err == nil {
+		t.Error("expected keystore create to keep the existing keystore")
+	}
+
+	err = run(t.Context(), []string{"keystore", "passwd", "-passphrase-env", "TEST_KEYSTORE_NEW_PASSPHRASE", "-new-passphrase-env", "TEST_KEYSTORE_PASSPHRASE", path}, &out)
+	if !errors.Is(err, secrets.ErrWrongPassphrase) {
+		t.Errorf("expected the wrong current passphrase to fail, got %v", err)
+	}
+	err = run(t.Context(), []string{"keystore", "passwd", "-passphrase-env", "TEST_KEYSTORE_PASSPHRASE", "-new-passphrase-env", "TEST_KEYSTORE_NEW_PASSPHRASE", path}, &out)
+	if err != nil {
+		t.Fatalf("keystore passwd: %v", err)
+	}
+
+	keystore, err := secrets.ReadKeystore(path)
+	if err != nil {
+		t.Fatalf("secrets.ReadKeystore: %v", err)
+	}
+	privateKey, err := keystore.Unlock("second")
+	if err != nil {
+		t.Fatalf("keystore.Unlock: %v", err)
+	}
+	if hex.EncodeToString(privateKey) != os.Getenv(mintPrivateKeyEnv) {
+		t.Errorf("expected MINT_PRIVATE_KEY in the keystore, got %x", privateKey)
+	}
+}
+
+func TestBackupCommands(t *testing.T) {
+	dir := t.TempDir()
+	privateKey := "0000000000000000000000000000000000000000000000000000000000000001"
+	t.Setenv(mintPrivateKeyEnv, privateKey)
+	t.Setenv(secrets.KeystoreFileEnv, "")
+
+	var out bytes.Buffer
+	err := run(t.Context(), []string{"backup", "split", "-shares", "3", "-threshold", "2", "-qr", dir}, &out)
+	if err != nil {
+		t.Fatalf("backup split: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		_, err = os.Stat(filepath.Join(dir, fmt.Sprintf("share-%d.png", i)))
+		if err != nil {
+			t.Errorf("expected a QR code for share %d: %v", i, err)
+		}
+	}
+
+	// drop the first share, the other two are enough
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("expected a header and 3 shares, got %q", out.String())
+	}
+	sharesPath := filepath.Join(dir, "shares.txt")
+	err = os.WriteFile(sharesPath, []byte(strings.Join(lines[3:], "\n")), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	out.Reset()
+	err = run(t.Context(), []string{"backup", "recover", "-no-verify", "-in", sharesPath, "-print"}, &out)
+	if err != nil {
+		t.Fatalf("backup recover: %v", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(out.String()), privateKey) {
+		t.Errorf("expected the recovered key, got %q", out.String())
+	}
+
+	err = os.WriteFile(sharesPath, []byte(lines[6]), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	err = run(t.Context(), []string{"backup", "recover", "-no-verify", "-in", sharesPath, "-print"}, &out)
+	if !errors.Is(err, backup.ErrNotEnoughShares) {
+		t.Errorf("expected one share not to be enough, got %v", err)
+	}
+
+	keystorePath := filepath.Join(dir, "keystore.json")
+	t.Setenv("TEST_KEYSTORE_PASSPHRASE", "passphrase")
+	err = os.WriteFile(sharesPath, []byte(strings.Join(lines[:5], "\n")), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	err = run(t.Context(), []string{"backup", "recover", "-no-verify", "-in", sharesPath, "-keystore", keystorePath, "-passphrase-env", "TEST_KEYSTORE_PASSPHRASE"}, &out)
+	if err != nil {
+		t.Fatalf("backup recover -keystore: %v", err)
+	}
+	keystore, err := secrets.ReadKeystore(keystorePath)
+	if err != nil {
+		t.Fatalf("secrets.ReadKeystore: %v", err)
+	}
+	recovered, err := keystore.Unlock("passphrase")
+	if err != nil || hex.EncodeToString(recovered) != privateKey {
+		t.Errorf("expected the recovered key in the keystore, got %x, %v", recovered, err)
+	}
+}
+
+func TestMasterKeysIdCommand(t *testing.T) {
+	privateKey := "0000000000000000000000000000000000000000000000000000000000000001"
+	t.Setenv(mintPrivateKeyEnv, privateKey)
+	t.Setenv(secrets.KeystoreFileEnv, "")
+
+	var out bytes.Buffer
+	err := run(t.Context(), []string{"masterkeys", "id"}, &out)
+	if err != nil {
+		t.Fatalf("masterkeys id: %v", err)
+	}
+	decoded, _ := hex.DecodeString(privateKey)
+	id, err := localsigner.MasterKeyId(decoded)
+	if err != nil || strings.TrimSpace(out.String()) != id {
+		t.Errorf("expected the id %s, got %q %v", id, out.String(), err)
+	}
+
+	err = run(t.Context(), []string{"masterkeys"}, &out)
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("expected ErrUsage without a subcommand, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+func masterKeysCmd(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	switch args[0] {
+	case "id":
+		return masterKeysIdCmd(args[1:], out)
+	case "status":
+		return masterKeysStatusCmd(ctx, out)
+	default:
+		return ErrUsage
+	}
+}
+
+// masterKeysIdCmd prints the id the keysets of a master key are tagged with.
+func masterKeysIdCmd(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("masterkeys id", flag.ContinueOnError)
+	keystorePath := flags.String("keystore", os.Getenv(secrets.KeystoreFileEnv), "keystore with the master key, MINT_PRIVATE_KEY is used without one")
+	passphraseEnv := flags.String("passphrase-env", "", "read the keystore passphrase from this environment variable")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 0 {
+		return errors.Join(ErrUsage, err)
+	}
+	privateKey, err := masterKey(*keystorePath, *passphraseEnv)
+	if err != nil {
+		return err
+	}
+	defer clear(privateKey)
+	id, err := localsigner.MasterKeyId(privateKey)
+	if err != nil {
+		return fmt.Errorf("localsigner.MasterKeyId(privateKey). %w", err)
+	}
+	_, err = fmt.Fprintln(out, id)
+	return err
+}
+
+type masterKeyUnit struct {
+	masterKeyId string
+	unit        string
+}
+
+type masterKeyUnitStatus struct {
+	keysets     int
+	active      int
+	outstanding int64
+}
+
+// masterKeysStatusCmd lists the ecash still outstanding in the keysets of
+// every master key. A previous master key can be dropped once its keysets
+// are drained.
+func masterKeysStatusCmd(ctx context.Context, out io.Writer) error {
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		seeds, err := db.GetAllSeeds()
+		if err != nil {
+			return fmt.Errorf("db.GetAllSeeds(). %w", err)
+		}
+		statuses := make(map[masterKeyUnit]*masterKeyUnitStatus)
+		byKeyset := make(map[string]*masterKeyUnitStatus)
+		for _, seed := range seeds {
+			key := masterKeyUnit{masterKeyId: seed.MasterKeyId, unit: seed.Unit}
+			if key.masterKeyId == "" {
+				key.masterKeyId = "untagged"
+			}
+			status, exists := statuses[key]
+			if !exists {
+				status = &masterKeyUnitStatus{keysets: 0, active: 0, outstanding: 0}
+				statuses[key] = status
+			}
+			status.keysets++
+			if seed.Active {
+				status.active++
+			}
+			byKeyset[seed.Id] = status
+		}
+
+		balances, err := db.GetKeysetBalances(ctx)
+		if err != nil {
+			return fmt.Errorf("db.GetKeysetBalances(ctx). %w", err)
+		}
+		for _, balance := range balances {
+			if status, exists := byKeyset[balance.KeysetId]; exists {
+				status.outstanding += balance.Outstanding()
+			}
+		}
+
+		keys := make([]masterKeyUnit, 0, len(statuses))
+		for key := range statuses {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, func(a, b masterKeyUnit) int {
+			return cmp.Or(strings.Compare(a.masterKeyId, b.masterKeyId), strings.Compare(a.unit, b.unit))
+		})
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "MASTER KEY\tUNIT\tKEYSETS\tACTIVE\tOUTSTANDING")
+		for _, key := range keys {
+			status := statuses[key]
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", key.masterKeyId, key.unit, status.keysets, status.active, status.outstanding)
+		}
+		return w.Flush()
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database/postgresql"
+	"github.com/lescuer97/nutmix/internal/secrets"
+)
+
+var ErrKMSNotSet = errors.New("set " + secrets.PassphraseEnv + ", " + secrets.KeyFileEnv + " or " + secrets.KmsEnv + " to manage the config encryption")
+
+// withEnvelope lets db read the encrypted credentials when a kms is set.
+func withEnvelope(ctx context.Context, db postgresql.Postgresql) (postgresql.Postgresql, error) {
+	kms, err := secrets.KMSFromEnv()
+	if err != nil {
+		return db, fmt.Errorf("secrets.KMSFromEnv(). %w", err)
+	}
+	envelope, err := secrets.LoadEnvelope(ctx, db, kms)
+	if err != nil {
+		return db, fmt.Errorf("secrets.LoadEnvelope(ctx, db, kms). %w", err)
+	}
+	return db.WithEnvelope(envelope), nil
+}
+
+func requireKMS() (secrets.KMS, error) {
+	kms, err := secrets.KMSFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("secrets.KMSFromEnv(). %w", err)
+	}
+	if kms == nil {
+		return nil, ErrKMSNotSet
+	}
+	return kms, nil
+}
+
+func secretsCmd(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	if args[0] == "keygen" {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		err := secrets.GenerateKeyFile(args[1])
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "wrote a new key to %s, use it with %s\n", args[1], secrets.KeyFileEnv)
+		return err
+	}
+
+	return withDB(ctx, func(db postgresql.Postgresql) error {
+		switch {
+		case args[0] == "status" && len(args) == 1:
+			keys, err := db.GetDataKeys(ctx)
+			if err != nil {
+				return fmt.Errorf("db.GetDataKeys(ctx). %w", err)
+			}
+			if len(keys) == 0 {
+				_, err = fmt.Fprintln(out, "the config credentials are stored in plaintext")
+				return err
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "ID\tKMS\tACTIVE\tCREATED")
+			for _, key := range keys {
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%t\t%s\n", key.Id, key.Kms, key.Active, time.Unix(key.CreatedAt, 0).UTC().Format(time.RFC3339))
+			}
+			return w.Flush()
+
+		case args[0] == "encrypt" && len(args) == 1:
+			kms, err := requireKMS()
+			if err != nil {
+				return err
+			}
+			db, err = db.SetupEncryption(ctx, kms)
+			if err != nil {
+				return fmt.Errorf("db.SetupEncryption(ctx, kms). %w", err)
+			}
+			_, err = fmt.Fprintf(out, "the config credentials are encrypted with data key %d\n", db.Envelope().ActiveKeyId())
+			return err
+
+		case args[0] == "rotate" && len(args) == 1:
+			return rotateDataKey(ctx, db, out)
+
+		case args[0] == "rewrap":
+			return rewrapDataKeys(ctx, db, args[1:], out)
+
+		case args[0] == "prune" && len(args) == 1:
+			db, err := withEnvelope(ctx, db)
+			if err != nil {
+				return err
+			}
+			removed, err := db.PruneDataKeys(ctx)
+			if err != nil {
+				return fmt.Errorf("db.PruneDataKeys(ctx). %w", err)
+			}
+			_, err = fmt.Fprintf(out, "removed %d unused data keys\n", len(removed))
+			return err
+
+		default:
+			return ErrUsage
+		}
+	})
+}
+
+// rotateDataKey encrypts every credential with a new data key. The old key
+// stays until secrets prune, because a running mint keeps using it until it
+// restarts.
+func rotateDataKey(ctx context.Context, db postgresql.Postgresql, out io.Writer) error {
+	kms, err := requireKMS()
+	if err != nil {
+		return err
+	}
+	envelope, err := secrets.LoadEnvelope(ctx, db, kms)
+	if err != nil {
+		return fmt.Errorf("secrets.LoadEnvelope(ctx, db, kms). %w", err)
+	}
+	if envelope == nil {
+		return fmt.Errorf("%w, run secrets encrypt first", secrets.ErrNoEnvelope)
+	}
+
+	before := map[string]int64{"data_key": envelope.ActiveKeyId()}
+	id, err := envelope.Rotate(ctx)
+	if err != nil {
+		return fmt.Errorf("envelope.Rotate(ctx). %w", err)
+	}
+	changed, err := db.WithEnvelope(envelope).ReencryptSecrets(ctx)
+	if err != nil {
+		return fmt.Errorf("db.ReencryptSecrets(ctx). %w", err)
+	}
+	saveCliAudit(ctx, db, audit.ActionSecretsRotate, before, map[string]int64{"data_key": id})
+	_, err = fmt.Fprintf(out, "encrypted %d rows with data key %d. Restart the mint, then run secrets prune to remove the old key\n", changed, id)
+	return err
+}
+
+// rewrapDataKeys wraps the data keys with a new kms, like a new passphrase or
+// key file. The new passphrase is read from an environment variable so it
+// stays out of the shell history.
+func rewrapDataKeys(ctx context.Context, db postgresql.Postgresql, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("secrets rewrap", flag.ContinueOnError)
+	keyFile := flags.String("key-file", "", "wrap the data keys with this key file")
+	passphraseEnv := flags.String("passphrase-env", "", "wrap the data keys with the passphrase in this environment variable")
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Join(ErrUsage, err)
+	}
+	if (*keyFile == "") == (*passphraseEnv == "") {
+		return fmt.Errorf("%w: pass either -key-file or -passphrase-env", ErrUsage)
+	}
+
+	var newKms secrets.KMS
+	if *keyFile != "" {
+		newKms, err = secrets.NewFileKMS(*keyFile)
+	} else {
+		newKms, err = secrets.NewPassphraseKMS(os.Getenv(*passphraseEnv))
+	}
+	if err != nil {
+		return err
+	}
+
+	kms, err := requireKMS()
+	if err != nil {
+		return err
+	}
+	envelope, err := secrets.LoadEnvelope(ctx, db, kms)
+	if err != nil {
+		return fmt.Errorf("secrets.LoadEnvelope(ctx, db, kms). %w", err)
+	}
+	if envelope == nil {
+		return fmt.Errorf("%w, run secrets encrypt first", secrets.ErrNoEnvelope)
+	}
+	err = envelope.Rewrap(ctx, newKms)
+	if err != nil {
+		return fmt.Errorf("envelope.Rewrap(ctx, newKms). %w", err)
+	}
+	saveCliAudit(ctx, db, audit.ActionSecretsRewrap, map[string]string{"kms": kms.Name()}, map[string]string{"kms": newKms.Name()})
+	_, err = fmt.Fprintf(out, "the data keys are wrapped with the new %s kms, update the mint environment before it restarts\n", newKms.Name())
+	return err
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin              = "admin.login"
+	ActionLoginFailed        = "admin.login_failed"
+	ActionLogout             = "admin.logout"
+	ActionAdminAdd           = "admin.add"
+	ActionAdminRole          = "admin.role"
+	ActionAdminRemove        = "admin.remove"
+	ActionSessionRevoke      = "admin.session_revoke"
+	ActionApiKeyCreate       = "admin.api_key_create"
+	ActionApiKeyRevoke       = "admin.api_key_revoke"
+	ActionConfigGeneral      = "config.general"
+	ActionConfigLightning    = "config.lightning"
+	ActionConfigAuth         = "config.auth"
+	ActionConfigBackend      = "config.lightning_backend"
+	ActionConfigRollback     = "config.rollback"
+	ActionKeysetRotate       = "keyset.rotate"
+	ActionKeysetPolicy       = "keyset.policy"
+	ActionSecretsRotate      = "secrets.rotate"
+	ActionSecretsRewrap      = "secrets.rewrap"
+	ActionSignerUnlock       = "signer.unlock"
+	ActionSignerUnlockFailed = "signer.unlock_failed"
+	ActionBackupRecover      = "backup.recover"
+	ActionLiquiditySwapOut   = "liquidity.swap_out"
+	ActionLiquiditySwapIn    = "liquidity.swap_in"
+	ActionLiquidityConfirmed = "liquidity.swap_out_confirmed"
+)
+
+const Redacted = "[redacted]"
+
+// secretWords mark a field as a secret when they appear as a word of its name,
+// like MINT_LNBITS_KEY or LND_MACAROON.
+var secretWords = []string{"KEY", "MACAROON", "CERT", "SECRET", "NSEC", "PASSWORD", "PASSPHRASE", "SEED"}
+
+func IsSecretField(name string) bool {
+	for _, word := range strings.Split(strings.ToUpper(name), "_") {
+		for _, secretWord := range secretWords {
+			if word == secretWord {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Diff compares the JSON form of before and after and returns the fields that
+// changed. Secret fields only show whether they were set.
+func Diff(before any, after any) (map[string]database.AuditChange, error) {
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return nil, fmt.Errorf("toFields(before). %w", err)
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return nil, fmt.Errorf("toFields(after). %w", err)
+	}
+
+	diff := make(map[string]database.AuditChange)
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			beforeFields[name] = nil
+		}
+	}
+	for name, beforeValue := range beforeFields {
+		afterValue := afterFields[name]
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		if IsSecretField(name) {
+			beforeValue = redact(beforeValue)
+			afterValue = redact(afterValue)
+		}
+		diff[name] = database.AuditChange{Before: beforeValue, After: afterValue}
+	}
+	return diff, nil
+}
+
+// Details records the values of an action that has no before state, like a
+// keyset rotation. Secret fields are redacted.
+func Details(details any) (map[string]database.AuditChange, error) {
+	return Diff(nil, details)
+}
+
+func toFields(value any) (map[string]any, error) {
+	fields := make(map[string]any)
+	if value == nil {
+		return fields, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(value). %w", err)
+	}
+	err = json.Unmarshal(encoded, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(encoded, &fields). %w", err)
+	}
+	return fields, nil
+}
+
+func redact(value any) any {
+	if value == nil || value == "" {
+		return value
+	}
+	return Redacted
+}
+
+// Export is a signed copy of audit log entries. The signature is a schnorr
+// signature over the sha256 of Payload, so the payload bytes must be kept as is.
+type Export struct {
+	Payload   json.RawMessage `json:"payload"`
+	Pubkey    string          `json:"pubkey"`
+	Signature string          `json:"signature"`
+}
+
+type ExportPayload struct {
+	Entries    []database.AuditEntry `json:"entries"`
+	Filter     database.AuditFilter  `json:"filter"`
+	ExportedAt int64                 `json:"exported_at"`
+}
+
+var ErrInvalidExportSignature = errors.New("audit export signature is not valid")
+
+func SignExport(entries []database.AuditEntry, filter database.AuditFilter, key *secp256k1.PrivateKey, now time.Time) (Export, error) {
+	payload, err := json.Marshal(ExportPayload{Entries: entries, Filter: filter, ExportedAt: now.Unix()})
+	if err != nil {
+		return Export{}, fmt.Errorf("json.Marshal(ExportPayload). %w", err)
+	}
+
+	hash := sha256.Sum256(payload)
+	signature, err := schnorr.Sign(key, hash[:])
+	if err != nil {
+		return Export{}, fmt.Errorf("schnorr.Sign(key, hash[:]). %w", err)
+	}
+
+	return Export{
+		Payload:   payload,
+		Pubkey:    hex.EncodeToString(schnorr.SerializePubKey(key.PubKey())),
+		Signature: hex.EncodeToString(signature.Serialize()),
+	}, nil
+}
+
+// VerifyExport checks the export was signed by pubkey and was not modified.
+func VerifyExport(export Export, pubkey string) error {
+	if export.Pubkey != pubkey {
+		return fmt.Errorf("%w: signed by %s", ErrInvalidExportSignature, export.Pubkey)
+	}
+	pubkeyBytes, err := hex.DecodeString(export.Pubkey)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString(export.Pubkey). %w", err)
+	}
+	key, err := schnorr.ParsePubKey(pubkeyBytes)
+	if err != nil {
+		return fmt.Errorf("schnorr.ParsePubKey(pubkeyBytes). %w", err)
+	}
+	signatureBytes, err := hex.DecodeString(export.Signature)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString(export.Signature). %w", err)
+	}
+	signature, err := schnorr.ParseSignature(signatureBytes)
+	if err != nil {
+		return fmt.Errorf("schnorr.ParseSignature(signatureBytes). %w", err)
+	}
+
+	hash := sha256.Sum256(export.Payload)
+	if !signature.Verify(hash[:], key) {
+		return ErrInvalidExportSignature
+	}
+	return nil
+}
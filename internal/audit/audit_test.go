@@ -0,0 +1,102 @@
+//nolint:exhaustruct,govet
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+func TestDiffOnlyKeepsChangedFields(t *testing.T) {
+	before := utils.Config{NAME: "old mint", MOTD: "hello"}
+	after := utils.Config{NAME: "new mint", MOTD: "hello"}
+
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("Diff(before, after): %v", err)
+	}
+	if len(diff) != 1 {
+		t.Fatalf("expected one changed field, got %v", diff)
+	}
+	change := diff["NAME"]
+	if change.Before != "old mint" || change.After != "new mint" {
+		t.Fatalf("unexpected change %+v", change)
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	before := utils.Config{LND_MACAROON: "", MINT_LNBITS_KEY: "old-key", LND_GRPC_HOST: "localhost:1"}
+	after := utils.Config{LND_MACAROON: "0201036c6e64", MINT_LNBITS_KEY: "new-key", LND_GRPC_HOST: "localhost:2"}
+
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("Diff(before, after): %v", err)
+	}
+	if diff["LND_MACAROON"].Before != "" || diff["LND_MACAROON"].After != Redacted {
+		t.Fatalf("expected macaroon to be redacted, got %+v", diff["LND_MACAROON"])
+	}
+	if diff["MINT_LNBITS_KEY"].Before != Redacted || diff["MINT_LNBITS_KEY"].After != Redacted {
+		t.Fatalf("expected lnbits key to be redacted, got %+v", diff["MINT_LNBITS_KEY"])
+	}
+	if diff["LND_GRPC_HOST"].After != "localhost:2" {
+		t.Fatalf("expected host to be kept, got %+v", diff["LND_GRPC_HOST"])
+	}
+}
+
+func TestIsSecretField(t *testing.T) {
+	secrets := []string{"STRIKE_KEY", "CLN_CLIENT_KEY", "LND_TLS_CERT", "LND_MACAROON", "nsec"}
+	for _, name := range secrets {
+		if !IsSecretField(name) {
+			t.Errorf("expected %s to be a secret", name)
+		}
+	}
+	public := []string{"NAME", "MINT_AUTH_OICD_CLIENT_ID", "KEYSET_ID", "LND_GRPC_HOST"}
+	for _, name := range public {
+		if IsSecretField(name) {
+			t.Errorf("expected %s to not be a secret", name)
+		}
+	}
+}
+
+func TestSignedExportVerifies(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("secp256k1.GeneratePrivateKey(): %v", err)
+	}
+	entries := []database.AuditEntry{
+		{Id: 2, Actor: "abcd", Action: ActionKeysetRotate, Ip: "127.0.0.1", CreatedAt: 20},
+		{Id: 1, Actor: "abcd", Action: ActionLogin, Ip: "127.0.0.1", CreatedAt: 10},
+	}
+
+	export, err := SignExport(entries, database.AuditFilter{Actor: "abcd"}, key, time.Unix(30, 0))
+	if err != nil {
+		t.Fatalf("SignExport: %v", err)
+	}
+
+	// the export should survive being written to a file
+	encoded, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("json.Marshal(export): %v", err)
+	}
+	var decoded Export
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatalf("json.Unmarshal(encoded): %v", err)
+	}
+
+	err = VerifyExport(decoded, export.Pubkey)
+	if err != nil {
+		t.Fatalf("VerifyExport: %v", err)
+	}
+
+	decoded.Payload = []byte(`{"entries":[],"filter":{},"exported_at":30}`)
+	err = VerifyExport(decoded, export.Pubkey)
+	if !errors.Is(err, ErrInvalidExportSignature) {
+		t.Fatalf("expected a modified export to fail, got %v", err)
+	}
+}
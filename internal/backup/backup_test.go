@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testSecret = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+	0xf0, 0xe1, 0xd2, 0xc3, 0xb4, 0xa5, 0x96, 0x87, 0x78, 0x69, 0x5a, 0x4b, 0x3c, 0x2d, 0x1e, 0xff,
+}
+
+func TestSplitCombine(t *testing.T) {
+	shares, err := Split(testSecret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("expected 5 shares, got %d", len(shares))
+	}
+
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var picked []Share
+		for _, i := range subset {
+			picked = append(picked, shares[i])
+		}
+		secret, err := Combine(picked)
+		if err != nil {
+			t.Fatalf("Combine(%v): %v", subset, err)
+		}
+		if !bytes.Equal(secret, testSecret) {
+			t.Errorf("Combine(%v) gave %x", subset, secret)
+		}
+	}
+
+	_, err = Combine(shares[:2])
+	if !errors.Is(err, ErrNotEnoughShares) {
+		t.Errorf("expected ErrNotEnoughShares, got %v", err)
+	}
+	_, err = Combine([]Share{shares[0], shares[1], shares[0]})
+	if !errors.Is(err, ErrDuplicateShare) {
+		t.Errorf("expected ErrDuplicateShare, got %v", err)
+	}
+
+	other, err := Split(testSecret, 3, 3)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	_, err = Combine([]Share{shares[0], shares[1], other[2]})
+	if !errors.Is(err, ErrMixedShares) {
+		t.Errorf("expected ErrMixedShares, got %v", err)
+	}
+	secret, err := Combine(other)
+	if err != nil || !bytes.Equal(secret, testSecret) {
+		t.Errorf("expected all shares to recover the secret with threshold n, got %x, %v", secret, err)
+	}
+}
+
+func TestSplitLimits(t *testing.T) {
+	_, err := Split(testSecret, 3, 1)
+	if !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("expected ErrInvalidThreshold, got %v", err)
+	}
+	_, err = Split(testSecret, 3, 4)
+	if !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("expected ErrInvalidThreshold, got %v", err)
+	}
+	_, err = Split(testSecret, 256, 2)
+	if !errors.Is(err, ErrTooManyShares) {
+		t.Errorf("expected ErrTooManyShares, got %v", err)
+	}
+	_, err = Split(nil, 3, 2)
+	if !errors.Is(err, ErrEmptySecret) {
+		t.Errorf("expected ErrEmptySecret, got %v", err)
+	}
+	_, err = Split(make([]byte, MaxSecretSize+1), 3, 2)
+	if !errors.Is(err, ErrSecretTooLong) {
+		t.Errorf("expected ErrSecretTooLong, got %v", err)
+	}
+}
+
+func TestMnemonic(t *testing.T) {
+	shares, err := Split(testSecret, 3, 2)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	mnemonic := shares[1].Mnemonic()
+	words := strings.Fields(mnemonic)
+	if len(words) != 31 {
+		t.Errorf("expected 31 words for a 32 byte key, got %d", len(words))
+	}
+
+	parsed, err := ParseMnemonic(mnemonic)
+	if err != nil {
+		t.Fatalf("ParseMnemonic: %v", err)
+	}
+	if parsed.SetId != shares[1].SetId || parsed.Threshold != 2 || parsed.Index != 2 || !bytes.Equal(parsed.Value, shares[1].Value) {
+		t.Errorf("the share changed after parsing: %+v", parsed)
+	}
+
+	// the first four letters of each word are enough
+	short := make([]string, len(words))
+	for i, word := range words {
+		short[i] = word[:min(4, len(word))]
+	}
+	parsed, err = ParseMnemonic(strings.ToUpper(strings.Join(short, "  ")))
+	if err != nil {
+		t.Fatalf("ParseMnemonic(short): %v", err)
+	}
+	if !bytes.Equal(parsed.Value, shares[1].Value) {
+		t.Errorf("the short share did not parse to the same value")
+	}
+
+	typo := append([]string{}, words...)
+	if typo[3] == "abandon" {
+		typo[3] = "ability"
+	} else {
+		typo[3] = "abandon"
+	}
+	_, err = ParseMnemonic(strings.Join(typo, " "))
+	if !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("expected ErrInvalidChecksum, got %v", err)
+	}
+
+	typo[3] = "notaword"
+	_, err = ParseMnemonic(strings.Join(typo, " "))
+	if !errors.Is(err, ErrUnknownWord) {
+		t.Errorf("expected ErrUnknownWord, got %v", err)
+	}
+
+	_, err = ParseMnemonic(strings.Join(words[:5], " "))
+	if !errors.Is(err, ErrInvalidShare) {
+		t.Errorf("expected ErrInvalidShare, got %v", err)
+	}
+}
+
+func TestMnemonicEverySecretLength(t *testing.T) {
+	for length := 1; length <= 64; length++ {
+		secret := bytes.Repeat([]byte{byte(length)}, length)
+		shares, err := Split(secret, 3, 2)
+		if err != nil {
+			t.Fatalf("Split(%d bytes): %v", length, err)
+		}
+		parsed := make([]Share, len(shares))
+		for i, share := range shares {
+			parsed[i], err = ParseMnemonic(share.Mnemonic())
+			if err != nil {
+				t.Fatalf("ParseMnemonic(%d bytes, share %d): %v", length, share.Index, err)
+			}
+			if !bytes.Equal(parsed[i].Value, share.Value) {
+				t.Fatalf("share %d of a %d byte secret changed after parsing", share.Index, length)
+			}
+		}
+		recovered, err := Combine(parsed[1:])
+		if err != nil || !bytes.Equal(recovered, secret) {
+			t.Fatalf("Combine(%d bytes) gave %x, %v", length, recovered, err)
+		}
+	}
+}
+
+// legacyMnemonic writes a share the way version 1 did, without the length of
+// the value.
+func legacyMnemonic(s Share) string {
+	data := []byte{legacyShareVersion}
+	data = binary.BigEndian.AppendUint16(data, s.SetId)
+	data = append(data, s.Threshold, s.Index)
+	data = append(data, s.Value...)
+	checksum := sha256.Sum256(data)
+	data = append(data, checksum[:checksumSize]...)
+
+	words := make([]string, wordsFor(len(data)))
+	for i := range words {
+		words[i] = wordlist[readBits(data, i*bitsPerWord, bitsPerWord)]
+	}
+	return strings.Join(words, " ")
+}
+
+func TestParseLegacyMnemonic(t *testing.T) {
+	// 1 and 5 byte values leave a whole byte of padding in the last word, 2
+	// and 32 do not
+	for _, length := range []int{1, 2, 5, 32} {
+		shares, err := Split(testSecret[:length], 2, 2)
+		if err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+		parsed, err := ParseMnemonic(legacyMnemonic(shares[0]))
+		if err != nil {
+			t.Fatalf("ParseMnemonic(legacy %d bytes): %v", length, err)
+		}
+		if parsed.SetId != shares[0].SetId || parsed.Index != 1 || !bytes.Equal(parsed.Value, shares[0].Value) {
+			t.Errorf("the legacy %d byte share changed after parsing: %+v", length, parsed)
+		}
+	}
+}
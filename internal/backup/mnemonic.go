@@ -0,0 +1,172 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// version 2 adds the length of the value to the header, version 1 shares
+	// are still read
+	shareVersion       = 2
+	legacyShareVersion = 1
+	headerSize         = 6
+	legacyHeaderSize   = 5
+	checksumSize       = 4
+	bitsPerWord        = 11
+	wordlistCount      = 1 << bitsPerWord
+)
+
+var (
+	ErrUnknownWord     = errors.New("unknown word in the share")
+	ErrInvalidChecksum = errors.New("the share has a typo, the checksum does not match")
+	ErrInvalidShare    = errors.New("invalid share")
+)
+
+// wordlistFile is the BIP-39 english word list. The first four letters of
+// each word are enough to tell them apart, so shares can be typed shorter.
+//
+//go:embed wordlist.txt
+var wordlistFile string
+
+var wordlist, wordIndex = loadWordlist()
+
+func loadWordlist() ([]string, map[string]int) {
+	words := strings.Fields(wordlistFile)
+	if len(words) != wordlistCount {
+		panic(fmt.Sprintf("the word list has %d words", len(words)))
+	}
+	index := make(map[string]int, len(words)*2)
+	for i, word := range words {
+		index[word] = i
+		if len(word) > 4 {
+			index[word[:4]] = i
+		}
+	}
+	return words, index
+}
+
+// Mnemonic writes the share as words. It holds the backup id, the threshold
+// and the index so the shares can be given in any order, the length of the
+// value because the last word can hold a whole padding byte, and a checksum to
+// catch typos.
+func (s Share) Mnemonic() string {
+	data := make([]byte, 0, headerSize+len(s.Value)+checksumSize)
+	data = append(data, shareVersion)
+	data = binary.BigEndian.AppendUint16(data, s.SetId)
+	data = append(data, s.Threshold, s.Index, uint8(len(s.Value)))
+	data = append(data, s.Value...)
+	checksum := sha256.Sum256(data)
+	data = append(data, checksum[:checksumSize]...)
+
+	words := make([]string, wordsFor(len(data)))
+	for i := range words {
+		words[i] = wordlist[readBits(data, i*bitsPerWord, bitsPerWord)]
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseMnemonic reads a share written by Mnemonic.
+func ParseMnemonic(mnemonic string) (Share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words)*bitsPerWord/8 < legacyHeaderSize+checksumSize+1 {
+		return Share{}, fmt.Errorf("%w: only %d words", ErrInvalidShare, len(words))
+	}
+
+	data := make([]byte, (len(words)*bitsPerWord+7)/8)
+	for i, word := range words {
+		value, ok := wordIndex[word]
+		if !ok {
+			return Share{}, fmt.Errorf("%w: word %d, %q", ErrUnknownWord, i+1, word)
+		}
+		writeBits(data, i*bitsPerWord, bitsPerWord, value)
+	}
+
+	for _, size := range shareSizes(data, len(words)) {
+		payload, checksum := data[:size-checksumSize], data[size-checksumSize:size]
+		expected := sha256.Sum256(payload)
+		if bytes.Equal(checksum, expected[:checksumSize]) {
+			return decodeShare(payload)
+		}
+	}
+	return Share{}, ErrInvalidChecksum
+}
+
+// shareSizes gives the byte counts the words can hold. Current shares say the
+// length of their value. Version 1 shares were only padded to the next word,
+// which can fit two lengths, so both are tried against the checksum.
+func shareSizes(data []byte, wordCount int) []int {
+	if data[0] == shareVersion {
+		size := headerSize + int(data[headerSize-1]) + checksumSize
+		if size > len(data) || wordsFor(size) != wordCount {
+			return nil
+		}
+		return []int{size}
+	}
+	var sizes []int
+	longest := wordCount * bitsPerWord / 8
+	for _, size := range []int{longest, longest - 1} {
+		if size >= legacyHeaderSize+checksumSize+1 && wordsFor(size) == wordCount {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+func decodeShare(payload []byte) (Share, error) {
+	var value []byte
+	switch payload[0] {
+	case shareVersion:
+		value = payload[headerSize:]
+		if len(value) == 0 || len(value) != int(payload[headerSize-1]) {
+			return Share{}, fmt.Errorf("%w: value of %d bytes", ErrInvalidShare, len(value))
+		}
+	case legacyShareVersion:
+		value = payload[legacyHeaderSize:]
+	default:
+		return Share{}, fmt.Errorf("%w: unknown version %d", ErrInvalidShare, payload[0])
+	}
+	share := Share{
+		SetId:     binary.BigEndian.Uint16(payload[1:3]),
+		Threshold: payload[3],
+		Index:     payload[4],
+		Value:     bytes.Clone(value),
+	}
+	if share.Index == 0 || share.Threshold < 2 {
+		return Share{}, fmt.Errorf("%w: index %d, threshold %d", ErrInvalidShare, share.Index, share.Threshold)
+	}
+	return share, nil
+}
+
+// wordsFor is the number of words needed for size bytes.
+func wordsFor(size int) int {
+	return (size*8 + bitsPerWord - 1) / bitsPerWord
+}
+
+// readBits reads count bits big endian from data at offset. Bits past the end
+// of data read as zero.
+func readBits(data []byte, offset int, count int) int {
+	value := 0
+	for i := range count {
+		bit := offset + i
+		value <<= 1
+		if bit/8 < len(data) && data[bit/8]&(0x80>>(bit%8)) != 0 {
+			value |= 1
+		}
+	}
+	return value
+}
+
+func writeBits(data []byte, offset int, count int, value int) {
+	for i := range count {
+		bit := offset + i
+		if value&(1<<(count-1-i)) != 0 && bit/8 < len(data) {
+			data[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+}
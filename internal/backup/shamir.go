@@ -0,0 +1,168 @@
+// Package backup splits the mint master key into Shamir shares so it can be
+// kept in several places, and puts it back together from enough of them.
+package backup
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+const (
+	MaxShares     = 255
+	MaxSecretSize = 255
+)
+
+var (
+	ErrInvalidThreshold = errors.New("the threshold should be between 2 and the number of shares")
+	ErrTooManyShares    = errors.New("there can be at most 255 shares")
+	ErrNotEnoughShares  = errors.New("not enough shares to recover the key")
+	ErrMixedShares      = errors.New("the shares are from different backups")
+	ErrDuplicateShare   = errors.New("the same share was given twice")
+	ErrEmptySecret      = errors.New("the secret is empty")
+	ErrSecretTooLong    = errors.New("the secret can be at most 255 bytes")
+)
+
+// Share is one point of the polynomials that hide the secret, one polynomial
+// per byte. Any Threshold shares with the same SetId give the secret back.
+type Share struct {
+	Value     []byte
+	SetId     uint16
+	Threshold uint8
+	Index     uint8
+}
+
+// Split creates n shares of secret. Any threshold of them recover it, fewer
+// tell nothing about it.
+func Split(secret []byte, n int, threshold int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	if len(secret) > MaxSecretSize {
+		return nil, ErrSecretTooLong
+	}
+	if n > MaxShares {
+		return nil, ErrTooManyShares
+	}
+	if threshold < 2 || threshold > n {
+		return nil, ErrInvalidThreshold
+	}
+
+	setId := make([]byte, 2)
+	_, err := rand.Read(setId)
+	if err != nil {
+		return nil, fmt.Errorf("rand.Read(setId). %w", err)
+	}
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{
+			Value:     make([]byte, len(secret)),
+			SetId:     uint16(setId[0])<<8 | uint16(setId[1]),
+			Threshold: uint8(threshold),
+			Index:     uint8(i + 1),
+		}
+	}
+
+	// the constant term of every polynomial is a byte of the secret
+	coefficients := make([]byte, threshold)
+	for b, secretByte := range secret {
+		coefficients[0] = secretByte
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, fmt.Errorf("rand.Read(coefficients). %w", err)
+		}
+		for i := range shares {
+			shares[i].Value[b] = evaluate(coefficients, shares[i].Index)
+		}
+	}
+	clear(coefficients)
+	return shares, nil
+}
+
+// Combine recovers the secret from at least Threshold shares of one backup.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+	first := shares[0]
+	seen := make(map[uint8]bool, len(shares))
+	for _, share := range shares {
+		if share.SetId != first.SetId || share.Threshold != first.Threshold || len(share.Value) != len(first.Value) {
+			return nil, ErrMixedShares
+		}
+		if seen[share.Index] {
+			return nil, ErrDuplicateShare
+		}
+		seen[share.Index] = true
+	}
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughShares, len(shares), first.Threshold)
+	}
+	shares = shares[:first.Threshold]
+
+	// lagrange interpolation at x = 0
+	secret := make([]byte, len(first.Value))
+	for i, share := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			// other.Index / (other.Index - share.Index), subtraction is xor
+			basis = gfMul(basis, gfDiv(other.Index, other.Index^share.Index))
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(share.Value[b], basis)
+		}
+	}
+	return secret, nil
+}
+
+// evaluate the polynomial with coefficients at x with Horner's method.
+func evaluate(coefficients []byte, x uint8) byte {
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1 and 3 as generator.
+var gfExp, gfLog = gfTables()
+
+func gfTables() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+	x := byte(1)
+	for i := range 255 {
+		exp[i] = x
+		log[x] = byte(i)
+		// multiply by 3: x*2 xor x, reduced by the polynomial
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x = double ^ x
+	}
+	for i := 255; i < len(exp); i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}
+
+func gfMul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a byte, b byte) byte {
+	if b == 0 {
+		panic("division by zero in GF(256)")
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
@@ -90,6 +90,209 @@ type LightningActivityRow struct {
 	SeenAt  int64
 }
 
+type JobRunStatus string
+
+const (
+	JobRunning   JobRunStatus = "running"
+	JobSucceeded JobRunStatus = "succeeded"
+	JobFailed    JobRunStatus = "failed"
+)
+
+// JobRun is the last execution of a scheduled background job
+type JobRun struct {
+	LastError  *string      `db:"last_error"`
+	Name       string       `db:"name"`
+	Status     JobRunStatus `db:"status"`
+	Instance   string       `db:"instance"`
+	StartedAt  int64        `db:"started_at"`
+	FinishedAt int64        `db:"finished_at"`
+	DurationMs int64        `db:"duration_ms"`
+	RunCount   int64        `db:"run_count"`
+}
+
+// AuditChange is the value of a field before and after an admin action.
+// Secrets are redacted before they get here.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry is a row of the append only audit log
+type AuditEntry struct {
+	Diff      map[string]AuditChange `db:"diff" json:"diff"`
+	Actor     string                 `db:"actor" json:"actor"`
+	Action    string                 `db:"action" json:"action"`
+	Ip        string                 `db:"ip" json:"ip"`
+	Id        int64                  `db:"id" json:"id"`
+	CreatedAt int64                  `db:"created_at" json:"created_at"`
+}
+
+// AuditFilter narrows down the audit log. Empty fields match every entry.
+type AuditFilter struct {
+	Actor  string `json:"actor,omitempty"`
+	Action string `json:"action,omitempty"`
+	Since  int64  `json:"since,omitempty"`
+	Until  int64  `json:"until,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// ConfigRevision is a stored version of the mint config. Every change adds
+// one, a rollback adds a copy of the revision it goes back to.
+type ConfigRevision struct {
+	Diff           map[string]AuditChange `db:"diff" json:"diff"`
+	RolledBackFrom *int64                 `db:"rolled_back_from" json:"rolled_back_from"`
+	Actor          string                 `db:"actor" json:"actor"`
+	Config         utils.Config           `db:"config" json:"-"`
+	Id             int64                  `db:"id" json:"id"`
+	CreatedAt      int64                  `db:"created_at" json:"created_at"`
+}
+
+// AdminRole is the access level of an admin account. Every role can do
+// everything the roles before it can.
+type AdminRole string
+
+const (
+	AdminViewer   AdminRole = "viewer"
+	AdminOperator AdminRole = "operator"
+	AdminOwner    AdminRole = "owner"
+)
+
+// Admin is a nostr pubkey allowed to log in to the admin dashboard
+type Admin struct {
+	Pubkey    string    `db:"pubkey"`
+	Role      AdminRole `db:"role"`
+	AddedBy   string    `db:"added_by"`
+	CreatedAt int64     `db:"created_at"`
+}
+
+// AdminSession is a login to the admin dashboard. It is removed with its admin.
+type AdminSession struct {
+	Id        string `db:"id"`
+	Pubkey    string `db:"pubkey"`
+	Ip        string `db:"ip"`
+	CreatedAt int64  `db:"created_at"`
+	ExpiresAt int64  `db:"expires_at"`
+	Revoked   bool   `db:"revoked"`
+}
+
+// AdminApiKey gives scripts access to the admin JSON api. Only the sha256 of
+// the key is stored. It is removed with the admin that created it.
+type AdminApiKey struct {
+	Id         string   `db:"id"`
+	Name       string   `db:"name"`
+	KeyHash    string   `db:"key_hash"`
+	Scopes     []string `db:"scopes"`
+	CreatedBy  string   `db:"created_by"`
+	CreatedAt  int64    `db:"created_at"`
+	ExpiresAt  int64    `db:"expires_at"`
+	LastUsedAt int64    `db:"last_used_at"`
+	Revoked    bool     `db:"revoked"`
+}
+
+// KeysetPolicy rotates the active keyset of a unit on a schedule or after it
+// signed an amount of ecash. A zero value turns a trigger off.
+type KeysetPolicy struct {
+	Unit              string `db:"unit" json:"unit"`
+	RotateEveryHours  uint64 `db:"rotate_every_hours" json:"rotate_every_hours"`
+	RotateAfterAmount uint64 `db:"rotate_after_amount" json:"rotate_after_amount"`
+	InputFeePpk       uint64 `db:"input_fee_ppk" json:"input_fee_ppk"`
+	// FinalExpiryHours is how long the keysets the policy creates are accepted
+	FinalExpiryHours uint64 `db:"final_expiry_hours" json:"final_expiry_hours"`
+	RotatedAt        int64  `db:"rotated_at" json:"rotated_at"`
+	UpdatedAt        int64  `db:"updated_at" json:"updated_at"`
+}
+
+// KeysetBalance is the ecash a keyset signed and how much of it was redeemed.
+// Archived is the part of Redeemed moved to the cold proofs table.
+type KeysetBalance struct {
+	KeysetId string `db:"keyset_id"`
+	Issued   uint64 `db:"issued"`
+	Redeemed uint64 `db:"redeemed"`
+	Archived uint64 `db:"archived"`
+}
+
+// Outstanding is the ecash of the keyset still in the hands of users.
+func (b KeysetBalance) Outstanding() int64 {
+	return int64(b.Issued) - int64(b.Redeemed)
+}
+
+// KeysetLogLeaf is a keyset written to the append only keyset transparency
+// log. Leaf is the encoded cashu.KeysetLogEntry.
+type KeysetLogLeaf struct {
+	KeysetId  string `db:"keyset_id"`
+	Leaf      []byte `db:"leaf"`
+	LeafHash  []byte `db:"leaf_hash"`
+	LeafIndex uint64 `db:"leaf_index"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+// KeysetLogRoot is a tree head of the keyset log. Signature is empty when the
+// signer can not sign messages.
+type KeysetLogRoot struct {
+	RootHash  []byte `db:"root_hash"`
+	Signature []byte `db:"signature"`
+	TreeSize  uint64 `db:"tree_size"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+// LiabilityLeaf is a blind signature or a spent proof counted in a liability
+// report. Point is the B_ of a signature or the Y of a proof, Signature the
+// C_ of a signature.
+type LiabilityLeaf struct {
+	Point     cashu.WrappedPublicKey `db:"point"`
+	Signature cashu.WrappedPublicKey `db:"signature"`
+	KeysetId  string                 `db:"keyset_id"`
+	Amount    uint64                 `db:"amount"`
+	LeafIndex uint64                 `db:"leaf_index"`
+	Redeemed  bool                   `db:"redeemed"`
+}
+
+// KeysetLiabilities are the roots of the issued and redeemed trees of a
+// keyset in a liability report.
+type KeysetLiabilities struct {
+	IssuedRoot    []byte `db:"issued_root"`
+	RedeemedRoot  []byte `db:"redeemed_root"`
+	KeysetId      string `db:"keyset_id"`
+	Unit          string `db:"unit"`
+	IssuedTotal   uint64 `db:"issued_total"`
+	IssuedCount   uint64 `db:"issued_count"`
+	RedeemedTotal uint64 `db:"redeemed_total"`
+	RedeemedCount uint64 `db:"redeemed_count"`
+}
+
+// LiabilityReport is a signed liability report. Signature is empty when the
+// signer can not sign messages.
+type LiabilityReport struct {
+	Keysets   []KeysetLiabilities `db:"-"`
+	Signature []byte              `db:"signature"`
+	Id        uint64              `db:"id"`
+	CreatedAt int64               `db:"created_at"`
+}
+
+// ReserveSnapshot compares the lightning balance with the outstanding ecash of
+// the bitcoin units, both in sats. LightningBalance is nil when the backend
+// could not be read.
+type ReserveSnapshot struct {
+	LightningBalance *uint64 `db:"lightning_balance"`
+	// InflatedKeysets redeemed more ecash than they issued
+	InflatedKeysets []string `db:"inflated_keysets"`
+	// UntrackedKeysets signed before the mint recorded its signatures, their
+	// outstanding ecash is unknown and left out of Outstanding
+	UntrackedKeysets []string `db:"untracked_keysets"`
+	Id               int64    `db:"id"`
+	CreatedAt        int64    `db:"created_at"`
+	Outstanding      uint64   `db:"outstanding"`
+}
+
+// ReservePercent is the lightning balance as a percentage of the outstanding
+// ecash. It is false when there is no balance or nothing outstanding.
+func (s ReserveSnapshot) ReservePercent() (float64, bool) {
+	if s.LightningBalance == nil || s.Outstanding == 0 {
+		return 0, false
+	}
+	return float64(*s.LightningBalance) / float64(s.Outstanding) * 100, true
+}
+
 type MintDB interface {
 	GetTx(ctx context.Context) (pgx.Tx, error)
 	Commit(ctx context.Context, tx pgx.Tx) error
@@ -102,6 +305,8 @@ type MintDB interface {
 	SaveNewSeeds(seeds []cashu.Seed) error
 	// This should be used to only update the Active Status of seed on the db
 	UpdateSeedsActiveStatus(tx pgx.Tx, seeds []cashu.Seed) error
+	// UpdateSeedsMasterKeyId tags seeds with the master key that derives them
+	UpdateSeedsMasterKeyId(tx pgx.Tx, seeds []cashu.Seed) error
 
 	SaveMintRequest(tx pgx.Tx, request cashu.MintRequestDB) error
 	ChangeMintRequestState(tx pgx.Tx, quote string, state cashu.ACTION_STATE, minted bool) error
@@ -122,6 +327,7 @@ type MintDB interface {
 	GetProofsFromQuote(tx pgx.Tx, quote string) (cashu.Proofs, error)
 	SetProofsState(tx pgx.Tx, proofs cashu.Proofs, state cashu.ProofState) error
 	DeleteProofs(tx pgx.Tx, proofs cashu.Proofs) error
+	GetArchivedProofsFromSecretCurve(tx pgx.Tx, Ys []cashu.WrappedPublicKey) (cashu.Proofs, error)
 
 	GetRestoreSigsFromBlindedMessages(tx pgx.Tx, B_ []cashu.WrappedPublicKey) ([]cashu.RecoverSigDB, error)
 	SaveRestoreSigs(tx pgx.Tx, recover_sigs []cashu.RecoverSigDB) error
@@ -165,4 +371,89 @@ type MintDB interface {
 	GetStatsFeeRows(ctx context.Context, tx pgx.Tx, startDate, endDate int64) ([]KeysetFeeRow, error)
 	GetStatsSnapshotsBySince(ctx context.Context, since int64) ([]StatsSnapshot, error)
 	InsertStatsSnapshot(ctx context.Context, snapshot StatsSnapshot) error
+
+	// keyset lifecycle
+	GetKeysetPolicies(ctx context.Context) ([]KeysetPolicy, error)
+	SaveKeysetPolicy(ctx context.Context, policy KeysetPolicy) error
+	GetKeysetBalances(ctx context.Context) ([]KeysetBalance, error)
+	// GetUntrackedKeysets returns the keysets that signed before the
+	// signatures were recorded, so their issued ecash is incomplete
+	GetUntrackedKeysets(ctx context.Context) ([]string, error)
+	// ArchiveKeysetProofs moves the spent proofs of a keyset to the cold table
+	ArchiveKeysetProofs(ctx context.Context, keysetId string, archivedAt int64) (int64, error)
+
+	// keyset transparency log, append only
+	GetKeysetLogLeaves(ctx context.Context) ([]KeysetLogLeaf, error)
+	// AppendKeysetLog adds the leaves and the tree head that covers them together
+	AppendKeysetLog(ctx context.Context, leaves []KeysetLogLeaf, root KeysetLogRoot) error
+	// GetKeysetLogRoot returns nil when there is no tree head of treeSize
+	GetKeysetLogRoot(ctx context.Context, treeSize uint64) (*KeysetLogRoot, error)
+	// GetLatestKeysetLogRoot returns nil when the log is empty
+	GetLatestKeysetLogRoot(ctx context.Context) (*KeysetLogRoot, error)
+
+	// liability reports
+	// GetLiabilitiesTx is a repeatable read transaction, so the leaves a report
+	// is built from and the ones saved with it are the same
+	GetLiabilitiesTx(ctx context.Context) (pgx.Tx, error)
+	// ForEachKeysetLiability calls fn with the blind signatures and the spent
+	// proofs, archived ones included, of a keyset one at a time. The issued
+	// leaves come first, each group ordered by point, the order of the trees.
+	ForEachKeysetLiability(ctx context.Context, tx pgx.Tx, keysetId string, fn func(leaf LiabilityLeaf) error) error
+	// SaveLiabilityReport stores the report with the leaves of its keysets, in
+	// the order of ForEachKeysetLiability, and drops the leaves of the reports
+	// older than the latest keepLeaves
+	SaveLiabilityReport(ctx context.Context, tx pgx.Tx, report LiabilityReport, keepLeaves int) (uint64, error)
+	// GetLiabilityReport returns the latest report when id is 0, nil when there is none
+	GetLiabilityReport(ctx context.Context, id uint64) (*LiabilityReport, error)
+	GetLiabilityReportLeaves(ctx context.Context, reportId uint64, keysetId string) ([]LiabilityLeaf, error)
+
+	// reserves
+	InsertReserveSnapshot(ctx context.Context, snapshot ReserveSnapshot) error
+	// GetLatestReserveSnapshot returns nil when there is none
+	GetLatestReserveSnapshot(ctx context.Context) (*ReserveSnapshot, error)
+	GetReserveSnapshotsBySince(ctx context.Context, since int64) ([]ReserveSnapshot, error)
+	// DeleteReserveSnapshotsBefore keeps the latest snapshot even when it is
+	// older than before and returns how many were deleted
+	DeleteReserveSnapshotsBefore(ctx context.Context, before int64) (int64, error)
+
+	// admin sessions revoked on logout, shared between mint replicas
+	AddRevokedAdminToken(ctx context.Context, tokenHash string, expiresAt int64) error
+	IsAdminTokenRevoked(ctx context.Context, tokenHash string, now int64) (bool, error)
+	DeleteExpiredAdminTokens(ctx context.Context, now int64) error
+
+	// scheduled jobs
+	// RunExclusive only calls fn if no other connection holds the advisory lock for lockKey.
+	RunExclusive(ctx context.Context, lockKey int64, fn func(ctx context.Context) error) (bool, error)
+	GetJobRun(ctx context.Context, name string) (*JobRun, error)
+	GetJobRuns(ctx context.Context) ([]JobRun, error)
+	SaveJobRun(ctx context.Context, run JobRun) error
+
+	// audit log
+	SaveAuditEntry(ctx context.Context, entry AuditEntry) error
+	GetAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+
+	// config history
+	SaveConfigRevision(tx pgx.Tx, revision ConfigRevision) (int64, error)
+	GetLatestConfigRevision(tx pgx.Tx) (*ConfigRevision, error)
+	GetConfigRevision(ctx context.Context, id int64) (*ConfigRevision, error)
+	GetConfigRevisions(ctx context.Context, limit int) ([]ConfigRevision, error)
+
+	// admin accounts and their sessions
+	GetAdmins(ctx context.Context) ([]Admin, error)
+	GetAdmin(ctx context.Context, pubkey string) (*Admin, error)
+	SaveAdmin(ctx context.Context, admin Admin) error
+	DeleteAdmin(ctx context.Context, pubkey string) error
+	SaveAdminSession(ctx context.Context, session AdminSession) error
+	GetAdminSession(ctx context.Context, id string) (*AdminSession, error)
+	GetActiveAdminSessions(ctx context.Context, now int64) ([]AdminSession, error)
+	RevokeAdminSession(ctx context.Context, id string) error
+	DeleteExpiredAdminSessions(ctx context.Context, now int64) error
+
+	// admin api keys
+	SaveAdminApiKey(ctx context.Context, key AdminApiKey) error
+	GetAdminApiKeys(ctx context.Context) ([]AdminApiKey, error)
+	GetAdminApiKeyByHash(ctx context.Context, keyHash string) (*AdminApiKey, error)
+	// RevokeAdminApiKey returns false when there is no key with id
+	RevokeAdminApiKey(ctx context.Context, id string) (bool, error)
+	UpdateAdminApiKeyLastUsed(ctx context.Context, id string, lastUsedAt int64) error
 }
@@ -15,17 +15,76 @@ const POSTGRES DatabaseType = "postgres"
 //go:embed migrations/*.sql
 var embedMigrations embed.FS //
 
-func RunMigration(db *sql.DB, databaseType DatabaseType) error {
+func setup(databaseType DatabaseType) error {
 	goose.SetBaseFS(embedMigrations)
 	err := goose.SetDialect(string(databaseType))
 	if err != nil {
 		return fmt.Errorf(`goose.SetDialect(string(databaseType)). %w`, err)
 	}
+	return nil
+}
+
+func RunMigration(db *sql.DB, databaseType DatabaseType) error {
+	err := setup(databaseType)
+	if err != nil {
+		return err
+	}
 
 	gooseErr := goose.Up(db, "migrations")
 	if gooseErr != nil {
-		return fmt.Errorf(`goose.Up(db, "migrations"). %w`, err)
+		return fmt.Errorf(`goose.Up(db, "migrations"). %w`, gooseErr)
+	}
+
+	return nil
+}
+
+// RollbackMigration undoes the last applied migration.
+func RollbackMigration(db *sql.DB, databaseType DatabaseType) error {
+	err := setup(databaseType)
+	if err != nil {
+		return err
+	}
+
+	err = goose.Down(db, "migrations")
+	if err != nil {
+		return fmt.Errorf(`goose.Down(db, "migrations"). %w`, err)
+	}
+	return nil
+}
+
+// MigrationStatus logs which migrations are applied and which are pending.
+func MigrationStatus(db *sql.DB, databaseType DatabaseType) error {
+	err := setup(databaseType)
+	if err != nil {
+		return err
 	}
 
+	err = goose.Status(db, "migrations")
+	if err != nil {
+		return fmt.Errorf(`goose.Status(db, "migrations"). %w`, err)
+	}
 	return nil
 }
+
+// MigrationVersion returns the version the database is at and the latest
+// migration shipped with this build.
+func MigrationVersion(db *sql.DB, databaseType DatabaseType) (int64, int64, error) {
+	err := setup(databaseType)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	current, err := goose.GetDBVersion(db)
+	if err != nil {
+		return 0, 0, fmt.Errorf(`goose.GetDBVersion(db). %w`, err)
+	}
+	migrations, err := goose.CollectMigrations("migrations", 0, goose.MaxVersion)
+	if err != nil {
+		return 0, 0, fmt.Errorf(`goose.CollectMigrations("migrations", 0, goose.MaxVersion). %w`, err)
+	}
+	latest, err := migrations.Last()
+	if err != nil {
+		return 0, 0, fmt.Errorf(`migrations.Last(). %w`, err)
+	}
+	return current, latest.Version, nil
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS response_cache (
+    key TEXT PRIMARY KEY,
+    value BYTEA NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_response_cache_expires_at ON response_cache (expires_at);
+
+CREATE TABLE IF NOT EXISTS admin_token_blacklist (
+    token_hash TEXT PRIMARY KEY,
+    expires_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_admin_token_blacklist_expires_at ON admin_token_blacklist (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS response_cache;
+DROP TABLE IF EXISTS admin_token_blacklist;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    name TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    instance TEXT NOT NULL,
+    started_at BIGINT NOT NULL,
+    finished_at BIGINT NOT NULL DEFAULT 0,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    run_count BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS scheduled_jobs;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS admins (
+    pubkey TEXT PRIMARY KEY,
+    role TEXT NOT NULL,
+    added_by TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS admin_sessions (
+    id TEXT PRIMARY KEY,
+    pubkey TEXT NOT NULL REFERENCES admins(pubkey) ON DELETE CASCADE,
+    ip TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS admin_sessions_expires_at_idx ON admin_sessions (expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS admin_sessions_expires_at_idx;
+DROP TABLE IF EXISTS admin_sessions;
+DROP TABLE IF EXISTS admins;
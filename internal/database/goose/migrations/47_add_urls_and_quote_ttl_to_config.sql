@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE config ADD COLUMN urls TEXT[];
+ALTER TABLE config ADD COLUMN mint_quote_ttl BIGINT NOT NULL DEFAULT 900;
+ALTER TABLE config ADD COLUMN melt_quote_ttl BIGINT NOT NULL DEFAULT 900;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE config DROP COLUMN IF EXISTS urls;
+ALTER TABLE config DROP COLUMN IF EXISTS mint_quote_ttl;
+ALTER TABLE config DROP COLUMN IF EXISTS melt_quote_ttl;
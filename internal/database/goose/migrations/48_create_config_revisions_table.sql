@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS config_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at BIGINT NOT NULL,
+    actor TEXT NOT NULL,
+    config JSONB NOT NULL,
+    diff JSONB NOT NULL DEFAULT '{}',
+    rolled_back_from BIGINT REFERENCES config_revisions(id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS config_revisions;
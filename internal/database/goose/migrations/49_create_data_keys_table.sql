@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS data_keys (
+    id BIGSERIAL PRIMARY KEY,
+    kms TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at BIGINT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS data_keys_active_idx ON data_keys (active) WHERE active;
+
+-- +goose Down
+DROP INDEX IF EXISTS data_keys_active_idx;
+DROP TABLE IF EXISTS data_keys;
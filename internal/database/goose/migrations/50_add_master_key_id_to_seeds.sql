@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE seeds ADD COLUMN IF NOT EXISTS master_key_id TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE seeds DROP COLUMN IF EXISTS master_key_id;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS keyset_policies (
+    unit TEXT PRIMARY KEY,
+    rotate_every_hours BIGINT NOT NULL DEFAULT 0,
+    rotate_after_amount BIGINT NOT NULL DEFAULT 0,
+    input_fee_ppk BIGINT NOT NULL DEFAULT 0,
+    final_expiry_hours BIGINT NOT NULL DEFAULT 0,
+    rotated_at BIGINT NOT NULL DEFAULT 0,
+    updated_at BIGINT NOT NULL
+);
+
+-- spent proofs of keysets past their final expiry
+CREATE TABLE IF NOT EXISTS proofs_archive (LIKE proofs INCLUDING DEFAULTS);
+ALTER TABLE proofs_archive ADD COLUMN IF NOT EXISTS archived_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_proofs_archive_y ON proofs_archive (y);
+CREATE INDEX IF NOT EXISTS idx_proofs_id ON proofs (id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_proofs_id;
+DROP INDEX IF EXISTS idx_proofs_archive_y;
+DROP TABLE IF EXISTS proofs_archive;
+DROP TABLE IF EXISTS keyset_policies;
@@ -0,0 +1,50 @@
+-- +goose Up
+-- every keyset the mint published, in the order of the merkle tree
+CREATE TABLE IF NOT EXISTS keyset_log (
+    leaf_index BIGINT PRIMARY KEY,
+    keyset_id TEXT NOT NULL UNIQUE,
+    leaf BYTEA NOT NULL,
+    leaf_hash BYTEA NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS keyset_log_roots (
+    tree_size BIGINT PRIMARY KEY,
+    root_hash BYTEA NOT NULL,
+    signature BYTEA,
+    created_at BIGINT NOT NULL
+);
+
+-- published keysets and tree heads can not be changed or removed
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION keyset_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'the keyset log is append only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER keyset_log_append_only
+    BEFORE UPDATE OR DELETE ON keyset_log
+    FOR EACH ROW EXECUTE FUNCTION keyset_log_append_only();
+
+CREATE TRIGGER keyset_log_no_truncate
+    BEFORE TRUNCATE ON keyset_log
+    FOR EACH STATEMENT EXECUTE FUNCTION keyset_log_append_only();
+
+CREATE TRIGGER keyset_log_roots_append_only
+    BEFORE UPDATE OR DELETE ON keyset_log_roots
+    FOR EACH ROW EXECUTE FUNCTION keyset_log_append_only();
+
+CREATE TRIGGER keyset_log_roots_no_truncate
+    BEFORE TRUNCATE ON keyset_log_roots
+    FOR EACH STATEMENT EXECUTE FUNCTION keyset_log_append_only();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS keyset_log_roots_no_truncate ON keyset_log_roots;
+DROP TRIGGER IF EXISTS keyset_log_roots_append_only ON keyset_log_roots;
+DROP TRIGGER IF EXISTS keyset_log_no_truncate ON keyset_log;
+DROP TRIGGER IF EXISTS keyset_log_append_only ON keyset_log;
+DROP FUNCTION IF EXISTS keyset_log_append_only();
+DROP TABLE IF EXISTS keyset_log_roots;
+DROP TABLE IF EXISTS keyset_log;
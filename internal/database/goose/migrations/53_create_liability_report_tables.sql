@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS liability_reports (
+    id BIGSERIAL PRIMARY KEY,
+    signature BYTEA,
+    created_at BIGINT NOT NULL
+);
+
+-- roots of the issued and redeemed merkle sum trees of every keyset
+CREATE TABLE IF NOT EXISTS liability_report_keysets (
+    report_id BIGINT NOT NULL REFERENCES liability_reports(id),
+    keyset_id TEXT NOT NULL,
+    unit TEXT NOT NULL,
+    issued_root BYTEA NOT NULL,
+    issued_total BIGINT NOT NULL,
+    issued_count BIGINT NOT NULL,
+    redeemed_root BYTEA NOT NULL,
+    redeemed_total BIGINT NOT NULL,
+    redeemed_count BIGINT NOT NULL,
+    PRIMARY KEY (report_id, keyset_id)
+);
+
+-- leaves of the trees, only kept for the latest reports to serve proofs
+CREATE TABLE IF NOT EXISTS liability_report_leaves (
+    report_id BIGINT NOT NULL REFERENCES liability_reports(id),
+    keyset_id TEXT NOT NULL,
+    redeemed BOOLEAN NOT NULL,
+    leaf_index BIGINT NOT NULL,
+    point BYTEA NOT NULL,
+    signature BYTEA,
+    amount BIGINT NOT NULL,
+    PRIMARY KEY (report_id, keyset_id, redeemed, leaf_index)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS liability_report_leaves;
+DROP TABLE IF EXISTS liability_report_keysets;
+DROP TABLE IF EXISTS liability_reports;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- outstanding ecash of the bitcoin units compared with the lightning balance, in sats
+CREATE TABLE IF NOT EXISTS reserve_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    created_at BIGINT NOT NULL,
+    lightning_balance BIGINT,
+    outstanding BIGINT NOT NULL,
+    inflated_keysets TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS reserve_snapshots_created_at_idx ON reserve_snapshots (created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS reserve_snapshots_created_at_idx;
+DROP TABLE IF EXISTS reserve_snapshots;
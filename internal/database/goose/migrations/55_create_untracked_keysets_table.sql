@@ -0,0 +1,19 @@
+-- +goose Up
+-- keysets that signed ecash before recovery_signature (migration 6) recorded
+-- the signatures, how much of their ecash is outstanding can not be known
+CREATE TABLE IF NOT EXISTS untracked_keysets (
+    id TEXT PRIMARY KEY
+);
+
+INSERT INTO untracked_keysets (id)
+SELECT seeds.id FROM seeds
+WHERE seeds.created_at < (
+    SELECT EXTRACT(EPOCH FROM MIN(tstamp))::BIGINT FROM goose_db_version WHERE version_id = 6 AND is_applied
+)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE reserve_snapshots ADD COLUMN IF NOT EXISTS untracked_keysets TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE reserve_snapshots DROP COLUMN IF EXISTS untracked_keysets;
+DROP TABLE IF EXISTS untracked_keysets;
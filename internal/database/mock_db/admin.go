@@ -14,21 +14,31 @@ import (
 )
 
 func (m *MockDB) SaveNostrAuth(auth database.NostrLoginAuth) error {
+	for i := 0; i < len(m.NostrAuth); i++ {
+		if m.NostrAuth[i].Nonce == auth.Nonce {
+			return database.ErrDB
+		}
+	}
+	m.NostrAuth = append(m.NostrAuth, auth)
 	return nil
 }
 
 func (m *MockDB) UpdateNostrAuthActivation(tx pgx.Tx, nonce string, activated bool) error {
+	for i := 0; i < len(m.NostrAuth); i++ {
+		if m.NostrAuth[i].Nonce == nonce {
+			m.NostrAuth[i].Activated = activated
+		}
+	}
 	return nil
 }
 
 func (m *MockDB) GetNostrAuth(tx pgx.Tx, nonce string) (database.NostrLoginAuth, error) {
-	var seeds []database.NostrLoginAuth
 	for i := 0; i < len(m.NostrAuth); i++ {
-		if m.Seeds[i].Unit == nonce {
-			seeds = append(seeds, m.NostrAuth[i])
+		if m.NostrAuth[i].Nonce == nonce {
+			return m.NostrAuth[i], nil
 		}
 	}
-	return seeds[0], nil
+	return database.NostrLoginAuth{}, pgx.ErrNoRows
 }
 
 func (m *MockDB) AddLiquiditySwap(tx pgx.Tx, swap utils.LiquiditySwap) error {
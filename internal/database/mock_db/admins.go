@@ -0,0 +1,87 @@
+package mockdb
+
+import (
+	"context"
+	"slices"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) GetAdmins(ctx context.Context) ([]database.Admin, error) {
+	return m.Admins, nil
+}
+
+func (m *MockDB) GetAdmin(ctx context.Context, pubkey string) (*database.Admin, error) {
+	for i := range m.Admins {
+		if m.Admins[i].Pubkey == pubkey {
+			admin := m.Admins[i]
+			return &admin, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDB) SaveAdmin(ctx context.Context, admin database.Admin) error {
+	for i := range m.Admins {
+		if m.Admins[i].Pubkey == admin.Pubkey {
+			m.Admins[i].Role = admin.Role
+			return nil
+		}
+	}
+	m.Admins = append(m.Admins, admin)
+	return nil
+}
+
+func (m *MockDB) DeleteAdmin(ctx context.Context, pubkey string) error {
+	m.Admins = slices.DeleteFunc(m.Admins, func(admin database.Admin) bool {
+		return admin.Pubkey == pubkey
+	})
+	m.AdminSessions = slices.DeleteFunc(m.AdminSessions, func(session database.AdminSession) bool {
+		return session.Pubkey == pubkey
+	})
+	m.AdminApiKeys = slices.DeleteFunc(m.AdminApiKeys, func(key database.AdminApiKey) bool {
+		return key.CreatedBy == pubkey
+	})
+	return nil
+}
+
+func (m *MockDB) SaveAdminSession(ctx context.Context, session database.AdminSession) error {
+	m.AdminSessions = append(m.AdminSessions, session)
+	return nil
+}
+
+func (m *MockDB) GetAdminSession(ctx context.Context, id string) (*database.AdminSession, error) {
+	for i := range m.AdminSessions {
+		if m.AdminSessions[i].Id == id {
+			session := m.AdminSessions[i]
+			return &session, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDB) GetActiveAdminSessions(ctx context.Context, now int64) ([]database.AdminSession, error) {
+	sessions := []database.AdminSession{}
+	for _, session := range m.AdminSessions {
+		if session.ExpiresAt > now && !session.Revoked {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *MockDB) RevokeAdminSession(ctx context.Context, id string) error {
+	for i := range m.AdminSessions {
+		if m.AdminSessions[i].Id == id {
+			m.AdminSessions[i].Revoked = true
+		}
+	}
+	return nil
+}
+
+func (m *MockDB) DeleteExpiredAdminSessions(ctx context.Context, now int64) error {
+	m.AdminSessions = slices.DeleteFunc(m.AdminSessions, func(session database.AdminSession) bool {
+		return session.ExpiresAt <= now
+	})
+	return nil
+}
@@ -0,0 +1,48 @@
+package mockdb
+
+import (
+	"context"
+	"slices"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) SaveAdminApiKey(ctx context.Context, key database.AdminApiKey) error {
+	m.AdminApiKeys = append(m.AdminApiKeys, key)
+	return nil
+}
+
+func (m *MockDB) GetAdminApiKeys(ctx context.Context) ([]database.AdminApiKey, error) {
+	return m.AdminApiKeys, nil
+}
+
+func (m *MockDB) GetAdminApiKeyByHash(ctx context.Context, keyHash string) (*database.AdminApiKey, error) {
+	for i := range m.AdminApiKeys {
+		if m.AdminApiKeys[i].KeyHash == keyHash {
+			key := m.AdminApiKeys[i]
+			key.Scopes = slices.Clone(key.Scopes)
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDB) RevokeAdminApiKey(ctx context.Context, id string) (bool, error) {
+	revoked := false
+	for i := range m.AdminApiKeys {
+		if m.AdminApiKeys[i].Id == id {
+			m.AdminApiKeys[i].Revoked = true
+			revoked = true
+		}
+	}
+	return revoked, nil
+}
+
+func (m *MockDB) UpdateAdminApiKeyLastUsed(ctx context.Context, id string, lastUsedAt int64) error {
+	for i := range m.AdminApiKeys {
+		if m.AdminApiKeys[i].Id == id {
+			m.AdminApiKeys[i].LastUsedAt = lastUsedAt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package mockdb
+
+import (
+	"context"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) SaveAuditEntry(ctx context.Context, entry database.AuditEntry) error {
+	entry.Id = int64(len(m.AuditLog) + 1)
+	m.AuditLog = append(m.AuditLog, entry)
+	return nil
+}
+
+func (m *MockDB) GetAuditEntries(ctx context.Context, filter database.AuditFilter) ([]database.AuditEntry, error) {
+	entries := []database.AuditEntry{}
+	// newest first like the postgres query
+	for i := len(m.AuditLog) - 1; i >= 0; i-- {
+		entry := m.AuditLog[i]
+		if filter.Actor != "" && entry.Actor != filter.Actor {
+			continue
+		}
+		if filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		if filter.Since != 0 && entry.CreatedAt < filter.Since {
+			continue
+		}
+		if filter.Until != 0 && entry.CreatedAt > filter.Until {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
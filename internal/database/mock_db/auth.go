@@ -41,3 +41,25 @@ func (m *MockDB) UpdateLastLoggedIn(tx pgx.Tx, sub string, lastLoggedIn uint64)
 	}
 	return nil
 }
+
+func (m *MockDB) AddRevokedAdminToken(ctx context.Context, tokenHash string, expiresAt int64) error {
+	if m.RevokedAdminTokens == nil {
+		m.RevokedAdminTokens = make(map[string]int64)
+	}
+	m.RevokedAdminTokens[tokenHash] = expiresAt
+	return nil
+}
+
+func (m *MockDB) IsAdminTokenRevoked(ctx context.Context, tokenHash string, now int64) (bool, error) {
+	expiresAt, exists := m.RevokedAdminTokens[tokenHash]
+	return exists && expiresAt > now, nil
+}
+
+func (m *MockDB) DeleteExpiredAdminTokens(ctx context.Context, now int64) error {
+	for tokenHash, expiresAt := range m.RevokedAdminTokens {
+		if expiresAt <= now {
+			delete(m.RevokedAdminTokens, tokenHash)
+		}
+	}
+	return nil
+}
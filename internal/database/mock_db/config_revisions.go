@@ -0,0 +1,42 @@
+package mockdb
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) SaveConfigRevision(tx pgx.Tx, revision database.ConfigRevision) (int64, error) {
+	_ = tx
+	revision.Id = int64(len(m.ConfigRevisions) + 1)
+	m.ConfigRevisions = append(m.ConfigRevisions, revision)
+	return revision.Id, nil
+}
+
+func (m *MockDB) GetLatestConfigRevision(tx pgx.Tx) (*database.ConfigRevision, error) {
+	_ = tx
+	if len(m.ConfigRevisions) == 0 {
+		return nil, nil
+	}
+	revision := m.ConfigRevisions[len(m.ConfigRevisions)-1]
+	return &revision, nil
+}
+
+func (m *MockDB) GetConfigRevision(ctx context.Context, id int64) (*database.ConfigRevision, error) {
+	for _, revision := range m.ConfigRevisions {
+		if revision.Id == id {
+			return &revision, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDB) GetConfigRevisions(ctx context.Context, limit int) ([]database.ConfigRevision, error) {
+	revisions := []database.ConfigRevision{}
+	// newest first like the postgres query
+	for i := len(m.ConfigRevisions) - 1; i >= 0 && len(revisions) < limit; i-- {
+		revisions = append(revisions, m.ConfigRevisions[i])
+	}
+	return revisions, nil
+}
@@ -0,0 +1,39 @@
+package mockdb
+
+import (
+	"context"
+	"sort"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) RunExclusive(ctx context.Context, lockKey int64, fn func(ctx context.Context) error) (bool, error) {
+	return true, fn(ctx)
+}
+
+func (m *MockDB) GetJobRun(ctx context.Context, name string) (*database.JobRun, error) {
+	run, exists := m.JobRuns[name]
+	if !exists {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func (m *MockDB) GetJobRuns(ctx context.Context) ([]database.JobRun, error) {
+	runs := make([]database.JobRun, 0, len(m.JobRuns))
+	for _, run := range m.JobRuns {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Name < runs[j].Name
+	})
+	return runs, nil
+}
+
+func (m *MockDB) SaveJobRun(ctx context.Context, run database.JobRun) error {
+	if m.JobRuns == nil {
+		m.JobRuns = make(map[string]database.JobRun)
+	}
+	m.JobRuns[run.Name] = run
+	return nil
+}
@@ -0,0 +1,41 @@
+package mockdb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) GetKeysetLogLeaves(ctx context.Context) ([]database.KeysetLogLeaf, error) {
+	return slices.Clone(m.KeysetLog), nil
+}
+
+func (m *MockDB) AppendKeysetLog(ctx context.Context, leaves []database.KeysetLogLeaf, root database.KeysetLogRoot) error {
+	for _, leaf := range leaves {
+		if leaf.LeafIndex != uint64(len(m.KeysetLog)) {
+			return databaseError(fmt.Errorf("leaf %d is not the next one of the log", leaf.LeafIndex))
+		}
+		m.KeysetLog = append(m.KeysetLog, leaf)
+	}
+	m.KeysetLogRoots = append(m.KeysetLogRoots, root)
+	return nil
+}
+
+func (m *MockDB) GetKeysetLogRoot(ctx context.Context, treeSize uint64) (*database.KeysetLogRoot, error) {
+	for _, root := range m.KeysetLogRoots {
+		if root.TreeSize == treeSize {
+			return &root, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDB) GetLatestKeysetLogRoot(ctx context.Context) (*database.KeysetLogRoot, error) {
+	if len(m.KeysetLogRoots) == 0 {
+		return nil, nil
+	}
+	root := m.KeysetLogRoots[len(m.KeysetLogRoots)-1]
+	return &root, nil
+}
@@ -0,0 +1,88 @@
+package mockdb
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) GetKeysetPolicies(ctx context.Context) ([]database.KeysetPolicy, error) {
+	policies := slices.Clone(m.KeysetPolicies)
+	slices.SortFunc(policies, func(a, b database.KeysetPolicy) int {
+		return strings.Compare(a.Unit, b.Unit)
+	})
+	return policies, nil
+}
+
+func (m *MockDB) SaveKeysetPolicy(ctx context.Context, policy database.KeysetPolicy) error {
+	for i := range m.KeysetPolicies {
+		if m.KeysetPolicies[i].Unit == policy.Unit {
+			m.KeysetPolicies[i] = policy
+			return nil
+		}
+	}
+	m.KeysetPolicies = append(m.KeysetPolicies, policy)
+	return nil
+}
+
+func (m *MockDB) GetKeysetBalances(ctx context.Context) ([]database.KeysetBalance, error) {
+	balances := make(map[string]*database.KeysetBalance)
+	balance := func(id string) *database.KeysetBalance {
+		if _, exists := balances[id]; !exists {
+			balances[id] = &database.KeysetBalance{KeysetId: id, Issued: 0, Redeemed: 0, Archived: 0}
+		}
+		return balances[id]
+	}
+	for _, sig := range m.RecoverSigDB {
+		balance(sig.Id).Issued += sig.Amount
+	}
+	for _, proof := range m.Proofs {
+		if proof.State == cashu.PROOF_SPENT {
+			balance(proof.Id).Redeemed += proof.Amount
+		}
+	}
+	for _, proof := range m.ArchivedProofs {
+		balance(proof.Id).Redeemed += proof.Amount
+		balance(proof.Id).Archived += proof.Amount
+	}
+
+	rows := make([]database.KeysetBalance, 0, len(balances))
+	for _, balance := range balances {
+		rows = append(rows, *balance)
+	}
+	slices.SortFunc(rows, func(a, b database.KeysetBalance) int {
+		return strings.Compare(a.KeysetId, b.KeysetId)
+	})
+	return rows, nil
+}
+
+func (m *MockDB) GetUntrackedKeysets(ctx context.Context) ([]string, error) {
+	return slices.Clone(m.UntrackedKeysets), nil
+}
+
+func (m *MockDB) ArchiveKeysetProofs(ctx context.Context, keysetId string, archivedAt int64) (int64, error) {
+	var archived int64
+	m.Proofs = slices.DeleteFunc(m.Proofs, func(proof cashu.Proof) bool {
+		if proof.Id != keysetId || proof.State != cashu.PROOF_SPENT {
+			return false
+		}
+		m.ArchivedProofs = append(m.ArchivedProofs, proof)
+		archived++
+		return true
+	})
+	return archived, nil
+}
+
+func (m *MockDB) GetArchivedProofsFromSecretCurve(tx pgx.Tx, Ys []cashu.WrappedPublicKey) (cashu.Proofs, error) {
+	var proofs cashu.Proofs
+	for _, proof := range m.ArchivedProofs {
+		if slices.Contains(Ys, proof.Y) {
+			proofs = append(proofs, proof)
+		}
+	}
+	return proofs, nil
+}
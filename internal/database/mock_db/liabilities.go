@@ -0,0 +1,105 @@
+package mockdb
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+// keysetLiabilities returns the leaves of a keyset with their index, in the
+// order of ForEachKeysetLiability.
+func (m *MockDB) keysetLiabilities(keysetId string) []database.LiabilityLeaf {
+	leaves := make([]database.LiabilityLeaf, 0)
+	for _, sig := range m.RecoverSigDB {
+		if sig.Id == keysetId {
+			leaves = append(leaves, database.LiabilityLeaf{Point: sig.B_, Signature: sig.C_, KeysetId: sig.Id, Amount: sig.Amount, LeafIndex: 0, Redeemed: false})
+		}
+	}
+	for _, proof := range slices.Concat(m.Proofs, m.ArchivedProofs) {
+		if proof.Id == keysetId && proof.State == cashu.PROOF_SPENT {
+			leaves = append(leaves, database.LiabilityLeaf{Point: proof.Y, Signature: cashu.WrappedPublicKey{PublicKey: nil}, KeysetId: proof.Id, Amount: proof.Amount, LeafIndex: 0, Redeemed: true})
+		}
+	}
+	slices.SortFunc(leaves, func(a, b database.LiabilityLeaf) int {
+		if a.Redeemed != b.Redeemed {
+			if b.Redeemed {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(bytes.Compare(a.Point.SerializeCompressed(), b.Point.SerializeCompressed()), cmp.Compare(a.Amount, b.Amount))
+	})
+	var issued, redeemed uint64
+	for i := range leaves {
+		if leaves[i].Redeemed {
+			leaves[i].LeafIndex = redeemed
+			redeemed++
+		} else {
+			leaves[i].LeafIndex = issued
+			issued++
+		}
+	}
+	return leaves
+}
+
+func (m *MockDB) GetLiabilitiesTx(ctx context.Context) (pgx.Tx, error) {
+	return &pgxpool.Tx{}, nil
+}
+
+func (m *MockDB) ForEachKeysetLiability(ctx context.Context, tx pgx.Tx, keysetId string, fn func(leaf database.LiabilityLeaf) error) error {
+	for _, leaf := range m.keysetLiabilities(keysetId) {
+		leaf.LeafIndex = 0
+		err := fn(leaf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockDB) SaveLiabilityReport(ctx context.Context, tx pgx.Tx, report database.LiabilityReport, keepLeaves int) (uint64, error) {
+	report.Id = uint64(len(m.LiabilityReports) + 1)
+	m.LiabilityReports = append(m.LiabilityReports, report)
+	if m.LiabilityLeaves == nil {
+		m.LiabilityLeaves = make(map[uint64][]database.LiabilityLeaf)
+	}
+	leaves := make([]database.LiabilityLeaf, 0)
+	for _, keyset := range report.Keysets {
+		leaves = append(leaves, m.keysetLiabilities(keyset.KeysetId)...)
+	}
+	m.LiabilityLeaves[report.Id] = leaves
+	for id := range m.LiabilityLeaves {
+		if id+uint64(keepLeaves) <= report.Id {
+			delete(m.LiabilityLeaves, id)
+		}
+	}
+	return report.Id, nil
+}
+
+func (m *MockDB) GetLiabilityReport(ctx context.Context, id uint64) (*database.LiabilityReport, error) {
+	if id == 0 {
+		id = uint64(len(m.LiabilityReports))
+	}
+	if id == 0 || id > uint64(len(m.LiabilityReports)) {
+		return nil, nil
+	}
+	report := m.LiabilityReports[id-1]
+	return &report, nil
+}
+
+func (m *MockDB) GetLiabilityReportLeaves(ctx context.Context, reportId uint64, keysetId string) ([]database.LiabilityLeaf, error) {
+	leaves := make([]database.LiabilityLeaf, 0)
+	for _, leaf := range m.LiabilityLeaves[reportId] {
+		if leaf.KeysetId == keysetId {
+			leaves = append(leaves, leaf)
+		}
+	}
+	return leaves, nil
+}
@@ -22,8 +22,23 @@ type MockDB struct {
 	UpdateNostrNotificationConfigErr error
 	NostrNotificationConfig          *utils.NostrNotificationConfig
 	LastLightningSearch              *string
+	RevokedAdminTokens               map[string]int64
+	JobRuns                          map[string]database.JobRun
+	LiabilityLeaves                  map[uint64][]database.LiabilityLeaf
+	AuditLog                         []database.AuditEntry
+	ConfigRevisions                  []database.ConfigRevision
+	Admins                           []database.Admin
+	AdminSessions                    []database.AdminSession
+	AdminApiKeys                     []database.AdminApiKey
 	MeltChange                       []cashu.MeltChange
 	Proofs                           []cashu.Proof
+	ArchivedProofs                   []cashu.Proof
+	KeysetPolicies                   []database.KeysetPolicy
+	KeysetLog                        []database.KeysetLogLeaf
+	KeysetLogRoots                   []database.KeysetLogRoot
+	LiabilityReports                 []database.LiabilityReport
+	ReserveSnapshots                 []database.ReserveSnapshot
+	UntrackedKeysets                 []string
 	Stats                            []database.StatsSnapshot
 	RecoverSigDB                     []cashu.RecoverSigDB
 	NostrAuth                        []database.NostrLoginAuth
@@ -78,6 +93,18 @@ func (m *MockDB) SaveNewSeeds(seeds []cashu.Seed) error {
 	return nil
 }
 
+func (m *MockDB) UpdateSeedsMasterKeyId(tx pgx.Tx, seeds []cashu.Seed) error {
+	for i := range m.Seeds {
+		for _, seed := range seeds {
+			if m.Seeds[i].Id == seed.Id {
+				m.Seeds[i].MasterKeyId = seed.MasterKeyId
+				break
+			}
+		}
+	}
+	return nil
+}
+
 func (m *MockDB) UpdateSeedsActiveStatus(tx pgx.Tx, seeds []cashu.Seed) error {
 	for i := 0; i < len(m.Seeds); i++ {
 		for j := 0; j < len(seeds); j++ {
@@ -0,0 +1,66 @@
+package mockdb
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (m *MockDB) InsertReserveSnapshot(ctx context.Context, snapshot database.ReserveSnapshot) error {
+	if m.ReturnError != 0 {
+		return database.ErrDB
+	}
+	snapshot.Id = int64(len(m.ReserveSnapshots) + 1)
+	m.ReserveSnapshots = append(m.ReserveSnapshots, snapshot)
+	return nil
+}
+
+func (m *MockDB) GetLatestReserveSnapshot(ctx context.Context) (*database.ReserveSnapshot, error) {
+	if m.ReturnError != 0 {
+		return nil, database.ErrDB
+	}
+	if len(m.ReserveSnapshots) == 0 {
+		return nil, nil
+	}
+	latest := slices.MaxFunc(m.ReserveSnapshots, compareReserveSnapshots)
+	return &latest, nil
+}
+
+func (m *MockDB) GetReserveSnapshotsBySince(ctx context.Context, since int64) ([]database.ReserveSnapshot, error) {
+	if m.ReturnError != 0 {
+		return nil, database.ErrDB
+	}
+	rows := make([]database.ReserveSnapshot, 0)
+	for _, snapshot := range m.ReserveSnapshots {
+		if snapshot.CreatedAt >= since {
+			rows = append(rows, snapshot)
+		}
+	}
+	slices.SortFunc(rows, compareReserveSnapshots)
+	return rows, nil
+}
+
+func (m *MockDB) DeleteReserveSnapshotsBefore(ctx context.Context, before int64) (int64, error) {
+	if m.ReturnError != 0 {
+		return 0, database.ErrDB
+	}
+	if len(m.ReserveSnapshots) == 0 {
+		return 0, nil
+	}
+	latest := slices.MaxFunc(m.ReserveSnapshots, compareReserveSnapshots)
+	kept := slices.DeleteFunc(m.ReserveSnapshots, func(snapshot database.ReserveSnapshot) bool {
+		return snapshot.CreatedAt < before && snapshot.Id != latest.Id
+	})
+	deleted := int64(len(m.ReserveSnapshots) - len(kept))
+	m.ReserveSnapshots = kept
+	return deleted, nil
+}
+
+func compareReserveSnapshots(a, b database.ReserveSnapshot) int {
+	if a.CreatedAt != b.CreatedAt {
+		return cmp.Compare(a.CreatedAt, b.CreatedAt)
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
@@ -0,0 +1,113 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (pql Postgresql) GetAdmins(ctx context.Context) ([]database.Admin, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT pubkey, role, added_by, created_at FROM admins ORDER BY created_at")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from admins: %w", err))
+	}
+
+	admins, err := collectRows(rows, pgx.RowToStructByName[database.Admin])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetAdmins collect error: %w", err))
+	}
+	return admins, nil
+}
+
+func (pql Postgresql) GetAdmin(ctx context.Context, pubkey string) (*database.Admin, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT pubkey, role, added_by, created_at FROM admins WHERE pubkey = $1", pubkey)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from admins: %w", err))
+	}
+	defer rows.Close()
+
+	admin, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.Admin])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.Admin]): %w", err))
+	}
+	return &admin, nil
+}
+
+func (pql Postgresql) SaveAdmin(ctx context.Context, admin database.Admin) error {
+	_, err := pql.pool.Exec(ctx, `INSERT INTO admins (pubkey, role, added_by, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pubkey) DO UPDATE SET role = EXCLUDED.role`,
+		admin.Pubkey, admin.Role, admin.AddedBy, admin.CreatedAt)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to admins: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) DeleteAdmin(ctx context.Context, pubkey string) error {
+	_, err := pql.pool.Exec(ctx, "DELETE FROM admins WHERE pubkey = $1", pubkey)
+	if err != nil {
+		return databaseError(fmt.Errorf("deleting from admins: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) SaveAdminSession(ctx context.Context, session database.AdminSession) error {
+	_, err := pql.pool.Exec(ctx, "INSERT INTO admin_sessions (id, pubkey, ip, created_at, expires_at, revoked) VALUES ($1, $2, $3, $4, $5, $6)",
+		session.Id, session.Pubkey, session.Ip, session.CreatedAt, session.ExpiresAt, session.Revoked)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to admin_sessions: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) GetAdminSession(ctx context.Context, id string) (*database.AdminSession, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT id, pubkey, ip, created_at, expires_at, revoked FROM admin_sessions WHERE id = $1", id)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from admin_sessions: %w", err))
+	}
+	defer rows.Close()
+
+	session, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.AdminSession])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.AdminSession]): %w", err))
+	}
+	return &session, nil
+}
+
+func (pql Postgresql) GetActiveAdminSessions(ctx context.Context, now int64) ([]database.AdminSession, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT id, pubkey, ip, created_at, expires_at, revoked FROM admin_sessions WHERE expires_at > $1 AND NOT revoked ORDER BY created_at DESC", now)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from admin_sessions: %w", err))
+	}
+
+	sessions, err := collectRows(rows, pgx.RowToStructByName[database.AdminSession])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetActiveAdminSessions collect error: %w", err))
+	}
+	return sessions, nil
+}
+
+func (pql Postgresql) RevokeAdminSession(ctx context.Context, id string) error {
+	_, err := pql.pool.Exec(ctx, "UPDATE admin_sessions SET revoked = TRUE WHERE id = $1", id)
+	if err != nil {
+		return databaseError(fmt.Errorf("updating admin_sessions: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) DeleteExpiredAdminSessions(ctx context.Context, now int64) error {
+	_, err := pql.pool.Exec(ctx, "DELETE FROM admin_sessions WHERE expires_at <= $1", now)
+	if err != nil {
+		return databaseError(fmt.Errorf("deleting from admin_sessions: %w", err))
+	}
+	return nil
+}
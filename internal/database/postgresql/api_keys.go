@@ -0,0 +1,66 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (pql Postgresql) SaveAdminApiKey(ctx context.Context, key database.AdminApiKey) error {
+	_, err := pql.pool.Exec(ctx, `INSERT INTO admin_api_keys (id, name, key_hash, scopes, created_by, created_at, expires_at, last_used_at, revoked)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		key.Id, key.Name, key.KeyHash, key.Scopes, key.CreatedBy, key.CreatedAt, key.ExpiresAt, key.LastUsedAt, key.Revoked)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to admin_api_keys: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) GetAdminApiKeys(ctx context.Context) ([]database.AdminApiKey, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT id, name, key_hash, scopes, created_by, created_at, expires_at, last_used_at, revoked FROM admin_api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from admin_api_keys: %w", err))
+	}
+
+	keys, err := collectRows(rows, pgx.RowToStructByName[database.AdminApiKey])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetAdminApiKeys collect error: %w", err))
+	}
+	return keys, nil
+}
+
+func (pql Postgresql) GetAdminApiKeyByHash(ctx context.Context, keyHash string) (*database.AdminApiKey, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT id, name, key_hash, scopes, created_by, created_at, expires_at, last_used_at, revoked FROM admin_api_keys WHERE key_hash = $1", keyHash)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from admin_api_keys: %w", err))
+	}
+	defer rows.Close()
+
+	key, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.AdminApiKey])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.AdminApiKey]): %w", err))
+	}
+	return &key, nil
+}
+
+func (pql Postgresql) RevokeAdminApiKey(ctx context.Context, id string) (bool, error) {
+	tag, err := pql.pool.Exec(ctx, "UPDATE admin_api_keys SET revoked = TRUE WHERE id = $1", id)
+	if err != nil {
+		return false, databaseError(fmt.Errorf("updating admin_api_keys: %w", err))
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (pql Postgresql) UpdateAdminApiKeyLastUsed(ctx context.Context, id string, lastUsedAt int64) error {
+	_, err := pql.pool.Exec(ctx, "UPDATE admin_api_keys SET last_used_at = $1 WHERE id = $2", lastUsedAt, id)
+	if err != nil {
+		return databaseError(fmt.Errorf("updating admin_api_keys: %w", err))
+	}
+	return nil
+}
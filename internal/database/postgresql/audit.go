@@ -0,0 +1,66 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (pql Postgresql) SaveAuditEntry(ctx context.Context, entry database.AuditEntry) error {
+	diff := entry.Diff
+	if diff == nil {
+		diff = map[string]database.AuditChange{}
+	}
+	_, err := pql.pool.Exec(ctx, "INSERT INTO audit_log (created_at, actor, action, ip, diff) VALUES ($1, $2, $3, $4, $5)",
+		entry.CreatedAt, entry.Actor, entry.Action, entry.Ip, diff)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to audit_log: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) GetAuditEntries(ctx context.Context, filter database.AuditFilter) ([]database.AuditEntry, error) {
+	conditions := []string{}
+	args := []any{}
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.Since != 0 {
+		addCondition("created_at >= $%d", filter.Since)
+	}
+	if filter.Until != 0 {
+		addCondition("created_at <= $%d", filter.Until)
+	}
+
+	query := "SELECT id, created_at, actor, action, ip, diff FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := pql.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from audit_log: %w", err))
+	}
+
+	entries, err := collectRows(rows, pgx.RowToStructByName[database.AuditEntry])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetAuditEntries collect error: %w", err))
+	}
+	return entries, nil
+}
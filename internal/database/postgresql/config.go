@@ -81,7 +81,10 @@ func (pql Postgresql) GetConfig(tx pgx.Tx) (utils.Config, error) {
             strike_key,
             strike_endpoint,
             icon_url,
-            tos_url
+            tos_url,
+            urls,
+            mint_quote_ttl,
+            melt_quote_ttl
          FROM config WHERE id = 1`).Scan(
 		&config.NAME,
 		&config.DESCRIPTION,
@@ -115,6 +118,9 @@ func (pql Postgresql) GetConfig(tx pgx.Tx) (utils.Config, error) {
 		&config.STRIKE_ENDPOINT,
 		&config.IconUrl,
 		&config.TosUrl,
+		&config.URLS,
+		&config.MINT_QUOTE_TTL,
+		&config.MELT_QUOTE_TTL,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -124,10 +130,19 @@ func (pql Postgresql) GetConfig(tx pgx.Tx) (utils.Config, error) {
 		return config, fmt.Errorf("error checking for config: %w", err)
 	}
 
+	config, err = pql.envelope.DecryptConfig(config)
+	if err != nil {
+		return config, fmt.Errorf("pql.envelope.DecryptConfig(config): %w", err)
+	}
+
 	return config, nil
 }
 
 func (pql Postgresql) SetConfig(tx pgx.Tx, config utils.Config) error {
+	config, err := pql.envelope.EncryptConfig(config)
+	if err != nil {
+		return fmt.Errorf("pql.envelope.EncryptConfig(config): %w", err)
+	}
 	tries := 0
 	stmt := `
         INSERT INTO config (
@@ -163,8 +178,11 @@ func (pql Postgresql) SetConfig(tx pgx.Tx, config utils.Config) error {
 			strike_key,
 			strike_endpoint,
 			icon_url,
-			tos_url
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33)`
+			tos_url,
+			urls,
+			mint_quote_ttl,
+			melt_quote_ttl
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36)`
 
 	for {
 		tries += 1
@@ -202,6 +220,9 @@ func (pql Postgresql) SetConfig(tx pgx.Tx, config utils.Config) error {
 			config.STRIKE_ENDPOINT,
 			config.IconUrl,
 			config.TosUrl,
+			config.URLS,
+			config.MINT_QUOTE_TTL,
+			config.MELT_QUOTE_TTL,
 		)
 
 		switch {
@@ -216,6 +237,10 @@ func (pql Postgresql) SetConfig(tx pgx.Tx, config utils.Config) error {
 }
 
 func (pql Postgresql) UpdateConfig(tx pgx.Tx, config utils.Config) error {
+	config, err := pql.envelope.EncryptConfig(config)
+	if err != nil {
+		return fmt.Errorf("pql.envelope.EncryptConfig(config): %w", err)
+	}
 	tries := 0
 	for {
 		tries += 1
@@ -252,7 +277,10 @@ func (pql Postgresql) UpdateConfig(tx pgx.Tx, config utils.Config) error {
 			strike_key = $29,
 			strike_endpoint = $30,
 			icon_url = $31,
-			tos_url = $32
+			tos_url = $32,
+			urls = $33,
+			mint_quote_ttl = $34,
+			melt_quote_ttl = $35
         WHERE id = 1`
 		_, err := tx.Exec(context.Background(), stmt,
 			config.NAME,
@@ -287,6 +315,9 @@ func (pql Postgresql) UpdateConfig(tx pgx.Tx, config utils.Config) error {
 			config.STRIKE_ENDPOINT,
 			config.IconUrl,
 			config.TosUrl,
+			config.URLS,
+			config.MINT_QUOTE_TTL,
+			config.MELT_QUOTE_TTL,
 		)
 
 		switch {
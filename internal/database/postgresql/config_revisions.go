@@ -0,0 +1,92 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+const configRevisionColumns = "id, created_at, actor, config, diff, rolled_back_from"
+
+func (pql Postgresql) SaveConfigRevision(tx pgx.Tx, revision database.ConfigRevision) (int64, error) {
+	diff := revision.Diff
+	if diff == nil {
+		diff = map[string]database.AuditChange{}
+	}
+	config, err := pql.envelope.EncryptConfig(revision.Config)
+	if err != nil {
+		return 0, fmt.Errorf("pql.envelope.EncryptConfig(revision.Config): %w", err)
+	}
+	var id int64
+	err = tx.QueryRow(context.Background(), `INSERT INTO config_revisions (created_at, actor, config, diff, rolled_back_from)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		revision.CreatedAt, revision.Actor, config, diff, revision.RolledBackFrom).Scan(&id)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("inserting to config_revisions: %w", err))
+	}
+	return id, nil
+}
+
+func (pql Postgresql) GetLatestConfigRevision(tx pgx.Tx) (*database.ConfigRevision, error) {
+	rows, err := tx.Query(context.Background(), "SELECT "+configRevisionColumns+" FROM config_revisions ORDER BY id DESC LIMIT 1")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from config_revisions: %w", err))
+	}
+	defer rows.Close()
+
+	revision, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.ConfigRevision])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.ConfigRevision]): %w", err))
+	}
+	revision.Config, err = pql.envelope.DecryptConfig(revision.Config)
+	if err != nil {
+		return nil, fmt.Errorf("pql.envelope.DecryptConfig(revision.Config): %w", err)
+	}
+	return &revision, nil
+}
+
+func (pql Postgresql) GetConfigRevision(ctx context.Context, id int64) (*database.ConfigRevision, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT "+configRevisionColumns+" FROM config_revisions WHERE id = $1", id)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from config_revisions: %w", err))
+	}
+	defer rows.Close()
+
+	revision, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.ConfigRevision])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.ConfigRevision]): %w", err))
+	}
+	revision.Config, err = pql.envelope.DecryptConfig(revision.Config)
+	if err != nil {
+		return nil, fmt.Errorf("pql.envelope.DecryptConfig(revision.Config): %w", err)
+	}
+	return &revision, nil
+}
+
+func (pql Postgresql) GetConfigRevisions(ctx context.Context, limit int) ([]database.ConfigRevision, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT "+configRevisionColumns+" FROM config_revisions ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from config_revisions: %w", err))
+	}
+
+	revisions, err := collectRows(rows, pgx.RowToStructByName[database.ConfigRevision])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetConfigRevisions collect error: %w", err))
+	}
+	for i := range revisions {
+		revisions[i].Config, err = pql.envelope.DecryptConfig(revisions[i].Config)
+		if err != nil {
+			return nil, fmt.Errorf("pql.envelope.DecryptConfig(revision %d): %w", revisions[i].Id, err)
+		}
+	}
+	return revisions, nil
+}
@@ -0,0 +1,262 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+// WithEnvelope returns a copy of pql that encrypts the config credentials
+// with envelope.
+func (pql Postgresql) WithEnvelope(envelope *secrets.Envelope) Postgresql {
+	pql.envelope = envelope
+	return pql
+}
+
+func (pql Postgresql) Envelope() *secrets.Envelope {
+	return pql.envelope
+}
+
+// SetupEncryption unwraps the data keys with kms and encrypts the
+// credentials that are still in plaintext or use an older data key. Without a
+// kms the credentials stay in plaintext.
+func (pql Postgresql) SetupEncryption(ctx context.Context, kms secrets.KMS) (Postgresql, error) {
+	envelope, err := secrets.OpenEnvelope(ctx, pql, kms)
+	if err != nil {
+		return pql, fmt.Errorf("secrets.OpenEnvelope(ctx, pql, kms). %w", err)
+	}
+	if envelope == nil {
+		slog.Warn("The backend credentials in the config are stored in plaintext. Set " + secrets.PassphraseEnv + " or " + secrets.KeyFileEnv + " to encrypt them")
+		return pql, nil
+	}
+
+	pql = pql.WithEnvelope(envelope)
+	reencrypted, err := pql.ReencryptSecrets(ctx)
+	if err != nil {
+		return pql, fmt.Errorf("pql.ReencryptSecrets(ctx). %w", err)
+	}
+	if reencrypted > 0 {
+		slog.Info("Encrypted the stored config credentials", slog.Int("rows", reencrypted))
+	}
+	return pql, nil
+}
+
+func (pql Postgresql) GetDataKeys(ctx context.Context) ([]secrets.DataKey, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT id, kms, wrapped_key, created_at, active FROM data_keys ORDER BY id")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from data_keys: %w", err))
+	}
+	keys, err := collectRows(rows, pgx.RowToStructByName[secrets.DataKey])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetDataKeys collect error: %w", err))
+	}
+	return keys, nil
+}
+
+func (pql Postgresql) SaveDataKey(ctx context.Context, key secrets.DataKey) (id int64, err error) {
+	tx, err := pql.pool.Begin(ctx)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("pql.pool.Begin(ctx): %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, "UPDATE data_keys SET active = FALSE WHERE active")
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("updating data_keys: %w", err))
+	}
+	err = tx.QueryRow(ctx, "INSERT INTO data_keys (kms, wrapped_key, created_at, active) VALUES ($1, $2, $3, TRUE) RETURNING id",
+		key.Kms, key.WrappedKey, key.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("inserting to data_keys: %w", err))
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("tx.Commit(ctx): %w", err))
+	}
+	return id, nil
+}
+
+func (pql Postgresql) UpdateWrappedDataKeys(ctx context.Context, keys []secrets.DataKey) (err error) {
+	tx, err := pql.pool.Begin(ctx)
+	if err != nil {
+		return databaseError(fmt.Errorf("pql.pool.Begin(ctx): %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	for _, key := range keys {
+		_, err = tx.Exec(ctx, "UPDATE data_keys SET kms = $1, wrapped_key = $2 WHERE id = $3", key.Kms, key.WrappedKey, key.Id)
+		if err != nil {
+			return databaseError(fmt.Errorf("updating data_keys: %w", err))
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return databaseError(fmt.Errorf("tx.Commit(ctx): %w", err))
+	}
+	return nil
+}
+
+// ReencryptSecrets encrypts every stored credential with the active data key.
+// It returns how many rows changed.
+func (pql Postgresql) ReencryptSecrets(ctx context.Context) (changed int, err error) {
+	if pql.envelope == nil {
+		return 0, secrets.ErrNoEnvelope
+	}
+	tx, err := pql.pool.Begin(ctx)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("pql.pool.Begin(ctx): %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var stored utils.Config
+	err = tx.QueryRow(ctx, "SELECT lnd_macaroon, cln_client_key, cln_macaroon, mint_lnbits_key, strike_key FROM config WHERE id = 1 FOR UPDATE").Scan(
+		&stored.LND_MACAROON,
+		&stored.CLN_CLIENT_KEY,
+		&stored.CLN_MACAROON,
+		&stored.MINT_LNBITS_KEY,
+		&stored.STRIKE_KEY,
+	)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return 0, databaseError(fmt.Errorf("selecting from config: %w", err))
+	case !pql.configIsCurrent(stored):
+		config, err := pql.GetConfig(tx)
+		if err != nil {
+			return 0, fmt.Errorf("pql.GetConfig(tx). %w", err)
+		}
+		err = pql.UpdateConfig(tx, config)
+		if err != nil {
+			return 0, fmt.Errorf("pql.UpdateConfig(tx, config). %w", err)
+		}
+		changed++
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id, config FROM config_revisions ORDER BY id FOR UPDATE")
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("selecting from config_revisions: %w", err))
+	}
+	type storedRevision struct {
+		Config utils.Config `db:"config"`
+		Id     int64        `db:"id"`
+	}
+	revisions, err := collectRows(rows, pgx.RowToStructByName[storedRevision])
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("ReencryptSecrets collect error: %w", err))
+	}
+	for _, revision := range revisions {
+		if pql.configIsCurrent(revision.Config) {
+			continue
+		}
+		config, err := pql.envelope.DecryptConfig(revision.Config)
+		if err != nil {
+			return 0, fmt.Errorf("pql.envelope.DecryptConfig(revision %d). %w", revision.Id, err)
+		}
+		config, err = pql.envelope.EncryptConfig(config)
+		if err != nil {
+			return 0, fmt.Errorf("pql.envelope.EncryptConfig(revision %d). %w", revision.Id, err)
+		}
+		_, err = tx.Exec(ctx, "UPDATE config_revisions SET config = $1 WHERE id = $2", config, revision.Id)
+		if err != nil {
+			return 0, databaseError(fmt.Errorf("updating config_revisions: %w", err))
+		}
+		changed++
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("tx.Commit(ctx): %w", err))
+	}
+	return changed, nil
+}
+
+// PruneDataKeys removes the inactive data keys no stored credential uses.
+// A running mint keeps encrypting with the data key it started with, so this
+// should only run once every instance restarted after a rotation.
+func (pql Postgresql) PruneDataKeys(ctx context.Context) (removed []int64, err error) {
+	tx, err := pql.pool.Begin(ctx)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("pql.pool.Begin(ctx): %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	rows, err := tx.Query(ctx, `SELECT lnd_macaroon, cln_client_key, cln_macaroon, mint_lnbits_key, strike_key FROM config
+		UNION ALL
+		SELECT config->>'LND_MACAROON', config->>'CLN_CLIENT_KEY', config->>'CLN_MACAROON', config->>'MINT_LNBITS_KEY', config->>'STRIKE_KEY' FROM config_revisions`)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting the stored credentials: %w", err))
+	}
+	used := []int64{}
+	for rows.Next() {
+		values := make([]*string, 5)
+		scan := make([]any, len(values))
+		for i := range values {
+			scan[i] = &values[i]
+		}
+		err = rows.Scan(scan...)
+		if err != nil {
+			rows.Close()
+			return nil, databaseError(fmt.Errorf("rows.Scan(credentials): %w", err))
+		}
+		for _, value := range values {
+			if value == nil {
+				continue
+			}
+			if id, ok := secrets.KeyIdOf(*value); ok {
+				used = append(used, id)
+			}
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, databaseError(fmt.Errorf("selecting the stored credentials: %w", rows.Err()))
+	}
+
+	deleted, err := tx.Query(ctx, "DELETE FROM data_keys WHERE NOT active AND NOT (id = ANY($1)) RETURNING id", used)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("deleting from data_keys: %w", err))
+	}
+	removed, err = collectRows(deleted, pgx.RowTo[int64])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("PruneDataKeys collect error: %w", err))
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("tx.Commit(ctx): %w", err))
+	}
+	for _, id := range removed {
+		pql.envelope.Forget(id)
+	}
+	return removed, nil
+}
+
+func (pql Postgresql) configIsCurrent(config utils.Config) bool {
+	for _, value := range secrets.ConfigSecrets(&config) {
+		if !pql.envelope.IsCurrent(*value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+// RunExclusive takes a session advisory lock on a dedicated connection and
+// only calls fn if the lock was free. The lock is released when fn returns,
+// or by postgres if the connection dies, so a crashed replica never keeps it.
+func (pql Postgresql) RunExclusive(ctx context.Context, lockKey int64, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := pql.pool.Acquire(ctx)
+	if err != nil {
+		return false, databaseError(fmt.Errorf("pql.pool.Acquire(ctx): %w", err))
+	}
+	defer conn.Release()
+
+	var acquired bool
+	err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&acquired)
+	if err != nil {
+		return false, databaseError(fmt.Errorf("pg_try_advisory_lock(%d): %w", lockKey, err))
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	defer func() {
+		// the job context might be cancelled already, the lock still needs to go
+		_, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+		if unlockErr != nil {
+			slog.Error("pg_advisory_unlock failed, dropping connection", slog.Int64("lock_key", lockKey), slog.Any("error", unlockErr))
+			// closing the connection releases any lock it still holds
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	return true, fn(ctx)
+}
+
+func (pql Postgresql) GetJobRun(ctx context.Context, name string) (*database.JobRun, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT name, status, instance, started_at, finished_at, duration_ms, run_count, last_error FROM scheduled_jobs WHERE name = $1", name)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from scheduled_jobs: %w", err))
+	}
+	defer rows.Close()
+
+	run, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.JobRun])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.JobRun]): %w", err))
+	}
+	return &run, nil
+}
+
+func (pql Postgresql) GetJobRuns(ctx context.Context) ([]database.JobRun, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT name, status, instance, started_at, finished_at, duration_ms, run_count, last_error FROM scheduled_jobs ORDER BY name")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from scheduled_jobs: %w", err))
+	}
+
+	runs, err := collectRows(rows, pgx.RowToStructByName[database.JobRun])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetJobRuns collect error: %w", err))
+	}
+	return runs, nil
+}
+
+func (pql Postgresql) SaveJobRun(ctx context.Context, run database.JobRun) error {
+	_, err := pql.pool.Exec(ctx, `INSERT INTO scheduled_jobs (name, status, instance, started_at, finished_at, duration_ms, run_count, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (name) DO UPDATE SET status = EXCLUDED.status, instance = EXCLUDED.instance, started_at = EXCLUDED.started_at,
+		finished_at = EXCLUDED.finished_at, duration_ms = EXCLUDED.duration_ms, run_count = EXCLUDED.run_count, last_error = EXCLUDED.last_error`,
+		run.Name, run.Status, run.Instance, run.StartedAt, run.FinishedAt, run.DurationMs, run.RunCount, run.LastError)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to scheduled_jobs: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (pql Postgresql) GetKeysetLogLeaves(ctx context.Context) ([]database.KeysetLogLeaf, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT leaf_index, keyset_id, leaf, leaf_hash, created_at FROM keyset_log ORDER BY leaf_index")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from keyset_log: %w", err))
+	}
+
+	leaves, err := collectRows(rows, pgx.RowToStructByName[database.KeysetLogLeaf])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetKeysetLogLeaves collect error: %w", err))
+	}
+	return leaves, nil
+}
+
+func (pql Postgresql) AppendKeysetLog(ctx context.Context, leaves []database.KeysetLogLeaf, root database.KeysetLogRoot) (err error) {
+	tx, err := pql.pool.Begin(ctx)
+	if err != nil {
+		return databaseError(fmt.Errorf("pql.pool.Begin(ctx): %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	for _, leaf := range leaves {
+		_, err = tx.Exec(ctx, "INSERT INTO keyset_log (leaf_index, keyset_id, leaf, leaf_hash, created_at) VALUES ($1, $2, $3, $4, $5)",
+			leaf.LeafIndex, leaf.KeysetId, leaf.Leaf, leaf.LeafHash, leaf.CreatedAt)
+		if err != nil {
+			return databaseError(fmt.Errorf("inserting to keyset_log: %w", err))
+		}
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO keyset_log_roots (tree_size, root_hash, signature, created_at) VALUES ($1, $2, $3, $4)",
+		root.TreeSize, root.RootHash, root.Signature, root.CreatedAt)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to keyset_log_roots: %w", err))
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return databaseError(fmt.Errorf("tx.Commit(ctx): %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) GetKeysetLogRoot(ctx context.Context, treeSize uint64) (*database.KeysetLogRoot, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT tree_size, root_hash, signature, created_at FROM keyset_log_roots WHERE tree_size = $1", treeSize)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from keyset_log_roots: %w", err))
+	}
+	return collectKeysetLogRoot(rows)
+}
+
+func (pql Postgresql) GetLatestKeysetLogRoot(ctx context.Context) (*database.KeysetLogRoot, error) {
+	rows, err := pql.pool.Query(ctx, "SELECT tree_size, root_hash, signature, created_at FROM keyset_log_roots ORDER BY tree_size DESC LIMIT 1")
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from keyset_log_roots: %w", err))
+	}
+	return collectKeysetLogRoot(rows)
+}
+
+func collectKeysetLogRoot(rows pgx.Rows) (*database.KeysetLogRoot, error) {
+	defer rows.Close()
+	root, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[database.KeysetLogRoot])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("pgx.CollectOneRow(rows, pgx.RowToStructByName[database.KeysetLogRoot]): %w", err))
+	}
+	return &root, nil
+}
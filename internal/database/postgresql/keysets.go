@@ -0,0 +1,106 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (pql Postgresql) GetKeysetPolicies(ctx context.Context) ([]database.KeysetPolicy, error) {
+	rows, err := pql.pool.Query(ctx, `SELECT unit, rotate_every_hours, rotate_after_amount, input_fee_ppk, final_expiry_hours, rotated_at, updated_at
+		FROM keyset_policies ORDER BY unit`)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from keyset_policies: %w", err))
+	}
+
+	policies, err := collectRows(rows, pgx.RowToStructByName[database.KeysetPolicy])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetKeysetPolicies collect error: %w", err))
+	}
+	return policies, nil
+}
+
+func (pql Postgresql) SaveKeysetPolicy(ctx context.Context, policy database.KeysetPolicy) error {
+	_, err := pql.pool.Exec(ctx, `INSERT INTO keyset_policies (unit, rotate_every_hours, rotate_after_amount, input_fee_ppk, final_expiry_hours, rotated_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (unit) DO UPDATE SET
+			rotate_every_hours = EXCLUDED.rotate_every_hours,
+			rotate_after_amount = EXCLUDED.rotate_after_amount,
+			input_fee_ppk = EXCLUDED.input_fee_ppk,
+			final_expiry_hours = EXCLUDED.final_expiry_hours,
+			rotated_at = EXCLUDED.rotated_at,
+			updated_at = EXCLUDED.updated_at`,
+		policy.Unit, policy.RotateEveryHours, policy.RotateAfterAmount, policy.InputFeePpk, policy.FinalExpiryHours, policy.RotatedAt, policy.UpdatedAt)
+	if err != nil {
+		return databaseError(fmt.Errorf("upserting keyset_policies: %w", err))
+	}
+	return nil
+}
+
+// GetKeysetBalances adds up the signatures and the spent proofs of every
+// keyset that has any, archived proofs included.
+func (pql Postgresql) GetKeysetBalances(ctx context.Context) ([]database.KeysetBalance, error) {
+	rows, err := pql.pool.Query(ctx, `SELECT id AS keyset_id,
+			SUM(issued)::BIGINT AS issued,
+			SUM(redeemed + archived)::BIGINT AS redeemed,
+			SUM(archived)::BIGINT AS archived
+		FROM (
+			SELECT id, SUM(amount) AS issued, 0 AS redeemed, 0 AS archived FROM recovery_signature GROUP BY id
+			UNION ALL
+			SELECT id, 0, SUM(amount), 0 FROM proofs WHERE state = 'SPENT' GROUP BY id
+			UNION ALL
+			SELECT id, 0, 0, SUM(amount) FROM proofs_archive GROUP BY id
+		) AS balances
+		GROUP BY id
+		ORDER BY id`)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetKeysetBalances query error: %w", err))
+	}
+
+	balances, err := collectRows(rows, pgx.RowToStructByName[database.KeysetBalance])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetKeysetBalances collect error: %w", err))
+	}
+	return balances, nil
+}
+
+func (pql Postgresql) GetUntrackedKeysets(ctx context.Context) ([]string, error) {
+	rows, err := pql.pool.Query(ctx, `SELECT id FROM untracked_keysets ORDER BY id`)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetUntrackedKeysets query error: %w", err))
+	}
+	ids, err := collectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetUntrackedKeysets collect error: %w", err))
+	}
+	return ids, nil
+}
+
+func (pql Postgresql) ArchiveKeysetProofs(ctx context.Context, keysetId string, archivedAt int64) (int64, error) {
+	tag, err := pql.pool.Exec(ctx, `WITH moved AS (
+			DELETE FROM proofs WHERE id = $1 AND state = 'SPENT'
+			RETURNING amount, id, secret, c, y, witness, seen_at, state, quote
+		)
+		INSERT INTO proofs_archive (amount, id, secret, c, y, witness, seen_at, state, quote, archived_at)
+		SELECT amount, id, secret, c, y, witness, seen_at, state, quote, $2 FROM moved`, keysetId, archivedAt)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("archiving proofs of keyset %s: %w", keysetId, err))
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (pql Postgresql) GetArchivedProofsFromSecretCurve(tx pgx.Tx, Ys []cashu.WrappedPublicKey) (cashu.Proofs, error) {
+	rows, err := tx.Query(context.Background(), `SELECT amount, id, secret, c, y, witness, seen_at, state, quote FROM proofs_archive WHERE y = ANY($1)`, Ys)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("query error could not get archived proofs: %w", err))
+	}
+
+	proofs, err := collectRows(rows, pgx.RowToStructByName[cashu.Proof])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetArchivedProofsFromSecretCurve collect error: %w", err))
+	}
+	return proofs, nil
+}
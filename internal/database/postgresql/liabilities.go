@@ -0,0 +1,123 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+// keysetLiabilities are the leaves of the keyset $1. The trees order them by
+// point, the other columns only break ties.
+const keysetLiabilities = `SELECT "B_" AS point, "C_" AS signature, id AS keyset_id, amount::BIGINT AS amount, false AS redeemed
+		FROM recovery_signature WHERE id = $1
+	UNION ALL
+	SELECT y, NULL::BYTEA, id, amount::BIGINT, true FROM proofs WHERE id = $1 AND state = 'SPENT'
+	UNION ALL
+	SELECT y, NULL::BYTEA, id, amount::BIGINT, true FROM proofs_archive WHERE id = $1`
+
+const liabilityLeafOrder = `point, amount, signature NULLS FIRST`
+
+func (pql Postgresql) GetLiabilitiesTx(ctx context.Context) (pgx.Tx, error) {
+	return pql.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadWrite, DeferrableMode: pgx.NotDeferrable, BeginQuery: "", CommitQuery: ""})
+}
+
+func (pql Postgresql) ForEachKeysetLiability(ctx context.Context, tx pgx.Tx, keysetId string, fn func(leaf database.LiabilityLeaf) error) error {
+	rows, err := tx.Query(ctx, `SELECT point, signature, keyset_id, amount, 0::BIGINT AS leaf_index, redeemed FROM (`+keysetLiabilities+`) leaves
+		ORDER BY redeemed, `+liabilityLeafOrder, keysetId)
+	if err != nil {
+		return databaseError(fmt.Errorf("selecting liabilities of keyset %s: %w", keysetId, err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		leaf, err := pgx.RowToStructByName[database.LiabilityLeaf](rows)
+		if err != nil {
+			return databaseError(fmt.Errorf("ForEachKeysetLiability scan error: %w", err))
+		}
+		err = fn(leaf)
+		if err != nil {
+			return err
+		}
+	}
+	if rows.Err() != nil {
+		return databaseError(fmt.Errorf("ForEachKeysetLiability rows error: %w", rows.Err()))
+	}
+	return nil
+}
+
+func (pql Postgresql) SaveLiabilityReport(ctx context.Context, tx pgx.Tx, report database.LiabilityReport, keepLeaves int) (uint64, error) {
+	var id uint64
+	err := tx.QueryRow(ctx, "INSERT INTO liability_reports (signature, created_at) VALUES ($1, $2) RETURNING id", report.Signature, report.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("inserting to liability_reports: %w", err))
+	}
+
+	keysets := make([][]any, 0, len(report.Keysets))
+	for _, keyset := range report.Keysets {
+		keysets = append(keysets, []any{id, keyset.KeysetId, keyset.Unit, keyset.IssuedRoot, keyset.IssuedTotal, keyset.IssuedCount, keyset.RedeemedRoot, keyset.RedeemedTotal, keyset.RedeemedCount})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"liability_report_keysets"},
+		[]string{"report_id", "keyset_id", "unit", "issued_root", "issued_total", "issued_count", "redeemed_root", "redeemed_total", "redeemed_count"},
+		pgx.CopyFromRows(keysets))
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("inserting to liability_report_keysets: %w", err))
+	}
+
+	// the leaves are copied inside the database instead of going through the mint
+	for _, keyset := range report.Keysets {
+		_, err = tx.Exec(ctx, `INSERT INTO liability_report_leaves (report_id, keyset_id, redeemed, leaf_index, point, signature, amount)
+			SELECT $2, keyset_id, redeemed, ROW_NUMBER() OVER (PARTITION BY redeemed ORDER BY `+liabilityLeafOrder+`) - 1, point, signature, amount
+			FROM (`+keysetLiabilities+`) leaves`, keyset.KeysetId, id)
+		if err != nil {
+			return 0, databaseError(fmt.Errorf("inserting to liability_report_leaves of keyset %s: %w", keyset.KeysetId, err))
+		}
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM liability_report_leaves WHERE report_id NOT IN (
+			SELECT id FROM liability_reports ORDER BY id DESC LIMIT $1
+		)`, keepLeaves)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("deleting old liability_report_leaves: %w", err))
+	}
+	return id, nil
+}
+
+func (pql Postgresql) GetLiabilityReport(ctx context.Context, id uint64) (*database.LiabilityReport, error) {
+	row := pql.pool.QueryRow(ctx, `SELECT id, signature, created_at FROM liability_reports
+		WHERE id = $1 OR $1 = 0 ORDER BY id DESC LIMIT 1`, id)
+	var report database.LiabilityReport
+	err := row.Scan(&report.Id, &report.Signature, &report.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("selecting from liability_reports: %w", err))
+	}
+
+	rows, err := pql.pool.Query(ctx, `SELECT keyset_id, unit, issued_root, issued_total, issued_count, redeemed_root, redeemed_total, redeemed_count
+		FROM liability_report_keysets WHERE report_id = $1 ORDER BY keyset_id`, report.Id)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from liability_report_keysets: %w", err))
+	}
+	report.Keysets, err = collectRows(rows, pgx.RowToStructByName[database.KeysetLiabilities])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetLiabilityReport collect error: %w", err))
+	}
+	return &report, nil
+}
+
+func (pql Postgresql) GetLiabilityReportLeaves(ctx context.Context, reportId uint64, keysetId string) ([]database.LiabilityLeaf, error) {
+	rows, err := pql.pool.Query(ctx, `SELECT point, signature, keyset_id, amount, leaf_index, redeemed FROM liability_report_leaves
+		WHERE report_id = $1 AND keyset_id = $2 ORDER BY redeemed, leaf_index`, reportId, keysetId)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from liability_report_leaves: %w", err))
+	}
+
+	leaves, err := collectRows(rows, pgx.RowToStructByName[database.LiabilityLeaf])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetLiabilityReportLeaves collect error: %w", err))
+	}
+	return leaves, nil
+}
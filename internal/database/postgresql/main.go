@@ -2,6 +2,7 @@ package postgresql
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -13,6 +14,8 @@ import (
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/lescuer97/nutmix/api/cashu"
 	"github.com/lescuer97/nutmix/internal/database/goose"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	"github.com/lescuer97/nutmix/internal/tracing"
 )
 
 var ErrDB = errors.New("ERROR DATABASE")
@@ -21,13 +24,17 @@ var DATABASE_URL_ENV = "DATABASE_URL"
 
 type Postgresql struct {
 	pool *pgxpool.Pool
+	// envelope encrypts the backend credentials of the config. It is nil
+	// when no kms is set up.
+	envelope *secrets.Envelope
 }
 
 func databaseError(err error) error {
 	return errors.Join(ErrDB, err)
 }
 
-func DatabaseSetup(ctx context.Context, migrationDir string) (Postgresql, error) {
+// DatabaseConnect opens the pool without running the migrations.
+func DatabaseConnect(ctx context.Context) (Postgresql, error) {
 	var postgresql Postgresql
 
 	dbUrl := os.Getenv(DATABASE_URL_ENV)
@@ -35,12 +42,28 @@ func DatabaseSetup(ctx context.Context, migrationDir string) (Postgresql, error)
 		return postgresql, fmt.Errorf("%v environment variable empty", DATABASE_URL_ENV)
 	}
 
-	pool, err := pgxpool.New(ctx, dbUrl)
+	poolConfig, err := pgxpool.ParseConfig(dbUrl)
+	if err != nil {
+		return postgresql, fmt.Errorf("pgxpool.ParseConfig: %w", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.PgxTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return postgresql, fmt.Errorf("pgxpool.NewWithConfig: %w", err)
+	}
+	postgresql.pool = pool
+
+	return postgresql, nil
+}
+
+func DatabaseSetup(ctx context.Context, migrationDir string) (Postgresql, error) {
+	postgresql, err := DatabaseConnect(ctx)
 	if err != nil {
-		return postgresql, fmt.Errorf("pgxpool.New: %w", err)
+		return postgresql, err
 	}
 
-	db := stdlib.OpenDBFromPool(pool)
+	db := postgresql.SqlDB()
 
 	err = goose.RunMigration(db, goose.POSTGRES)
 	if err := db.Close(); err != nil {
@@ -48,13 +71,24 @@ func DatabaseSetup(ctx context.Context, migrationDir string) (Postgresql, error)
 	}
 
 	if err != nil {
-		return postgresql, databaseError(fmt.Errorf("error connecting to database: %w", err))
+		postgresql.Close()
+		return Postgresql{}, databaseError(fmt.Errorf("error connecting to database: %w", err))
 	}
-	postgresql.pool = pool
 
 	return postgresql, nil
 }
 
+// SqlDB wraps the pool for libraries that need a database/sql handle, like
+// goose. Closing it leaves the pool open.
+func (pql Postgresql) SqlDB() *sql.DB {
+	return stdlib.OpenDBFromPool(pql.pool)
+}
+
+// PoolStats reports the state of the connection pool for the metrics.
+func (pql Postgresql) PoolStats() *pgxpool.Stat {
+	return pql.pool.Stat()
+}
+
 func (pql Postgresql) GetTx(ctx context.Context) (pgx.Tx, error) {
 	return pql.pool.Begin(ctx)
 }
@@ -69,7 +103,7 @@ func (pql Postgresql) Rollback(ctx context.Context, tx pgx.Tx) error {
 func (pql Postgresql) GetAllSeeds() ([]cashu.Seed, error) {
 	var seeds []cashu.Seed
 
-	rows, err := pql.pool.Query(context.Background(), `SELECT  created_at, active, version, unit, id,  "input_fee_ppk", final_expiry, derivation_path, amounts, legacy, issuer_version FROM seeds ORDER BY version DESC`)
+	rows, err := pql.pool.Query(context.Background(), `SELECT  created_at, active, version, unit, id,  "input_fee_ppk", final_expiry, derivation_path, amounts, legacy, issuer_version, master_key_id FROM seeds ORDER BY version DESC`)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return seeds, fmt.Errorf("no rows found: %w", err)
@@ -89,7 +123,7 @@ func (pql Postgresql) GetAllSeeds() ([]cashu.Seed, error) {
 }
 
 func (pql Postgresql) GetSeedsByUnit(tx pgx.Tx, unit cashu.Unit) ([]cashu.Seed, error) {
-	rows, err := tx.Query(context.Background(), "SELECT  created_at, active, version, unit, id, input_fee_ppk, final_expiry, derivation_path, amounts, legacy, issuer_version FROM seeds WHERE unit = $1", unit.String())
+	rows, err := tx.Query(context.Background(), "SELECT  created_at, active, version, unit, id, input_fee_ppk, final_expiry, derivation_path, amounts, legacy, issuer_version, master_key_id FROM seeds WHERE unit = $1", unit.String())
 	if err != nil {
 		return []cashu.Seed{}, fmt.Errorf("error checking for active seeds: %w", err)
 	}
@@ -112,7 +146,7 @@ func (pql Postgresql) SaveNewSeed(tx pgx.Tx, seed cashu.Seed) error {
 
 	for {
 		tries += 1
-		_, err := tx.Exec(context.Background(), "INSERT INTO seeds ( active, created_at, unit, id, version, input_fee_ppk, final_expiry, derivation_path, amounts, legacy, issuer_version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)", seed.Active, seed.CreatedAt, seed.Unit, seed.Id, seed.Version, seed.InputFeePpk, seed.FinalExpiry, seed.DerivationPath, seed.Amounts, seed.Legacy, seed.IssuerVersion)
+		_, err := tx.Exec(context.Background(), "INSERT INTO seeds ( active, created_at, unit, id, version, input_fee_ppk, final_expiry, derivation_path, amounts, legacy, issuer_version, master_key_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)", seed.Active, seed.CreatedAt, seed.Unit, seed.Id, seed.Version, seed.InputFeePpk, seed.FinalExpiry, seed.DerivationPath, seed.Amounts, seed.Legacy, seed.IssuerVersion, seed.MasterKeyId)
 
 		switch {
 		case err != nil && tries < 3:
@@ -129,11 +163,11 @@ func (pql Postgresql) SaveNewSeeds(seeds []cashu.Seed) error {
 	tries := 0
 
 	entries := [][]any{}
-	columns := []string{"active", "created_at", "unit", "id", "version", "input_fee_ppk", "final_expiry", "derivation_path", "amounts", "legacy", "issuer_version"}
+	columns := []string{"active", "created_at", "unit", "id", "version", "input_fee_ppk", "final_expiry", "derivation_path", "amounts", "legacy", "issuer_version", "master_key_id"}
 	tableName := "seeds"
 
 	for _, seed := range seeds {
-		entries = append(entries, []any{seed.Active, seed.CreatedAt, seed.Unit, seed.Id, seed.Version, seed.InputFeePpk, seed.FinalExpiry, seed.DerivationPath, seed.Amounts, seed.Legacy, seed.IssuerVersion})
+		entries = append(entries, []any{seed.Active, seed.CreatedAt, seed.Unit, seed.Id, seed.Version, seed.InputFeePpk, seed.FinalExpiry, seed.DerivationPath, seed.Amounts, seed.Legacy, seed.IssuerVersion, seed.MasterKeyId})
 	}
 
 	for {
@@ -174,6 +208,18 @@ func (pql Postgresql) UpdateSeedsActiveStatus(tx pgx.Tx, seeds []cashu.Seed) err
 	return nil
 }
 
+func (pql Postgresql) UpdateSeedsMasterKeyId(tx pgx.Tx, seeds []cashu.Seed) error {
+	var batch pgx.Batch
+	for _, seed := range seeds {
+		batch.Queue("UPDATE seeds SET master_key_id = $1 WHERE id = $2", seed.MasterKeyId, seed.Id)
+	}
+	err := tx.SendBatch(context.Background(), &batch).Close()
+	if err != nil {
+		return databaseError(fmt.Errorf("updating the master key of seeds: %w", err))
+	}
+	return nil
+}
+
 func (pql Postgresql) SaveMintRequest(tx pgx.Tx, request cashu.MintRequestDB) error {
 	ctx := context.Background()
 
@@ -452,6 +498,18 @@ func privateKeysToDleq(s_key []byte, e_key []byte, sig *cashu.RecoverSigDB) {
 	sig.Dleq = dleqTmp
 }
 
+// dleqToPrivateKeys returns the e and s of the DLEQ proof, or nil for both when
+// the signer does not prove its signatures, like the PKCS#11 one. They are
+// stored as NULL.
+func dleqToPrivateKeys(dleq *cashu.BlindSignatureDLEQ) ([]byte, []byte) {
+	if dleq == nil || dleq.E == nil || dleq.S == nil {
+		return nil, nil
+	}
+	e := dleq.E.Key.Bytes()
+	s := dleq.S.Key.Bytes()
+	return e[:], s[:]
+}
+
 func (pql Postgresql) GetRestoreSigsFromBlindedMessages(tx pgx.Tx, B_ []cashu.WrappedPublicKey) ([]cashu.RecoverSigDB, error) {
 	signaturesList := make([]cashu.RecoverSigDB, 0)
 
@@ -493,9 +551,8 @@ func (pql Postgresql) SaveRestoreSigs(tx pgx.Tx, recover_sigs []cashu.RecoverSig
 	tries := 0
 
 	for _, sig := range recover_sigs {
-		dleq_e_bytes := sig.Dleq.E.Key.Bytes()
-		dleq_s_bytes := sig.Dleq.S.Key.Bytes()
-		entries = append(entries, []any{sig.Id, sig.Amount, sig.B_, sig.C_, sig.CreatedAt, dleq_e_bytes[:], dleq_s_bytes[:]})
+		dleq_e_bytes, dleq_s_bytes := dleqToPrivateKeys(sig.Dleq)
+		entries = append(entries, []any{sig.Id, sig.Amount, sig.B_, sig.C_, sig.CreatedAt, dleq_e_bytes, dleq_s_bytes})
 	}
 
 	for {
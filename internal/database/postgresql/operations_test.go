@@ -10,6 +10,7 @@ import (
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
 	"github.com/lescuer97/nutmix/internal/utils"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
@@ -807,3 +808,115 @@ func TestSearchLightningRequestsAppliesSinceLimitAndEscapesWildcards(t *testing.
 		t.Fatalf("expected escaped wildcard to match only literal percent row, got %#v", wildcardRows)
 	}
 }
+
+func TestSaveRestoreSigsAndGet_WithoutDleq(t *testing.T) {
+	db, ctx := setupTestDB(t)
+
+	// the PKCS#11 signer can not prove its signatures, they come without DLEQ
+	b_Pubkey, _ := secp256k1.GeneratePrivateKey()
+	c_Pubkey, _ := secp256k1.GeneratePrivateKey()
+	wrappedB := cashu.WrappedPublicKey{PublicKey: b_Pubkey.PubKey()}
+	recoverSig := cashu.RecoverSigDB{
+		B_:        wrappedB,
+		C_:        cashu.WrappedPublicKey{PublicKey: c_Pubkey.PubKey()},
+		Dleq:      nil,
+		Id:        "test_keyset_id",
+		MeltQuote: "",
+		Amount:    8,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	tx, err := db.GetTx(ctx)
+	if err != nil {
+		t.Fatalf("could not get transaction. %v", err)
+	}
+	err = db.SaveRestoreSigs(tx, []cashu.RecoverSigDB{recoverSig})
+	if err != nil {
+		t.Fatalf("db.SaveRestoreSigs failed: %v", err)
+	}
+	sigs, err := db.GetRestoreSigsFromBlindedMessages(tx, []cashu.WrappedPublicKey{wrappedB})
+	if err != nil {
+		t.Fatalf("db.GetRestoreSigsFromBlindedMessages failed: %v", err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("could not commit transaction. %v", err)
+	}
+	if len(sigs) != 1 || sigs[0].Dleq != nil || sigs[0].Amount != 8 {
+		t.Fatalf("expected one signature without dleq, got %+v", sigs)
+	}
+}
+
+func TestDleqToPrivateKeys(t *testing.T) {
+	e, s := dleqToPrivateKeys(nil)
+	if e != nil || s != nil {
+		t.Errorf("expected no keys without a dleq, got %x %x", e, s)
+	}
+
+	eKey, _ := secp256k1.GeneratePrivateKey()
+	sKey, _ := secp256k1.GeneratePrivateKey()
+	e, s = dleqToPrivateKeys(&cashu.BlindSignatureDLEQ{E: eKey, S: sKey})
+	var sig cashu.RecoverSigDB
+	privateKeysToDleq(s, e, &sig)
+	if sig.Dleq == nil || !sig.Dleq.E.Key.Equals(&eKey.Key) || !sig.Dleq.S.Key.Equals(&sKey.Key) {
+		t.Errorf("expected the dleq to round trip, got %+v", sig.Dleq)
+	}
+}
+
+func TestLiabilityReportCopiesLeavesInTreeOrder(t *testing.T) {
+	db, ctx := setupTestDB(t)
+
+	sigs := make([]cashu.RecoverSigDB, 5)
+	for i := range sigs {
+		B_, _ := secp256k1.GeneratePrivateKey()
+		C_, _ := secp256k1.GeneratePrivateKey()
+		sigs[i] = cashu.RecoverSigDB{B_: cashu.WrappedPublicKey{PublicKey: B_.PubKey()}, C_: cashu.WrappedPublicKey{PublicKey: C_.PubKey()}, Dleq: nil, Id: "keyset", MeltQuote: "", Amount: uint64(i + 1), CreatedAt: 0}
+	}
+	tx, err := db.GetTx(ctx)
+	if err != nil {
+		t.Fatalf("could not get transaction. %v", err)
+	}
+	err = db.SaveRestoreSigs(tx, sigs)
+	if err != nil {
+		t.Fatalf("db.SaveRestoreSigs failed: %v", err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("could not commit transaction. %v", err)
+	}
+
+	tx, err = db.GetLiabilitiesTx(ctx)
+	if err != nil {
+		t.Fatalf("db.GetLiabilitiesTx(ctx) %v", err)
+	}
+	streamed := make([]string, 0)
+	err = db.ForEachKeysetLiability(ctx, tx, "keyset", func(leaf database.LiabilityLeaf) error {
+		streamed = append(streamed, leaf.Point.ToHex())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("db.ForEachKeysetLiability(ctx, tx, keyset) %v", err)
+	}
+	report := database.LiabilityReport{Keysets: []database.KeysetLiabilities{{KeysetId: "keyset", Unit: "sat", IssuedCount: 5}}, Signature: nil, Id: 0, CreatedAt: 1} //nolint:exhaustruct
+	id, err := db.SaveLiabilityReport(ctx, tx, report, 2)
+	if err != nil {
+		t.Fatalf("db.SaveLiabilityReport(ctx, tx, report, 2) %v", err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("could not commit transaction. %v", err)
+	}
+
+	leaves, err := db.GetLiabilityReportLeaves(ctx, id, "keyset")
+	if err != nil {
+		t.Fatalf("db.GetLiabilityReportLeaves(ctx, id, keyset) %v", err)
+	}
+	if len(leaves) != len(streamed) || len(leaves) != 5 {
+		t.Fatalf("expected 5 leaves, got %d saved and %d streamed", len(leaves), len(streamed))
+	}
+	for i, leaf := range leaves {
+		if leaf.LeafIndex != uint64(i) || leaf.Point.ToHex() != streamed[i] {
+			t.Errorf("leaf %d was saved out of the streamed order: %+v", i, leaf)
+		}
+	}
+}
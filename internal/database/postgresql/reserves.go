@@ -0,0 +1,66 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+func (pql Postgresql) InsertReserveSnapshot(ctx context.Context, snapshot database.ReserveSnapshot) error {
+	inflated := snapshot.InflatedKeysets
+	if inflated == nil {
+		inflated = []string{}
+	}
+	untracked := snapshot.UntrackedKeysets
+	if untracked == nil {
+		untracked = []string{}
+	}
+	_, err := pql.pool.Exec(ctx, `INSERT INTO reserve_snapshots (created_at, lightning_balance, outstanding, inflated_keysets, untracked_keysets)
+		VALUES ($1, $2, $3, $4, $5)`, snapshot.CreatedAt, snapshot.LightningBalance, snapshot.Outstanding, inflated, untracked)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to reserve_snapshots: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) GetLatestReserveSnapshot(ctx context.Context) (*database.ReserveSnapshot, error) {
+	rows, err := pql.pool.Query(ctx, `SELECT id, created_at, lightning_balance, outstanding, inflated_keysets, untracked_keysets
+		FROM reserve_snapshots ORDER BY created_at DESC, id DESC LIMIT 1`)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("selecting from reserve_snapshots: %w", err))
+	}
+	snapshot, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[database.ReserveSnapshot])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, databaseError(fmt.Errorf("GetLatestReserveSnapshot collect error: %w", err))
+	}
+	return &snapshot, nil
+}
+
+func (pql Postgresql) GetReserveSnapshotsBySince(ctx context.Context, since int64) ([]database.ReserveSnapshot, error) {
+	rows, err := pql.pool.Query(ctx, `SELECT id, created_at, lightning_balance, outstanding, inflated_keysets, untracked_keysets
+		FROM reserve_snapshots WHERE created_at >= $1 ORDER BY created_at ASC, id ASC`, since)
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetReserveSnapshotsBySince query error: %w", err))
+	}
+	snapshots, err := collectRows(rows, pgx.RowToStructByName[database.ReserveSnapshot])
+	if err != nil {
+		return nil, databaseError(fmt.Errorf("GetReserveSnapshotsBySince collect error: %w", err))
+	}
+	return snapshots, nil
+}
+
+func (pql Postgresql) DeleteReserveSnapshotsBefore(ctx context.Context, before int64) (int64, error) {
+	tag, err := pql.pool.Exec(ctx, `DELETE FROM reserve_snapshots WHERE created_at < $1 AND id NOT IN (
+			SELECT id FROM reserve_snapshots ORDER BY created_at DESC, id DESC LIMIT 1
+		)`, before)
+	if err != nil {
+		return 0, databaseError(fmt.Errorf("deleting from reserve_snapshots: %w", err))
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,130 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	cacheUtils "github.com/gin-contrib/cache/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const responseCacheTimeout = 5 * time.Second
+
+// ResponseCache is a NUT-19 response cache stored in postgres so every replica
+// behind a load balancer answers a retried request with the same response.
+type ResponseCache struct {
+	pool *pgxpool.Pool
+}
+
+func NewResponseCache(pql Postgresql) *ResponseCache {
+	return &ResponseCache{pool: pql.pool}
+}
+
+func (rc *ResponseCache) Get(key string, value any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), responseCacheTimeout)
+	defer cancel()
+
+	var stored []byte
+	err := rc.pool.QueryRow(ctx, "SELECT value FROM response_cache WHERE key = $1 AND expires_at > $2", key, time.Now().Unix()).Scan(&stored)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return persistence.ErrCacheMiss
+		}
+		return databaseError(fmt.Errorf("selecting from response_cache: %w", err))
+	}
+
+	return cacheUtils.Deserialize(stored, value)
+}
+
+func (rc *ResponseCache) Set(key string, value any, expire time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), responseCacheTimeout)
+	defer cancel()
+
+	serialized, err := cacheUtils.Serialize(value)
+	if err != nil {
+		return fmt.Errorf("cacheUtils.Serialize(value). %w", err)
+	}
+
+	_, err = rc.pool.Exec(ctx, `INSERT INTO response_cache (key, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		key, serialized, time.Now().Add(expire).Unix())
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to response_cache: %w", err))
+	}
+	return nil
+}
+
+// DeleteExpired removes cached responses that can no longer be served.
+func (rc *ResponseCache) DeleteExpired(ctx context.Context) error {
+	_, err := rc.pool.Exec(ctx, "DELETE FROM response_cache WHERE expires_at <= $1", time.Now().Unix())
+	if err != nil {
+		return databaseError(fmt.Errorf("deleting from response_cache: %w", err))
+	}
+	return nil
+}
+
+// Notify sends a payload to every connection listening on channel, including
+// connections held by other nutmix instances.
+func (pql Postgresql) Notify(ctx context.Context, channel string, payload string) error {
+	_, err := pql.pool.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	if err != nil {
+		return databaseError(fmt.Errorf("pg_notify(%s): %w", channel, err))
+	}
+	return nil
+}
+
+// Listen holds a connection from the pool and calls handle for every
+// notification received on channel. It blocks until ctx is cancelled or the
+// connection fails.
+func (pql Postgresql) Listen(ctx context.Context, channel string, handle func(payload string)) error {
+	conn, err := pql.pool.Acquire(ctx)
+	if err != nil {
+		return databaseError(fmt.Errorf("pql.pool.Acquire(ctx): %w", err))
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		return databaseError(fmt.Errorf("LISTEN %s: %w", channel, err))
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return databaseError(fmt.Errorf("conn.Conn().WaitForNotification(ctx): %w", err))
+		}
+		handle(notification.Payload)
+	}
+}
+
+func (pql Postgresql) AddRevokedAdminToken(ctx context.Context, tokenHash string, expiresAt int64) error {
+	_, err := pql.pool.Exec(ctx, "INSERT INTO admin_token_blacklist (token_hash, expires_at) VALUES ($1, $2) ON CONFLICT (token_hash) DO NOTHING", tokenHash, expiresAt)
+	if err != nil {
+		return databaseError(fmt.Errorf("inserting to admin_token_blacklist: %w", err))
+	}
+	return nil
+}
+
+func (pql Postgresql) IsAdminTokenRevoked(ctx context.Context, tokenHash string, now int64) (bool, error) {
+	var revoked bool
+	err := pql.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM admin_token_blacklist WHERE token_hash = $1 AND expires_at > $2)", tokenHash, now).Scan(&revoked)
+	if err != nil {
+		return false, databaseError(fmt.Errorf("selecting from admin_token_blacklist: %w", err))
+	}
+	return revoked, nil
+}
+
+func (pql Postgresql) DeleteExpiredAdminTokens(ctx context.Context, now int64) error {
+	_, err := pql.pool.Exec(ctx, "DELETE FROM admin_token_blacklist WHERE expires_at <= $1", now)
+	if err != nil {
+		return databaseError(fmt.Errorf("deleting from admin_token_blacklist: %w", err))
+	}
+	return nil
+}
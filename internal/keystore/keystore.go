@@ -0,0 +1,85 @@
+// Package keystore reads the encrypted keystores of the master keys and gets
+// their passphrase, for every binary that can hold the master key.
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+const unlockAttempts = 3
+
+var (
+	ErrKeystoreAndPrivateKey = errors.New("set either " + secrets.KeystoreFileEnv + " or " + utils.MINT_PRIVATE_KEY_ENV + " and " + localsigner.PreviousPrivateKeysEnv + ", not both")
+	ErrNoPassphrase          = errors.New("there is no " + secrets.KeystorePassphraseFdEnv + " or terminal to read the keystore passphrase from")
+	ErrTooManyAttempts       = fmt.Errorf("%w %d times", secrets.ErrWrongPassphrase, unlockAttempts)
+)
+
+// Read reads the keystore in path and the keystores of the previous master
+// keys in MINT_PREVIOUS_KEYSTORE_FILES. The raw keys can not be set as well.
+func Read(path string) (secrets.Keystore, []secrets.Keystore, error) {
+	if os.Getenv(utils.MINT_PRIVATE_KEY_ENV) != "" || os.Getenv(localsigner.PreviousPrivateKeysEnv) != "" {
+		return secrets.Keystore{}, nil, ErrKeystoreAndPrivateKey
+	}
+	current, err := secrets.ReadKeystore(path)
+	if err != nil {
+		return secrets.Keystore{}, nil, fmt.Errorf("secrets.ReadKeystore(path). %w", err)
+	}
+	previous, err := secrets.ReadKeystores(os.Getenv(secrets.PreviousKeystoreFilesEnv))
+	if err != nil {
+		return secrets.Keystore{}, nil, fmt.Errorf("secrets.ReadKeystores(%s). %w", secrets.PreviousKeystoreFilesEnv, err)
+	}
+	return current, previous, nil
+}
+
+// UnlockKeys returns the master key of current and the previous master keys.
+func UnlockKeys(current secrets.Keystore, previous []secrets.Keystore, passphrase string) ([]byte, [][]byte, error) {
+	privateKey, err := current.Unlock(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	previousKeys, err := secrets.UnlockKeystores(previous, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, previousKeys, nil
+}
+
+// Unlock calls unlock with the passphrase from MINT_KEYSTORE_PASSPHRASE_FD.
+// Without it the passphrase is asked for on the terminal until unlock stops
+// failing with secrets.ErrWrongPassphrase, up to three times.
+func Unlock(unlock func(passphrase string) error) error {
+	fd := os.Getenv(secrets.KeystorePassphraseFdEnv)
+	if fd != "" {
+		passphrase, err := secrets.PassphraseFromFd(fd)
+		if err != nil {
+			return fmt.Errorf("secrets.PassphraseFromFd(%s). %w", fd, err)
+		}
+		err = unlock(passphrase)
+		if err != nil {
+			return fmt.Errorf("unlock(passphrase). %w", err)
+		}
+		return nil
+	}
+	if !secrets.IsTerminal() {
+		return ErrNoPassphrase
+	}
+
+	for range unlockAttempts {
+		passphrase, err := secrets.PromptPassphrase("Keystore passphrase: ")
+		if err != nil {
+			return err
+		}
+		err = unlock(passphrase)
+		if !errors.Is(err, secrets.ErrWrongPassphrase) {
+			return err
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "Wrong passphrase")
+	}
+	return ErrTooManyAttempts
+}
@@ -0,0 +1,105 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/lescuer97/nutmix/internal/secrets"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+func writeKeystore(t *testing.T, secret []byte, passphrase string) string {
+	t.Helper()
+	keystore, err := secrets.NewKeystore(secret, "pubkey", passphrase)
+	if err != nil {
+		t.Fatalf("secrets.NewKeystore: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	err = secrets.WriteKeystore(path, keystore, false)
+	if err != nil {
+		t.Fatalf("secrets.WriteKeystore: %v", err)
+	}
+	return path
+}
+
+func passphraseFd(t *testing.T, passphrase string) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	t.Cleanup(func() { _ = reader.Close() })
+	_, err = writer.WriteString(passphrase + "\n")
+	if err != nil {
+		t.Fatalf("writer.WriteString: %v", err)
+	}
+	_ = writer.Close()
+	return strconv.FormatUint(uint64(reader.Fd()), 10)
+}
+
+func TestReadAndUnlockKeys(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+	previousSecret := bytes.Repeat([]byte{2}, 32)
+	path := writeKeystore(t, secret, "passphrase")
+	t.Setenv(secrets.PreviousKeystoreFilesEnv, writeKeystore(t, previousSecret, "passphrase"))
+	t.Setenv(utils.MINT_PRIVATE_KEY_ENV, "")
+	t.Setenv(localsigner.PreviousPrivateKeysEnv, "")
+
+	current, previous, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read(path): %v", err)
+	}
+	privateKey, previousKeys, err := UnlockKeys(current, previous, "passphrase")
+	if err != nil {
+		t.Fatalf("UnlockKeys: %v", err)
+	}
+	if !bytes.Equal(privateKey, secret) || len(previousKeys) != 1 || !bytes.Equal(previousKeys[0], previousSecret) {
+		t.Errorf("unexpected keys %x %x", privateKey, previousKeys)
+	}
+	_, _, err = UnlockKeys(current, previous, "wrong")
+	if !errors.Is(err, secrets.ErrWrongPassphrase) {
+		t.Errorf("expected a wrong passphrase, got %v", err)
+	}
+
+	t.Setenv(utils.MINT_PRIVATE_KEY_ENV, "0000000000000000000000000000000000000000000000000000000000000001")
+	_, _, err = Read(path)
+	if !errors.Is(err, ErrKeystoreAndPrivateKey) {
+		t.Errorf("expected the raw key to be refused, got %v", err)
+	}
+}
+
+func TestUnlockFromFd(t *testing.T) {
+	var got string
+	unlock := func(passphrase string) error {
+		got = passphrase
+		if passphrase != "right" {
+			return secrets.ErrWrongPassphrase
+		}
+		return nil
+	}
+
+	t.Setenv(secrets.KeystorePassphraseFdEnv, passphraseFd(t, "right"))
+	err := Unlock(unlock)
+	if err != nil || got != "right" {
+		t.Errorf("expected the passphrase of the fd, got %q %v", got, err)
+	}
+
+	t.Setenv(secrets.KeystorePassphraseFdEnv, passphraseFd(t, "wrong"))
+	err = Unlock(unlock)
+	if !errors.Is(err, secrets.ErrWrongPassphrase) || errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("expected a single wrong passphrase, got %v", err)
+	}
+
+	if !secrets.IsTerminal() {
+		t.Setenv(secrets.KeystorePassphraseFdEnv, "")
+		err = Unlock(unlock)
+		if !errors.Is(err, ErrNoPassphrase) {
+			t.Errorf("expected no passphrase without a terminal, got %v", err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package lightning
 
 import (
+	"context"
 	"errors"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -40,6 +41,25 @@ type LightningBackend interface {
 	DescriptionSupport() bool
 }
 
+// NodeNetworkReporter is implemented by the backends that can ask the node
+// which chain it runs on. GetNetwork only returns the configured network.
+type NodeNetworkReporter interface {
+	NodeNetwork(ctx context.Context) (string, error)
+}
+
+// chainParamsName maps the network names used by lightning nodes to the
+// chaincfg.Params names.
+func chainParamsName(network string) string {
+	switch network {
+	case "bitcoin":
+		return chaincfg.MainNetParams.Name
+	case "testnet":
+		return chaincfg.TestNet3Params.Name
+	default:
+		return network
+	}
+}
+
 type PaymentStatus uint
 
 const SETTLED PaymentStatus = iota + 1
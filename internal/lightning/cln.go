@@ -87,6 +87,14 @@ func (l *CLNGRPCWallet) SetupGrpc(host string, caCert string, clientCert string,
 	return nil
 }
 
+// Close closes the grpc connection to the node.
+func (l CLNGRPCWallet) Close() error {
+	if l.grpcClient == nil {
+		return nil
+	}
+	return l.grpcClient.Close()
+}
+
 func (l *CLNGRPCWallet) clnGrpcPayInvoice(invoice string, feeReserve cashu.Amount, lightningResponse *PaymentResponse) error {
 	ctx := metadata.AppendToOutgoingContext(context.Background(), "rune", l.macaroon)
 	client := cln_grpc.NewNodeClient(l.grpcClient)
@@ -421,6 +429,17 @@ func (l CLNGRPCWallet) WalletBalance() (cashu.Amount, error) {
 	return cashu.NewAmount(cashu.Msat, fundsMSat), nil
 }
 
+func (l CLNGRPCWallet) NodeNetwork(ctx context.Context) (string, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "rune", l.macaroon)
+	client := cln_grpc.NewNodeClient(l.grpcClient)
+
+	info, err := client.Getinfo(ctx, &cln_grpc.GetinfoRequest{})
+	if err != nil {
+		return "", fmt.Errorf("client.Getinfo(ctx, &cln_grpc.GetinfoRequest{}). %w", err)
+	}
+	return chainParamsName(info.GetNetwork()), nil
+}
+
 func (f CLNGRPCWallet) LightningType() Backend {
 	return CLNGRPC
 }
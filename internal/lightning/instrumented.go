@@ -0,0 +1,129 @@
+package lightning
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/metrics"
+	"github.com/lescuer97/nutmix/internal/tracing"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentedBackend records the latency and failures of every call that
+// reaches the lightning node. Calls made through WithContext also get a span
+// under the request that made them.
+type InstrumentedBackend struct {
+	ctx     context.Context
+	Backend LightningBackend
+	Name    string
+}
+
+// Instrument wraps backend so its calls show up in the metrics and traces. Wrapping an
+// already instrumented backend returns it unchanged.
+func Instrument(backend LightningBackend, name string) LightningBackend {
+	if _, ok := backend.(InstrumentedBackend); ok {
+		return backend
+	}
+	return InstrumentedBackend{ctx: nil, Backend: backend, Name: name}
+}
+
+// Close closes the wrapped backend when it holds a connection.
+func (i InstrumentedBackend) Close() error {
+	closer, ok := i.Backend.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+// WithContext ties the calls on backend to ctx so their spans are children of
+// the operation that triggered them.
+func WithContext(ctx context.Context, backend LightningBackend) LightningBackend {
+	instrumented, ok := backend.(InstrumentedBackend)
+	if !ok {
+		return backend
+	}
+	instrumented.ctx = ctx
+	return instrumented
+}
+
+func (i InstrumentedBackend) start(method string) (trace.Span, time.Time) {
+	ctx := i.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.Start(ctx, "Lightning."+method, attribute.String("lightning.backend", i.Name))
+	return span, time.Now()
+}
+
+func (i InstrumentedBackend) end(method string, span trace.Span, start time.Time, err error) {
+	metrics.ObserveLightningCall(i.Name, method, start, err)
+	tracing.End(span, err)
+}
+
+func (i InstrumentedBackend) PayInvoice(melt_quote cashu.MeltRequestDB, zpayInvoice *zpay32.Invoice, feeReserve cashu.Amount, mpp bool, amount cashu.Amount) (PaymentResponse, error) {
+	span, start := i.start("PayInvoice")
+	res, err := i.Backend.PayInvoice(melt_quote, zpayInvoice, feeReserve, mpp, amount)
+	i.end("PayInvoice", span, start, err)
+	return res, err
+}
+
+func (i InstrumentedBackend) CheckPayed(quote string, invoice *zpay32.Invoice, checkingId string) (PaymentStatus, string, cashu.Amount, error) {
+	span, start := i.start("CheckPayed")
+	status, preimage, fee, err := i.Backend.CheckPayed(quote, invoice, checkingId)
+	i.end("CheckPayed", span, start, err)
+	return status, preimage, fee, err
+}
+
+func (i InstrumentedBackend) CheckReceived(quote cashu.MintRequestDB, invoice *zpay32.Invoice) (PaymentStatus, string, error) {
+	span, start := i.start("CheckReceived")
+	status, preimage, err := i.Backend.CheckReceived(quote, invoice)
+	i.end("CheckReceived", span, start, err)
+	return status, preimage, err
+}
+
+func (i InstrumentedBackend) RequestInvoice(amount cashu.Amount, description *string) (InvoiceResponse, error) {
+	span, start := i.start("RequestInvoice")
+	res, err := i.Backend.RequestInvoice(amount, description)
+	i.end("RequestInvoice", span, start, err)
+	return res, err
+}
+
+func (i InstrumentedBackend) QueryFees(invoice string, zpayInvoice *zpay32.Invoice, mpp bool, amount cashu.Amount) (FeesResponse, error) {
+	span, start := i.start("QueryFees")
+	res, err := i.Backend.QueryFees(invoice, zpayInvoice, mpp, amount)
+	i.end("QueryFees", span, start, err)
+	return res, err
+}
+
+func (i InstrumentedBackend) WalletBalance() (cashu.Amount, error) {
+	span, start := i.start("WalletBalance")
+	balance, err := i.Backend.WalletBalance()
+	i.end("WalletBalance", span, start, err)
+	return balance, err
+}
+
+func (i InstrumentedBackend) LightningType() Backend {
+	return i.Backend.LightningType()
+}
+
+func (i InstrumentedBackend) GetNetwork() *chaincfg.Params {
+	return i.Backend.GetNetwork()
+}
+
+func (i InstrumentedBackend) ActiveMPP() bool {
+	return i.Backend.ActiveMPP()
+}
+
+func (i InstrumentedBackend) VerifyUnitSupport(unit cashu.Unit) bool {
+	return i.Backend.VerifyUnitSupport(unit)
+}
+
+func (i InstrumentedBackend) DescriptionSupport() bool {
+	return i.Backend.DescriptionSupport()
+}
@@ -79,6 +79,14 @@ func (l *LndGrpcWallet) SetupGrpc(host string, macaroon string, tlsCrt string) e
 	return nil
 }
 
+// Close closes the grpc connection to the node.
+func (l LndGrpcWallet) Close() error {
+	if l.grpcClient == nil {
+		return nil
+	}
+	return l.grpcClient.Close()
+}
+
 func (l *LndGrpcWallet) lndGrpcPayInvoice(routerrpcClient routerrpc.RouterClient, invoiceString string, decodedInvoice *zpay32.Invoice, feeReserve cashu.Amount, lightningResponse *PaymentResponse) error {
 	ctx := metadata.AppendToOutgoingContext(context.Background(), "macaroon", l.macaroon)
 
@@ -500,6 +508,20 @@ func (l LndGrpcWallet) WalletBalance() (cashu.Amount, error) {
 	return cashu.Amount{Unit: cashu.Msat, Amount: balance.LocalBalance.GetMsat()}, nil
 }
 
+func (l LndGrpcWallet) NodeNetwork(ctx context.Context) (string, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "macaroon", l.macaroon)
+	client := lnrpc.NewLightningClient(l.grpcClient)
+
+	info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		return "", fmt.Errorf("client.GetInfo(ctx, &lnrpc.GetInfoRequest{}). %w", err)
+	}
+	if len(info.GetChains()) == 0 {
+		return "", fmt.Errorf("lnd did not report a chain")
+	}
+	return chainParamsName(info.GetChains()[0].GetNetwork()), nil
+}
+
 func (f LndGrpcWallet) LightningType() Backend {
 	return LNDGRPC
 }
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "nutmix"
+
+const (
+	OperationSwap = "swap"
+	OperationMint = "mint"
+	OperationMelt = "melt"
+)
+
+// outcomes of a melt request
+const (
+	MeltPaid    = "paid"
+	MeltPending = "pending"
+	MeltFailed  = "failed"
+)
+
+// Registry holds every nutmix metric. A dedicated registry keeps metrics from
+// dependencies out of the /metrics output.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_errors_total",
+		Help:      "HTTP requests answered with a 4xx or 5xx status by route.",
+	}, []string{"method", "route", "status"})
+
+	ecashOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ecash",
+		Name:      "operations_total",
+		Help:      "Successful swaps, mints and melts by unit and keyset.",
+	}, []string{"operation", "unit", "keyset"})
+
+	ecashAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ecash",
+		Name:      "amount_total",
+		Help:      "Amount moved by successful swaps, mints and melts by unit and keyset.",
+	}, []string{"operation", "unit", "keyset"})
+
+	meltDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ecash",
+		Name:      "melt_duration_seconds",
+		Help:      "Duration of melt requests by unit and outcome.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"unit", "outcome"})
+
+	lightningDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "lightning",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of lightning backend calls by method.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"backend", "method"})
+
+	lightningFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "lightning",
+		Name:      "request_failures_total",
+		Help:      "Failed lightning backend calls by method.",
+	}, []string{"backend", "method"})
+
+	signerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "signer",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of signer calls by method.",
+		Buckets:   []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"method"})
+
+	signerFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "signer",
+		Name:      "request_failures_total",
+		Help:      "Failed signer calls by method.",
+	}, []string{"method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), //nolint:exhaustruct
+		httpRequestDuration,
+		httpRequestErrors,
+		ecashOperations,
+		ecashAmount,
+		meltDuration,
+		lightningDuration,
+		lightningFailures,
+		signerDuration,
+		signerFailures,
+	)
+}
+
+// Handler serves the metrics in the prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}) //nolint:exhaustruct
+}
+
+// GinMiddleware records the latency and errors of every request. Requests are
+// labelled with the route template so quote ids don't create new series.
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		statusStr := strconv.Itoa(status)
+
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, statusStr).Observe(time.Since(start).Seconds())
+		if status >= http.StatusBadRequest {
+			httpRequestErrors.WithLabelValues(c.Request.Method, route, statusStr).Inc()
+		}
+	}
+}
+
+// RecordOperation counts a successful swap, mint or melt and the amount
+// moved in each keyset.
+func RecordOperation(operation string, unit string, amountByKeyset map[string]uint64) {
+	for keyset, amount := range amountByKeyset {
+		ecashOperations.WithLabelValues(operation, unit, keyset).Inc()
+		ecashAmount.WithLabelValues(operation, unit, keyset).Add(float64(amount))
+	}
+}
+
+// ObserveMelt records how long a melt request took and if it was paid, left
+// pending or failed.
+func ObserveMelt(unit string, outcome string, start time.Time) {
+	meltDuration.WithLabelValues(unit, outcome).Observe(time.Since(start).Seconds())
+}
+
+func ObserveLightningCall(backend string, method string, start time.Time, err error) {
+	lightningDuration.WithLabelValues(backend, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		lightningFailures.WithLabelValues(backend, method).Inc()
+	}
+}
+
+func ObserveSignerCall(method string, start time.Time, err error) {
+	signerDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		signerFailures.WithLabelValues(method).Inc()
+	}
+}
+
+// RegisterGauge exposes a value that is read every time metrics are scraped.
+// Registering the same gauge twice is not an error.
+func RegisterGauge(subsystem string, name string, help string, value func() float64) error {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, value)
+
+	return register(gauge)
+}
+
+// RegisterCollector adds a collector, like the database pool stats, to the
+// registry. Registering the same collector twice is not an error.
+func RegisterCollector(collector prometheus.Collector) error {
+	return register(collector)
+}
+
+func register(collector prometheus.Collector) error {
+	err := Registry.Register(collector)
+	if err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+//nolint:exhaustruct,govet
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGinMiddlewareUsesRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/v1/mint/quote/bolt11/:quote", func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+
+	for _, quote := range []string{"a", "b"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/mint/quote/bolt11/"+quote, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	errCount := testutil.ToFloat64(httpRequestErrors.WithLabelValues(http.MethodGet, "/v1/mint/quote/bolt11/:quote", "400"))
+	if errCount != 2 {
+		t.Fatalf("expected 2 errors on the route template, got %v", errCount)
+	}
+}
+
+func TestRecordOperationByKeyset(t *testing.T) {
+	RecordOperation(OperationSwap, "sat", map[string]uint64{"00aa": 8, "00bb": 2})
+
+	if amount := testutil.ToFloat64(ecashAmount.WithLabelValues(OperationSwap, "sat", "00aa")); amount != 8 {
+		t.Fatalf("expected amount 8 for keyset 00aa, got %v", amount)
+	}
+	if count := testutil.ToFloat64(ecashOperations.WithLabelValues(OperationSwap, "sat", "00bb")); count != 1 {
+		t.Fatalf("expected one operation for keyset 00bb, got %v", count)
+	}
+}
+
+func TestObserveLightningCallCountsFailures(t *testing.T) {
+	ObserveLightningCall("FakeWallet", "PayInvoice", time.Now(), nil)
+	ObserveLightningCall("FakeWallet", "PayInvoice", time.Now(), errors.New("no route"))
+
+	if failures := testutil.ToFloat64(lightningFailures.WithLabelValues("FakeWallet", "PayInvoice")); failures != 1 {
+		t.Fatalf("expected one failure, got %v", failures)
+	}
+}
+
+func TestRegisterGaugeTwice(t *testing.T) {
+	value := func() float64 { return 3 }
+	if err := RegisterGauge("test", "gauge", "test gauge", value); err != nil {
+		t.Fatalf("RegisterGauge: %v", err)
+	}
+	if err := RegisterGauge("test", "gauge", "test gauge", value); err != nil {
+		t.Fatalf("second RegisterGauge should be ignored, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "nutmix_test_gauge 3") {
+		t.Fatalf("expected gauge in the metrics output")
+	}
+}
+
+func TestObserveMeltByOutcome(t *testing.T) {
+	ObserveMelt("sat", MeltPaid, time.Now())
+	ObserveMelt("sat", MeltFailed, time.Now())
+	ObserveMelt("sat", MeltFailed, time.Now())
+
+	if count := testutil.CollectAndCount(meltDuration, "nutmix_ecash_melt_duration_seconds"); count != 2 {
+		t.Fatalf("expected a series for each outcome, got %d", count)
+	}
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `nutmix_ecash_melt_duration_seconds_count{outcome="failed",unit="sat"} 2`) {
+		t.Fatalf("expected two failed melts in the metrics output")
+	}
+}
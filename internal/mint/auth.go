@@ -15,7 +15,7 @@ import (
 func (m *Mint) verifyClams(clams cashu.AuthClams) error {
 	ctx := context.Background()
 
-	if clams.ClientId != m.Config.MINT_AUTH_OICD_CLIENT_ID {
+	if clams.ClientId != m.Config().MINT_AUTH_OICD_CLIENT_ID {
 		return cashu.ErrInvalidAuthToken
 	}
 	tx, err := m.MintDB.GetTx(ctx)
@@ -61,7 +61,7 @@ func (m *Mint) verifyClams(clams cashu.AuthClams) error {
 }
 
 func (m *Mint) VerifyAuthClearToken(token string) error {
-	verifier := m.OICDClient.Verifier(&oidc.Config{ClientID: m.Config.MINT_AUTH_OICD_CLIENT_ID, Now: time.Now, SkipClientIDCheck: false}) //nolint:exhaustruct
+	verifier := m.OICDClient().Verifier(&oidc.Config{ClientID: m.Config().MINT_AUTH_OICD_CLIENT_ID, Now: time.Now, SkipClientIDCheck: false}) //nolint:exhaustruct
 
 	ctx := context.Background()
 	idToken, err := verifier.Verify(ctx, token)
@@ -125,7 +125,7 @@ func (m *Mint) VerifyAuthBlindToken(authProof cashu.AuthProof) error {
 		return fmt.Errorf("m.MintDB.SaveProof(tx, proofArray). %w", err)
 	}
 
-	err = m.Signer.VerifyProofs(proofArray)
+	err = m.Signer.VerifyProofs(ctx, proofArray)
 	if err != nil {
 		return fmt.Errorf("m.Signer.VerifyProofs(proofArray, nil). %w", err)
 	}
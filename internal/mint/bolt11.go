@@ -14,7 +14,7 @@ import (
 )
 
 func CheckMintRequest(mint *Mint, quote cashu.MintRequestDB, invoice *zpay32.Invoice) (cashu.MintRequestDB, error) {
-	status, _, err := mint.LightningBackend.CheckReceived(quote, invoice)
+	status, _, err := mint.LightningBackend().CheckReceived(quote, invoice)
 	if err != nil {
 		return quote, fmt.Errorf("mint.VerifyLightingPaymentHappened(pool). %w", err)
 	}
@@ -53,17 +53,17 @@ func CheckMeltRequest(ctx context.Context, mint *Mint, quoteId string) (cashu.Po
 		return quote.GetPostMeltQuoteResponse(), nil
 	}
 
-	invoice, err := zpay32.Decode(quote.Request, mint.LightningBackend.GetNetwork())
+	invoice, err := zpay32.Decode(quote.Request, mint.LightningBackend().GetNetwork())
 	if err != nil {
-		return quote.GetPostMeltQuoteResponse(), fmt.Errorf("zpay32.Decode(quote.Request, mint.LightningBackend.GetNetwork()). %w", err)
+		return quote.GetPostMeltQuoteResponse(), fmt.Errorf("zpay32.Decode(quote.Request, mint.LightningBackend().GetNetwork()). %w", err)
 	}
 
-	status, preimage, feesAmount, err := mint.LightningBackend.CheckPayed(quote.Quote, invoice, quote.CheckingId)
+	status, preimage, feesAmount, err := mint.lightningBackend(ctx).CheckPayed(quote.Quote, invoice, quote.CheckingId)
 	if err != nil {
 		if errors.Is(err, invoices.ErrInvoiceNotFound) || strings.Contains(err.Error(), "NotFound") {
 			return quote.GetPostMeltQuoteResponse(), nil
 		}
-		return quote.GetPostMeltQuoteResponse(), fmt.Errorf("mint.LightningBackend.CheckPayed(quote.Quote). %w", err)
+		return quote.GetPostMeltQuoteResponse(), fmt.Errorf("mint.LightningBackend().CheckPayed(quote.Quote). %w", err)
 	}
 
 	switch status {
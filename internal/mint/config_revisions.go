@@ -0,0 +1,282 @@
+package mint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/utils"
+	"github.com/lightningnetwork/lnd/zpay32"
+)
+
+var (
+	ErrInvalidConfig               = errors.New("invalid config")
+	ErrLightningBackendUnreachable = errors.New("could not reach the lightning backend")
+	ErrLightningBackendNetwork     = errors.New("lightning backend network does not match the configured network")
+	ErrOidcDiscovery               = errors.New("could not load the oidc discovery url")
+	ErrConfigRevisionNotFound      = errors.New("config revision not found")
+)
+
+// ConfigRevisionSystemActor stores the config the mint had before its first
+// recorded change, so that one can be rolled back to as well.
+const ConfigRevisionSystemActor = "system"
+
+const oidcSetupTimeout = 3 * time.Second
+
+// ValidateConfig checks the values that would stop the mint from working.
+func ValidateConfig(config utils.Config) error {
+	_, err := CheckChainParams(config.NETWORK)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	switch config.MINT_LIGHTNING_BACKEND {
+	case utils.FAKE_WALLET, utils.LNDGRPC, utils.LNBITS, utils.CLNGRPC, utils.Strike: //nolint:staticcheck // deprecated backends are still valid until their removal.
+	default:
+		return fmt.Errorf("%w: unknown lightning backend %q", ErrInvalidConfig, config.MINT_LIGHTNING_BACKEND)
+	}
+	if config.MINT_QUOTE_TTL == 0 || config.MELT_QUOTE_TTL == 0 {
+		return fmt.Errorf("%w: quote ttls should be more than zero", ErrInvalidConfig)
+	}
+	if config.PEG_IN_LIMIT_SATS != nil && *config.PEG_IN_LIMIT_SATS < 0 {
+		return fmt.Errorf("%w: peg in limit can not be negative", ErrInvalidConfig)
+	}
+	if config.PEG_OUT_LIMIT_SATS != nil && *config.PEG_OUT_LIMIT_SATS < 0 {
+		return fmt.Errorf("%w: peg out limit can not be negative", ErrInvalidConfig)
+	}
+	if config.MINT_REQUIRE_AUTH && config.MINT_AUTH_OICD_URL == "" {
+		return fmt.Errorf("%w: auth needs an oidc discovery url", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// lightningConfigChanged reports if the lightning backend has to be rebuilt.
+func lightningConfigChanged(before utils.Config, after utils.Config) bool {
+	return before.NETWORK != after.NETWORK ||
+		before.MINT_LIGHTNING_BACKEND != after.MINT_LIGHTNING_BACKEND ||
+		before.LND_GRPC_HOST != after.LND_GRPC_HOST ||
+		before.LND_TLS_CERT != after.LND_TLS_CERT ||
+		before.LND_MACAROON != after.LND_MACAROON ||
+		before.MINT_LNBITS_ENDPOINT != after.MINT_LNBITS_ENDPOINT ||
+		before.MINT_LNBITS_KEY != after.MINT_LNBITS_KEY ||
+		before.CLN_GRPC_HOST != after.CLN_GRPC_HOST ||
+		before.CLN_CA_CERT != after.CLN_CA_CERT ||
+		before.CLN_CLIENT_CERT != after.CLN_CLIENT_CERT ||
+		before.CLN_CLIENT_KEY != after.CLN_CLIENT_KEY ||
+		before.CLN_MACAROON != after.CLN_MACAROON ||
+		before.STRIKE_KEY != after.STRIKE_KEY ||
+		before.STRIKE_ENDPOINT != after.STRIKE_ENDPOINT
+}
+
+// VerifyLightningBackend checks that backend answers and makes invoices for
+// network before the mint starts using it.
+func VerifyLightningBackend(backend lightning.LightningBackend, network chaincfg.Params) error {
+	_, err := backend.WalletBalance()
+	if err != nil {
+		return fmt.Errorf("%w: backend.WalletBalance(). %w", ErrLightningBackendUnreachable, err)
+	}
+
+	description := "verification-test-" + strconv.FormatInt(time.Now().Unix(), 10)
+	invoice, err := backend.RequestInvoice(cashu.NewAmount(cashu.Sat, 100), &description)
+	if err != nil {
+		return fmt.Errorf("%w: backend.RequestInvoice(100 sat). %w", ErrLightningBackendUnreachable, err)
+	}
+	decoded, err := zpay32.Decode(invoice.PaymentRequest, &network)
+	if err != nil {
+		return fmt.Errorf("%w: zpay32.Decode(invoice, %s). %w", ErrLightningBackendNetwork, network.Name, err)
+	}
+	if decoded.MilliSat == nil || int64(decoded.MilliSat.ToSatoshis()) != 100 {
+		slog.Warn("Decoded invoice amount mismatch")
+	}
+	return nil
+}
+
+// UpdateConfig validates config, connects the lightning backend and oidc
+// provider it needs when they changed and stores it as a new revision. The
+// mint only switches to it once all of that worked.
+func (m *Mint) UpdateConfig(ctx context.Context, config utils.Config, actor string) (database.ConfigRevision, error) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	return m.applyConfig(ctx, config, actor, nil)
+}
+
+// ChangeConfig is UpdateConfig for the config change makes of a copy of the
+// current one. No other change can happen between reading and storing it, so
+// changes to different settings don't overwrite each other.
+func (m *Mint) ChangeConfig(ctx context.Context, actor string, change func(config *utils.Config) error) (database.ConfigRevision, error) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	before := m.Config()
+	config := before
+	config.URLS = slices.Clone(before.URLS)
+	config.MINT_AUTH_CLEAR_AUTH_URLS = slices.Clone(before.MINT_AUTH_CLEAR_AUTH_URLS)
+	config.MINT_AUTH_BLIND_AUTH_URLS = slices.Clone(before.MINT_AUTH_BLIND_AUTH_URLS)
+	err := change(&config)
+	if err != nil {
+		return database.ConfigRevision{}, err
+	}
+	return m.applyConfig(ctx, config, actor, nil)
+}
+
+// RollbackConfig applies the config of an earlier revision as a new revision.
+func (m *Mint) RollbackConfig(ctx context.Context, revisionId int64, actor string) (database.ConfigRevision, error) {
+	revision, err := m.MintDB.GetConfigRevision(ctx, revisionId)
+	if err != nil {
+		return database.ConfigRevision{}, fmt.Errorf("m.MintDB.GetConfigRevision(ctx, revisionId). %w", err)
+	}
+	if revision == nil {
+		return database.ConfigRevision{}, ErrConfigRevisionNotFound
+	}
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	return m.applyConfig(ctx, revision.Config, actor, &revision.Id)
+}
+
+// applyConfig needs configMu to be held.
+func (m *Mint) applyConfig(ctx context.Context, config utils.Config, actor string, rolledBackFrom *int64) (database.ConfigRevision, error) {
+	current := m.loadState()
+	before := current.config
+	err := ValidateConfig(config)
+	if err != nil {
+		return database.ConfigRevision{}, err
+	}
+
+	// a new backend that does not make it into the state is closed, and so is
+	// the one it replaces when it does
+	var backend lightning.LightningBackend
+	applied := false
+	defer func() {
+		if backend != nil && !applied {
+			closeLightningBackend(backend)
+		}
+	}()
+	if current.lightningBackend == nil || lightningConfigChanged(before, config) {
+		backend, err = SetupLightningBackend(config)
+		if err != nil {
+			return database.ConfigRevision{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+		err = VerifyLightningBackend(backend, *backend.GetNetwork())
+		if err != nil {
+			return database.ConfigRevision{}, err
+		}
+	}
+
+	oidcChanged := before.MINT_REQUIRE_AUTH != config.MINT_REQUIRE_AUTH || before.MINT_AUTH_OICD_URL != config.MINT_AUTH_OICD_URL
+	var oidcClient *oidc.Provider
+	if oidcChanged && config.MINT_REQUIRE_AUTH {
+		oidcCtx, cancel := context.WithTimeout(ctx, oidcSetupTimeout)
+		defer cancel()
+		oidcClient, err = oidc.NewProvider(oidcCtx, config.MINT_AUTH_OICD_URL)
+		if err != nil {
+			return database.ConfigRevision{}, fmt.Errorf("%w: %w", ErrOidcDiscovery, err)
+		}
+	}
+
+	diff, err := audit.Diff(before, config)
+	if err != nil {
+		return database.ConfigRevision{}, fmt.Errorf("audit.Diff(before, config). %w", err)
+	}
+	revision := database.ConfigRevision{
+		Diff:           diff,
+		RolledBackFrom: rolledBackFrom,
+		Actor:          actor,
+		Config:         config,
+		Id:             0,
+		CreatedAt:      time.Now().Unix(),
+	}
+	revision.Id, err = m.saveConfigRevision(ctx, before, revision)
+	if err != nil {
+		return database.ConfigRevision{}, err
+	}
+
+	var replaced lightning.LightningBackend
+	m.updateState(func(state *mintState) {
+		state.config = config
+		if backend != nil {
+			replaced = state.lightningBackend
+			state.lightningBackend = lightning.Instrument(backend, string(config.MINT_LIGHTNING_BACKEND))
+		}
+		if oidcChanged {
+			state.oidcClient = oidcClient
+		}
+	})
+	applied = true
+	if replaced != nil {
+		closeLightningBackend(replaced)
+	}
+	return revision, nil
+}
+
+// closeLightningBackend closes the connection of a backend that is no longer
+// used, when it has one.
+func closeLightningBackend(backend lightning.LightningBackend) {
+	closer, ok := backend.(io.Closer)
+	if !ok {
+		return
+	}
+	err := closer.Close()
+	if err != nil {
+		slog.Warn("could not close the lightning backend", slog.Any("error", err))
+	}
+}
+
+// saveConfigRevision stores the config and its revision in one transaction.
+// The first change also stores the config from before it.
+func (m *Mint) saveConfigRevision(ctx context.Context, before utils.Config, revision database.ConfigRevision) (id int64, err error) {
+	tx, err := m.MintDB.GetTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("m.MintDB.GetTx(ctx). %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rollbackErr := m.MintDB.Rollback(ctx, tx); rollbackErr != nil {
+			slog.Warn("m.MintDB.Rollback(ctx, tx)", slog.String(utils.LogExtraInfo, rollbackErr.Error()))
+		}
+	}()
+
+	latest, err := m.MintDB.GetLatestConfigRevision(tx)
+	if err != nil {
+		return 0, fmt.Errorf("m.MintDB.GetLatestConfigRevision(tx). %w", err)
+	}
+	if latest == nil {
+		_, err = m.MintDB.SaveConfigRevision(tx, database.ConfigRevision{
+			Diff:           nil,
+			RolledBackFrom: nil,
+			Actor:          ConfigRevisionSystemActor,
+			Config:         before,
+			Id:             0,
+			CreatedAt:      revision.CreatedAt,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("m.MintDB.SaveConfigRevision(tx, initial). %w", err)
+		}
+	}
+
+	err = m.MintDB.UpdateConfig(tx, revision.Config)
+	if err != nil {
+		return 0, fmt.Errorf("m.MintDB.UpdateConfig(tx, config). %w", err)
+	}
+	id, err = m.MintDB.SaveConfigRevision(tx, revision)
+	if err != nil {
+		return 0, fmt.Errorf("m.MintDB.SaveConfigRevision(tx, revision). %w", err)
+	}
+
+	err = m.MintDB.Commit(ctx, tx)
+	if err != nil {
+		return 0, fmt.Errorf("m.MintDB.Commit(ctx, tx). %w", err)
+	}
+	return id, nil
+}
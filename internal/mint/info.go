@@ -1,16 +1,19 @@
 package mint
 
 import (
+	"slices"
 	"time"
 
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/signer"
 	"github.com/lescuer97/nutmix/internal/utils"
 )
 
 func (m *Mint) Info() cashu.GetInfoResponse {
+	config := m.Config()
 	contacts := []cashu.ContactInfo{}
 
-	email := m.Config.EMAIL
+	email := config.EMAIL
 
 	if len(email) > 0 {
 		contacts = append(contacts, cashu.ContactInfo{
@@ -19,7 +22,7 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 		})
 	}
 
-	nostr := m.Config.NOSTR
+	nostr := config.NOSTR
 
 	if len(nostr) > 0 {
 		contacts = append(contacts, cashu.ContactInfo{
@@ -33,10 +36,13 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 
 	var optionalNuts = []string{"7", "8", "9", "10", "11", "12", "17", "20"}
 
-	if m.LightningBackend.ActiveMPP() {
+	if !signer.ProvesDleq(m.Signer) {
+		optionalNuts = slices.DeleteFunc(optionalNuts, func(nut string) bool { return nut == "12" })
+	}
+	if m.LightningBackend().ActiveMPP() {
 		optionalNuts = append(optionalNuts, "15")
 	}
-	if m.Config.MINT_REQUIRE_AUTH {
+	if config.MINT_REQUIRE_AUTH {
 		optionalNuts = append(optionalNuts, "21")
 		optionalNuts = append(optionalNuts, "22")
 	}
@@ -55,11 +61,11 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 				Commands:  nil,
 			}
 
-			if m.Config.PEG_IN_LIMIT_SATS != nil {
-				bolt11Method.MaxAmount = *m.Config.PEG_IN_LIMIT_SATS
+			if config.PEG_IN_LIMIT_SATS != nil {
+				bolt11Method.MaxAmount = *config.PEG_IN_LIMIT_SATS
 			}
 
-			descriptionEnabled := m.LightningBackend.DescriptionSupport()
+			descriptionEnabled := m.LightningBackend().DescriptionSupport()
 			bolt11Method.Options = &cashu.SwapMintMethodOptions{
 				Description: &descriptionEnabled,
 			}
@@ -68,7 +74,7 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 				Methods: &[]cashu.SwapMintMethod{
 					bolt11Method,
 				},
-				Disabled:  &m.Config.PEG_OUT_ONLY,
+				Disabled:  &config.PEG_OUT_ONLY,
 				Supported: nil,
 			}
 		case "5":
@@ -81,8 +87,8 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 				Commands:  nil,
 			}
 
-			if m.Config.PEG_OUT_LIMIT_SATS != nil {
-				bolt11Method.MaxAmount = *m.Config.PEG_OUT_LIMIT_SATS
+			if config.PEG_OUT_LIMIT_SATS != nil {
+				bolt11Method.MaxAmount = *config.PEG_OUT_LIMIT_SATS
 			}
 
 			nuts[nut] = cashu.SwapMintInfo{
@@ -150,18 +156,18 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 			nuts[nut] = wsMethod
 
 		case "21":
-			formatedDiscoveryUrl := m.Config.MINT_AUTH_OICD_URL + "/.well-known/openid-configuration"
+			formatedDiscoveryUrl := config.MINT_AUTH_OICD_URL + "/.well-known/openid-configuration"
 			protectedRoutes := cashu.Nut21Info{
 				OpenIdDiscovery: formatedDiscoveryUrl,
-				ClientId:        m.Config.MINT_AUTH_OICD_CLIENT_ID,
-				ProtectedRoutes: cashu.ConvertRouteListToProtectedRouteList(m.Config.MINT_AUTH_CLEAR_AUTH_URLS),
+				ClientId:        config.MINT_AUTH_OICD_CLIENT_ID,
+				ProtectedRoutes: cashu.ConvertRouteListToProtectedRouteList(config.MINT_AUTH_CLEAR_AUTH_URLS),
 			}
 
 			nuts[nut] = protectedRoutes
 		case "22":
 			protectedRoutes := cashu.Nut22Info{
-				BatMaxMint:      m.Config.MINT_AUTH_MAX_BLIND_TOKENS,
-				ProtectedRoutes: cashu.ConvertRouteListToProtectedRouteList(m.Config.MINT_AUTH_BLIND_AUTH_URLS),
+				BatMaxMint:      config.MINT_AUTH_MAX_BLIND_TOKENS,
+				ProtectedRoutes: cashu.ConvertRouteListToProtectedRouteList(config.MINT_AUTH_BLIND_AUTH_URLS),
 			}
 
 			nuts[nut] = protectedRoutes
@@ -176,16 +182,17 @@ func (m *Mint) Info() cashu.GetInfoResponse {
 	}
 
 	response := cashu.GetInfoResponse{
-		Name:            m.Config.NAME,
+		Name:            config.NAME,
 		Version:         "nutmix/" + utils.AppVersion,
 		Pubkey:          m.MintPubkey,
-		Description:     m.Config.DESCRIPTION,
-		DescriptionLong: m.Config.DESCRIPTION_LONG,
-		Motd:            m.Config.MOTD,
+		Description:     config.DESCRIPTION,
+		DescriptionLong: config.DESCRIPTION_LONG,
+		Motd:            config.MOTD,
 		Contact:         contacts,
 		Nuts:            nuts,
-		IconUrl:         m.Config.IconUrl,
-		TosUrl:          m.Config.TosUrl,
+		IconUrl:         config.IconUrl,
+		TosUrl:          config.TosUrl,
+		Urls:            config.URLS,
 		Time:            time.Now().Unix(),
 	}
 
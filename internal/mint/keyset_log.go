@@ -0,0 +1,368 @@
+package mint
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/signer"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+)
+
+// keysetLogPageSize is the most entries returned at once.
+const keysetLogPageSize = 1000
+
+var (
+	ErrKeysetLogEmpty = errors.New("the keyset log has no tree head yet")
+	// ErrKeysetLogTreeSize means a proof was asked for a tree head the mint
+	// never published.
+	ErrKeysetLogTreeSize = errors.New("the keyset log has no tree head of that size")
+	ErrKeysetNotLogged   = errors.New("keyset is not in the keyset log")
+	// ErrKeysetLogMismatch means the signer serves a keyset with other keys
+	// than the ones it was logged with.
+	ErrKeysetLogMismatch = errors.New("keyset does not match its keyset log entry")
+)
+
+// keysetLogEntries returns every keyset of the signer, auth keysets included,
+// as entries of the keyset log.
+func (m *Mint) keysetLogEntries() ([]cashu.KeysetLogEntry, error) {
+	keysets, err := m.Signer.GetKeysets()
+	if err != nil {
+		return nil, fmt.Errorf("m.Signer.GetKeysets(). %w", err)
+	}
+	authKeysets, err := m.Signer.GetAuthKeys()
+	if err != nil {
+		return nil, fmt.Errorf("m.Signer.GetAuthKeys(). %w", err)
+	}
+
+	entries := make([]cashu.KeysetLogEntry, 0, len(keysets.Keysets)+len(authKeysets.Keysets))
+	for _, keyset := range slices.Concat(keysets.Keysets, authKeysets.Keysets) {
+		getKeys := m.Signer.GetKeysById
+		if keyset.Unit == cashu.AUTH.String() {
+			getKeys = m.Signer.GetAuthKeysById
+		}
+		keys, err := getKeys(keyset.Id)
+		if err != nil {
+			return nil, fmt.Errorf("getKeys(%s). %w", keyset.Id, err)
+		}
+		if len(keys.Keysets) == 0 {
+			return nil, fmt.Errorf("%w. Keyset: %s", cashu.ErrKeysetNotKnow, keyset.Id)
+		}
+		entries = append(entries, cashu.KeysetLogEntry{
+			FinalExpiry: keyset.FinalExpiry,
+			Keys:        keys.Keysets[0].Keys,
+			Id:          keyset.Id,
+			Unit:        keyset.Unit,
+			InputFeePpk: keyset.InputFeePpk,
+		})
+	}
+	slices.SortFunc(entries, func(a, b cashu.KeysetLogEntry) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return entries, nil
+}
+
+// signKeysetLogRoot signs a tree head with the mint key. It returns no
+// signature when the signer can only sign blind messages, like the remote
+// signer.
+func (m *Mint) signKeysetLogRoot(treeSize uint64, timestamp int64, rootHash []byte) ([]byte, error) {
+	messageSigner, ok := signer.AsMessageSigner(m.Signer)
+	if !ok {
+		slog.Warn("the signer can not sign the keyset log root, it is published without a signature")
+		return nil, nil
+	}
+	signature, err := messageSigner.SignMessage(cashu.KeysetLogSignedHash(treeSize, timestamp, rootHash))
+	if err != nil {
+		return nil, fmt.Errorf("messageSigner.SignMessage(hash). %w", err)
+	}
+	return signature, nil
+}
+
+func keysetLogRootResponse(root database.KeysetLogRoot) cashu.KeysetLogRoot {
+	return cashu.KeysetLogRoot{
+		RootHash:  hex.EncodeToString(root.RootHash),
+		Signature: hex.EncodeToString(root.Signature),
+		TreeSize:  root.TreeSize,
+		Timestamp: root.CreatedAt,
+	}
+}
+
+func keysetLogHashes(leaves []database.KeysetLogLeaf) [][]byte {
+	hashes := make([][]byte, len(leaves))
+	for i := range leaves {
+		hashes[i] = leaves[i].LeafHash
+	}
+	return hashes
+}
+
+func hexHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i := range hashes {
+		encoded[i] = hex.EncodeToString(hashes[i])
+	}
+	return encoded
+}
+
+// SyncKeysetLog appends the keysets of the signer that are not in the keyset
+// log yet and signs a tree head over them. It also checks the logged keysets
+// are still served with the same keys. It returns the latest tree head, empty
+// while nothing is logged.
+func (m *Mint) SyncKeysetLog(ctx context.Context) (cashu.KeysetLogRoot, error) {
+	if m.SignerLocked() {
+		root, err := m.MintDB.GetLatestKeysetLogRoot(ctx)
+		if err != nil || root == nil {
+			return cashu.KeysetLogRoot{}, err
+		}
+		return keysetLogRootResponse(*root), nil
+	}
+	leaves, err := m.MintDB.GetKeysetLogLeaves(ctx)
+	if err != nil {
+		return cashu.KeysetLogRoot{}, fmt.Errorf("m.MintDB.GetKeysetLogLeaves(ctx). %w", err)
+	}
+	entries, err := m.keysetLogEntries()
+	if err != nil {
+		return cashu.KeysetLogRoot{}, fmt.Errorf("m.keysetLogEntries(). %w", err)
+	}
+	logged := make(map[string]database.KeysetLogLeaf, len(leaves))
+	for _, leaf := range leaves {
+		logged[leaf.KeysetId] = leaf
+	}
+
+	now := time.Now().Unix()
+	var errs []error
+	newLeaves := make([]database.KeysetLogLeaf, 0)
+	for _, entry := range entries {
+		leafData, err := entry.Leaf()
+		if err != nil {
+			return cashu.KeysetLogRoot{}, fmt.Errorf("entry.Leaf(). %w", err)
+		}
+		if leaf, ok := logged[entry.Id]; ok {
+			if !bytes.Equal(leaf.Leaf, leafData) {
+				slog.Error("a keyset is served with other keys than the ones it was logged with", slog.String("keyset", entry.Id))
+				errs = append(errs, fmt.Errorf("%w. Keyset: %s", ErrKeysetLogMismatch, entry.Id))
+			}
+			continue
+		}
+		newLeaves = append(newLeaves, database.KeysetLogLeaf{
+			KeysetId:  entry.Id,
+			Leaf:      leafData,
+			LeafHash:  cashu.KeysetLogLeafHash(leafData),
+			LeafIndex: uint64(len(leaves) + len(newLeaves)),
+			CreatedAt: now,
+		})
+	}
+
+	latest, err := m.MintDB.GetLatestKeysetLogRoot(ctx)
+	if err != nil {
+		return cashu.KeysetLogRoot{}, fmt.Errorf("m.MintDB.GetLatestKeysetLogRoot(ctx). %w", err)
+	}
+	if len(newLeaves) == 0 && (len(leaves) == 0 || latest != nil && latest.TreeSize == uint64(len(leaves))) {
+		if latest == nil {
+			return cashu.KeysetLogRoot{}, errors.Join(errs...)
+		}
+		return keysetLogRootResponse(*latest), errors.Join(errs...)
+	}
+
+	allLeaves := slices.Concat(leaves, newLeaves)
+	rootHash := cashu.KeysetLogRootHash(keysetLogHashes(allLeaves))
+	treeSize := uint64(len(allLeaves))
+	signature, err := m.signKeysetLogRoot(treeSize, now, rootHash)
+	if err != nil {
+		return cashu.KeysetLogRoot{}, fmt.Errorf("m.signKeysetLogRoot(treeSize, now, rootHash). %w", err)
+	}
+	root := database.KeysetLogRoot{RootHash: rootHash, Signature: signature, TreeSize: treeSize, CreatedAt: now}
+	err = m.MintDB.AppendKeysetLog(ctx, newLeaves, root)
+	if err != nil {
+		return cashu.KeysetLogRoot{}, fmt.Errorf("m.MintDB.AppendKeysetLog(ctx, newLeaves, root). %w", err)
+	}
+	slog.Info("signed a new keyset log root", slog.Int("new_keysets", len(newLeaves)), slog.Uint64("tree_size", treeSize))
+	return keysetLogRootResponse(root), errors.Join(errs...)
+}
+
+// KeysetLogRoot is the latest tree head of the keyset log.
+func (m *Mint) KeysetLogRoot(ctx context.Context) (cashu.KeysetLogRoot, error) {
+	root, err := m.MintDB.GetLatestKeysetLogRoot(ctx)
+	if err != nil {
+		return cashu.KeysetLogRoot{}, fmt.Errorf("m.MintDB.GetLatestKeysetLogRoot(ctx). %w", err)
+	}
+	if root == nil {
+		return cashu.KeysetLogRoot{}, ErrKeysetLogEmpty
+	}
+	return keysetLogRootResponse(*root), nil
+}
+
+// keysetLogTree returns the leaves of the published tree head of treeSize,
+// or of the latest one when treeSize is 0.
+func (m *Mint) keysetLogTree(ctx context.Context, treeSize uint64) ([]database.KeysetLogLeaf, database.KeysetLogRoot, error) {
+	var root *database.KeysetLogRoot
+	var err error
+	if treeSize == 0 {
+		root, err = m.MintDB.GetLatestKeysetLogRoot(ctx)
+	} else {
+		root, err = m.MintDB.GetKeysetLogRoot(ctx, treeSize)
+	}
+	if err != nil {
+		return nil, database.KeysetLogRoot{}, fmt.Errorf("m.MintDB.GetKeysetLogRoot(ctx, treeSize). %w", err)
+	}
+	if root == nil {
+		return nil, database.KeysetLogRoot{}, ErrKeysetLogTreeSize
+	}
+	leaves, err := m.MintDB.GetKeysetLogLeaves(ctx)
+	if err != nil {
+		return nil, database.KeysetLogRoot{}, fmt.Errorf("m.MintDB.GetKeysetLogLeaves(ctx). %w", err)
+	}
+	if uint64(len(leaves)) < root.TreeSize {
+		return nil, database.KeysetLogRoot{}, fmt.Errorf("the keyset log has %d leaves for a tree head of %d", len(leaves), root.TreeSize)
+	}
+	return leaves[:root.TreeSize], *root, nil
+}
+
+// KeysetLogEntries returns the logged keysets from start, at most
+// keysetLogPageSize of them.
+func (m *Mint) KeysetLogEntries(ctx context.Context, start uint64) (cashu.GetKeysetLogEntriesResponse, error) {
+	leaves, err := m.MintDB.GetKeysetLogLeaves(ctx)
+	if err != nil {
+		return cashu.GetKeysetLogEntriesResponse{}, fmt.Errorf("m.MintDB.GetKeysetLogLeaves(ctx). %w", err)
+	}
+	response := cashu.GetKeysetLogEntriesResponse{Entries: []cashu.KeysetLogEntry{}, Start: start}
+	if start >= uint64(len(leaves)) {
+		return response, nil
+	}
+	leaves = leaves[start:min(uint64(len(leaves)), start+keysetLogPageSize)]
+	for _, leaf := range leaves {
+		var entry cashu.KeysetLogEntry
+		err := json.Unmarshal(leaf.Leaf, &entry)
+		if err != nil {
+			return cashu.GetKeysetLogEntriesResponse{}, fmt.Errorf("json.Unmarshal(leaf.Leaf, &entry). %w", err)
+		}
+		response.Entries = append(response.Entries, entry)
+	}
+	return response, nil
+}
+
+// KeysetLogInclusion proves the keyset is in the tree head of treeSize, or
+// the latest one when treeSize is 0.
+func (m *Mint) KeysetLogInclusion(ctx context.Context, keysetId string, treeSize uint64) (cashu.GetKeysetLogInclusionResponse, error) {
+	leaves, root, err := m.keysetLogTree(ctx, treeSize)
+	if err != nil {
+		return cashu.GetKeysetLogInclusionResponse{}, err
+	}
+	index := slices.IndexFunc(leaves, func(leaf database.KeysetLogLeaf) bool {
+		return leaf.KeysetId == keysetId
+	})
+	if index < 0 {
+		return cashu.GetKeysetLogInclusionResponse{}, ErrKeysetNotLogged
+	}
+	var entry cashu.KeysetLogEntry
+	err = json.Unmarshal(leaves[index].Leaf, &entry)
+	if err != nil {
+		return cashu.GetKeysetLogInclusionResponse{}, fmt.Errorf("json.Unmarshal(leaf.Leaf, &entry). %w", err)
+	}
+	path, err := cashu.KeysetLogInclusionProof(keysetLogHashes(leaves), uint64(index))
+	if err != nil {
+		return cashu.GetKeysetLogInclusionResponse{}, fmt.Errorf("cashu.KeysetLogInclusionProof(hashes, index). %w", err)
+	}
+	return cashu.GetKeysetLogInclusionResponse{
+		Entry:     entry,
+		RootHash:  hex.EncodeToString(root.RootHash),
+		AuditPath: hexHashes(path),
+		LeafIndex: uint64(index),
+		TreeSize:  root.TreeSize,
+	}, nil
+}
+
+// KeysetLogConsistency proves the tree head of first is the start of the
+// tree head of second, or of the latest one when second is 0.
+func (m *Mint) KeysetLogConsistency(ctx context.Context, first uint64, second uint64) (cashu.GetKeysetLogConsistencyResponse, error) {
+	leaves, root, err := m.keysetLogTree(ctx, second)
+	if err != nil {
+		return cashu.GetKeysetLogConsistencyResponse{}, err
+	}
+	if first > 0 {
+		firstRoot, err := m.MintDB.GetKeysetLogRoot(ctx, first)
+		if err != nil {
+			return cashu.GetKeysetLogConsistencyResponse{}, fmt.Errorf("m.MintDB.GetKeysetLogRoot(ctx, first). %w", err)
+		}
+		if firstRoot == nil || first > root.TreeSize {
+			return cashu.GetKeysetLogConsistencyResponse{}, ErrKeysetLogTreeSize
+		}
+	}
+	proof, err := cashu.KeysetLogConsistencyProof(keysetLogHashes(leaves), first)
+	if err != nil {
+		return cashu.GetKeysetLogConsistencyResponse{}, fmt.Errorf("cashu.KeysetLogConsistencyProof(hashes, first). %w", err)
+	}
+	return cashu.GetKeysetLogConsistencyResponse{
+		Proof:  hexHashes(proof),
+		First:  first,
+		Second: root.TreeSize,
+	}, nil
+}
+
+// keysetLogNostrKind is the addressable app data kind of NIP-78, so relays
+// keep only the latest tree head of the mint.
+const keysetLogNostrKind = 30078
+
+const keysetLogPublishTimeout = 20 * time.Second
+
+var ErrNoNostrNotificationKey = errors.New("no nostr notification key is set")
+
+// PublishKeysetLogRoot publishes a tree head to the relays, signed by the
+// nostr notification key. The mint pubkey goes with it so wallets can check
+// the signature of the root itself.
+func (m *Mint) PublishKeysetLogRoot(ctx context.Context, root cashu.KeysetLogRoot, relays []string) error {
+	if m.NostrNotificationConfig == nil || m.NostrNotificationConfig.NOSTR_NOTIFICATION_NSEC == nil {
+		return ErrNoNostrNotificationKey
+	}
+	content, err := json.Marshal(struct {
+		MintPubkey string `json:"mint_pubkey"`
+		cashu.KeysetLogRoot
+	}{MintPubkey: m.MintPubkey, KeysetLogRoot: root})
+	if err != nil {
+		return fmt.Errorf("json.Marshal(root). %w", err)
+	}
+	key, err := keyer.NewPlainKeySigner(hex.EncodeToString(m.NostrNotificationConfig.NOSTR_NOTIFICATION_NSEC))
+	if err != nil {
+		return fmt.Errorf("keyer.NewPlainKeySigner(hex.EncodeToString(nsec)). %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, keysetLogPublishTimeout)
+	defer cancel()
+	event := nostr.Event{
+		Kind:      keysetLogNostrKind,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{nostr.Tag{"d", "nutmix-keyset-log:" + m.MintPubkey}},
+		Content:   string(content),
+		ID:        "",
+		PubKey:    "",
+		Sig:       "",
+	}
+	if err := key.SignEvent(ctx, &event); err != nil {
+		return fmt.Errorf("key.SignEvent(ctx, &event). %w", err)
+	}
+
+	pool := nostr.NewSimplePool(ctx)
+	results := pool.PublishMany(ctx, relays, event)
+	publishSuccess := false
+	var publishErr error
+	for result := range results {
+		if result.Error == nil {
+			publishSuccess = true
+		} else {
+			publishErr = result.Error
+		}
+	}
+	if !publishSuccess && publishErr != nil {
+		return fmt.Errorf("pool.PublishMany(ctx, relays, event). %w", publishErr)
+	}
+	return nil
+}
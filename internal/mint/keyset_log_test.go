@@ -0,0 +1,135 @@
+package mint
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+func decodeHashes(t *testing.T, encoded []string) [][]byte {
+	t.Helper()
+	hashes := make([][]byte, len(encoded))
+	for i := range encoded {
+		hash, err := hex.DecodeString(encoded[i])
+		if err != nil {
+			t.Fatalf("hex.DecodeString(%s) %+v", encoded[i], err)
+		}
+		hashes[i] = hash
+	}
+	return hashes
+}
+
+func TestKeysetLogProvesEveryKeyset(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
+	signer, err := localsigner.SetupLocalSigner(&db)
+	if err != nil {
+		t.Fatalf("localsigner.SetupLocalSigner(&db) %+v", err)
+	}
+	pubkey, err := signer.GetSignerPubkey()
+	if err != nil {
+		t.Fatalf("signer.GetSignerPubkey() %+v", err)
+	}
+	mint := Mint{MintDB: &db, Signer: &signer, MintPubkey: pubkey} //nolint:exhaustruct
+
+	_, err = mint.KeysetLogRoot(t.Context())
+	if !errors.Is(err, ErrKeysetLogEmpty) {
+		t.Fatalf("expected ErrKeysetLogEmpty, got %v", err)
+	}
+	first, err := mint.SyncKeysetLog(t.Context())
+	if err != nil {
+		t.Fatalf("mint.SyncKeysetLog(ctx) %+v", err)
+	}
+	keysets, err := signer.GetKeysets()
+	if err != nil {
+		t.Fatalf("signer.GetKeysets() %+v", err)
+	}
+	if first.TreeSize != uint64(len(keysets.Keysets)) {
+		t.Fatalf("expected a tree of %d keysets, got %d", len(keysets.Keysets), first.TreeSize)
+	}
+	err = first.Verify(mint.MintPubkey)
+	if err != nil {
+		t.Fatalf("first.Verify(mint.MintPubkey) %+v", err)
+	}
+
+	// a sync without new keysets keeps the tree head
+	again, err := mint.SyncKeysetLog(t.Context())
+	if err != nil {
+		t.Fatalf("mint.SyncKeysetLog(ctx) %+v", err)
+	}
+	if again != first {
+		t.Errorf("expected the same tree head %+v, got %+v", first, again)
+	}
+
+	err = mint.RotateKeyset(t.Context(), cashu.Sat, 100, 0, nil)
+	if err != nil {
+		t.Fatalf("mint.RotateKeyset(ctx, sat) %+v", err)
+	}
+	second, err := mint.KeysetLogRoot(t.Context())
+	if err != nil {
+		t.Fatalf("mint.KeysetLogRoot(ctx) %+v", err)
+	}
+	if second.TreeSize != first.TreeSize+1 {
+		t.Fatalf("expected the rotated keyset in the log, got a tree of %d", second.TreeSize)
+	}
+	err = second.Verify(mint.MintPubkey)
+	if err != nil {
+		t.Fatalf("second.Verify(mint.MintPubkey) %+v", err)
+	}
+
+	active, err := signer.GetActiveKeys()
+	if err != nil {
+		t.Fatalf("signer.GetActiveKeys() %+v", err)
+	}
+	var rotated string
+	for _, keyset := range active.Keysets {
+		if keyset.Unit == cashu.Sat.String() {
+			rotated = keyset.Id
+		}
+	}
+	inclusion, err := mint.KeysetLogInclusion(t.Context(), rotated, 0)
+	if err != nil {
+		t.Fatalf("mint.KeysetLogInclusion(ctx, rotated, 0) %+v", err)
+	}
+	if inclusion.Entry.InputFeePpk != 100 {
+		t.Errorf("expected the fee of the rotated keyset, got %+v", inclusion.Entry)
+	}
+	leaf, err := inclusion.Entry.Leaf()
+	if err != nil {
+		t.Fatalf("inclusion.Entry.Leaf() %+v", err)
+	}
+	err = cashu.VerifyKeysetLogInclusion(cashu.KeysetLogLeafHash(leaf), inclusion.LeafIndex, inclusion.TreeSize, decodeHashes(t, inclusion.AuditPath), decodeHashes(t, []string{second.RootHash})[0])
+	if err != nil {
+		t.Errorf("cashu.VerifyKeysetLogInclusion(...) %+v", err)
+	}
+	_, err = mint.KeysetLogInclusion(t.Context(), rotated, first.TreeSize)
+	if !errors.Is(err, ErrKeysetNotLogged) {
+		t.Errorf("expected ErrKeysetNotLogged in the first tree, got %v", err)
+	}
+
+	consistency, err := mint.KeysetLogConsistency(t.Context(), first.TreeSize, 0)
+	if err != nil {
+		t.Fatalf("mint.KeysetLogConsistency(ctx, first, 0) %+v", err)
+	}
+	roots := decodeHashes(t, []string{first.RootHash, second.RootHash})
+	err = cashu.VerifyKeysetLogConsistency(consistency.First, consistency.Second, roots[0], roots[1], decodeHashes(t, consistency.Proof))
+	if err != nil {
+		t.Errorf("cashu.VerifyKeysetLogConsistency(...) %+v", err)
+	}
+	_, err = mint.KeysetLogConsistency(t.Context(), first.TreeSize+5, 0)
+	if !errors.Is(err, ErrKeysetLogTreeSize) {
+		t.Errorf("expected ErrKeysetLogTreeSize, got %v", err)
+	}
+
+	entries, err := mint.KeysetLogEntries(t.Context(), first.TreeSize)
+	if err != nil {
+		t.Fatalf("mint.KeysetLogEntries(ctx, first) %+v", err)
+	}
+	if len(entries.Entries) != 1 || entries.Entries[0].Id != rotated {
+		t.Errorf("expected only the rotated keyset after the first tree, got %+v", entries)
+	}
+}
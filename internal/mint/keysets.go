@@ -0,0 +1,165 @@
+package mint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+// ErrKeysetPolicyExpiry means a policy would let its keysets expire before it
+// rotates them, leaving wallets with ecash the mint refuses.
+var ErrKeysetPolicyExpiry = errors.New("keysets with a final expiry have to rotate before it")
+
+func keysetExpired(finalExpiry *uint64, now time.Time) bool {
+	return finalExpiry != nil && now.Unix() >= int64(*finalExpiry)
+}
+
+// ValidateKeysetPolicy checks the unit of the policy and that its keysets are
+// rotated out before they expire.
+func ValidateKeysetPolicy(policy database.KeysetPolicy) error {
+	unit, err := cashu.UnitFromString(policy.Unit)
+	if err != nil {
+		return fmt.Errorf("cashu.UnitFromString(policy.Unit). %w", err)
+	}
+	if unit == cashu.AUTH {
+		return fmt.Errorf("%w: %s", cashu.ErrUnitNotSupported, policy.Unit)
+	}
+	if policy.FinalExpiryHours > 0 && (policy.RotateEveryHours == 0 || policy.RotateEveryHours >= policy.FinalExpiryHours) {
+		return ErrKeysetPolicyExpiry
+	}
+	return nil
+}
+
+func keysetRotationDue(policy database.KeysetPolicy, issued uint64, now time.Time) bool {
+	if policy.RotateEveryHours > 0 && now.Unix()-policy.RotatedAt >= int64(policy.RotateEveryHours)*int64(time.Hour/time.Second) {
+		return true
+	}
+	return policy.RotateAfterAmount > 0 && issued >= policy.RotateAfterAmount
+}
+
+// keysetAmounts returns the denominations of the keyset with id, smallest first.
+func (m *Mint) keysetAmounts(id string) ([]uint64, error) {
+	keys, err := m.Signer.GetKeysById(id)
+	if err != nil {
+		return nil, fmt.Errorf("m.Signer.GetKeysById(id). %w", err)
+	}
+	if len(keys.Keysets) == 0 {
+		return nil, cashu.ErrKeysetNotKnow
+	}
+	return slices.Sorted(maps.Keys(keys.Keysets[0].Keys)), nil
+}
+
+// RotateKeyset makes a new active keyset for unit with amounts, or the
+// default powers of two when there are none, and restarts the schedule of the
+// unit policy.
+func (m *Mint) RotateKeyset(ctx context.Context, unit cashu.Unit, fee uint, expiryHours uint, amounts []uint64) error {
+	err := m.Signer.RotateKeyset(unit, fee, expiryHours, amounts)
+	if err != nil {
+		return fmt.Errorf("m.Signer.RotateKeyset(unit, fee, expiryHours, amounts). %w", err)
+	}
+
+	policies, err := m.MintDB.GetKeysetPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("m.MintDB.GetKeysetPolicies(ctx). %w", err)
+	}
+	for _, policy := range policies {
+		if policy.Unit != unit.String() {
+			continue
+		}
+		policy.RotatedAt = time.Now().Unix()
+		err = m.MintDB.SaveKeysetPolicy(ctx, policy)
+		if err != nil {
+			return fmt.Errorf("m.MintDB.SaveKeysetPolicy(ctx, policy). %w", err)
+		}
+	}
+
+	// the keyset log job would add it later, the rotation is done either way
+	_, err = m.SyncKeysetLog(ctx)
+	if err != nil {
+		slog.Warn("could not add the new keyset to the keyset log", slog.Any("error", err))
+	}
+	return nil
+}
+
+// RunKeysetLifecycle rotates the active keysets that are due under the policy
+// of their unit or already past their final expiry, to a keyset with the same
+// amounts, and moves the spent proofs of expired keysets to the archive.
+// Inputs from those keysets are refused, so their proofs are no longer needed
+// to catch double spends.
+func (m *Mint) RunKeysetLifecycle(ctx context.Context) error {
+	if m.SignerLocked() {
+		return nil
+	}
+	keysets, err := m.Signer.GetKeysets()
+	if err != nil {
+		return fmt.Errorf("m.Signer.GetKeysets(). %w", err)
+	}
+	policies, err := m.MintDB.GetKeysetPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("m.MintDB.GetKeysetPolicies(ctx). %w", err)
+	}
+	policyByUnit := make(map[string]database.KeysetPolicy, len(policies))
+	for _, policy := range policies {
+		policyByUnit[policy.Unit] = policy
+	}
+	balances, err := m.MintDB.GetKeysetBalances(ctx)
+	if err != nil {
+		return fmt.Errorf("m.MintDB.GetKeysetBalances(ctx). %w", err)
+	}
+	issued := make(map[string]uint64, len(balances))
+	for _, balance := range balances {
+		issued[balance.KeysetId] = balance.Issued
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, keyset := range keysets.Keysets {
+		expired := keysetExpired(keyset.FinalExpiry, now)
+		if keyset.Active {
+			policy, hasPolicy := policyByUnit[keyset.Unit]
+			if !expired && (!hasPolicy || !keysetRotationDue(policy, issued[keyset.Id], now)) {
+				continue
+			}
+			unit, err := cashu.UnitFromString(keyset.Unit)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cashu.UnitFromString(%s). %w", keyset.Unit, err))
+				continue
+			}
+			fee, expiryHours := keyset.InputFeePpk, uint(0)
+			if hasPolicy {
+				fee, expiryHours = uint(policy.InputFeePpk), uint(policy.FinalExpiryHours)
+			}
+			amounts, err := m.keysetAmounts(keyset.Id)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("m.keysetAmounts(%s). %w", keyset.Id, err))
+				continue
+			}
+			err = m.RotateKeyset(ctx, unit, fee, expiryHours, amounts)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("m.RotateKeyset(ctx, %s, fee, expiryHours, amounts). %w", keyset.Unit, err))
+				continue
+			}
+			slog.Info("rotated keyset", slog.String("unit", keyset.Unit), slog.String("keyset", keyset.Id), slog.Bool("expired", expired))
+			continue
+		}
+		if !expired {
+			continue
+		}
+		archived, err := m.MintDB.ArchiveKeysetProofs(ctx, keyset.Id, now.Unix())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("m.MintDB.ArchiveKeysetProofs(ctx, %s, now). %w", keyset.Id, err))
+			continue
+		}
+		if archived > 0 {
+			slog.Info("archived proofs of expired keyset", slog.String("keyset", keyset.Id), slog.Int64("proofs", archived))
+		}
+	}
+	return errors.Join(errs...)
+}
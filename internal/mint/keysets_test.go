@@ -0,0 +1,202 @@
+package mint
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/signer"
+)
+
+type rotation struct {
+	unit        cashu.Unit
+	amounts     []uint64
+	fee         uint
+	expiryHours uint
+}
+
+// lifecycleSigner has the keysets, and the amounts of their keys, it is made
+// with and records the rotations it is asked for.
+type lifecycleSigner struct {
+	signer.Signer
+	amounts   map[string][]uint64
+	keysets   []cashu.BasicKeysetResponse
+	rotations []rotation
+}
+
+func (s *lifecycleSigner) GetKeysets() (signer.GetKeysetsResponse, error) {
+	return signer.GetKeysetsResponse{Keysets: s.keysets}, nil
+}
+
+func (s *lifecycleSigner) GetKeysById(id string) (signer.GetKeysResponse, error) {
+	keys := make(map[uint64]string)
+	for _, amount := range s.amounts[id] {
+		keys[amount] = ""
+	}
+	return signer.GetKeysResponse{Keysets: []signer.KeysetResponse{{Keys: keys, Id: id, Unit: "", InputFeePpk: 0, Active: true}}}, nil
+}
+
+func (s *lifecycleSigner) GetAuthKeys() (signer.GetKeysetsResponse, error) {
+	return signer.GetKeysetsResponse{Keysets: nil}, nil
+}
+
+func (s *lifecycleSigner) RotateKeyset(unit cashu.Unit, fee uint, expiryHours uint, amounts []uint64) error {
+	s.rotations = append(s.rotations, rotation{unit: unit, amounts: amounts, fee: fee, expiryHours: expiryHours})
+	return nil
+}
+
+func generateKey(t *testing.T) *secp256k1.PublicKey {
+	t.Helper()
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("secp256k1.GeneratePrivateKey() %+v", err)
+	}
+	return key.PubKey()
+}
+
+func unixPointer(t time.Time) *uint64 {
+	unix := uint64(t.Unix())
+	return &unix
+}
+
+func TestCheckProofsAreSameUnitRefusesExpiredKeyset(t *testing.T) {
+	keysets := []cashu.BasicKeysetResponse{
+		{Id: "expired", Unit: "sat", Active: false, FinalExpiry: unixPointer(time.Now().Add(-time.Hour)), InputFeePpk: 0, Version: 0},
+		{Id: "valid", Unit: "sat", Active: true, FinalExpiry: unixPointer(time.Now().Add(time.Hour)), InputFeePpk: 0, Version: 1},
+	}
+	_, err := checkProofsAreSameUnit(cashu.Proofs{{Id: "valid"}}, keysets) //nolint:exhaustruct
+	if err != nil {
+		t.Fatalf("checkProofsAreSameUnit(valid) %+v", err)
+	}
+	_, err = checkProofsAreSameUnit(cashu.Proofs{{Id: "valid"}, {Id: "expired"}}, keysets) //nolint:exhaustruct
+	if !errors.Is(err, cashu.ErrKeysetExpired) {
+		t.Errorf("expected ErrKeysetExpired, got %v", err)
+	}
+}
+
+func TestValidateKeysetPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy database.KeysetPolicy
+		err    error
+	}{
+		{"schedule", database.KeysetPolicy{Unit: "sat", RotateEveryHours: 24}, nil},                                                           //nolint:exhaustruct
+		{"expiry after rotation", database.KeysetPolicy{Unit: "sat", RotateEveryHours: 24, FinalExpiryHours: 48}, nil},                        //nolint:exhaustruct
+		{"expiry without schedule", database.KeysetPolicy{Unit: "sat", RotateAfterAmount: 1000, FinalExpiryHours: 48}, ErrKeysetPolicyExpiry}, //nolint:exhaustruct
+		{"expiry before rotation", database.KeysetPolicy{Unit: "sat", RotateEveryHours: 48, FinalExpiryHours: 24}, ErrKeysetPolicyExpiry},     //nolint:exhaustruct
+		{"auth", database.KeysetPolicy{Unit: "auth", RotateEveryHours: 24}, cashu.ErrUnitNotSupported},                                        //nolint:exhaustruct
+	}
+	for _, test := range tests {
+		err := ValidateKeysetPolicy(test.policy)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestKeysetLifecycleRotatesDueKeysets(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	hourAgo := time.Now().Add(-time.Hour).Unix()
+	db.KeysetPolicies = []database.KeysetPolicy{
+		{Unit: "sat", RotateEveryHours: 0, RotateAfterAmount: 10, InputFeePpk: 100, FinalExpiryHours: 0, RotatedAt: hourAgo, UpdatedAt: hourAgo},
+		{Unit: "usd", RotateEveryHours: 24, RotateAfterAmount: 0, InputFeePpk: 0, FinalExpiryHours: 48, RotatedAt: hourAgo, UpdatedAt: hourAgo},
+	}
+	db.RecoverSigDB = []cashu.RecoverSigDB{
+		{Id: "sat-active", Amount: 16}, //nolint:exhaustruct
+		{Id: "usd-active", Amount: 16}, //nolint:exhaustruct
+	}
+	fakeSigner := lifecycleSigner{
+		Signer:  nil,
+		amounts: map[string][]uint64{"sat-active": cashu.GetAmountsForKeysets(cashu.MaxKeysetAmount), "eur-active": {1, 2, 5, 10, 20, 50}},
+		keysets: []cashu.BasicKeysetResponse{
+			{Id: "sat-active", Unit: "sat", Active: true, FinalExpiry: nil, InputFeePpk: 0, Version: 0},
+			{Id: "usd-active", Unit: "usd", Active: true, FinalExpiry: nil, InputFeePpk: 0, Version: 0},
+			{Id: "eur-active", Unit: "eur", Active: true, FinalExpiry: unixPointer(time.Now().Add(-time.Minute)), InputFeePpk: 50, Version: 0},
+		},
+		rotations: nil,
+	}
+	mint := Mint{MintDB: &db, Signer: &fakeSigner} //nolint:exhaustruct
+
+	err := mint.RunKeysetLifecycle(t.Context())
+	if err != nil {
+		t.Fatalf("mint.RunKeysetLifecycle(ctx) %+v", err)
+	}
+	expected := []rotation{
+		// signed more than the policy allows
+		{unit: cashu.Sat, amounts: cashu.GetAmountsForKeysets(cashu.MaxKeysetAmount), fee: 100, expiryHours: 0},
+		// past its final expiry, without a policy it keeps its fee and amounts
+		{unit: cashu.EUR, amounts: []uint64{1, 2, 5, 10, 20, 50}, fee: 50, expiryHours: 0},
+	}
+	if len(fakeSigner.rotations) != len(expected) {
+		t.Fatalf("expected rotations %+v, got %+v", expected, fakeSigner.rotations)
+	}
+	for i := range expected {
+		got := fakeSigner.rotations[i]
+		if got.unit != expected[i].unit || got.fee != expected[i].fee || got.expiryHours != expected[i].expiryHours || !slices.Equal(got.amounts, expected[i].amounts) {
+			t.Errorf("expected rotation %+v, got %+v", expected[i], fakeSigner.rotations[i])
+		}
+	}
+	if db.KeysetPolicies[0].RotatedAt <= hourAgo {
+		t.Errorf("expected the sat policy schedule to restart")
+	}
+	if db.KeysetPolicies[1].RotatedAt != hourAgo {
+		t.Errorf("the usd policy was not due, got rotated at %d", db.KeysetPolicies[1].RotatedAt)
+	}
+}
+
+func TestKeysetLifecycleArchivesExpiredKeysets(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	spentY := cashu.WrappedPublicKey{PublicKey: generateKey(t)}
+	db.RecoverSigDB = []cashu.RecoverSigDB{{Id: "expired", Amount: 8}} //nolint:exhaustruct
+	db.Proofs = cashu.Proofs{
+		{Id: "expired", Amount: 4, State: cashu.PROOF_SPENT, Y: spentY},                                              //nolint:exhaustruct
+		{Id: "expired", Amount: 2, State: cashu.PROOF_PENDING, Y: cashu.WrappedPublicKey{PublicKey: generateKey(t)}}, //nolint:exhaustruct
+		{Id: "active", Amount: 1, State: cashu.PROOF_SPENT, Y: cashu.WrappedPublicKey{PublicKey: generateKey(t)}},    //nolint:exhaustruct
+	}
+	fakeSigner := lifecycleSigner{
+		Signer:  nil,
+		amounts: nil,
+		keysets: []cashu.BasicKeysetResponse{
+			{Id: "expired", Unit: "sat", Active: false, FinalExpiry: unixPointer(time.Now().Add(-time.Hour)), InputFeePpk: 0, Version: 0},
+			{Id: "active", Unit: "sat", Active: true, FinalExpiry: nil, InputFeePpk: 0, Version: 1},
+		},
+		rotations: nil,
+	}
+	mint := Mint{MintDB: &db, Signer: &fakeSigner} //nolint:exhaustruct
+
+	err := mint.RunKeysetLifecycle(t.Context())
+	if err != nil {
+		t.Fatalf("mint.RunKeysetLifecycle(ctx) %+v", err)
+	}
+	if len(fakeSigner.rotations) != 0 {
+		t.Errorf("expected no rotation, got %+v", fakeSigner.rotations)
+	}
+	if len(db.ArchivedProofs) != 1 || db.ArchivedProofs[0].Y != spentY {
+		t.Fatalf("expected the spent proof of the expired keyset archived, got %+v", db.ArchivedProofs)
+	}
+	if len(db.Proofs) != 2 {
+		t.Errorf("expected the pending and the active proofs to stay, got %+v", db.Proofs)
+	}
+
+	balances, err := db.GetKeysetBalances(t.Context())
+	if err != nil {
+		t.Fatalf("db.GetKeysetBalances(ctx) %+v", err)
+	}
+	expired := balances[1]
+	if expired.KeysetId != "expired" || expired.Redeemed != 4 || expired.Archived != 4 || expired.Outstanding() != 4 {
+		t.Errorf("unexpected balance of the expired keyset %+v", expired)
+	}
+
+	states, err := CheckProofState(t.Context(), &mint, []cashu.WrappedPublicKey{spentY})
+	if err != nil {
+		t.Fatalf("CheckProofState(ctx, mint, spentY) %+v", err)
+	}
+	if states[0].State != cashu.PROOF_SPENT {
+		t.Errorf("expected the archived proof to stay spent, got %s", states[0].State)
+	}
+}
@@ -0,0 +1,402 @@
+package mint
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/signer"
+)
+
+// liabilityReportLeavesKept is how many of the latest reports keep their
+// leaves to serve inclusion proofs.
+const liabilityReportLeavesKept = 2
+
+// maxLiabilityInclusionItems is the most outputs and proofs a wallet can ask
+// proofs for at once.
+const maxLiabilityInclusionItems = 1000
+
+var (
+	ErrLiabilityReportNotFound    = errors.New("liability report not found")
+	ErrLiabilityKeysetNotInReport = errors.New("keyset is not in the liability report")
+	ErrLiabilityLeavesPruned      = errors.New("the leaves of the liability report are no longer kept")
+	ErrTooManyLiabilityItems      = errors.New("too many outputs and proofs in one request")
+)
+
+func liabilityNode(leaf database.LiabilityLeaf) cashu.MerkleSumNode {
+	if leaf.Redeemed {
+		return cashu.RedeemedLiabilityLeaf(leaf.Amount, leaf.Point)
+	}
+	return cashu.IssuedLiabilityLeaf(leaf.Amount, leaf.Point, leaf.Signature)
+}
+
+func liabilityTree(leaves []database.LiabilityLeaf) (*cashu.MerkleSumTree, error) {
+	nodes := make([]cashu.MerkleSumNode, len(leaves))
+	for i, leaf := range leaves {
+		nodes[i] = liabilityNode(leaf)
+	}
+	return cashu.NewMerkleSumTree(nodes)
+}
+
+// splitLiabilityLeaves returns the issued and the redeemed leaves.
+func splitLiabilityLeaves(leaves []database.LiabilityLeaf) ([]database.LiabilityLeaf, []database.LiabilityLeaf) {
+	issued := make([]database.LiabilityLeaf, 0, len(leaves))
+	redeemed := make([]database.LiabilityLeaf, 0)
+	for _, leaf := range leaves {
+		if leaf.Redeemed {
+			redeemed = append(redeemed, leaf)
+		} else {
+			issued = append(issued, leaf)
+		}
+	}
+	return issued, redeemed
+}
+
+// keysetLiabilities builds the roots of the issued and redeemed trees of a
+// keyset from the leaves streamed by the database, without holding them.
+func (m *Mint) keysetLiabilities(ctx context.Context, tx pgx.Tx, keyset cashu.BasicKeysetResponse) (database.KeysetLiabilities, error) {
+	var issued, redeemed cashu.MerkleSumBuilder
+	err := m.MintDB.ForEachKeysetLiability(ctx, tx, keyset.Id, func(leaf database.LiabilityLeaf) error {
+		if leaf.Redeemed {
+			return redeemed.Add(liabilityNode(leaf))
+		}
+		return issued.Add(liabilityNode(leaf))
+	})
+	if err != nil {
+		return database.KeysetLiabilities{}, fmt.Errorf("m.MintDB.ForEachKeysetLiability(ctx, tx, %s). %w", keyset.Id, err)
+	}
+	issuedRoot, err := issued.Root()
+	if err != nil {
+		return database.KeysetLiabilities{}, fmt.Errorf("issued.Root(). %w", err)
+	}
+	redeemedRoot, err := redeemed.Root()
+	if err != nil {
+		return database.KeysetLiabilities{}, fmt.Errorf("redeemed.Root(). %w", err)
+	}
+	return database.KeysetLiabilities{
+		IssuedRoot:    issuedRoot.Hash,
+		RedeemedRoot:  redeemedRoot.Hash,
+		KeysetId:      keyset.Id,
+		Unit:          keyset.Unit,
+		IssuedTotal:   issuedRoot.Sum,
+		IssuedCount:   issued.Size(),
+		RedeemedTotal: redeemedRoot.Sum,
+		RedeemedCount: redeemed.Size(),
+	}, nil
+}
+
+func liabilityReportResponse(report database.LiabilityReport) cashu.LiabilityReport {
+	response := cashu.LiabilityReport{
+		Keysets:   make([]cashu.KeysetLiabilities, 0, len(report.Keysets)),
+		Units:     make([]cashu.UnitLiabilities, 0),
+		Signature: hex.EncodeToString(report.Signature),
+		Id:        report.Id,
+		Timestamp: report.CreatedAt,
+	}
+	units := make(map[string]*cashu.UnitLiabilities)
+	for _, keyset := range report.Keysets {
+		liabilities := cashu.KeysetLiabilities{
+			Issued:        cashu.MerkleSumNode{Hash: keyset.IssuedRoot, Sum: keyset.IssuedTotal},
+			Redeemed:      cashu.MerkleSumNode{Hash: keyset.RedeemedRoot, Sum: keyset.RedeemedTotal},
+			Id:            keyset.KeysetId,
+			Unit:          keyset.Unit,
+			IssuedCount:   keyset.IssuedCount,
+			RedeemedCount: keyset.RedeemedCount,
+		}
+		response.Keysets = append(response.Keysets, liabilities)
+
+		unit, ok := units[keyset.Unit]
+		if !ok {
+			unit = &cashu.UnitLiabilities{Unit: keyset.Unit, Issued: 0, Redeemed: 0, Outstanding: 0}
+			units[keyset.Unit] = unit
+		}
+		unit.Issued += keyset.IssuedTotal
+		unit.Redeemed += keyset.RedeemedTotal
+		unit.Outstanding += liabilities.Outstanding()
+	}
+	for _, unit := range units {
+		response.Units = append(response.Units, *unit)
+	}
+	slices.SortFunc(response.Units, func(a, b cashu.UnitLiabilities) int {
+		return strings.Compare(a.Unit, b.Unit)
+	})
+	return response
+}
+
+// CreateLiabilityReport commits to the blind signatures and the spent proofs
+// of every keyset and signs the roots with the mint key, when the signer can
+// sign messages. The leaves are streamed from the database and copied there
+// with the report, in one snapshot, so wallets can ask for proofs of their
+// own outputs and proofs.
+func (m *Mint) CreateLiabilityReport(ctx context.Context) (cashu.LiabilityReport, error) {
+	keysets, err := m.Signer.GetKeysets()
+	if err != nil {
+		return cashu.LiabilityReport{}, fmt.Errorf("m.Signer.GetKeysets(). %w", err)
+	}
+	slices.SortFunc(keysets.Keysets, func(a, b cashu.BasicKeysetResponse) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	tx, err := m.MintDB.GetLiabilitiesTx(ctx)
+	if err != nil {
+		return cashu.LiabilityReport{}, fmt.Errorf("m.MintDB.GetLiabilitiesTx(ctx). %w", err)
+	}
+	defer func() {
+		rollbackErr := m.MintDB.Rollback(ctx, tx)
+		if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			slog.Warn("could not roll back the liability report", slog.Any("error", rollbackErr))
+		}
+	}()
+
+	report := database.LiabilityReport{
+		Keysets:   make([]database.KeysetLiabilities, 0, len(keysets.Keysets)),
+		Signature: nil,
+		Id:        0,
+		CreatedAt: time.Now().Unix(),
+	}
+	for _, keyset := range keysets.Keysets {
+		liabilities, err := m.keysetLiabilities(ctx, tx, keyset)
+		if err != nil {
+			return cashu.LiabilityReport{}, fmt.Errorf("m.keysetLiabilities(ctx, tx, keyset). %w", err)
+		}
+		report.Keysets = append(report.Keysets, liabilities)
+	}
+
+	messageSigner, ok := signer.AsMessageSigner(m.Signer)
+	if ok {
+		report.Signature, err = messageSigner.SignMessage(liabilityReportResponse(report).SignedHash())
+		if err != nil {
+			return cashu.LiabilityReport{}, fmt.Errorf("messageSigner.SignMessage(hash). %w", err)
+		}
+	} else {
+		slog.Warn("the signer can not sign the liability report, it is published without a signature")
+	}
+
+	report.Id, err = m.MintDB.SaveLiabilityReport(ctx, tx, report, liabilityReportLeavesKept)
+	if err != nil {
+		return cashu.LiabilityReport{}, fmt.Errorf("m.MintDB.SaveLiabilityReport(ctx, tx, report, kept). %w", err)
+	}
+	err = m.MintDB.Commit(ctx, tx)
+	if err != nil {
+		return cashu.LiabilityReport{}, fmt.Errorf("m.MintDB.Commit(ctx, tx). %w", err)
+	}
+	m.liabilityTrees.prune(report.Id)
+	return liabilityReportResponse(report), nil
+}
+
+// RunLiabilityReport makes a new liability report unless the signer is locked.
+func (m *Mint) RunLiabilityReport(ctx context.Context) error {
+	if m.SignerLocked() {
+		return nil
+	}
+	report, err := m.CreateLiabilityReport(ctx)
+	if err != nil {
+		return fmt.Errorf("m.CreateLiabilityReport(ctx). %w", err)
+	}
+	slog.Info("made a liability report", slog.Uint64("report", report.Id), slog.Int("keysets", len(report.Keysets)))
+	return nil
+}
+
+// LiabilityReport returns the report with id, or the latest one when id is 0.
+func (m *Mint) LiabilityReport(ctx context.Context, id uint64) (cashu.LiabilityReport, error) {
+	report, err := m.MintDB.GetLiabilityReport(ctx, id)
+	if err != nil {
+		return cashu.LiabilityReport{}, fmt.Errorf("m.MintDB.GetLiabilityReport(ctx, id). %w", err)
+	}
+	if report == nil {
+		return cashu.LiabilityReport{}, ErrLiabilityReportNotFound
+	}
+	return liabilityReportResponse(*report), nil
+}
+
+// provenTree is the tree of the issued or the redeemed leaves of a keyset in a
+// report with its leaves by their point.
+type provenTree struct {
+	byPoint map[string]database.LiabilityLeaf
+	tree    *cashu.MerkleSumTree
+}
+
+// newProvenTree checks the tree over leaves still has the root of the report.
+func newProvenTree(leaves []database.LiabilityLeaf, root []byte, count uint64) (provenTree, error) {
+	if uint64(len(leaves)) != count {
+		return provenTree{}, ErrLiabilityLeavesPruned
+	}
+	tree, err := liabilityTree(leaves)
+	if err != nil {
+		return provenTree{}, fmt.Errorf("liabilityTree(leaves). %w", err)
+	}
+	if !bytes.Equal(tree.Root().Hash, root) {
+		return provenTree{}, fmt.Errorf("the stored leaves do not have the root of the report")
+	}
+	byPoint := make(map[string]database.LiabilityLeaf, len(leaves))
+	for _, leaf := range leaves {
+		byPoint[string(leaf.Point.SerializeCompressed())] = leaf
+	}
+	return provenTree{byPoint: byPoint, tree: tree}, nil
+}
+
+// proof returns the leaf with point and its audit path, false when the point
+// is not in the tree.
+func (t provenTree) proof(point cashu.WrappedPublicKey) (database.LiabilityLeaf, []cashu.MerkleSumNode, bool, error) {
+	if point.PublicKey == nil {
+		return database.LiabilityLeaf{}, nil, false, nil
+	}
+	leaf, ok := t.byPoint[string(point.SerializeCompressed())]
+	if !ok {
+		return database.LiabilityLeaf{}, nil, false, nil
+	}
+	path, err := t.tree.InclusionProof(leaf.LeafIndex)
+	if err != nil {
+		return database.LiabilityLeaf{}, nil, false, fmt.Errorf("t.tree.InclusionProof(%d). %w", leaf.LeafIndex, err)
+	}
+	return leaf, path, true, nil
+}
+
+type liabilityTreeKey struct {
+	keysetId string
+	reportId uint64
+}
+
+// keysetTrees are the trees of a keyset in a report. loaded is closed once
+// they are built or failed with err.
+type keysetTrees struct {
+	loaded   chan struct{}
+	err      error
+	issued   provenTree
+	redeemed provenTree
+}
+
+// liabilityTreeCache builds the trees of a keyset in a report once, so an
+// inclusion proof only looks up its leaves and their paths instead of loading
+// every leaf of the keyset. Only the reports that keep their leaves are
+// cached.
+type liabilityTreeCache struct {
+	entries map[liabilityTreeKey]*keysetTrees
+	mu      sync.Mutex
+}
+
+// prune drops the trees of the reports whose leaves were pruned after
+// latestReport was saved.
+func (c *liabilityTreeCache) prune(latestReport uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneLocked(latestReport)
+}
+
+func (c *liabilityTreeCache) pruneLocked(latestReport uint64) {
+	for key := range c.entries {
+		if key.reportId+liabilityReportLeavesKept <= latestReport {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// get returns the trees of the keyset, built by load on the first call. A
+// failed load is not cached.
+func (c *liabilityTreeCache) get(key liabilityTreeKey, load func() (provenTree, provenTree, error)) (*keysetTrees, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[liabilityTreeKey]*keysetTrees)
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &keysetTrees{loaded: make(chan struct{}), err: nil, issued: provenTree{byPoint: nil, tree: nil}, redeemed: provenTree{byPoint: nil, tree: nil}}
+		c.entries[key] = entry
+		c.pruneLocked(key.reportId)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		entry.issued, entry.redeemed, entry.err = load()
+		if entry.err != nil {
+			c.mu.Lock()
+			delete(c.entries, key)
+			c.mu.Unlock()
+		}
+		close(entry.loaded)
+	}
+	<-entry.loaded
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry, nil
+}
+
+// LiabilityInclusion proves which of the outputs and spent proofs of the
+// request are counted in a liability report.
+func (m *Mint) LiabilityInclusion(ctx context.Context, request cashu.PostLiabilityInclusionRequest) (cashu.PostLiabilityInclusionResponse, error) {
+	if len(request.BlindedMessages)+len(request.Ys) > maxLiabilityInclusionItems {
+		return cashu.PostLiabilityInclusionResponse{}, ErrTooManyLiabilityItems
+	}
+	report, err := m.MintDB.GetLiabilityReport(ctx, request.ReportId)
+	if err != nil {
+		return cashu.PostLiabilityInclusionResponse{}, fmt.Errorf("m.MintDB.GetLiabilityReport(ctx, id). %w", err)
+	}
+	if report == nil {
+		return cashu.PostLiabilityInclusionResponse{}, ErrLiabilityReportNotFound
+	}
+	index := slices.IndexFunc(report.Keysets, func(keyset database.KeysetLiabilities) bool {
+		return keyset.KeysetId == request.KeysetId
+	})
+	if index < 0 {
+		return cashu.PostLiabilityInclusionResponse{}, ErrLiabilityKeysetNotInReport
+	}
+	keyset := report.Keysets[index]
+
+	trees, err := m.liabilityTrees.get(liabilityTreeKey{keysetId: keyset.KeysetId, reportId: report.Id}, func() (provenTree, provenTree, error) {
+		leaves, err := m.MintDB.GetLiabilityReportLeaves(ctx, report.Id, keyset.KeysetId)
+		if err != nil {
+			return provenTree{}, provenTree{}, fmt.Errorf("m.MintDB.GetLiabilityReportLeaves(ctx, report, keyset). %w", err)
+		}
+		issuedLeaves, redeemedLeaves := splitLiabilityLeaves(leaves)
+		issued, err := newProvenTree(issuedLeaves, keyset.IssuedRoot, keyset.IssuedCount)
+		if err != nil {
+			return provenTree{}, provenTree{}, fmt.Errorf("newProvenTree(issued). %w", err)
+		}
+		redeemed, err := newProvenTree(redeemedLeaves, keyset.RedeemedRoot, keyset.RedeemedCount)
+		if err != nil {
+			return provenTree{}, provenTree{}, fmt.Errorf("newProvenTree(redeemed). %w", err)
+		}
+		return issued, redeemed, nil
+	})
+	if err != nil {
+		return cashu.PostLiabilityInclusionResponse{}, fmt.Errorf("m.liabilityTrees.get(key, load). %w", err)
+	}
+
+	response := cashu.PostLiabilityInclusionResponse{
+		Issued:   make([]cashu.IssuedLiabilityProof, 0),
+		Redeemed: make([]cashu.RedeemedLiabilityProof, 0),
+		KeysetId: keyset.KeysetId,
+		ReportId: report.Id,
+	}
+	for _, B_ := range request.BlindedMessages {
+		leaf, path, ok, err := trees.issued.proof(B_)
+		if err != nil {
+			return cashu.PostLiabilityInclusionResponse{}, fmt.Errorf("trees.issued.proof(B_). %w", err)
+		}
+		if ok {
+			response.Issued = append(response.Issued, cashu.IssuedLiabilityProof{B_: leaf.Point, C_: leaf.Signature, AuditPath: path, Amount: leaf.Amount, LeafIndex: leaf.LeafIndex})
+		}
+	}
+	for _, Y := range request.Ys {
+		leaf, path, ok, err := trees.redeemed.proof(Y)
+		if err != nil {
+			return cashu.PostLiabilityInclusionResponse{}, fmt.Errorf("trees.redeemed.proof(Y). %w", err)
+		}
+		if ok {
+			response.Redeemed = append(response.Redeemed, cashu.RedeemedLiabilityProof{Y: leaf.Point, AuditPath: path, Amount: leaf.Amount, LeafIndex: leaf.LeafIndex})
+		}
+	}
+	return response, nil
+}
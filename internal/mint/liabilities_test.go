@@ -0,0 +1,123 @@
+package mint
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+func TestLiabilityReportProvesOutputsAndProofs(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
+	signer, err := localsigner.SetupLocalSigner(&db)
+	if err != nil {
+		t.Fatalf("localsigner.SetupLocalSigner(&db) %+v", err)
+	}
+	pubkey, err := signer.GetSignerPubkey()
+	if err != nil {
+		t.Fatalf("signer.GetSignerPubkey() %+v", err)
+	}
+	mint := Mint{MintDB: &db, Signer: &signer, MintPubkey: pubkey} //nolint:exhaustruct
+	keysets, err := signer.GetKeysets()
+	if err != nil {
+		t.Fatalf("signer.GetKeysets() %+v", err)
+	}
+	keysetId := keysets.Keysets[0].Id
+
+	B_ := cashu.WrappedPublicKey{PublicKey: generateKey(t)}
+	C_ := cashu.WrappedPublicKey{PublicKey: generateKey(t)}
+	spentY := cashu.WrappedPublicKey{PublicKey: generateKey(t)}
+	archivedY := cashu.WrappedPublicKey{PublicKey: generateKey(t)}
+	db.RecoverSigDB = []cashu.RecoverSigDB{
+		{Id: keysetId, Amount: 8, B_: B_, C_: C_}, //nolint:exhaustruct
+		{Id: keysetId, Amount: 4, B_: cashu.WrappedPublicKey{PublicKey: generateKey(t)}, C_: cashu.WrappedPublicKey{PublicKey: generateKey(t)}}, //nolint:exhaustruct
+		{Id: keysetId, Amount: 2, B_: cashu.WrappedPublicKey{PublicKey: generateKey(t)}, C_: cashu.WrappedPublicKey{PublicKey: generateKey(t)}}, //nolint:exhaustruct
+	}
+	db.Proofs = cashu.Proofs{
+		{Id: keysetId, Amount: 4, State: cashu.PROOF_SPENT, Y: spentY},                                              //nolint:exhaustruct
+		{Id: keysetId, Amount: 2, State: cashu.PROOF_PENDING, Y: cashu.WrappedPublicKey{PublicKey: generateKey(t)}}, //nolint:exhaustruct
+	}
+	db.ArchivedProofs = cashu.Proofs{{Id: keysetId, Amount: 1, State: cashu.PROOF_SPENT, Y: archivedY}} //nolint:exhaustruct
+
+	report, err := mint.CreateLiabilityReport(t.Context())
+	if err != nil {
+		t.Fatalf("mint.CreateLiabilityReport(ctx) %+v", err)
+	}
+	err = report.Verify(mint.MintPubkey)
+	if err != nil {
+		t.Fatalf("report.Verify(mint.MintPubkey) %+v", err)
+	}
+	keyset := report.Keysets[0]
+	if keyset.Id != keysetId || keyset.Issued.Sum != 14 || keyset.Redeemed.Sum != 5 || keyset.IssuedCount != 3 || keyset.RedeemedCount != 2 {
+		t.Fatalf("unexpected liabilities of the keyset %+v", keyset)
+	}
+	if len(report.Units) != 1 || report.Units[0].Outstanding != 9 {
+		t.Errorf("expected 9 sat outstanding, got %+v", report.Units)
+	}
+
+	unknownY := cashu.WrappedPublicKey{PublicKey: generateKey(t)}
+	request := cashu.PostLiabilityInclusionRequest{
+		BlindedMessages: []cashu.WrappedPublicKey{B_},
+		Ys:              []cashu.WrappedPublicKey{spentY, archivedY, unknownY},
+		KeysetId:        keysetId,
+		ReportId:        0,
+	}
+	inclusion, err := mint.LiabilityInclusion(t.Context(), request)
+	if err != nil {
+		t.Fatalf("mint.LiabilityInclusion(ctx, request) %+v", err)
+	}
+	if len(inclusion.Issued) != 1 || len(inclusion.Redeemed) != 2 {
+		t.Fatalf("expected proofs of the output and the two spent proofs, got %+v", inclusion)
+	}
+	if inclusion.Issued[0].C_ != C_ {
+		t.Errorf("expected the blind signature of the output")
+	}
+	err = inclusion.Issued[0].Verify(keyset)
+	if err != nil {
+		t.Errorf("inclusion.Issued[0].Verify(keyset) %+v", err)
+	}
+	for _, proof := range inclusion.Redeemed {
+		err = proof.Verify(keyset)
+		if err != nil {
+			t.Errorf("proof.Verify(keyset) %+v", err)
+		}
+	}
+
+	// the tree is built once, the next proofs do not load the leaves again
+	db.LiabilityLeaves[report.Id] = nil
+	inclusion, err = mint.LiabilityInclusion(t.Context(), request)
+	if err != nil || len(inclusion.Issued) != 1 || len(inclusion.Redeemed) != 2 {
+		t.Fatalf("expected the proofs from the cached tree, got %+v, %v", inclusion, err)
+	}
+
+	// only the latest reports keep their leaves
+	for range liabilityReportLeavesKept {
+		_, err = mint.CreateLiabilityReport(t.Context())
+		if err != nil {
+			t.Fatalf("mint.CreateLiabilityReport(ctx) %+v", err)
+		}
+	}
+	request.ReportId = report.Id
+	_, err = mint.LiabilityInclusion(t.Context(), request)
+	if !errors.Is(err, ErrLiabilityLeavesPruned) {
+		t.Errorf("expected ErrLiabilityLeavesPruned, got %v", err)
+	}
+	old, err := mint.LiabilityReport(t.Context(), report.Id)
+	if err != nil {
+		t.Fatalf("mint.LiabilityReport(ctx, id) %+v", err)
+	}
+	err = old.Verify(mint.MintPubkey)
+	if err != nil {
+		t.Errorf("the old report should still verify %+v", err)
+	}
+
+	request.KeysetId = "unknown"
+	request.ReportId = 0
+	_, err = mint.LiabilityInclusion(t.Context(), request)
+	if !errors.Is(err, ErrLiabilityKeysetNotInReport) {
+		t.Errorf("expected ErrLiabilityKeysetNotInReport, got %v", err)
+	}
+}
@@ -11,8 +11,11 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/lescuer97/nutmix/api/cashu"
 	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/metrics"
+	"github.com/lescuer97/nutmix/internal/tracing"
 	"github.com/lescuer97/nutmix/internal/utils"
 	"github.com/lightningnetwork/lnd/zpay32"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (m *Mint) CreateMeltQuote(ctx context.Context, meltRequest cashu.PostMeltQuoteBolt11Request, method METHOD) (cashu.MeltRequestDB, error) {
@@ -48,15 +51,15 @@ func (m *Mint) createAndStoreBolt11MeltQuote(ctx context.Context, meltRequest ca
 		return cashu.MeltRequestDB{}, fmt.Errorf("utils.RandomHash(). %w", err)
 	}
 
-	expireTime := cashu.ExpiryTimeMinUnit(15)
+	expireTime := utils.QuoteExpiry(m.Config().MELT_QUOTE_TTL, time.Now())
 	now := time.Now().Unix()
 	queryFee := uint64(0)
 	checkingId := quoteId
 	amountToSend := requestData.Amount
 	if !requestData.Internal {
-		feesResponse, err := m.LightningBackend.QueryFees(meltRequest.Request, requestData.invoice, requestData.Internal, requestData.Amount)
+		feesResponse, err := m.lightningBackend(ctx).QueryFees(meltRequest.Request, requestData.invoice, requestData.Internal, requestData.Amount)
 		if err != nil {
-			return cashu.MeltRequestDB{}, fmt.Errorf("m.LightningBackend.QueryFees. %w", err)
+			return cashu.MeltRequestDB{}, fmt.Errorf("m.LightningBackend().QueryFees. %w", err)
 		}
 		checkingId = feesResponse.CheckingId
 		queryFee = feesResponse.Fees.Amount
@@ -86,7 +89,7 @@ func (m *Mint) createAndStoreBolt11MeltQuote(ctx context.Context, meltRequest ca
 		rollbackErr := m.MintDB.Rollback(ctx, tx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -113,15 +116,16 @@ type bolt11MeltReqData struct {
 }
 
 func (m *Mint) validateBolt11MeltQuoteRequest(ctx context.Context, meltRequest cashu.PostMeltQuoteBolt11Request) (bolt11MeltReqData, error) {
+	config := m.Config()
 	unit, err := cashu.UnitFromString(meltRequest.Unit)
 	if err != nil {
 		return bolt11MeltReqData{}, errors.Join(err, cashu.ErrUnitNotSupported)
 	}
-	supported := m.LightningBackend.VerifyUnitSupport(unit)
+	supported := m.LightningBackend().VerifyUnitSupport(unit)
 	if !supported {
 		return bolt11MeltReqData{}, errors.Join(err, cashu.ErrUnitNotSupported)
 	}
-	invoice, err := zpay32.Decode(meltRequest.Request, m.LightningBackend.GetNetwork())
+	invoice, err := zpay32.Decode(meltRequest.Request, m.LightningBackend().GetNetwork())
 	if err != nil {
 		return bolt11MeltReqData{}, fmt.Errorf(" zpay32.Decode. %w ", err)
 	}
@@ -130,8 +134,8 @@ func (m *Mint) validateBolt11MeltQuoteRequest(ctx context.Context, meltRequest c
 		return bolt11MeltReqData{}, cashu.ErrAmountlessInvoiceNotSupported
 	}
 
-	if m.Config.PEG_OUT_LIMIT_SATS != nil {
-		if int64(*invoice.MilliSat) > (int64(*m.Config.PEG_OUT_LIMIT_SATS) * 1000) {
+	if config.PEG_OUT_LIMIT_SATS != nil {
+		if int64(*invoice.MilliSat) > (int64(*config.PEG_OUT_LIMIT_SATS) * 1000) {
 			return bolt11MeltReqData{}, cashu.ErrAmountOutsideLimit
 		}
 	}
@@ -151,7 +155,7 @@ func (m *Mint) validateBolt11MeltQuoteRequest(ctx context.Context, meltRequest c
 		}
 		isMpp = true
 		cashuAmount = mppAmount
-		if !m.LightningBackend.ActiveMPP() {
+		if !m.LightningBackend().ActiveMPP() {
 			// TODO: Add error code multi path payments being not allowed
 			return bolt11MeltReqData{}, fmt.Errorf("mpp not supported")
 		}
@@ -222,7 +226,7 @@ func (m *Mint) RefreshMeltQuoteState(ctx context.Context, quoteId string) (cashu
 		rollbackErr := m.MintDB.Rollback(ctx, initialTx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -251,15 +255,15 @@ func (m *Mint) RefreshMeltQuoteState(ctx context.Context, quoteId string) (cashu
 			return quote, fmt.Errorf("m.VerifyUnitSupport(quote.Unit). %w", err)
 		}
 
-		invoice, err := zpay32.Decode(quote.Request, m.LightningBackend.GetNetwork())
+		invoice, err := zpay32.Decode(quote.Request, m.LightningBackend().GetNetwork())
 
 		if err != nil {
-			return quote, fmt.Errorf("zpay32.Decode(quote.Request, m.LightningBackend.GetNetwork()). %w", err)
+			return quote, fmt.Errorf("zpay32.Decode(quote.Request, m.LightningBackend().GetNetwork()). %w", err)
 		}
 
-		status, preimage, feeAmount, err := m.LightningBackend.CheckPayed(quote.Quote, invoice, quote.CheckingId)
+		status, preimage, feeAmount, err := m.lightningBackend(ctx).CheckPayed(quote.Quote, invoice, quote.CheckingId)
 		if err != nil {
-			return quote, fmt.Errorf("m.LightningBackend.CheckPayed(quote.Quote). %w", err)
+			return quote, fmt.Errorf("m.LightningBackend().CheckPayed(quote.Quote). %w", err)
 		}
 
 		if status == lightning.SETTLED {
@@ -290,7 +294,7 @@ func (m *Mint) RefreshMeltQuoteState(ctx context.Context, quoteId string) (cashu
 				rollbackErr := m.MintDB.Rollback(ctx, settleTx)
 				if rollbackErr != nil {
 					if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-						slog.Warn("rollback error", slog.Any("error", rollbackErr))
+						slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 					}
 				}
 			}()
@@ -314,7 +318,7 @@ func (m *Mint) RefreshMeltQuoteState(ctx context.Context, quoteId string) (cashu
 				for _, v := range changeMessages {
 					blindMessages = append(blindMessages, cashu.BlindedMessage{Id: v.Id, B_: v.B_, Witness: "", Amount: 0})
 				}
-				sigs, err := m.GetChangeOutput(blindMessages, overpaidFees, quote.Unit)
+				sigs, err := m.GetChangeOutput(ctx, blindMessages, overpaidFees, quote.Unit)
 				if err != nil {
 					return quote, fmt.Errorf("m.GetChangeOutput(changeMessages, quote.Unit ). %w", err)
 				}
@@ -359,7 +363,7 @@ func (m *Mint) RefreshMeltQuoteState(ctx context.Context, quoteId string) (cashu
 				rollbackErr := m.MintDB.Rollback(ctx, failedLnTx)
 				if rollbackErr != nil {
 					if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-						slog.Warn("rollback error", slog.Any("error", rollbackErr))
+						slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 					}
 				}
 			}()
@@ -415,17 +419,17 @@ type bolt11MeltData struct {
 	Unit         cashu.Unit
 }
 
-func (m *Mint) validateBolt11MeltInputs(meltRequest cashu.PostMeltBolt11Request, quote cashu.MeltRequestDB) (bolt11MeltData, error) {
+func (m *Mint) validateBolt11MeltInputs(ctx context.Context, meltRequest cashu.PostMeltBolt11Request, quote cashu.MeltRequestDB) (bolt11MeltData, error) {
 	if len(meltRequest.Inputs) == 0 {
 		return bolt11MeltData{}, fmt.Errorf("inputs or outputs are empty")
 	}
 	if quote.State == cashu.PENDING {
-		slog.Warn("Quote is pending")
+		slog.WarnContext(ctx, "Quote is pending")
 		return bolt11MeltData{}, cashu.ErrQuoteIsPending
 	}
 
 	if quote.Melted {
-		slog.Info("Quote already melted", slog.String(utils.LogExtraInfo, quote.Quote))
+		slog.InfoContext(ctx, "Quote already melted", slog.String(utils.LogExtraInfo, quote.Quote))
 		return bolt11MeltData{}, cashu.ErrMeltAlreadyPaid
 	}
 
@@ -443,7 +447,7 @@ func (m *Mint) validateBolt11MeltInputs(meltRequest cashu.PostMeltBolt11Request,
 	}
 
 	if proofsAmount < (quote.Amount + quote.FeeReserve + uint64(fee)) {
-		slog.Info(fmt.Sprintf("Not enought proofs to expend. Needs: %v", quote.Amount))
+		slog.InfoContext(ctx, fmt.Sprintf("Not enought proofs to expend. Needs: %v", quote.Amount))
 		return bolt11MeltData{}, fmt.Errorf("%w", cashu.ErrNotEnoughtProofs)
 	}
 
@@ -470,7 +474,7 @@ func (m *Mint) validateBolt11MeltInputs(meltRequest cashu.PostMeltBolt11Request,
 		}
 	}
 	// validate if the proofs are correctly signed
-	err = m.VerifyProofsBDHKE(meltRequest.Inputs)
+	err = m.VerifyProofsBDHKE(ctx, meltRequest.Inputs)
 	if err != nil {
 		return bolt11MeltData{}, fmt.Errorf("m.VerifyProofsBDHKE(proofs). %w", err)
 	}
@@ -510,7 +514,7 @@ func (m *Mint) reserveMeltInputsAndMarkPending(ctx context.Context, meltRequest
 		if err != nil {
 			rollbackErr := m.MintDB.Rollback(ctx, sizeCheckTx)
 			if rollbackErr != nil {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -519,12 +523,12 @@ func (m *Mint) reserveMeltInputsAndMarkPending(ctx context.Context, meltRequest
 		return cashu.MeltRequestDB{}, fmt.Errorf("m.MintDB.GetMeltRequestById(preparationTx, meltRequest.Quote): %w", err)
 	}
 	if quote.State == cashu.PENDING {
-		slog.Warn("Quote is pending")
+		slog.WarnContext(ctx, "Quote is pending")
 		return cashu.MeltRequestDB{}, cashu.ErrQuoteIsPending
 	}
 
 	if quote.Melted {
-		slog.Info("Quote already melted", slog.String(utils.LogExtraInfo, quote.Quote))
+		slog.InfoContext(ctx, "Quote already melted", slog.String(utils.LogExtraInfo, quote.Quote))
 		return cashu.MeltRequestDB{}, cashu.ErrMeltAlreadyPaid
 	}
 
@@ -569,10 +573,10 @@ func (m *Mint) reserveMeltInputsAndMarkPending(ctx context.Context, meltRequest
 
 func (m *Mint) attemptBolt11MeltPayment(ctx context.Context, meltRequest cashu.PostMeltBolt11Request, quote cashu.MeltRequestDB) (cashu.MeltRequestDB, cashu.Amount, error) {
 	// Commit all blind messages and proofs as pending before going over the network
-	invoice, err := zpay32.Decode(quote.Request, m.LightningBackend.GetNetwork())
+	invoice, err := zpay32.Decode(quote.Request, m.LightningBackend().GetNetwork())
 	if err != nil {
-		slog.Info(fmt.Errorf("zpay32.Decode: %w", err).Error())
-		return cashu.MeltRequestDB{}, cashu.Amount{}, fmt.Errorf("zpay32.Decode(quote.Request, m.LightningBackend.GetNetwork()) %w", err)
+		slog.InfoContext(ctx, fmt.Errorf("zpay32.Decode: %w", err).Error())
+		return cashu.MeltRequestDB{}, cashu.Amount{}, fmt.Errorf("zpay32.Decode(quote.Request, m.LightningBackend().GetNetwork()) %w", err)
 	}
 
 	unit, err := cashu.UnitFromString(quote.Unit)
@@ -584,7 +588,7 @@ func (m *Mint) attemptBolt11MeltPayment(ctx context.Context, meltRequest cashu.P
 	if quote.State != cashu.PAID {
 		// Convert feeReserve to Amount for the lightning backend
 		feeReserveAmount := cashu.NewAmount(unit, quote.FeeReserve)
-		payment, err := m.LightningBackend.PayInvoice(quote, invoice, feeReserveAmount, quote.Mpp, amount)
+		payment, err := m.lightningBackend(ctx).PayInvoice(quote, invoice, feeReserveAmount, quote.Mpp, amount)
 		// Hardened error handling
 		if err != nil || payment.PaymentState == lightning.FAILED || payment.PaymentState == lightning.UNKNOWN || payment.PaymentState == lightning.PENDING {
 			lnTx, err := m.MintDB.GetTx(ctx)
@@ -595,14 +599,14 @@ func (m *Mint) attemptBolt11MeltPayment(ctx context.Context, meltRequest cashu.P
 				rollbackErr := m.MintDB.Rollback(ctx, lnTx)
 				if rollbackErr != nil {
 					if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-						slog.Warn("rollback error", slog.Any("error", rollbackErr))
+						slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 					}
 				}
 			}()
 
-			slog.Warn("Possible payment failure", slog.String(utils.LogExtraInfo, fmt.Sprintf("error:  %+v. payment: %+v", err, payment)))
+			slog.WarnContext(ctx, "Possible payment failure", slog.String(utils.LogExtraInfo, fmt.Sprintf("error:  %+v. payment: %+v", err, payment)))
 
-			slog.Debug("changing checking Id to payment checking Id", slog.String("quote.CheckingId", quote.CheckingId), slog.String("payment.CheckingId", payment.CheckingId))
+			slog.DebugContext(ctx, "changing checking Id to payment checking Id", slog.String("quote.CheckingId", quote.CheckingId), slog.String("payment.CheckingId", payment.CheckingId))
 			quote.CheckingId = payment.CheckingId
 			err = m.MintDB.ChangeCheckingId(lnTx, quote.Quote, quote.CheckingId)
 			if err != nil {
@@ -614,15 +618,15 @@ func (m *Mint) attemptBolt11MeltPayment(ctx context.Context, meltRequest cashu.P
 			}
 
 			// if exception of lightning payment says fail do a payment status recheck.
-			status, _, fee_paid, err := m.LightningBackend.CheckPayed(quote.Quote, invoice, quote.CheckingId)
+			status, _, fee_paid, err := m.lightningBackend(ctx).CheckPayed(quote.Quote, invoice, quote.CheckingId)
 
 			// if error on checking payement we will save as pending and returns status
 			if err != nil {
-				slog.Warn("Something happened while paying the invoice. Keeping proofs and quote as pending ")
-				return cashu.MeltRequestDB{}, cashu.Amount{}, fmt.Errorf("m.LightningBackend.CheckPayed(quote.Quote) %w", err)
+				slog.WarnContext(ctx, "Something happened while paying the invoice. Keeping proofs and quote as pending ")
+				return cashu.MeltRequestDB{}, cashu.Amount{}, fmt.Errorf("m.LightningBackend().CheckPayed(quote.Quote) %w", err)
 			}
 
-			slog.Info("after check paid verification")
+			slog.InfoContext(ctx, "after check paid verification")
 			// Convert fee Amount to quote's unit for storage
 			convertErr := fee_paid.To(unit)
 			if convertErr != nil {
@@ -638,7 +642,7 @@ func (m *Mint) attemptBolt11MeltPayment(ctx context.Context, meltRequest cashu.P
 				rollbackErr := m.MintDB.Rollback(ctx, lnStatusTx)
 				if rollbackErr != nil {
 					if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-						slog.Warn("rollback error", slog.Any("error", rollbackErr))
+						slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 					}
 				}
 			}()
@@ -707,11 +711,15 @@ func (m *Mint) finalizePaidBolt11Melt(ctx context.Context, meltData bolt11MeltDa
 	var blindSigs []cashu.BlindSignature
 	if meltData.AmountProofs.Amount > totalExpent && len(meltRequest.Outputs) > 0 {
 		overpaidFees := meltData.AmountProofs.Amount - totalExpent
-		change := utils.GetMessagesForChange(overpaidFees, meltRequest.Outputs)
+		denominations, err := m.keysetAmounts(meltRequest.Outputs[0].Id)
+		if err != nil {
+			return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, nil, fmt.Errorf("m.keysetAmounts(meltRequest.Outputs[0].Id) %w", err)
+		}
+		change := utils.GetMessagesForChange(overpaidFees, meltRequest.Outputs, denominations)
 
-		blindSignaturesDB, recoverySigsDb, err := m.Signer.SignBlindMessages(change)
+		blindSignaturesDB, recoverySigsDb, err := m.Signer.SignBlindMessages(ctx, change)
 		if err != nil {
-			return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, nil, fmt.Errorf("m.Signer.SignBlindMessages(change) %w", err)
+			return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, nil, fmt.Errorf("m.Signer.SignBlindMessages(ctx, change) %w", err)
 		}
 		recoverySigs = recoverySigsDb
 		blindSigs = blindSignaturesDB
@@ -725,7 +733,7 @@ func (m *Mint) finalizePaidBolt11Melt(ctx context.Context, meltData bolt11MeltDa
 		rollbackErr := m.MintDB.Rollback(ctx, paidLnxTx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -733,7 +741,7 @@ func (m *Mint) finalizePaidBolt11Melt(ctx context.Context, meltData bolt11MeltDa
 	if len(recoverySigs) > 0 {
 		err = m.MintDB.SaveRestoreSigs(paidLnxTx, recoverySigs)
 		if err != nil {
-			slog.Error("recoverySigsDb", slog.String(utils.LogExtraInfo, fmt.Sprintf("%+v", recoverySigs)))
+			slog.ErrorContext(ctx, "recoverySigsDb", slog.String(utils.LogExtraInfo, fmt.Sprintf("%+v", recoverySigs)))
 			return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, nil, fmt.Errorf("m.MintDB.SaveRestoreSigs(paidLnxTx, recoverySigsDb) %w", err)
 		}
 
@@ -757,7 +765,7 @@ func (m *Mint) finalizePaidBolt11Melt(ctx context.Context, meltData bolt11MeltDa
 	meltRequest.Inputs.SetProofsState(cashu.PROOF_SPENT)
 	err = m.MintDB.SetProofsState(paidLnxTx, meltRequest.Inputs, cashu.PROOF_SPENT)
 	if err != nil {
-		slog.Error("Proofs", slog.Any("proofs", meltRequest.Inputs))
+		slog.ErrorContext(ctx, "Proofs", slog.Any("proofs", meltRequest.Inputs))
 		return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, nil, fmt.Errorf("m.MintDB.SetProofsState(tx, meltRequest.Inputs, cashu.PROOF_SPENT) %w", err)
 	}
 
@@ -767,15 +775,33 @@ func (m *Mint) finalizePaidBolt11Melt(ctx context.Context, meltData bolt11MeltDa
 	}
 	return quote, response, meltRequest.Inputs, nil
 }
-func (m *Mint) bolt11Melt(ctx context.Context, meltRequest cashu.PostMeltBolt11Request) (cashu.MeltRequestDB, cashu.PostMeltQuoteBolt11Response, error) {
-	quote, err := m.RefreshMeltQuoteState(ctx, meltRequest.Quote)
+func (m *Mint) bolt11Melt(ctx context.Context, meltRequest cashu.PostMeltBolt11Request) (quote cashu.MeltRequestDB, response cashu.PostMeltQuoteBolt11Response, err error) {
+	start := time.Now()
+	var unit string
+	// every return is counted, so failed and pending melts show in the success rate
+	defer func() {
+		outcome := metrics.MeltFailed
+		if err == nil {
+			switch quote.State {
+			case cashu.PAID:
+				outcome = metrics.MeltPaid
+				metrics.RecordOperation(metrics.OperationMelt, quote.Unit, proofsAmountByKeyset(meltRequest.Inputs))
+			case cashu.PENDING:
+				outcome = metrics.MeltPending
+			}
+		}
+		metrics.ObserveMelt(unit, outcome, start)
+	}()
+
+	quote, err = m.RefreshMeltQuoteState(ctx, meltRequest.Quote)
 	if err != nil {
 		return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, fmt.Errorf("m.RefreshMeltQuoteState(ctx, quoteId): %w", err)
 	}
+	unit = quote.Unit
 
-	meltRequestData, err := m.validateBolt11MeltInputs(meltRequest, quote)
+	meltRequestData, err := m.validateBolt11MeltInputs(ctx, meltRequest, quote)
 	if err != nil {
-		return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, fmt.Errorf("m.validateBolt11MeltInputs(meltRequest, quote): %w", err)
+		return cashu.MeltRequestDB{}, cashu.PostMeltQuoteBolt11Response{}, fmt.Errorf("m.validateBolt11MeltInputs(ctx, meltRequest, quote): %w", err)
 	}
 	quote, err = m.reserveMeltInputsAndMarkPending(ctx, meltRequest)
 	if err != nil {
@@ -805,6 +831,13 @@ func (m *Mint) bolt11Melt(ctx context.Context, meltRequest cashu.PostMeltBolt11R
 }
 
 func (m *Mint) ExecuteMelt(ctx context.Context, meltRequest cashu.PostMeltBolt11Request, method METHOD) (cashu.PostMeltQuoteBolt11Response, error) {
+	ctx, span := tracing.Start(ctx, "Mint.ExecuteMelt", attribute.String("quote", meltRequest.Quote), attribute.Int("inputs", len(meltRequest.Inputs)))
+	response, err := m.executeMelt(ctx, meltRequest, method)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (m *Mint) executeMelt(ctx context.Context, meltRequest cashu.PostMeltBolt11Request, method METHOD) (cashu.PostMeltQuoteBolt11Response, error) {
 	switch method {
 	case Bolt11:
 		_, response, err := m.bolt11Melt(ctx, meltRequest)
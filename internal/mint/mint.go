@@ -2,10 +2,12 @@ package mint
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -17,14 +19,81 @@ import (
 )
 
 type Mint struct {
-	LightningBackend        lightning.LightningBackend
 	MintDB                  database.MintDB
 	Signer                  signer.Signer
-	OICDClient              *oidc.Provider
 	Observer                *Observer
 	NostrNotificationConfig *utils.NostrNotificationConfig
+	state                   atomic.Pointer[mintState]
 	MintPubkey              string
-	Config                  utils.Config
+	// configMu keeps config changes from overwriting each other, readers go
+	// through state and never wait for it
+	configMu sync.Mutex
+	// trees of the latest liability reports, for the inclusion proofs
+	liabilityTrees liabilityTreeCache
+}
+
+// mintState is what a config change swaps at once. It is never modified after
+// being stored, every change stores a new one.
+type mintState struct {
+	lightningBackend lightning.LightningBackend
+	oidcClient       *oidc.Provider
+	config           utils.Config
+}
+
+func (m *Mint) loadState() mintState {
+	if state := m.state.Load(); state != nil {
+		return *state
+	}
+	var empty mintState
+	return empty
+}
+
+// updateState stores what change makes of a copy of the current state.
+func (m *Mint) updateState(change func(state *mintState)) {
+	for {
+		current := m.state.Load()
+		var next mintState
+		if current != nil {
+			next = *current
+		}
+		change(&next)
+		if m.state.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
+
+// Config returns the config the mint is running with. Changes go through
+// UpdateConfig or ChangeConfig.
+func (m *Mint) Config() utils.Config {
+	return m.loadState().config
+}
+
+// LightningBackend returns the backend built from the current config.
+func (m *Mint) LightningBackend() lightning.LightningBackend {
+	return m.loadState().lightningBackend
+}
+
+// OICDClient returns the oidc provider of the current config, nil when auth
+// is turned off.
+func (m *Mint) OICDClient() *oidc.Provider {
+	return m.loadState().oidcClient
+}
+
+// SetConfig replaces the config without validating or storing it, it is meant
+// for setting the mint up.
+func (m *Mint) SetConfig(config utils.Config) {
+	m.updateState(func(state *mintState) {
+		state.config = config
+	})
+}
+
+// SetLightningBackend replaces the backend without verifying it, it is meant
+// for setting the mint up.
+func (m *Mint) SetLightningBackend(backend lightning.LightningBackend) {
+	m.updateState(func(state *mintState) {
+		state.lightningBackend = backend
+	})
 }
 
 var (
@@ -36,6 +105,7 @@ func checkProofsAreSameUnit(proofs []cashu.Proof, keys []cashu.BasicKeysetRespon
 	units := make(map[string]bool)
 
 	seenKeys := make(map[string]cashu.BasicKeysetResponse)
+	now := time.Now()
 
 	for _, v := range keys {
 		seenKeys[v.Id] = v
@@ -46,6 +116,9 @@ func checkProofsAreSameUnit(proofs []cashu.Proof, keys []cashu.BasicKeysetRespon
 		if !exists {
 			return cashu.Sat, cashu.ErrKeysetNotKnow
 		}
+		if keysetExpired(val.FinalExpiry, now) {
+			return cashu.Sat, fmt.Errorf("%w. Keyset: %s", cashu.ErrKeysetExpired, val.Id)
+		}
 
 		units[val.Unit] = true
 		if len(units) > 1 {
@@ -93,19 +166,59 @@ func CheckChainParams(network string) (chaincfg.Params, error) {
 
 func SetUpMint(ctx context.Context, config utils.Config, nostrNotificationConfig *utils.NostrNotificationConfig, db database.MintDB, sig signer.Signer) (*Mint, error) {
 	mint := Mint{
-		Config:                  config,
 		NostrNotificationConfig: nostrNotificationConfig,
 		MintDB:                  db,
 		Signer:                  sig,
 		MintPubkey:              "",
-		LightningBackend:        nil,
-		OICDClient:              nil,
 		Observer:                nil,
+		state:                   atomic.Pointer[mintState]{},
+		configMu:                sync.Mutex{},
+		liabilityTrees:          liabilityTreeCache{entries: nil, mu: sync.Mutex{}},
 	}
+	mint.SetConfig(config)
+
+	backend, err := SetupLightningBackend(config)
+	if err != nil {
+		return &mint, fmt.Errorf("SetupLightningBackend(config) %w", err)
+	}
+	mint.SetLightningBackend(lightning.Instrument(backend, string(config.MINT_LIGHTNING_BACKEND)))
+
+	// parse mint private key and get hex value pubkey
+	pubkey, err := sig.GetSignerPubkey()
+	if err != nil {
+		return &mint, fmt.Errorf("sig.GetSignerPubkey() %w", err)
+	}
+	mint.MintPubkey = pubkey
+
+	observer := Observer{
+		Proofs:     make(map[string][]ProofWatchChannel),
+		MintQuote:  make(map[string][]MintQuoteChannel),
+		MeltQuote:  make(map[string][]MeltQuoteChannel),
+		notifier:   nil,
+		instanceId: "",
+		Mutex:      sync.Mutex{},
+	}
+	mint.Observer = &observer
 
+	if config.MINT_REQUIRE_AUTH {
+		if config.MINT_AUTH_OICD_URL == "" {
+			return nil, fmt.Errorf("there is no oidc url for stepup")
+		}
+		err = mint.SetupOidcService(ctx, config.MINT_AUTH_OICD_URL)
+		if err != nil {
+			slog.Error("Could not setup the oidc provider. This could cause problems later when trying to authenticate tokens")
+		}
+	}
+
+	return &mint, nil
+}
+
+// SetupLightningBackend connects to the lightning backend picked in config
+// for the network in config.
+func SetupLightningBackend(config utils.Config) (lightning.LightningBackend, error) {
 	chainparam, err := CheckChainParams(config.NETWORK)
 	if err != nil {
-		return &mint, fmt.Errorf("CheckChainParams(config.NETWORK) %w", err)
+		return nil, fmt.Errorf("CheckChainParams(config.NETWORK) %w", err)
 	}
 
 	switch config.MINT_LIGHTNING_BACKEND {
@@ -116,7 +229,7 @@ func SetUpMint(ctx context.Context, config utils.Config, nostrNotificationConfig
 			InvoiceFee:      0,
 		}
 
-		mint.LightningBackend = fake_wallet
+		return fake_wallet, nil
 
 	case utils.LNDGRPC:
 		lndWallet := lightning.LndGrpcWallet{
@@ -125,9 +238,9 @@ func SetUpMint(ctx context.Context, config utils.Config, nostrNotificationConfig
 
 		err := lndWallet.SetupGrpc(config.LND_GRPC_HOST, config.LND_MACAROON, config.LND_TLS_CERT)
 		if err != nil {
-			return &mint, fmt.Errorf("lndWallet.SetupGrpc %w", err)
+			return nil, fmt.Errorf("lndWallet.SetupGrpc %w", err)
 		}
-		mint.LightningBackend = lndWallet
+		return lndWallet, nil
 	case utils.LNBITS: //nolint:staticcheck // LNBITS remains supported until its planned removal in v0.8.0.
 		slog.Warn("LNBITS backend is deprecated and will be removed in v0.8.0")
 
@@ -136,7 +249,7 @@ func SetUpMint(ctx context.Context, config utils.Config, nostrNotificationConfig
 			Endpoint: config.MINT_LNBITS_ENDPOINT,
 			Key:      config.MINT_LNBITS_KEY,
 		}
-		mint.LightningBackend = lnbitsWallet
+		return lnbitsWallet, nil
 	case utils.CLNGRPC:
 		clnWallet := lightning.CLNGRPCWallet{
 			Network: chainparam,
@@ -144,9 +257,9 @@ func SetUpMint(ctx context.Context, config utils.Config, nostrNotificationConfig
 
 		err := clnWallet.SetupGrpc(config.CLN_GRPC_HOST, config.CLN_CA_CERT, config.CLN_CLIENT_CERT, config.CLN_CLIENT_KEY, config.CLN_MACAROON)
 		if err != nil {
-			return &mint, fmt.Errorf("lndWallet.SetupGrpc %w", err)
+			return nil, fmt.Errorf("lndWallet.SetupGrpc %w", err)
 		}
-		mint.LightningBackend = clnWallet
+		return clnWallet, nil
 	case utils.Strike: //nolint:staticcheck // Strike remains supported until its planned removal in v0.7.0.
 		strikeWallet := lightning.Strike{
 			Network: chainparam,
@@ -154,38 +267,28 @@ func SetUpMint(ctx context.Context, config utils.Config, nostrNotificationConfig
 
 		err := strikeWallet.Setup(config.STRIKE_KEY, config.STRIKE_ENDPOINT)
 		if err != nil {
-			return &mint, fmt.Errorf("lndWallet.SetupGrpc %w", err)
+			return nil, fmt.Errorf("lndWallet.SetupGrpc %w", err)
 		}
-		mint.LightningBackend = strikeWallet
+		return strikeWallet, nil
 
 	default:
-		log.Fatalf("Unknown lightning backend: %s", config.MINT_LIGHTNING_BACKEND)
+		return nil, fmt.Errorf("unknown lightning backend: %s", config.MINT_LIGHTNING_BACKEND)
 	}
+}
 
-	// parse mint private key and get hex value pubkey
-	pubkey, err := sig.GetSignerPubkey()
-	if err != nil {
-		return &mint, fmt.Errorf("sig.GetSignerPubkey() %w", err)
-	}
-	mint.MintPubkey = pubkey
+var ErrSignerNotLockable = errors.New("the signer does not use a keystore")
 
-	observer := Observer{
-		Proofs:    make(map[string][]ProofWatchChannel),
-		MintQuote: make(map[string][]MintQuoteChannel),
-		MeltQuote: make(map[string][]MeltQuoteChannel),
-		Mutex:     sync.Mutex{},
-	}
-	mint.Observer = &observer
+// SignerLocked reports if the signer is waiting for its keystore passphrase.
+func (m *Mint) SignerLocked() bool {
+	locker, ok := signer.AsLocker(m.Signer)
+	return ok && locker.Locked()
+}
 
-	if config.MINT_REQUIRE_AUTH {
-		if config.MINT_AUTH_OICD_URL == "" {
-			return nil, fmt.Errorf("there is no oidc url for stepup")
-		}
-		err = mint.SetupOidcService(ctx, config.MINT_AUTH_OICD_URL)
-		if err != nil {
-			slog.Error("Could not setup the oidc provider. This could cause problems later when trying to authenticate tokens")
-		}
+// UnlockSigner unlocks a memory signer started with an encrypted keystore.
+func (m *Mint) UnlockSigner(passphrase string) error {
+	locker, ok := signer.AsLocker(m.Signer)
+	if !ok {
+		return ErrSignerNotLockable
 	}
-
-	return &mint, nil
+	return locker.Unlock(passphrase)
 }
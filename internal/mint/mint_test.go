@@ -255,13 +255,13 @@ func TestPendingQuotesAndProofsWithPostgresAndMockLNFail(t *testing.T) {
 
 	ln := lightning.FakeWallet{
 		UnpurposeErrors: nil,
-		Network:         *mint.LightningBackend.GetNetwork(),
+		Network:         *mint.LightningBackend().GetNetwork(),
 		InvoiceFee:      0,
 	}
 	ln.UnpurposeErrors = []lightning.FakeWalletError{
 		lightning.FailQueryFailed,
 	}
-	mint.LightningBackend = ln
+	mint.SetLightningBackend(ln)
 
 	err := SetupDataOnDB(mint)
 	if err != nil {
@@ -328,13 +328,13 @@ func TestPendingQuotesAndProofsWithPostgresAndMockLNPending(t *testing.T) {
 
 	ln := lightning.FakeWallet{
 		UnpurposeErrors: nil,
-		Network:         *mint.LightningBackend.GetNetwork(),
+		Network:         *mint.LightningBackend().GetNetwork(),
 		InvoiceFee:      0,
 	}
 	ln.UnpurposeErrors = []lightning.FakeWalletError{
 		lightning.FailQueryPending,
 	}
-	mint.LightningBackend = ln
+	mint.SetLightningBackend(ln)
 
 	err := SetupDataOnDB(mint)
 	if err != nil {
@@ -10,8 +10,11 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/lescuer97/nutmix/api/cashu"
 	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/metrics"
+	"github.com/lescuer97/nutmix/internal/tracing"
 	"github.com/lescuer97/nutmix/internal/utils"
 	"github.com/lightningnetwork/lnd/zpay32"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (m *Mint) CreateMintQuote(ctx context.Context, request cashu.PostMintQuoteBolt11Request, method METHOD) (cashu.PostMintQuoteBolt11Response, error) {
@@ -21,7 +24,7 @@ func (m *Mint) CreateMintQuote(ctx context.Context, request cashu.PostMintQuoteB
 	}
 	switch method {
 	case Bolt11:
-		supported := m.LightningBackend.VerifyUnitSupport(unit)
+		supported := m.LightningBackend().VerifyUnitSupport(unit)
 		if !supported {
 			return cashu.PostMintQuoteBolt11Response{}, errors.Join(err, cashu.ErrUnitNotSupported)
 		}
@@ -36,16 +39,17 @@ func (m *Mint) CreateMintQuote(ctx context.Context, request cashu.PostMintQuoteB
 	}
 }
 func (m *Mint) validateMintConfiguration(request cashu.PostMintQuoteBolt11Request) (cashu.Unit, error) {
+	config := m.Config()
 	if request.Amount == 0 {
 		return cashu.Sat, fmt.Errorf("amount empty")
 	}
 
-	if m.Config.PEG_OUT_ONLY {
+	if config.PEG_OUT_ONLY {
 		return cashu.Sat, cashu.ErrMintintDisabled
 	}
 
-	if m.Config.PEG_IN_LIMIT_SATS != nil {
-		if request.Amount > uint64(*m.Config.PEG_IN_LIMIT_SATS) {
+	if config.PEG_IN_LIMIT_SATS != nil {
+		if request.Amount > uint64(*config.PEG_IN_LIMIT_SATS) {
 			slog.Info("Mint amount over the limit", slog.Uint64("amount", request.Amount))
 
 			return cashu.Sat, cashu.ErrAmountOutsideLimit
@@ -61,16 +65,16 @@ func (m *Mint) validateMintConfiguration(request cashu.PostMintQuoteBolt11Reques
 }
 
 func (m *Mint) createBolt11MintQuote(ctx context.Context, request cashu.PostMintQuoteBolt11Request, unit cashu.Unit) (cashu.PostMintQuoteBolt11Response, error) {
-	resInvoice, err := m.LightningBackend.RequestInvoice(cashu.NewAmount(unit, request.Amount), request.Description)
+	resInvoice, err := m.lightningBackend(ctx).RequestInvoice(cashu.NewAmount(unit, request.Amount), request.Description)
 	if err != nil {
-		return cashu.PostMintQuoteBolt11Response{}, fmt.Errorf(" m.LightningBackend.RequestInvoice. %w", err)
+		return cashu.PostMintQuoteBolt11Response{}, fmt.Errorf(" m.LightningBackend().RequestInvoice. %w", err)
 	}
 	quoteId, err := utils.RandomHash()
 	if err != nil {
 		return cashu.PostMintQuoteBolt11Response{}, fmt.Errorf(" utils.RandomHash() %w ", err)
 	}
 
-	expireTime := cashu.ExpiryTimeMinUnit(15)
+	expireTime := utils.QuoteExpiry(m.Config().MINT_QUOTE_TTL, time.Now())
 	now := time.Now().Unix()
 
 	mintRequestDB := cashu.MintRequestDB{
@@ -94,7 +98,7 @@ func (m *Mint) createBolt11MintQuote(ctx context.Context, request cashu.PostMint
 		rollbackErr := m.MintDB.Rollback(ctx, tx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -121,7 +125,7 @@ func (m *Mint) RefreshMintQuoteStatus(ctx context.Context, quoteId string, metho
 		rollbackErr := m.MintDB.Rollback(ctx, tx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -153,14 +157,14 @@ func (m *Mint) reconcileBolt11MintQuoteState(ctx context.Context, request cashu.
 	if method != Bolt11 {
 		return cashu.MintRequestDB{}, fmt.Errorf("request method is not BOLT11")
 	}
-	invoice, err := zpay32.Decode(request.Request, m.LightningBackend.GetNetwork())
+	invoice, err := zpay32.Decode(request.Request, m.LightningBackend().GetNetwork())
 	if err != nil {
-		return cashu.MintRequestDB{}, fmt.Errorf("zpay32.Decode(request.Request, m.LightningBackend.GetNetwork()). %w", err)
+		return cashu.MintRequestDB{}, fmt.Errorf("zpay32.Decode(request.Request, m.LightningBackend().GetNetwork()). %w", err)
 	}
 
-	status, _, err := m.LightningBackend.CheckReceived(request, invoice)
+	status, _, err := m.lightningBackend(ctx).CheckReceived(request, invoice)
 	if err != nil {
-		return cashu.MintRequestDB{}, fmt.Errorf("m.LightningBackend.CheckReceived(request, invoice). %w", err)
+		return cashu.MintRequestDB{}, fmt.Errorf("m.LightningBackend().CheckReceived(request, invoice). %w", err)
 	}
 	stateChangeTX, err := m.MintDB.GetTx(ctx)
 	if err != nil {
@@ -170,7 +174,7 @@ func (m *Mint) reconcileBolt11MintQuoteState(ctx context.Context, request cashu.
 		rollbackErr := m.MintDB.Rollback(ctx, stateChangeTX)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -203,6 +207,13 @@ func (m *Mint) reconcileBolt11MintQuoteState(ctx context.Context, request cashu.
 }
 
 func (m *Mint) IssueTokens(ctx context.Context, request cashu.PostMintBolt11Request, method METHOD) (cashu.PostMintBolt11Response, error) {
+	ctx, span := tracing.Start(ctx, "Mint.IssueTokens", attribute.String("quote", request.Quote), attribute.Int("outputs", len(request.Outputs)))
+	response, err := m.issueTokens(ctx, request, method)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (m *Mint) issueTokens(ctx context.Context, request cashu.PostMintBolt11Request, method METHOD) (cashu.PostMintBolt11Response, error) {
 	mintReq, err := m.loadAndValidateMintQuoteForIssuance(ctx, request)
 	if err != nil {
 		return cashu.PostMintBolt11Response{}, fmt.Errorf("m.loadAndValidateMintQuoteForIssuance(ctx, request). %w", err)
@@ -230,7 +241,7 @@ func (m *Mint) loadAndValidateMintQuoteForIssuance(ctx context.Context, request
 		rollbackErr := m.MintDB.Rollback(ctx, preparationTx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -269,7 +280,7 @@ func (m *Mint) loadAndValidateMintQuoteForIssuance(ctx context.Context, request
 		if err != nil {
 			rollbackErr := m.MintDB.Rollback(ctx, sizeCheckTx)
 			if rollbackErr != nil {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -294,14 +305,14 @@ func (m *Mint) bolt11Mint(ctx context.Context, request cashu.PostMintBolt11Reque
 		return cashu.PostMintBolt11Response{}, fmt.Errorf("cashu.UnitFromString(mintReq.Unit) %w", err)
 	}
 
-	supported := m.LightningBackend.VerifyUnitSupport(unit)
+	supported := m.LightningBackend().VerifyUnitSupport(unit)
 	if !supported {
-		return cashu.PostMintBolt11Response{}, fmt.Errorf(" m.LightningBackend.VerifyUnitSupport(unit). %w. %w", err, cashu.ErrUnitNotSupported)
+		return cashu.PostMintBolt11Response{}, fmt.Errorf(" m.LightningBackend().VerifyUnitSupport(unit). %w. %w", err, cashu.ErrUnitNotSupported)
 	}
 
-	invoice, err := zpay32.Decode(mintReq.Request, m.LightningBackend.GetNetwork())
+	invoice, err := zpay32.Decode(mintReq.Request, m.LightningBackend().GetNetwork())
 	if err != nil {
-		return cashu.PostMintBolt11Response{}, fmt.Errorf("zpay32.Decode(mintRequestDB.Request, mint.LightningBackend.GetNetwork()). %w", err)
+		return cashu.PostMintBolt11Response{}, fmt.Errorf("zpay32.Decode(mintRequestDB.Request, mint.LightningBackend().GetNetwork()). %w", err)
 	}
 	cashuBlindMessage := cashu.NewAmount(unit, request.Outputs.Amount())
 	err = cashuBlindMessage.To(cashu.Msat)
@@ -311,7 +322,7 @@ func (m *Mint) bolt11Mint(ctx context.Context, request cashu.PostMintBolt11Reque
 
 	// Mint outputs must match the invoice amount exactly.
 	if uint64(*invoice.MilliSat) != cashuBlindMessage.Amount {
-		slog.Info("mismatched amount of milisats", slog.Int("invoice_milisats", int(*invoice.MilliSat)), slog.Int("requested_milisats", int(cashuBlindMessage.Amount)))
+		slog.InfoContext(ctx, "mismatched amount of milisats", slog.Int("invoice_milisats", int(*invoice.MilliSat)), slog.Int("requested_milisats", int(cashuBlindMessage.Amount)))
 		return cashu.PostMintBolt11Response{}, cashu.ErrAmountNotEqualToInvoice
 	}
 
@@ -330,15 +341,16 @@ func (m *Mint) bolt11Mint(ctx context.Context, request cashu.PostMintBolt11Reque
 	if err != nil {
 		return cashu.PostMintBolt11Response{}, err
 	}
+	metrics.RecordOperation(metrics.OperationMint, mintReq.Unit, outputsAmountByKeyset(request.Outputs))
 
 
 	return cashu.PostMintBolt11Response{Signatures: blindSigs}, nil
 }
 
 func (m *Mint) signMintOutputsAndMarkIssued(ctx context.Context, request cashu.PostMintBolt11Request, mintRequestDB cashu.MintRequestDB) ([]cashu.BlindSignature, error) {
-	blindedSignatures, recoverySigsDb, err := m.Signer.SignBlindMessages(request.Outputs)
+	blindedSignatures, recoverySigsDb, err := m.Signer.SignBlindMessages(ctx, request.Outputs)
 	if err != nil {
-		return nil, fmt.Errorf("m.Signer.SignBlindMessages(request.Outputs) %w", err)
+		return nil, fmt.Errorf("m.Signer.SignBlindMessages(ctx, request.Outputs) %w", err)
 	}
 	mintRequestDB.State = cashu.ISSUED
 	mintRequestDB.Minted = true
@@ -350,7 +362,7 @@ func (m *Mint) signMintOutputsAndMarkIssued(ctx context.Context, request cashu.P
 		rollbackErr := m.MintDB.Rollback(ctx, afterMintingTx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -359,7 +371,7 @@ func (m *Mint) signMintOutputsAndMarkIssued(ctx context.Context, request cashu.P
 		return nil, fmt.Errorf("m.MintDB.ChangeMintRequestState. %w", err)
 	}
 
-	slog.Debug(fmt.Sprintf("Saving restore sigs for quote: id %v", mintRequestDB.Quote))
+	slog.DebugContext(ctx, fmt.Sprintf("Saving restore sigs for quote: id %v", mintRequestDB.Quote))
 	err = m.MintDB.SaveRestoreSigs(afterMintingTx, recoverySigsDb)
 	if err != nil {
 		return nil, fmt.Errorf("m.MintDB.SaveRestoreSigs. %w", err)
@@ -12,6 +12,8 @@ func (m *Mint) SetupOidcService(ctx context.Context, url string) error {
 		return err
 	}
 
-	m.OICDClient = oidcClient
+	m.updateState(func(state *mintState) {
+		state.oidcClient = oidcClient
+	})
 	return nil
 }
@@ -30,6 +30,12 @@ func CheckProofState(ctx context.Context, mint *Mint, Ys []cashu.WrappedPublicKe
 	if err != nil {
 		return nil, fmt.Errorf("database.CheckListOfProofsBySecretCurve(pool, Ys). %w", err)
 	}
+	// proofs of expired keysets stay spent after they are archived
+	archived, err := mint.MintDB.GetArchivedProofsFromSecretCurve(tx, Ys)
+	if err != nil {
+		return nil, fmt.Errorf("mint.MintDB.GetArchivedProofsFromSecretCurve(tx, Ys). %w", err)
+	}
+	proofs = append(proofs, archived...)
 
 	err = mint.MintDB.Commit(ctx, tx)
 	if err != nil {
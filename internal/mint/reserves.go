@@ -0,0 +1,244 @@
+package mint
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+// RESERVE_ALERT_PERCENT_ENV is the lightning balance, as a percentage of the
+// outstanding ecash, under which an alert is raised. 0 turns the alert off.
+var RESERVE_ALERT_PERCENT_ENV = "RESERVE_ALERT_PERCENT"
+
+const defaultReserveAlertPercent = 100
+
+// RESERVE_SNAPSHOT_RETENTION_DAYS_ENV is how many days of reserve checks are
+// kept for the chart. 0 keeps them forever.
+var RESERVE_SNAPSHOT_RETENTION_DAYS_ENV = "RESERVE_SNAPSHOT_RETENTION_DAYS"
+
+const defaultReserveSnapshotRetentionDays = 365
+
+// ReserveAlertPercent reads RESERVE_ALERT_PERCENT_ENV, 100 when it is not set.
+func ReserveAlertPercent() float64 {
+	value := os.Getenv(RESERVE_ALERT_PERCENT_ENV)
+	if value == "" {
+		return defaultReserveAlertPercent
+	}
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil || percent < 0 {
+		slog.Warn("invalid reserve alert percent, using the default", slog.String("value", value), slog.Int("default", defaultReserveAlertPercent))
+		return defaultReserveAlertPercent
+	}
+	return percent
+}
+
+// ReserveSnapshotRetentionDays reads RESERVE_SNAPSHOT_RETENTION_DAYS_ENV, 365
+// when it is not set.
+func ReserveSnapshotRetentionDays() int {
+	value := os.Getenv(RESERVE_SNAPSHOT_RETENTION_DAYS_ENV)
+	if value == "" {
+		return defaultReserveSnapshotRetentionDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		slog.Warn("invalid reserve snapshot retention, using the default", slog.String("value", value), slog.Int("default", defaultReserveSnapshotRetentionDays))
+		return defaultReserveSnapshotRetentionDays
+	}
+	return days
+}
+
+// KeysetOutstanding is the balance of a keyset with the unit of its ecash.
+// Untracked keysets signed before the mint recorded its signatures, so their
+// Issued misses that ecash and their outstanding ecash is unknown.
+type KeysetOutstanding struct {
+	Unit string
+	database.KeysetBalance
+	Untracked bool
+}
+
+// Inflated is true when the keyset redeemed more ecash than it signed, which
+// only happens with a leaked key or a bug that signs without recording it.
+// Untracked keysets are never inflated, the ecash signed before the recording
+// is redeemed without being counted as issued.
+func (k KeysetOutstanding) Inflated() bool {
+	return !k.Untracked && k.Redeemed > k.Issued
+}
+
+// OutstandingByKeyset returns the balance of every keyset of the signer,
+// including the ones that never signed anything.
+func (m *Mint) OutstandingByKeyset(ctx context.Context) ([]KeysetOutstanding, error) {
+	keysets, err := m.Signer.GetKeysets()
+	if err != nil {
+		return nil, fmt.Errorf("m.Signer.GetKeysets(). %w", err)
+	}
+	balances, err := m.MintDB.GetKeysetBalances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("m.MintDB.GetKeysetBalances(ctx). %w", err)
+	}
+	untracked, err := m.MintDB.GetUntrackedKeysets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("m.MintDB.GetUntrackedKeysets(ctx). %w", err)
+	}
+	byId := make(map[string]database.KeysetBalance, len(balances))
+	for _, balance := range balances {
+		byId[balance.KeysetId] = balance
+	}
+
+	outstanding := make([]KeysetOutstanding, 0, len(keysets.Keysets))
+	for _, keyset := range keysets.Keysets {
+		balance, ok := byId[keyset.Id]
+		if !ok {
+			balance = database.KeysetBalance{KeysetId: keyset.Id, Issued: 0, Redeemed: 0, Archived: 0}
+		}
+		outstanding = append(outstanding, KeysetOutstanding{Unit: keyset.Unit, KeysetBalance: balance, Untracked: slices.Contains(untracked, keyset.Id)})
+	}
+	slices.SortFunc(outstanding, func(a, b KeysetOutstanding) int {
+		return cmp.Or(strings.Compare(a.Unit, b.Unit), strings.Compare(a.KeysetId, b.KeysetId))
+	})
+	return outstanding, nil
+}
+
+// reserveSnapshot adds up the outstanding ecash of the sat and msat keysets in
+// sats. Inflated keysets count as nothing outstanding instead of lowering the
+// total. Untracked keysets are listed apart, the total is then only a lower
+// bound and the reserve ratio an upper one.
+func reserveSnapshot(keysets []KeysetOutstanding, lightningBalance *uint64, now time.Time) database.ReserveSnapshot {
+	var sats, msats uint64
+	inflated := make([]string, 0)
+	untracked := make([]string, 0)
+	for _, keyset := range keysets {
+		if keyset.Untracked {
+			untracked = append(untracked, keyset.KeysetId)
+			continue
+		}
+		if keyset.Inflated() {
+			inflated = append(inflated, keyset.KeysetId)
+			continue
+		}
+		switch keyset.Unit {
+		case cashu.Sat.String():
+			sats += uint64(keyset.Outstanding())
+		case cashu.Msat.String():
+			msats += uint64(keyset.Outstanding())
+		}
+	}
+	return database.ReserveSnapshot{
+		LightningBalance: lightningBalance,
+		InflatedKeysets:  inflated,
+		UntrackedKeysets: untracked,
+		Id:               0,
+		CreatedAt:        now.Unix(),
+		// round the msats up, the mint owes them in full
+		Outstanding: sats + (msats+999)/1000,
+	}
+}
+
+// reserveAlerts returns if the reserves fell below alertPercent since the
+// previous snapshot and the keysets that became inflated since then, so every
+// problem is only reported once.
+func reserveAlerts(previous *database.ReserveSnapshot, current database.ReserveSnapshot, alertPercent float64) (bool, []string) {
+	var lowReserves bool
+	percent, ok := current.ReservePercent()
+	if alertPercent > 0 && ok && percent < alertPercent {
+		lowReserves = true
+		if previous != nil {
+			previousPercent, previousOk := previous.ReservePercent()
+			lowReserves = !previousOk || previousPercent >= alertPercent
+		}
+	}
+
+	newlyInflated := make([]string, 0)
+	for _, id := range current.InflatedKeysets {
+		if previous == nil || !slices.Contains(previous.InflatedKeysets, id) {
+			newlyInflated = append(newlyInflated, id)
+		}
+	}
+	return lowReserves, newlyInflated
+}
+
+// RunReserveCheck stores how the lightning balance compares with the
+// outstanding ecash and logs an error, sent to the admins through the nostr
+// notifier, when the reserves fall below ReserveAlertPercent or a keyset gets
+// inflated.
+func (m *Mint) RunReserveCheck(ctx context.Context) error {
+	if m.SignerLocked() {
+		return nil
+	}
+	keysets, err := m.OutstandingByKeyset(ctx)
+	if err != nil {
+		return fmt.Errorf("m.OutstandingByKeyset(ctx). %w", err)
+	}
+
+	// the fake wallet has no real balance to compare against
+	var lightningBalance *uint64
+	if m.Config().MINT_LIGHTNING_BACKEND != utils.FAKE_WALLET {
+		balance, err := m.LightningBackend().WalletBalance()
+		if err == nil {
+			err = balance.To(cashu.Sat)
+		}
+		if err != nil {
+			slog.Warn("could not read the lightning balance for the reserve check", slog.Any("error", err))
+		} else {
+			lightningBalance = &balance.Amount
+		}
+	}
+
+	previous, err := m.MintDB.GetLatestReserveSnapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("m.MintDB.GetLatestReserveSnapshot(ctx). %w", err)
+	}
+	snapshot := reserveSnapshot(keysets, lightningBalance, time.Now())
+	err = m.MintDB.InsertReserveSnapshot(ctx, snapshot)
+	if err != nil {
+		return fmt.Errorf("m.MintDB.InsertReserveSnapshot(ctx, snapshot). %w", err)
+	}
+
+	alertPercent := ReserveAlertPercent()
+	lowReserves, inflated := reserveAlerts(previous, snapshot, alertPercent)
+	if lowReserves {
+		percent, _ := snapshot.ReservePercent()
+		slog.Error("the lightning balance is below the reserve threshold of the outstanding ecash",
+			slog.String("reserves", strconv.FormatFloat(percent, 'f', 2, 64)+"%"),
+			slog.String("threshold", strconv.FormatFloat(alertPercent, 'f', 2, 64)+"%"),
+			slog.Uint64("balance_sats", *snapshot.LightningBalance),
+			slog.Uint64("outstanding_sats", snapshot.Outstanding))
+	}
+	for _, keyset := range keysets {
+		if slices.Contains(inflated, keyset.KeysetId) {
+			slog.Error("a keyset redeemed more ecash than it issued, this points to inflation or a leaked key",
+				slog.String("keyset", keyset.KeysetId),
+				slog.String("unit", keyset.Unit),
+				slog.Uint64("issued", keyset.Issued),
+				slog.Uint64("redeemed", keyset.Redeemed))
+		}
+	}
+	return nil
+}
+
+// PruneReserveSnapshots deletes the reserve checks older than
+// ReserveSnapshotRetentionDays, a check every few minutes adds up to a lot of
+// rows.
+func (m *Mint) PruneReserveSnapshots(ctx context.Context) error {
+	days := ReserveSnapshotRetentionDays()
+	if days == 0 {
+		return nil
+	}
+	deleted, err := m.MintDB.DeleteReserveSnapshotsBefore(ctx, time.Now().AddDate(0, 0, -days).Unix())
+	if err != nil {
+		return fmt.Errorf("m.MintDB.DeleteReserveSnapshotsBefore(ctx, before). %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("pruned old reserve checks", slog.Int64("deleted", deleted), slog.Int("retention_days", days))
+	}
+	return nil
+}
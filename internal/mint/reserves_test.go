@@ -0,0 +1,169 @@
+package mint
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+// balanceBackend is a lightning backend that only knows its balance.
+type balanceBackend struct {
+	lightning.LightningBackend
+	balance cashu.Amount
+}
+
+func (b balanceBackend) WalletBalance() (cashu.Amount, error) {
+	return b.balance, nil
+}
+
+func reserveSnapshotWithBalance(balance uint64, outstanding uint64, inflated ...string) database.ReserveSnapshot {
+	return database.ReserveSnapshot{LightningBalance: &balance, InflatedKeysets: inflated, Id: 0, CreatedAt: 0, Outstanding: outstanding}
+}
+
+func TestReserveSnapshotAddsBitcoinUnits(t *testing.T) {
+	keysets := []KeysetOutstanding{
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "a", Issued: 100, Redeemed: 40, Archived: 0}},
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "b", Issued: 10, Redeemed: 30, Archived: 0}},
+		{Unit: "msat", KeysetBalance: database.KeysetBalance{KeysetId: "c", Issued: 2500, Redeemed: 0, Archived: 0}},
+		{Unit: "usd", KeysetBalance: database.KeysetBalance{KeysetId: "d", Issued: 500, Redeemed: 0, Archived: 0}},
+	}
+	snapshot := reserveSnapshot(keysets, nil, time.Unix(1700000000, 0))
+	// 60 sat, the inflated keyset counts as nothing and 2500 msat round up to 3 sat
+	if snapshot.Outstanding != 63 || snapshot.CreatedAt != 1700000000 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if !slices.Equal(snapshot.InflatedKeysets, []string{"b"}) {
+		t.Errorf("expected keyset b to be inflated, got %v", snapshot.InflatedKeysets)
+	}
+	if _, ok := snapshot.ReservePercent(); ok {
+		t.Errorf("expected no reserve percent without a balance")
+	}
+}
+
+func TestReserveSnapshotLeavesOutUntrackedKeysets(t *testing.T) {
+	keysets := []KeysetOutstanding{
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "a", Issued: 100, Redeemed: 40, Archived: 0}, Untracked: false},
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "old", Issued: 10, Redeemed: 500, Archived: 0}, Untracked: true},
+	}
+	if keysets[1].Inflated() {
+		t.Errorf("an untracked keyset redeeming more than it issued is not inflation")
+	}
+	snapshot := reserveSnapshot(keysets, nil, time.Unix(1700000000, 0))
+	if snapshot.Outstanding != 60 || len(snapshot.InflatedKeysets) != 0 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if !slices.Equal(snapshot.UntrackedKeysets, []string{"old"}) {
+		t.Errorf("expected keyset old to be untracked, got %v", snapshot.UntrackedKeysets)
+	}
+}
+
+func TestReserveAlertsOnlyOnChange(t *testing.T) {
+	low := reserveSnapshotWithBalance(50, 100)
+	healthy := reserveSnapshotWithBalance(150, 100)
+
+	lowReserves, _ := reserveAlerts(nil, low, 100)
+	if !lowReserves {
+		t.Errorf("expected an alert for the first low snapshot")
+	}
+	lowReserves, _ = reserveAlerts(&healthy, low, 100)
+	if !lowReserves {
+		t.Errorf("expected an alert when the reserves fall")
+	}
+	lowReserves, _ = reserveAlerts(&low, low, 100)
+	if lowReserves {
+		t.Errorf("expected no second alert while the reserves stay low")
+	}
+	lowReserves, _ = reserveAlerts(nil, low, 0)
+	if lowReserves {
+		t.Errorf("expected no alert when the threshold is turned off")
+	}
+
+	previous := reserveSnapshotWithBalance(150, 100, "a")
+	_, inflated := reserveAlerts(&previous, reserveSnapshotWithBalance(150, 100, "a", "b"), 100)
+	if !slices.Equal(inflated, []string{"b"}) {
+		t.Errorf("expected only the newly inflated keyset, got %v", inflated)
+	}
+}
+
+func TestRunReserveCheckStoresSnapshots(t *testing.T) {
+	db := mockdb.MockDB{}          //nolint:exhaustruct
+	fakeSigner := lifecycleSigner{ //nolint:exhaustruct
+		keysets: []cashu.BasicKeysetResponse{
+			{Id: "00aa", Unit: "sat", Active: true, FinalExpiry: nil, InputFeePpk: 0, Version: 1},
+			{Id: "00bb", Unit: "sat", Active: false, FinalExpiry: nil, InputFeePpk: 0, Version: 0},
+		},
+	}
+	mint := Mint{ //nolint:exhaustruct
+		MintDB: &db,
+		Signer: &fakeSigner,
+	}
+	mint.SetLightningBackend(balanceBackend{LightningBackend: nil, balance: cashu.Amount{Unit: cashu.Msat, Amount: 5000}})
+	mint.SetConfig(utils.Config{MINT_LIGHTNING_BACKEND: utils.LNDGRPC})                      //nolint:exhaustruct
+	db.RecoverSigDB = []cashu.RecoverSigDB{{Id: "00aa", Amount: 8}, {Id: "00bb", Amount: 2}} //nolint:exhaustruct
+	db.Proofs = cashu.Proofs{{Id: "00bb", Amount: 4, State: cashu.PROOF_SPENT}}              //nolint:exhaustruct
+
+	err := mint.RunReserveCheck(t.Context())
+	if err != nil {
+		t.Fatalf("mint.RunReserveCheck(ctx) %+v", err)
+	}
+	if len(db.ReserveSnapshots) != 1 {
+		t.Fatalf("expected one snapshot, got %+v", db.ReserveSnapshots)
+	}
+	snapshot := db.ReserveSnapshots[0]
+	if snapshot.LightningBalance == nil || *snapshot.LightningBalance != 5 || snapshot.Outstanding != 8 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if !slices.Equal(snapshot.InflatedKeysets, []string{"00bb"}) {
+		t.Errorf("expected keyset 00bb to be inflated, got %v", snapshot.InflatedKeysets)
+	}
+	percent, ok := snapshot.ReservePercent()
+	if !ok || percent != 62.5 {
+		t.Errorf("expected 62.5%% reserves, got %v %v", percent, ok)
+	}
+
+	db.UntrackedKeysets = []string{"00bb"}
+	err = mint.RunReserveCheck(t.Context())
+	if err != nil {
+		t.Fatalf("mint.RunReserveCheck(ctx) %+v", err)
+	}
+	snapshot = db.ReserveSnapshots[1]
+	if len(snapshot.InflatedKeysets) != 0 || !slices.Equal(snapshot.UntrackedKeysets, []string{"00bb"}) {
+		t.Errorf("expected keyset 00bb to be untracked instead of inflated, got %+v", snapshot)
+	}
+}
+
+func TestPruneReserveSnapshotsKeepsRecentAndLatest(t *testing.T) {
+	db := mockdb.MockDB{}     //nolint:exhaustruct
+	mint := Mint{MintDB: &db} //nolint:exhaustruct
+	old := time.Now().AddDate(0, 0, -40).Unix()
+	db.ReserveSnapshots = []database.ReserveSnapshot{
+		{Id: 1, CreatedAt: old, Outstanding: 1},                                 //nolint:exhaustruct
+		{Id: 2, CreatedAt: time.Now().Unix(), Outstanding: 2},                   //nolint:exhaustruct
+		{Id: 3, CreatedAt: time.Now().AddDate(0, 0, -1).Unix(), Outstanding: 3}, //nolint:exhaustruct
+	}
+
+	t.Setenv(RESERVE_SNAPSHOT_RETENTION_DAYS_ENV, "0")
+	err := mint.PruneReserveSnapshots(t.Context())
+	if err != nil || len(db.ReserveSnapshots) != 3 {
+		t.Fatalf("expected every snapshot to be kept forever, got %+v, %v", db.ReserveSnapshots, err)
+	}
+
+	t.Setenv(RESERVE_SNAPSHOT_RETENTION_DAYS_ENV, "30")
+	err = mint.PruneReserveSnapshots(t.Context())
+	if err != nil || len(db.ReserveSnapshots) != 2 || db.ReserveSnapshots[0].Id != 2 {
+		t.Fatalf("expected the old snapshot to be pruned, got %+v, %v", db.ReserveSnapshots, err)
+	}
+
+	// the latest snapshot is kept for the next alert even when it is old
+	db.ReserveSnapshots = []database.ReserveSnapshot{{Id: 1, CreatedAt: old, Outstanding: 1}} //nolint:exhaustruct
+	err = mint.PruneReserveSnapshots(t.Context())
+	if err != nil || len(db.ReserveSnapshots) != 1 {
+		t.Errorf("expected the latest snapshot to be kept, got %+v, %v", db.ReserveSnapshots, err)
+	}
+}
@@ -8,17 +8,27 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/metrics"
+	"github.com/lescuer97/nutmix/internal/tracing"
 	"github.com/lescuer97/nutmix/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (m *Mint) ExecuteSwap(ctx context.Context, request cashu.PostSwapRequest) (cashu.PostSwapResponse, error) {
-	amountValidationErr := m.validateSwapBalanceAndUnits(request)
+	ctx, span := tracing.Start(ctx, "Mint.ExecuteSwap", attribute.Int("inputs", len(request.Inputs)), attribute.Int("outputs", len(request.Outputs)))
+	response, err := m.executeSwap(ctx, request)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (m *Mint) executeSwap(ctx context.Context, request cashu.PostSwapRequest) (cashu.PostSwapResponse, error) {
+	unit, amountValidationErr := m.validateSwapBalanceAndUnits(request)
 	if amountValidationErr != nil {
 		return cashu.PostSwapResponse{}, fmt.Errorf("m.validateSwapBalanceAndUnits(request). %w", amountValidationErr)
 	}
 
 	// validate sig all
-	err := m.validateSwapProofsAndSpendConditions(request)
+	err := m.validateSwapProofsAndSpendConditions(ctx, request)
 	if err != nil {
 		return cashu.PostSwapResponse{}, fmt.Errorf("m.validateSwapProofsAndSpendConditions(request). %w", err)
 	}
@@ -33,7 +43,7 @@ func (m *Mint) ExecuteSwap(ctx context.Context, request cashu.PostSwapRequest) (
 			rollbackErr := m.MintDB.Rollback(ctx, sizeCheckTx)
 			if rollbackErr != nil {
 				if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-					slog.Warn("rollback error", slog.Any("error", rollbackErr))
+					slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 				}
 				return
 			}
@@ -75,6 +85,7 @@ func (m *Mint) ExecuteSwap(ctx context.Context, request cashu.PostSwapRequest) (
 
 	proofs.SetProofsState(cashu.PROOF_SPENT)
 	go m.Observer.SendProofsEvent(proofs)
+	metrics.RecordOperation(metrics.OperationSwap, unit.String(), proofsAmountByKeyset(proofs))
 	// mark as pending and sign
 	return cashu.PostSwapResponse{
 		Signatures: blindSignatures,
@@ -83,7 +94,7 @@ func (m *Mint) ExecuteSwap(ctx context.Context, request cashu.PostSwapRequest) (
 
 func (m *Mint) signSwapOutputsAndMarkInputsSpent(ctx context.Context, inputs cashu.Proofs, swapRequest cashu.PostSwapRequest) (blindedSignatures []cashu.BlindSignature, shouldRemovePendingProofs bool, err error) {
 	// sign the outputs
-	blindedSignatures, recoverySigsDb, err := m.Signer.SignBlindMessages(swapRequest.Outputs)
+	blindedSignatures, recoverySigsDb, err := m.Signer.SignBlindMessages(ctx, swapRequest.Outputs)
 	if err != nil {
 		return nil, true, fmt.Errorf("m.Signer.SignBlindMessages(outputs). %w", err)
 	}
@@ -97,7 +108,7 @@ func (m *Mint) signSwapOutputsAndMarkInputsSpent(ctx context.Context, inputs cas
 		rollbackErr := m.MintDB.Rollback(ctx, afterSigningTx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("could not swap state", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "could not swap state", slog.Any("error", rollbackErr))
 			}
 			return
 		}
@@ -119,7 +130,7 @@ func (m *Mint) signSwapOutputsAndMarkInputsSpent(ctx context.Context, inputs cas
 			return nil, true, fmt.Errorf("m.MintDB.Commit(ctx, afterSigningTx). %w", err)
 		}
 		if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-			slog.Warn("could not rollback failed swap commit", slog.Any("error", rollbackErr))
+			slog.WarnContext(ctx, "could not rollback failed swap commit", slog.Any("error", rollbackErr))
 		}
 		return nil, false, fmt.Errorf("m.MintDB.Commit(ctx, afterSigningTx). %w", err)
 	}
@@ -135,7 +146,7 @@ func (m *Mint) removePendingSwapProofs(ctx context.Context, proofs cashu.Proofs)
 		rollbackErr := m.MintDB.Rollback(ctx, tx)
 		if rollbackErr != nil {
 			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				slog.Warn("rollback error", slog.Any("error", rollbackErr))
+				slog.WarnContext(ctx, "rollback error", slog.Any("error", rollbackErr))
 			}
 		}
 	}()
@@ -153,9 +164,9 @@ func (m *Mint) removePendingSwapProofs(ctx context.Context, proofs cashu.Proofs)
 	return nil
 }
 
-func (m *Mint) validateSwapProofsAndSpendConditions(request cashu.PostSwapRequest) error {
+func (m *Mint) validateSwapProofsAndSpendConditions(ctx context.Context, request cashu.PostSwapRequest) error {
 	// validate if the proofs are correctly signed
-	err := m.VerifyProofsBDHKE(request.Inputs)
+	err := m.VerifyProofsBDHKE(ctx, request.Inputs)
 	if err != nil {
 		return fmt.Errorf("m.VerifyProofsBDHKE(proofs). %w", err)
 	}
@@ -182,46 +193,46 @@ func (m *Mint) validateSwapProofsAndSpendConditions(request cashu.PostSwapReques
 	return nil
 }
 
-func (m *Mint) validateSwapBalanceAndUnits(request cashu.PostSwapRequest) error {
+func (m *Mint) validateSwapBalanceAndUnits(request cashu.PostSwapRequest) (cashu.Unit, error) {
 	if len(request.Inputs) == 0 || len(request.Outputs) == 0 {
-		return fmt.Errorf("inputs or outputs are empty")
+		return 0, fmt.Errorf("inputs or outputs are empty")
 	}
 	proofsAmount := request.Inputs.Amount()
 	blindMessageAmount := request.Outputs.Amount()
 
 	keysets, err := m.Signer.GetKeysets()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// check for needed amount of fees
 	fee, err := cashu.Fees(request.Inputs, keysets.Keysets)
 	if err != nil {
-		return fmt.Errorf("cashu.Fees(request.Inputs, keysets.Keysets). %w", err)
+		return 0, fmt.Errorf("cashu.Fees(request.Inputs, keysets.Keysets). %w", err)
 	}
 
 	balance := (proofsAmount - (uint64(fee) + blindMessageAmount))
 	if balance != 0 {
-		return fmt.Errorf("(proofs.Amount() - (uint64(fee) + AmountSignature)). %w", cashu.ErrUnbalanced)
+		return 0, fmt.Errorf("(proofs.Amount() - (uint64(fee) + AmountSignature)). %w", cashu.ErrUnbalanced)
 	}
 
 	// get unit from proofs
 	proofUnit, err := checkProofsAreSameUnit(request.Inputs, keysets.Keysets)
 	if err != nil {
-		return fmt.Errorf("m.CheckProofsAreSameUnit(proofs, keysets.Keysets). %w", err)
+		return 0, fmt.Errorf("m.CheckProofsAreSameUnit(proofs, keysets.Keysets). %w", err)
 	}
 
 	// check if outputs are
 	outputUnit, err := verifyOutputs(request.Outputs, keysets.Keysets)
 	if err != nil {
-		return fmt.Errorf("m.VerifyOutputs(outputs). %w", err)
+		return 0, fmt.Errorf("m.VerifyOutputs(outputs). %w", err)
 	}
 
 	if proofUnit != outputUnit {
-		return fmt.Errorf("proofUnit != messageUnit. %w", cashu.ErrNotSameUnits)
+		return 0, fmt.Errorf("proofUnit != messageUnit. %w", cashu.ErrNotSameUnits)
 	}
 
-	return nil
+	return proofUnit, nil
 }
 
 // returns the proofs with the Y's and seen at.
@@ -40,12 +40,12 @@ type failingSigner struct {
 	signBlindMessagesErr error
 }
 
-func (s failingSigner) SignBlindMessages(messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
+func (s failingSigner) SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
 	if s.signBlindMessagesErr != nil {
 		return nil, nil, s.signBlindMessagesErr
 	}
 
-	return s.Signer.SignBlindMessages(messages)
+	return s.Signer.SignBlindMessages(ctx, messages)
 }
 
 func TestExecuteSwapRemovesPendingProofsWhenSaveRestoreSigsFails(t *testing.T) {
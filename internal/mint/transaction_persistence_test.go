@@ -74,9 +74,9 @@ func createSpendableProofs(t *testing.T, mint *Mint, amount uint64, activeKeys s
 	t.Helper()
 
 	blindedMessages, secrets, blindingFactors := createMintTestBlindedMessagesWithSecrets(t, amount, activeKeys)
-	blindSignatures, _, err := mint.Signer.SignBlindMessages(blindedMessages)
+	blindSignatures, _, err := mint.Signer.SignBlindMessages(context.Background(), blindedMessages)
 	if err != nil {
-		t.Fatalf("mint.Signer.SignBlindMessages(blindedMessages): %v", err)
+		t.Fatalf("mint.Signer.SignBlindMessages(context.Background(), blindedMessages): %v", err)
 	}
 
 	proofs := make(cashu.Proofs, len(blindSignatures))
@@ -415,9 +415,9 @@ func TestReserveMeltInputsAndMarkPendingPersistsProofQuoteReference(t *testing.T
 
 func TestCreateMeltQuoteUsesBackendAmountToSend(t *testing.T) {
 	mint := SetupMintWithLightningMockPostgres(t)
-	mint.LightningBackend = quoteAmountBackend{
+	mint.SetLightningBackend(quoteAmountBackend{
 		FakeWallet: lightning.FakeWallet{
-			Network:         *mint.LightningBackend.GetNetwork(),
+			Network:         *mint.LightningBackend().GetNetwork(),
 			UnpurposeErrors: []lightning.FakeWalletError{},
 			InvoiceFee:      0,
 		},
@@ -426,7 +426,7 @@ func TestCreateMeltQuoteUsesBackendAmountToSend(t *testing.T) {
 			Fees:         cashu.NewAmount(cashu.Sat, 0),
 			AmountToSend: cashu.NewAmount(cashu.Sat, 2),
 		},
-	}
+	})
 
 	quote, err := mint.CreateMeltQuote(context.Background(), cashu.PostMeltQuoteBolt11Request{
 		Options: cashu.PostMeltQuoteBolt11Options{Mpp: nil},
@@ -8,17 +8,22 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/lightning"
 	"github.com/lescuer97/nutmix/internal/utils"
 )
 
-func (m *Mint) GetChangeOutput(messages []cashu.BlindedMessage, overPaidFees uint64, unit string) ([]cashu.RecoverSigDB, error) {
+func (m *Mint) GetChangeOutput(ctx context.Context, messages []cashu.BlindedMessage, overPaidFees uint64, unit string) ([]cashu.RecoverSigDB, error) {
 	if overPaidFees > 0 && len(messages) > 0 {
-		change := utils.GetMessagesForChange(overPaidFees, messages)
+		denominations, err := m.keysetAmounts(messages[0].Id)
+		if err != nil {
+			return nil, fmt.Errorf("m.keysetAmounts(messages[0].Id). %w", err)
+		}
+		change := utils.GetMessagesForChange(overPaidFees, messages, denominations)
 
-		_, recoverySigsDb, err := m.Signer.SignBlindMessages(change)
+		_, recoverySigsDb, err := m.Signer.SignBlindMessages(ctx, change)
 
 		if err != nil {
-			return recoverySigsDb, fmt.Errorf("m.Signer.SignBlindMessages(change). %w", err)
+			return recoverySigsDb, fmt.Errorf("m.Signer.SignBlindMessages(ctx, change). %w", err)
 		}
 
 		return recoverySigsDb, nil
@@ -32,10 +37,10 @@ func (m *Mint) VerifyUnitSupport(unitStr string) error {
 		return fmt.Errorf(" cashu.UnitFromString(unitStr). %w. %w", err, cashu.ErrUnitNotSupported)
 	}
 
-	supported := m.LightningBackend.VerifyUnitSupport(unit)
+	supported := m.LightningBackend().VerifyUnitSupport(unit)
 
 	if !supported {
-		return fmt.Errorf(" m.LightningBackend.VerifyUnitSupport(unit). %w. %w", err, cashu.ErrUnitNotSupported)
+		return fmt.Errorf(" m.LightningBackend().VerifyUnitSupport(unit). %w. %w", err, cashu.ErrUnitNotSupported)
 	}
 	return nil
 }
@@ -93,10 +98,10 @@ func (m *Mint) VerifyOutputs(outputs []cashu.BlindedMessage, keys []cashu.BasicK
 
 // VerifyProofsBDHKE verifies the BDHKE cryptographic signatures of the proofs.
 // This should always be called regardless of SIG_ALL.
-func (m *Mint) VerifyProofsBDHKE(proofs cashu.Proofs) error {
-	err := m.Signer.VerifyProofs(proofs)
+func (m *Mint) VerifyProofsBDHKE(ctx context.Context, proofs cashu.Proofs) error {
+	err := m.Signer.VerifyProofs(ctx, proofs)
 	if err != nil {
-		return fmt.Errorf("m.Signer.VerifyProofs(proofs). %w", err)
+		return fmt.Errorf("m.Signer.VerifyProofs(ctx, proofs). %w", err)
 	}
 	return nil
 }
@@ -134,3 +139,25 @@ func (m *Mint) IsInternalTransaction(ctx context.Context, request string) (bool,
 
 	return false, nil
 }
+
+// proofsAmountByKeyset groups the amount of the proofs by keyset id for the metrics.
+func proofsAmountByKeyset(proofs cashu.Proofs) map[string]uint64 {
+	amounts := make(map[string]uint64)
+	for _, proof := range proofs {
+		amounts[proof.Id] += proof.Amount
+	}
+	return amounts
+}
+
+func outputsAmountByKeyset(outputs cashu.BlindedMessages) map[string]uint64 {
+	amounts := make(map[string]uint64)
+	for _, output := range outputs {
+		amounts[output.Id] += output.Amount
+	}
+	return amounts
+}
+
+// lightningBackend returns the lightning backend with its calls traced under ctx.
+func (m *Mint) lightningBackend(ctx context.Context) lightning.LightningBackend {
+	return lightning.WithContext(ctx, m.LightningBackend())
+}
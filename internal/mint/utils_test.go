@@ -100,7 +100,7 @@ func TestIsInternalTransactionFail(t *testing.T) {
 func TestVerifyUnitOfProofFail(t *testing.T) {
 	mint := SetupMintWithLightningMockPostgres(t)
 
-	err := mint.Signer.RotateKeyset(cashu.EUR, 0, 0)
+	err := mint.Signer.RotateKeyset(cashu.EUR, 0, 0, nil)
 	if err != nil {
 		t.Fatalf("mint.Signer.RotateKeyset(cashu.EUR, 0): %+v ", err)
 	}
@@ -156,7 +156,7 @@ func TestVerifyUnitOfProofFail(t *testing.T) {
 func TestVerifyUnitOfProofPass(t *testing.T) {
 	mint := SetupMintWithLightningMockPostgres(t)
 
-	err := mint.Signer.RotateKeyset(cashu.EUR, 0, 240)
+	err := mint.Signer.RotateKeyset(cashu.EUR, 0, 240, nil)
 	if err != nil {
 		t.Fatalf("mint.Signer.RotateKeyset(cashu.EUR, 0): %+v ", err)
 	}
@@ -287,7 +287,7 @@ func TestVerifyOutputsFailsForUnknownKeyset(t *testing.T) {
 func TestVerifyOutputsFailRepeatedOutput(t *testing.T) {
 	mint := SetupMintWithLightningMockPostgres(t)
 
-	err := mint.Signer.RotateKeyset(cashu.EUR, 0, 240)
+	err := mint.Signer.RotateKeyset(cashu.EUR, 0, 240, nil)
 	if err != nil {
 		t.Fatalf("mint.Signer.RotateKeyset(cashu.EUR, 0): %+v ", err)
 	}
@@ -28,6 +28,9 @@ type Observer struct {
 	Proofs    map[string][]ProofWatchChannel
 	MintQuote map[string][]MintQuoteChannel
 	MeltQuote map[string][]MeltQuoteChannel
+	// notifier forwards events to other mint instances. nil keeps them local.
+	notifier   ObserverNotifier
+	instanceId string
 	sync.Mutex
 }
 
@@ -103,7 +106,39 @@ func (o *Observer) RemoveWatch(subId string) {
 	}
 }
 
+// SubscriptionCount returns how many websocket subscriptions are watching
+// proofs, mint quotes and melt quotes on this instance.
+func (o *Observer) SubscriptionCount() (proofs int, mintQuotes int, meltQuotes int) {
+	o.Lock()
+	defer o.Unlock()
+	for _, watchers := range o.Proofs {
+		proofs += len(watchers)
+	}
+	for _, watchers := range o.MintQuote {
+		mintQuotes += len(watchers)
+	}
+	for _, watchers := range o.MeltQuote {
+		meltQuotes += len(watchers)
+	}
+	return proofs, mintQuotes, meltQuotes
+}
+
 func (o *Observer) SendProofsEvent(proofs cashu.Proofs) {
+	o.deliverProofsEvent(proofs)
+	o.relayProofsEvent(proofs)
+}
+
+func (o *Observer) SendMeltEvent(melt cashu.MeltRequestDB) {
+	o.deliverMeltEvent(melt)
+	o.relayEvent(relayedEvent{MeltQuote: &melt, MintQuote: nil, Origin: "", Proofs: nil})
+}
+
+func (o *Observer) SendMintEvent(mint cashu.MintRequestDB) {
+	o.deliverMintEvent(mint)
+	o.relayEvent(relayedEvent{MintQuote: &mint, MeltQuote: nil, Origin: "", Proofs: nil})
+}
+
+func (o *Observer) deliverProofsEvent(proofs cashu.Proofs) {
 	o.Lock()
 	defer o.Unlock()
 
@@ -117,7 +152,7 @@ func (o *Observer) SendProofsEvent(proofs cashu.Proofs) {
 	}
 }
 
-func (o *Observer) SendMeltEvent(melt cashu.MeltRequestDB) {
+func (o *Observer) deliverMeltEvent(melt cashu.MeltRequestDB) {
 	o.Lock()
 	watchArray, exists := o.MeltQuote[melt.Quote]
 	defer o.Unlock()
@@ -128,7 +163,7 @@ func (o *Observer) SendMeltEvent(melt cashu.MeltRequestDB) {
 	}
 }
 
-func (o *Observer) SendMintEvent(mint cashu.MintRequestDB) {
+func (o *Observer) deliverMintEvent(mint cashu.MintRequestDB) {
 	o.Lock()
 	watchArray, exists := o.MintQuote[mint.Quote]
 	defer o.Unlock()
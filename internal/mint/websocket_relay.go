@@ -0,0 +1,166 @@
+package mint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lescuer97/nutmix/api/cashu"
+)
+
+// ObserverEventsChannel is the postgres channel used to share websocket events
+// between mint instances.
+const ObserverEventsChannel = "nutmix_observer_events"
+
+// postgres rejects NOTIFY payloads of 8000 bytes or more.
+const maxRelayPayloadSize = 7900
+
+const relayNotifyTimeout = 5 * time.Second
+
+// ObserverNotifier publishes and receives observer events between mint
+// instances. postgresql.Postgresql implements it with LISTEN/NOTIFY.
+type ObserverNotifier interface {
+	Notify(ctx context.Context, channel string, payload string) error
+	Listen(ctx context.Context, channel string, handle func(payload string)) error
+}
+
+// relayedProof only carries what a websocket subscriber is sent, to keep
+// NOTIFY payloads small.
+type relayedProof struct {
+	Y       cashu.WrappedPublicKey `json:"Y"`
+	Witness string                 `json:"witness"`
+	State   cashu.ProofState       `json:"state"`
+}
+
+type relayedEvent struct {
+	MintQuote *cashu.MintRequestDB `json:"mint_quote,omitempty"`
+	MeltQuote *cashu.MeltRequestDB `json:"melt_quote,omitempty"`
+	Origin    string               `json:"origin"`
+	Proofs    []relayedProof       `json:"proofs,omitempty"`
+}
+
+// StartRelay makes the observer publish its events through notifier and
+// deliver the events published by other instances to local subscribers. It
+// returns once the relay is enabled and keeps listening until ctx is done.
+func (o *Observer) StartRelay(ctx context.Context, notifier ObserverNotifier) {
+	o.Lock()
+	o.notifier = notifier
+	o.instanceId = uuid.New().String()
+	o.Unlock()
+
+	go func() {
+		backoff := time.Second
+		for {
+			err := notifier.Listen(ctx, ObserverEventsChannel, o.handleRelayedEvent)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Websocket event relay stopped listening, retrying", slog.Any("error", err), slog.Duration("backoff", backoff))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+		}
+	}()
+}
+
+func (o *Observer) relayTarget() (ObserverNotifier, string) {
+	o.Lock()
+	defer o.Unlock()
+	return o.notifier, o.instanceId
+}
+
+func (o *Observer) relayEvent(event relayedEvent) {
+	notifier, instanceId := o.relayTarget()
+	if notifier == nil {
+		return
+	}
+	event.Origin = instanceId
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Warn("json.Marshal(event)", slog.Any("error", err))
+		return
+	}
+	if len(payload) > maxRelayPayloadSize {
+		slog.Warn("Websocket event is too big to relay to other instances", slog.Int("size", len(payload)))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayNotifyTimeout)
+	defer cancel()
+	err = notifier.Notify(ctx, ObserverEventsChannel, string(payload))
+	if err != nil {
+		slog.Warn("notifier.Notify(ctx, ObserverEventsChannel, payload)", slog.Any("error", err))
+	}
+}
+
+// relayProofsEvent splits the proofs in batches that fit in a single
+// notification.
+func (o *Observer) relayProofsEvent(proofs cashu.Proofs) {
+	notifier, _ := o.relayTarget()
+	if notifier == nil {
+		return
+	}
+
+	// leave room for the origin and the json envelope
+	const envelopeSize = 128
+	batch := []relayedProof{}
+	batchSize := envelopeSize
+	for _, proof := range proofs {
+		relayed := relayedProof{Y: proof.Y, Witness: proof.Witness, State: proof.State}
+		encoded, err := json.Marshal(relayed)
+		if err != nil {
+			slog.Warn("json.Marshal(relayed)", slog.Any("error", err))
+			continue
+		}
+		proofSize := len(encoded) + 1
+
+		if batchSize+proofSize > maxRelayPayloadSize && len(batch) > 0 {
+			o.relayEvent(relayedEvent{Proofs: batch, MintQuote: nil, MeltQuote: nil, Origin: ""})
+			batch = []relayedProof{}
+			batchSize = envelopeSize
+		}
+		batch = append(batch, relayed)
+		batchSize += proofSize
+	}
+
+	if len(batch) > 0 {
+		o.relayEvent(relayedEvent{Proofs: batch, MintQuote: nil, MeltQuote: nil, Origin: ""})
+	}
+}
+
+func (o *Observer) handleRelayedEvent(payload string) {
+	var event relayedEvent
+	err := json.Unmarshal([]byte(payload), &event)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("json.Unmarshal(%s)", ObserverEventsChannel), slog.Any("error", err))
+		return
+	}
+
+	_, instanceId := o.relayTarget()
+	if event.Origin == instanceId {
+		return
+	}
+
+	if len(event.Proofs) > 0 {
+		proofs := make(cashu.Proofs, len(event.Proofs))
+		for i, relayed := range event.Proofs {
+			//nolint:exhaustruct
+			proofs[i] = cashu.Proof{Y: relayed.Y, Witness: relayed.Witness, State: relayed.State}
+		}
+		go o.deliverProofsEvent(proofs)
+	}
+	if event.MintQuote != nil {
+		go o.deliverMintEvent(*event.MintQuote)
+	}
+	if event.MeltQuote != nil {
+		go o.deliverMeltEvent(*event.MeltQuote)
+	}
+}
@@ -1,8 +1,10 @@
 package mint
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/lescuer97/nutmix/api/cashu"
 )
@@ -108,3 +110,80 @@ func TestRemoveWatchClosesMintAndMeltChannels(t *testing.T) {
 		t.Fatal("expected melt channel to be closed and readable")
 	}
 }
+
+// loopbackNotifier hands every notification to all the observers listening.
+type loopbackNotifier struct {
+	handlers []func(payload string)
+	mu       sync.Mutex
+}
+
+func (n *loopbackNotifier) Notify(ctx context.Context, channel string, payload string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, handle := range n.handlers {
+		handle(payload)
+	}
+	return nil
+}
+
+func (n *loopbackNotifier) Listen(ctx context.Context, channel string, handle func(payload string)) error {
+	n.mu.Lock()
+	n.handlers = append(n.handlers, handle)
+	n.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+func TestRelayDeliversEventsToOtherInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := &loopbackNotifier{}
+	sender := newObserverForTest()
+	receiver := newObserverForTest()
+	sender.StartRelay(ctx, notifier)
+	receiver.StartRelay(ctx, notifier)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		notifier.mu.Lock()
+		listening := len(notifier.handlers)
+		notifier.mu.Unlock()
+		if listening == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("observers did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	senderChan := make(chan cashu.MintRequestDB, 2)
+	receiverChan := make(chan cashu.MintRequestDB, 2)
+	sender.AddMintWatch("quote-1", MintQuoteChannel{SubId: "sender", Channel: senderChan})
+	receiver.AddMintWatch("quote-1", MintQuoteChannel{SubId: "receiver", Channel: receiverChan})
+
+	sender.SendMintEvent(cashu.MintRequestDB{Quote: "quote-1", State: cashu.PAID})
+
+	select {
+	case quote := <-receiverChan:
+		if quote.State != cashu.PAID {
+			t.Fatalf("expected relayed quote to be paid, got %s", quote.State)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected mint event to be relayed to the other instance")
+	}
+
+	select {
+	case <-senderChan:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected mint event to be delivered locally")
+	}
+
+	// the sender should not get its own event back from the relay
+	select {
+	case <-senderChan:
+		t.Fatal("sender received its own relayed event")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,411 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+)
+
+const (
+	// AdminIPAllowlistEnv is a comma separated list of IPs or CIDR ranges allowed
+	// to reach the admin dashboard. Every address is allowed when it is empty.
+	AdminIPAllowlistEnv = "ADMIN_IP_ALLOWLIST"
+
+	adminRoleKey    = "admin-role"
+	adminSessionKey = "admin-session"
+
+	adminSessionDuration = time.Hour
+)
+
+var (
+	ErrRoleNotAllowed   = errors.New("your admin role does not allow this action")
+	ErrInvalidAdminRole = errors.New("admin role is not valid")
+	ErrLastOwner        = errors.New("the mint needs at least one owner")
+	ErrAdminExists      = errors.New("this npub is already an admin")
+)
+
+func roleRank(role database.AdminRole) int {
+	switch role {
+	case database.AdminViewer:
+		return 1
+	case database.AdminOperator:
+		return 2
+	case database.AdminOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func parseAdminRole(role string) (database.AdminRole, error) {
+	adminRole := database.AdminRole(strings.ToLower(strings.TrimSpace(role)))
+	if roleRank(adminRole) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrInvalidAdminRole, role)
+	}
+	return adminRole, nil
+}
+
+// RequireRole stops requests from admins with a lower role than role.
+func RequireRole(role database.AdminRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := database.AdminRole(c.GetString(adminRoleKey))
+		if roleRank(current) >= roleRank(role) {
+			c.Next()
+			return
+		}
+
+		slog.Info("admin role not allowed",
+			slog.String("path", c.Request.URL.Path),
+			slog.String("role", string(current)),
+			slog.String("required", string(role)))
+		if c.GetHeader("HX-Request") == "true" {
+			_ = c.Error(ErrRoleNotAllowed)
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrRoleNotAllowed.Error())
+	}
+}
+
+func parseIPAllowlist(list string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("netip.ParsePrefix(%s). %w", value, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("netip.ParseAddr(%s). %w", value, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// IPAllowlistMiddleware only lets requests from the allowed ranges through.
+// The client IP comes from gin, so the trusted proxies need to be set when the
+// mint runs behind a reverse proxy.
+func IPAllowlistMiddleware(allowed []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(allowed) == 0 {
+			c.Next()
+			return
+		}
+
+		addr, err := netip.ParseAddr(c.ClientIP())
+		if err == nil {
+			addr = addr.Unmap()
+			for _, prefix := range allowed {
+				if prefix.Contains(addr) {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		slog.Warn("admin request from an address outside the allowlist", slog.String("ip", c.ClientIP()))
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+// setupBootstrapOwner makes sure the admin from ADMIN_NOSTR_NPUB is an owner so
+// the mint can never be locked out of its dashboard.
+func setupBootstrapOwner(ctx context.Context, db database.MintDB, pubkey string) error {
+	err := db.SaveAdmin(ctx, database.Admin{
+		Pubkey:    pubkey,
+		Role:      database.AdminOwner,
+		AddedBy:   "ADMIN_NOSTR_NPUB",
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("db.SaveAdmin(ctx, owner). %w", err)
+	}
+	return nil
+}
+
+// checkOwnerRemains fails if pubkey is the last owner and would lose the role.
+func checkOwnerRemains(ctx context.Context, db database.MintDB, pubkey string) error {
+	admins, err := db.GetAdmins(ctx)
+	if err != nil {
+		return fmt.Errorf("db.GetAdmins(ctx). %w", err)
+	}
+	owners := 0
+	isOwner := false
+	for _, admin := range admins {
+		if admin.Role == database.AdminOwner {
+			owners++
+			if admin.Pubkey == pubkey {
+				isOwner = true
+			}
+		}
+	}
+	if isOwner && owners == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func AccessPage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := templates.AccessPage().Render(c.Request.Context(), c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.AccessPage().Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+func AdminsTable(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		admins, err := db.GetAdmins(ctx)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetAdmins(ctx). %w", err))
+			return
+		}
+
+		rows := make([]templates.AdminRow, len(admins))
+		for i, admin := range admins {
+			rows[i] = templates.AdminRow{
+				Pubkey:    admin.Pubkey,
+				Role:      string(admin.Role),
+				AddedBy:   admin.AddedBy,
+				CreatedAt: admin.CreatedAt,
+				IsCurrent: admin.Pubkey == auditActor(c),
+			}
+		}
+
+		err = templates.AdminsTable(rows).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.AdminsTable(rows).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+// SaveNewAdmin adds npub as an admin with role. addedBy is recorded as the
+// admin that added it.
+func SaveNewAdmin(ctx context.Context, db database.MintDB, npub string, role string, addedBy string) (database.Admin, error) {
+	var admin database.Admin
+	pubkey, err := decodeNpubToHex(strings.TrimSpace(npub))
+	if err != nil {
+		return admin, errors.Join(ErrInvalidNostrKey, err)
+	}
+	adminRole, err := parseAdminRole(role)
+	if err != nil {
+		return admin, err
+	}
+
+	existing, err := db.GetAdmin(ctx, pubkey)
+	if err != nil {
+		return admin, fmt.Errorf("db.GetAdmin(ctx, pubkey). %w", err)
+	}
+	if existing != nil {
+		return admin, ErrAdminExists
+	}
+
+	admin = database.Admin{
+		Pubkey:    pubkey,
+		Role:      adminRole,
+		AddedBy:   addedBy,
+		CreatedAt: time.Now().Unix(),
+	}
+	err = db.SaveAdmin(ctx, admin)
+	if err != nil {
+		return admin, fmt.Errorf("db.SaveAdmin(ctx, admin). %w", err)
+	}
+	return admin, nil
+}
+
+// RemoveAdminAccount deletes an admin and its sessions, unless it is the last
+// owner.
+func RemoveAdminAccount(ctx context.Context, db database.MintDB, pubkey string) error {
+	err := checkOwnerRemains(ctx, db, pubkey)
+	if err != nil {
+		return err
+	}
+	err = db.DeleteAdmin(ctx, pubkey)
+	if err != nil {
+		return fmt.Errorf("db.DeleteAdmin(ctx, pubkey). %w", err)
+	}
+	return nil
+}
+
+func AddAdmin(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin, err := SaveNewAdmin(c.Request.Context(), db, c.PostForm("npub"), c.PostForm("role"), auditActor(c))
+		switch {
+		case errors.Is(err, ErrInvalidNostrKey):
+			_ = c.Error(err)
+			return
+		case errors.Is(err, ErrInvalidAdminRole):
+			_ = RenderError(c, ErrInvalidAdminRole.Error())
+			return
+		case errors.Is(err, ErrAdminExists):
+			_ = RenderError(c, "This npub is already an admin")
+			return
+		case err != nil:
+			_ = c.Error(fmt.Errorf("SaveNewAdmin(ctx, db, npub, role, actor). %w", err))
+			return
+		}
+		recordAuditChange(c, db, audit.ActionAdminAdd, nil, map[string]string{"pubkey": admin.Pubkey, "role": string(admin.Role)})
+
+		c.Header("HX-Trigger", "recharge-access")
+		if err := RenderSuccess(c, "Admin added"); err != nil {
+			slog.Warn("failed to render success", slog.Any("error", err))
+		}
+	}
+}
+
+func ChangeAdminRole(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		pubkey := c.Param("pubkey")
+		role, err := parseAdminRole(c.PostForm("role"))
+		if err != nil {
+			_ = RenderError(c, ErrInvalidAdminRole.Error())
+			return
+		}
+
+		admin, err := db.GetAdmin(ctx, pubkey)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetAdmin(ctx, pubkey). %w", err))
+			return
+		}
+		if admin == nil {
+			_ = RenderError(c, "Admin not found")
+			return
+		}
+		if role != database.AdminOwner {
+			err = checkOwnerRemains(ctx, db, pubkey)
+			if err != nil {
+				c.Header("HX-Trigger", "recharge-access")
+				_ = RenderError(c, err.Error())
+				return
+			}
+		}
+
+		before := map[string]string{"pubkey": pubkey, "role": string(admin.Role)}
+		admin.Role = role
+		err = db.SaveAdmin(ctx, *admin)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.SaveAdmin(ctx, admin). %w", err))
+			return
+		}
+		recordAuditChange(c, db, audit.ActionAdminRole, before, map[string]string{"pubkey": pubkey, "role": string(role)})
+
+		c.Header("HX-Trigger", "recharge-access")
+		if err := RenderSuccess(c, "Admin role changed"); err != nil {
+			slog.Warn("failed to render success", slog.Any("error", err))
+		}
+	}
+}
+
+func RemoveAdmin(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		pubkey := c.Param("pubkey")
+
+		err := RemoveAdminAccount(ctx, db, pubkey)
+		if errors.Is(err, ErrLastOwner) {
+			_ = RenderError(c, err.Error())
+			return
+		}
+		if err != nil {
+			_ = c.Error(fmt.Errorf("RemoveAdminAccount(ctx, db, pubkey). %w", err))
+			return
+		}
+		recordAuditChange(c, db, audit.ActionAdminRemove, map[string]string{"pubkey": pubkey}, nil)
+
+		c.Header("HX-Trigger", "recharge-access")
+		if err := RenderSuccess(c, "Admin removed"); err != nil {
+			slog.Warn("failed to render success", slog.Any("error", err))
+		}
+	}
+}
+
+func SessionsTable(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sessions, err := db.GetActiveAdminSessions(ctx, time.Now().Unix())
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetActiveAdminSessions(ctx, now). %w", err))
+			return
+		}
+
+		rows := make([]templates.SessionRow, len(sessions))
+		for i, session := range sessions {
+			rows[i] = templates.SessionRow{
+				Id:        session.Id,
+				Pubkey:    session.Pubkey,
+				Ip:        session.Ip,
+				CreatedAt: session.CreatedAt,
+				ExpiresAt: session.ExpiresAt,
+				IsCurrent: session.Id == c.GetString(adminSessionKey),
+			}
+		}
+
+		err = templates.SessionsTable(rows).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.SessionsTable(rows).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+func RevokeSession(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		id := c.Param("id")
+
+		session, err := db.GetAdminSession(ctx, id)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetAdminSession(ctx, id). %w", err))
+			return
+		}
+		if session == nil {
+			_ = RenderError(c, "Session not found")
+			return
+		}
+
+		err = db.RevokeAdminSession(ctx, id)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.RevokeAdminSession(ctx, id). %w", err))
+			return
+		}
+		recordAuditChange(c, db, audit.ActionSessionRevoke, nil, map[string]string{"pubkey": session.Pubkey, "ip": session.Ip})
+
+		c.Header("HX-Trigger", "recharge-access")
+		if err := RenderSuccess(c, "Session revoked"); err != nil {
+			slog.Warn("failed to render success", slog.Any("error", err))
+		}
+	}
+}
+
+func adminIPAllowlist() ([]netip.Prefix, error) {
+	allowed, err := parseIPAllowlist(os.Getenv(AdminIPAllowlistEnv))
+	if err != nil {
+		return nil, fmt.Errorf("parseIPAllowlist(%s). %w", AdminIPAllowlistEnv, err)
+	}
+	return allowed, nil
+}
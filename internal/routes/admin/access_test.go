@@ -0,0 +1,160 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/database"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+)
+
+func roleTestRouter(role database.AdminRole, required database.AdminRole) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(adminRoleKey, string(role))
+	})
+	r.POST("/rotate", RequireRole(required), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestRequireRole(t *testing.T) {
+	cases := []struct {
+		role     database.AdminRole
+		required database.AdminRole
+		allowed  bool
+	}{
+		{database.AdminViewer, database.AdminOperator, false},
+		{database.AdminOperator, database.AdminOperator, true},
+		{database.AdminOwner, database.AdminOperator, true},
+		{database.AdminOperator, database.AdminOwner, false},
+		{"", database.AdminViewer, false},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		roleTestRouter(tc.role, tc.required).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rotate", nil))
+		if (w.Code == http.StatusOK) != tc.allowed {
+			t.Errorf("role %q on a %q route: got status %d", tc.role, tc.required, w.Code)
+		}
+	}
+}
+
+func TestIPAllowlistMiddleware(t *testing.T) {
+	allowed, err := parseIPAllowlist("10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatalf("parseIPAllowlist: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(IPAllowlistMiddleware(allowed))
+	r.GET("/admin", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := map[string]int{
+		"10.1.2.3:4000":    http.StatusOK,
+		"192.168.1.5:4000": http.StatusOK,
+		"192.168.1.6:4000": http.StatusForbidden,
+	}
+	for remote, status := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("request from %s: expected %d, got %d", remote, status, w.Code)
+		}
+	}
+
+	_, err = parseIPAllowlist("10.0.0.0/33")
+	if err == nil {
+		t.Fatal("expected an invalid range to fail")
+	}
+}
+
+func TestAuthMiddlewareChecksSession(t *testing.T) {
+	secret := []byte("test-secret")
+	db := &mockdb.MockDB{
+		Admins: []database.Admin{{Pubkey: "viewer-pubkey", Role: database.AdminViewer}},
+	}
+	session := database.AdminSession{
+		Id:        "session-1",
+		Pubkey:    "viewer-pubkey",
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}
+	err := db.SaveAdminSession(t.Context(), session)
+	if err != nil {
+		t.Fatalf("db.SaveAdminSession: %v", err)
+	}
+	token, err := makeJWTToken(secret, session)
+	if err != nil {
+		t.Fatalf("makeJWTToken: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AuthMiddleware(secret, NewTokenBlacklist(), db))
+	var role string
+	r.GET("/admin", func(c *gin.Context) {
+		role = c.GetString(adminRoleKey)
+		c.Status(http.StatusOK)
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.AddCookie(&http.Cookie{Name: AdminAuthKey, Value: token})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request()
+	if w.Code != http.StatusOK || role != string(database.AdminViewer) {
+		t.Fatalf("expected the session to be accepted as a viewer, got %d and role %q", w.Code, role)
+	}
+
+	err = db.RevokeAdminSession(t.Context(), session.Id)
+	if err != nil {
+		t.Fatalf("db.RevokeAdminSession: %v", err)
+	}
+	w = request()
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected a revoked session to be sent to the login, got %d", w.Code)
+	}
+}
+
+func TestLastOwnerCanNotBeRemoved(t *testing.T) {
+	db := &mockdb.MockDB{
+		Admins: []database.Admin{
+			{Pubkey: "owner", Role: database.AdminOwner},
+			{Pubkey: "operator", Role: database.AdminOperator},
+		},
+	}
+
+	err := checkOwnerRemains(t.Context(), db, "owner")
+	if !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected ErrLastOwner, got %v", err)
+	}
+	err = checkOwnerRemains(t.Context(), db, "operator")
+	if err != nil {
+		t.Fatalf("expected an operator to be removable, got %v", err)
+	}
+
+	err = db.SaveAdmin(t.Context(), database.Admin{Pubkey: "operator", Role: database.AdminOwner})
+	if err != nil {
+		t.Fatalf("db.SaveAdmin: %v", err)
+	}
+	err = checkOwnerRemains(t.Context(), db, "owner")
+	if err != nil {
+		t.Fatalf("expected an owner to be removable when another owner exists, got %v", err)
+	}
+}
@@ -0,0 +1,613 @@
+package admin
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	m "github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/scheduler"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+//go:embed openapi.json
+var openApiSpec []byte
+
+const (
+	apiDefaultLimit = 100
+	apiMaxLimit     = 1000
+)
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func abortApi(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, apiError{Error: message})
+}
+
+// apiInternalError logs err and hides it from the caller.
+func apiInternalError(c *gin.Context, err error) {
+	slog.ErrorContext(c.Request.Context(), "admin api error", slog.String("path", c.Request.URL.Path), slog.Any("error", err))
+	abortApi(c, http.StatusInternalServerError, "internal error")
+}
+
+// apiLimit reads the limit query parameter, capped to apiMaxLimit.
+func apiLimit(c *gin.Context) (int, error) {
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		return apiDefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit should be a positive number")
+	}
+	return min(limit, apiMaxLimit), nil
+}
+
+// apiSince reads the since query parameter as a unix timestamp.
+func apiSince(c *gin.Context, fallback time.Time) (time.Time, error) {
+	sinceStr := c.Query("since")
+	if sinceStr == "" {
+		return fallback, nil
+	}
+	since, err := strconv.ParseInt(sinceStr, 10, 64)
+	if err != nil || since < 0 {
+		return time.Time{}, fmt.Errorf("since should be a unix timestamp")
+	}
+	return time.Unix(since, 0), nil
+}
+
+// ApiRoutes serves the admin json api under /admin/api/v1. It is
+// authenticated with api keys instead of the dashboard session.
+func ApiRoutes(r *gin.Engine, mint *m.Mint, adminHandler *adminHandler, jobs *scheduler.Scheduler, middlewares ...gin.HandlerFunc) {
+	apiRoute := r.Group("/admin/api/v1", middlewares...)
+	apiRoute.GET("/openapi.json", OpenApiSpec())
+
+	keyRoute := apiRoute.Group("", ApiKeyMiddleware(mint.MintDB))
+	// nolint: contextcheck
+	keyRoute.GET("/config", RequireScope(ScopeConfigRead), ApiGetConfig(mint))
+	// nolint: contextcheck
+	keyRoute.PATCH("/config", RequireScope(ScopeConfigWrite), ApiUpdateConfig(mint))
+	// nolint: contextcheck
+	keyRoute.GET("/keysets", RequireScope(ScopeKeysetsRead), ApiKeysets(mint))
+	// nolint: contextcheck
+	keyRoute.POST("/keysets/rotate", RequireScope(ScopeKeysetsRotate), ApiRotateKeyset(adminHandler))
+	// nolint: contextcheck
+	keyRoute.GET("/quotes", RequireScope(ScopeQuotesRead), ApiQuotes(mint))
+	// nolint: contextcheck
+	keyRoute.GET("/liquidity/swaps", RequireScope(ScopeLiquidityRead), ApiGetLiquiditySwaps(mint))
+	// nolint: contextcheck
+	keyRoute.GET("/liquidity/swaps/:id", RequireScope(ScopeLiquidityRead), ApiGetLiquiditySwap(mint))
+	// nolint: contextcheck
+	keyRoute.GET("/stats/snapshots", RequireScope(ScopeStatsRead), ApiStatsSnapshots(mint))
+	// nolint: contextcheck
+	keyRoute.GET("/logs", RequireScope(ScopeLogsRead), ApiLogs())
+	// nolint: contextcheck
+	keyRoute.POST("/jobs/:name/run", RequireScope(ScopeJobsRun), ApiRunJob(jobs))
+	// nolint: contextcheck
+	keyRoute.POST("/signer/unlock", RequireScope(ScopeSignerUnlock), ApiUnlockSigner(mint))
+}
+
+func OpenApiSpec() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openApiSpec)
+	}
+}
+
+// ApiConfig is the part of the mint config exposed by the api. Lightning
+// backend credentials are never returned.
+type ApiConfig struct {
+	IconUrl          *string `json:"icon_url"`
+	TosUrl           *string `json:"tos_url"`
+	PegInLimitSats   *int    `json:"peg_in_limit_sats"`
+	PegOutLimitSats  *int    `json:"peg_out_limit_sats"`
+	Name             string  `json:"name"`
+	Description      string  `json:"description"`
+	DescriptionLong  string  `json:"description_long"`
+	Email            string  `json:"email"`
+	Nostr            string  `json:"nostr"`
+	Motd             string  `json:"motd"`
+	Network          string  `json:"network"`
+	LightningBackend string  `json:"lightning_backend"`
+	PegOutOnly       bool    `json:"peg_out_only"`
+	RequireAuth      bool    `json:"require_auth"`
+}
+
+func apiConfigFrom(config utils.Config) ApiConfig {
+	return ApiConfig{
+		IconUrl:          config.IconUrl,
+		TosUrl:           config.TosUrl,
+		PegInLimitSats:   config.PEG_IN_LIMIT_SATS,
+		PegOutLimitSats:  config.PEG_OUT_LIMIT_SATS,
+		Name:             config.NAME,
+		Description:      config.DESCRIPTION,
+		DescriptionLong:  config.DESCRIPTION_LONG,
+		Email:            config.EMAIL,
+		Nostr:            config.NOSTR,
+		Motd:             config.MOTD,
+		Network:          config.NETWORK,
+		LightningBackend: string(config.MINT_LIGHTNING_BACKEND),
+		PegOutOnly:       config.PEG_OUT_ONLY,
+		RequireAuth:      config.MINT_REQUIRE_AUTH,
+	}
+}
+
+// optionalLimit tells a missing field apart from an explicit null, which
+// removes the limit.
+type optionalLimit struct {
+	Value *int
+	Set   bool
+}
+
+func (o *optionalLimit) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// ApiConfigUpdate changes the general and lightning limit settings. Fields
+// that are left out keep their value.
+type ApiConfigUpdate struct {
+	Name            *string       `json:"name"`
+	Description     *string       `json:"description"`
+	DescriptionLong *string       `json:"description_long"`
+	Email           *string       `json:"email"`
+	Nostr           *string       `json:"nostr"`
+	Motd            *string       `json:"motd"`
+	IconUrl         *string       `json:"icon_url"`
+	TosUrl          *string       `json:"tos_url"`
+	PegOutOnly      *bool         `json:"peg_out_only"`
+	PegInLimitSats  optionalLimit `json:"peg_in_limit_sats"`
+	PegOutLimitSats optionalLimit `json:"peg_out_limit_sats"`
+}
+
+// apply returns config with the update applied. An empty url removes it.
+func (u ApiConfigUpdate) apply(config utils.Config) (utils.Config, error) {
+	optionalUrl := func(value string) (*string, error) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, nil
+		}
+		err := validateURL(value)
+		if err != nil {
+			return nil, err
+		}
+		return &value, nil
+	}
+
+	if u.IconUrl != nil {
+		iconUrl, err := optionalUrl(*u.IconUrl)
+		if err != nil {
+			return config, fmt.Errorf("invalid icon_url: %w", err)
+		}
+		config.IconUrl = iconUrl
+	}
+	if u.TosUrl != nil {
+		tosUrl, err := optionalUrl(*u.TosUrl)
+		if err != nil {
+			return config, fmt.Errorf("invalid tos_url: %w", err)
+		}
+		config.TosUrl = tosUrl
+	}
+	if u.Nostr != nil {
+		if *u.Nostr != "" {
+			isValid, err := isNostrKeyValid(*u.Nostr)
+			if err != nil || !isValid {
+				return config, ErrInvalidNostrKey
+			}
+		}
+		config.NOSTR = *u.Nostr
+	}
+	if u.Name != nil {
+		config.NAME = *u.Name
+	}
+	if u.Description != nil {
+		config.DESCRIPTION = *u.Description
+	}
+	if u.DescriptionLong != nil {
+		config.DESCRIPTION_LONG = *u.DescriptionLong
+	}
+	if u.Email != nil {
+		config.EMAIL = *u.Email
+	}
+	if u.Motd != nil {
+		config.MOTD = *u.Motd
+	}
+	if u.PegOutOnly != nil {
+		config.PEG_OUT_ONLY = *u.PegOutOnly
+	}
+	if u.PegInLimitSats.Set {
+		config.PEG_IN_LIMIT_SATS = u.PegInLimitSats.Value
+	}
+	if u.PegOutLimitSats.Set {
+		config.PEG_OUT_LIMIT_SATS = u.PegOutLimitSats.Value
+	}
+	return config, nil
+}
+
+func ApiGetConfig(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, apiConfigFrom(mint.Config()))
+	}
+}
+
+func ApiUpdateConfig(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update ApiConfigUpdate
+		err := c.ShouldBindJSON(&update)
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, "malformed body request")
+			return
+		}
+
+		revision, err := mint.ChangeConfig(c.Request.Context(), auditActor(c), func(config *utils.Config) error {
+			updated, err := update.apply(*config)
+			if err != nil {
+				return fmt.Errorf("%w: %w", m.ErrInvalidConfig, err)
+			}
+			*config = updated
+			return nil
+		})
+		if errors.Is(err, m.ErrInvalidConfig) {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("mint.ChangeConfig(ctx, actor, change). %w", err))
+			return
+		}
+		recordConfigRevision(c, mint.MintDB, audit.ActionConfigGeneral, revision)
+
+		c.JSON(http.StatusOK, apiConfigFrom(revision.Config))
+	}
+}
+
+type ApiKeyset struct {
+	FinalExpiry *uint64 `json:"final_expiry,omitempty"`
+	Id          string  `json:"id"`
+	Unit        string  `json:"unit"`
+	Version     uint32  `json:"version"`
+	InputFeePpk uint    `json:"input_fee_ppk"`
+	Active      bool    `json:"active"`
+	Auth        bool    `json:"auth"`
+}
+
+func ApiKeysets(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keysets, err := mint.Signer.GetKeysets()
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("mint.Signer.GetKeysets(). %w", err))
+			return
+		}
+		authKeysets, err := mint.Signer.GetAuthKeys()
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("mint.Signer.GetAuthKeys(). %w", err))
+			return
+		}
+
+		result := make([]ApiKeyset, 0, len(keysets.Keysets)+len(authKeysets.Keysets))
+		for _, keyset := range keysets.Keysets {
+			result = append(result, ApiKeyset{
+				FinalExpiry: keyset.FinalExpiry,
+				Id:          keyset.Id,
+				Unit:        keyset.Unit,
+				Version:     keyset.Version,
+				InputFeePpk: keyset.InputFeePpk,
+				Active:      keyset.Active,
+				Auth:        false,
+			})
+		}
+		for _, keyset := range authKeysets.Keysets {
+			result = append(result, ApiKeyset{
+				FinalExpiry: keyset.FinalExpiry,
+				Id:          keyset.Id,
+				Unit:        keyset.Unit,
+				Version:     keyset.Version,
+				InputFeePpk: keyset.InputFeePpk,
+				Active:      keyset.Active,
+				Auth:        true,
+			})
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ApiRotateRequest takes the unit by name, unlike RotateRequest.
+type ApiRotateRequest struct {
+	Unit             string   `json:"unit"`
+	Amounts          []uint64 `json:"amounts"`
+	Fee              uint     `json:"fee"`
+	ExpireLimitHours uint     `json:"expire_limit_hours"`
+	MaxOrder         uint     `json:"max_order"`
+}
+
+func ApiRotateKeyset(adminHandler *adminHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request ApiRotateRequest
+		err := c.ShouldBindJSON(&request)
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, "malformed body request")
+			return
+		}
+		unit, err := cashu.UnitFromString(request.Unit)
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, ErrUnitNotCorrect.Error())
+			return
+		}
+		rotateRequest := RotateRequest{
+			Fee:              request.Fee,
+			Unit:             unit,
+			ExpireLimitHours: request.ExpireLimitHours,
+			Amounts:          request.Amounts,
+			MaxOrder:         request.MaxOrder,
+		}
+		amounts, err := rotateRequest.keysetAmounts()
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		err = adminHandler.rotateKeyset(c.Request.Context(), rotateRequest.Unit, rotateRequest.Fee, rotateRequest.ExpireLimitHours, amounts)
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("adminHandler.rotateKeyset(unit, fee, expiry). %w", err))
+			return
+		}
+		recordAuditDetails(c, adminHandler.mint.MintDB, audit.ActionKeysetRotate, rotateRequest)
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+type ApiQuote struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Request string `json:"request"`
+	State   string `json:"state"`
+	Unit    string `json:"unit"`
+	SeenAt  int64  `json:"seen_at"`
+}
+
+func ApiQuotes(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		since, err := apiSince(c, time.Now().Add(-7*24*time.Hour))
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit, err := apiLimit(c)
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		activity, err := lightningActivity(c.Request.Context(), mint.MintDB, strings.TrimSpace(c.Query("search")), since)
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("lightningActivity(ctx, db, search, since). %w", err))
+			return
+		}
+		if len(activity) > limit {
+			activity = activity[:limit]
+		}
+
+		quotes := make([]ApiQuote, len(activity))
+		for i, quote := range activity {
+			quotes[i] = ApiQuote{
+				Id:      quote.Id,
+				Type:    quote.Type,
+				Request: quote.Invoice,
+				State:   quote.Status,
+				Unit:    quote.Unit,
+				SeenAt:  quote.Time,
+			}
+		}
+		c.JSON(http.StatusOK, quotes)
+	}
+}
+
+type ApiLiquiditySwap struct {
+	Id               string `json:"id"`
+	LightningInvoice string `json:"lightning_invoice"`
+	State            string `json:"state"`
+	Type             string `json:"type"`
+	Amount           uint64 `json:"amount"`
+	Expiration       uint64 `json:"expiration"`
+}
+
+func apiLiquiditySwapFrom(swap utils.LiquiditySwap) ApiLiquiditySwap {
+	return ApiLiquiditySwap{
+		Id:               swap.Id,
+		LightningInvoice: swap.LightningInvoice,
+		State:            string(swap.State),
+		Type:             string(swap.Type),
+		Amount:           swap.Amount,
+		Expiration:       swap.Expiration,
+	}
+}
+
+func ApiGetLiquiditySwaps(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		swaps, err := mint.MintDB.GetAllLiquiditySwaps()
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("mint.MintDB.GetAllLiquiditySwaps(). %w", err))
+			return
+		}
+
+		result := make([]ApiLiquiditySwap, len(swaps))
+		for i, swap := range swaps {
+			result[i] = apiLiquiditySwapFrom(swap)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func ApiGetLiquiditySwap(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		swaps, err := mint.MintDB.GetAllLiquiditySwaps()
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("mint.MintDB.GetAllLiquiditySwaps(). %w", err))
+			return
+		}
+
+		for _, swap := range swaps {
+			if swap.Id == c.Param("id") {
+				c.JSON(http.StatusOK, apiLiquiditySwapFrom(swap))
+				return
+			}
+		}
+		abortApi(c, http.StatusNotFound, "swap not found")
+	}
+}
+
+type ApiStatsSnapshot struct {
+	MintSummary      []database.StatsSummaryItem `json:"mint_summary"`
+	MeltSummary      []database.StatsSummaryItem `json:"melt_summary"`
+	BlindSigsSummary []database.StatsSummaryItem `json:"blind_sigs_summary"`
+	ProofsSummary    []database.StatsSummaryItem `json:"proofs_summary"`
+	Id               int64                       `json:"id"`
+	StartDate        int64                       `json:"start_date"`
+	EndDate          int64                       `json:"end_date"`
+	Fees             uint64                      `json:"fees"`
+}
+
+func ApiStatsSnapshots(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		since, err := apiSince(c, time.Now().Add(-7*24*time.Hour))
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		snapshots, err := mint.MintDB.GetStatsSnapshotsBySince(c.Request.Context(), since.Unix())
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("mint.MintDB.GetStatsSnapshotsBySince(ctx, since). %w", err))
+			return
+		}
+
+		result := make([]ApiStatsSnapshot, len(snapshots))
+		for i, snapshot := range snapshots {
+			result[i] = ApiStatsSnapshot{
+				MintSummary:      snapshot.MintSummary,
+				MeltSummary:      snapshot.MeltSummary,
+				BlindSigsSummary: snapshot.BlindSigsSummary,
+				ProofsSummary:    snapshot.ProofsSummary,
+				Id:               snapshot.ID,
+				StartDate:        snapshot.StartDate,
+				EndDate:          snapshot.EndDate,
+				Fees:             snapshot.Fees,
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+type ApiLogRecord struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	Msg       string    `json:"msg"`
+	ExtraInfo string    `json:"extra_info,omitempty"`
+}
+
+// apiLogLevels returns the levels at or above the level query parameter.
+func apiLogLevels(level string) ([]slog.Level, error) {
+	var minLevel slog.Level
+	if level == "" {
+		level = "info"
+	}
+	err := minLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("level should be one of debug, info, warn or error")
+	}
+	levels := []slog.Level{}
+	for _, l := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if l >= minLevel {
+			levels = append(levels, l)
+		}
+	}
+	return levels, nil
+}
+
+func ApiLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		levels, err := apiLogLevels(c.Query("level"))
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		since, err := apiSince(c, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit, err := apiLimit(c)
+		if err != nil {
+			abortApi(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		logsDir, err := utils.GetLogsDirectory()
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("utils.GetLogsDirectory(). %w", err))
+			return
+		}
+		file, err := os.Open(logsDir + "/" + utils.LogFileName)
+		if err != nil {
+			apiInternalError(c, fmt.Errorf("os.Open(logFile). %w", err))
+			return
+		}
+		defer func() {
+			err := file.Close()
+			if err != nil {
+				slog.Warn("failed to close log file", slog.Any("error", err))
+			}
+		}()
+
+		records := utils.ParseLogFileByLevelAndTime(file, levels, since)
+		// keep the newest records
+		if len(records) > limit {
+			records = records[len(records)-limit:]
+		}
+
+		result := make([]ApiLogRecord, len(records))
+		for i, record := range records {
+			result[i] = ApiLogRecord{
+				Time:      record.Time,
+				Level:     record.Level.String(),
+				Msg:       record.Msg,
+				ExtraInfo: record.ExtraInfo,
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ApiRunJob runs a scheduled job now, like the run button of the jobs page.
+func ApiRunJob(jobs *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		err := jobs.Trigger(c.Request.Context(), name)
+		switch {
+		case errors.Is(err, scheduler.ErrJobNotFound):
+			abortApi(c, http.StatusNotFound, "job does not exist")
+			return
+		case errors.Is(err, scheduler.ErrJobRunningElsewhere):
+			abortApi(c, http.StatusConflict, "job is already running")
+			return
+		case err != nil:
+			slog.WarnContext(c.Request.Context(), "jobs.Trigger(ctx, name)", slog.String("job", name), slog.Any("error", err))
+			abortApi(c, http.StatusInternalServerError, "job failed, check the last error on the jobs page")
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,294 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+)
+
+// Scopes an admin api key can be given.
+const (
+	ScopeConfigRead    = "config:read"
+	ScopeConfigWrite   = "config:write"
+	ScopeKeysetsRead   = "keysets:read"
+	ScopeKeysetsRotate = "keysets:rotate"
+	ScopeQuotesRead    = "quotes:read"
+	ScopeLiquidityRead = "liquidity:read"
+	ScopeStatsRead     = "stats:read"
+	ScopeLogsRead      = "logs:read"
+	ScopeJobsRun       = "jobs:run"
+	ScopeSignerUnlock  = "signer:unlock"
+)
+
+// apiScopeRoles is the role the creator of a key needs for each scope. It is
+// checked on every request so a key never does more than its admin could.
+var apiScopeRoles = map[string]database.AdminRole{
+	ScopeConfigRead:    database.AdminViewer,
+	ScopeConfigWrite:   database.AdminOperator,
+	ScopeKeysetsRead:   database.AdminViewer,
+	ScopeKeysetsRotate: database.AdminOperator,
+	ScopeQuotesRead:    database.AdminViewer,
+	ScopeLiquidityRead: database.AdminViewer,
+	ScopeStatsRead:     database.AdminViewer,
+	ScopeLogsRead:      database.AdminViewer,
+	ScopeJobsRun:       database.AdminOperator,
+	ScopeSignerUnlock:  database.AdminOwner,
+}
+
+const (
+	apiKeyPrefix       = "nutmix_"
+	apiKeyContextKey   = "admin-api-key"
+	apiKeyMaxDays      = 365
+	apiKeyDefaultDays  = 90
+	apiKeyBearerScheme = "Bearer "
+)
+
+var (
+	ErrInvalidApiKey      = errors.New("api key is not valid")
+	ErrApiScopeNotAllowed = errors.New("api key does not have the scope for this action")
+	ErrInvalidApiScope    = errors.New("api key scope is not valid")
+)
+
+func apiScopes() []string {
+	scopes := make([]string, 0, len(apiScopeRoles))
+	for scope := range apiScopeRoles {
+		scopes = append(scopes, scope)
+	}
+	slices.Sort(scopes)
+	return scopes
+}
+
+func hashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// generateApiKey returns a new key and the hash that gets stored.
+func generateApiKey() (string, string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("rand.Read(secret). %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	return key, hashApiKey(key), nil
+}
+
+// activeApiKey returns the stored key and the current role of its creator.
+func activeApiKey(ctx context.Context, db database.MintDB, key string, now time.Time) (*database.AdminApiKey, database.AdminRole, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, "", ErrInvalidApiKey
+	}
+	apiKey, err := db.GetAdminApiKeyByHash(ctx, hashApiKey(key))
+	if err != nil {
+		return nil, "", fmt.Errorf("db.GetAdminApiKeyByHash(ctx, hash). %w", err)
+	}
+	if apiKey == nil || apiKey.Revoked || apiKey.ExpiresAt <= now.Unix() {
+		return nil, "", ErrInvalidApiKey
+	}
+	admin, err := db.GetAdmin(ctx, apiKey.CreatedBy)
+	if err != nil {
+		return nil, "", fmt.Errorf("db.GetAdmin(ctx, apiKey.CreatedBy). %w", err)
+	}
+	if admin == nil {
+		return nil, "", ErrInvalidApiKey
+	}
+	return apiKey, admin.Role, nil
+}
+
+// ApiKeyMiddleware authenticates the admin json api with an
+// "Authorization: Bearer <key>" header.
+func ApiKeyMiddleware(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		key, found := strings.CutPrefix(c.GetHeader("Authorization"), apiKeyBearerScheme)
+		if !found {
+			abortApi(c, http.StatusUnauthorized, "missing api key")
+			return
+		}
+
+		now := time.Now()
+		apiKey, role, err := activeApiKey(ctx, db, strings.TrimSpace(key), now)
+		if err != nil {
+			slog.Debug("activeApiKey(ctx, db, key, now)", slog.Any("error", err))
+			abortApi(c, http.StatusUnauthorized, ErrInvalidApiKey.Error())
+			return
+		}
+
+		err = db.UpdateAdminApiKeyLastUsed(ctx, apiKey.Id, now.Unix())
+		if err != nil {
+			slog.Warn("db.UpdateAdminApiKeyLastUsed(ctx, apiKey.Id, now)", slog.Any("error", err))
+		}
+
+		// changes made with a key are recorded for the admin that created it
+		c.Set(adminPubkeyKey, apiKey.CreatedBy)
+		c.Set(adminRoleKey, string(role))
+		c.Set(apiKeyContextKey, apiKey)
+		c.Next()
+	}
+}
+
+// RequireScope stops api requests made with a key without scope, or whose
+// admin no longer has the role the scope needs.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(apiKeyContextKey)
+		apiKey, ok := value.(*database.AdminApiKey)
+		role := database.AdminRole(c.GetString(adminRoleKey))
+		if !ok || !slices.Contains(apiKey.Scopes, scope) || roleRank(role) < roleRank(apiScopeRoles[scope]) {
+			abortApi(c, http.StatusForbidden, ErrApiScopeNotAllowed.Error())
+			return
+		}
+		c.Next()
+	}
+}
+
+func parseApiScopes(scopes []string) ([]string, error) {
+	parsed := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if _, ok := apiScopeRoles[scope]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidApiScope, scope)
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	if len(parsed) == 0 {
+		return nil, ErrInvalidApiScope
+	}
+	slices.Sort(parsed)
+	return parsed, nil
+}
+
+func ApiKeysTable(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		keys, err := db.GetAdminApiKeys(ctx)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetAdminApiKeys(ctx). %w", err))
+			return
+		}
+
+		now := time.Now().Unix()
+		rows := make([]templates.ApiKeyRow, len(keys))
+		for i, key := range keys {
+			rows[i] = templates.ApiKeyRow{
+				Id:         key.Id,
+				Name:       key.Name,
+				Scopes:     strings.Join(key.Scopes, ", "),
+				CreatedBy:  key.CreatedBy,
+				ExpiresAt:  key.ExpiresAt,
+				LastUsedAt: key.LastUsedAt,
+				Active:     !key.Revoked && key.ExpiresAt > now,
+			}
+		}
+
+		err = templates.ApiKeysTable(rows).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.ApiKeysTable(rows).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+// CreateApiKey stores a new api key and shows it once. Only its hash is kept.
+func CreateApiKey(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		name := strings.TrimSpace(c.PostForm("name"))
+		if name == "" {
+			_ = RenderError(c, "The api key needs a name")
+			return
+		}
+		scopes, err := parseApiScopes(c.PostFormArray("scopes"))
+		if err != nil {
+			_ = RenderError(c, "Pick at least one valid scope")
+			return
+		}
+		days := apiKeyDefaultDays
+		if daysStr := c.PostForm("expires_in_days"); daysStr != "" {
+			days, err = strconv.Atoi(daysStr)
+			if err != nil || days < 1 || days > apiKeyMaxDays {
+				_ = RenderError(c, fmt.Sprintf("Expiry should be between 1 and %d days", apiKeyMaxDays))
+				return
+			}
+		}
+
+		key, keyHash, err := generateApiKey()
+		if err != nil {
+			_ = c.Error(fmt.Errorf("generateApiKey(). %w", err))
+			return
+		}
+		now := time.Now()
+		apiKey := database.AdminApiKey{
+			Id:         uuid.New().String(),
+			Name:       name,
+			KeyHash:    keyHash,
+			Scopes:     scopes,
+			CreatedBy:  auditActor(c),
+			CreatedAt:  now.Unix(),
+			ExpiresAt:  now.AddDate(0, 0, days).Unix(),
+			LastUsedAt: 0,
+			Revoked:    false,
+		}
+		err = db.SaveAdminApiKey(ctx, apiKey)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.SaveAdminApiKey(ctx, apiKey). %w", err))
+			return
+		}
+		recordAuditChange(c, db, audit.ActionApiKeyCreate, nil, map[string]any{
+			"id":         apiKey.Id,
+			"name":       apiKey.Name,
+			"scopes":     apiKey.Scopes,
+			"expires_at": apiKey.ExpiresAt,
+		})
+
+		c.Header("HX-Trigger", "recharge-access")
+		err = templates.ApiKeyCreated(name, key).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.ApiKeyCreated(name, key).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+func RevokeApiKey(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		id := c.Param("id")
+
+		revoked, err := db.RevokeAdminApiKey(ctx, id)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.RevokeAdminApiKey(ctx, id). %w", err))
+			return
+		}
+		if !revoked {
+			c.Status(http.StatusNotFound)
+			if err := RenderError(c, "Api key not found"); err != nil {
+				slog.Warn("failed to render error", slog.Any("error", err))
+			}
+			return
+		}
+		recordAuditChange(c, db, audit.ActionApiKeyRevoke, nil, map[string]string{"id": id})
+
+		c.Header("HX-Trigger", "recharge-access")
+		if err := RenderSuccess(c, "Api key revoked"); err != nil {
+			slog.Warn("failed to render success", slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,211 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/database"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+func apiKeyTestRouter(db database.MintDB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ApiKeyMiddleware(db))
+	r.GET("/keysets", RequireScope(ScopeKeysetsRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.POST("/keysets/rotate", RequireScope(ScopeKeysetsRotate), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestApiKeyMiddleware(t *testing.T) {
+	db := &mockdb.MockDB{
+		Admins: []database.Admin{{Pubkey: "operator", Role: database.AdminOperator}},
+	}
+	key, keyHash, err := generateApiKey()
+	if err != nil {
+		t.Fatalf("generateApiKey: %v", err)
+	}
+	err = db.SaveAdminApiKey(t.Context(), database.AdminApiKey{
+		Id:        "key-1",
+		KeyHash:   keyHash,
+		Scopes:    []string{ScopeKeysetsRead, ScopeKeysetsRotate},
+		CreatedBy: "operator",
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("db.SaveAdminApiKey: %v", err)
+	}
+	r := apiKeyTestRouter(db)
+
+	request := func(method string, path string, key string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request(http.MethodGet, "/keysets", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected a request without a key to be rejected, got %d", code)
+	}
+	if code := request(http.MethodGet, "/keysets", "nutmix_wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown key to be rejected, got %d", code)
+	}
+	if code := request(http.MethodPost, "/keysets/rotate", key); code != http.StatusOK {
+		t.Errorf("expected the key to rotate keysets, got %d", code)
+	}
+	if db.AdminApiKeys[0].LastUsedAt == 0 {
+		t.Error("expected the last use of the key to be stored")
+	}
+
+	// a viewer can not rotate keysets, so neither can the keys it created
+	err = db.SaveAdmin(t.Context(), database.Admin{Pubkey: "operator", Role: database.AdminViewer})
+	if err != nil {
+		t.Fatalf("db.SaveAdmin: %v", err)
+	}
+	if code := request(http.MethodPost, "/keysets/rotate", key); code != http.StatusForbidden {
+		t.Errorf("expected the key to lose the rotate scope with its admin role, got %d", code)
+	}
+	if code := request(http.MethodGet, "/keysets", key); code != http.StatusOK {
+		t.Errorf("expected the key to keep reading keysets, got %d", code)
+	}
+
+	revoked, err := db.RevokeAdminApiKey(t.Context(), "key-1")
+	if err != nil || !revoked {
+		t.Fatalf("db.RevokeAdminApiKey: %v, %v", revoked, err)
+	}
+	if code := request(http.MethodGet, "/keysets", key); code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked key to be rejected, got %d", code)
+	}
+}
+
+func TestRevokeApiKey(t *testing.T) {
+	db := &mockdb.MockDB{
+		AdminApiKeys: []database.AdminApiKey{{Id: "key-1", Scopes: []string{ScopeKeysetsRead}, CreatedBy: "owner"}},
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api-keys/:id/revoke", RevokeApiKey(db))
+
+	request := func(id string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api-keys/"+id+"/revoke", nil))
+		return w.Code
+	}
+
+	if code := request("missing"); code != http.StatusNotFound {
+		t.Errorf("expected an unknown key to be not found, got %d", code)
+	}
+	if len(db.AuditLog) != 0 {
+		t.Errorf("expected no audit entry for an unknown key, got %+v", db.AuditLog)
+	}
+	if code := request("key-1"); code != http.StatusOK {
+		t.Errorf("expected the key to be revoked, got %d", code)
+	}
+	if !db.AdminApiKeys[0].Revoked || len(db.AuditLog) != 1 {
+		t.Errorf("expected the key to be revoked and audited, got %+v, %+v", db.AdminApiKeys[0], db.AuditLog)
+	}
+}
+
+func TestApiKeyExpiredAndMissingScope(t *testing.T) {
+	db := &mockdb.MockDB{
+		Admins: []database.Admin{{Pubkey: "owner", Role: database.AdminOwner}},
+	}
+	expired, expiredHash, err := generateApiKey()
+	if err != nil {
+		t.Fatalf("generateApiKey: %v", err)
+	}
+	readOnly, readOnlyHash, err := generateApiKey()
+	if err != nil {
+		t.Fatalf("generateApiKey: %v", err)
+	}
+	db.AdminApiKeys = []database.AdminApiKey{
+		{Id: "expired", KeyHash: expiredHash, Scopes: []string{ScopeKeysetsRead}, CreatedBy: "owner", ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+		{Id: "read-only", KeyHash: readOnlyHash, Scopes: []string{ScopeKeysetsRead}, CreatedBy: "owner", ExpiresAt: time.Now().Add(time.Hour).Unix()},
+	}
+	r := apiKeyTestRouter(db)
+
+	req := httptest.NewRequest(http.MethodGet, "/keysets", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an expired key to be rejected, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/keysets/rotate", nil)
+	req.Header.Set("Authorization", "Bearer "+readOnly)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected a key without the rotate scope to be rejected, got %d", w.Code)
+	}
+}
+
+func TestParseApiScopes(t *testing.T) {
+	scopes, err := parseApiScopes([]string{ScopeStatsRead, ScopeConfigRead, ScopeStatsRead})
+	if err != nil {
+		t.Fatalf("parseApiScopes: %v", err)
+	}
+	if len(scopes) != 2 || scopes[0] != ScopeConfigRead || scopes[1] != ScopeStatsRead {
+		t.Errorf("expected sorted unique scopes, got %v", scopes)
+	}
+
+	_, err = parseApiScopes([]string{"admins:write"})
+	if err == nil {
+		t.Error("expected an unknown scope to fail")
+	}
+	_, err = parseApiScopes(nil)
+	if err == nil {
+		t.Error("expected a key without scopes to fail")
+	}
+}
+
+func TestApiConfigUpdateApply(t *testing.T) {
+	limit := 1000
+	iconUrl := "https://mint.example/icon.png"
+	config := utils.Config{NAME: "old", PEG_IN_LIMIT_SATS: &limit, PEG_OUT_LIMIT_SATS: &limit, IconUrl: &iconUrl}
+
+	var update ApiConfigUpdate
+	err := json.Unmarshal([]byte(`{"name": "new", "peg_in_limit_sats": null, "icon_url": ""}`), &update)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	updated, err := update.apply(config)
+	if err != nil {
+		t.Fatalf("update.apply: %v", err)
+	}
+	if updated.NAME != "new" {
+		t.Errorf("expected the name to change, got %q", updated.NAME)
+	}
+	if updated.PEG_IN_LIMIT_SATS != nil {
+		t.Errorf("expected a null limit to remove it, got %d", *updated.PEG_IN_LIMIT_SATS)
+	}
+	if updated.PEG_OUT_LIMIT_SATS == nil || *updated.PEG_OUT_LIMIT_SATS != limit {
+		t.Error("expected a missing limit to keep its value")
+	}
+	if updated.IconUrl != nil {
+		t.Error("expected an empty url to remove it")
+	}
+
+	err = json.Unmarshal([]byte(`{"tos_url": "not a url"}`), &update)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	_, err = update.apply(config)
+	if err == nil {
+		t.Error("expected an invalid url to fail")
+	}
+}
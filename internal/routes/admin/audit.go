@@ -0,0 +1,248 @@
+package admin
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+)
+
+// AuditSigningKeyEnv is a hex encoded 32 byte key used to sign audit log
+// exports. Without it exports are turned off.
+const AuditSigningKeyEnv = "AUDIT_SIGNING_KEY"
+
+const auditTableLimit = 200
+
+// auditSigningKey returns nil when AuditSigningKeyEnv is not set, an export
+// signed with a key nobody can check it against proves nothing.
+func auditSigningKey() (*secp256k1.PrivateKey, error) {
+	keyHex := os.Getenv(AuditSigningKeyEnv)
+	if keyHex == "" {
+		slog.Warn(fmt.Sprintf("%s is not set. Audit log exports are turned off", AuditSigningKeyEnv))
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString(%s). %w", AuditSigningKeyEnv, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s should be 32 bytes, got %d", AuditSigningKeyEnv, len(key))
+	}
+	return secp256k1.PrivKeyFromBytes(key), nil
+}
+
+// auditActor returns the pubkey of the admin that made the request.
+func auditActor(c *gin.Context) string {
+	return c.GetString(adminPubkeyKey)
+}
+
+// recordAudit stores an action in the audit log. The action already happened
+// when this is called, so a failure is logged instead of failing the request.
+func recordAudit(c *gin.Context, db database.MintDB, action string, actor string, diff map[string]database.AuditChange) {
+	saveAuditEntry(c.Request.Context(), db, action, actor, c.ClientIP(), diff)
+}
+
+// saveAuditEntry is recordAudit for callers outside of a gin request.
+func saveAuditEntry(ctx context.Context, db database.MintDB, action string, actor string, ip string, diff map[string]database.AuditChange) {
+	entry := database.AuditEntry{
+		Diff:      diff,
+		Actor:     actor,
+		Action:    action,
+		Ip:        ip,
+		Id:        0,
+		CreatedAt: time.Now().Unix(),
+	}
+	err := db.SaveAuditEntry(ctx, entry)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not save audit entry", slog.String("action", action), slog.Any("error", err))
+	}
+}
+
+// recordAuditChange stores the fields that changed between before and after.
+func recordAuditChange(c *gin.Context, db database.MintDB, action string, before any, after any) {
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "audit.Diff(before, after)", slog.String("action", action), slog.Any("error", err))
+		return
+	}
+	if len(diff) == 0 {
+		return
+	}
+	recordAudit(c, db, action, auditActor(c), diff)
+}
+
+// recordConfigRevision stores the fields a config change changed.
+func recordConfigRevision(c *gin.Context, db database.MintDB, action string, revision database.ConfigRevision) {
+	if len(revision.Diff) == 0 {
+		return
+	}
+	recordAudit(c, db, action, auditActor(c), revision.Diff)
+}
+
+// recordAuditDetails stores an action with the values it was made with.
+func recordAuditDetails(c *gin.Context, db database.MintDB, action string, details any) {
+	diff, err := audit.Details(details)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "audit.Details(details)", slog.String("action", action), slog.Any("error", err))
+		return
+	}
+	recordAudit(c, db, action, auditActor(c), diff)
+}
+
+func auditFilterFromQuery(c *gin.Context) (database.AuditFilter, error) {
+	filter := database.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Since:  0,
+		Until:  0,
+		Limit:  0,
+	}
+	if since := c.Query("since"); since != "" {
+		day, err := time.Parse(time.DateOnly, since)
+		if err != nil {
+			return filter, fmt.Errorf("time.Parse(time.DateOnly, since). %w", err)
+		}
+		filter.Since = day.Unix()
+	}
+	if until := c.Query("until"); until != "" {
+		day, err := time.Parse(time.DateOnly, until)
+		if err != nil {
+			return filter, fmt.Errorf("time.Parse(time.DateOnly, until). %w", err)
+		}
+		// include the whole day
+		filter.Until = day.Add(24*time.Hour).Unix() - 1
+	}
+	return filter, nil
+}
+
+func AuditPage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actions := []string{
+			audit.ActionLogin,
+			audit.ActionLoginFailed,
+			audit.ActionLogout,
+			audit.ActionAdminAdd,
+			audit.ActionAdminRole,
+			audit.ActionAdminRemove,
+			audit.ActionSessionRevoke,
+			audit.ActionApiKeyCreate,
+			audit.ActionApiKeyRevoke,
+			audit.ActionConfigGeneral,
+			audit.ActionConfigLightning,
+			audit.ActionConfigBackend,
+			audit.ActionConfigAuth,
+			audit.ActionConfigRollback,
+			audit.ActionKeysetRotate,
+			audit.ActionKeysetPolicy,
+			audit.ActionSecretsRotate,
+			audit.ActionSecretsRewrap,
+			audit.ActionSignerUnlock,
+			audit.ActionSignerUnlockFailed,
+			audit.ActionBackupRecover,
+			audit.ActionLiquiditySwapOut,
+			audit.ActionLiquiditySwapIn,
+			audit.ActionLiquidityConfirmed,
+		}
+		err := templates.AuditPage(actions).Render(c.Request.Context(), c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.AuditPage(actions).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+func AuditTable(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		filter, err := auditFilterFromQuery(c)
+		if err != nil {
+			_ = RenderError(c, "Invalid date in the filter")
+			return
+		}
+		filter.Limit = auditTableLimit
+
+		entries, err := db.GetAuditEntries(ctx, filter)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetAuditEntries(ctx, filter). %w", err))
+			return
+		}
+
+		rows := make([]templates.AuditRow, len(entries))
+		for i, entry := range entries {
+			diff, err := json.Marshal(entry.Diff)
+			if err != nil {
+				_ = c.Error(fmt.Errorf("json.Marshal(entry.Diff). %w", err))
+				return
+			}
+			rows[i] = templates.AuditRow{
+				Actor:     entry.Actor,
+				Action:    entry.Action,
+				Ip:        entry.Ip,
+				Diff:      string(diff),
+				CreatedAt: entry.CreatedAt,
+			}
+		}
+
+		err = templates.AuditTable(rows).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.AuditTable(rows).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+// AuditExport returns the filtered audit log signed with the audit signing key
+// so it can be verified after leaving the mint.
+func AuditExport(db database.MintDB, key *secp256k1.PrivateKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == nil {
+			c.JSON(503, fmt.Sprintf("Audit log exports are turned off, set %s to sign them", AuditSigningKeyEnv))
+			return
+		}
+		ctx := c.Request.Context()
+		filter, err := auditFilterFromQuery(c)
+		if err != nil {
+			c.JSON(400, "Invalid date in the filter")
+			return
+		}
+
+		entries, err := db.GetAuditEntries(ctx, filter)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetAuditEntries(ctx, filter). %w", err))
+			return
+		}
+
+		now := time.Now()
+		export, err := audit.SignExport(entries, filter, key, now)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("audit.SignExport(entries, filter, key, now). %w", err))
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-log-%d.json", now.Unix()))
+		c.JSON(200, export)
+	}
+}
+
+// AuditPubkey returns the pubkey audit log exports are signed with, so they
+// can be verified against a key the operator published beforehand.
+func AuditPubkey(key *secp256k1.PrivateKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == nil {
+			c.JSON(404, fmt.Sprintf("%s is not set", AuditSigningKeyEnv))
+			return
+		}
+		c.JSON(200, gin.H{"pubkey": hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))})
+	}
+}
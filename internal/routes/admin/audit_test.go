@@ -0,0 +1,70 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+)
+
+func auditTestRouter(key *secp256k1.PrivateKey) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/audit/export", AuditExport(&mockdb.MockDB{}, key))
+	router.GET("/audit/pubkey", AuditPubkey(key))
+	return router
+}
+
+func TestAuditExportNeedsSigningKey(t *testing.T) {
+	t.Setenv(AuditSigningKeyEnv, "")
+	key, err := auditSigningKey()
+	if err != nil || key != nil {
+		t.Fatalf("expected no key and no error, got %v %v", key, err)
+	}
+	router := auditTestRouter(key)
+
+	for path, status := range map[string]int{"/audit/export": http.StatusServiceUnavailable, "/audit/pubkey": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("expected %d from %s, got %d", status, path, w.Code)
+		}
+	}
+}
+
+func TestAuditExportVerifiesWithPublishedPubkey(t *testing.T) {
+	t.Setenv(AuditSigningKeyEnv, "0000000000000000000000000000000000000000000000000000000000000001")
+	key, err := auditSigningKey()
+	if err != nil {
+		t.Fatalf("auditSigningKey() %+v", err)
+	}
+	router := auditTestRouter(key)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit/pubkey", nil))
+	var published struct {
+		Pubkey string `json:"pubkey"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &published)
+	if err != nil {
+		t.Fatalf("json.Unmarshal(pubkey) %+v", err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit/export", nil))
+	var export audit.Export
+	err = json.Unmarshal(w.Body.Bytes(), &export)
+	if err != nil {
+		t.Fatalf("json.Unmarshal(export) %+v", err)
+	}
+	err = audit.VerifyExport(export, published.Pubkey)
+	if err != nil {
+		t.Errorf("audit.VerifyExport(export, pubkey) %+v", err)
+	}
+}
@@ -1,19 +1,21 @@
 package admin
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
 	"github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/utils"
 	"github.com/nbd-wtf/go-nostr"
@@ -21,6 +23,9 @@ import (
 
 const AdminAuthKey = "admin-cookie"
 
+// adminPubkeyKey holds the nostr pubkey of the logged in admin in the gin context.
+const adminPubkeyKey = "admin-pubkey"
+
 func handleUnauthorized(c *gin.Context) {
 	slog.Debug("Handling unauthorized request", slog.String("path", c.Request.URL.Path), slog.String("method", c.Request.Method))
 	c.SetCookie(AdminAuthKey, "", -1, "/", "", false, true)
@@ -33,12 +38,30 @@ func handleUnauthorized(c *gin.Context) {
 	c.Abort()
 }
 
-func AuthMiddleware(secret []byte, blacklist *TokenBlacklist) gin.HandlerFunc {
+// isLoginPath reports whether path can be reached without being logged in.
+func isLoginPath(path string) bool {
+	return path == "/admin/login" || path == "/admin/login/bunker"
+}
+
+func AuthMiddleware(secret []byte, blacklist SessionBlacklist, db database.MintDB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		cookie, err := c.Cookie(AdminAuthKey)
 		if err != nil {
 			slog.Debug("No admin cookie found", slog.String("error", err.Error()))
-			if c.Request.URL.Path == "/admin/login" {
+			if isLoginPath(c.Request.URL.Path) {
+				return
+			}
+			if strings.HasPrefix(c.GetHeader("Authorization"), nip98AuthScheme) {
+				admin, err := nip98Admin(c, db)
+				if err != nil {
+					slog.Debug("nip98Admin(c, db)", slog.String(utils.LogExtraInfo, err.Error()))
+					c.AbortWithStatusJSON(http.StatusUnauthorized, "Invalid NIP-98 authorization")
+					return
+				}
+				c.Set(adminPubkeyKey, admin.Pubkey)
+				c.Set(adminRoleKey, string(admin.Role))
+				c.Set(adminSessionKey, "")
+				c.Next()
 				return
 			}
 			handleUnauthorized(c)
@@ -76,6 +99,18 @@ func AuthMiddleware(secret []byte, blacklist *TokenBlacklist) gin.HandlerFunc {
 			return
 		}
 
+		// the session and the admin are checked on every request so a revoked
+		// session or a removed admin lose access straight away
+		admin, sessionId, err := activeAdminSession(c.Request.Context(), db, token)
+		if err != nil {
+			slog.Debug("admin session is not active", slog.String(utils.LogExtraInfo, err.Error()))
+			handleUnauthorized(c)
+			return
+		}
+		c.Set(adminPubkeyKey, admin.Pubkey)
+		c.Set(adminRoleKey, string(admin.Role))
+		c.Set(adminSessionKey, sessionId)
+
 		// Success path
 		if c.Request.URL.Path == "/admin/login" {
 			slog.Debug("Redirecting to /admin from login page")
@@ -89,15 +124,47 @@ func AuthMiddleware(secret []byte, blacklist *TokenBlacklist) gin.HandlerFunc {
 	}
 }
 
-var ErrIncorrectNpub = errors.New("incorrect npub used in signature")
+var (
+	ErrIncorrectNpub       = errors.New("incorrect npub used in signature")
+	ErrAdminSessionExpired = errors.New("admin session is not active")
+)
 
-func LoginPost(mint *mint.Mint, loginKey *secp256k1.PrivateKey, adminNostrPubkey *btcec.PublicKey) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if adminNostrPubkey == nil {
-			slog.Error("adminNostrPubkey is nil. this should have never happened")
-			panic("adminNostrPubkey is nil")
-		}
+func activeAdminSession(ctx context.Context, db database.MintDB, token *jwt.Token) (*database.Admin, string, error) {
+	sessionId, err := jwtID(token)
+	if err != nil {
+		return nil, "", err
+	}
+	session, err := db.GetAdminSession(ctx, sessionId)
+	if err != nil {
+		return nil, "", fmt.Errorf("db.GetAdminSession(ctx, sessionId). %w", err)
+	}
+	if session == nil || session.Revoked || session.ExpiresAt <= time.Now().Unix() {
+		return nil, "", ErrAdminSessionExpired
+	}
+	admin, err := db.GetAdmin(ctx, session.Pubkey)
+	if err != nil {
+		return nil, "", fmt.Errorf("db.GetAdmin(ctx, session.Pubkey). %w", err)
+	}
+	if admin == nil {
+		return nil, "", ErrAdminSessionExpired
+	}
+	return admin, sessionId, nil
+}
+
+func jwtID(token *jwt.Token) (string, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", ErrAdminSessionExpired
+	}
+	id, ok := claims["jti"].(string)
+	if !ok || id == "" {
+		return "", ErrAdminSessionExpired
+	}
+	return id, nil
+}
 
+func LoginPost(mint *mint.Mint, loginKey *secp256k1.PrivateKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		// parse data for login
 		slog.Debug("Attempting log in")
 		var nostrEvent nostr.Event
@@ -110,108 +177,128 @@ func LoginPost(mint *mint.Mint, loginKey *secp256k1.PrivateKey, adminNostrPubkey
 			c.JSON(400, "Malformed body request")
 			return
 		}
-		ctx := c.Request.Context()
+		loginWithNostrEvent(c, mint, loginKey, nostrEvent)
+	}
+}
 
-		tx, err := mint.MintDB.GetTx(ctx)
-		if err != nil {
-			_ = c.Error(fmt.Errorf("mint.MintDB.GetTx(). %w", err))
-			return
-		}
+// loginWithNostrEvent starts an admin session when nostrEvent signs an unused
+// login nonce with the key of an admin.
+func loginWithNostrEvent(c *gin.Context, mint *mint.Mint, loginKey *secp256k1.PrivateKey, nostrEvent nostr.Event) {
+	ctx := c.Request.Context()
 
-		defer func() {
-			if p := recover(); p != nil {
-				recoveredErr, ok := p.(error)
-				if ok {
-					_ = c.Error(fmt.Errorf("rolling back because of failure: %w", recoveredErr))
-				} else {
-					_ = c.Error(fmt.Errorf("rolling back because of failure: %v", p))
-				}
-				rollbackErr := mint.MintDB.Rollback(ctx, tx)
-				if rollbackErr != nil {
-					if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-						slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
-					}
-				}
-			} else if err != nil {
-				_ = c.Error(fmt.Errorf("rolling back because of failure: %w", err))
-				rollbackErr := mint.MintDB.Rollback(ctx, tx)
-				if rollbackErr != nil {
-					if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-						slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
-					}
-				}
+	tx, err := mint.MintDB.GetTx(ctx)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("mint.MintDB.GetTx(). %w", err))
+		return
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			recoveredErr, ok := p.(error)
+			if ok {
+				_ = c.Error(fmt.Errorf("rolling back because of failure: %w", recoveredErr))
 			} else {
-				err = mint.MintDB.Commit(ctx, tx)
-				if err != nil {
-					_ = c.Error(fmt.Errorf("failed to commit transaction: %w", err))
+				_ = c.Error(fmt.Errorf("rolling back because of failure: %v", p))
+			}
+			rollbackErr := mint.MintDB.Rollback(ctx, tx)
+			if rollbackErr != nil {
+				if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+					slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
 				}
 			}
-		}()
-
-		nostrLogin, err := mint.MintDB.GetNostrAuth(tx, nostrEvent.Content)
-		if err != nil {
-			_ = c.Error(errors.Join(ErrCouldNotParseLogin, err))
-			return
-		}
-
-		if nostrLogin.Activated {
-			c.JSON(403, "This login value was already used, please reload the page")
-			return
+		} else if err != nil {
+			_ = c.Error(fmt.Errorf("rolling back because of failure: %w", err))
+			rollbackErr := mint.MintDB.Rollback(ctx, tx)
+			if rollbackErr != nil {
+				if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+					slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
+				}
+			}
+		} else {
+			err = mint.MintDB.Commit(ctx, tx)
+			if err != nil {
+				_ = c.Error(fmt.Errorf("failed to commit transaction: %w", err))
+			}
 		}
+	}()
 
-		// check valid signature
-		validSig, err := nostrEvent.CheckSignature()
-		if err != nil {
-			_ = c.Error(errors.Join(ErrInvalidNostrSignature, err))
-			return
-		}
+	nostrLogin, err := mint.MintDB.GetNostrAuth(tx, nostrEvent.Content)
+	if err != nil {
+		_ = c.Error(errors.Join(ErrCouldNotParseLogin, err))
+		return
+	}
 
-		if !validSig {
-			_ = c.Error(errors.Join(ErrInvalidNostrSignature, err))
-			return
-		}
+	if nostrLogin.Activated {
+		c.JSON(403, "This login value was already used, please reload the page")
+		return
+	}
 
-		// check signature happened with the correct private key.
-		sigBytes, err := hex.DecodeString(nostrEvent.Sig)
-		if err != nil {
-			_ = c.Error(errors.Join(ErrInvalidNostrSignature, err))
-			return
-		}
+	// check valid signature
+	validSig, err := nostrEvent.CheckSignature()
+	if err != nil {
+		_ = c.Error(errors.Join(ErrInvalidNostrSignature, err))
+		return
+	}
 
-		sig, err := schnorr.ParseSignature(sigBytes)
-		if err != nil {
-			_ = c.Error(errors.Join(ErrInvalidNostrSignature, err))
-			return
-		}
+	if !validSig {
+		_ = c.Error(errors.Join(ErrInvalidNostrSignature, err))
+		return
+	}
 
-		eventHash := sha256.Sum256(nostrEvent.Serialize())
-		verified := sig.Verify(eventHash[:], adminNostrPubkey)
-		if !verified {
-			_ = c.Error(ErrIncorrectNpub)
-			return
-		}
+	// the signature is valid for the event pubkey, so it only needs to be an admin.
+	admin, err := mint.MintDB.GetAdmin(ctx, nostrEvent.PubKey)
+	if err != nil {
+		_ = c.Error(errors.Join(ErrCouldNotParseLogin, fmt.Errorf("mint.MintDB.GetAdmin(ctx, nostrEvent.PubKey). %w", err)))
+		return
+	}
+	if admin == nil {
+		recordAudit(c, mint.MintDB, audit.ActionLoginFailed, nostrEvent.PubKey, nil)
+		_ = c.Error(ErrIncorrectNpub)
+		return
+	}
 
-		nostrLogin.Activated = verified
-		err = mint.MintDB.UpdateNostrAuthActivation(tx, nostrLogin.Nonce, nostrLogin.Activated)
-		if err != nil {
-			_ = c.Error(errors.Join(ErrCouldNotParseLogin, fmt.Errorf("mint.MintDB.UpdateNostrAuthActivation(tx, nostrLogin.Nonce, nostrLogin.Activated). %w", err)))
-			return
-		}
+	nostrLogin.Activated = true
+	err = mint.MintDB.UpdateNostrAuthActivation(tx, nostrLogin.Nonce, nostrLogin.Activated)
+	if err != nil {
+		_ = c.Error(errors.Join(ErrCouldNotParseLogin, fmt.Errorf("mint.MintDB.UpdateNostrAuthActivation(tx, nostrLogin.Nonce, nostrLogin.Activated). %w", err)))
+		return
+	}
 
-		token, err := makeJWTToken(loginKey.Serialize())
-		if err != nil {
-			_ = c.Error(fmt.Errorf("makeJWTToken(loginKey.Serialize()). %w", err))
-			return
-		}
+	now := time.Now()
+	session := database.AdminSession{
+		Id:        uuid.New().String(),
+		Pubkey:    admin.Pubkey,
+		Ip:        c.ClientIP(),
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(adminSessionDuration).Unix(),
+		Revoked:   false,
+	}
+	err = mint.MintDB.SaveAdminSession(ctx, session)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("mint.MintDB.SaveAdminSession(ctx, session). %w", err))
+		return
+	}
 
-		c.SetCookie(AdminAuthKey, token, 3600, "/", "", false, true)
-		c.Header("HX-Redirect", "/admin")
-		c.JSON(200, nil)
+	token, err := makeJWTToken(loginKey.Serialize(), session)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("makeJWTToken(loginKey.Serialize(), session). %w", err))
+		return
 	}
+	recordAudit(c, mint.MintDB, audit.ActionLogin, admin.Pubkey, nil)
+
+	c.SetCookie(AdminAuthKey, token, int(adminSessionDuration.Seconds()), "/", "", false, true)
+	c.Header("HX-Redirect", "/admin")
+	c.JSON(200, nil)
 }
 
-func makeJWTToken(secret []byte) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+func makeJWTToken(secret []byte, session database.AdminSession) (string, error) {
+	//nolint:exhaustruct
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ID:        session.Id,
+		Subject:   session.Pubkey,
+		IssuedAt:  jwt.NewNumericDate(time.Unix(session.CreatedAt, 0)),
+		ExpiresAt: jwt.NewNumericDate(time.Unix(session.ExpiresAt, 0)),
+	})
 	string, err := token.SignedString(secret)
 	if err != nil {
 		return "", fmt.Errorf("token.SignedString(secret) %w", err)
@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// bunkerRelayMaxMessage is the largest relay message forwarded, NIP-46
+// requests and responses are a few kilobytes.
+const bunkerRelayMaxMessage = 1 << 20
+
+// specialPurposePrefixes are the ranges that are not private but do not reach
+// the public internet either, or that wrap an address that might not.
+var specialPurposePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("3fff::/20"),
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range specialPurposePrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicAddrOnly is a net.Dialer control function that refuses to connect to
+// anything but a public address. It runs on the address being connected to,
+// so a relay can not pass the check with one DNS answer and connect with
+// another.
+func publicAddrOnly(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: netip.ParseAddrPort(%s). %w", ErrBunkerRelay, address, err)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s is not a public address", ErrBunkerRelay, addrPort.Addr())
+	}
+	return nil
+}
+
+// relayProxy forwards the relay traffic of a bunker login. The nostr pool
+// can not be given a dialer, so it talks to the proxy on the loopback and the
+// proxy dials the relays with publicAddrOnly.
+type relayProxy struct {
+	listener net.Listener
+	server   *http.Server
+	dialer   websocket.Dialer
+	// targets are the relays by the path the pool connects to
+	targets  map[string]string
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	rejected error
+}
+
+// newRelayProxy starts a proxy for relays and returns the url the pool has to
+// use for each of them.
+func newRelayProxy(relays []string) (*relayProxy, map[string]string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("net.Listen(tcp, 127.0.0.1:0). %w", err)
+	}
+	netDialer := &net.Dialer{Timeout: bunkerStepTimeout, Control: publicAddrOnly} //nolint:exhaustruct
+	proxy := &relayProxy{
+		listener: listener,
+		server:   nil,
+		dialer: websocket.Dialer{ //nolint:exhaustruct
+			NetDialContext:   netDialer.DialContext,
+			HandshakeTimeout: bunkerStepTimeout,
+		},
+		targets: make(map[string]string, len(relays)),
+		upgrader: websocket.Upgrader{ //nolint:exhaustruct
+			HandshakeTimeout: bunkerStepTimeout,
+		},
+		mu:       sync.Mutex{},
+		rejected: nil,
+	}
+
+	local := make(map[string]string, len(relays))
+	for _, relay := range relays {
+		token := make([]byte, 16)
+		_, err := rand.Read(token)
+		if err != nil {
+			_ = listener.Close()
+			return nil, nil, fmt.Errorf("rand.Read(token). %w", err)
+		}
+		path := "/" + hex.EncodeToString(token)
+		proxy.targets[path] = relay
+		local[relay] = "ws://" + listener.Addr().String() + path
+	}
+
+	proxy.server = &http.Server{Handler: proxy, ReadHeaderTimeout: bunkerStepTimeout} //nolint:exhaustruct
+	go func() {
+		_ = proxy.server.Serve(listener)
+	}()
+	return proxy, local, nil
+}
+
+// err is the first relay connection refused by publicAddrOnly.
+func (p *relayProxy) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rejected
+}
+
+func (p *relayProxy) Close() error {
+	return p.server.Close()
+}
+
+func (p *relayProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target, ok := p.targets[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), bunkerStepTimeout)
+	defer cancel()
+	upstream, _, err := p.dialer.DialContext(ctx, target, nil)
+	if err != nil {
+		if errors.Is(err, ErrBunkerRelay) {
+			p.mu.Lock()
+			if p.rejected == nil {
+				p.rejected = err
+			}
+			p.mu.Unlock()
+		}
+		http.Error(w, "could not reach the relay", http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	downstream, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer downstream.Close()
+
+	upstream.SetReadLimit(bunkerRelayMaxMessage)
+	downstream.SetReadLimit(bunkerRelayMaxMessage)
+	done := make(chan struct{}, 2)
+	go forwardRelayMessages(downstream, upstream, done)
+	go forwardRelayMessages(upstream, downstream, done)
+	// closing both ends stops the other direction
+	<-done
+}
+
+func forwardRelayMessages(from *websocket.Conn, to *websocket.Conn, done chan<- struct{}) {
+	defer func() {
+		done <- struct{}{}
+	}()
+	for {
+		messageType, message, err := from.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = to.SetWriteDeadline(time.Now().Add(bunkerStepTimeout))
+		err = to.WriteMessage(messageType, message)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// proxiedBunkerURL replaces the relays of bunkerURL with their proxy urls.
+func proxiedBunkerURL(bunkerURL string, local map[string]string) (string, error) {
+	parsed, err := url.Parse(bunkerURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: url.Parse(bunkerURL). %w", ErrInvalidBunkerURL, err)
+	}
+	query := parsed.Query()
+	relays := query["relay"]
+	proxied := make([]string, len(relays))
+	for i, relay := range relays {
+		proxied[i] = local[nostr.NormalizeURL(relay)]
+	}
+	query["relay"] = proxied
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
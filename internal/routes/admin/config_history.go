@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	m "github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+const configHistoryLimit = 50
+
+func configRevisionRows(revisions []database.ConfigRevision) []templates.ConfigRevisionRow {
+	rows := make([]templates.ConfigRevisionRow, len(revisions))
+	for i, revision := range revisions {
+		fields := make([]string, 0, len(revision.Diff))
+		for field := range revision.Diff {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		changes := strings.Join(fields, ", ")
+		if revision.Actor == m.ConfigRevisionSystemActor && len(revision.Diff) == 0 {
+			changes = "settings before the first recorded change"
+		}
+
+		rows[i] = templates.ConfigRevisionRow{
+			RolledBackFrom: revision.RolledBackFrom,
+			Actor:          revision.Actor,
+			Changes:        changes,
+			Id:             revision.Id,
+			CreatedAt:      revision.CreatedAt,
+			// revisions come newest first
+			Current: i == 0,
+		}
+	}
+	return rows
+}
+
+func ConfigHistoryTable(db database.MintDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		revisions, err := db.GetConfigRevisions(ctx, configHistoryLimit)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("db.GetConfigRevisions(ctx, configHistoryLimit). %w", err))
+			return
+		}
+
+		err = templates.ConfigHistoryTable(configRevisionRows(revisions)).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.ConfigHistoryTable(rows).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+// RollbackConfig applies the config of an earlier revision. The page is
+// reloaded so every settings form shows the restored values.
+func RollbackConfig(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			_ = RenderError(c, "Invalid revision")
+			return
+		}
+
+		revision, err := mint.RollbackConfig(c.Request.Context(), id, auditActor(c))
+		if errors.Is(err, m.ErrConfigRevisionNotFound) {
+			_ = RenderError(c, "Revision not found")
+			return
+		}
+		if err != nil {
+			slog.Warn(
+				"mint.RollbackConfig(ctx, id, auditActor(c))",
+				slog.String(utils.LogExtraInfo, err.Error()))
+			if renderErr := RenderError(c, configErrorMessage(err)); renderErr != nil {
+				slog.Warn("failed to render error", slog.Any("error", renderErr))
+			}
+			return
+		}
+		recordConfigRevision(c, mint.MintDB, audit.ActionConfigRollback, revision)
+
+		c.Header("HX-Refresh", "true")
+		if err := RenderSuccess(c, fmt.Sprintf("Settings rolled back to revision %d", id)); err != nil {
+			slog.Warn("failed to render success", slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+func configHistoryTestMint() (*mint.Mint, *mockdb.MockDB) {
+	var config utils.Config
+	config.Default()
+	var mintInstance mint.Mint
+	mintInstance.SetConfig(config)
+	mintInstance.SetLightningBackend(lightning.FakeWallet{})
+
+	var mockDatabase mockdb.MockDB
+	mockDatabase.Config = config
+	mintInstance.MintDB = &mockDatabase
+	return &mintInstance, &mockDatabase
+}
+
+func TestUpdateConfigStoresRevisions(t *testing.T) {
+	mintInstance, db := configHistoryTestMint()
+	ctx := t.Context()
+
+	config := mintInstance.Config()
+	config.MOTD = "first"
+	_, err := mintInstance.UpdateConfig(ctx, config, "alice")
+	if err != nil {
+		t.Fatalf("mintInstance.UpdateConfig: %v", err)
+	}
+	config.MOTD = "second"
+	config.NETWORK = lightning.REGTEST
+	revision, err := mintInstance.UpdateConfig(ctx, config, "bob")
+	if err != nil {
+		t.Fatalf("mintInstance.UpdateConfig: %v", err)
+	}
+
+	if len(db.ConfigRevisions) != 3 || db.ConfigRevisions[0].Actor != mint.ConfigRevisionSystemActor {
+		t.Fatalf("expected the initial config and two revisions, got %+v", db.ConfigRevisions)
+	}
+	if revision.Id != 3 || revision.Actor != "bob" || len(revision.Diff) != 2 {
+		t.Errorf("unexpected revision %+v", revision)
+	}
+	if db.Config.MOTD != "second" || mintInstance.Config().MOTD != "second" {
+		t.Error("expected the config to be stored and applied")
+	}
+	if mintInstance.LightningBackend().GetNetwork().Name != "regtest" {
+		t.Errorf("expected the backend to be rebuilt for regtest, got %s", mintInstance.LightningBackend().GetNetwork().Name)
+	}
+
+	invalid := mintInstance.Config()
+	invalid.MOTD = "never applied"
+	invalid.MINT_QUOTE_TTL = 0
+	_, err = mintInstance.UpdateConfig(ctx, invalid, "bob")
+	if !errors.Is(err, mint.ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error, got %v", err)
+	}
+	if mintInstance.Config().MOTD != "second" || len(db.ConfigRevisions) != 3 {
+		t.Error("expected an invalid config to change nothing")
+	}
+}
+
+// closingBackend records when the mint closes it.
+type closingBackend struct {
+	lightning.FakeWallet
+	closed *bool
+}
+
+func (c closingBackend) Close() error {
+	*c.closed = true
+	return nil
+}
+
+func TestUpdateConfigClosesReplacedBackend(t *testing.T) {
+	mintInstance, _ := configHistoryTestMint()
+	closed := false
+	mintInstance.SetLightningBackend(closingBackend{FakeWallet: lightning.FakeWallet{Network: *mintInstance.LightningBackend().GetNetwork()}, closed: &closed})
+
+	config := mintInstance.Config()
+	config.MOTD = "same backend"
+	_, err := mintInstance.UpdateConfig(t.Context(), config, "alice")
+	if err != nil {
+		t.Fatalf("mintInstance.UpdateConfig: %v", err)
+	}
+	if closed {
+		t.Error("expected the backend to stay open when its config did not change")
+	}
+
+	config.NETWORK = lightning.REGTEST
+	_, err = mintInstance.UpdateConfig(t.Context(), config, "alice")
+	if err != nil {
+		t.Fatalf("mintInstance.UpdateConfig: %v", err)
+	}
+	if !closed {
+		t.Error("expected the replaced backend to be closed")
+	}
+}
+
+func TestRollbackConfig(t *testing.T) {
+	mintInstance, db := configHistoryTestMint()
+	config := mintInstance.Config()
+	config.MOTD = "maintenance"
+	config.NETWORK = lightning.REGTEST
+	_, err := mintInstance.UpdateConfig(t.Context(), config, "alice")
+	if err != nil {
+		t.Fatalf("mintInstance.UpdateConfig: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/config-history/:id/rollback", RollbackConfig(mintInstance))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/config-history/1/rollback", nil))
+	if recorder.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("expected the page to be refreshed, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if mintInstance.Config().MOTD != "" || mintInstance.Config().NETWORK != lightning.MAINNET {
+		t.Errorf("expected the initial config back, got %+v", mintInstance.Config())
+	}
+	if mintInstance.LightningBackend().GetNetwork().Name != "mainnet" {
+		t.Errorf("expected the backend to be rebuilt for mainnet, got %s", mintInstance.LightningBackend().GetNetwork().Name)
+	}
+	latest := db.ConfigRevisions[len(db.ConfigRevisions)-1]
+	if latest.Id != 3 || latest.RolledBackFrom == nil || *latest.RolledBackFrom != 1 {
+		t.Errorf("expected the rollback to be a new revision, got %+v", latest)
+	}
+	if len(db.AuditLog) != 1 || db.AuditLog[0].Action != audit.ActionConfigRollback {
+		t.Errorf("expected a rollback audit entry, got %+v", db.AuditLog)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/config-history/42/rollback", nil))
+	if recorder.Header().Get("HX-Refresh") != "" || !strings.Contains(recorder.Body.String(), "Revision not found") {
+		t.Errorf("expected an unknown revision to fail, got %s", recorder.Body.String())
+	}
+
+	rows := configRevisionRows(db.ConfigRevisions)
+	if rows[0].Changes != "settings before the first recorded change" || rows[1].Changes != "MOTD, NETWORK" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+}
+
+func TestChangeConfigKeepsConcurrentChanges(t *testing.T) {
+	mintInstance, db := configHistoryTestMint()
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			_, err := mintInstance.ChangeConfig(t.Context(), "alice", func(config *utils.Config) error {
+				config.URLS = append(config.URLS, fmt.Sprintf("https://mint%d.example.com", i))
+				return nil
+			})
+			if err != nil {
+				t.Errorf("mintInstance.ChangeConfig: %v", err)
+			}
+		})
+		wg.Go(func() {
+			_ = mintInstance.Config().URLS
+			_ = mintInstance.LightningBackend()
+		})
+	}
+	wg.Wait()
+
+	if len(mintInstance.Config().URLS) != 10 || len(db.Config.URLS) != 10 {
+		t.Errorf("expected every change to be kept, got %v", mintInstance.Config().URLS)
+	}
+
+	before := mintInstance.Config()
+	_, err := mintInstance.ChangeConfig(t.Context(), "alice", func(config *utils.Config) error {
+		config.MOTD = "never applied"
+		return mint.ErrInvalidConfig
+	})
+	if !errors.Is(err, mint.ErrInvalidConfig) || mintInstance.Config().MOTD != before.MOTD {
+		t.Errorf("expected a failed change to keep the config, got %v", err)
+	}
+}
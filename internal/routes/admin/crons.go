@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"slices"
-	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/lescuer97/nutmix/api/cashu"
@@ -17,10 +15,45 @@ import (
 	"github.com/lightningnetwork/lnd/zpay32"
 )
 
-// newLiquidity is a channel that will be used to send new liquidity swap ids to the checked
-func CheckStatusOfLiquiditySwaps(mint *m.Mint, newLiquidity chan string) {
-	ctx := context.Background()
+// CheckStatusOfLiquiditySwaps checks every liquidity swap that is waiting on
+// a lightning payment once and stores its new state. It is run by the job
+// scheduler.
+func CheckStatusOfLiquiditySwaps(ctx context.Context, mint *m.Mint) error {
 	tx, err := mint.MintDB.GetTx(ctx)
+	if err != nil {
+		return fmt.Errorf("mint.MintDB.GetTx(ctx). %w", err)
+	}
+	defer func() {
+		rollbackErr := mint.MintDB.Rollback(ctx, tx)
+		if rollbackErr != nil {
+			if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+				slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
+			}
+		}
+	}()
+	swaps, err := mint.MintDB.GetLiquiditySwapsByStates(tx, []utils.SwapState{
+		utils.MintWaitingPaymentRecv,
+		utils.LightningPaymentPending,
+	})
+	if err != nil {
+		return fmt.Errorf("mint.MintDB.GetLiquiditySwapsByStates(). %w", err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("tx.Commit(ctx). %w", err)
+	}
+
+	slog.Debug("Checking liquidity swaps", slog.String("swaps", fmt.Sprintf("%v", swaps)))
+	for _, swapId := range swaps {
+		checkLiquiditySwap(ctx, mint, swapId)
+	}
+	return nil
+}
+
+func checkLiquiditySwap(ctx context.Context, mint *m.Mint, swapId string) {
+	slog.Debug("Checking out swap", slog.String("swap_id", swapId))
+
+	swapTx, err := mint.MintDB.GetTx(ctx)
 	if err != nil {
 		slog.Warn(
 			"Could not get db transactions",
@@ -31,7 +64,7 @@ func CheckStatusOfLiquiditySwaps(mint *m.Mint, newLiquidity chan string) {
 	defer func() {
 		if p := recover(); p != nil {
 			slog.Warn("Rolling back because of failure", slog.Any("error", err))
-			rollbackErr := mint.MintDB.Rollback(ctx, tx)
+			rollbackErr := mint.MintDB.Rollback(ctx, swapTx)
 			if rollbackErr != nil {
 				if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
 					slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
@@ -39,7 +72,7 @@ func CheckStatusOfLiquiditySwaps(mint *m.Mint, newLiquidity chan string) {
 			}
 		} else if err != nil {
 			slog.Warn("Rolling back because of failure", slog.Any("error", err))
-			rollbackErr := mint.MintDB.Rollback(ctx, tx)
+			rollbackErr := mint.MintDB.Rollback(ctx, swapTx)
 			if rollbackErr != nil {
 				if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
 					slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
@@ -47,177 +80,109 @@ func CheckStatusOfLiquiditySwaps(mint *m.Mint, newLiquidity chan string) {
 			}
 		}
 	}()
-	swaps, err := mint.MintDB.GetLiquiditySwapsByStates(tx, []utils.SwapState{
-		utils.MintWaitingPaymentRecv,
-		utils.LightningPaymentPending,
-	})
 
+	swap, err := mint.MintDB.GetLiquiditySwapById(swapTx, swapId)
 	if err != nil {
 		slog.Warn(
-			"mint.MintDB.GetLiquiditySwapsByStates()",
-			slog.Any("error", err.Error()))
+			"Could not get swap",
+			slog.String(utils.LogExtraInfo, err.Error()),
+		)
+		return
 	}
-	err = tx.Commit(ctx)
+	err = swapTx.Commit(ctx)
 	if err != nil {
-		slog.Error("Could not commit transaction", slog.Any("error", err))
+		slog.Error("Could not commit sub transaction", slog.Any("error", err))
+		return
 	}
 
-	for {
-		func() {
-			// check if there are new liquidity swaps to check
-			select {
-			case swapId := <-newLiquidity:
-				swaps = append(swaps, swapId)
-			default:
-				break
-			}
+	decodedInvoice, err := zpay32.Decode(swap.LightningInvoice, mint.LightningBackend().GetNetwork())
+	if err != nil {
+		slog.Warn(
+			"zpay32.Decode(swap.Destination, mint.LightningBackend().GetNetwork())",
+			slog.String(utils.LogExtraInfo, err.Error()))
+		return
+	}
+
+	payHash := hex.EncodeToString(decodedInvoice.PaymentHash[:])
 
-			for _, swapId := range swaps {
-				func() {
-					slog.Debug("Checking out swap", slog.String("swap_id", swapId))
-
-					swapTx, err := mint.MintDB.GetTx(ctx)
-					if err != nil {
-						slog.Warn(
-							"Could not get db transactions",
-							slog.String(utils.LogExtraInfo, err.Error()),
-						)
-						return
-					}
-					defer func() {
-						if p := recover(); p != nil {
-							slog.Warn("Rolling back because of failure", slog.Any("error", err))
-							rollbackErr := mint.MintDB.Rollback(ctx, swapTx)
-							if rollbackErr != nil {
-								if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-									slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
-								}
-							}
-						} else if err != nil {
-							slog.Warn("Rolling back because of failure", slog.Any("error", err))
-							rollbackErr := mint.MintDB.Rollback(ctx, swapTx)
-							if rollbackErr != nil {
-								if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-									slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
-								}
-							}
-						}
-					}()
-
-					swap, err := mint.MintDB.GetLiquiditySwapById(swapTx, swapId)
-					if err != nil {
-						slog.Warn(
-							"Could not get swap",
-							slog.String(utils.LogExtraInfo, err.Error()),
-						)
-						return
-					}
-					err = swapTx.Commit(ctx)
-					if err != nil {
-						slog.Error("Could not commit sub transaction", slog.Any("error", err))
-						return
-					}
-
-					decodedInvoice, err := zpay32.Decode(swap.LightningInvoice, mint.LightningBackend.GetNetwork())
-					if err != nil {
-						slog.Warn(
-							"zpay32.Decode(swap.Destination, mint.LightningBackend.GetNetwork())",
-							slog.String(utils.LogExtraInfo, err.Error()))
-						return
-					}
-
-					payHash := hex.EncodeToString(decodedInvoice.PaymentHash[:])
-
-					switch swap.Type {
-					case utils.LiquidityIn:
-						slog.Debug("Checking in swap", slog.String("swap_id", swap.Id))
-						//nolint:exhaustruct
-						status, _, err := mint.LightningBackend.CheckReceived(cashu.MintRequestDB{Quote: payHash}, decodedInvoice)
-						if err != nil {
-							slog.Warn(
-								"mint.LightningBackend.CheckReceived(payHash)",
-								slog.String(utils.LogExtraInfo, err.Error()))
-
-							return
-						}
-
-						switch status {
-						case lightning.SETTLED:
-							swap.State = utils.Finished
-						case lightning.PENDING:
-							swap.State = utils.LightningPaymentPending
-						case lightning.FAILED:
-							swap.State = utils.LightningPaymentFail
-						}
-
-					case utils.LiquidityOut:
-						slog.Debug("Checking out swap", slog.String("swap_id", swap.Id))
-						status, _, _, err := mint.LightningBackend.CheckPayed(payHash, decodedInvoice, swap.CheckingId)
-						if err != nil {
-							slog.Warn(
-								"mint.LightningBackend.CheckPayed(payHash)",
-								slog.Any("error", err),
-								slog.String("swap_id", swap.Id),
-								slog.String("invoice", swap.LightningInvoice),
-							)
-
-							return
-						}
-
-						switch status {
-						case lightning.SETTLED:
-							swap.State = utils.Finished
-						case lightning.PENDING:
-							swap.State = utils.LightningPaymentPending
-						case lightning.FAILED:
-							swap.State = utils.LightningPaymentFail
-						}
-					}
-
-					afterCheckTx, err := mint.MintDB.GetTx(ctx)
-					if err != nil {
-						slog.Warn(
-							"Could not get db transactions",
-							slog.String(utils.LogExtraInfo, err.Error()),
-						)
-						return
-					}
-					defer func() {
-						if p := recover(); p != nil {
-							slog.Warn("Rolling back because of failure", slog.Any("error", err))
-							rollbackErr := mint.MintDB.Rollback(ctx, afterCheckTx)
-							if rollbackErr != nil {
-								if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-									slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
-								}
-							}
-						}
-					}()
-					err = mint.MintDB.ChangeLiquiditySwapState(afterCheckTx, swap.Id, swap.State)
-					if err != nil {
-						slog.Warn(
-							"mint.MintDB.ChangeLiquiditySwapState(swap.Id,utils.Expired)",
-							slog.String(utils.LogExtraInfo, err.Error()))
-
-						return
-					}
-
-					slog.Debug("Committing swap", slog.String("swap_id", swap.Id))
-					err = afterCheckTx.Commit(ctx)
-					if err != nil {
-						slog.Error("Could not commit sub transaction", slog.Any("error", err))
-						return
-					}
-
-					if swap.State == utils.Finished || swap.State == utils.Expired || swap.State == utils.LightningPaymentFail {
-						swaps = slices.DeleteFunc(swaps, func(id string) bool {
-							return id == swap.Id
-						})
-					}
-				}()
+	switch swap.Type {
+	case utils.LiquidityIn:
+		slog.Debug("Checking in swap", slog.String("swap_id", swap.Id))
+		//nolint:exhaustruct
+		status, _, err := mint.LightningBackend().CheckReceived(cashu.MintRequestDB{Quote: payHash}, decodedInvoice)
+		if err != nil {
+			slog.Warn(
+				"mint.LightningBackend().CheckReceived(payHash)",
+				slog.String(utils.LogExtraInfo, err.Error()))
+
+			return
+		}
+
+		switch status {
+		case lightning.SETTLED:
+			swap.State = utils.Finished
+		case lightning.PENDING:
+			swap.State = utils.LightningPaymentPending
+		case lightning.FAILED:
+			swap.State = utils.LightningPaymentFail
+		}
+
+	case utils.LiquidityOut:
+		slog.Debug("Checking out swap", slog.String("swap_id", swap.Id))
+		status, _, _, err := mint.LightningBackend().CheckPayed(payHash, decodedInvoice, swap.CheckingId)
+		if err != nil {
+			slog.Warn(
+				"mint.LightningBackend().CheckPayed(payHash)",
+				slog.Any("error", err),
+				slog.String("swap_id", swap.Id),
+				slog.String("invoice", swap.LightningInvoice),
+			)
+
+			return
+		}
+
+		switch status {
+		case lightning.SETTLED:
+			swap.State = utils.Finished
+		case lightning.PENDING:
+			swap.State = utils.LightningPaymentPending
+		case lightning.FAILED:
+			swap.State = utils.LightningPaymentFail
+		}
+	}
+
+	afterCheckTx, err := mint.MintDB.GetTx(ctx)
+	if err != nil {
+		slog.Warn(
+			"Could not get db transactions",
+			slog.String(utils.LogExtraInfo, err.Error()),
+		)
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Warn("Rolling back because of failure", slog.Any("error", err))
+			rollbackErr := mint.MintDB.Rollback(ctx, afterCheckTx)
+			if rollbackErr != nil {
+				if !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+					slog.Error("Failed to rollback transaction", slog.Any("error", rollbackErr))
+				}
 			}
-		}()
-		slog.Debug("Sleeping for 2 seconds", slog.String("swaps", fmt.Sprintf("%v", swaps)))
-		time.Sleep(2 * time.Second)
+		}
+	}()
+	err = mint.MintDB.ChangeLiquiditySwapState(afterCheckTx, swap.Id, swap.State)
+	if err != nil {
+		slog.Warn(
+			"mint.MintDB.ChangeLiquiditySwapState(swap.Id,utils.Expired)",
+			slog.String(utils.LogExtraInfo, err.Error()))
+
+		return
+	}
+
+	slog.Debug("Committing swap", slog.String("swap_id", swap.Id))
+	err = afterCheckTx.Commit(ctx)
+	if err != nil {
+		slog.Error("Could not commit sub transaction", slog.Any("error", err))
+		return
 	}
 }
@@ -59,6 +59,8 @@ func (a *adminHandler) getKeysets(filterUnits []string) (map[string][]templates.
 			Version:     seed.Version,
 			CreatedAt:   0,
 			ExpireLimit: expireTime,
+			Issued:      0,
+			Redeemed:    0,
 		})
 	}
 	for _, seed := range authKeysets.Keysets {
@@ -82,6 +84,8 @@ func (a *adminHandler) getKeysets(filterUnits []string) (map[string][]templates.
 			Version:     seed.Version,
 			CreatedAt:   0,
 			ExpireLimit: expireTime,
+			Issued:      0,
+			Redeemed:    0,
 		})
 	}
 
@@ -109,14 +113,34 @@ func (a *adminHandler) getKeysets(filterUnits []string) (map[string][]templates.
 	return keysetMap, orderedUnits, nil
 }
 
-func (a *adminHandler) rotateKeyset(unit cashu.Unit, fee uint, expiry_hours uint) error {
-	return a.mint.Signer.RotateKeyset(unit, fee, expiry_hours)
+// addKeysetBalances fills in how much of the ecash of every keyset was
+// redeemed.
+func (a *adminHandler) addKeysetBalances(ctx context.Context, keysetMap map[string][]templates.KeysetData) error {
+	balances, err := a.mint.MintDB.GetKeysetBalances(ctx)
+	if err != nil {
+		return fmt.Errorf("a.mint.MintDB.GetKeysetBalances(ctx). %w", err)
+	}
+	byId := make(map[string]database.KeysetBalance, len(balances))
+	for _, balance := range balances {
+		byId[balance.KeysetId] = balance
+	}
+	for _, keysets := range keysetMap {
+		for i := range keysets {
+			keysets[i].Issued = byId[keysets[i].Id].Issued
+			keysets[i].Redeemed = byId[keysets[i].Id].Redeemed
+		}
+	}
+	return nil
+}
+
+func (a *adminHandler) rotateKeyset(ctx context.Context, unit cashu.Unit, fee uint, expiry_hours uint, amounts []uint64) error {
+	return a.mint.RotateKeyset(ctx, unit, fee, expiry_hours, amounts)
 }
 
 func (a *adminHandler) lnSatsBalance() (uint64, error) {
-	balanceAmount, err := a.mint.LightningBackend.WalletBalance()
+	balanceAmount, err := a.mint.LightningBackend().WalletBalance()
 	if err != nil {
-		return 0, fmt.Errorf("a.mint.LightningBackend.WalletBalance(). %w", err)
+		return 0, fmt.Errorf("a.mint.LightningBackend().WalletBalance(). %w", err)
 	}
 	// Convert to Sat for display
 	convertErr := balanceAmount.To(cashu.Sat)
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+	"github.com/lescuer97/nutmix/internal/scheduler"
+)
+
+const (
+	LiquiditySwapsJob = "liquidity-swaps"
+	SessionCleanupJob = "admin-session-cleanup"
+)
+
+func JobsPage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := templates.JobsPage().Render(c.Request.Context(), c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.JobsPage().Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+func JobsTable(jobs *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		statuses, err := jobs.Jobs(ctx)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("jobs.Jobs(ctx). %w", err))
+			return
+		}
+
+		jobsData := make([]templates.JobData, len(statuses))
+		for i, status := range statuses {
+			jobsData[i] = templates.JobData{
+				Name:       status.Name,
+				Interval:   status.Interval.String(),
+				LastError:  "",
+				Status:     "",
+				Instance:   "",
+				StartedAt:  0,
+				DurationMs: 0,
+				RunCount:   0,
+			}
+			if status.LastRun != nil {
+				jobsData[i].Status = string(status.LastRun.Status)
+				jobsData[i].Instance = status.LastRun.Instance
+				jobsData[i].StartedAt = status.LastRun.StartedAt
+				jobsData[i].DurationMs = status.LastRun.DurationMs
+				jobsData[i].RunCount = status.LastRun.RunCount
+				if status.LastRun.LastError != nil {
+					jobsData[i].LastError = *status.LastRun.LastError
+				}
+			}
+		}
+
+		err = templates.JobsTable(jobsData).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.JobsTable(jobsData).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
+func TriggerJob(jobs *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		err := jobs.Trigger(c.Request.Context(), name)
+		switch {
+		case errors.Is(err, scheduler.ErrJobNotFound):
+			err := RenderError(c, "Job does not exist")
+			if err != nil {
+				slog.Error("RenderError", slog.Any("error", err))
+			}
+			return
+		case errors.Is(err, scheduler.ErrJobRunningElsewhere):
+			err := RenderError(c, "Job is already running")
+			if err != nil {
+				slog.Error("RenderError", slog.Any("error", err))
+			}
+			return
+		case err != nil:
+			slog.Warn("jobs.Trigger(ctx, name)", slog.String("job", name), slog.Any("error", err))
+			c.Header("HX-Trigger", "recharge-jobs")
+			err := RenderError(c, "Job failed, check the last error")
+			if err != nil {
+				slog.Error("RenderError", slog.Any("error", err))
+			}
+			return
+		}
+
+		c.Header("HX-Trigger", "recharge-jobs")
+		err = RenderSuccess(c, "Job ran successfully")
+		if err != nil {
+			slog.Error("RenderSuccess", slog.Any("error", err))
+		}
+	}
+}
@@ -6,11 +6,16 @@ import (
 	"log/slog"
 	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
 	m "github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+	"github.com/lescuer97/nutmix/internal/signer"
 	"github.com/lescuer97/nutmix/internal/utils"
 )
 
@@ -23,11 +28,11 @@ func KeysetsPage(mint *m.Mint) gin.HandlerFunc {
 		availableUnits := []cashu.Unit{cashu.Sat, cashu.Msat, cashu.USD, cashu.EUR}
 
 		availableUnits = slices.DeleteFunc(availableUnits, func(val cashu.Unit) bool {
-			return !mint.LightningBackend.VerifyUnitSupport(val)
+			return !mint.LightningBackend().VerifyUnitSupport(val)
 		})
 
 		availableUnits = append(availableUnits, cashu.AUTH)
-		err := templates.KeysetsPage(availableUnits).Render(ctx, c.Writer)
+		err := templates.KeysetsPage(availableUnits, mint.SignerLocked()).Render(ctx, c.Writer)
 
 		if err != nil {
 			_ = c.Error(fmt.Errorf("templates.KeysetsPage().Render(ctx, c.Writer). %w", err))
@@ -36,6 +41,24 @@ func KeysetsPage(mint *m.Mint) gin.HandlerFunc {
 		}
 	}
 }
+
+// SignerStatus shows the endpoints of a remote signer, other signers have
+// nothing to show.
+func SignerStatus(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reporter, ok := signer.AsHealthReporter(mint.Signer)
+		if !ok {
+			c.Status(200)
+			return
+		}
+		err := templates.SignerStatus(reporter.Health()).Render(c.Request.Context(), c.Writer)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("templates.SignerStatus(reporter.Health()).Render(ctx, c.Writer). %w", err))
+			return
+		}
+	}
+}
+
 func KeysetsLayoutPage(adminHandler *adminHandler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		keysetMap, orderedUnits, err := adminHandler.getKeysets(nil)
@@ -44,7 +67,17 @@ func KeysetsLayoutPage(adminHandler *adminHandler) gin.HandlerFunc {
 			return
 		}
 		ctx := c.Request.Context()
-		err = templates.KeysetsList(keysetMap, orderedUnits).Render(ctx, c.Writer)
+		err = adminHandler.addKeysetBalances(ctx, keysetMap)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("adminHandler.addKeysetBalances(ctx, keysetMap). %w", err))
+			return
+		}
+		policies, err := adminHandler.mint.MintDB.GetKeysetPolicies(ctx)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("adminHandler.mint.MintDB.GetKeysetPolicies(ctx). %w", err))
+			return
+		}
+		err = templates.KeysetsList(keysetMap, orderedUnits, policies).Render(ctx, c.Writer)
 
 		if err != nil {
 			_ = c.Error(fmt.Errorf("templates.KeysetsList(keysetArr.Keysets).Render(ctx, c.Writer). %w", err))
@@ -53,10 +86,101 @@ func KeysetsLayoutPage(adminHandler *adminHandler) gin.HandlerFunc {
 	}
 }
 
+// SaveKeysetPolicy sets when the keyset of a unit rotates on its own. The
+// schedule of the policy starts when it is saved.
+func SaveKeysetPolicy(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		unit, err := cashu.UnitFromString(c.Request.PostFormValue("UNIT"))
+		if err != nil {
+			_ = c.Error(fmt.Errorf("cashu.UnitFromString(UNIT). %w. %w", err, ErrUnitNotCorrect))
+			return
+		}
+
+		values := make(map[string]uint64, 4)
+		for _, field := range []string{"ROTATE_EVERY_HOURS", "ROTATE_AFTER_AMOUNT", "FEE", "FINAL_EXPIRY_HOURS"} {
+			value, err := strconv.ParseUint(c.Request.PostFormValue(field), 10, 64)
+			if err != nil {
+				err := RenderError(c, fmt.Sprintf("%s is not a positive integer", field))
+				if err != nil {
+					slog.Error("RenderError", slog.Any("error", err))
+				}
+				return
+			}
+			values[field] = value
+		}
+
+		now := time.Now().Unix()
+		policy := database.KeysetPolicy{
+			Unit:              unit.String(),
+			RotateEveryHours:  values["ROTATE_EVERY_HOURS"],
+			RotateAfterAmount: values["ROTATE_AFTER_AMOUNT"],
+			InputFeePpk:       values["FEE"],
+			FinalExpiryHours:  values["FINAL_EXPIRY_HOURS"],
+			RotatedAt:         now,
+			UpdatedAt:         now,
+		}
+		err = m.ValidateKeysetPolicy(policy)
+		if err != nil {
+			err := RenderError(c, err.Error())
+			if err != nil {
+				slog.Error("RenderError", slog.Any("error", err))
+			}
+			return
+		}
+
+		err = mint.MintDB.SaveKeysetPolicy(ctx, policy)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("mint.MintDB.SaveKeysetPolicy(ctx, policy). %w", err))
+			return
+		}
+		recordAuditDetails(c, mint.MintDB, audit.ActionKeysetPolicy, policy)
+
+		c.Header("HX-Trigger", "recharge-keyset")
+		err = RenderSuccess(c, "Rotation policy saved")
+		if err != nil {
+			slog.Error("RenderSuccess", slog.Any("error", err))
+		}
+	}
+}
+
 type RotateRequest struct {
 	Fee              uint       `json:"fee,omitempty"`
 	Unit             cashu.Unit `json:"unit,omitempty"`
 	ExpireLimitHours uint       `json:"expire_limit,omitempty"`
+	Amounts          []uint64   `json:"amounts,omitempty"`
+	MaxOrder         uint       `json:"max_order,omitempty"`
+}
+
+// keysetAmounts returns the amounts of the new keyset, from the list or the
+// max order of the request. Nil keeps the default powers of two.
+func (r RotateRequest) keysetAmounts() ([]uint64, error) {
+	switch {
+	case len(r.Amounts) > 0 && r.MaxOrder > 0:
+		return nil, fmt.Errorf("%w: set either the amounts or the max order", cashu.ErrInvalidKeysetAmounts)
+	case r.MaxOrder > 0:
+		return cashu.AmountsForMaxOrder(int(r.MaxOrder))
+	case len(r.Amounts) > 0:
+		return cashu.KeysetAmounts(r.Amounts)
+	}
+	return nil, nil
+}
+
+// parseAmounts reads a comma separated list of amounts, like "1, 5, 10".
+func parseAmounts(amountsStr string) ([]uint64, error) {
+	if strings.TrimSpace(amountsStr) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(amountsStr, ",")
+	amounts := make([]uint64, len(parts))
+	for i, part := range parts {
+		amount, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseUint(part, 10, 64). %w", err)
+		}
+		amounts[i] = amount
+	}
+	return amounts, nil
 }
 
 func RotateSatsSeed(adminHandler *adminHandler) gin.HandlerFunc {
@@ -122,9 +246,42 @@ func RotateSatsSeed(adminHandler *adminHandler) gin.HandlerFunc {
 				return
 			}
 			rotateRequest.ExpireLimitHours = uint(expiryLimit)
+
+			rotateRequest.Amounts, err = parseAmounts(c.Request.PostFormValue("AMOUNTS"))
+			if err != nil {
+				err := RenderError(c, "Amounts have to be a comma separated list of integers")
+				if err != nil {
+					slog.Error("RenderError", slog.Any("error", err))
+				}
+				return
+			}
+			if maxOrderStr := c.Request.PostFormValue("MAX_ORDER"); maxOrderStr != "" {
+				maxOrder, err := strconv.ParseUint(maxOrderStr, 10, 64)
+				if err != nil {
+					err := RenderError(c, "Max order is not an integer")
+					if err != nil {
+						slog.Error("RenderError", slog.Any("error", err))
+					}
+					return
+				}
+				rotateRequest.MaxOrder = uint(maxOrder)
+			}
+		}
+
+		amounts, err := rotateRequest.keysetAmounts()
+		if err != nil {
+			if c.ContentType() == gin.MIMEJSON {
+				c.JSON(400, nil)
+				return
+			}
+			err := RenderError(c, err.Error())
+			if err != nil {
+				slog.Error("RenderError", slog.Any("error", err))
+			}
+			return
 		}
 
-		err := adminHandler.rotateKeyset(rotateRequest.Unit, rotateRequest.Fee, rotateRequest.ExpireLimitHours)
+		err = adminHandler.rotateKeyset(c.Request.Context(), rotateRequest.Unit, rotateRequest.Fee, rotateRequest.ExpireLimitHours, amounts)
 		if err != nil {
 			slog.Error(
 				"mint.Signer.RotateKeyset(cashu.Sat, rotateRequest.Fee)",
@@ -136,6 +293,7 @@ func RotateSatsSeed(adminHandler *adminHandler) gin.HandlerFunc {
 			}
 			return
 		}
+		recordAuditDetails(c, adminHandler.mint.MintDB, audit.ActionKeysetRotate, rotateRequest)
 
 		if c.ContentType() == gin.MIMEJSON {
 			c.JSON(200, nil)
@@ -0,0 +1,89 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+)
+
+func TestSaveKeysetPolicy(t *testing.T) {
+	mintInstance, db := configHistoryTestMint()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/keysets/policy", SaveKeysetPolicy(mintInstance))
+
+	save := func(rotateEvery string, finalExpiry string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"UNIT":                {"sat"},
+			"ROTATE_EVERY_HOURS":  {rotateEvery},
+			"ROTATE_AFTER_AMOUNT": {"100000"},
+			"FEE":                 {"100"},
+			"FINAL_EXPIRY_HOURS":  {finalExpiry},
+		}
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/admin/keysets/policy", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := save("48", "24")
+	if len(db.KeysetPolicies) != 0 || !strings.Contains(recorder.Body.String(), "rotate before") {
+		t.Fatalf("expected a policy that lets keysets expire to be refused, got %s", recorder.Body.String())
+	}
+
+	recorder = save("24", "48")
+	if recorder.Header().Get("HX-Trigger") != "recharge-keyset" || len(db.KeysetPolicies) != 1 {
+		t.Fatalf("expected the policy to be saved, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	policy := db.KeysetPolicies[0]
+	if policy.Unit != "sat" || policy.RotateEveryHours != 24 || policy.RotateAfterAmount != 100000 || policy.InputFeePpk != 100 || policy.FinalExpiryHours != 48 || policy.RotatedAt == 0 {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+	if len(db.AuditLog) != 1 || db.AuditLog[0].Action != audit.ActionKeysetPolicy {
+		t.Errorf("expected the policy in the audit log, got %+v", db.AuditLog)
+	}
+}
+
+func TestRotateRequestKeysetAmounts(t *testing.T) {
+	amounts, err := parseAmounts(" 1, 2,5 ,10")
+	if err != nil {
+		t.Fatalf("parseAmounts() %+v", err)
+	}
+	request := RotateRequest{Amounts: amounts}
+	amounts, err = request.keysetAmounts()
+	if err != nil || !slices.Equal(amounts, []uint64{1, 2, 5, 10}) {
+		t.Errorf("expected the decimal amounts, got %v, %v", amounts, err)
+	}
+
+	request = RotateRequest{MaxOrder: 21}
+	amounts, err = request.keysetAmounts()
+	if err != nil || len(amounts) != 21 {
+		t.Errorf("expected 21 amounts, got %v, %v", amounts, err)
+	}
+
+	amounts, err = RotateRequest{}.keysetAmounts()
+	if err != nil || amounts != nil {
+		t.Errorf("expected the default amounts, got %v, %v", amounts, err)
+	}
+
+	request = RotateRequest{Amounts: []uint64{1, 2}, MaxOrder: 2}
+	_, err = request.keysetAmounts()
+	if !errors.Is(err, cashu.ErrInvalidKeysetAmounts) {
+		t.Errorf("expected ErrInvalidKeysetAmounts with both amounts and max order, got %v", err)
+	}
+
+	_, err = parseAmounts("1,two")
+	if err == nil {
+		t.Errorf("expected amounts that are not integers to fail")
+	}
+}
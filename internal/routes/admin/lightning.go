@@ -10,13 +10,14 @@ import (
 
 func LightningDataFormFields(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		config := mint.Config()
 		backend := c.Query(m.MINT_LIGHTNING_BACKEND_ENV)
 
 		ctx := c.Request.Context()
-		err := templates.SetupForms(backend, mint.Config).Render(ctx, c.Writer)
+		err := templates.SetupForms(backend, config).Render(ctx, c.Writer)
 
 		if err != nil {
-			_ = c.Error(fmt.Errorf("templates.SetupForms(mint.Config).Render(ctx, c.Writer). %w", err))
+			_ = c.Error(fmt.Errorf("templates.SetupForms(config).Render(ctx, c.Writer). %w", err))
 			return
 		}
 	}
@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
 	"github.com/lescuer97/nutmix/internal/lightning"
 	m "github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
@@ -24,7 +25,7 @@ import (
 func LiquidityButton(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
-		if !utils.CanUseLiquidityManager(mint.Config.MINT_LIGHTNING_BACKEND) {
+		if !utils.CanUseLiquidityManager(mint.Config().MINT_LIGHTNING_BACKEND) {
 			// c.Status(200)
 			return
 		}
@@ -43,7 +44,7 @@ func LnSendPage(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
-		milillisatBalance, err := mint.LightningBackend.WalletBalance()
+		milillisatBalance, err := mint.LightningBackend().WalletBalance()
 		var balance string
 		if err != nil {
 			slog.Warn(
@@ -88,7 +89,7 @@ func LnReceivePage(mint *m.Mint) gin.HandlerFunc {
 func SwapOutForm(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
-		milillisatBalance, err := mint.LightningBackend.WalletBalance()
+		milillisatBalance, err := mint.LightningBackend().WalletBalance()
 		if err != nil {
 			slog.Warn(
 				"mint.LightningComs.WalletBalance()",
@@ -135,7 +136,7 @@ func SwapOutRequest(mint *m.Mint) gin.HandlerFunc {
 		// need amount and liquid address
 		invoice := c.PostForm("invoice")
 
-		decodedInvoice, err := zpay32.Decode(invoice, mint.LightningBackend.GetNetwork())
+		decodedInvoice, err := zpay32.Decode(invoice, mint.LightningBackend().GetNetwork())
 		if err != nil {
 			// If the fees are acceptable, continue to create the Receive Payment
 			slog.Warn("zpay32.Decode(invoice)", slog.Any("error", err))
@@ -155,7 +156,7 @@ func SwapOutRequest(mint *m.Mint) gin.HandlerFunc {
 		}
 
 		// Check for sufficient funds
-		currentBalance, err := mint.LightningBackend.WalletBalance()
+		currentBalance, err := mint.LightningBackend().WalletBalance()
 		if err != nil {
 			slog.Warn("Could not fetch wallet balance", slog.Any("error", err))
 			err := RenderError(c, "Could not check wallet balance")
@@ -192,7 +193,7 @@ func SwapOutRequest(mint *m.Mint) gin.HandlerFunc {
 		}
 
 		amount := decodedInvoice.MilliSat.ToSatoshis()
-		feesResponse, err := mint.LightningBackend.QueryFees(invoice, decodedInvoice, false, cashu.NewAmount(cashu.Sat, uint64(amount)))
+		feesResponse, err := mint.LightningBackend().QueryFees(invoice, decodedInvoice, false, cashu.NewAmount(cashu.Sat, uint64(amount)))
 		if err != nil {
 			slog.Info("mint.LightningComs.PayInvoice", slog.Any("error", err))
 			err := RenderError(c, "Could not calculate fees or route not found")
@@ -257,6 +258,7 @@ func SwapOutRequest(mint *m.Mint) gin.HandlerFunc {
 			_ = c.Error(fmt.Errorf("mint.MintDB.Commit(c.Request.Context(), tx). %w", err))
 			return
 		}
+		recordAuditDetails(c, mint.MintDB, audit.ActionLiquiditySwapOut, swap)
 
 		c.Header("HX-Location", "/admin/liquidity/"+uuid)
 		component := templates.LightningSendSummary(decodedInvoice.MilliSat.ToSatoshis().Format(btcutil.AmountSatoshi), swap.LightningInvoice, uuid)
@@ -269,7 +271,7 @@ func SwapOutRequest(mint *m.Mint) gin.HandlerFunc {
 	}
 }
 
-func SwapInRequest(mint *m.Mint, newLiquidity chan string) gin.HandlerFunc {
+func SwapInRequest(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
@@ -295,16 +297,16 @@ func SwapInRequest(mint *m.Mint, newLiquidity chan string) gin.HandlerFunc {
 
 		uuid := uuid.New().String()
 
-		resp, err := mint.LightningBackend.RequestInvoice(cashu.Amount{Amount: amount, Unit: cashu.Sat}, nil)
+		resp, err := mint.LightningBackend().RequestInvoice(cashu.Amount{Amount: amount, Unit: cashu.Sat}, nil)
 		if err != nil {
-			slog.Warn("mint.LightningBackend.RequestInvoice", slog.Any("error", err))
+			slog.Warn("mint.LightningBackend().RequestInvoice", slog.Any("error", err))
 			err := RenderError(c, "Could not generate invoice")
 			if err != nil {
 				slog.Warn("failed to render error", slog.Any("error", err))
 			}
 			return
 		}
-		decodedInvoice, err := zpay32.Decode(resp.PaymentRequest, mint.LightningBackend.GetNetwork())
+		decodedInvoice, err := zpay32.Decode(resp.PaymentRequest, mint.LightningBackend().GetNetwork())
 		if err != nil {
 			// If the fees are acceptable, continue to create the Receive Payment
 			log.Printf("\n zpay32.Decode(resp.PaymentRequest, %+v \n", err)
@@ -366,6 +368,7 @@ func SwapInRequest(mint *m.Mint, newLiquidity chan string) gin.HandlerFunc {
 			_ = c.Error(fmt.Errorf("mint.MintDB.Commit(c.Request.Context(), tx). %w", err))
 			return
 		}
+		recordAuditDetails(c, mint.MintDB, audit.ActionLiquiditySwapIn, swap)
 
 		amountConverted := strconv.FormatUint(swap.Amount, 10)
 
@@ -375,7 +378,6 @@ func SwapInRequest(mint *m.Mint, newLiquidity chan string) gin.HandlerFunc {
 			_ = c.Error(fmt.Errorf("generateQR(swap.LightningInvoice). %w", err))
 			return
 		}
-		newLiquidity <- uuid
 
 		// redirect to the swap status page
 		c.Header("HX-Location", "/admin/liquidity/"+uuid)
@@ -446,7 +448,7 @@ func SwapStateCheck(mint *m.Mint) gin.HandlerFunc {
 	}
 }
 
-func ConfirmSwapOutTransaction(mint *m.Mint, newLiquidity chan string) gin.HandlerFunc {
+func ConfirmSwapOutTransaction(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
@@ -511,9 +513,9 @@ func ConfirmSwapOutTransaction(mint *m.Mint, newLiquidity chan string) gin.Handl
 			_ = c.Error(fmt.Errorf("mint.MintDB.Commit(ctx tx). %w", err))
 			return
 		}
-		newLiquidity <- swapId
+		recordAuditDetails(c, mint.MintDB, audit.ActionLiquidityConfirmed, swapRequest)
 
-		decodedInvoice, err := zpay32.Decode(swapRequest.LightningInvoice, mint.LightningBackend.GetNetwork())
+		decodedInvoice, err := zpay32.Decode(swapRequest.LightningInvoice, mint.LightningBackend().GetNetwork())
 		if err != nil {
 			// If the fees are acceptable, continue to create the Receive Payment
 			_ = c.Error(fmt.Errorf("zpay32.Decode(res.Destination) %w", err))
@@ -525,12 +527,12 @@ func ConfirmSwapOutTransaction(mint *m.Mint, newLiquidity chan string) gin.Handl
 		slog.Info("making payment to invoice", slog.String("invoice", swapRequest.LightningInvoice))
 
 		//nolint:exhaustruct
-		payment, err := mint.LightningBackend.PayInvoice(cashu.MeltRequestDB{Request: swapRequest.LightningInvoice}, decodedInvoice, fee, false, cashu.Amount{Unit: cashu.Sat, Amount: swapRequest.Amount})
+		payment, err := mint.LightningBackend().PayInvoice(cashu.MeltRequestDB{Request: swapRequest.LightningInvoice}, decodedInvoice, fee, false, cashu.Amount{Unit: cashu.Sat, Amount: swapRequest.Amount})
 
 		// Hardened error handling
 		if err != nil || payment.PaymentState == lightning.FAILED || payment.PaymentState == lightning.UNKNOWN || payment.PaymentState == lightning.PENDING {
 			// if exception of lightning payment says fail do a payment status recheck.
-			status, _, _, err := mint.LightningBackend.CheckPayed(swapRequest.LightningInvoice, decodedInvoice, swapRequest.CheckingId)
+			status, _, _, err := mint.LightningBackend().CheckPayed(swapRequest.LightningInvoice, decodedInvoice, swapRequest.CheckingId)
 
 			// if error on checking payement we will save as pending and returns status
 			if err != nil {
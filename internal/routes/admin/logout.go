@@ -1,15 +1,18 @@
 package admin
 
 import (
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
 )
 
 // LogoutHandler handles user logout requests
-func LogoutHandler(blacklist *TokenBlacklist) gin.HandlerFunc {
+func LogoutHandler(db database.MintDB, blacklist SessionBlacklist) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract token from cookie
 		tokenString, err := c.Cookie(AdminAuthKey)
@@ -42,6 +45,11 @@ func LogoutHandler(blacklist *TokenBlacklist) gin.HandlerFunc {
 
 		// Add token to blacklist
 		blacklist.AddToken(tokenString, expirationTime)
+		err = db.RevokeAdminSession(c.Request.Context(), c.GetString(adminSessionKey))
+		if err != nil {
+			slog.Error("db.RevokeAdminSession(ctx, session)", slog.Any("error", err))
+		}
+		recordAudit(c, db, audit.ActionLogout, auditActor(c), nil)
 
 		// Clear the cookie
 		c.SetCookie(AdminAuthKey, "", -1, "/", "", false, true)
@@ -5,20 +5,24 @@ import (
 	"embed"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/a-h/templ"
-	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/database"
 	m "github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+	"github.com/lescuer97/nutmix/internal/routes/middleware"
+	"github.com/lescuer97/nutmix/internal/scheduler"
 	"github.com/lescuer97/nutmix/internal/utils"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
@@ -49,6 +53,8 @@ func ErrorHtmlMessageMiddleware() gin.HandlerFunc {
 					message = ErrCouldNotParseLogin.Error()
 				case errors.Is(e, ErrInvalidNostrSignature):
 					message = ErrInvalidNostrSignature.Error()
+				case errors.Is(e, ErrRoleNotAllowed):
+					message = ErrRoleNotAllowed.Error()
 				}
 			}
 			slog.Error("Error from calls", slog.String("errors", c.Errors.String()))
@@ -79,7 +85,7 @@ func RenderSuccess(c *gin.Context, message string) error {
 //go:embed static/dist/js/*.js static/dist/js/modules/*.js static/dist/css/*.css
 var staticEmbed embed.FS
 
-func AdminRoutes(ctx context.Context, r *gin.Engine, mint *m.Mint) {
+func AdminRoutes(ctx context.Context, r *gin.Engine, mint *m.Mint, jobs *scheduler.Scheduler) {
 	// Create a file server for the embedded static files
 	// The embed contains files at: static/dist/js/*.js and static/dist/css/*.css
 	// We need to serve them at /js and /css routes
@@ -97,16 +103,20 @@ func AdminRoutes(ctx context.Context, r *gin.Engine, mint *m.Mint) {
 
 	adminRoute := r.Group("/admin")
 
-	loginKey, err := secp256k1.GeneratePrivateKey()
+	loginKey, err := sessionSigningKey()
 	if err != nil {
 		slog.Error(
-			"secp256k1.GeneratePrivateKey()",
+			"sessionSigningKey()",
 			slog.String(utils.LogExtraInfo, err.Error()),
 		)
-		log.Panicf("secp256k1.GeneratePrivateKey(). %+v", err)
+		log.Panicf("sessionSigningKey(). %+v", err)
+	}
+
+	auditKey, err := auditSigningKey()
+	if err != nil {
+		log.Panicf("auditSigningKey(). %+v", err)
 	}
 
-	var nostrPubkey *btcec.PublicKey
 	adminNpubStr := os.Getenv("ADMIN_NOSTR_NPUB")
 	if adminNpubStr != "" {
 		_, value, err := nip19.Decode(adminNpubStr)
@@ -133,28 +143,68 @@ func AdminRoutes(ctx context.Context, r *gin.Engine, mint *m.Mint) {
 			panic("")
 		}
 
-		nostrPubkey = pubkey
+		err = setupBootstrapOwner(ctx, mint.MintDB, hex.EncodeToString(schnorr.SerializePubKey(pubkey)))
+		if err != nil {
+			log.Panicf("setupBootstrapOwner(ctx, mint.MintDB, pubkey). %+v", err)
+		}
+	}
+
+	admins, err := mint.MintDB.GetAdmins(ctx)
+	if err != nil {
+		log.Panicf("mint.MintDB.GetAdmins(ctx). %+v", err)
+	}
+	adminsAvailable := len(admins) > 0
+
+	ipAllowlist, err := adminIPAllowlist()
+	if err != nil {
+		log.Panicf("adminIPAllowlist(). %+v", err)
 	}
 
 	// Create token blacklist
-	tokenBlacklist := NewTokenBlacklist()
+	var tokenBlacklist SessionBlacklist = NewTokenBlacklist()
+	if os.Getenv(AdminSessionStoreEnv) == AdminSessionStoreDatabase {
+		tokenBlacklist = NewDBTokenBlacklist(mint.MintDB)
+	}
 
+	// the in memory blacklist belongs to this process, so every replica prunes
+	// its own instead of only the scheduler leader
+	go cleanupSessionBlacklist(ctx, tokenBlacklist, time.Hour)
+
+	err = jobs.Register(scheduler.Job{
+		Name:       SessionCleanupJob,
+		Interval:   time.Hour,
+		RunOnStart: false,
+		Run: func(ctx context.Context) error {
+			return mint.MintDB.DeleteExpiredAdminSessions(ctx, time.Now().Unix())
+		},
+	})
+	if err != nil {
+		log.Panicf("jobs.Register(SessionCleanupJob). %+v", err)
+	}
+
+	adminRoute.Use(IPAllowlistMiddleware(ipAllowlist))
 	adminRoute.Use(ErrorHtmlMessageMiddleware())
 	// I use the first active keyset as secret for jwt token signing
-	adminRoute.Use(AuthMiddleware(loginKey.Serialize(), tokenBlacklist))
+	adminRoute.Use(AuthMiddleware(loginKey.Serialize(), tokenBlacklist, mint.MintDB))
 
 	adminHandler := newAdminHandler(mint)
 
 	// PAGES SETUP
 	// This is /admin pages
 	// nolint: contextcheck
-	adminRoute.GET("/login", LoginPage(mint, nostrPubkey != nil))
+	adminRoute.GET("/login", LoginPage(mint, adminsAvailable))
+
+	if adminsAvailable {
+		// every admin can see the dashboard. Operators can move funds and rotate
+		// keysets, owners manage credentials and the other admins.
+		operatorRoute := adminRoute.Group("", RequireRole(database.AdminOperator))
+		ownerRoute := adminRoute.Group("", RequireRole(database.AdminOwner))
 
-	newLiquidity := make(chan string)
-	if nostrPubkey != nil {
 		// nolint: contextcheck
 		adminRoute.GET("/summary", SummaryComponent(mint, &adminHandler))
 		// nolint: contextcheck
+		adminRoute.GET("/reserves-chart", ReservesChartCard(mint))
+		// nolint: contextcheck
 		adminRoute.GET("/proofs-chart", ProofsChartCard(mint))
 		// nolint: contextcheck
 		adminRoute.GET("/api/proofs-chart-data", ProofsChartDataAPI(mint))
@@ -175,40 +225,90 @@ func AdminRoutes(ctx context.Context, r *gin.Engine, mint *m.Mint) {
 		// nolint: contextcheck
 		adminRoute.GET("/keysets", KeysetsPage(mint))
 		// nolint: contextcheck
+		adminRoute.GET("/signer-status", SignerStatus(mint))
+		// nolint: contextcheck
 		adminRoute.GET("/settings", MintSettingsPage(mint))
+		// nolint: contextcheck
+		adminRoute.GET("/jobs", JobsPage())
+		// nolint: contextcheck
+		adminRoute.GET("/audit", AuditPage())
+		// nolint: contextcheck
+		operatorRoute.GET("/audit/export", AuditExport(mint.MintDB, auditKey))
+		// nolint: contextcheck
+		adminRoute.GET("/audit/pubkey", AuditPubkey(auditKey))
+		// nolint: contextcheck
+		ownerRoute.GET("/access", AccessPage())
 
 		// change routes
 		// nolint: contextcheck
-		adminRoute.POST("/login", LoginPost(mint, loginKey, nostrPubkey))
+		adminRoute.POST("/login", LoginPost(mint, loginKey))
 		// nolint: contextcheck
-		adminRoute.POST("/mintsettings/general", MintSettingsGeneral(mint))
+		adminRoute.POST("/login/bunker", RateLimitByIP(middleware.NewIPRateLimiter(bunkerLoginRate, bunkerLoginBurst)), BunkerLoginPost(mint, loginKey, adminBunkerRelays()))
 		// nolint: contextcheck
-		adminRoute.POST("/mintsettings/lightning", MintSettingsLightning(mint))
+		operatorRoute.POST("/mintsettings/general", MintSettingsGeneral(mint))
 		// nolint: contextcheck
-		adminRoute.POST("/mintsettings/auth", MintSettingsAuth(mint))
+		operatorRoute.POST("/mintsettings/lightning", MintSettingsLightning(mint))
 		// nolint: contextcheck
-		adminRoute.POST("/mintsettings/notifications", MintSettingsNotifications(mint))
+		ownerRoute.POST("/mintsettings/auth", MintSettingsAuth(mint))
 		// nolint: contextcheck
-		adminRoute.POST("/mintsettings/notifications/test", MintSettingsNotificationsTest(mint))
+		ownerRoute.POST("/mintsettings/notifications", MintSettingsNotifications(mint))
 		// nolint: contextcheck
-		adminRoute.DELETE("/mintsettings/notifications/npubs/:npub", MintSettingsNotificationDeleteNpub(mint))
+		ownerRoute.POST("/mintsettings/notifications/test", MintSettingsNotificationsTest(mint))
+		// nolint: contextcheck
+		ownerRoute.DELETE("/mintsettings/notifications/npubs/:npub", MintSettingsNotificationDeleteNpub(mint))
 		// Legacy/Fallback
 		// nolint: contextcheck
-		adminRoute.POST("/bolt11", Bolt11Post(mint))
+		ownerRoute.POST("/bolt11", Bolt11Post(mint))
+		// nolint: contextcheck
+		ownerRoute.POST("/config-history/:id/rollback", RollbackConfig(mint))
+		// nolint: contextcheck
+		ownerRoute.POST("/unlock", UnlockSigner(mint))
+		// nolint: contextcheck
+		operatorRoute.POST("/rotate/sats", RotateSatsSeed(&adminHandler))
+		// nolint: contextcheck
+		operatorRoute.POST("/keysets/policy", SaveKeysetPolicy(mint))
 		// nolint: contextcheck
-		adminRoute.POST("/rotate/sats", RotateSatsSeed(&adminHandler))
+		adminRoute.POST("/logout", LogoutHandler(mint.MintDB, tokenBlacklist))
 		// nolint: contextcheck
-		adminRoute.POST("/logout", LogoutHandler(tokenBlacklist))
+		operatorRoute.POST("/jobs/:name/run", TriggerJob(jobs))
+		// nolint: contextcheck
+		ownerRoute.POST("/admins", AddAdmin(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.POST("/admins/:pubkey/role", ChangeAdminRole(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.DELETE("/admins/:pubkey", RemoveAdmin(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.POST("/sessions/:id/revoke", RevokeSession(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.POST("/api-keys", CreateApiKey(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.POST("/api-keys/:id/revoke", RevokeApiKey(mint.MintDB))
 
 		// fractional html components
 		// nolint: contextcheck
 		adminRoute.GET("/keysets-layout", KeysetsLayoutPage(&adminHandler))
 		// nolint: contextcheck
-		adminRoute.GET("/lightningdata", LightningDataFormFields(mint))
+		ownerRoute.GET("/lightningdata", LightningDataFormFields(mint))
+		// nolint: contextcheck
+		adminRoute.GET("/jobs-table", JobsTable(jobs))
+		// nolint: contextcheck
+		adminRoute.GET("/audit-table", AuditTable(mint.MintDB))
+		// nolint: contextcheck
+		adminRoute.GET("/config-history", ConfigHistoryTable(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.GET("/admins-table", AdminsTable(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.GET("/sessions-table", SessionsTable(mint.MintDB))
+		// nolint: contextcheck
+		ownerRoute.GET("/api-keys-table", ApiKeysTable(mint.MintDB))
+
+		// json api for scripts, authenticated with api keys
+		ApiRoutes(r, mint, &adminHandler, jobs, IPAllowlistMiddleware(ipAllowlist))
 
 		liquidityMangerRouter := adminRoute.Group("")
 		// nolint: contextcheck
 		liquidityMangerRouter.Use(liquidityManagerMiddleware(mint))
+		liquidityOperatorRouter := liquidityMangerRouter.Group("", RequireRole(database.AdminOperator))
 		// nolint: contextcheck
 		liquidityMangerRouter.GET("/liquidity", LigthningLiquidityPage(mint))
 		// nolint: contextcheck
@@ -226,23 +326,66 @@ func AdminRoutes(ctx context.Context, r *gin.Engine, mint *m.Mint) {
 		// nolint: contextcheck
 		liquidityMangerRouter.GET("/lightning-swap-form", LightningSwapForm())
 		// nolint: contextcheck
-		liquidityMangerRouter.POST("/out-swap-req", SwapOutRequest(mint))
+		liquidityOperatorRouter.POST("/out-swap-req", SwapOutRequest(mint))
 		// nolint: contextcheck
-		liquidityMangerRouter.POST("/in-swap-req", SwapInRequest(mint, newLiquidity))
+		liquidityOperatorRouter.POST("/in-swap-req", SwapInRequest(mint))
 		// nolint: contextcheck
 		liquidityMangerRouter.GET("/liquidity-summary", LiquiditySummaryComponent(&adminHandler))
 		// nolint: contextcheck
 		liquidityMangerRouter.GET("/swap/:swapId", SwapStateCheck(mint))
 		// nolint: contextcheck
-		liquidityMangerRouter.POST("/swap/:swapId/confirm", ConfirmSwapOutTransaction(mint, newLiquidity))
-		// nolint: contextcheck
-		go CheckStatusOfLiquiditySwaps(mint, newLiquidity)
+		liquidityOperatorRouter.POST("/swap/:swapId/confirm", ConfirmSwapOutTransaction(mint))
+		err = jobs.Register(scheduler.Job{
+			Name:       LiquiditySwapsJob,
+			Interval:   5 * time.Second,
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				if !utils.CanUseLiquidityManager(mint.Config().MINT_LIGHTNING_BACKEND) {
+					return nil
+				}
+				return CheckStatusOfLiquiditySwaps(ctx, mint)
+			},
+		})
+		if err != nil {
+			log.Panicf("jobs.Register(LiquiditySwapsJob). %+v", err)
+		}
+	}
+}
+
+const (
+	// AdminSessionStoreEnv selects where logged out admin sessions are kept.
+	// Set it to "database" when running several mint replicas.
+	AdminSessionStoreEnv      = "ADMIN_SESSION_STORE"
+	AdminSessionStoreDatabase = "database"
+	// AdminJWTSecretEnv is a hex encoded 32 byte key used to sign admin
+	// sessions. Replicas need to share it so a session is valid on all of them.
+	AdminJWTSecretEnv = "ADMIN_JWT_SECRET"
+)
+
+// sessionSigningKey returns the key used to sign admin sessions. When no
+// secret is configured a random key is generated on every start.
+func sessionSigningKey() (*secp256k1.PrivateKey, error) {
+	secretHex := os.Getenv(AdminJWTSecretEnv)
+	if secretHex == "" {
+		return secp256k1.GeneratePrivateKey()
+	}
+
+	secret, err := hex.DecodeString(secretHex)
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString(%s). %w", AdminJWTSecretEnv, err)
 	}
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("%s should be 32 bytes, got %d", AdminJWTSecretEnv, len(secret))
+	}
+
+	return secp256k1.PrivKeyFromBytes(secret), nil
 }
+
 func liquidityManagerMiddleware(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !utils.CanUseLiquidityManager(mint.Config.MINT_LIGHTNING_BACKEND) {
-			slog.Debug("Liquidity manager is not available", slog.String("backend", string(mint.Config.MINT_LIGHTNING_BACKEND)))
+		config := mint.Config()
+		if !utils.CanUseLiquidityManager(config.MINT_LIGHTNING_BACKEND) {
+			slog.Debug("Liquidity manager is not available", slog.String("backend", string(config.MINT_LIGHTNING_BACKEND)))
 			c.Status(404)
 			return
 		}
@@ -53,7 +53,7 @@ func SummaryComponent(mint *m.Mint, adminHandler *adminHandler) gin.HandlerFunc
 			return
 		}
 
-		lnBalance, err := mint.LightningBackend.WalletBalance()
+		lnBalance, err := mint.LightningBackend().WalletBalance()
 		if err != nil {
 			_ = c.Error(err)
 			return
@@ -65,7 +65,7 @@ func SummaryComponent(mint *m.Mint, adminHandler *adminHandler) gin.HandlerFunc
 			sinceDate = "the beginning"
 		}
 
-		summary := buildSummaryFromStats(statsRows, lnBalance, mint.Config.MINT_LIGHTNING_BACKEND == utils.FAKE_WALLET, sinceDate)
+		summary := buildSummaryFromStats(statsRows, lnBalance, mint.Config().MINT_LIGHTNING_BACKEND == utils.FAKE_WALLET, sinceDate)
 
 		err = templates.SummaryComponent(summary).Render(c.Request.Context(), c.Writer)
 		if err != nil {
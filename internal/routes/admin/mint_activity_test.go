@@ -87,14 +87,14 @@ func TestBalanceFromStatsSnapshotsReturnsZeroBalanceForEmptyInput(t *testing.T)
 func summaryTestMint(db *mockdb.MockDB) *mint.Mint {
 	var m mint.Mint
 	m.MintDB = db
-	m.LightningBackend = lightning.FakeWallet{ //nolint:exhaustruct
+	m.SetLightningBackend(lightning.FakeWallet{ //nolint:exhaustruct
 		UnpurposeErrors: nil,
 		Network:         chaincfg.RegressionNetParams,
 		InvoiceFee:      0,
-	}
-	m.Config = utils.Config{ //nolint:exhaustruct
+	})
+	m.SetConfig(utils.Config{ //nolint:exhaustruct
 		MINT_LIGHTNING_BACKEND: utils.FAKE_WALLET,
-	}
+	})
 	return &m
 }
 
@@ -0,0 +1,455 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/audit"
+	rpc "github.com/lescuer97/nutmix/internal/gen/cdk_mint_rpc"
+	m "github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Environment variables of the mint management rpc. The server only starts
+// when MINT_RPC_ADDRESS is set and always requires a client certificate.
+const (
+	MintRpcAddressEnv = "MINT_RPC_ADDRESS"
+	MintRpcTlsCertEnv = "MINT_RPC_TLS_CERT"
+	MintRpcTlsKeyEnv  = "MINT_RPC_TLS_KEY"
+	MintRpcCaCertEnv  = "MINT_RPC_CA_CERT"
+)
+
+// mintRpcKeysetExpiryHours is the expiry limit sent with rotations, the same
+// default as the keyset rotation form of the dashboard: the keyset never
+// expires.
+const mintRpcKeysetExpiryHours uint = 0
+
+const mintRpcActorPrefix = "mint-rpc:"
+
+var ErrMintRpcCaMissing = errors.New("a CA certificate is needed to verify mint rpc clients")
+
+// MintRpcServer implements the cdk-mint-rpc management service on top of the
+// mint config, so tools written for cdk can manage nutmix.
+type MintRpcServer struct {
+	rpc.UnimplementedCdkMintServer
+	mint         *m.Mint
+	adminHandler *adminHandler
+}
+
+func NewMintRpcServer(mint *m.Mint) *MintRpcServer {
+	adminHandler := newAdminHandler(mint)
+	return &MintRpcServer{
+		UnimplementedCdkMintServer: rpc.UnimplementedCdkMintServer{},
+		mint:                       mint,
+		adminHandler:               &adminHandler,
+	}
+}
+
+// MintRpcCredentials loads the server certificate and only accepts clients
+// with a certificate signed by the CA in caCertPath.
+func MintRpcCredentials(certPath string, keyPath string, caCertPath string) (credentials.TransportCredentials, error) {
+	if caCertPath == "" {
+		return nil, ErrMintRpcCaMissing
+	}
+	serverCert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("tls.LoadX509KeyPair(certPath, keyPath). %w", err)
+	}
+	caCert, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(caCertPath). %w", err)
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("could not add the mint rpc CA certificate to the pool")
+	}
+
+	//nolint:exhaustruct
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// StartMintRpcServer serves the management rpc on address until the returned
+// server is stopped.
+func StartMintRpcServer(mint *m.Mint, address string, creds credentials.TransportCredentials) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("net.Listen(tcp, address). %w", err)
+	}
+
+	server := grpc.NewServer(grpc.Creds(creds))
+	rpc.RegisterCdkMintServer(server, NewMintRpcServer(mint))
+	go func() {
+		slog.Info("Mint rpc served", slog.String("address", address))
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			slog.Error("mint rpc server failed", slog.Any("error", err))
+		}
+	}()
+	return server, nil
+}
+
+// mintRpcCaller names the client in the audit log by the common name of its
+// certificate.
+func mintRpcCaller(ctx context.Context) (string, string) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return mintRpcActorPrefix + "unknown", ""
+	}
+	ip := ""
+	if p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err == nil {
+			ip = host
+		}
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return mintRpcActorPrefix + "unknown", ip
+	}
+	return mintRpcActorPrefix + tlsInfo.State.PeerCertificates[0].Subject.CommonName, ip
+}
+
+// updateConfig applies change to a copy of the config, stores it and only then
+// makes it the config of the mint.
+func (s *MintRpcServer) updateConfig(ctx context.Context, change func(config *utils.Config) error) (*rpc.UpdateResponse, error) {
+	actor, ip := mintRpcCaller(ctx)
+	var changeErr error
+	revision, err := s.mint.ChangeConfig(ctx, actor, func(config *utils.Config) error {
+		changeErr = change(config)
+		return changeErr
+	})
+	if changeErr != nil {
+		return nil, changeErr
+	}
+	if errors.Is(err, m.ErrInvalidConfig) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "s.mint.ChangeConfig(ctx, actor, change)", slog.Any("error", err))
+		return nil, status.Error(codes.Internal, "could not save the config")
+	}
+
+	if len(revision.Diff) > 0 {
+		saveAuditEntry(ctx, s.mint.MintDB, audit.ActionConfigGeneral, actor, ip, revision.Diff)
+	}
+	return &rpc.UpdateResponse{}, nil
+}
+
+func (s *MintRpcServer) GetInfo(ctx context.Context, _ *rpc.GetInfoRequest) (*rpc.GetInfoResponse, error) {
+	info := s.mint.Info()
+	balance, err := s.adminHandler.EcashBalance(time.Unix(0, 0))
+	if err != nil {
+		slog.ErrorContext(ctx, "s.adminHandler.EcashBalance(time.Unix(0, 0))", slog.Any("error", err))
+		return nil, status.Error(codes.Internal, "could not get the mint balance")
+	}
+
+	contacts := make([]*rpc.ContactInfo, len(info.Contact))
+	for i, contact := range info.Contact {
+		contacts[i] = &rpc.ContactInfo{Method: contact.Method, Info: contact.Info}
+	}
+	return &rpc.GetInfoResponse{
+		Name:            &info.Name,
+		Version:         &info.Version,
+		Description:     &info.Description,
+		LongDescription: &info.DescriptionLong,
+		Contact:         contacts,
+		Motd:            &info.Motd,
+		IconUrl:         info.IconUrl,
+		Urls:            info.Urls,
+		TotalIssued:     balance.BlindSigsAmount,
+		TotalRedeemed:   balance.ProofsAmount,
+	}, nil
+}
+
+func (s *MintRpcServer) UpdateMotd(ctx context.Context, req *rpc.UpdateMotdRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		config.MOTD = req.GetMotd()
+		return nil
+	})
+}
+
+func (s *MintRpcServer) UpdateShortDescription(ctx context.Context, req *rpc.UpdateDescriptionRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		config.DESCRIPTION = req.GetDescription()
+		return nil
+	})
+}
+
+func (s *MintRpcServer) UpdateLongDescription(ctx context.Context, req *rpc.UpdateDescriptionRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		config.DESCRIPTION_LONG = req.GetDescription()
+		return nil
+	})
+}
+
+func (s *MintRpcServer) UpdateName(ctx context.Context, req *rpc.UpdateNameRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		config.NAME = req.GetName()
+		return nil
+	})
+}
+
+func (s *MintRpcServer) UpdateIconUrl(ctx context.Context, req *rpc.UpdateIconUrlRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		iconUrl := strings.TrimSpace(req.GetIconUrl())
+		if iconUrl == "" {
+			config.IconUrl = nil
+			return nil
+		}
+		err := validateURL(iconUrl)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid icon url: %v", err)
+		}
+		config.IconUrl = &iconUrl
+		return nil
+	})
+}
+
+func (s *MintRpcServer) AddUrl(ctx context.Context, req *rpc.UpdateUrlRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		mintUrl := strings.TrimSpace(req.GetUrl())
+		if mintUrl == "" {
+			return status.Error(codes.InvalidArgument, "url is empty")
+		}
+		err := validateURL(mintUrl)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid url: %v", err)
+		}
+		if !slices.Contains(config.URLS, mintUrl) {
+			config.URLS = append(config.URLS, mintUrl)
+		}
+		return nil
+	})
+}
+
+func (s *MintRpcServer) RemoveUrl(ctx context.Context, req *rpc.UpdateUrlRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		mintUrl := strings.TrimSpace(req.GetUrl())
+		index := slices.Index(config.URLS, mintUrl)
+		if index < 0 {
+			return status.Errorf(codes.NotFound, "url %s is not in the mint info", mintUrl)
+		}
+		config.URLS = slices.Delete(config.URLS, index, index+1)
+		return nil
+	})
+}
+
+// nutmix keeps one contact per method, so adding a contact replaces the
+// previous one of the same method.
+func (s *MintRpcServer) AddContact(ctx context.Context, req *rpc.UpdateContactRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		info := strings.TrimSpace(req.GetInfo())
+		if info == "" {
+			return status.Error(codes.InvalidArgument, "contact info is empty")
+		}
+		switch strings.ToLower(req.GetMethod()) {
+		case "email":
+			config.EMAIL = info
+		case "nostr":
+			isValid, err := isNostrKeyValid(info)
+			if err != nil || !isValid {
+				return status.Error(codes.InvalidArgument, ErrInvalidNostrKey.Error())
+			}
+			config.NOSTR = info
+		default:
+			return status.Errorf(codes.InvalidArgument, "contact method %q is not supported, use email or nostr", req.GetMethod())
+		}
+		return nil
+	})
+}
+
+func (s *MintRpcServer) RemoveContact(ctx context.Context, req *rpc.UpdateContactRequest) (*rpc.UpdateResponse, error) {
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		info := strings.TrimSpace(req.GetInfo())
+		var contact *string
+		switch strings.ToLower(req.GetMethod()) {
+		case "email":
+			contact = &config.EMAIL
+		case "nostr":
+			contact = &config.NOSTR
+		default:
+			return status.Errorf(codes.InvalidArgument, "contact method %q is not supported, use email or nostr", req.GetMethod())
+		}
+		if *contact == "" || (info != "" && *contact != info) {
+			return status.Errorf(codes.NotFound, "contact %s %s is not in the mint info", req.GetMethod(), info)
+		}
+		*contact = ""
+		return nil
+	})
+}
+
+// checkBolt11Sat rejects settings for methods and units nutmix does not have
+// limits for.
+func checkBolt11Sat(unit string, method string) error {
+	if unit != cashu.Sat.String() || method != cashu.MethodBolt11 {
+		return status.Errorf(codes.InvalidArgument, "only the %s %s method can be configured", cashu.Sat.String(), cashu.MethodBolt11)
+	}
+	return nil
+}
+
+// maxAmountLimit turns a max amount into a config limit. Zero removes it.
+func maxAmountLimit(maxAmount uint64) (*int, error) {
+	if maxAmount == 0 {
+		return nil, nil
+	}
+	if maxAmount > uint64(1<<62) {
+		return nil, status.Error(codes.InvalidArgument, "max amount is too big")
+	}
+	limit := int(maxAmount)
+	return &limit, nil
+}
+
+func (s *MintRpcServer) UpdateNut04(ctx context.Context, req *rpc.UpdateNut04Request) (*rpc.UpdateResponse, error) {
+	err := checkBolt11Sat(req.GetUnit(), req.GetMethod())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetMinAmount() != 0 {
+		return nil, status.Error(codes.Unimplemented, "nutmix does not have a minimum mint amount")
+	}
+	if req.Options != nil && req.Options.GetDescription() != s.mint.LightningBackend().DescriptionSupport() {
+		return nil, status.Error(codes.FailedPrecondition, "invoice description support depends on the lightning backend")
+	}
+
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		if req.Disabled != nil {
+			config.PEG_OUT_ONLY = req.GetDisabled()
+		}
+		if req.MaxAmount != nil {
+			limit, err := maxAmountLimit(req.GetMaxAmount())
+			if err != nil {
+				return err
+			}
+			config.PEG_IN_LIMIT_SATS = limit
+		}
+		return nil
+	})
+}
+
+func (s *MintRpcServer) UpdateNut05(ctx context.Context, req *rpc.UpdateNut05Request) (*rpc.UpdateResponse, error) {
+	err := checkBolt11Sat(req.GetUnit(), req.GetMethod())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDisabled() {
+		return nil, status.Error(codes.Unimplemented, "nutmix can not disable melting")
+	}
+	if req.GetMinAmount() != 0 {
+		return nil, status.Error(codes.Unimplemented, "nutmix does not have a minimum melt amount")
+	}
+	if req.Options.GetAmountless() {
+		return nil, status.Error(codes.Unimplemented, "nutmix does not support amountless invoices")
+	}
+
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		if req.MaxAmount != nil {
+			limit, err := maxAmountLimit(req.GetMaxAmount())
+			if err != nil {
+				return err
+			}
+			config.PEG_OUT_LIMIT_SATS = limit
+		}
+		return nil
+	})
+}
+
+func (s *MintRpcServer) UpdateQuoteTtl(ctx context.Context, req *rpc.UpdateQuoteTtlRequest) (*rpc.UpdateResponse, error) {
+	if (req.MintTtl != nil && req.GetMintTtl() == 0) || (req.MeltTtl != nil && req.GetMeltTtl() == 0) {
+		return nil, status.Error(codes.InvalidArgument, "quote ttl should be more than zero seconds")
+	}
+	return s.updateConfig(ctx, func(config *utils.Config) error {
+		if req.MintTtl != nil {
+			config.MINT_QUOTE_TTL = req.GetMintTtl()
+		}
+		if req.MeltTtl != nil {
+			config.MELT_QUOTE_TTL = req.GetMeltTtl()
+		}
+		return nil
+	})
+}
+
+func (s *MintRpcServer) GetQuoteTtl(_ context.Context, _ *rpc.GetQuoteTtlRequest) (*rpc.GetQuoteTtlResponse, error) {
+	config := s.mint.Config()
+	return &rpc.GetQuoteTtlResponse{
+		MintTtl: utils.QuoteTtlOrDefault(config.MINT_QUOTE_TTL),
+		MeltTtl: utils.QuoteTtlOrDefault(config.MELT_QUOTE_TTL),
+	}, nil
+}
+
+// UpdateNut04Quote is not supported: marking a quote as paid by hand would
+// let the caller mint ecash that is not backed by a payment.
+func (s *MintRpcServer) UpdateNut04Quote(_ context.Context, _ *rpc.UpdateNut04QuoteRequest) (*rpc.UpdateNut04QuoteRequest, error) {
+	return nil, status.Error(codes.Unimplemented, "nutmix does not change the state of mint quotes by hand")
+}
+
+func (s *MintRpcServer) RotateNextKeyset(ctx context.Context, req *rpc.RotateNextKeysetRequest) (*rpc.RotateNextKeysetResponse, error) {
+	unit, err := cashu.UnitFromString(req.GetUnit())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ErrUnitNotCorrect.Error())
+	}
+
+	rotateRequest := RotateRequest{
+		Fee:              uint(req.GetInputFeePpk()),
+		Unit:             unit,
+		ExpireLimitHours: mintRpcKeysetExpiryHours,
+		Amounts:          nil,
+		MaxOrder:         uint(req.GetMaxOrder()),
+	}
+	amounts, err := rotateRequest.keysetAmounts()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = s.adminHandler.rotateKeyset(ctx, rotateRequest.Unit, rotateRequest.Fee, rotateRequest.ExpireLimitHours, amounts)
+	if err != nil {
+		slog.ErrorContext(ctx, "s.adminHandler.rotateKeyset(unit, fee, expiry)", slog.Any("error", err))
+		return nil, status.Error(codes.Internal, "could not rotate the keyset")
+	}
+	diff, err := audit.Details(rotateRequest)
+	if err != nil {
+		slog.ErrorContext(ctx, "audit.Details(rotateRequest)", slog.Any("error", err))
+	} else {
+		actor, ip := mintRpcCaller(ctx)
+		saveAuditEntry(ctx, s.mint.MintDB, audit.ActionKeysetRotate, actor, ip, diff)
+	}
+
+	keysets, err := s.mint.Signer.GetKeysets()
+	if err != nil {
+		slog.ErrorContext(ctx, "s.mint.Signer.GetKeysets()", slog.Any("error", err))
+		return nil, status.Error(codes.Internal, "could not get the new keyset")
+	}
+	maxOrder := cashu.MaxKeysetAmount
+	if len(amounts) > 0 {
+		maxOrder = len(amounts)
+	}
+	for _, keyset := range keysets.Keysets {
+		if keyset.Active && keyset.Unit == unit.String() {
+			return &rpc.RotateNextKeysetResponse{
+				Id:          keyset.Id,
+				Unit:        keyset.Unit,
+				MaxOrder:    uint32(maxOrder),
+				InputFeePpk: uint64(keyset.InputFeePpk),
+			}, nil
+		}
+	}
+	return nil, status.Error(codes.Internal, "the rotated keyset is not active")
+}
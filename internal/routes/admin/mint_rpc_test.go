@@ -0,0 +1,146 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"testing"
+
+	"github.com/lescuer97/nutmix/internal/audit"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	rpc "github.com/lescuer97/nutmix/internal/gen/cdk_mint_rpc"
+	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func mintRpcTestServer() (*MintRpcServer, *mockdb.MockDB) {
+	var config utils.Config
+	config.Default()
+	var mintInstance mint.Mint
+	mintInstance.SetConfig(config)
+	mintInstance.SetLightningBackend(lightning.FakeWallet{})
+
+	var mockDatabase mockdb.MockDB
+	mockDatabase.Config = config
+	mintInstance.MintDB = &mockDatabase
+	return NewMintRpcServer(&mintInstance), &mockDatabase
+}
+
+func TestMintRpcUpdateInfo(t *testing.T) {
+	server, db := mintRpcTestServer()
+	ctx := t.Context()
+
+	_, err := server.UpdateMotd(ctx, &rpc.UpdateMotdRequest{Motd: "maintenance at noon"})
+	if err != nil {
+		t.Fatalf("server.UpdateMotd: %v", err)
+	}
+	_, err = server.AddUrl(ctx, &rpc.UpdateUrlRequest{Url: "https://mint.example"})
+	if err != nil {
+		t.Fatalf("server.AddUrl: %v", err)
+	}
+	_, err = server.AddUrl(ctx, &rpc.UpdateUrlRequest{Url: "https://mint.example"})
+	if err != nil {
+		t.Fatalf("server.AddUrl: %v", err)
+	}
+	_, err = server.AddContact(ctx, &rpc.UpdateContactRequest{Method: "email", Info: "mint@example.com"})
+	if err != nil {
+		t.Fatalf("server.AddContact: %v", err)
+	}
+
+	info, err := server.GetInfo(ctx, &rpc.GetInfoRequest{})
+	if err != nil {
+		t.Fatalf("server.GetInfo: %v", err)
+	}
+	if info.GetMotd() != "maintenance at noon" {
+		t.Errorf("expected the new motd, got %q", info.GetMotd())
+	}
+	if len(info.GetUrls()) != 1 || info.GetUrls()[0] != "https://mint.example" {
+		t.Errorf("expected the url once, got %v", info.GetUrls())
+	}
+	if len(info.GetContact()) != 1 || info.GetContact()[0].GetInfo() != "mint@example.com" {
+		t.Errorf("expected the email contact, got %v", info.GetContact())
+	}
+	if db.Config.MOTD != "maintenance at noon" || len(db.Config.URLS) != 1 {
+		t.Error("expected the changes to be stored")
+	}
+	if len(db.AuditLog) != 3 || db.AuditLog[0].Action != audit.ActionConfigGeneral || db.AuditLog[0].Actor != mintRpcActorPrefix+"unknown" {
+		t.Errorf("expected one audit entry per change, got %+v", db.AuditLog)
+	}
+
+	_, err = server.RemoveUrl(ctx, &rpc.UpdateUrlRequest{Url: "https://other.example"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected removing an unknown url to fail with NotFound, got %v", err)
+	}
+	_, err = server.RemoveContact(ctx, &rpc.UpdateContactRequest{Method: "email", Info: "mint@example.com"})
+	if err != nil {
+		t.Fatalf("server.RemoveContact: %v", err)
+	}
+	if server.mint.Config().EMAIL != "" {
+		t.Error("expected the email to be removed")
+	}
+
+	_, err = server.AddContact(ctx, &rpc.UpdateContactRequest{Method: "nostr", Info: "not a npub"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid npub to fail with InvalidArgument, got %v", err)
+	}
+	_, err = server.UpdateIconUrl(ctx, &rpc.UpdateIconUrlRequest{IconUrl: "not a url"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid icon url to fail with InvalidArgument, got %v", err)
+	}
+	if server.mint.Config().IconUrl != nil {
+		t.Error("expected a rejected change to leave the config as it was")
+	}
+}
+
+func TestMintRpcLimitsAndQuoteTtl(t *testing.T) {
+	server, _ := mintRpcTestServer()
+	ctx := t.Context()
+
+	_, err := server.UpdateNut04(ctx, &rpc.UpdateNut04Request{Unit: "sat", Method: "bolt11", Disabled: proto.Bool(true), MaxAmount: proto.Uint64(50000)})
+	if err != nil {
+		t.Fatalf("server.UpdateNut04: %v", err)
+	}
+	if !server.mint.Config().PEG_OUT_ONLY || server.mint.Config().PEG_IN_LIMIT_SATS == nil || *server.mint.Config().PEG_IN_LIMIT_SATS != 50000 {
+		t.Errorf("expected minting to be disabled with a limit of 50000, got %+v", server.mint.Config())
+	}
+	_, err = server.UpdateNut05(ctx, &rpc.UpdateNut05Request{Unit: "sat", Method: "bolt11", MaxAmount: proto.Uint64(0)})
+	if err != nil {
+		t.Fatalf("server.UpdateNut05: %v", err)
+	}
+	if server.mint.Config().PEG_OUT_LIMIT_SATS != nil {
+		t.Error("expected a max amount of zero to remove the melt limit")
+	}
+	_, err = server.UpdateNut04(ctx, &rpc.UpdateNut04Request{Unit: "usd", Method: "bolt11"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected a unit without limits to fail with InvalidArgument, got %v", err)
+	}
+	_, err = server.UpdateNut05(ctx, &rpc.UpdateNut05Request{Unit: "sat", Method: "bolt11", Disabled: proto.Bool(true)})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected disabling melts to fail with Unimplemented, got %v", err)
+	}
+
+	ttl, err := server.GetQuoteTtl(ctx, &rpc.GetQuoteTtlRequest{})
+	if err != nil {
+		t.Fatalf("server.GetQuoteTtl: %v", err)
+	}
+	if ttl.GetMintTtl() != utils.DefaultQuoteTtl || ttl.GetMeltTtl() != utils.DefaultQuoteTtl {
+		t.Errorf("expected the default ttls, got %+v", ttl)
+	}
+	_, err = server.UpdateQuoteTtl(ctx, &rpc.UpdateQuoteTtlRequest{MintTtl: proto.Uint64(3600)})
+	if err != nil {
+		t.Fatalf("server.UpdateQuoteTtl: %v", err)
+	}
+	ttl, err = server.GetQuoteTtl(ctx, &rpc.GetQuoteTtlRequest{})
+	if err != nil {
+		t.Fatalf("server.GetQuoteTtl: %v", err)
+	}
+	if ttl.GetMintTtl() != 3600 || ttl.GetMeltTtl() != utils.DefaultQuoteTtl {
+		t.Errorf("expected only the mint ttl to change, got %+v", ttl)
+	}
+	_, err = server.UpdateQuoteTtl(ctx, &rpc.UpdateQuoteTtlRequest{MeltTtl: proto.Uint64(0)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected a ttl of zero to fail with InvalidArgument, got %v", err)
+	}
+}
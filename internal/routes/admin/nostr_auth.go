@@ -0,0 +1,303 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/mint"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip46"
+	"golang.org/x/time/rate"
+)
+
+const (
+	nip98AuthScheme   = "Nostr "
+	nip98MaxClockSkew = 60 * time.Second
+	nip98MaxBodySize  = 1 << 20
+
+	// every step of a bunker login waits this long for the relays
+	bunkerStepTimeout = 10 * time.Second
+	bunkerLoginBurst  = 5
+
+	// AdminBunkerRelaysEnv is a comma separated list of the relays bunker logins
+	// can use. When it is empty any wss relay on a public address is allowed.
+	AdminBunkerRelaysEnv = "ADMIN_BUNKER_RELAYS"
+)
+
+// bunkerLoginRate is how often an address can start a bunker login after its
+// burst, every login opens connections to the relays it names.
+var bunkerLoginRate = rate.Every(12 * time.Second)
+
+var (
+	ErrInvalidNip98Auth = errors.New("invalid NIP-98 authorization")
+	ErrNip98Replayed    = errors.New("NIP-98 event was already used")
+	ErrInvalidBunkerURL = errors.New("bunker url is not valid")
+	ErrBunkerRelay      = errors.New("bunker relay is not allowed")
+)
+
+func parseNip98Header(header string) (nostr.Event, error) {
+	var event nostr.Event
+	encoded, found := strings.CutPrefix(header, nip98AuthScheme)
+	if !found {
+		return event, fmt.Errorf("%w: missing %q scheme", ErrInvalidNip98Auth, strings.TrimSpace(nip98AuthScheme))
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return event, fmt.Errorf("%w: base64.StdEncoding.DecodeString(encoded). %w", ErrInvalidNip98Auth, err)
+	}
+	err = json.Unmarshal(decoded, &event)
+	if err != nil {
+		return event, fmt.Errorf("%w: json.Unmarshal(decoded, &event). %w", ErrInvalidNip98Auth, err)
+	}
+	return event, nil
+}
+
+// verifyNip98Event checks event authorizes exactly this request. The scheme of
+// the u tag is not compared because the mint usually sits behind a TLS proxy.
+func verifyNip98Event(event nostr.Event, req *http.Request, body []byte, now time.Time) error {
+	if event.Kind != nostr.KindHTTPAuth {
+		return fmt.Errorf("%w: kind %d", ErrInvalidNip98Auth, event.Kind)
+	}
+
+	createdAt := event.CreatedAt.Time()
+	if createdAt.Before(now.Add(-nip98MaxClockSkew)) || createdAt.After(now.Add(nip98MaxClockSkew)) {
+		return fmt.Errorf("%w: event is too old or in the future", ErrInvalidNip98Auth)
+	}
+
+	urlTag := event.Tags.GetFirst([]string{"u", ""})
+	if urlTag == nil {
+		return fmt.Errorf("%w: missing u tag", ErrInvalidNip98Auth)
+	}
+	signedURL, err := url.Parse(urlTag.Value())
+	if err != nil {
+		return fmt.Errorf("%w: url.Parse(u). %w", ErrInvalidNip98Auth, err)
+	}
+	if signedURL.Host != req.Host || signedURL.Path != req.URL.Path || signedURL.RawQuery != req.URL.RawQuery {
+		return fmt.Errorf("%w: u tag %s does not match the request", ErrInvalidNip98Auth, urlTag.Value())
+	}
+
+	methodTag := event.Tags.GetFirst([]string{"method", ""})
+	if methodTag == nil || !strings.EqualFold(methodTag.Value(), req.Method) {
+		return fmt.Errorf("%w: method tag does not match %s", ErrInvalidNip98Auth, req.Method)
+	}
+
+	// a signed request body can not be swapped for another one
+	payloadTag := event.Tags.GetFirst([]string{"payload", ""})
+	if len(body) > 0 && payloadTag == nil {
+		return fmt.Errorf("%w: missing payload tag", ErrInvalidNip98Auth)
+	}
+	if payloadTag != nil {
+		hash := sha256.Sum256(body)
+		if !strings.EqualFold(payloadTag.Value(), hex.EncodeToString(hash[:])) {
+			return fmt.Errorf("%w: payload tag does not match the body", ErrInvalidNip98Auth)
+		}
+	}
+
+	validSig, err := event.CheckSignature()
+	if err != nil || !validSig {
+		return errors.Join(ErrInvalidNip98Auth, ErrInvalidNostrSignature, err)
+	}
+	return nil
+}
+
+// nip98Admin authenticates a request signed with NIP-98 so scripts can call the
+// admin endpoints without a session. Every event can only be used once, its id
+// is kept in the nostr login nonce table.
+func nip98Admin(c *gin.Context, db database.MintDB) (*database.Admin, error) {
+	event, err := parseNip98Header(c.GetHeader("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, nip98MaxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll(c.Request.Body). %w", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = verifyNip98Event(event, c.Request, body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := db.GetAdmin(c.Request.Context(), event.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetAdmin(ctx, event.PubKey). %w", err)
+	}
+	if admin == nil {
+		recordAudit(c, db, audit.ActionLoginFailed, event.PubKey, nil)
+		return nil, ErrIncorrectNpub
+	}
+
+	err = db.SaveNostrAuth(database.NostrLoginAuth{
+		Nonce:     event.ID,
+		Activated: true,
+		Expiry:    int(event.CreatedAt.Time().Add(nip98MaxClockSkew).Unix()),
+	})
+	if err != nil {
+		return nil, errors.Join(ErrNip98Replayed, err)
+	}
+	return admin, nil
+}
+
+// adminBunkerRelays reads AdminBunkerRelaysEnv.
+func adminBunkerRelays() []string {
+	relays := []string{}
+	for _, relay := range strings.Split(os.Getenv(AdminBunkerRelaysEnv), ",") {
+		relay = strings.TrimSpace(relay)
+		if relay != "" {
+			relays = append(relays, nostr.NormalizeURL(relay))
+		}
+	}
+	return relays
+}
+
+// checkBunkerRelays stops the mint from connecting to relays the operator did
+// not allow, or without an allowlist to anything but a wss relay, and returns
+// the relays. Names are only checked to be public when the relay proxy
+// connects to them, addresses are checked here too so the error is clear.
+func checkBunkerRelays(bunkerURL string, allowed []string) ([]string, error) {
+	parsed, err := url.Parse(bunkerURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: url.Parse(bunkerURL). %w", ErrInvalidBunkerURL, err)
+	}
+	relays := parsed.Query()["relay"]
+	if len(relays) == 0 {
+		return nil, fmt.Errorf("%w: no relays", ErrInvalidBunkerURL)
+	}
+
+	normalized := make([]string, 0, len(relays))
+	for _, relay := range relays {
+		relay = nostr.NormalizeURL(relay)
+		normalized = append(normalized, relay)
+		if len(allowed) > 0 {
+			if !slices.Contains(allowed, relay) {
+				return nil, fmt.Errorf("%w: %s is not in %s", ErrBunkerRelay, relay, AdminBunkerRelaysEnv)
+			}
+			continue
+		}
+
+		relayURL, err := url.Parse(relay)
+		if err != nil || relayURL.Scheme != "wss" {
+			return nil, fmt.Errorf("%w: %s is not a wss url", ErrBunkerRelay, relay)
+		}
+		addr, err := netip.ParseAddr(strings.Trim(relayURL.Hostname(), "[]"))
+		if err == nil && !isPublicAddr(addr) {
+			return nil, fmt.Errorf("%w: %s is not a public address", ErrBunkerRelay, addr)
+		}
+	}
+	return normalized, nil
+}
+
+// signLoginWithBunker asks the NIP-46 remote signer behind bunkerURL to sign
+// the login nonce. onAuth gets the url the admin has to open when the signer
+// asks for approval.
+func signLoginWithBunker(ctx context.Context, bunkerURL string, allowedRelays []string, nonce string, onAuth func(string)) (nostr.Event, error) {
+	//nolint:exhaustruct
+	event := nostr.Event{}
+	if !nip46.IsValidBunkerURL(bunkerURL) {
+		return event, ErrInvalidBunkerURL
+	}
+
+	relays, err := checkBunkerRelays(bunkerURL, allowedRelays)
+	if err != nil {
+		return event, err
+	}
+
+	// relays the operator allowed can be internal, the others go through the
+	// proxy so they can only connect to public addresses
+	var proxy *relayProxy
+	if len(allowedRelays) == 0 {
+		var local map[string]string
+		proxy, local, err = newRelayProxy(relays)
+		if err != nil {
+			return event, err
+		}
+		defer proxy.Close()
+		bunkerURL, err = proxiedBunkerURL(bunkerURL, local)
+		if err != nil {
+			return event, err
+		}
+	}
+
+	pool := nostr.NewSimplePool(ctx)
+	defer pool.Close("login finished")
+
+	stepCtx, cancel := context.WithTimeout(ctx, bunkerStepTimeout)
+	defer cancel()
+	bunker, err := nip46.ConnectBunker(stepCtx, nostr.GeneratePrivateKey(), bunkerURL, pool, onAuth)
+	if err != nil {
+		if proxy != nil && proxy.err() != nil {
+			return event, proxy.err()
+		}
+		return event, fmt.Errorf("nip46.ConnectBunker(ctx, clientKey, bunkerURL, pool, onAuth). %w", err)
+	}
+	stepCtx, cancel = context.WithTimeout(ctx, bunkerStepTimeout)
+	defer cancel()
+	pubkey, err := bunker.GetPublicKey(stepCtx)
+	if err != nil {
+		return event, fmt.Errorf("bunker.GetPublicKey(ctx). %w", err)
+	}
+
+	event.PubKey = pubkey
+	event.Kind = nostr.KindHTTPAuth
+	event.Content = nonce
+	event.CreatedAt = nostr.Now()
+	event.Tags = nostr.Tags{}
+	stepCtx, cancel = context.WithTimeout(ctx, bunkerStepTimeout)
+	defer cancel()
+	err = bunker.SignEvent(stepCtx, &event)
+	if err != nil {
+		return event, fmt.Errorf("bunker.SignEvent(ctx, &event). %w", err)
+	}
+	return event, nil
+}
+
+func BunkerLoginPost(mint *mint.Mint, loginKey *secp256k1.PrivateKey, allowedRelays []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bunkerURL := strings.TrimSpace(c.PostForm("bunker_url"))
+		nonce := c.PostForm("passwordNonce")
+
+		authURL := ""
+		event, err := signLoginWithBunker(c.Request.Context(), bunkerURL, allowedRelays, nonce, func(url string) {
+			authURL = url
+		})
+		if err != nil {
+			slog.Info("signLoginWithBunker(ctx, bunkerURL, allowedRelays, nonce)", slog.Any("error", err))
+			message := "Could not sign the login with the remote signer"
+			switch {
+			case errors.Is(err, ErrInvalidBunkerURL):
+				message = "Bunker URL is not valid"
+			case errors.Is(err, ErrBunkerRelay):
+				message = "The relays of the bunker URL are not allowed"
+			case authURL != "":
+				message = "Approve the login in your remote signer and try again: " + authURL
+			}
+			if renderErr := RenderError(c, message); renderErr != nil {
+				slog.Warn("failed to render error", slog.Any("error", renderErr))
+			}
+			return
+		}
+
+		loginWithNostrEvent(c, mint, loginKey, event)
+	}
+}
@@ -0,0 +1,282 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/lescuer97/nutmix/internal/database"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/routes/middleware"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func signedNip98Event(t *testing.T, secretKey string, method string, u string, body []byte, createdAt time.Time) nostr.Event {
+	t.Helper()
+	event := nostr.Event{
+		Kind:      nostr.KindHTTPAuth,
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Tags:      nostr.Tags{{"u", u}, {"method", method}},
+	}
+	if len(body) > 0 {
+		hash := sha256.Sum256(body)
+		event.Tags = append(event.Tags, nostr.Tag{"payload", hex.EncodeToString(hash[:])})
+	}
+	err := event.Sign(secretKey)
+	if err != nil {
+		t.Fatalf("event.Sign: %v", err)
+	}
+	return event
+}
+
+func nip98Header(t *testing.T, event nostr.Event) string {
+	t.Helper()
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return nip98AuthScheme + base64.StdEncoding.EncodeToString(encoded)
+}
+
+func TestVerifyNip98Event(t *testing.T) {
+	secretKey := nostr.GeneratePrivateKey()
+	now := time.Now()
+	body := []byte(`{"unit":"sat"}`)
+
+	req := httptest.NewRequest(http.MethodPost, "http://mint.example/admin/rotate/sats?x=1", strings.NewReader(string(body)))
+
+	cases := []struct {
+		name  string
+		event nostr.Event
+		body  []byte
+		valid bool
+	}{
+		{"valid", signedNip98Event(t, secretKey, http.MethodPost, "https://mint.example/admin/rotate/sats?x=1", body, now), body, true},
+		{"wrong url", signedNip98Event(t, secretKey, http.MethodPost, "https://mint.example/admin/other?x=1", body, now), body, false},
+		{"wrong host", signedNip98Event(t, secretKey, http.MethodPost, "https://evil.example/admin/rotate/sats?x=1", body, now), body, false},
+		{"wrong method", signedNip98Event(t, secretKey, http.MethodGet, "https://mint.example/admin/rotate/sats?x=1", body, now), body, false},
+		{"stale", signedNip98Event(t, secretKey, http.MethodPost, "https://mint.example/admin/rotate/sats?x=1", body, now.Add(-2*time.Minute)), body, false},
+		{"payload mismatch", signedNip98Event(t, secretKey, http.MethodPost, "https://mint.example/admin/rotate/sats?x=1", body, now), []byte(`{"unit":"usd"}`), false},
+		{"missing payload", signedNip98Event(t, secretKey, http.MethodPost, "https://mint.example/admin/rotate/sats?x=1", nil, now), body, false},
+	}
+	for _, tc := range cases {
+		err := verifyNip98Event(tc.event, req, tc.body, now)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tc.name, tc.valid, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidNip98Auth) {
+			t.Errorf("%s: expected ErrInvalidNip98Auth, got %v", tc.name, err)
+		}
+	}
+
+	tampered := signedNip98Event(t, secretKey, http.MethodPost, "https://mint.example/admin/rotate/sats?x=1", body, now)
+	tampered.Tags = nostr.Tags{{"u", "https://mint.example/admin/rotate/sats?x=1"}, {"method", http.MethodPost}, {"payload", tampered.Tags[2][1]}, {"extra", "1"}}
+	err := verifyNip98Event(tampered, req, body, now)
+	if err == nil {
+		t.Error("expected an event changed after signing to be rejected")
+	}
+}
+
+func TestAuthMiddlewareNip98(t *testing.T) {
+	secretKey := nostr.GeneratePrivateKey()
+	pubkey, err := nostr.GetPublicKey(secretKey)
+	if err != nil {
+		t.Fatalf("nostr.GetPublicKey: %v", err)
+	}
+	db := &mockdb.MockDB{
+		Admins: []database.Admin{{Pubkey: pubkey, Role: database.AdminOperator}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AuthMiddleware([]byte("test-secret"), NewTokenBlacklist(), db))
+	var role string
+	r.GET("/admin/jobs", func(c *gin.Context) {
+		role = c.GetString(adminRoleKey)
+		c.Status(http.StatusOK)
+	})
+
+	request := func(event nostr.Event) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://mint.example/admin/jobs", nil)
+		req.Header.Set("Authorization", nip98Header(t, event))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	event := signedNip98Event(t, secretKey, http.MethodGet, "https://mint.example/admin/jobs", nil, time.Now())
+	w := request(event)
+	if w.Code != http.StatusOK || role != string(database.AdminOperator) {
+		t.Fatalf("expected the request to be accepted as an operator, got %d and role %q", w.Code, role)
+	}
+
+	w = request(event)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a replayed event to be rejected, got %d", w.Code)
+	}
+
+	strangerKey := nostr.GeneratePrivateKey()
+	w = request(signedNip98Event(t, strangerKey, http.MethodGet, "https://mint.example/admin/jobs", nil, time.Now()))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a pubkey that is not an admin to be rejected, got %d", w.Code)
+	}
+}
+
+func TestCheckBunkerRelays(t *testing.T) {
+	const pubkey = "bunker://79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	tests := []struct {
+		name    string
+		url     string
+		allowed []string
+		err     error
+	}{
+		{name: "public wss relay", url: pubkey + "?relay=wss://1.1.1.1", allowed: nil, err: nil},
+		{name: "no relays", url: pubkey, allowed: nil, err: ErrInvalidBunkerURL},
+		{name: "plain websocket", url: pubkey + "?relay=ws://1.1.1.1", allowed: nil, err: ErrBunkerRelay},
+		{name: "loopback", url: pubkey + "?relay=wss://127.0.0.1:8080", allowed: nil, err: ErrBunkerRelay},
+		{name: "private network", url: pubkey + "?relay=wss://1.1.1.1&relay=wss://10.0.0.2", allowed: nil, err: ErrBunkerRelay},
+		{name: "carrier grade nat", url: pubkey + "?relay=wss://100.64.0.1", allowed: nil, err: ErrBunkerRelay},
+		{name: "nat64", url: pubkey + "?relay=wss://[64:ff9b::a00:2]", allowed: nil, err: ErrBunkerRelay},
+		{name: "names are checked when dialing", url: pubkey + "?relay=wss://localhost", allowed: nil, err: nil},
+		{name: "allowed relay", url: pubkey + "?relay=wss://relay.example.com/", allowed: []string{"wss://relay.example.com"}, err: nil},
+		{name: "relay outside allowlist", url: pubkey + "?relay=wss://1.1.1.1", allowed: []string{"wss://relay.example.com"}, err: ErrBunkerRelay},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := checkBunkerRelays(test.url, test.allowed)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRelayProxyOnlyDialsPublicAddresses(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the proxy connected to a loopback relay")
+	}))
+	defer relay.Close()
+	relayURL := "ws://localhost:" + relay.URL[strings.LastIndex(relay.URL, ":")+1:]
+
+	proxy, local, err := newRelayProxy([]string{relayURL})
+	if err != nil {
+		t.Fatalf("newRelayProxy: %v", err)
+	}
+	defer proxy.Close()
+
+	bunkerURL, err := proxiedBunkerURL("bunker://79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798?relay="+relayURL+"&secret=abc", local)
+	if err != nil {
+		t.Fatalf("proxiedBunkerURL: %v", err)
+	}
+	parsed, err := url.Parse(bunkerURL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	if parsed.Query().Get("relay") != local[relayURL] || parsed.Query().Get("secret") != "abc" {
+		t.Fatalf("expected the relay to be replaced by the proxy, got %s", bunkerURL)
+	}
+
+	_, _, err = websocket.DefaultDialer.DialContext(t.Context(), local[relayURL], nil)
+	if err == nil {
+		t.Fatal("expected the proxy to refuse the loopback relay")
+	}
+	if !errors.Is(proxy.err(), ErrBunkerRelay) {
+		t.Errorf("expected ErrBunkerRelay, got %v", proxy.err())
+	}
+}
+
+func TestRelayProxyForwardsMessages(t *testing.T) {
+	var upgrader websocket.Upgrader
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(messageType, append([]byte("echo "), message...))
+		}
+	}))
+	defer relay.Close()
+	relayURL := "ws://" + strings.TrimPrefix(relay.URL, "http://")
+
+	proxy, local, err := newRelayProxy([]string{relayURL})
+	if err != nil {
+		t.Fatalf("newRelayProxy: %v", err)
+	}
+	defer proxy.Close()
+	// the test relay is on the loopback
+	proxy.dialer.NetDialContext = nil
+
+	conn, _, err := websocket.DefaultDialer.DialContext(t.Context(), local[relayURL], nil)
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+	err = conn.WriteMessage(websocket.TextMessage, []byte(`["REQ","sub"]`))
+	if err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil || string(message) != `echo ["REQ","sub"]` {
+		t.Errorf("expected the relay answer, got %q, %v", message, err)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	public := []string{"1.1.1.1", "8.8.8.8", "2606:4700:4700::1111", "::ffff:1.1.1.1"}
+	internal := []string{"127.0.0.1", "10.1.2.3", "100.100.0.1", "169.254.169.254", "0.1.2.3", "198.18.0.1", "240.0.0.1", "::1", "fd00::1", "fe80::1", "64:ff9b::7f00:1", "2002:7f00:1::"}
+	for _, addr := range public {
+		if !isPublicAddr(netip.MustParseAddr(addr)) {
+			t.Errorf("expected %s to be public", addr)
+		}
+	}
+	for _, addr := range internal {
+		if isPublicAddr(netip.MustParseAddr(addr)) {
+			t.Errorf("expected %s not to be public", addr)
+		}
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login/bunker", RateLimitByIP(middleware.NewIPRateLimiter(0, 2)), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	request := func(ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login/bunker", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	for range 2 {
+		if code := request("192.0.2.1"); code != http.StatusNoContent {
+			t.Fatalf("expected the burst to go through, got %d", code)
+		}
+	}
+	if code := request("192.0.2.1"); code == http.StatusNoContent {
+		t.Error("expected the third request to be limited")
+	}
+	if code := request("192.0.2.2"); code != http.StatusNoContent {
+		t.Errorf("expected another address to have its own limit, got %d", code)
+	}
+}
@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"sort"
@@ -65,7 +66,7 @@ func InitPage(mint *mint.Mint) gin.HandlerFunc {
 		selectedRange := "1w"
 
 		err := templates.MintActivityLayout(
-			utils.CanUseLiquidityManager(mint.Config.MINT_LIGHTNING_BACKEND),
+			utils.CanUseLiquidityManager(mint.Config().MINT_LIGHTNING_BACKEND),
 			selectedRange,
 		).Render(ctx, c.Writer)
 
@@ -341,7 +342,7 @@ func LnPage(mint *mint.Mint) gin.HandlerFunc {
 		selectedRange := c.DefaultQuery("since", "1w")
 		searchQuery := strings.TrimSpace(c.Query("search"))
 
-		err := templates.LightningActivityLayout(mint.Config, selectedRange, searchQuery).Render(ctx, c.Writer)
+		err := templates.LightningActivityLayout(mint.Config(), selectedRange, searchQuery).Render(ctx, c.Writer)
 
 		if err != nil {
 			_ = c.Error(err)
@@ -358,83 +359,92 @@ func LightningTable(adminHandler *adminHandler) gin.HandlerFunc {
 		timeRange := c.Query("since")
 		startTime, _ := parseTimeRange(timeRange)
 
-		mintRequests := make([]cashu.MintRequestDB, 0)
-		meltRequests := make([]cashu.MeltRequestDB, 0)
-		filtered := make([]templates.LightningInvoiceVisual, 0)
+		filtered, err := lightningActivity(ctx, adminHandler.mint.MintDB, searchQuery, startTime)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
 
-		if len([]rune(searchQuery)) < minLightningSearchLength {
-			errGroup := errgroup.Group{}
-			errGroup.Go(func() error {
-				requests, err := adminHandler.mint.MintDB.GetMintRequestsByTime(ctx, startTime)
-				if err != nil {
-					return err
-				}
-				mintRequests = requests
-				return nil
-			})
-			errGroup.Go(func() error {
-				requests, err := adminHandler.mint.MintDB.GetMeltRequestsByTime(ctx, startTime)
-				if err != nil {
-					return err
-				}
-				meltRequests = requests
-				return nil
-			})
-			err := errGroup.Wait()
-			if err != nil {
-				_ = c.Error(err)
-				return
-			}
+		err = templates.LightningActivityTable(filtered).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+}
 
-			filtered = make([]templates.LightningInvoiceVisual, 0, len(mintRequests)+len(meltRequests))
-			for _, mintRequest := range mintRequests {
-				filtered = append(filtered, templates.LightningInvoiceVisual{
-					Id:      mintRequest.Quote,
-					Type:    "mint",
-					Invoice: mintRequest.Request,
-					Status:  string(mintRequest.State),
-					Unit:    mintRequest.Unit,
-					Time:    mintRequest.SeenAt,
-				})
-			}
-			for _, meltRequest := range meltRequests {
-				filtered = append(filtered, templates.LightningInvoiceVisual{
-					Id:      meltRequest.Quote,
-					Type:    "melt",
-					Invoice: meltRequest.Request,
-					Status:  string(meltRequest.State),
-					Unit:    meltRequest.Unit,
-					Time:    meltRequest.SeenAt,
-				})
-			}
-		} else {
-			searchRows, err := adminHandler.mint.MintDB.SearchLightningRequests(ctx, searchQuery, startTime, lightningSearchLimit)
+// lightningActivity returns the mint and melt quotes seen since startTime,
+// newest first. A searchQuery shorter than minLightningSearchLength is ignored.
+func lightningActivity(ctx context.Context, db database.MintDB, searchQuery string, startTime time.Time) ([]templates.LightningInvoiceVisual, error) {
+	mintRequests := make([]cashu.MintRequestDB, 0)
+	meltRequests := make([]cashu.MeltRequestDB, 0)
+	filtered := make([]templates.LightningInvoiceVisual, 0)
+
+	if len([]rune(searchQuery)) < minLightningSearchLength {
+		errGroup := errgroup.Group{}
+		errGroup.Go(func() error {
+			requests, err := db.GetMintRequestsByTime(ctx, startTime)
 			if err != nil {
-				_ = c.Error(err)
-				return
+				return err
 			}
-
-			filtered = make([]templates.LightningInvoiceVisual, 0, len(searchRows))
-			for _, row := range searchRows {
-				filtered = append(filtered, templates.LightningInvoiceVisual{
-					Id:      row.ID,
-					Type:    row.Type,
-					Invoice: row.Request,
-					Status:  row.State,
-					Unit:    row.Unit,
-					Time:    row.SeenAt,
-				})
+			mintRequests = requests
+			return nil
+		})
+		errGroup.Go(func() error {
+			requests, err := db.GetMeltRequestsByTime(ctx, startTime)
+			if err != nil {
+				return err
 			}
+			meltRequests = requests
+			return nil
+		})
+		err := errGroup.Wait()
+		if err != nil {
+			return nil, err
 		}
 
-		sort.Slice(filtered, func(i, j int) bool { return filtered[i].Time > filtered[j].Time })
-
-		err := templates.LightningActivityTable(filtered).Render(ctx, c.Writer)
+		filtered = make([]templates.LightningInvoiceVisual, 0, len(mintRequests)+len(meltRequests))
+		for _, mintRequest := range mintRequests {
+			filtered = append(filtered, templates.LightningInvoiceVisual{
+				Id:      mintRequest.Quote,
+				Type:    "mint",
+				Invoice: mintRequest.Request,
+				Status:  string(mintRequest.State),
+				Unit:    mintRequest.Unit,
+				Time:    mintRequest.SeenAt,
+			})
+		}
+		for _, meltRequest := range meltRequests {
+			filtered = append(filtered, templates.LightningInvoiceVisual{
+				Id:      meltRequest.Quote,
+				Type:    "melt",
+				Invoice: meltRequest.Request,
+				Status:  string(meltRequest.State),
+				Unit:    meltRequest.Unit,
+				Time:    meltRequest.SeenAt,
+			})
+		}
+	} else {
+		searchRows, err := db.SearchLightningRequests(ctx, searchQuery, startTime, lightningSearchLimit)
 		if err != nil {
-			_ = c.Error(err)
-			return
+			return nil, err
+		}
+
+		filtered = make([]templates.LightningInvoiceVisual, 0, len(searchRows))
+		for _, row := range searchRows {
+			filtered = append(filtered, templates.LightningInvoiceVisual{
+				Id:      row.ID,
+				Type:    row.Type,
+				Invoice: row.Request,
+				Status:  row.State,
+				Unit:    row.Unit,
+				Time:    row.SeenAt,
+			})
 		}
 	}
+
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Time > filtered[j].Time })
+	return filtered, nil
 }
 
 // calculateLnChartSummary calculates total mint and melt amounts from time series data
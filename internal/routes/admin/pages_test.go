@@ -18,14 +18,14 @@ import (
 func adminTestMint(db *mockdb.MockDB) *mint.Mint {
 	var m mint.Mint
 	m.MintDB = db
-	m.LightningBackend = lightning.FakeWallet{ //nolint:exhaustruct
+	m.SetLightningBackend(lightning.FakeWallet{ //nolint:exhaustruct
 		UnpurposeErrors: nil,
 		Network:         chaincfg.RegressionNetParams,
 		InvoiceFee:      0,
-	}
-	m.Config = utils.Config{ //nolint:exhaustruct
+	})
+	m.SetConfig(utils.Config{ //nolint:exhaustruct
 		MINT_LIGHTNING_BACKEND: utils.FAKE_WALLET,
-	}
+	})
 	return &m
 }
 
@@ -149,3 +149,58 @@ func TestChartHandlersFailCleanlyOnMalformedStatsData(t *testing.T) {
 		t.Fatalf("expected chart content on malformed stats failure, got %s", recorder.Body.String())
 	}
 }
+
+func TestBuildReserveTimeSeriesKeepsLastSnapshotOfBucket(t *testing.T) {
+	balance, laterBalance := uint64(50), uint64(80)
+	rows := []database.ReserveSnapshot{
+		{LightningBalance: &balance, CreatedAt: 3600, Outstanding: 100},
+		{LightningBalance: &laterBalance, CreatedAt: 3650, Outstanding: 100},
+		{LightningBalance: nil, CreatedAt: 7200, Outstanding: 40},
+	}
+	data := buildReserveTimeSeries(rows, 60)
+	if len(data) != 2 {
+		t.Fatalf("expected two buckets, got %#v", data)
+	}
+	if data[0].Timestamp != 3600 || *data[0].Balance != 80 || data[0].Percent == nil || *data[0].Percent != 80 {
+		t.Errorf("unexpected first bucket %#v", data[0])
+	}
+	if data[1].Timestamp != 7200 || data[1].Balance != nil || data[1].Percent != nil || data[1].Outstanding != 40 {
+		t.Errorf("unexpected second bucket %#v", data[1])
+	}
+}
+
+func TestBuildReservesAddsUpUnitsAndInflatedKeysets(t *testing.T) {
+	keysets := []mint.KeysetOutstanding{
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "a", Issued: 100, Redeemed: 40}},
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "b", Issued: 10, Redeemed: 30}},
+		{Unit: "usd", KeysetBalance: database.KeysetBalance{KeysetId: "c", Issued: 5, Redeemed: 1}},
+	}
+	reserves := buildReserves(nil, keysets, nil, 100)
+	if reserves.Percent != nil || reserves.CheckedAt != 0 {
+		t.Errorf("expected no reserve check, got %#v", reserves)
+	}
+	if len(reserves.Units) != 2 || reserves.Units[0].Outstanding != 40 || reserves.Units[1].Outstanding != 4 {
+		t.Errorf("unexpected units %#v", reserves.Units)
+	}
+	if len(reserves.InflatedKeysets) != 1 || reserves.InflatedKeysets[0] != "b" {
+		t.Errorf("expected keyset b to be inflated, got %v", reserves.InflatedKeysets)
+	}
+}
+
+func TestBuildReservesShowsUntrackedKeysetsAsUnknown(t *testing.T) {
+	keysets := []mint.KeysetOutstanding{
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "a", Issued: 100, Redeemed: 40}},
+		{Unit: "sat", KeysetBalance: database.KeysetBalance{KeysetId: "old", Issued: 0, Redeemed: 30}, Untracked: true},
+		{Unit: "usd", KeysetBalance: database.KeysetBalance{KeysetId: "c", Issued: 5, Redeemed: 1}},
+	}
+	reserves := buildReserves(nil, keysets, nil, 100)
+	if len(reserves.InflatedKeysets) != 0 {
+		t.Errorf("expected the untracked keyset not to be inflated, got %v", reserves.InflatedKeysets)
+	}
+	if len(reserves.UntrackedKeysets) != 1 || reserves.UntrackedKeysets[0] != "old" {
+		t.Errorf("expected keyset old to be untracked, got %v", reserves.UntrackedKeysets)
+	}
+	if !reserves.Units[0].Untracked || reserves.Units[1].Untracked {
+		t.Errorf("expected only the sat outstanding to be unknown, got %#v", reserves.Units)
+	}
+}
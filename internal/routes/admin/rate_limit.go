@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/routes/middleware"
+)
+
+// RateLimitByIP stops clients that made more requests than limiter allows.
+func RateLimitByIP(limiter *middleware.IPRateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter.Allow(c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		slog.Warn("rate limited admin request", slog.String("path", c.Request.URL.Path), slog.String("ip", c.ClientIP()))
+		c.Abort()
+		if renderErr := RenderError(c, "Too many attempts, try again in a minute"); renderErr != nil {
+			slog.Warn("failed to render error", slog.Any("error", renderErr))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/database"
+	m "github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+// ReservesChartCard compares the lightning balance with the outstanding ecash
+// and charts the reserve checks of the selected time range.
+func ReservesChartCard(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		startTime, bucketMinutes := parseTimeRange(c.Query("since"))
+		snapshots, err := mint.MintDB.GetReserveSnapshotsBySince(ctx, startTime.Unix())
+		if err != nil {
+			slog.Error(
+				"mint.MintDB.GetReserveSnapshotsBySince()",
+				slog.String(utils.LogExtraInfo, err.Error()))
+			snapshots = []database.ReserveSnapshot{}
+		}
+		latest, err := mint.MintDB.GetLatestReserveSnapshot(ctx)
+		if err != nil {
+			slog.Error(
+				"mint.MintDB.GetLatestReserveSnapshot()",
+				slog.String(utils.LogExtraInfo, err.Error()))
+			latest = nil
+		}
+
+		keysets := []m.KeysetOutstanding{}
+		if !mint.SignerLocked() {
+			keysets, err = mint.OutstandingByKeyset(ctx)
+			if err != nil {
+				slog.Error(
+					"mint.OutstandingByKeyset()",
+					slog.String(utils.LogExtraInfo, err.Error()))
+				keysets = []m.KeysetOutstanding{}
+			}
+		}
+
+		reserves := buildReserves(latest, keysets, buildReserveTimeSeries(snapshots, bucketMinutes), m.ReserveAlertPercent())
+		reserves.FakeWallet = mint.Config().MINT_LIGHTNING_BACKEND == utils.FAKE_WALLET
+
+		err = templates.ReservesCard(reserves).Render(ctx, c.Writer)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+}
+
+func buildReserves(latest *database.ReserveSnapshot, keysets []m.KeysetOutstanding, data []templates.ReserveTimeSeriesPoint, alertPercent float64) templates.Reserves {
+	reserves := templates.Reserves{
+		Balance:          nil,
+		Percent:          nil,
+		InflatedKeysets:  []string{},
+		UntrackedKeysets: []string{},
+		Units:            []templates.UnitReserve{},
+		Data:             data,
+		AlertPercent:     alertPercent,
+		CheckedAt:        0,
+		Outstanding:      0,
+		FakeWallet:       false,
+	}
+	if latest != nil {
+		reserves.Balance = latest.LightningBalance
+		reserves.CheckedAt = latest.CreatedAt
+		reserves.Outstanding = latest.Outstanding
+		if percent, ok := latest.ReservePercent(); ok {
+			reserves.Percent = &percent
+		}
+	}
+
+	unitIndex := make(map[string]int)
+	for _, keyset := range keysets {
+		if keyset.Inflated() {
+			reserves.InflatedKeysets = append(reserves.InflatedKeysets, keyset.KeysetId)
+		}
+		if keyset.Untracked {
+			reserves.UntrackedKeysets = append(reserves.UntrackedKeysets, keyset.KeysetId)
+		}
+		i, ok := unitIndex[keyset.Unit]
+		if !ok {
+			i = len(reserves.Units)
+			unitIndex[keyset.Unit] = i
+			reserves.Units = append(reserves.Units, templates.UnitReserve{Unit: keyset.Unit, Issued: 0, Redeemed: 0, Outstanding: 0, Untracked: false})
+		}
+		reserves.Units[i].Untracked = reserves.Units[i].Untracked || keyset.Untracked
+		reserves.Units[i].Issued += keyset.Issued
+		reserves.Units[i].Redeemed += keyset.Redeemed
+		reserves.Units[i].Outstanding += keyset.Outstanding()
+	}
+	return reserves
+}
+
+// buildReserveTimeSeries keeps the last snapshot of every bucket, the rows are
+// sorted by their creation time.
+func buildReserveTimeSeries(rows []database.ReserveSnapshot, bucketMinutes int) []templates.ReserveTimeSeriesPoint {
+	points := make([]templates.ReserveTimeSeriesPoint, 0)
+	for _, row := range rows {
+		point := templates.ReserveTimeSeriesPoint{
+			Balance:     row.LightningBalance,
+			Percent:     nil,
+			Timestamp:   statsBucketTimestamp(row.CreatedAt, bucketMinutes),
+			Outstanding: row.Outstanding,
+		}
+		if percent, ok := row.ReservePercent(); ok {
+			point.Percent = &percent
+		}
+		if len(points) > 0 && points[len(points)-1].Timestamp == point.Timestamp {
+			points[len(points)-1] = point
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
@@ -22,7 +22,8 @@ const COLORS = {
  const chartInstances = {
   proofs: null,
   blindSigs: null,
-  ln: null
+  ln: null,
+  reserves: null
 };
 
 // Helper to read chart data from a canvas data attribute first, then fallback
@@ -489,6 +490,201 @@ function createLnChartConfig(data) {
   chartInstances.ln = new Chart(canvas, config);
 }
 
+/**
+ * Create chart configuration for the reserves chart
+ * @param {Array} data - Array of {timestamp, balance, outstanding, percent} objects
+ * @param {number} alertPercent - Reserve ratio under which the mint raises an alert
+ */
+function createReservesChartConfig(data, alertPercent) {
+  const points = data.map(point => ({
+    x: new Date(point.timestamp * 1000), // Convert Unix timestamp to Date
+    balance: point.balance,
+    outstanding: point.outstanding,
+    percent: point.percent
+  }));
+
+  const datasets = [
+    {
+      label: 'Node Balance',
+      data: points.map(d => ({ x: d.x, y: d.balance })),
+      borderColor: COLORS.green,
+      backgroundColor: COLORS.greenLight,
+      borderWidth: 2,
+      fill: false,
+      tension: 0.3,
+      pointRadius: 2,
+      pointHoverRadius: 6,
+      spanGaps: false,
+      yAxisID: 'y'
+    },
+    {
+      label: 'Outstanding Ecash',
+      data: points.map(d => ({ x: d.x, y: d.outstanding })),
+      borderColor: COLORS.purple,
+      backgroundColor: COLORS.purpleLight,
+      borderWidth: 2,
+      fill: true,
+      tension: 0.3,
+      pointRadius: 2,
+      pointHoverRadius: 6,
+      yAxisID: 'y'
+    },
+    {
+      label: 'Reserve Ratio',
+      data: points.map(d => ({ x: d.x, y: d.percent })),
+      borderColor: COLORS.cyan,
+      backgroundColor: COLORS.cyanLight,
+      borderWidth: 2,
+      fill: false,
+      tension: 0.3,
+      pointRadius: 2,
+      pointHoverRadius: 6,
+      spanGaps: false,
+      yAxisID: 'y1'
+    }
+  ];
+  if (alertPercent > 0 && points.length > 0) {
+    datasets.push({
+      label: 'Alert Threshold',
+      data: [
+        { x: points[0].x, y: alertPercent },
+        { x: points[points.length - 1].x, y: alertPercent }
+      ],
+      borderColor: COLORS.red,
+      borderWidth: 1,
+      borderDash: [6, 4],
+      fill: false,
+      pointRadius: 0,
+      yAxisID: 'y1'
+    });
+  }
+
+  const axisFont = { family: "'Inter', sans-serif", size: 11 };
+  const titleFont = { family: "'Inter', sans-serif", size: 12, weight: '500' };
+
+  return {
+    type: 'line',
+    data: { datasets },
+    options: {
+      responsive: true,
+      maintainAspectRatio: false,
+      interaction: {
+        mode: 'index',
+        intersect: false
+      },
+      plugins: {
+        legend: {
+          display: true,
+          position: 'top',
+          labels: {
+            color: COLORS.textPrimary,
+            usePointStyle: true,
+            padding: 20,
+            font: { family: "'Inter', sans-serif", size: 12 }
+          }
+        },
+        tooltip: {
+          backgroundColor: '#161b22',
+          titleColor: COLORS.textPrimary,
+          bodyColor: COLORS.textColor,
+          borderColor: COLORS.gridColor,
+          borderWidth: 1,
+          padding: 12,
+          displayColors: true,
+          callbacks: {
+            title: function(tooltipItems) {
+              const date = tooltipItems[0].parsed.x;
+              return new Date(date).toLocaleString();
+            },
+            label: function(context) {
+              let label = context.dataset.label || '';
+              if (label) {
+                label += ': ';
+              }
+              if (context.parsed.y !== null) {
+                if (context.dataset.yAxisID === 'y1') {
+                  label += context.parsed.y.toFixed(1) + '%';
+                } else {
+                  label += context.parsed.y.toLocaleString() + ' sats';
+                }
+              }
+              return label;
+            }
+          }
+        }
+      },
+      scales: {
+        x: {
+          type: 'time',
+          time: {
+            displayFormats: {
+              hour: 'MMM d, HH:mm',
+              day: 'MMM d',
+              week: 'MMM d',
+              month: 'MMM yyyy'
+            },
+            tooltipFormat: 'PPpp'
+          },
+          grid: { color: COLORS.gridColor, drawBorder: false },
+          ticks: { color: COLORS.textColor, font: axisFont, maxRotation: 0, autoSkip: true, maxTicksLimit: 8 }
+        },
+        y: {
+          type: 'linear',
+          display: true,
+          position: 'left',
+          title: { display: true, text: 'Sats', color: COLORS.textColor, font: titleFont },
+          grid: { color: COLORS.gridColor, drawBorder: false },
+          ticks: {
+            color: COLORS.textColor,
+            font: axisFont,
+            callback: function(value) {
+              return value.toLocaleString();
+            }
+          },
+          beginAtZero: true
+        },
+        y1: {
+          type: 'linear',
+          display: true,
+          position: 'right',
+          title: { display: true, text: 'Reserve Ratio (%)', color: COLORS.cyan, font: titleFont },
+          grid: { drawOnChartArea: false },
+          ticks: {
+            color: COLORS.cyan,
+            font: axisFont,
+            callback: function(value) {
+              return value + '%';
+            }
+          },
+          beginAtZero: true
+        }
+      }
+    }
+  };
+}
+
+/**
+ * Initialize or reinitialize the reserves chart from the current DOM
+ */
+function initializeReservesChartFromDOM() {
+  const { canvas, data } = getChartContext({
+    canvasId: 'reservesChart',
+    dataElementId: null
+  });
+
+  if (!canvas || !data) {
+    return;
+  }
+
+  if (chartInstances.reserves) {
+    chartInstances.reserves.destroy();
+    chartInstances.reserves = null;
+  }
+
+  const alertPercent = parseFloat(canvas.getAttribute('data-alert-percent')) || 0;
+  chartInstances.reserves = new Chart(canvas, createReservesChartConfig(data, alertPercent));
+}
+
 /**
  * Initialize or reinitialize the proofs chart from the current DOM
  */
@@ -534,6 +730,13 @@ function setupHtmxListener() {
       setTimeout(initializeBlindSigsChartFromDOM, 50);
     }
     
+    // Reserves chart updates
+    if (targetId === 'reserves-chart-wrapper' ||
+        targetId === 'reserves-chart-placeholder' ||
+        targetId === 'reserves-chart-card') {
+      setTimeout(initializeReservesChartFromDOM, 50);
+    }
+    
     // LN chart updates
     if (targetId === 'ln-chart-wrapper' || 
         targetId === 'ln-chart-placeholder' ||
@@ -557,6 +760,10 @@ function setupHtmxListener() {
     if (targetId === 'ln-chart-placeholder') {
       setTimeout(initializeLnChartFromDOM, 50);
     }
+
+    if (targetId === 'reserves-chart-placeholder') {
+      setTimeout(initializeReservesChartFromDOM, 50);
+    }
   });
 }
 
@@ -14,30 +14,30 @@ import (
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/gin-gonic/gin"
 	"github.com/lescuer97/nutmix/api/cashu"
-	"github.com/lescuer97/nutmix/internal/lightning"
+	"github.com/lescuer97/nutmix/internal/audit"
 	m "github.com/lescuer97/nutmix/internal/mint"
 	"github.com/lescuer97/nutmix/internal/routes/admin/templates"
 	"github.com/lescuer97/nutmix/internal/utils"
-	"github.com/lightningnetwork/lnd/zpay32"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
 var (
-	ErrInvalidOICDURL         = errors.New("invalid OICD discovery URL")
-	ErrInvalidNostrKey        = errors.New("nostr npub is not valid")
-	ErrInvalidStrikeConfig    = errors.New("invalid strike config")
-	ErrInvalidStrikeCheck     = errors.New("could not verify strike configuration")
-	ErrCouldNotParseLogin     = errors.New("could not parse login")
-	ErrInvalidNostrSignature  = errors.New("invalid nostr signature")
-	ErrFailedLightningPayment = errors.New("failed lightning payment")
+	ErrInvalidOICDURL          = errors.New("invalid OICD discovery URL")
+	ErrInvalidLightningBackend = errors.New("invalid lightning backend selection")
+	ErrInvalidNostrKey         = errors.New("nostr npub is not valid")
+	ErrInvalidStrikeConfig     = errors.New("invalid strike config")
+	ErrInvalidStrikeCheck      = errors.New("could not verify strike configuration")
+	ErrCouldNotParseLogin      = errors.New("could not parse login")
+	ErrInvalidNostrSignature   = errors.New("invalid nostr signature")
+	ErrFailedLightningPayment  = errors.New("failed lightning payment")
 )
 
 func MintSettingsPage(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
-		err := templates.MintSettings(mint.Config, nostrNotificationConfigValue(mint.NostrNotificationConfig)).Render(ctx, c.Writer)
+		err := templates.MintSettings(mint.Config(), nostrNotificationConfigValue(mint.NostrNotificationConfig)).Render(ctx, c.Writer)
 		if err != nil {
 			_ = c.Error(err)
 			c.Status(400)
@@ -172,7 +172,9 @@ func validateURL(urlStr string) error {
 	return nil
 }
 
-func changeAuthSettings(mint *m.Mint, c *gin.Context) error {
+// changeAuthSettings reads the auth form into config. The oidc provider is
+// set up by mint.UpdateConfig.
+func changeAuthSettings(config *utils.Config, c *gin.Context) error {
 	activateAuthStr := c.Request.PostFormValue("MINT_REQUIRE_AUTH")
 	activateAuth := false
 	if activateAuthStr == "on" {
@@ -198,27 +200,17 @@ func changeAuthSettings(mint *m.Mint, c *gin.Context) error {
 		return fmt.Errorf("strconv.ParseUint(rateLimitPerMinuteStr, 10, 64). %w", err)
 	}
 
-	if activateAuth {
-		if oicdDiscoveryUrl == "" {
-			return ErrInvalidOICDURL
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-		err := mint.SetupOidcService(ctx, oicdDiscoveryUrl)
-		if err != nil {
-			return fmt.Errorf("oidc.NewProvider(ctx, config.MINT_AUTH_OICD_URL): %w %w", err, ErrInvalidOICDURL)
-		}
-	} else {
-		mint.OICDClient = nil
+	if activateAuth && oicdDiscoveryUrl == "" {
+		return ErrInvalidOICDURL
 	}
 
-	mint.Config.MINT_REQUIRE_AUTH = activateAuth
-	mint.Config.MINT_AUTH_OICD_URL = oicdDiscoveryUrl
-	mint.Config.MINT_AUTH_OICD_CLIENT_ID = oicdClientId
-	mint.Config.MINT_AUTH_RATE_LIMIT_PER_MINUTE = int(rateLimitPerMinute)
-	mint.Config.MINT_AUTH_MAX_BLIND_TOKENS = maxBlindToken
-	mint.Config.MINT_AUTH_CLEAR_AUTH_URLS = authClearArray
-	mint.Config.MINT_AUTH_BLIND_AUTH_URLS = authBlindArray
+	config.MINT_REQUIRE_AUTH = activateAuth
+	config.MINT_AUTH_OICD_URL = oicdDiscoveryUrl
+	config.MINT_AUTH_OICD_CLIENT_ID = oicdClientId
+	config.MINT_AUTH_RATE_LIMIT_PER_MINUTE = int(rateLimitPerMinute)
+	config.MINT_AUTH_MAX_BLIND_TOKENS = maxBlindToken
+	config.MINT_AUTH_CLEAR_AUTH_URLS = authClearArray
+	config.MINT_AUTH_BLIND_AUTH_URLS = authBlindArray
 
 	return nil
 }
@@ -260,26 +252,6 @@ func MintSettingsGeneral(mint *m.Mint) gin.HandlerFunc {
 			}
 		}
 
-		// Set values after validation
-		if iconUrl == "" {
-			mint.Config.IconUrl = nil
-		} else {
-			mint.Config.IconUrl = &iconUrl
-		}
-
-		if tosUrl == "" {
-			mint.Config.TosUrl = nil
-		} else {
-			mint.Config.TosUrl = &tosUrl
-		}
-
-		// Now process all other form fields
-		mint.Config.NAME = c.Request.PostFormValue("NAME")
-		mint.Config.DESCRIPTION = c.Request.PostFormValue("DESCRIPTION")
-		mint.Config.DESCRIPTION_LONG = c.Request.PostFormValue("DESCRIPTION_LONG")
-		mint.Config.EMAIL = c.Request.PostFormValue("EMAIL")
-		mint.Config.MOTD = c.Request.PostFormValue("MOTD")
-
 		nostrKey := c.Request.PostFormValue("NOSTR")
 
 		if len(nostrKey) > 0 {
@@ -296,22 +268,44 @@ func MintSettingsGeneral(mint *m.Mint) gin.HandlerFunc {
 				_ = c.Error(ErrInvalidNostrKey)
 				return
 			}
-
-			mint.Config.NOSTR = nostrKey
-		} else {
-			mint.Config.NOSTR = ""
 		}
 
-		err := persistConfigTx(c.Request.Context(), mint, mint.Config)
+		revision, err := mint.ChangeConfig(c.Request.Context(), auditActor(c), func(config *utils.Config) error {
+			// Set values after validation
+			if iconUrl == "" {
+				config.IconUrl = nil
+			} else {
+				config.IconUrl = &iconUrl
+			}
+
+			if tosUrl == "" {
+				config.TosUrl = nil
+			} else {
+				config.TosUrl = &tosUrl
+			}
+
+			// Now process all other form fields
+			config.NAME = c.Request.PostFormValue("NAME")
+			config.DESCRIPTION = c.Request.PostFormValue("DESCRIPTION")
+			config.DESCRIPTION_LONG = c.Request.PostFormValue("DESCRIPTION_LONG")
+			config.EMAIL = c.Request.PostFormValue("EMAIL")
+			config.MOTD = c.Request.PostFormValue("MOTD")
+			config.NOSTR = nostrKey
+			return nil
+		})
 		if err != nil {
 			slog.Warn(
-				"persistConfigTx(c.Request.Context(), mint, mint.Config) - Mocking success despite error",
+				"mint.ChangeConfig(c.Request.Context(), auditActor(c), change)",
 				slog.String(utils.LogExtraInfo, err.Error()))
+			if renderErr := RenderError(c, configErrorMessage(err)); renderErr != nil {
+				slog.Warn("failed to render error", slog.Any("error", renderErr))
+			}
 			return
 		}
+		recordConfigRevision(c, mint.MintDB, audit.ActionConfigGeneral, revision)
 
 		// render the settings page
-		if err := templates.General(mint.Config).Render(c.Request.Context(), c.Writer); err != nil {
+		if err := templates.General(revision.Config).Render(c.Request.Context(), c.Writer); err != nil {
 			slog.Warn("failed to render settings", slog.Any("error", err))
 			return
 		}
@@ -327,12 +321,7 @@ func MintSettingsGeneral(mint *m.Mint) gin.HandlerFunc {
 
 func MintSettingsLightning(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		pegoutOnly := c.Request.PostFormValue("PEG_OUT_ONLY")
-		if pegoutOnly == "on" {
-			mint.Config.PEG_OUT_ONLY = true
-		} else {
-			mint.Config.PEG_OUT_ONLY = false
-		}
+		pegoutOnly := c.Request.PostFormValue("PEG_OUT_ONLY") == "on"
 
 		// Check pegin limit.
 		pegInLitmit, err := checkLimitSat(c.Request.PostFormValue("PEG_IN_LIMIT_SATS"))
@@ -345,7 +334,6 @@ func MintSettingsLightning(mint *m.Mint) gin.HandlerFunc {
 			}
 			return
 		}
-		mint.Config.PEG_IN_LIMIT_SATS = pegInLitmit
 
 		// Check pegout limit.
 		pegOutLitmit, err := checkLimitSat(c.Request.PostFormValue("PEG_OUT_LIMIT_SATS"))
@@ -358,17 +346,26 @@ func MintSettingsLightning(mint *m.Mint) gin.HandlerFunc {
 			}
 			return
 		}
-		mint.Config.PEG_OUT_LIMIT_SATS = pegOutLitmit
 
-		err = persistConfigTx(c.Request.Context(), mint, mint.Config)
+		revision, err := mint.ChangeConfig(c.Request.Context(), auditActor(c), func(config *utils.Config) error {
+			config.PEG_OUT_ONLY = pegoutOnly
+			config.PEG_IN_LIMIT_SATS = pegInLitmit
+			config.PEG_OUT_LIMIT_SATS = pegOutLitmit
+			return nil
+		})
 		if err != nil {
 			slog.Warn(
-				"persistConfigTx(c.Request.Context(), mint, mint.Config) - Mocking success despite error",
+				"mint.ChangeConfig(c.Request.Context(), auditActor(c), change)",
 				slog.String(utils.LogExtraInfo, err.Error()))
+			if renderErr := RenderError(c, configErrorMessage(err)); renderErr != nil {
+				slog.Warn("failed to render error", slog.Any("error", renderErr))
+			}
+			return
 		}
+		recordConfigRevision(c, mint.MintDB, audit.ActionConfigLightning, revision)
 
 		// render the settings page
-		if err := templates.Lightning(mint.Config).Render(c.Request.Context(), c.Writer); err != nil {
+		if err := templates.Lightning(revision.Config).Render(c.Request.Context(), c.Writer); err != nil {
 			slog.Warn("failed to render settings", slog.Any("error", err))
 			return
 		}
@@ -384,27 +381,27 @@ func MintSettingsLightning(mint *m.Mint) gin.HandlerFunc {
 
 func MintSettingsAuth(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := changeAuthSettings(mint, c)
+		revision, err := mint.ChangeConfig(c.Request.Context(), auditActor(c), func(config *utils.Config) error {
+			err := changeAuthSettings(config, c)
+			if err != nil {
+				return fmt.Errorf("changeAuthSettings(config, c). %w", err)
+			}
+			return nil
+		})
 		if err != nil {
-			_ = c.Error(fmt.Errorf("changeAuthSettings(mint, c). %w", err))
 			slog.Warn(
-				`fmt.Errorf("changeAuthSettings(mint, c). %w", err)`,
+				"mint.ChangeConfig(c.Request.Context(), auditActor(c), change)",
 				slog.String(utils.LogExtraInfo, err.Error()))
+			if errors.Is(err, m.ErrOidcDiscovery) {
+				err = fmt.Errorf("%w %w", err, ErrInvalidOICDURL)
+			}
+			_ = c.Error(fmt.Errorf("mint.ChangeConfig(c.Request.Context(), auditActor(c), change). %w", err))
 			return
 		}
-		err = persistConfigTx(c.Request.Context(), mint, mint.Config)
-
-		if err != nil {
-			slog.Warn(
-				"persistConfigTx(c.Request.Context(), mint, mint.Config) - Mocking success despite error",
-				slog.String(utils.LogExtraInfo, err.Error()))
-
-			_ = c.Error(fmt.Errorf("persistConfigTx(c.Request.Context(), mint, mint.Config). %w", err))
-			// return // Mocking success
-		}
+		recordConfigRevision(c, mint.MintDB, audit.ActionConfigAuth, revision)
 
 		// render the settings page
-		if err := templates.Auth(mint.Config).Render(c.Request.Context(), c.Writer); err != nil {
+		if err := templates.Auth(revision.Config).Render(c.Request.Context(), c.Writer); err != nil {
 			slog.Warn("failed to render settings", slog.Any("error", err))
 			return
 		}
@@ -444,7 +441,7 @@ func MintSettingsNotifications(mint *m.Mint) gin.HandlerFunc {
 		err := nextConfig.SetNostrNotificationConfig(nostrNotificationsEnabled, nil, npubsToPersist)
 		if err != nil {
 			slog.Warn(
-				"mint.Config.SetNostrNotificationConfig(...)",
+				"mint.Config().SetNostrNotificationConfig(...)",
 				slog.String(utils.LogExtraInfo, err.Error()))
 			if renderErr := RenderError(c, "Could not update nostr notification settings"); renderErr != nil {
 				slog.Warn("failed to render error", slog.Any("error", renderErr))
@@ -562,7 +559,7 @@ func MintSettingsNotificationDeleteNpub(mint *m.Mint) gin.HandlerFunc {
 		)
 		if err != nil {
 			slog.Warn(
-				"mint.Config.SetNostrNotificationConfig(...)",
+				"mint.Config().SetNostrNotificationConfig(...)",
 				slog.String(utils.LogExtraInfo, err.Error()))
 			if renderErr := RenderError(c, "Could not update nostr notification settings"); renderErr != nil {
 				slog.Warn("failed to render error", slog.Any("error", renderErr))
@@ -637,34 +634,6 @@ func renderNotificationsForm(c *gin.Context, config utils.NostrNotificationConfi
 	return templates.ObbNotification(templates.ErrorNotif(message)).Render(c.Request.Context(), c.Writer)
 }
 
-func persistConfigTx(ctx context.Context, mint *m.Mint, config utils.Config) (err error) {
-	tx, err := mint.MintDB.GetTx(ctx)
-	if err != nil {
-		return fmt.Errorf("mint.MintDB.GetTx(ctx): %w", err)
-	}
-
-	defer func() {
-		if err == nil {
-			return
-		}
-		if rollbackErr := mint.MintDB.Rollback(ctx, tx); rollbackErr != nil {
-			slog.Warn("mint.MintDB.Rollback(ctx, tx)", slog.String(utils.LogExtraInfo, rollbackErr.Error()))
-		}
-	}()
-
-	err = mint.MintDB.UpdateConfig(tx, config)
-	if err != nil {
-		return fmt.Errorf("mint.MintDB.UpdateConfig(tx, config): %w", err)
-	}
-
-	err = mint.MintDB.Commit(ctx, tx)
-	if err != nil {
-		return fmt.Errorf("mint.MintDB.Commit(ctx, tx): %w", err)
-	}
-
-	return nil
-}
-
 func persistNostrNotificationConfigTx(ctx context.Context, mint *m.Mint, config utils.NostrNotificationConfig) (err error) {
 	tx, err := mint.MintDB.GetTx(ctx)
 	if err != nil {
@@ -695,11 +664,12 @@ func persistNostrNotificationConfigTx(ctx context.Context, mint *m.Mint, config
 
 func LightningNodePage(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		config := mint.Config()
 		ctx := c.Request.Context()
-		err := templates.LightningBackendPage(mint.Config).Render(ctx, c.Writer)
+		err := templates.LightningBackendPage(config).Render(ctx, c.Writer)
 
 		if err != nil {
-			_ = c.Error(fmt.Errorf("templates.LightningBackendPage(mint.Config).Render(ctx, c.Writer). %w", err))
+			_ = c.Error(fmt.Errorf("templates.LightningBackendPage(config).Render(ctx, c.Writer). %w", err))
 			return
 		}
 	}
@@ -721,215 +691,82 @@ func Bolt11Post(mint *m.Mint) gin.HandlerFunc {
 			return
 		}
 
-		var newBackend lightning.LightningBackend
-		var newBackendType utils.LightningBackend
-
-		// Temporary config variables to hold the new settings
-		// Initialize with existing config values
-		var (
-			lndHost     = mint.Config.LND_GRPC_HOST
-			lndTls      = mint.Config.LND_TLS_CERT
-			lndMacaroon = mint.Config.LND_MACAROON
-
-			lnbitsKey      = mint.Config.MINT_LNBITS_KEY
-			lnbitsEndpoint = mint.Config.MINT_LNBITS_ENDPOINT
-
-			strikeKey      = mint.Config.STRIKE_KEY
-			strikeEndpoint = mint.Config.STRIKE_ENDPOINT
-
-			clnHost     = mint.Config.CLN_GRPC_HOST
-			clnCa       = mint.Config.CLN_CA_CERT
-			clnClient   = mint.Config.CLN_CLIENT_CERT
-			clnKey      = mint.Config.CLN_CLIENT_KEY
-			clnMacaroon = mint.Config.CLN_MACAROON
-		)
-
-		switch c.Request.PostFormValue("MINT_LIGHTNING_BACKEND") {
-		case string(utils.FAKE_WALLET):
-			newBackendType = utils.FAKE_WALLET
-			fakeWalletBackend := lightning.FakeWallet{
-				Network:         chainparam,
-				UnpurposeErrors: []lightning.FakeWalletError{},
-				InvoiceFee:      0,
-			}
-			newBackend = fakeWalletBackend
-
-		case string(utils.LNDGRPC):
-			newBackendType = utils.LNDGRPC
-			lndHost = c.Request.PostFormValue("LND_GRPC_HOST")
-			lndTls = c.Request.PostFormValue("LND_TLS_CERT")
-			lndMacaroon = c.Request.PostFormValue("LND_MACAROON")
-
-			lndWallet := lightning.LndGrpcWallet{
-				Network: chainparam,
-			}
-
-			err := lndWallet.SetupGrpc(lndHost, lndMacaroon, lndTls)
-			if err != nil {
-				slog.Warn(
-					"lndWallet.SetupGrpc",
-					slog.String(utils.LogExtraInfo, err.Error()))
-
-				if renderErr := RenderError(c, "Something went wrong setting up LND communications"); renderErr != nil {
-					slog.Warn("failed to render error", slog.Any("error", renderErr))
-				}
-				return
-			}
-			newBackend = lndWallet
-
-		case string(utils.LNBITS): //nolint:staticcheck // LNBITS remains configurable until its planned removal in v0.8.0.
-			newBackendType = utils.LNBITS //nolint:staticcheck // LNBITS remains configurable until its planned removal in v0.8.0.
-			lnbitsKey = c.Request.PostFormValue("MINT_LNBITS_KEY")
-			lnbitsEndpoint = c.Request.PostFormValue("MINT_LNBITS_ENDPOINT")
-
-			slog.Warn("LNBITS backend is deprecated and will be removed in v0.8.0")
-
-			lnbitsWallet := lightning.LnbitsWallet{
-				Network:  chainparam,
-				Key:      lnbitsKey,
-				Endpoint: lnbitsEndpoint,
-			}
-			newBackend = lnbitsWallet
-
-		case string(utils.Strike): //nolint:staticcheck // Strike remains configurable until its planned removal in v0.7.0.
-			newBackendType = utils.Strike //nolint:staticcheck // Strike remains configurable until its planned removal in v0.7.0.
-			strikeKey = c.Request.PostFormValue("STRIKE_KEY")
-			strikeEndpoint = c.Request.PostFormValue("STRIKE_ENDPOINT")
-
-			strikeWallet := lightning.Strike{
-				Network: chainparam,
-			}
-
-			err := strikeWallet.Setup(strikeKey, strikeEndpoint)
-			if err != nil {
-				_ = c.Error(fmt.Errorf("strikeWallet.Setup(strikeKey, strikeEndpoint) %w %w", err, ErrInvalidStrikeConfig))
-				if renderErr := RenderError(c, "Invalid Strike configuration"); renderErr != nil {
-					slog.Warn("failed to render error", slog.Any("error", renderErr))
-				}
-				return
-			}
-			newBackend = strikeWallet
-
-		case string(utils.CLNGRPC):
-			newBackendType = utils.CLNGRPC
-			clnHost = c.Request.PostFormValue("CLN_GRPC_HOST")
-			clnCa = c.Request.PostFormValue("CLN_CA_CERT")
-			clnClient = c.Request.PostFormValue("CLN_CLIENT_CERT")
-			clnKey = c.Request.PostFormValue("CLN_CLIENT_KEY")
-			clnMacaroon = c.Request.PostFormValue("CLN_MACAROON")
-
-			clnWallet := lightning.CLNGRPCWallet{
-				Network: chainparam,
-			}
-
-			err := clnWallet.SetupGrpc(clnHost, clnCa, clnClient, clnKey, clnMacaroon)
-			if err != nil {
-				slog.Warn(
-					"clnWallet.SetupGrpc",
-					slog.String(utils.LogExtraInfo, err.Error()))
-
-				if renderErr := RenderError(c, "Something went wrong setting up CLN communications"); renderErr != nil {
-					slog.Warn("failed to render error", slog.Any("error", renderErr))
-				}
-				return
-			}
-			newBackend = clnWallet
-
-		default:
+		// Only the fields of the selected backend change, the rest keep their
+		// stored values. The new backend is connected and verified before it
+		// replaces the running one.
+		revision, err := mint.ChangeConfig(c.Request.Context(), auditActor(c), func(config *utils.Config) error {
+			config.NETWORK = chainparam.Name
+
+			switch c.Request.PostFormValue("MINT_LIGHTNING_BACKEND") {
+			case string(utils.FAKE_WALLET):
+				config.MINT_LIGHTNING_BACKEND = utils.FAKE_WALLET
+
+			case string(utils.LNDGRPC):
+				config.MINT_LIGHTNING_BACKEND = utils.LNDGRPC
+				config.LND_GRPC_HOST = c.Request.PostFormValue("LND_GRPC_HOST")
+				config.LND_TLS_CERT = c.Request.PostFormValue("LND_TLS_CERT")
+				config.LND_MACAROON = c.Request.PostFormValue("LND_MACAROON")
+
+			case string(utils.LNBITS): //nolint:staticcheck // LNBITS remains configurable until its planned removal in v0.8.0.
+				config.MINT_LIGHTNING_BACKEND = utils.LNBITS //nolint:staticcheck // LNBITS remains configurable until its planned removal in v0.8.0.
+				config.MINT_LNBITS_KEY = c.Request.PostFormValue("MINT_LNBITS_KEY")
+				config.MINT_LNBITS_ENDPOINT = c.Request.PostFormValue("MINT_LNBITS_ENDPOINT")
+
+				slog.Warn("LNBITS backend is deprecated and will be removed in v0.8.0")
+
+			case string(utils.Strike): //nolint:staticcheck // Strike remains configurable until its planned removal in v0.7.0.
+				config.MINT_LIGHTNING_BACKEND = utils.Strike //nolint:staticcheck // Strike remains configurable until its planned removal in v0.7.0.
+				config.STRIKE_KEY = c.Request.PostFormValue("STRIKE_KEY")
+				config.STRIKE_ENDPOINT = c.Request.PostFormValue("STRIKE_ENDPOINT")
+
+			case string(utils.CLNGRPC):
+				config.MINT_LIGHTNING_BACKEND = utils.CLNGRPC
+				config.CLN_GRPC_HOST = c.Request.PostFormValue("CLN_GRPC_HOST")
+				config.CLN_CA_CERT = c.Request.PostFormValue("CLN_CA_CERT")
+				config.CLN_CLIENT_CERT = c.Request.PostFormValue("CLN_CLIENT_CERT")
+				config.CLN_CLIENT_KEY = c.Request.PostFormValue("CLN_CLIENT_KEY")
+				config.CLN_MACAROON = c.Request.PostFormValue("CLN_MACAROON")
+
+			default:
+				return ErrInvalidLightningBackend
+			}
+			return nil
+		})
+		if errors.Is(err, ErrInvalidLightningBackend) {
 			if renderErr := RenderError(c, "Invalid backend selection"); renderErr != nil {
 				slog.Warn("failed to render error", slog.Any("error", renderErr))
 			}
 			return
 		}
-
-		// --- VERIFICATION STEP ---
-
-		// 1. Check connection/balance
-		_, err = newBackend.WalletBalance()
 		if err != nil {
 			slog.Warn(
-				"Could not get lightning balance",
+				"mint.ChangeConfig(c.Request.Context(), auditActor(c), change)",
 				slog.String(utils.LogExtraInfo, err.Error()))
-			if renderErr := RenderError(c, "Could not check established connection with Node (WalletBalance failed)"); renderErr != nil {
-				slog.Warn("failed to render error", slog.Any("error", renderErr))
-			}
-			return
-		}
-
-		// 2. Check invoice generation (100 sats)
-		// We use a dummy quote ID to avoid messing with real DB if possible.
-		testQuote := "verification-test-" + strconv.FormatInt(time.Now().Unix(), 10)
-		//nolint:exhaustruct
-		testDescription := testQuote
-		invoiceResp, err := newBackend.RequestInvoice(
-			cashu.NewAmount(cashu.Sat, 100),
-			&testDescription,
-		)
-		if err != nil {
-			slog.Warn("newBackend.RequestInvoice failed during verification", slog.String("err", err.Error()))
-			if renderErr := RenderError(c, "Could not generate a test invoice with the new backend"); renderErr != nil {
-				slog.Warn("failed to render error", slog.Any("error", renderErr))
-			}
-			return
-		}
-
-		// 3. Decode invoice and verify network
-		decodedInvoice, err := zpay32.Decode(invoiceResp.PaymentRequest, &chainparam)
-		if err != nil {
-			slog.Warn("zpay32.Decode failed during verification", slog.String("err", err.Error()))
-			if renderErr := RenderError(c, "Lightning backend network does not match selected network configuration"); renderErr != nil {
-				slog.Warn("failed to render error", slog.Any("error", renderErr))
-			}
-			return
-		}
-
-		// Verify amount matches (sanity check)
-		if decodedInvoice.MilliSat == nil || int64(decodedInvoice.MilliSat.ToSatoshis()) != 100 {
-			slog.Warn("Decoded invoice amount mismatch")
-		}
-
-		// --- APPLY SETTINGS ---
-
-		// Update Config object
-		mint.Config.NETWORK = chainparam.Name
-		mint.Config.MINT_LIGHTNING_BACKEND = newBackendType
-
-		switch newBackendType {
-		case utils.LNDGRPC:
-			mint.Config.LND_GRPC_HOST = lndHost
-			mint.Config.LND_MACAROON = lndMacaroon
-			mint.Config.LND_TLS_CERT = lndTls
-		case utils.LNBITS: //nolint:staticcheck // LNBITS config is still persisted until its planned removal in v0.8.0.
-			mint.Config.MINT_LNBITS_KEY = lnbitsKey
-			mint.Config.MINT_LNBITS_ENDPOINT = lnbitsEndpoint
-		case utils.Strike: //nolint:staticcheck // Strike config is still persisted until its planned removal in v0.7.0.
-			mint.Config.STRIKE_KEY = strikeKey
-			mint.Config.STRIKE_ENDPOINT = strikeEndpoint
-		case utils.CLNGRPC:
-			mint.Config.CLN_GRPC_HOST = clnHost
-			mint.Config.CLN_MACAROON = clnMacaroon
-			mint.Config.CLN_CA_CERT = clnCa
-			mint.Config.CLN_CLIENT_KEY = clnKey
-			mint.Config.CLN_CLIENT_CERT = clnClient
-		}
-
-		// Switch the live backend
-		mint.LightningBackend = newBackend
-
-		// Save to DB
-		err = persistConfigTx(c.Request.Context(), mint, mint.Config)
-		if err != nil {
-			slog.Warn(
-				"persistConfigTx(c.Request.Context(), mint, mint.Config)",
-				slog.String(utils.LogExtraInfo, err.Error()))
-			if renderErr := RenderError(c, "Settings applied but failed to save to database"); renderErr != nil {
+			if renderErr := RenderError(c, configErrorMessage(err)); renderErr != nil {
 				slog.Warn("failed to render error", slog.Any("error", renderErr))
 			}
 			return
 		}
+		recordConfigRevision(c, mint.MintDB, audit.ActionConfigBackend, revision)
 
 		if err := RenderSuccess(c, "Lightning node settings changed and verified successfully"); err != nil {
 			slog.Warn("failed to render success", slog.Any("error", err))
 		}
 	}
 }
+
+// configErrorMessage explains why mint.UpdateConfig refused a config.
+func configErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, m.ErrLightningBackendUnreachable):
+		return "Could not check established connection with the lightning node"
+	case errors.Is(err, m.ErrLightningBackendNetwork):
+		return "Lightning backend network does not match selected network configuration"
+	case errors.Is(err, m.ErrOidcDiscovery):
+		return "Could not reach the OIDC discovery url"
+	case errors.Is(err, m.ErrInvalidConfig):
+		return "Invalid settings: " + err.Error()
+	default:
+		return "Could not save the settings"
+	}
+}
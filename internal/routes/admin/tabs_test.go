@@ -107,7 +107,7 @@ func TestMintSettingsNotificationsFailsWithNotificationOnInvalidNpub(t *testing.
 	var config utils.Config
 	config.Default()
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 
 	var mockDatabase mockdb.MockDB
 	mockDatabase.Config = config
@@ -148,7 +148,7 @@ func TestMintSettingsNotificationsRendersComponentOnSuccess(t *testing.T) {
 	var config utils.Config
 	config.Default()
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 
 	var mockDatabase mockdb.MockDB
 	mockDatabase.Config = config
@@ -209,7 +209,7 @@ func TestMintSettingsNotificationsTestRequiresEnabledNotifications(t *testing.T)
 	config.Default()
 
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 
 	handler := MintSettingsNotificationsTest(&mintInstance)
 	handler(ctx)
@@ -239,7 +239,7 @@ func TestMintSettingsNotificationsTestWritesSuccessNotification(t *testing.T) {
 	config.Default()
 
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 	var nostrNotificationConfig utils.NostrNotificationConfig
 	nostrNotificationConfig.NOSTR_NOTIFICATIONS = true
 	mintInstance.NostrNotificationConfig = &nostrNotificationConfig
@@ -283,7 +283,7 @@ func TestMintSettingsNotificationDeleteNpub(t *testing.T) {
 	nostrConfig.NOSTR_NOTIFICATION_NSEC = nsec
 
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 	mintInstance.NostrNotificationConfig = &nostrConfig
 
 	var mockDatabase mockdb.MockDB
@@ -349,7 +349,7 @@ func TestMintSettingsNotificationsKeepsNpubsWhenDisabled(t *testing.T) {
 	nostrConfig.NOSTR_NOTIFICATION_NSEC = nsec
 
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 	mintInstance.NostrNotificationConfig = &nostrConfig
 
 	var mockDatabase mockdb.MockDB
@@ -414,7 +414,7 @@ func TestMintSettingsNotificationsDedupeNpubs(t *testing.T) {
 	var config utils.Config
 	config.Default()
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 
 	var mockDatabase mockdb.MockDB
 	mockDatabase.Config = config
@@ -456,7 +456,7 @@ func TestMintSettingsNotificationDeleteNpubNotFoundReturnsError(t *testing.T) {
 	}
 
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 	mintInstance.NostrNotificationConfig = &nostrConfig
 
 	var mockDatabase mockdb.MockDB
@@ -514,7 +514,7 @@ func TestMintSettingsNotificationsDoesNotMutateConfigOnDBFailure(t *testing.T) {
 	config.Default()
 
 	var mintInstance mint.Mint
-	mintInstance.Config = config
+	mintInstance.SetConfig(config)
 
 	var mockDatabase mockdb.MockDB
 	mockDatabase.Config = config
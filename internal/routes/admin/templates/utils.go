@@ -53,3 +53,16 @@ func WrappedPublicKeyToNpub(pubkey cashu.WrappedPublicKey) string {
 
 	return npub
 }
+
+// PubkeyHexToNpub shows a hex pubkey as an npub. Values that are not a pubkey
+// are returned as they are.
+func PubkeyHexToNpub(pubkeyHex string) string {
+	if !nostr.IsValid32ByteHex(pubkeyHex) {
+		return pubkeyHex
+	}
+	npub, err := nip19.EncodePublicKey(pubkeyHex)
+	if err != nil {
+		return pubkeyHex
+	}
+	return npub
+}
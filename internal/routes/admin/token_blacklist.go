@@ -1,10 +1,25 @@
 package admin
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/lescuer97/nutmix/internal/database"
 )
 
+const blacklistQueryTimeout = 5 * time.Second
+
+// SessionBlacklist keeps track of admin sessions invalidated on logout
+type SessionBlacklist interface {
+	AddToken(token string, expiration time.Time)
+	IsTokenBlacklisted(token string) bool
+	CleanupExpiredTokens()
+}
+
 // TokenBlacklist stores invalidated tokens in memory
 type TokenBlacklist struct {
 	tokens map[string]time.Time // token -> expiration time
@@ -53,3 +68,71 @@ func (tb *TokenBlacklist) CleanupExpiredTokens() {
 		}
 	}
 }
+
+// cleanupSessionBlacklist removes the expired tokens of the blacklist on every
+// interval until ctx is done.
+func cleanupSessionBlacklist(ctx context.Context, blacklist SessionBlacklist, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			blacklist.CleanupExpiredTokens()
+		}
+	}
+}
+
+// DBTokenBlacklist stores invalidated tokens in the database so a logout is
+// honoured by every mint replica. Only a hash of the token is stored.
+type DBTokenBlacklist struct {
+	db database.MintDB
+}
+
+// NewDBTokenBlacklist creates a token blacklist shared through the database
+func NewDBTokenBlacklist(db database.MintDB) *DBTokenBlacklist {
+	return &DBTokenBlacklist{db: db}
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// AddToken adds a token to the blacklist with an expiration time
+func (tb *DBTokenBlacklist) AddToken(token string, expiration time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), blacklistQueryTimeout)
+	defer cancel()
+
+	err := tb.db.AddRevokedAdminToken(ctx, hashToken(token), expiration.Unix())
+	if err != nil {
+		slog.Error("tb.db.AddRevokedAdminToken(ctx, hashToken(token), expiration.Unix())", slog.Any("error", err))
+	}
+}
+
+// IsTokenBlacklisted checks if a token is in the blacklist and hasn't expired.
+// If the database can not be reached the token is treated as blacklisted.
+func (tb *DBTokenBlacklist) IsTokenBlacklisted(token string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), blacklistQueryTimeout)
+	defer cancel()
+
+	revoked, err := tb.db.IsAdminTokenRevoked(ctx, hashToken(token), time.Now().Unix())
+	if err != nil {
+		slog.Error("tb.db.IsAdminTokenRevoked(ctx, hashToken(token), time.Now().Unix())", slog.Any("error", err))
+		return true
+	}
+	return revoked
+}
+
+// CleanupExpiredTokens removes expired tokens from the blacklist
+func (tb *DBTokenBlacklist) CleanupExpiredTokens() {
+	ctx, cancel := context.WithTimeout(context.Background(), blacklistQueryTimeout)
+	defer cancel()
+
+	err := tb.db.DeleteExpiredAdminTokens(ctx, time.Now().Unix())
+	if err != nil {
+		slog.Error("tb.db.DeleteExpiredAdminTokens(ctx, time.Now().Unix())", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+)
+
+func TestDBTokenBlacklistIsSharedBetweenInstances(t *testing.T) {
+	db := &mockdb.MockDB{}
+	first := NewDBTokenBlacklist(db)
+	second := NewDBTokenBlacklist(db)
+
+	first.AddToken("session-token", time.Now().Add(time.Hour))
+
+	if !second.IsTokenBlacklisted("session-token") {
+		t.Fatal("expected token revoked on one instance to be blacklisted on the other")
+	}
+	if second.IsTokenBlacklisted("other-token") {
+		t.Fatal("expected unrelated token not to be blacklisted")
+	}
+	if _, stored := db.RevokedAdminTokens["session-token"]; stored {
+		t.Fatal("expected only the token hash to be stored")
+	}
+}
+
+func TestDBTokenBlacklistCleanupExpiredTokens(t *testing.T) {
+	db := &mockdb.MockDB{}
+	blacklist := NewDBTokenBlacklist(db)
+
+	blacklist.AddToken("expired-token", time.Now().Add(-time.Minute))
+	blacklist.AddToken("valid-token", time.Now().Add(time.Hour))
+
+	if blacklist.IsTokenBlacklisted("expired-token") {
+		t.Fatal("expected expired token not to be blacklisted")
+	}
+
+	blacklist.CleanupExpiredTokens()
+
+	if len(db.RevokedAdminTokens) != 1 {
+		t.Fatalf("expected 1 token left after cleanup, got %d", len(db.RevokedAdminTokens))
+	}
+}
+
+func TestCleanupSessionBlacklistPrunesLocalTokens(t *testing.T) {
+	blacklist := NewTokenBlacklist()
+	blacklist.AddToken("expired-token", time.Now().Add(-time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cleanupSessionBlacklist(ctx, blacklist, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		blacklist.mutex.RLock()
+		remaining := len(blacklist.tokens)
+		blacklist.mutex.RUnlock()
+		if remaining == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the expired token to be pruned")
+}
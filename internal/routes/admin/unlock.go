@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	m "github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/secrets"
+)
+
+type apiUnlockRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// unlockSigner unlocks the keystore and records the attempt. A wrong
+// passphrase is recorded too, so guessing shows up in the audit log.
+func unlockSigner(c *gin.Context, mint *m.Mint, passphrase string) error {
+	if !mint.SignerLocked() {
+		return nil
+	}
+	err := mint.UnlockSigner(passphrase)
+	if errors.Is(err, secrets.ErrWrongPassphrase) {
+		recordAuditDetails(c, mint.MintDB, audit.ActionSignerUnlockFailed, map[string]string{"reason": "wrong passphrase"})
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("mint.UnlockSigner(passphrase). %w", err)
+	}
+	pubkey, _ := mint.Signer.GetSignerPubkey()
+	recordAuditDetails(c, mint.MintDB, audit.ActionSignerUnlock, map[string]string{"pubkey": pubkey})
+	slog.Info("The signer keystore was unlocked", slog.String("admin", auditActor(c)))
+	return nil
+}
+
+// UnlockSigner unlocks a mint started with an encrypted keystore from the
+// dashboard.
+func UnlockSigner(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := unlockSigner(c, mint, c.Request.PostFormValue("passphrase"))
+		if errors.Is(err, secrets.ErrWrongPassphrase) {
+			err = RenderError(c, "Wrong passphrase")
+			if err != nil {
+				slog.Error("RenderError", slog.Any("error", err))
+			}
+			return
+		}
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		c.Header("HX-Refresh", "true")
+		err = RenderSuccess(c, "The mint is unlocked")
+		if err != nil {
+			slog.Error("RenderSuccess", slog.Any("error", err))
+		}
+	}
+}
+
+func ApiUnlockSigner(mint *m.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request apiUnlockRequest
+		err := c.ShouldBindJSON(&request)
+		if err != nil || request.Passphrase == "" {
+			abortApi(c, http.StatusBadRequest, "passphrase is needed")
+			return
+		}
+		err = unlockSigner(c, mint, request.Passphrase)
+		if errors.Is(err, secrets.ErrWrongPassphrase) {
+			abortApi(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			apiInternalError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
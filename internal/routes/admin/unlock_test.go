@@ -0,0 +1,74 @@
+//nolint:exhaustruct
+package admin
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/internal/audit"
+	"github.com/lescuer97/nutmix/internal/routes/middleware"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	"github.com/lescuer97/nutmix/internal/signer"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+)
+
+func TestUnlockSigner(t *testing.T) {
+	mintInstance, db := configHistoryTestMint()
+	privateKey, err := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+	if err != nil {
+		t.Fatalf("hex.DecodeString: %v", err)
+	}
+	pubkey, err := localsigner.SignerPubkey(privateKey)
+	if err != nil {
+		t.Fatalf("localsigner.SignerPubkey: %v", err)
+	}
+	keystore, err := secrets.NewKeystore(privateKey, pubkey, "passphrase")
+	if err != nil {
+		t.Fatalf("secrets.NewKeystore: %v", err)
+	}
+	mintInstance.Signer = signer.Instrument(localsigner.NewLockedSigner(db, keystore))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.SignerLockedMiddleware(mintInstance))
+	router.GET("/v1/keys", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/admin/unlock", UnlockSigner(mintInstance))
+
+	unlock := func(passphrase string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/admin/unlock", strings.NewReader(url.Values{"passphrase": {passphrase}}.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	keys := func() int {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/keys", nil))
+		return recorder.Code
+	}
+
+	if keys() != http.StatusServiceUnavailable {
+		t.Error("expected the wallet api to wait for the unlock")
+	}
+	recorder := unlock("wrong")
+	if !strings.Contains(recorder.Body.String(), "Wrong passphrase") || !mintInstance.SignerLocked() {
+		t.Errorf("expected a wrong passphrase to keep the mint locked, got %s", recorder.Body.String())
+	}
+	recorder = unlock("passphrase")
+	if recorder.Header().Get("HX-Refresh") != "true" || mintInstance.SignerLocked() {
+		t.Fatalf("expected the mint to unlock, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if keys() != http.StatusOK {
+		t.Error("expected the wallet api to answer once unlocked")
+	}
+	if len(db.AuditLog) != 2 || db.AuditLog[0].Action != audit.ActionSignerUnlockFailed || db.AuditLog[1].Action != audit.ActionSignerUnlock {
+		t.Errorf("expected the failed and the successful unlock in the audit log, got %+v", db.AuditLog)
+	}
+}
@@ -12,7 +12,7 @@ import (
 
 func AuthActivatedMiddleware(mint *m.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !mint.Config.MINT_REQUIRE_AUTH {
+		if !mint.Config().MINT_REQUIRE_AUTH {
 			slog.Warn(fmt.Errorf("tried using route that does not exist because auth not being active").Error())
 			c.JSON(404, "route does not exists")
 			c.Abort()
@@ -79,7 +79,7 @@ func v1AuthRoutes(r *gin.Engine, mint *m.Mint) {
 			// check all blind messages have the same unit
 		}
 
-		if amountBlindMessages > mint.Config.MINT_AUTH_MAX_BLIND_TOKENS {
+		if amountBlindMessages > mint.Config().MINT_AUTH_MAX_BLIND_TOKENS {
 			slog.Warn("Trying to mint auth tokens over the limit")
 			c.JSON(400, cashu.ErrorCodeToResponse(cashu.MAXIMUM_BAT_MINT_LIMIT_EXCEEDED, nil))
 			return
@@ -127,9 +127,9 @@ func v1AuthRoutes(r *gin.Engine, mint *m.Mint) {
 			return
 		}
 
-		blindedSignatures, recoverySigsDb, err := mint.Signer.SignBlindMessages(mintRequest.Outputs)
+		blindedSignatures, recoverySigsDb, err := mint.Signer.SignBlindMessages(c.Request.Context(), mintRequest.Outputs)
 		if err != nil {
-			slog.Warn("mint.Signer.SignBlindMessages(mintRequest.Outputs)", slog.Any("error", err))
+			slog.Warn("mint.Signer.SignBlindMessages(c.Request.Context(), mintRequest.Outputs)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -17,7 +17,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 		err := c.BindJSON(&mintRequest)
 
 		if err != nil {
-			slog.Info("Incorrect body", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "Incorrect body", slog.Any("error", err))
 			c.JSON(400, "Malformed body request")
 			return
 		}
@@ -27,7 +27,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 
 		response, err := mint.CreateMintQuote(mintQuoteCtx, mintRequest, m.Bolt11)
 		if err != nil {
-			slog.Info("mint.CreateMintQuote(c.Request.Context(), mintRequest, m.Bolt11)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "mint.CreateMintQuote(c.Request.Context(), mintRequest, m.Bolt11)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -40,7 +40,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 		quoteId := c.Param("quote")
 		response, err := mint.RefreshMintQuoteStatus(c.Request.Context(), quoteId, m.Bolt11)
 		if err != nil {
-			slog.Info("mint.RefreshMintQuoteStatus(c.Request.Context(), quoteId, m.Bolt11)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "mint.RefreshMintQuoteStatus(c.Request.Context(), quoteId, m.Bolt11)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -53,7 +53,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 
 		err := c.BindJSON(&mintRequest)
 		if err != nil {
-			slog.Info("Incorrect body", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "Incorrect body", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -64,7 +64,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 
 		response, err := mint.IssueTokens(mintCtx, mintRequest, m.Bolt11)
 		if err != nil {
-			slog.Info("mint.IssueTokens(c.Request.Context(), mintRequest, m.Bolt11)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "mint.IssueTokens(c.Request.Context(), mintRequest, m.Bolt11)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -77,7 +77,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 		err := c.BindJSON(&meltRequest)
 
 		if err != nil {
-			slog.Info("Incorrect body", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "Incorrect body", slog.Any("error", err))
 			c.JSON(400, "Malformed body request")
 			return
 		}
@@ -87,7 +87,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 
 		dbRequest, err := mint.CreateMeltQuote(meltQuoteCtx, meltRequest, m.Bolt11)
 		if err != nil {
-			slog.Warn("mint.CreateMeltQuote", slog.Any("error", err))
+			slog.WarnContext(c.Request.Context(), "mint.CreateMeltQuote", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -100,7 +100,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 
 		quote, err := mint.RefreshMeltQuoteState(c.Request.Context(), quoteId)
 		if err != nil {
-			slog.Warn("mint.RefreshMeltQuoteState(quoteId)", slog.Any("error", err))
+			slog.WarnContext(c.Request.Context(), "mint.RefreshMeltQuoteState(quoteId)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -113,7 +113,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 		var meltRequest cashu.PostMeltBolt11Request
 		err := c.BindJSON(&meltRequest)
 		if err != nil {
-			slog.Info("Incorrect body", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "Incorrect body", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -124,7 +124,7 @@ func registerV1Bolt11Routes(r *gin.Engine, mint *m.Mint) {
 
 		quote, err := mint.ExecuteMelt(meltCtx, meltRequest, m.Bolt11)
 		if err != nil {
-			slog.Warn("mint.ExecuteMelt(ctx, meltRequest)", slog.Any("error", err))
+			slog.WarnContext(c.Request.Context(), "mint.ExecuteMelt(ctx, meltRequest)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	m "github.com/lescuer97/nutmix/internal/mint"
+)
+
+// uintQuery reads an optional unsigned query parameter, 0 when it is missing.
+func uintQuery(c *gin.Context, name string) (uint64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func keysetLogError(c *gin.Context, call string, err error) {
+	if errors.Is(err, m.ErrKeysetLogEmpty) || errors.Is(err, m.ErrKeysetLogTreeSize) || errors.Is(err, m.ErrKeysetNotLogged) {
+		c.JSON(404, err.Error())
+		return
+	}
+	slog.ErrorContext(c.Request.Context(), call, slog.Any("error", err))
+	c.JSON(500, "Server side error")
+}
+
+func registerV1KeysetLogRoutes(r *gin.Engine, mint *m.Mint) {
+	v1 := r.Group("/v1")
+
+	v1.GET("/keysets/log", func(c *gin.Context) {
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		root, err := mint.KeysetLogRoot(ctx)
+		if err != nil {
+			keysetLogError(c, "mint.KeysetLogRoot(ctx)", err)
+			return
+		}
+		c.JSON(200, root)
+	})
+
+	v1.GET("/keysets/log/entries", func(c *gin.Context) {
+		start, err := uintQuery(c, "start")
+		if err != nil {
+			c.JSON(400, "Malformed start")
+			return
+		}
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		entries, err := mint.KeysetLogEntries(ctx, start)
+		if err != nil {
+			keysetLogError(c, "mint.KeysetLogEntries(ctx, start)", err)
+			return
+		}
+		c.JSON(200, entries)
+	})
+
+	v1.GET("/keysets/log/inclusion/:id", func(c *gin.Context) {
+		treeSize, err := uintQuery(c, "tree_size")
+		if err != nil {
+			c.JSON(400, "Malformed tree_size")
+			return
+		}
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		proof, err := mint.KeysetLogInclusion(ctx, c.Param("id"), treeSize)
+		if err != nil {
+			keysetLogError(c, "mint.KeysetLogInclusion(ctx, id, treeSize)", err)
+			return
+		}
+		c.JSON(200, proof)
+	})
+
+	v1.GET("/keysets/log/consistency", func(c *gin.Context) {
+		first, err := uintQuery(c, "first")
+		if err != nil {
+			c.JSON(400, "Malformed first")
+			return
+		}
+		second, err := uintQuery(c, "second")
+		if err != nil {
+			c.JSON(400, "Malformed second")
+			return
+		}
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		proof, err := mint.KeysetLogConsistency(ctx, first, second)
+		if err != nil {
+			keysetLogError(c, "mint.KeysetLogConsistency(ctx, first, second)", err)
+			return
+		}
+		c.JSON(200, proof)
+	})
+}
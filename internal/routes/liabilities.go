@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/api/cashu"
+	m "github.com/lescuer97/nutmix/internal/mint"
+	"github.com/lescuer97/nutmix/internal/routes/middleware"
+	"golang.org/x/time/rate"
+)
+
+// the first inclusion proof of a keyset builds its tree, so wallets get a few
+// requests at once and then one a second
+const liabilityInclusionBurst = 10
+
+var liabilityInclusionRate = rate.Every(time.Second)
+
+func liabilityError(c *gin.Context, call string, err error) {
+	switch {
+	case errors.Is(err, m.ErrLiabilityReportNotFound) || errors.Is(err, m.ErrLiabilityKeysetNotInReport) || errors.Is(err, m.ErrLiabilityLeavesPruned):
+		c.JSON(404, err.Error())
+	case errors.Is(err, m.ErrTooManyLiabilityItems):
+		c.JSON(400, err.Error())
+	default:
+		slog.ErrorContext(c.Request.Context(), call, slog.Any("error", err))
+		c.JSON(500, "Server side error")
+	}
+}
+
+func registerV1LiabilityRoutes(r *gin.Engine, mint *m.Mint) {
+	v1 := r.Group("/v1")
+
+	v1.GET("/liabilities", func(c *gin.Context) {
+		reportId, err := uintQuery(c, "report_id")
+		if err != nil {
+			c.JSON(400, "Malformed report_id")
+			return
+		}
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		report, err := mint.LiabilityReport(ctx, reportId)
+		if err != nil {
+			liabilityError(c, "mint.LiabilityReport(ctx, reportId)", err)
+			return
+		}
+		c.JSON(200, report)
+	})
+
+	v1.POST("/liabilities/inclusion", middleware.RateLimitByIP(middleware.NewIPRateLimiter(liabilityInclusionRate, liabilityInclusionBurst)), func(c *gin.Context) {
+		var request cashu.PostLiabilityInclusionRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "c.BindJSON(&request)", slog.Any("error", err))
+			c.JSON(400, "Malformed body request")
+			return
+		}
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		response, err := mint.LiabilityInclusion(ctx, request)
+		if err != nil {
+			liabilityError(c, "mint.LiabilityInclusion(ctx, request)", err)
+			return
+		}
+		c.JSON(200, response)
+	})
+}
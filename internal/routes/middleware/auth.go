@@ -17,12 +17,13 @@ import (
 // but only for paths that match patterns in the specified allowedPathPatterns list
 func ClearAuthMiddleware(mint *mint.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		config := mint.Config()
 		requestPath := c.Request.URL.Path
 
-		if mint.Config.MINT_REQUIRE_AUTH {
+		if config.MINT_REQUIRE_AUTH {
 			// Check if current path matches any of the patterns
-			for _, pattern := range mint.Config.MINT_AUTH_CLEAR_AUTH_URLS {
-				if !mint.Config.MINT_REQUIRE_AUTH {
+			for _, pattern := range config.MINT_AUTH_CLEAR_AUTH_URLS {
+				if !config.MINT_REQUIRE_AUTH {
 					log.Panicf("mint require auth should always be on when using the middleware")
 				}
 
@@ -31,10 +32,10 @@ func ClearAuthMiddleware(mint *mint.Mint) gin.HandlerFunc {
 					log.Panicf("This should not happen and something went wrong %+v. Patten: %s", err, pattern)
 				}
 				if matches {
-					if mint.OICDClient == nil {
+					if mint.OICDClient() == nil {
 						ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 						defer cancel()
-						err := mint.SetupOidcService(ctx, mint.Config.MINT_AUTH_OICD_URL)
+						err := mint.SetupOidcService(ctx, config.MINT_AUTH_OICD_URL)
 						if err != nil {
 							slog.Error("Could not setup oidc service during middleware.", slog.Any("error", err))
 							errMsg := "This is a mint connectin error with the oidc service"
@@ -74,15 +75,16 @@ func ClearAuthMiddleware(mint *mint.Mint) gin.HandlerFunc {
 // but only for paths that match patterns in the specified allowedPathPatterns list
 func BlindAuthMiddleware(mint *mint.Mint) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		config := mint.Config()
 		requestPath := c.Request.URL.Path
 
-		if !mint.Config.MINT_REQUIRE_AUTH {
+		if !config.MINT_REQUIRE_AUTH {
 			c.Next()
 		}
-		if mint.Config.MINT_REQUIRE_AUTH {
+		if config.MINT_REQUIRE_AUTH {
 			// Check if current path matches any of the patterns
-			for _, pattern := range mint.Config.MINT_AUTH_BLIND_AUTH_URLS {
-				if !mint.Config.MINT_REQUIRE_AUTH {
+			for _, pattern := range config.MINT_AUTH_BLIND_AUTH_URLS {
+				if !config.MINT_REQUIRE_AUTH {
 					log.Panicf("mint require auth should always be on when using the middleware")
 				}
 				matches, err := matchesPattern(requestPath, pattern)
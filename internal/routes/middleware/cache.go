@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
 )
 
@@ -29,7 +28,16 @@ var cachedPaths = map[string]bool{
 	"/v1/swap":        true,
 }
 
-func CacheMiddleware(store *persistence.InMemoryStore) gin.HandlerFunc {
+// ResponseStore is the storage used for NUT-19 cached responses. The
+// persistence.InMemoryStore and persistence.RedisStore from gin-contrib/cache
+// satisfy it, as does the postgres backed ResponseCache, so replicas behind a
+// load balancer can share their cache.
+type ResponseStore interface {
+	Get(key string, value any) error
+	Set(key string, value any, expire time.Duration) error
+}
+
+func CacheMiddleware(store ResponseStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !cachedPaths[c.Request.URL.Path] {
 			c.Next()
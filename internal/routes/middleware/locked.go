@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/mint"
+)
+
+// SignerLockedMiddleware answers the wallet api with 503 while the signer
+// waits for its keystore passphrase. /v1/info still answers so wallets can
+// tell the mint is up.
+func SignerLockedMiddleware(mint *mint.Mint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestPath := c.Request.URL.Path
+		if !strings.HasPrefix(requestPath, "/v1/") || requestPath == "/v1/info" || !mint.SignerLocked() {
+			c.Next()
+			return
+		}
+		detail := "the mint is locked, an admin needs to unlock it"
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, cashu.ErrorCodeToResponse(cashu.UNKNOWN, &detail))
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"golang.org/x/time/rate"
+)
+
+// maxTrackedIPs bounds the memory of an IPRateLimiter, past it the addresses
+// that are back to a full burst get forgotten.
+const maxTrackedIPs = 10_000
+
+// IPRateLimiter keeps a token bucket per client IP.
+type IPRateLimiter struct {
+	limiters map[string]*rate.Limiter
+	limit    rate.Limit
+	burst    int
+	mu       sync.Mutex
+}
+
+func NewIPRateLimiter(limit rate.Limit, burst int) *IPRateLimiter {
+	return &IPRateLimiter{
+		limiters: make(map[string]*rate.Limiter),
+		limit:    limit,
+		burst:    burst,
+		mu:       sync.Mutex{},
+	}
+}
+
+// Allow takes a token from the bucket of ip.
+func (l *IPRateLimiter) Allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[ip]
+	if !ok {
+		if len(l.limiters) >= maxTrackedIPs {
+			for trackedIP, tracked := range l.limiters {
+				if tracked.Tokens() >= float64(l.burst) {
+					delete(l.limiters, trackedIP)
+				}
+			}
+		}
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[ip] = limiter
+	}
+	return limiter.Allow()
+}
+
+// RateLimitByIP answers the wallet api with 429 to clients that made more
+// requests than limiter allows.
+func RateLimitByIP(limiter *IPRateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter.Allow(c.ClientIP()) {
+			c.Next()
+			return
+		}
+		slog.Warn("rate limited request", slog.String("path", c.Request.URL.Path), slog.String("ip", c.ClientIP()))
+		detail := "too many requests, try again later"
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, cashu.ErrorCodeToResponse(cashu.UNKNOWN, &detail))
+	}
+}
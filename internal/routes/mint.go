@@ -30,7 +30,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 	v1.GET("/keys", func(c *gin.Context) {
 		keys, err := mint.Signer.GetActiveKeys()
 		if err != nil {
-			slog.Error("mint.Signer.GetActiveKeys()", slog.Any("error", err))
+			slog.ErrorContext(c.Request.Context(), "mint.Signer.GetActiveKeys()", slog.Any("error", err))
 			c.JSON(400, cashu.ErrorCodeToResponse(cashu.KEYSET_NOT_KNOW, nil))
 			return
 		}
@@ -44,7 +44,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 		keysets, err := mint.Signer.GetKeysById(id)
 
 		if err != nil {
-			slog.Warn("mint.Signer.GetKeysById(id)", slog.Any("error", err))
+			slog.WarnContext(c.Request.Context(), "mint.Signer.GetKeysById(id)", slog.Any("error", err))
 			c.JSON(400, cashu.ErrorCodeToResponse(cashu.KEYSET_NOT_KNOW, nil))
 			return
 		}
@@ -54,7 +54,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 	v1.GET("/keysets", func(c *gin.Context) {
 		keys, err := mint.Signer.GetKeysets()
 		if err != nil {
-			slog.Error("mint.Signer.GetKeys()", slog.Any("error", err))
+			slog.ErrorContext(c.Request.Context(), "mint.Signer.GetKeys()", slog.Any("error", err))
 			c.JSON(500, "Server side error")
 			return
 		}
@@ -72,7 +72,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 
 		err := c.BindJSON(&swapRequest)
 		if err != nil {
-			slog.Info("Incorrect body", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "Incorrect body", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -83,7 +83,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 
 		response, err := mint.ExecuteSwap(swapCtx, swapRequest)
 		if err != nil {
-			slog.Info("mint.ExecuteSwap(swapCtx, swapRequest)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "mint.ExecuteSwap(swapCtx, swapRequest)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -95,7 +95,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 		var checkStateRequest cashu.PostCheckStateRequest
 		err := c.BindJSON(&checkStateRequest)
 		if err != nil {
-			slog.Info("c.BindJSON(&checkStateRequest)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "c.BindJSON(&checkStateRequest)", slog.Any("error", err))
 			c.JSON(400, "Malformed Body")
 			return
 		}
@@ -109,7 +109,7 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 
 		states, err := m.CheckProofState(checkStateCtx, mint, checkStateRequest.Ys)
 		if err != nil {
-			slog.Info("could not check proofs state", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "could not check proofs state", slog.Any("error", err))
 			c.JSON(400, "could not validate proofs state")
 			return
 		}
@@ -123,14 +123,14 @@ func registerV1MintRoutes(r *gin.Engine, mint *m.Mint) {
 		err := c.BindJSON(&restoreRequest)
 
 		if err != nil {
-			slog.Info("c.BindJSON(&restoreRequest)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "c.BindJSON(&restoreRequest)", slog.Any("error", err))
 			c.JSON(400, "Malformed body request")
 			return
 		}
 
 		response, err := mint.Restore(c.Request.Context(), restoreRequest)
 		if err != nil {
-			slog.Info("mint.Restore(c.Request.Context(), restoreRequest)", slog.Any("error", err))
+			slog.InfoContext(c.Request.Context(), "mint.Restore(c.Request.Context(), restoreRequest)", slog.Any("error", err))
 			errorCode, details := utils.ParseErrorToCashuErrorCode(err)
 			c.JSON(400, cashu.ErrorCodeToResponse(errorCode, details))
 			return
@@ -7,10 +7,13 @@ import (
 )
 
 func V1Routes(r *gin.Engine, mint *mint.Mint) {
+	r.Use(middleware.SignerLockedMiddleware(mint))
 	r.Use(middleware.ClearAuthMiddleware(mint))
 	r.Use(middleware.BlindAuthMiddleware(mint))
 	v1AuthRoutes(r, mint)
 	registerV1MintRoutes(r, mint)
+	registerV1KeysetLogRoutes(r, mint)
+	registerV1LiabilityRoutes(r, mint)
 	registerV1Bolt11Routes(r, mint)
 	v1WebSocketRoute(r, mint)
 }
@@ -257,7 +257,7 @@ func CheckStatusOfSub(ctx context.Context, request cashu.WsRequest, mint *m.Mint
 				return fmt.Errorf("mint.MintDB.Commit(ctx tx). %w", err)
 			}
 
-			decodedInvoice, err := zpay32.Decode(quote.Request, mint.LightningBackend.GetNetwork())
+			decodedInvoice, err := zpay32.Decode(quote.Request, mint.LightningBackend().GetNetwork())
 			if err != nil {
 				return fmt.Errorf("m.CheckMintRequest(mint, filter). %w", err)
 			}
@@ -0,0 +1,284 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+var (
+	ErrJobNotFound         = errors.New("job not found")
+	ErrJobAlreadyExists    = errors.New("job already registered")
+	ErrJobRunningElsewhere = errors.New("job is already running")
+)
+
+type Store interface {
+	RunExclusive(ctx context.Context, lockKey int64, fn func(ctx context.Context) error) (bool, error)
+	GetJobRun(ctx context.Context, name string) (*database.JobRun, error)
+	GetJobRuns(ctx context.Context) ([]database.JobRun, error)
+	SaveJobRun(ctx context.Context, run database.JobRun) error
+}
+
+// Job is a piece of background work that should only run on one mint replica
+// at a time.
+type Job struct {
+	Run      func(ctx context.Context) error
+	Name     string
+	Interval time.Duration
+	// RunOnStart runs the job as soon as it is scheduled instead of waiting for
+	// the first interval.
+	RunOnStart bool
+}
+
+type JobStatus struct {
+	LastRun  *database.JobRun
+	Name     string
+	Interval time.Duration
+}
+
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
+
+// Scheduler runs registered jobs on their interval. Every replica runs a
+// scheduler, a postgres advisory lock per job and the persisted last run make
+// sure each scheduled run only happens on one of them.
+type Scheduler struct {
+	DB         Store
+	Now        func() time.Time
+	Logger     *slog.Logger
+	NewTicker  func(interval time.Duration) ticker
+	ctx        context.Context
+	instanceId string
+	jobs       []Job
+	mu         sync.Mutex
+}
+
+func New(db Store) *Scheduler {
+	return &Scheduler{
+		DB:         db,
+		Now:        nil,
+		Logger:     nil,
+		NewTicker:  nil,
+		ctx:        nil,
+		instanceId: uuid.New().String(),
+		jobs:       []Job{},
+		mu:         sync.Mutex{},
+	}
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Scheduler) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s *Scheduler) newTicker(interval time.Duration) ticker {
+	if s.NewTicker != nil {
+		return s.NewTicker(interval)
+	}
+	return realTicker{ticker: time.NewTicker(interval)}
+}
+
+// lockKey maps a job name to the advisory lock used for it.
+func lockKey(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte("nutmix-job:" + name))
+	return int64(hash.Sum64())
+}
+
+// Register adds a job to the scheduler. Jobs registered after Start are
+// scheduled right away.
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a run function")
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("job %s needs a positive interval", job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.jobs, func(registered Job) bool { return registered.Name == job.Name }) {
+		return fmt.Errorf("%w: %s", ErrJobAlreadyExists, job.Name)
+	}
+	s.jobs = append(s.jobs, job)
+
+	if s.ctx != nil {
+		go s.loop(s.ctx, job)
+	}
+	return nil
+}
+
+// Start schedules every registered job until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	s.ctx = ctx
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	if job.RunOnStart {
+		s.runScheduled(ctx, job)
+	}
+
+	t := s.newTicker(job.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+			s.runScheduled(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runScheduled(ctx context.Context, job Job) {
+	err := s.run(ctx, job, false)
+	if err != nil && !errors.Is(err, ErrJobRunningElsewhere) {
+		s.logger().Warn("scheduled job failed", slog.String("job", job.Name), slog.Any("error", err))
+	}
+}
+
+// Trigger runs a job right away, ignoring when it last ran.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, job, true)
+}
+
+func (s *Scheduler) job(name string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+	return Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+}
+
+// Jobs returns the registered jobs with the last run persisted by any replica.
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobStatus, error) {
+	runs, err := s.DB.GetJobRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.DB.GetJobRuns(ctx). %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := JobStatus{Name: job.Name, Interval: job.Interval, LastRun: nil}
+		for i := range runs {
+			if runs[i].Name == job.Name {
+				status.LastRun = &runs[i]
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// run executes the job while holding its advisory lock. Unless forced, a run
+// is skipped when another replica already ran the job in this interval.
+func (s *Scheduler) run(ctx context.Context, job Job, force bool) error {
+	var jobErr error
+	acquired, err := s.DB.RunExclusive(ctx, lockKey(job.Name), func(ctx context.Context) error {
+		lastRun, err := s.DB.GetJobRun(ctx, job.Name)
+		if err != nil {
+			return fmt.Errorf("s.DB.GetJobRun(ctx, job.Name). %w", err)
+		}
+
+		startedAt := s.now()
+		runCount := int64(0)
+		if lastRun != nil {
+			// leave some slack so tickers drifting between replicas don't skip a run
+			sinceLastRun := startedAt.Sub(time.Unix(lastRun.StartedAt, 0))
+			if !force && sinceLastRun < job.Interval*9/10 {
+				return nil
+			}
+			runCount = lastRun.RunCount
+		}
+
+		run := database.JobRun{
+			Name:       job.Name,
+			Status:     database.JobRunning,
+			Instance:   s.instanceId,
+			StartedAt:  startedAt.Unix(),
+			FinishedAt: 0,
+			DurationMs: 0,
+			RunCount:   runCount + 1,
+			LastError:  nil,
+		}
+		err = s.DB.SaveJobRun(ctx, run)
+		if err != nil {
+			return fmt.Errorf("s.DB.SaveJobRun(ctx, run). %w", err)
+		}
+
+		jobErr = job.Run(ctx)
+
+		finishedAt := s.now()
+		run.FinishedAt = finishedAt.Unix()
+		run.DurationMs = finishedAt.Sub(startedAt).Milliseconds()
+		run.Status = database.JobSucceeded
+		if jobErr != nil {
+			run.Status = database.JobFailed
+			errMsg := jobErr.Error()
+			run.LastError = &errMsg
+		}
+
+		// the job context might be done when shutting down, still record the run
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		err = s.DB.SaveJobRun(saveCtx, run)
+		if err != nil {
+			return fmt.Errorf("s.DB.SaveJobRun(saveCtx, run). %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("s.DB.RunExclusive(ctx, lockKey(%s)). %w", job.Name, err)
+	}
+	if !acquired {
+		return ErrJobRunningElsewhere
+	}
+	if jobErr != nil {
+		return fmt.Errorf("job %s. %w", job.Name, jobErr)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+//nolint:exhaustruct,govet
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lescuer97/nutmix/internal/database"
+)
+
+var _ Store = (database.MintDB)(nil)
+
+// sharedStore behaves like postgres shared between replicas: advisory locks
+// are exclusive and job runs are visible to every scheduler.
+type sharedStore struct {
+	mu     sync.Mutex
+	locked map[int64]bool
+	runs   map[string]database.JobRun
+}
+
+func newSharedStore() *sharedStore {
+	return &sharedStore{locked: map[int64]bool{}, runs: map[string]database.JobRun{}}
+}
+
+func (s *sharedStore) RunExclusive(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	s.mu.Lock()
+	if s.locked[key] {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.locked[key] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.locked, key)
+		s.mu.Unlock()
+	}()
+	return true, fn(ctx)
+}
+
+func (s *sharedStore) GetJobRun(ctx context.Context, name string) (*database.JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[name]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func (s *sharedStore) GetJobRuns(ctx context.Context) ([]database.JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := []database.JobRun{}
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func (s *sharedStore) SaveJobRun(ctx context.Context, run database.JobRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.Name] = run
+	return nil
+}
+
+func TestOnlyOneReplicaRunsEachInterval(t *testing.T) {
+	store := newSharedStore()
+	now := time.Unix(1_700_000_000, 0)
+	calls := 0
+	job := Job{
+		Name:     "job",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			calls++
+			return nil
+		},
+	}
+
+	first := New(store)
+	first.Now = func() time.Time { return now }
+	second := New(store)
+	second.Now = func() time.Time { return now.Add(5 * time.Second) }
+	if err := first.Register(job); err != nil {
+		t.Fatalf("first.Register(job): %v", err)
+	}
+	if err := second.Register(job); err != nil {
+		t.Fatalf("second.Register(job): %v", err)
+	}
+
+	if err := first.run(context.Background(), job, false); err != nil {
+		t.Fatalf("first.run: %v", err)
+	}
+	if err := second.run(context.Background(), job, false); err != nil {
+		t.Fatalf("second.run: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected job to run once in the interval, ran %d times", calls)
+	}
+
+	second.Now = func() time.Time { return now.Add(time.Minute) }
+	if err := second.run(context.Background(), job, false); err != nil {
+		t.Fatalf("second.run: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected job to run again on the next interval, ran %d times", calls)
+	}
+
+	run, _ := store.GetJobRun(context.Background(), "job")
+	if run.RunCount != 2 || run.Instance != second.instanceId || run.Status != database.JobSucceeded {
+		t.Fatalf("unexpected persisted run: %+v", run)
+	}
+}
+
+func TestRunIsSkippedWhileLockIsHeld(t *testing.T) {
+	store := newSharedStore()
+	scheduler := New(store)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	job := Job{
+		Name:     "slow",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}
+	if err := scheduler.Register(job); err != nil {
+		t.Fatalf("scheduler.Register(job): %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- scheduler.Trigger(context.Background(), "slow") }()
+	<-started
+
+	err := scheduler.Trigger(context.Background(), "slow")
+	if !errors.Is(err, ErrJobRunningElsewhere) {
+		t.Fatalf("expected ErrJobRunningElsewhere, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("first trigger: %v", err)
+	}
+}
+
+func TestFailedRunIsPersisted(t *testing.T) {
+	store := newSharedStore()
+	scheduler := New(store)
+	if err := scheduler.Register(Job{
+		Name:     "failing",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			return errors.New("lightning node unreachable")
+		},
+	}); err != nil {
+		t.Fatalf("scheduler.Register: %v", err)
+	}
+
+	err := scheduler.Trigger(context.Background(), "failing")
+	if err == nil {
+		t.Fatal("expected trigger to return the job error")
+	}
+
+	statuses, err := scheduler.Jobs(context.Background())
+	if err != nil {
+		t.Fatalf("scheduler.Jobs: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].LastRun == nil {
+		t.Fatalf("expected one job with a last run, got %+v", statuses)
+	}
+	lastRun := statuses[0].LastRun
+	if lastRun.Status != database.JobFailed || lastRun.LastError == nil || *lastRun.LastError != "lightning node unreachable" {
+		t.Fatalf("unexpected last run: %+v", lastRun)
+	}
+}
+
+func TestRegisterRejectsDuplicatesAndTriggerUnknown(t *testing.T) {
+	scheduler := New(newSharedStore())
+	job := Job{Name: "job", Interval: time.Minute, Run: func(ctx context.Context) error { return nil }}
+	if err := scheduler.Register(job); err != nil {
+		t.Fatalf("scheduler.Register(job): %v", err)
+	}
+	if err := scheduler.Register(job); !errors.Is(err, ErrJobAlreadyExists) {
+		t.Fatalf("expected ErrJobAlreadyExists, got %v", err)
+	}
+	if err := scheduler.Trigger(context.Background(), "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
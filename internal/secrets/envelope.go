@@ -0,0 +1,375 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+// encryptedPrefix marks a value encrypted by an Envelope. The id of the data
+// key and the base64 nonce and ciphertext follow it:
+// enc:v1:<data key id>:<base64>.
+const encryptedPrefix = "enc:v1:"
+
+var (
+	ErrMissingKMS     = errors.New("the config secrets are encrypted, set " + PassphraseEnv + ", " + KeyFileEnv + " or " + KmsEnv)
+	ErrKMSMismatch    = errors.New("the data key was wrapped with a different kms")
+	ErrUnknownDataKey = errors.New("unknown data key")
+	ErrNoEnvelope     = errors.New("config encryption is not set up")
+)
+
+// DataKey is a data key wrapped by a KMS. Only the active key encrypts new
+// values, the others are kept until nothing uses them.
+type DataKey struct {
+	Kms        string `db:"kms"`
+	WrappedKey []byte `db:"wrapped_key"`
+	Id         int64  `db:"id"`
+	CreatedAt  int64  `db:"created_at"`
+	Active     bool   `db:"active"`
+}
+
+type DataKeyStore interface {
+	GetDataKeys(ctx context.Context) ([]DataKey, error)
+	// SaveDataKey stores key as the only active data key.
+	SaveDataKey(ctx context.Context, key DataKey) (int64, error)
+	// UpdateWrappedDataKeys replaces the kms and wrapped key of every key in
+	// one transaction.
+	UpdateWrappedDataKeys(ctx context.Context, keys []DataKey) error
+}
+
+const reloadTimeout = 10 * time.Second
+
+// Envelope encrypts single values with the data keys. A nil Envelope leaves
+// values in plaintext and fails on encrypted ones.
+type Envelope struct {
+	store    DataKeyStore
+	kms      KMS
+	keys     map[int64][]byte
+	mu       sync.RWMutex
+	activeId int64
+}
+
+// LoadEnvelope unwraps the stored data keys with kms without changing the
+// database. It returns nil when there are no data keys yet.
+func LoadEnvelope(ctx context.Context, store DataKeyStore, kms KMS) (*Envelope, error) {
+	keys, err := store.GetDataKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("store.GetDataKeys(ctx). %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if kms == nil {
+		return nil, ErrMissingKMS
+	}
+
+	envelope := &Envelope{
+		store:    store,
+		kms:      kms,
+		keys:     make(map[int64][]byte, len(keys)),
+		mu:       sync.RWMutex{},
+		activeId: 0,
+	}
+	err = envelope.addKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// OpenEnvelope is LoadEnvelope that also creates the first data key. Without
+// a kms and data keys the config stays in plaintext and it returns nil.
+func OpenEnvelope(ctx context.Context, store DataKeyStore, kms KMS) (*Envelope, error) {
+	envelope, err := LoadEnvelope(ctx, store, kms)
+	if err != nil || envelope != nil || kms == nil {
+		return envelope, err
+	}
+
+	envelope = &Envelope{
+		store:    store,
+		kms:      kms,
+		keys:     map[int64][]byte{},
+		mu:       sync.RWMutex{},
+		activeId: 0,
+	}
+	_, err = envelope.Rotate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+func (e *Envelope) addKeys(ctx context.Context, keys []DataKey) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := e.keys[key.Id]; ok {
+			continue
+		}
+		unwrapped, err := UnwrapDataKey(ctx, e.kms, key)
+		if err != nil {
+			return err
+		}
+		e.keys[key.Id] = unwrapped
+		if key.Active || e.activeId == 0 {
+			e.activeId = key.Id
+		}
+	}
+	return nil
+}
+
+// reload picks up data keys created by another process, like nutmixctl
+// rotating the data key while the mint runs.
+func (e *Envelope) reload() error {
+	if e.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+	keys, err := e.store.GetDataKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("e.store.GetDataKeys(ctx). %w", err)
+	}
+	return e.addKeys(ctx, keys)
+}
+
+// UnwrapDataKey unwraps key after checking it was wrapped by kms.
+func UnwrapDataKey(ctx context.Context, kms KMS, key DataKey) ([]byte, error) {
+	if key.Kms != kms.Name() {
+		return nil, fmt.Errorf("%w: data key %d uses %s, not %s", ErrKMSMismatch, key.Id, key.Kms, kms.Name())
+	}
+	unwrapped, err := kms.UnwrapKey(ctx, key.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("kms.UnwrapKey(ctx, data key %d). %w", key.Id, err)
+	}
+	return unwrapped, nil
+}
+
+// Rotate creates a new active data key. Values encrypted with the old keys
+// can still be read until they are encrypted again.
+func (e *Envelope) Rotate(ctx context.Context) (int64, error) {
+	if e == nil {
+		return 0, ErrNoEnvelope
+	}
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return 0, fmt.Errorf("rand.Read(key). %w", err)
+	}
+	wrapped, err := e.kms.WrapKey(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("e.kms.WrapKey(ctx, key). %w", err)
+	}
+	id, err := e.store.SaveDataKey(ctx, DataKey{
+		Kms:        e.kms.Name(),
+		WrappedKey: wrapped,
+		Id:         0,
+		CreatedAt:  time.Now().Unix(),
+		Active:     true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("e.store.SaveDataKey(ctx, key). %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys[id] = key
+	e.activeId = id
+	return id, nil
+}
+
+// Rewrap wraps every data key with newKms, like after changing the
+// passphrase. The encrypted values do not change.
+func (e *Envelope) Rewrap(ctx context.Context, newKms KMS) error {
+	if e == nil {
+		return ErrNoEnvelope
+	}
+	stored, err := e.store.GetDataKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("e.store.GetDataKeys(ctx). %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rewrapped := make([]DataKey, 0, len(stored))
+	for _, key := range stored {
+		unwrapped, ok := e.keys[key.Id]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownDataKey, key.Id)
+		}
+		wrapped, err := newKms.WrapKey(ctx, unwrapped)
+		if err != nil {
+			return fmt.Errorf("newKms.WrapKey(ctx, data key %d). %w", key.Id, err)
+		}
+		key.Kms = newKms.Name()
+		key.WrappedKey = wrapped
+		rewrapped = append(rewrapped, key)
+	}
+	err = e.store.UpdateWrappedDataKeys(ctx, rewrapped)
+	if err != nil {
+		return fmt.Errorf("e.store.UpdateWrappedDataKeys(ctx, keys). %w", err)
+	}
+	e.kms = newKms
+	return nil
+}
+
+// ActiveKeyId returns the data key new values are encrypted with.
+func (e *Envelope) ActiveKeyId() int64 {
+	if e == nil {
+		return 0
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.activeId
+}
+
+// Forget drops a data key once it was removed from the database.
+func (e *Envelope) Forget(id int64) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if id != e.activeId {
+		delete(e.keys, id)
+	}
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// IsCurrent reports if value needs no work: it is empty or already encrypted
+// with the active data key.
+func (e *Envelope) IsCurrent(value string) bool {
+	if value == "" {
+		return true
+	}
+	if e == nil {
+		return !IsEncrypted(value)
+	}
+	id, _, err := parseEncrypted(value)
+	return err == nil && id == e.ActiveKeyId()
+}
+
+// Encrypt encrypts value for field with the active data key. The field name
+// is authenticated, so a value can not be moved to another column.
+func (e *Envelope) Encrypt(field string, value string) (string, error) {
+	if e == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	e.mu.RLock()
+	id := e.activeId
+	key := e.keys[id]
+	e.mu.RUnlock()
+
+	sealed, err := seal(key, []byte(value), []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("seal(key, %s). %w", field, err)
+	}
+	return encryptedPrefix + strconv.FormatInt(id, 10) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns plaintext values unchanged, so rows written before the
+// encryption was set up still load.
+func (e *Envelope) Decrypt(field string, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if e == nil {
+		return "", ErrMissingKMS
+	}
+	id, sealed, err := parseEncrypted(value)
+	if err != nil {
+		return "", fmt.Errorf("parseEncrypted(%s). %w", field, err)
+	}
+	key, ok := e.key(id)
+	if !ok {
+		err = e.reload()
+		if err != nil {
+			return "", err
+		}
+		key, ok = e.key(id)
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnknownDataKey, id)
+	}
+	plaintext, err := open(key, sealed, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("open(key, %s). %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+func (e *Envelope) key(id int64) ([]byte, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	key, ok := e.keys[id]
+	return key, ok
+}
+
+// KeyIdOf returns the data key an encrypted value uses.
+func KeyIdOf(value string) (int64, bool) {
+	id, _, err := parseEncrypted(value)
+	return id, err == nil
+}
+
+func parseEncrypted(value string) (int64, []byte, error) {
+	idStr, encoded, found := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !IsEncrypted(value) || !found {
+		return 0, nil, ErrDecrypt
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: strconv.ParseInt(id). %w", ErrDecrypt, err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: base64.StdEncoding.DecodeString(value). %w", ErrDecrypt, err)
+	}
+	return id, sealed, nil
+}
+
+// ConfigSecrets are the config fields holding backend credentials, keyed by
+// their column name.
+func ConfigSecrets(config *utils.Config) map[string]*string {
+	return map[string]*string{
+		"lnd_macaroon":    &config.LND_MACAROON,
+		"cln_client_key":  &config.CLN_CLIENT_KEY,
+		"cln_macaroon":    &config.CLN_MACAROON,
+		"mint_lnbits_key": &config.MINT_LNBITS_KEY,
+		"strike_key":      &config.STRIKE_KEY,
+	}
+}
+
+// EncryptConfig returns config with its credentials encrypted.
+func (e *Envelope) EncryptConfig(config utils.Config) (utils.Config, error) {
+	for field, value := range ConfigSecrets(&config) {
+		encrypted, err := e.Encrypt(field, *value)
+		if err != nil {
+			return config, err
+		}
+		*value = encrypted
+	}
+	return config, nil
+}
+
+// DecryptConfig returns config with its credentials in plaintext.
+func (e *Envelope) DecryptConfig(config utils.Config) (utils.Config, error) {
+	for field, value := range ConfigSecrets(&config) {
+		decrypted, err := e.Decrypt(field, *value)
+		if err != nil {
+			return config, err
+		}
+		*value = decrypted
+	}
+	return config, nil
+}
@@ -0,0 +1,214 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lescuer97/nutmix/internal/utils"
+)
+
+type memoryStore struct {
+	keys []DataKey
+}
+
+func (m *memoryStore) GetDataKeys(ctx context.Context) ([]DataKey, error) {
+	return append([]DataKey{}, m.keys...), nil
+}
+
+func (m *memoryStore) SaveDataKey(ctx context.Context, key DataKey) (int64, error) {
+	for i := range m.keys {
+		m.keys[i].Active = false
+	}
+	key.Id = int64(len(m.keys) + 1)
+	key.Active = true
+	m.keys = append(m.keys, key)
+	return key.Id, nil
+}
+
+func (m *memoryStore) UpdateWrappedDataKeys(ctx context.Context, keys []DataKey) error {
+	for _, key := range keys {
+		m.keys[key.Id-1] = key
+	}
+	return nil
+}
+
+func testFileKMS(t *testing.T) *FileKMS {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.key")
+	err := GenerateKeyFile(path)
+	if err != nil {
+		t.Fatalf("GenerateKeyFile(path): %v", err)
+	}
+	err = GenerateKeyFile(path)
+	if err == nil {
+		t.Fatal("expected GenerateKeyFile to refuse to overwrite the key")
+	}
+	kms, err := NewFileKMS(path)
+	if err != nil {
+		t.Fatalf("NewFileKMS(path): %v", err)
+	}
+	return kms
+}
+
+func TestEnvelopeEncryptsConfigSecrets(t *testing.T) {
+	ctx := t.Context()
+	store := &memoryStore{}
+	kms := testFileKMS(t)
+
+	envelope, err := OpenEnvelope(ctx, store, kms)
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	if len(store.keys) != 1 || store.keys[0].Kms != FileKmsName {
+		t.Fatalf("expected the first data key to be created, got %+v", store.keys)
+	}
+
+	config := utils.Config{
+		LND_MACAROON: "macaroon",
+		STRIKE_KEY:   "strike",
+		NAME:         "mint",
+	}
+	encrypted, err := envelope.EncryptConfig(config)
+	if err != nil {
+		t.Fatalf("envelope.EncryptConfig: %v", err)
+	}
+	if !IsEncrypted(encrypted.LND_MACAROON) || !IsEncrypted(encrypted.STRIKE_KEY) || strings.Contains(encrypted.LND_MACAROON, "macaroon") {
+		t.Errorf("expected the credentials to be encrypted, got %+v", encrypted)
+	}
+	if encrypted.CLN_MACAROON != "" || encrypted.NAME != "mint" {
+		t.Error("expected empty credentials and other fields to stay as they are")
+	}
+
+	// a fresh envelope from the stored keys reads what the first one wrote
+	loaded, err := LoadEnvelope(ctx, store, kms)
+	if err != nil {
+		t.Fatalf("LoadEnvelope: %v", err)
+	}
+	decrypted, err := loaded.DecryptConfig(encrypted)
+	if err != nil {
+		t.Fatalf("loaded.DecryptConfig: %v", err)
+	}
+	if decrypted.LND_MACAROON != "macaroon" || decrypted.STRIKE_KEY != "strike" {
+		t.Errorf("expected the credentials back, got %+v", decrypted)
+	}
+
+	// a value can not be moved to another column
+	_, err = loaded.Decrypt("cln_macaroon", encrypted.LND_MACAROON)
+	if !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected a moved value to fail, got %v", err)
+	}
+
+	_, err = LoadEnvelope(ctx, store, nil)
+	if !errors.Is(err, ErrMissingKMS) {
+		t.Errorf("expected encrypted data without a kms to fail, got %v", err)
+	}
+	var missing *Envelope
+	_, err = missing.DecryptConfig(encrypted)
+	if !errors.Is(err, ErrMissingKMS) {
+		t.Errorf("expected a nil envelope to refuse encrypted values, got %v", err)
+	}
+	plain, err := missing.EncryptConfig(config)
+	if err != nil || plain.LND_MACAROON != "macaroon" {
+		t.Errorf("expected a nil envelope to keep plaintext, got %+v %v", plain, err)
+	}
+}
+
+func TestEnvelopeRotateAndRewrap(t *testing.T) {
+	ctx := t.Context()
+	store := &memoryStore{}
+	kms := testFileKMS(t)
+
+	envelope, err := OpenEnvelope(ctx, store, kms)
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	old, err := envelope.Encrypt("lnd_macaroon", "macaroon")
+	if err != nil {
+		t.Fatalf("envelope.Encrypt: %v", err)
+	}
+
+	// another process rotates, the running envelope picks the key up
+	other, err := LoadEnvelope(ctx, store, kms)
+	if err != nil {
+		t.Fatalf("LoadEnvelope: %v", err)
+	}
+	id, err := other.Rotate(ctx)
+	if err != nil {
+		t.Fatalf("other.Rotate: %v", err)
+	}
+	rotated, err := other.Encrypt("lnd_macaroon", "macaroon")
+	if err != nil {
+		t.Fatalf("other.Encrypt: %v", err)
+	}
+	if keyId, _ := KeyIdOf(rotated); keyId != id || envelope.IsCurrent(old) == other.IsCurrent(old) {
+		t.Errorf("expected the new value to use data key %d", id)
+	}
+	value, err := envelope.Decrypt("lnd_macaroon", rotated)
+	if err != nil || value != "macaroon" {
+		t.Errorf("expected the running envelope to load the new key, got %q %v", value, err)
+	}
+
+	passphrase, err := NewPassphraseKMS("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("NewPassphraseKMS: %v", err)
+	}
+	err = other.Rewrap(ctx, passphrase)
+	if err != nil {
+		t.Fatalf("other.Rewrap: %v", err)
+	}
+	_, err = LoadEnvelope(ctx, store, kms)
+	if !errors.Is(err, ErrKMSMismatch) {
+		t.Errorf("expected the old kms to be refused, got %v", err)
+	}
+	rewrapped, err := LoadEnvelope(ctx, store, passphrase)
+	if err != nil {
+		t.Fatalf("LoadEnvelope(passphrase): %v", err)
+	}
+	value, err = rewrapped.Decrypt("lnd_macaroon", old)
+	if err != nil || value != "macaroon" {
+		t.Errorf("expected the values to survive the rewrap, got %q %v", value, err)
+	}
+
+	wrongPassphrase, err := NewPassphraseKMS("wrong")
+	if err != nil {
+		t.Fatalf("NewPassphraseKMS: %v", err)
+	}
+	_, err = LoadEnvelope(ctx, store, wrongPassphrase)
+	if !errors.Is(err, ErrUnwrapDataKey) {
+		t.Errorf("expected a wrong passphrase to fail, got %v", err)
+	}
+}
+
+func TestKMSFromEnv(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	t.Setenv(KeyFileEnv, "")
+	t.Setenv(KmsEnv, "")
+	kms, err := KMSFromEnv()
+	if err != nil || kms != nil {
+		t.Errorf("expected no kms, got %v %v", kms, err)
+	}
+
+	t.Setenv(PassphraseEnv, "passphrase")
+	t.Setenv(KeyFileEnv, "/tmp/key")
+	_, err = KMSFromEnv()
+	if !errors.Is(err, ErrConflictingKMS) {
+		t.Errorf("expected two kms to conflict, got %v", err)
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	t.Setenv(KeyFileEnv, "")
+	t.Setenv(KmsEnv, "test-kms")
+	_, err = KMSFromEnv()
+	if !errors.Is(err, ErrUnknownKMS) {
+		t.Errorf("expected an unregistered kms to fail, got %v", err)
+	}
+	fileKms := testFileKMS(t)
+	RegisterKMS("test-kms", func() (KMS, error) { return fileKms, nil })
+	kms, err = KMSFromEnv()
+	if err != nil || kms != fileKms {
+		t.Errorf("expected the registered kms, got %v %v", kms, err)
+	}
+}
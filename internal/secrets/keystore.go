@@ -0,0 +1,281 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/term"
+)
+
+const (
+	// KeystoreFileEnv points to the encrypted keystore with the mint master
+	// key. It replaces MINT_PRIVATE_KEY.
+	KeystoreFileEnv = "MINT_KEYSTORE_FILE"
+	// KeystorePassphraseFdEnv is a file descriptor the keystore passphrase is
+	// read from at startup, like a pipe opened by systemd or a secrets agent.
+	KeystorePassphraseFdEnv = "MINT_KEYSTORE_PASSPHRASE_FD"
+	// PreviousKeystoreFilesEnv lists, comma separated, the keystores of the
+	// master keys used before the one in MINT_KEYSTORE_FILE. They are
+	// unlocked with the same passphrase.
+	PreviousKeystoreFilesEnv = "MINT_PREVIOUS_KEYSTORE_FILES"
+
+	keystoreVersion = 1
+	keystoreKdf     = "argon2id"
+	keystoreCipher  = "aes-256-gcm"
+)
+
+var (
+	ErrWrongPassphrase    = errors.New("wrong keystore passphrase")
+	ErrInvalidKeystore    = errors.New("invalid keystore file")
+	ErrNoTerminal         = errors.New("stdin is not a terminal")
+	ErrPassphraseMismatch = errors.New("the passphrases do not match")
+)
+
+var defaultKeystoreKdf = KeystoreKdf{
+	Name:    keystoreKdf,
+	Salt:    "",
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+type KeystoreKdf struct {
+	Name    string `json:"name"`
+	Salt    string `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// Keystore is the on disk format of the encrypted mint master key. The
+// pubkey is stored in the clear so a locked mint can still tell wallets who it
+// is, and it is authenticated with the key so it can not be swapped.
+type Keystore struct {
+	Pubkey     string      `json:"pubkey"`
+	Cipher     string      `json:"cipher"`
+	Ciphertext string      `json:"ciphertext"`
+	Kdf        KeystoreKdf `json:"kdf"`
+	Version    int         `json:"version"`
+}
+
+// NewKeystore encrypts secret with a key derived from passphrase.
+func NewKeystore(secret []byte, pubkey string, passphrase string) (Keystore, error) {
+	if passphrase == "" {
+		return Keystore{}, ErrEmptyPassphrase
+	}
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return Keystore{}, fmt.Errorf("rand.Read(salt). %w", err)
+	}
+	kdf := defaultKeystoreKdf
+	kdf.Salt = hex.EncodeToString(salt)
+
+	sealed, err := seal(kdf.deriveKey(passphrase, salt), secret, []byte(pubkey))
+	if err != nil {
+		return Keystore{}, err
+	}
+	return Keystore{
+		Pubkey:     pubkey,
+		Cipher:     keystoreCipher,
+		Ciphertext: hex.EncodeToString(sealed),
+		Kdf:        kdf,
+		Version:    keystoreVersion,
+	}, nil
+}
+
+func (k KeystoreKdf) deriveKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, k.Time, k.Memory, k.Threads, keySize)
+}
+
+// Unlock decrypts the secret in the keystore.
+func (k Keystore) Unlock(passphrase string) ([]byte, error) {
+	if k.Version != keystoreVersion || k.Cipher != keystoreCipher || k.Kdf.Name != keystoreKdf {
+		return nil, fmt.Errorf("%w: unsupported version %d, cipher %s or kdf %s", ErrInvalidKeystore, k.Version, k.Cipher, k.Kdf.Name)
+	}
+	salt, err := hex.DecodeString(k.Kdf.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: hex.DecodeString(salt). %w", ErrInvalidKeystore, err)
+	}
+	sealed, err := hex.DecodeString(k.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: hex.DecodeString(ciphertext). %w", ErrInvalidKeystore, err)
+	}
+	secret, err := open(k.Kdf.deriveKey(passphrase, salt), sealed, []byte(k.Pubkey))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return secret, nil
+}
+
+// ChangePassphrase returns the keystore encrypted with newPassphrase.
+func (k Keystore) ChangePassphrase(oldPassphrase string, newPassphrase string) (Keystore, error) {
+	secret, err := k.Unlock(oldPassphrase)
+	if err != nil {
+		return k, err
+	}
+	defer clear(secret)
+	return NewKeystore(secret, k.Pubkey, newPassphrase)
+}
+
+func ReadKeystore(path string) (Keystore, error) {
+	var keystore Keystore
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return keystore, fmt.Errorf("os.ReadFile(%s). %w", path, err)
+	}
+	err = json.Unmarshal(content, &keystore)
+	if err != nil {
+		return keystore, fmt.Errorf("%w: json.Unmarshal(keystore). %w", ErrInvalidKeystore, err)
+	}
+	return keystore, nil
+}
+
+// ReadKeystores reads a comma separated list of keystore paths, like
+// MINT_PREVIOUS_KEYSTORE_FILES.
+func ReadKeystores(paths string) ([]Keystore, error) {
+	var keystores []Keystore
+	for path := range strings.SplitSeq(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		keystore, err := ReadKeystore(path)
+		if err != nil {
+			return nil, err
+		}
+		keystores = append(keystores, keystore)
+	}
+	return keystores, nil
+}
+
+// UnlockKeystores unlocks every keystore with the same passphrase.
+func UnlockKeystores(keystores []Keystore, passphrase string) ([][]byte, error) {
+	keys := make([][]byte, 0, len(keystores))
+	for _, keystore := range keystores {
+		secret, err := keystore.Unlock(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("keystore %s: %w", keystore.Pubkey, err)
+		}
+		keys = append(keys, secret)
+	}
+	return keys, nil
+}
+
+// WriteKeystore writes the keystore with mode 0600. An existing file is only
+// replaced when overwrite is set, and then through a rename so a crash never
+// leaves half a keystore behind.
+func WriteKeystore(path string, keystore Keystore, overwrite bool) error {
+	content, err := json.MarshalIndent(keystore, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent(keystore). %w", err)
+	}
+	content = append(content, '\n')
+
+	if !overwrite {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return fmt.Errorf("os.OpenFile(%s). %w", path, err)
+		}
+		_, err = file.Write(content)
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("file.Write(keystore). %w", err)
+		}
+		return file.Close()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp(%s). %w", filepath.Dir(path), err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	_, err = tmp.Write(content)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("tmp.Write(keystore). %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("tmp.Close(). %w", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("os.Rename(%s, %s). %w", tmp.Name(), path, err)
+	}
+	return nil
+}
+
+// PassphraseFromFd reads a passphrase from the file descriptor in fdStr up to
+// the first newline and closes it.
+func PassphraseFromFd(fdStr string) (string, error) {
+	fd, err := strconv.ParseUint(fdStr, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("strconv.ParseUint(%s). %w", fdStr, err)
+	}
+	file := os.NewFile(uintptr(fd), "passphrase")
+	if file == nil {
+		return "", fmt.Errorf("file descriptor %d is not valid", fd)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading the passphrase from fd %d. %w", fd, err)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", ErrEmptyPassphrase
+	}
+	return passphrase, nil
+}
+
+// IsTerminal reports if a passphrase can be asked for on stdin.
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// PromptPassphrase asks for a passphrase on the terminal without echoing it.
+func PromptPassphrase(prompt string) (string, error) {
+	if !IsTerminal() {
+		return "", ErrNoTerminal
+	}
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("term.ReadPassword(stdin). %w", err)
+	}
+	if len(passphrase) == 0 {
+		return "", ErrEmptyPassphrase
+	}
+	return string(passphrase), nil
+}
+
+// PromptNewPassphrase asks for a new passphrase twice.
+func PromptNewPassphrase(prompt string) (string, error) {
+	passphrase, err := PromptPassphrase(prompt)
+	if err != nil {
+		return "", err
+	}
+	again, err := PromptPassphrase("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != again {
+		return "", ErrPassphraseMismatch
+	}
+	return passphrase, nil
+}
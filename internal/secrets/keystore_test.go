@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestKeystore(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+	keystore, err := NewKeystore(secret, "pubkey", "first passphrase")
+	if err != nil {
+		t.Fatalf("NewKeystore: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	err = WriteKeystore(path, keystore, false)
+	if err != nil {
+		t.Fatalf("WriteKeystore: %v", err)
+	}
+	err = WriteKeystore(path, keystore, false)
+	if err == nil {
+		t.Fatal("expected WriteKeystore to refuse to overwrite the keystore")
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the keystore to be private, got %v %v", info, err)
+	}
+
+	read, err := ReadKeystore(path)
+	if err != nil {
+		t.Fatalf("ReadKeystore: %v", err)
+	}
+	_, err = read.Unlock("wrong")
+	if !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected a wrong passphrase error, got %v", err)
+	}
+	unlocked, err := read.Unlock("first passphrase")
+	if err != nil || !bytes.Equal(unlocked, secret) {
+		t.Errorf("expected the secret back, got %x %v", unlocked, err)
+	}
+
+	// the pubkey is authenticated with the secret
+	swapped := read
+	swapped.Pubkey = "another pubkey"
+	_, err = swapped.Unlock("first passphrase")
+	if !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected a swapped pubkey to fail, got %v", err)
+	}
+
+	changed, err := read.ChangePassphrase("first passphrase", "second passphrase")
+	if err != nil {
+		t.Fatalf("read.ChangePassphrase: %v", err)
+	}
+	err = WriteKeystore(path, changed, true)
+	if err != nil {
+		t.Fatalf("WriteKeystore(overwrite): %v", err)
+	}
+	read, err = ReadKeystore(path)
+	if err != nil {
+		t.Fatalf("ReadKeystore: %v", err)
+	}
+	_, err = read.Unlock("first passphrase")
+	if !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected the old passphrase to fail, got %v", err)
+	}
+	unlocked, err = read.Unlock("second passphrase")
+	if err != nil || !bytes.Equal(unlocked, secret) || read.Pubkey != "pubkey" {
+		t.Errorf("expected the secret with the new passphrase, got %x %v", unlocked, err)
+	}
+}
+
+func TestPassphraseFromFd(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	_, err = writer.WriteString("pipe passphrase\nignored")
+	if err != nil {
+		t.Fatalf("writer.WriteString: %v", err)
+	}
+	_ = writer.Close()
+
+	passphrase, err := PassphraseFromFd(strconv.FormatUint(uint64(reader.Fd()), 10))
+	if err != nil || passphrase != "pipe passphrase" {
+		t.Errorf("expected the first line, got %q %v", passphrase, err)
+	}
+}
@@ -0,0 +1,234 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	PassphraseEnv = "CONFIG_ENCRYPTION_PASSPHRASE"
+	KeyFileEnv    = "CONFIG_ENCRYPTION_KEY_FILE"
+	// KmsEnv picks a kms added with RegisterKMS.
+	KmsEnv = "CONFIG_ENCRYPTION_KMS"
+
+	PassphraseKmsName = "passphrase"
+	FileKmsName       = "file"
+
+	keySize  = 32
+	saltSize = 16
+)
+
+var (
+	ErrUnknownKMS      = errors.New("unknown kms")
+	ErrConflictingKMS  = errors.New("only one of " + PassphraseEnv + ", " + KeyFileEnv + " and " + KmsEnv + " can be set")
+	ErrInvalidKeyFile  = errors.New("the key file should hold 32 hex encoded bytes")
+	ErrUnwrapDataKey   = errors.New("could not unwrap the data key")
+	ErrDecrypt         = errors.New("could not decrypt")
+	ErrEmptyPassphrase = errors.New("passphrase is empty")
+)
+
+// KMS wraps the data keys that encrypt the secrets in the database. Only the
+// wrapped data keys are stored, so a copy of the database is useless without
+// it. A cloud kms can be added with RegisterKMS.
+type KMS interface {
+	// Name is stored with every wrapped key so a key is never unwrapped with
+	// a different kms by mistake.
+	Name() string
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+var (
+	kmsRegistryMu sync.RWMutex
+	kmsRegistry   = map[string]func() (KMS, error){}
+)
+
+// RegisterKMS makes a kms available with CONFIG_ENCRYPTION_KMS=name.
+func RegisterKMS(name string, setup func() (KMS, error)) {
+	kmsRegistryMu.Lock()
+	defer kmsRegistryMu.Unlock()
+	kmsRegistry[name] = setup
+}
+
+// KMSFromEnv returns the kms configured in the environment, or nil if the
+// secrets should stay in plaintext.
+func KMSFromEnv() (KMS, error) {
+	passphrase := os.Getenv(PassphraseEnv)
+	keyFile := os.Getenv(KeyFileEnv)
+	kmsName := os.Getenv(KmsEnv)
+
+	set := 0
+	for _, value := range []string{passphrase, keyFile, kmsName} {
+		if value != "" {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return nil, ErrConflictingKMS
+	case passphrase != "":
+		return NewPassphraseKMS(passphrase)
+	case keyFile != "":
+		return NewFileKMS(keyFile)
+	case kmsName != "":
+		kmsRegistryMu.RLock()
+		setup, ok := kmsRegistry[kmsName]
+		kmsRegistryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownKMS, kmsName)
+		}
+		kms, err := setup()
+		if err != nil {
+			return nil, fmt.Errorf("setup kms %s. %w", kmsName, err)
+		}
+		return kms, nil
+	default:
+		return nil, nil
+	}
+}
+
+// PassphraseKMS derives a key from a passphrase with argon2id. Every wrapped
+// key has its own salt.
+type PassphraseKMS struct {
+	passphrase []byte
+}
+
+func NewPassphraseKMS(passphrase string) (*PassphraseKMS, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	return &PassphraseKMS{passphrase: []byte(passphrase)}, nil
+}
+
+func (p *PassphraseKMS) Name() string {
+	return PassphraseKmsName
+}
+
+func (p *PassphraseKMS) deriveKey(salt []byte) []byte {
+	return argon2.IDKey(p.passphrase, salt, 1, 64*1024, 4, keySize)
+}
+
+func (p *PassphraseKMS) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("rand.Read(salt). %w", err)
+	}
+	sealed, err := seal(p.deriveKey(salt), key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, sealed...), nil
+}
+
+func (p *PassphraseKMS) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < saltSize {
+		return nil, ErrUnwrapDataKey
+	}
+	key, err := open(p.deriveKey(wrapped[:saltSize]), wrapped[saltSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnwrapDataKey, err)
+	}
+	return key, nil
+}
+
+// FileKMS keeps its key in a local file. It stands in for a real kms on
+// setups that can keep the file away from the database backups.
+type FileKMS struct {
+	key []byte
+}
+
+func NewFileKMS(path string) (*FileKMS, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%s). %w", path, err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidKeyFile
+	}
+	return &FileKMS{key: key}, nil
+}
+
+// GenerateKeyFile writes a new random key for FileKMS. It never overwrites
+// an existing file.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return fmt.Errorf("rand.Read(key). %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(%s). %w", path, err)
+	}
+	_, err = file.WriteString(hex.EncodeToString(key) + "\n")
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("file.WriteString(key). %w", err)
+	}
+	return file.Close()
+}
+
+func (f *FileKMS) Name() string {
+	return FileKmsName
+}
+
+func (f *FileKMS) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	return seal(f.key, key, nil)
+}
+
+func (f *FileKMS) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	key, err := open(f.key, wrapped, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnwrapDataKey, err)
+	}
+	return key, nil
+}
+
+// seal encrypts with AES-256-GCM and puts the nonce in front.
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher(key). %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM(block). %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("rand.Read(nonce). %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher(key). %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM(block). %w", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,98 @@
+package signer
+
+import (
+	"context"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/metrics"
+	"github.com/lescuer97/nutmix/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// InstrumentedSigner records the latency and failures of every signer call,
+// local or remote. Signing and verification also get a span.
+type InstrumentedSigner struct {
+	Signer Signer
+}
+
+func Instrument(signer Signer) Signer {
+	if _, ok := signer.(InstrumentedSigner); ok {
+		return signer
+	}
+	return InstrumentedSigner{Signer: signer}
+}
+
+func (i InstrumentedSigner) GetKeysets() (GetKeysetsResponse, error) {
+	start := time.Now()
+	res, err := i.Signer.GetKeysets()
+	metrics.ObserveSignerCall("GetKeysets", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) GetKeysById(id string) (GetKeysResponse, error) {
+	start := time.Now()
+	res, err := i.Signer.GetKeysById(id)
+	metrics.ObserveSignerCall("GetKeysById", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) GetActiveKeys() (GetKeysResponse, error) {
+	start := time.Now()
+	res, err := i.Signer.GetActiveKeys()
+	metrics.ObserveSignerCall("GetActiveKeys", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) GetAuthKeys() (GetKeysetsResponse, error) {
+	start := time.Now()
+	res, err := i.Signer.GetAuthKeys()
+	metrics.ObserveSignerCall("GetAuthKeys", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) GetAuthKeysById(id string) (GetKeysResponse, error) {
+	start := time.Now()
+	res, err := i.Signer.GetAuthKeysById(id)
+	metrics.ObserveSignerCall("GetAuthKeysById", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) GetAuthActiveKeys() (GetKeysResponse, error) {
+	start := time.Now()
+	res, err := i.Signer.GetAuthActiveKeys()
+	metrics.ObserveSignerCall("GetAuthActiveKeys", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit uint, amounts []uint64) error {
+	start := time.Now()
+	err := i.Signer.RotateKeyset(unit, fee, expiry_limit, amounts)
+	metrics.ObserveSignerCall("RotateKeyset", start, err)
+	return err
+}
+
+func (i InstrumentedSigner) GetSignerPubkey() (string, error) {
+	start := time.Now()
+	res, err := i.Signer.GetSignerPubkey()
+	metrics.ObserveSignerCall("GetSignerPubkey", start, err)
+	return res, err
+}
+
+func (i InstrumentedSigner) SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
+	ctx, span := tracing.Start(ctx, "Signer.SignBlindMessages", attribute.Int("messages", len(messages)))
+	start := time.Now()
+	signatures, recoverSigs, err := i.Signer.SignBlindMessages(ctx, messages)
+	metrics.ObserveSignerCall("SignBlindMessages", start, err)
+	tracing.End(span, err)
+	return signatures, recoverSigs, err
+}
+
+func (i InstrumentedSigner) VerifyProofs(ctx context.Context, proofs []cashu.Proof) error {
+	ctx, span := tracing.Start(ctx, "Signer.VerifyProofs", attribute.Int("proofs", len(proofs)))
+	start := time.Now()
+	err := i.Signer.VerifyProofs(ctx, proofs)
+	metrics.ObserveSignerCall("VerifyProofs", start, err)
+	tracing.End(span, err)
+	return err
+}
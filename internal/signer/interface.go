@@ -1,6 +1,11 @@
 package signer
 
-import "github.com/lescuer97/nutmix/api/cashu"
+import (
+	"context"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+)
 
 type Signer interface {
 	GetKeysets() (GetKeysetsResponse, error)
@@ -11,9 +16,82 @@ type Signer interface {
 	GetAuthKeysById(id string) (GetKeysResponse, error)
 	GetAuthActiveKeys() (GetKeysResponse, error)
 
-	RotateKeyset(unit cashu.Unit, fee uint, expiry_limit uint) error
+	// RotateKeyset makes a new active keyset for unit with the denominations
+	// in amounts, or the default powers of two when amounts is empty.
+	RotateKeyset(unit cashu.Unit, fee uint, expiry_limit uint, amounts []uint64) error
 	GetSignerPubkey() (string, error)
 
-	SignBlindMessages(messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error)
-	VerifyProofs(proofs []cashu.Proof) error
+	SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error)
+	VerifyProofs(ctx context.Context, proofs []cashu.Proof) error
+}
+
+// Locker is a signer that starts locked, like the memory signer with an
+// encrypted keystore. It answers with ErrSignerLocked until it is unlocked.
+type Locker interface {
+	Locked() bool
+	Unlock(passphrase string) error
+}
+
+// AsLocker returns the Locker behind s, if it has one.
+func AsLocker(s Signer) (Locker, bool) {
+	if instrumented, ok := s.(InstrumentedSigner); ok {
+		s = instrumented.Signer
+	}
+	locker, ok := s.(Locker)
+	return locker, ok
+}
+
+// EndpointHealth is the state of one endpoint of a remote signer.
+type EndpointHealth struct {
+	LastCheck time.Time
+	Address   string
+	LastError string
+	Failures  int
+	Healthy   bool
+}
+
+// HealthReporter is a signer that talks to endpoints that can go down, like
+// the remote signer.
+type HealthReporter interface {
+	Health() []EndpointHealth
+}
+
+// AsHealthReporter returns the HealthReporter behind s, if it has one.
+func AsHealthReporter(s Signer) (HealthReporter, bool) {
+	if instrumented, ok := s.(InstrumentedSigner); ok {
+		s = instrumented.Signer
+	}
+	reporter, ok := s.(HealthReporter)
+	return reporter, ok
+}
+
+// DleqProver is a signer that can leave the DLEQ proof of NUT-12 out of its
+// signatures, like the PKCS#11 signer.
+type DleqProver interface {
+	ProvesDleq() bool
+}
+
+// ProvesDleq tells if the signatures of s carry a DLEQ proof.
+func ProvesDleq(s Signer) bool {
+	if instrumented, ok := s.(InstrumentedSigner); ok {
+		s = instrumented.Signer
+	}
+	prover, ok := s.(DleqProver)
+	return !ok || prover.ProvesDleq()
+}
+
+// MessageSigner is a signer that can sign other messages than blind messages
+// with the key of GetSignerPubkey, like the memory signer.
+type MessageSigner interface {
+	// SignMessage returns the BIP-340 schnorr signature of hash.
+	SignMessage(hash []byte) ([]byte, error)
+}
+
+// AsMessageSigner returns the MessageSigner behind s, if it has one.
+func AsMessageSigner(s Signer) (MessageSigner, bool) {
+	if instrumented, ok := s.(InstrumentedSigner); ok {
+		s = instrumented.Signer
+	}
+	messageSigner, ok := s.(MessageSigner)
+	return messageSigner, ok
 }
@@ -3,8 +3,8 @@ package localsigner
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
 	"slices"
 	"strconv"
 	"strings"
@@ -15,6 +15,10 @@ import (
 	"github.com/lescuer97/nutmix/api/cashu"
 )
 
+// ErrSeedIdMismatch means the master key does not derive the keysets stored in
+// the database, usually because MINT_PRIVATE_KEY changed.
+var ErrSeedIdMismatch = errors.New("seed Id generated is not the same as the stored one")
+
 func GenerateKeysets(versionKey *hdkeychain.ExtendedKey, seed cashu.Seed) ([]cashu.MintKey, error) {
 	var keysets = make([]cashu.MintKey, len(seed.Amounts))
 
@@ -167,60 +171,81 @@ func getDerivationSteps(path string) ([]uint32, error) {
 	return derivationPaths, nil
 }
 
-func deriveSeed(seed cashu.Seed, mintKey *hdkeychain.ExtendedKey) ([]cashu.MintKey, error) {
+func deriveSeed(seed cashu.Seed, mintKey masterKey) ([]cashu.MintKey, error) {
 	if seed.Legacy {
-		legacyKey, err := legacyGetMintPrivateKey()
+		legacyKey, err := legacyMasterKey(mintKey.privateKey)
 		if err != nil {
-			return nil, fmt.Errorf("legacyGetMintPrivateKey(). %w", err)
+			return nil, fmt.Errorf("legacyMasterKey(mintKey.privateKey). %w", err)
 		}
 		defer func() {
 			legacyKey = nil
 		}()
 		return legacyDeriveKeyset(legacyKey, seed)
 	} else {
-		return DeriveKeyset(mintKey, seed)
+		return DeriveKeyset(mintKey.key, seed)
 	}
 }
 
-func GetKeysetsFromSeeds(seeds []cashu.Seed, mintKey *hdkeychain.ExtendedKey) (map[string]cashu.MintKeysMap, map[string]cashu.MintKeysMap, error) {
-	newKeysets := make(map[string]cashu.MintKeysMap)
-	newActiveKeysets := make(map[string]cashu.MintKeysMap)
+// checkSeedId derives the id of the keysets and compares it with the stored
+// one.
+func checkSeedId(seed cashu.Seed, keysets []cashu.MintKey) error {
+	justPubkeys := make([]*btcec.PublicKey, len(keysets))
+	pubkeysWithValues := make(map[uint64]*btcec.PublicKey, len(keysets))
+	for i := range keysets {
+		justPubkeys[i] = keysets[i].GetPubKey()
+		pubkeysWithValues[keysets[i].Amount] = keysets[i].GetPubKey()
+	}
 
-	for _, seed := range seeds {
-		keysets, err := deriveSeed(seed, mintKey)
+	newSeedId := ""
+	switch seed.Id[:2] {
+	case "00":
+		var err error
+		newSeedId, err = DeriveKeysetId(justPubkeys)
 		if err != nil {
-			return newKeysets, newActiveKeysets, fmt.Errorf("deriveSeed(seed, mintKey) %w", err)
+			return fmt.Errorf("cashu.DeriveKeysetId(justPubkeys) %w", err)
 		}
+	case "01":
+		var finalExpiry *time.Time = nil
 
-		justPubkeys := make([]*btcec.PublicKey, len(keysets))
-		pubkeysWithValues := make(map[uint64]*btcec.PublicKey, len(keysets))
-		for i := range keysets {
-			justPubkeys[i] = keysets[i].GetPubKey()
-			pubkeysWithValues[keysets[i].Amount] = keysets[i].GetPubKey()
+		if seed.FinalExpiry != nil {
+			timeUnix := time.Unix(int64(*seed.FinalExpiry), 0)
+			finalExpiry = &timeUnix
 		}
 
-		newSeedId := ""
-		switch seed.Id[:2] {
-		case "00":
-			newSeedId, err = DeriveKeysetId(justPubkeys)
-			if err != nil {
-				return nil, nil, fmt.Errorf("cashu.DeriveKeysetId(justPubkeys) %w", err)
-			}
-		case "01":
-			var finalExpiry *time.Time = nil
+		newSeedId = DeriveKeysetIdV2(pubkeysWithValues, seed.Unit, seed.InputFeePpk, finalExpiry)
+	default:
+		return fmt.Errorf("could not generate a seed id")
+	}
 
-			if seed.FinalExpiry != nil {
-				timeUnix := time.Unix(int64(*seed.FinalExpiry), 0)
-				finalExpiry = &timeUnix
-			}
+	if newSeedId != seed.Id {
+		return fmt.Errorf("%w. Stored: %v. Generated: %v", ErrSeedIdMismatch, seed.Id, newSeedId)
+	}
+	return nil
+}
 
-			newSeedId = DeriveKeysetIdV2(pubkeysWithValues, seed.Unit, seed.InputFeePpk, finalExpiry)
-		default:
-			return nil, nil, fmt.Errorf("could not generate a seed id")
-		}
+// GetKeysetsFromSeeds derives every seed with the master key it is tagged
+// with.
+func GetKeysetsFromSeeds(seeds []cashu.Seed, keys MasterKeys) (map[string]cashu.MintKeysMap, map[string]cashu.MintKeysMap, error) {
+	keysets, activeKeysets, _, err := loadSeeds(slices.Clone(seeds), keys)
+	return keysets, activeKeysets, err
+}
+
+// loadSeeds derives the seeds and tags the untagged ones with the master key
+// that derives them. It returns the seeds it tagged so they can be stored.
+func loadSeeds(seeds []cashu.Seed, keys MasterKeys) (map[string]cashu.MintKeysMap, map[string]cashu.MintKeysMap, []cashu.Seed, error) {
+	newKeysets := make(map[string]cashu.MintKeysMap)
+	newActiveKeysets := make(map[string]cashu.MintKeysMap)
+	var tagged []cashu.Seed
 
-		if newSeedId != seed.Id {
-			log.Panicf("seed Id generated is not the same as the stored one. \n Stored: %v. \n Generated: %v", seed.Id, newSeedId)
+	for i := range seeds {
+		seed := &seeds[i]
+		untagged := seed.MasterKeyId == ""
+		keysets, err := keys.deriveSeed(seed)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("keys.deriveSeed(seed) %w", err)
+		}
+		if untagged {
+			tagged = append(tagged, *seed)
 		}
 
 		mintkeyMap := make(cashu.MintKeysMap)
@@ -234,5 +259,5 @@ func GetKeysetsFromSeeds(seeds []cashu.Seed, mintKey *hdkeychain.ExtendedKey) (m
 
 		newKeysets[seed.Id] = mintkeyMap
 	}
-	return newKeysets, newActiveKeysets, nil
+	return newKeysets, newActiveKeysets, tagged, nil
 }
@@ -33,6 +33,7 @@ func TestGenerateKeysetsAndIdGeneration(t *testing.T) {
 		CreatedAt:      0,
 		Active:         false,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 
 	generatedKeysets, err := GenerateKeysets(key, seed)
@@ -89,6 +90,7 @@ func TestGeneratingAuthKeyset(t *testing.T) {
 		InputFeePpk:    0,
 		Active:         false,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 
 	generatedKeysets, err := DeriveKeyset(key, seedConfig)
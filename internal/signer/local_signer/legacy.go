@@ -1,9 +1,7 @@
 package localsigner
 
 import (
-	"encoding/hex"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
@@ -11,23 +9,10 @@ import (
 	"github.com/tyler-smith/go-bip32"
 )
 
-func legacyGetMintPrivateKey() (*bip32.Key, error) {
-	mint_privkey := os.Getenv("MINT_PRIVATE_KEY")
-	if mint_privkey == "" {
-		return nil, fmt.Errorf(`os.Getenv("MINT_PRIVATE_KEY")`)
-	}
-	defer func() {
-		mint_privkey = ""
-	}()
-
-	decodedPrivKey, err := hex.DecodeString(mint_privkey)
-	if err != nil {
-		return nil, fmt.Errorf(`hex.DecodeString(mint_privkey). %w`, err)
-	}
-	defer func() {
-		decodedPrivKey = nil
-	}()
-	mintKey := secp256k1.PrivKeyFromBytes(decodedPrivKey)
+// legacyMasterKey is the bip32 master key legacy seeds derive from, the same
+// private key through another library.
+func legacyMasterKey(privateKey []byte) (*bip32.Key, error) {
+	mintKey := secp256k1.PrivKeyFromBytes(privateKey)
 	defer func() {
 		mintKey = nil
 	}()
@@ -13,6 +13,8 @@ import (
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/lescuer97/nutmix/api/cashu"
 	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	"github.com/lescuer97/nutmix/internal/signer"
 	"github.com/tyler-smith/go-bip32"
 )
 
@@ -30,12 +32,12 @@ func TestRotateUnexistingSeedUnit(t *testing.T) {
 		t.Fatalf("getSignerPrivateKey failed: %v", err)
 	}
 
-	err = localsigner.RotateKeyset(cashu.Msat, uint(100), 240)
+	err = localsigner.RotateKeyset(cashu.Msat, uint(100), 240, nil)
 	if err != nil {
 		t.Fatalf("localsigner.RotateKeyset(cashu.Msat, uint(100)) %+v", err)
 	}
 
-	err = localsigner.RotateKeyset(cashu.Sat, uint(100), 240)
+	err = localsigner.RotateKeyset(cashu.Sat, uint(100), 240, nil)
 	if err != nil {
 		t.Fatalf("localsigner.RotateKeyset(cashu.Sat, uint(100)) %+v", err)
 	}
@@ -81,6 +83,93 @@ func TestRotateUnexistingSeedUnit(t *testing.T) {
 	}
 }
 
+func TestRotateKeysetWithFinalExpiry(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
+	localsigner, err := SetupLocalSigner(&db)
+	if err != nil {
+		t.Fatalf("SetupLocalSigner(&db) %+v", err)
+	}
+	err = localsigner.RotateKeyset(cashu.Sat, 0, 24, nil)
+	if err != nil {
+		t.Fatalf("localsigner.RotateKeyset(cashu.Sat, 0, 24) %+v", err)
+	}
+
+	keysets, err := localsigner.GetKeysets()
+	if err != nil {
+		t.Fatalf("localsigner.GetKeysets() %+v", err)
+	}
+	var expiry *uint64
+	for _, keyset := range keysets.Keysets {
+		if keyset.Active {
+			expiry = keyset.FinalExpiry
+		} else if keyset.FinalExpiry != nil {
+			t.Errorf("the first keyset was made without an expiry, got %d", *keyset.FinalExpiry)
+		}
+	}
+	want := time.Now().Add(24 * time.Hour).Unix()
+	if expiry == nil || int64(*expiry) < want-60 || int64(*expiry) > want {
+		t.Fatalf("expected the new keyset to expire in 24 hours, got %v", expiry)
+	}
+
+	// the expiry is part of the keyset id, so the seed has to derive it again
+	_, err = SetupLocalSigner(&db)
+	if err != nil {
+		t.Errorf("SetupLocalSigner(&db) after the rotation %+v", err)
+	}
+}
+
+func TestRotateKeysetWithCustomAmounts(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
+	localsigner, err := SetupLocalSigner(&db)
+	if err != nil {
+		t.Fatalf("SetupLocalSigner(&db) %+v", err)
+	}
+	err = localsigner.RotateKeyset(cashu.USD, 0, 0, []uint64{100, 25, 10, 5, 1})
+	if err != nil {
+		t.Fatalf("localsigner.RotateKeyset(cashu.USD, 0, 0, amounts) %+v", err)
+	}
+	err = localsigner.RotateKeyset(cashu.EUR, 0, 0, []uint64{5, 10})
+	if !errors.Is(err, cashu.ErrInvalidKeysetAmounts) {
+		t.Errorf("expected ErrInvalidKeysetAmounts without a 1, got %v", err)
+	}
+
+	keys, err := localsigner.GetActiveKeys()
+	if err != nil {
+		t.Fatalf("localsigner.GetActiveKeys() %+v", err)
+	}
+	var usd signer.KeysetResponse
+	for _, keyset := range keys.Keysets {
+		if keyset.Unit == cashu.USD.String() {
+			usd = keyset
+		}
+	}
+	if len(usd.Keys) != 5 || usd.Keys[25] == "" {
+		t.Fatalf("expected the usd keyset with the 5 amounts, got %v", usd.Keys)
+	}
+
+	blindingFactor, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("secp256k1.GeneratePrivateKey() %+v", err)
+	}
+	B_ := cashu.WrappedPublicKey{PublicKey: blindingFactor.PubKey()}
+	_, _, err = localsigner.SignBlindMessages(context.Background(), []cashu.BlindedMessage{{B_: B_, Id: usd.Id, Witness: "", Amount: 25}})
+	if err != nil {
+		t.Errorf("localsigner.SignBlindMessages(25) %+v", err)
+	}
+	_, _, err = localsigner.SignBlindMessages(context.Background(), []cashu.BlindedMessage{{B_: B_, Id: usd.Id, Witness: "", Amount: 2}})
+	if err == nil {
+		t.Errorf("expected an amount outside of the keyset to fail")
+	}
+
+	// the amounts are part of the keyset id, so the seed has to derive it again
+	_, err = SetupLocalSigner(&db)
+	if err != nil {
+		t.Errorf("SetupLocalSigner(&db) after the rotation %+v", err)
+	}
+}
+
 func TestCreateNewSeed(t *testing.T) {
 	db := mockdb.MockDB{} //nolint:exhaustruct
 	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
@@ -114,7 +203,7 @@ func TestRotateAuthSeedUnit(t *testing.T) {
 		t.Fatalf("getSignerPrivateKey failed: %v", err)
 	}
 
-	err = localsigner.RotateKeyset(cashu.AUTH, uint(100), 240)
+	err = localsigner.RotateKeyset(cashu.AUTH, uint(100), 240, nil)
 	if err != nil {
 		t.Fatalf("localsigner.RotateKeyset(cashu.Msat, uint(100)) %+v", err)
 	}
@@ -155,6 +244,7 @@ func TestBackwardCompatibilityV1Keysets(t *testing.T) {
 		Legacy:         true,
 		CreatedAt:      0,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 	db := mockdb.MockDB{ //nolint:exhaustruct
 		Seeds: []cashu.Seed{v1Seed},
@@ -205,6 +295,7 @@ func TestMixedV1AndV2Keysets(t *testing.T) {
 		DerivationPath: "1/1",
 		Legacy:         true,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 	db := mockdb.MockDB{ //nolint:exhaustruct
 		Seeds: []cashu.Seed{v1Seed},
@@ -217,7 +308,7 @@ func TestMixedV1AndV2Keysets(t *testing.T) {
 	}
 
 	// Rotate the keyset - new one should use V2
-	err = localsigner.RotateKeyset(cashu.Sat, uint(50), 240)
+	err = localsigner.RotateKeyset(cashu.Sat, uint(50), 240, nil)
 	if err != nil {
 		t.Fatalf("localsigner.RotateKeyset(cashu.Sat, uint(50), 240) %+v", err)
 	}
@@ -291,8 +382,9 @@ func TestSignBlindMessagesFailsForUnknownKeyset(t *testing.T) {
 		t.Fatalf("SetupLocalSigner(&db) %+v", err)
 	}
 
-	_, _, err = localsigner.SignBlindMessages([]cashu.BlindedMessage{{
-		B_:      cashu.WrappedPublicKey{PublicKey: nil},
+	key, _ := secp256k1.GeneratePrivateKey()
+	_, _, err = localsigner.SignBlindMessages(context.Background(), []cashu.BlindedMessage{{
+		B_:      cashu.WrappedPublicKey{PublicKey: key.PubKey()},
 		Id:      "missing-keyset",
 		Witness: "",
 		Amount:  1,
@@ -314,8 +406,9 @@ func TestVerifyProofsFailsForUnknownKeyset(t *testing.T) {
 		t.Fatalf("SetupLocalSigner(&db) %+v", err)
 	}
 
-	err = localsigner.VerifyProofs([]cashu.Proof{{
-		C:       cashu.WrappedPublicKey{PublicKey: nil},
+	key, _ := secp256k1.GeneratePrivateKey()
+	err = localsigner.VerifyProofs(context.Background(), []cashu.Proof{{
+		C:       cashu.WrappedPublicKey{PublicKey: key.PubKey()},
 		Y:       cashu.WrappedPublicKey{PublicKey: nil},
 		Quote:   nil,
 		Id:      "missing-keyset",
@@ -379,7 +472,7 @@ func TestDeriveKeysetSat(t *testing.T) {
 	version := uint32(1)
 	unit := cashu.Sat
 
-	keyDerivation := keyDerivation(uint(version), unit)
+	keyDerivation := KeyDerivation(uint(version), unit)
 	seed := cashu.Seed{
 		Active:         true,
 		CreatedAt:      time.Now().Unix(),
@@ -392,6 +485,7 @@ func TestDeriveKeysetSat(t *testing.T) {
 		FinalExpiry:    nil,
 		Legacy:         false,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 	privateKeyBytes, err := hex.DecodeString(MintPrivateKey)
 	if err != nil {
@@ -502,7 +596,7 @@ func TestDeriveKeysetSat(t *testing.T) {
 func TestDeriveKeysetAuth(t *testing.T) {
 	version := uint32(1)
 	unit := cashu.AUTH
-	keyDerivation := keyDerivation(uint(version), unit)
+	keyDerivation := KeyDerivation(uint(version), unit)
 	seed := cashu.Seed{
 		Active:         true,
 		CreatedAt:      time.Now().Unix(),
@@ -515,6 +609,7 @@ func TestDeriveKeysetAuth(t *testing.T) {
 		FinalExpiry:    nil,
 		Legacy:         false,
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 	privateKeyBytes, err := hex.DecodeString(MintPrivateKey)
 	if err != nil {
@@ -589,6 +684,7 @@ func TestLoadLegacyAndNonLegacySeeds(t *testing.T) {
 		FinalExpiry:    nil,
 		Id:             "",
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 
 	// Calculate correct ID for legacy seed
@@ -612,13 +708,14 @@ func TestLoadLegacyAndNonLegacySeeds(t *testing.T) {
 		CreatedAt:      time.Now().Unix(),
 		Version:        1,
 		Unit:           cashu.Sat.String(),
-		DerivationPath: keyDerivation(1, cashu.Sat),
+		DerivationPath: KeyDerivation(1, cashu.Sat),
 		InputFeePpk:    0,
 		Amounts:        cashu.GetAmountsForKeysets(cashu.LegacyMaxKeysetAmount),
 		Legacy:         false,
 		FinalExpiry:    nil,
 		Id:             "",
 		IssuerVersion:  nil,
+		MasterKeyId:    "",
 	}
 
 	// Calculate correct ID for non-legacy seed
@@ -677,3 +774,56 @@ func TestLoadLegacyAndNonLegacySeeds(t *testing.T) {
 		t.Errorf("Non-Legacy keyset ID %s not found in response", nonLegacyId)
 	}
 }
+
+func TestLockedSignerUnlocksKeystore(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	privateKey, err := hex.DecodeString(MintPrivateKey)
+	if err != nil {
+		t.Fatalf("hex.DecodeString(MintPrivateKey) %+v", err)
+	}
+	pubkey, err := SignerPubkey(privateKey)
+	if err != nil {
+		t.Fatalf("SignerPubkey(privateKey) %+v", err)
+	}
+	keystore, err := secrets.NewKeystore(privateKey, pubkey, "passphrase")
+	if err != nil {
+		t.Fatalf("secrets.NewKeystore %+v", err)
+	}
+
+	locked := NewLockedSigner(&db, keystore)
+	if !locked.Locked() {
+		t.Fatal("the signer should start locked")
+	}
+	_, err = locked.GetActiveKeys()
+	if !errors.Is(err, signer.ErrSignerLocked) {
+		t.Errorf("expected ErrSignerLocked, got %v", err)
+	}
+	lockedPubkey, err := locked.GetSignerPubkey()
+	if err != nil || lockedPubkey != pubkey {
+		t.Errorf("expected the keystore pubkey while locked, got %s %v", lockedPubkey, err)
+	}
+
+	err = locked.Unlock("wrong")
+	if !errors.Is(err, secrets.ErrWrongPassphrase) || !locked.Locked() {
+		t.Errorf("expected a wrong passphrase to keep it locked, got %v", err)
+	}
+	err = locked.Unlock("passphrase")
+	if err != nil {
+		t.Fatalf("locked.Unlock(passphrase) %+v", err)
+	}
+	keys, err := locked.GetActiveKeys()
+	if err != nil || len(keys.Keysets) != 1 {
+		t.Errorf("expected the keysets after unlocking, got %+v %v", keys, err)
+	}
+
+	// the keystore derives the same keysets as MINT_PRIVATE_KEY
+	t.Setenv("MINT_PRIVATE_KEY", MintPrivateKey)
+	fromEnv, err := SetupLocalSigner(&db)
+	if err != nil {
+		t.Fatalf("SetupLocalSigner(&db) %+v", err)
+	}
+	envKeys, err := fromEnv.GetActiveKeys()
+	if err != nil || envKeys.Keysets[0].Id != keys.Keysets[0].Id {
+		t.Errorf("expected the same keyset as MINT_PRIVATE_KEY, got %+v %v", envKeys, err)
+	}
+}
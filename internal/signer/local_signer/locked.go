@@ -0,0 +1,167 @@
+package localsigner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	"github.com/lescuer97/nutmix/internal/signer"
+)
+
+// LockedSigner is a memory signer whose master key is in an encrypted
+// keystore. Until it is unlocked it only knows the pubkey and every other call
+// returns signer.ErrSignerLocked.
+type LockedSigner struct {
+	db       database.MintDB
+	signer   *LocalSigner
+	keystore secrets.Keystore
+	// previous are the keystores of the master keys used before keystore,
+	// they unlock with the same passphrase.
+	previous []secrets.Keystore
+	mu       sync.RWMutex
+}
+
+func NewLockedSigner(db database.MintDB, keystore secrets.Keystore, previous ...secrets.Keystore) *LockedSigner {
+	return &LockedSigner{
+		db:       db,
+		signer:   nil,
+		keystore: keystore,
+		previous: previous,
+		mu:       sync.RWMutex{},
+	}
+}
+
+func (l *LockedSigner) Locked() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.signer == nil
+}
+
+// Unlock decrypts the keystore and loads the keysets. Unlocking an unlocked
+// signer does nothing.
+func (l *LockedSigner) Unlock(passphrase string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.signer != nil {
+		return nil
+	}
+	privateKey, err := l.keystore.Unlock(passphrase)
+	if err != nil {
+		return fmt.Errorf("l.keystore.Unlock(passphrase). %w", err)
+	}
+	previousKeys, err := secrets.UnlockKeystores(l.previous, passphrase)
+	if err != nil {
+		return fmt.Errorf("secrets.UnlockKeystores(l.previous, passphrase). %w", err)
+	}
+	local, err := SetupLocalSignerWithKeys(l.db, privateKey, previousKeys)
+	if err != nil {
+		return fmt.Errorf("SetupLocalSignerWithKeys(l.db, privateKey, previousKeys). %w", err)
+	}
+	pubkey, err := local.GetSignerPubkey()
+	if err != nil {
+		return fmt.Errorf("local.GetSignerPubkey(). %w", err)
+	}
+	if pubkey != l.keystore.Pubkey {
+		return fmt.Errorf("%w: the key does not match the pubkey %s", secrets.ErrInvalidKeystore, l.keystore.Pubkey)
+	}
+	l.signer = &local
+	return nil
+}
+
+func (l *LockedSigner) unlocked() (*LocalSigner, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.signer == nil {
+		return nil, signer.ErrSignerLocked
+	}
+	return l.signer, nil
+}
+
+func (l *LockedSigner) GetKeysets() (signer.GetKeysetsResponse, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return signer.GetKeysetsResponse{}, err
+	}
+	return local.GetKeysets()
+}
+
+func (l *LockedSigner) GetKeysById(id string) (signer.GetKeysResponse, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return signer.GetKeysResponse{}, err
+	}
+	return local.GetKeysById(id)
+}
+
+func (l *LockedSigner) GetActiveKeys() (signer.GetKeysResponse, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return signer.GetKeysResponse{}, err
+	}
+	return local.GetActiveKeys()
+}
+
+func (l *LockedSigner) GetAuthKeys() (signer.GetKeysetsResponse, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return signer.GetKeysetsResponse{}, err
+	}
+	return local.GetAuthKeys()
+}
+
+func (l *LockedSigner) GetAuthKeysById(id string) (signer.GetKeysResponse, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return signer.GetKeysResponse{}, err
+	}
+	return local.GetAuthKeysById(id)
+}
+
+func (l *LockedSigner) GetAuthActiveKeys() (signer.GetKeysResponse, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return signer.GetKeysResponse{}, err
+	}
+	return local.GetAuthActiveKeys()
+}
+
+func (l *LockedSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit uint, amounts []uint64) error {
+	local, err := l.unlocked()
+	if err != nil {
+		return err
+	}
+	return local.RotateKeyset(unit, fee, expiry_limit, amounts)
+}
+
+// GetSignerPubkey works while locked, the pubkey is stored in the clear in
+// the keystore.
+func (l *LockedSigner) GetSignerPubkey() (string, error) {
+	return l.keystore.Pubkey, nil
+}
+
+func (l *LockedSigner) SignMessage(hash []byte) ([]byte, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return nil, err
+	}
+	return local.SignMessage(hash)
+}
+
+func (l *LockedSigner) SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
+	local, err := l.unlocked()
+	if err != nil {
+		return nil, nil, err
+	}
+	return local.SignBlindMessages(ctx, messages)
+}
+
+func (l *LockedSigner) VerifyProofs(ctx context.Context, proofs []cashu.Proof) error {
+	local, err := l.unlocked()
+	if err != nil {
+		return err
+	}
+	return local.VerifyProofs(ctx, proofs)
+}
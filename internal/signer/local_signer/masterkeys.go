@@ -0,0 +1,152 @@
+package localsigner
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/lescuer97/nutmix/api/cashu"
+)
+
+// PreviousPrivateKeysEnv lists, comma separated in hex, the master keys the
+// mint used before MINT_PRIVATE_KEY. Their keysets stop signing but keep
+// verifying so the ecash from them can still be redeemed.
+const PreviousPrivateKeysEnv = "MINT_PREVIOUS_PRIVATE_KEYS"
+
+// ErrMasterKeyNotLoaded means a seed is tagged with a master key the signer
+// was not given. It has to stay loaded as a previous key until its keysets are
+// drained.
+var ErrMasterKeyNotLoaded = errors.New("the master key of the keyset is not loaded")
+
+type masterKey struct {
+	id         string
+	privateKey []byte
+	key        *hdkeychain.ExtendedKey
+}
+
+func newMasterKey(privateKey []byte) (masterKey, error) {
+	key, err := masterKeyFromBytes(privateKey)
+	if err != nil {
+		return masterKey{}, err
+	}
+	id, err := masterKeyId(key)
+	if err != nil {
+		return masterKey{}, err
+	}
+	return masterKey{id: id, privateKey: privateKey, key: key}, nil
+}
+
+// MasterKeys are the master keys a memory signer derives its seeds from. New
+// seeds use the active key, the previous keys only derive the seeds tagged
+// with them.
+type MasterKeys struct {
+	active   masterKey
+	previous []masterKey
+}
+
+func NewMasterKeys(active []byte, previous [][]byte) (MasterKeys, error) {
+	activeKey, err := newMasterKey(active)
+	if err != nil {
+		return MasterKeys{}, fmt.Errorf("newMasterKey(active). %w", err)
+	}
+	keys := MasterKeys{active: activeKey, previous: make([]masterKey, 0, len(previous))}
+	for i := range previous {
+		previousKey, err := newMasterKey(previous[i])
+		if err != nil {
+			return MasterKeys{}, fmt.Errorf("newMasterKey(previous[%d]). %w", i, err)
+		}
+		if _, exists := keys.byId(previousKey.id); exists {
+			continue
+		}
+		keys.previous = append(keys.previous, previousKey)
+	}
+	return keys, nil
+}
+
+// ActiveId is the id new seeds are tagged with.
+func (k MasterKeys) ActiveId() string {
+	return k.active.id
+}
+
+func (k MasterKeys) byId(id string) (masterKey, bool) {
+	if k.active.id == id {
+		return k.active, true
+	}
+	for _, key := range k.previous {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return masterKey{}, false
+}
+
+// deriveSeed derives seed with the master key it is tagged with. A seed from
+// before the tags is tried with every key, the active one first, and gets the
+// id of the key that derives it.
+func (k MasterKeys) deriveSeed(seed *cashu.Seed) ([]cashu.MintKey, error) {
+	if seed.MasterKeyId != "" {
+		key, exists := k.byId(seed.MasterKeyId)
+		if !exists {
+			return nil, fmt.Errorf("%w. Keyset: %s. Master key: %s", ErrMasterKeyNotLoaded, seed.Id, seed.MasterKeyId)
+		}
+		keysets, err := deriveSeed(*seed, key)
+		if err != nil {
+			return nil, err
+		}
+		return keysets, checkSeedId(*seed, keysets)
+	}
+
+	var firstErr error
+	for _, key := range append([]masterKey{k.active}, k.previous...) {
+		keysets, err := deriveSeed(*seed, key)
+		if err == nil {
+			err = checkSeedId(*seed, keysets)
+		}
+		if err == nil {
+			seed.MasterKeyId = key.id
+			return keysets, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// MasterKeyId is the BIP32 fingerprint of the master key, the first four bytes
+// of the hash160 of its pubkey. Seeds store it to know which key derives them.
+func MasterKeyId(privateKey []byte) (string, error) {
+	key, err := masterKeyFromBytes(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return masterKeyId(key)
+}
+
+func masterKeyId(key *hdkeychain.ExtendedKey) (string, error) {
+	pubkey, err := key.ECPubKey()
+	if err != nil {
+		return "", fmt.Errorf("key.ECPubKey(). %w", err)
+	}
+	return hex.EncodeToString(btcutil.Hash160(pubkey.SerializeCompressed())[:4]), nil
+}
+
+func previousKeysFromEnv() ([][]byte, error) {
+	var keys [][]byte
+	for value := range strings.SplitSeq(os.Getenv(PreviousPrivateKeysEnv), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		key, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: hex.DecodeString(key). %w", PreviousPrivateKeysEnv, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
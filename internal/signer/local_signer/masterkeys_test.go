@@ -0,0 +1,174 @@
+package localsigner
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/internal/secrets"
+	nutmixCrypto "github.com/lescuer97/nutmix/pkg/crypto"
+)
+
+const newMintPrivateKey string = "0000000000000000000000000000000000000000000000000000000000000002"
+
+func signedProofs(t *testing.T, localsigner *LocalSigner, keyset testKeyset) []cashu.Proof {
+	t.Helper()
+	batch := newBlindedBatch(t, keyset.id, 4)
+	sigs, _, err := localsigner.SignBlindMessages(t.Context(), batch.messages)
+	if err != nil {
+		t.Fatalf("localsigner.SignBlindMessages %+v", err)
+	}
+	proofs := make([]cashu.Proof, len(sigs))
+	for i := range sigs {
+		K, _ := hex.DecodeString(keyset.keys[sigs[i].Amount])
+		pubkey, _ := secp256k1.ParsePubKey(K)
+		C := nutmixCrypto.UnblindSignature(sigs[i].C_.PublicKey, batch.rs[i], pubkey)
+		proofs[i] = cashu.Proof{C: cashu.WrappedPublicKey{PublicKey: C}, Id: keyset.id, Secret: batch.secrets[i], Amount: sigs[i].Amount} //nolint:exhaustruct
+	}
+	return proofs
+}
+
+func TestMasterKeyRotation(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	oldKey, _ := hex.DecodeString(MintPrivateKey)
+	newKey, _ := hex.DecodeString(newMintPrivateKey)
+	oldId, err := MasterKeyId(oldKey)
+	if err != nil {
+		t.Fatalf("MasterKeyId(oldKey) %+v", err)
+	}
+	newId, _ := MasterKeyId(newKey)
+	if len(oldId) != 8 || oldId == newId {
+		t.Fatalf("expected two different fingerprints, got %s and %s", oldId, newId)
+	}
+
+	old, err := SetupLocalSignerWithKey(&db, oldKey)
+	if err != nil {
+		t.Fatalf("SetupLocalSignerWithKey(&db, oldKey) %+v", err)
+	}
+	err = old.RotateKeyset(cashu.Msat, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("old.RotateKeyset(cashu.Msat, 0, 0) %+v", err)
+	}
+	active, _ := old.GetActiveKeys()
+	oldKeyset := testKeyset{id: active.Keysets[0].Id, keys: active.Keysets[0].Keys}
+	proofs := signedProofs(t, &old, oldKeyset)
+	for _, seed := range db.Seeds {
+		if seed.MasterKeyId != oldId {
+			t.Fatalf("expected seed %s tagged with %s, got %q", seed.Id, oldId, seed.MasterKeyId)
+		}
+	}
+
+	// the old key has to stay loaded while its keysets hold ecash
+	_, err = SetupLocalSignerWithKey(&db, newKey)
+	if !errors.Is(err, ErrMasterKeyNotLoaded) {
+		t.Fatalf("expected ErrMasterKeyNotLoaded, got %v", err)
+	}
+
+	rotated, err := SetupLocalSignerWithKeys(&db, newKey, [][]byte{oldKey})
+	if err != nil {
+		t.Fatalf("SetupLocalSignerWithKeys(&db, newKey, oldKey) %+v", err)
+	}
+	if len(db.Seeds) != 4 {
+		t.Fatalf("expected a new sat and msat keyset, got %d seeds", len(db.Seeds))
+	}
+	for _, seed := range db.Seeds {
+		if seed.Active != (seed.MasterKeyId == newId) {
+			t.Errorf("expected only the keysets of the new key active, seed %s of %s active %t", seed.Id, seed.MasterKeyId, seed.Active)
+		}
+	}
+	newActive, _ := rotated.GetActiveKeys()
+	if len(newActive.Keysets) != 2 {
+		t.Fatalf("expected two active keysets, got %d", len(newActive.Keysets))
+	}
+	for _, keyset := range newActive.Keysets {
+		if keyset.Id == oldKeyset.id {
+			t.Errorf("keyset %s of the old key is still active", keyset.Id)
+		}
+	}
+
+	err = rotated.VerifyProofs(t.Context(), proofs)
+	if err != nil {
+		t.Errorf("ecash of the old key should keep verifying, got %v", err)
+	}
+	_, _, err = rotated.SignBlindMessages(t.Context(), newBlindedBatch(t, oldKeyset.id, 1).messages)
+	if !errors.Is(err, cashu.ErrKeysetNotKnow) {
+		t.Errorf("expected the old keyset to stop signing, got %v", err)
+	}
+	newPubkey, _ := SignerPubkey(newKey)
+	pubkey, _ := rotated.GetSignerPubkey()
+	if pubkey != newPubkey {
+		t.Errorf("expected the pubkey of the new key, got %s", pubkey)
+	}
+
+	verified, err := VerifySeeds(db.Seeds, newKey, [][]byte{oldKey})
+	if err != nil || verified != newPubkey {
+		t.Errorf("VerifySeeds(db.Seeds, newKey, oldKey) %s %v", verified, err)
+	}
+
+	// a second start does not rotate again
+	_, err = SetupLocalSignerWithKeys(&db, newKey, [][]byte{oldKey})
+	if err != nil || len(db.Seeds) != 4 {
+		t.Errorf("expected the same 4 seeds after a restart, got %d %v", len(db.Seeds), err)
+	}
+}
+
+func TestMasterKeyTagsUntaggedSeeds(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	oldKey, _ := hex.DecodeString(MintPrivateKey)
+	newKey, _ := hex.DecodeString(newMintPrivateKey)
+	_, err := SetupLocalSignerWithKey(&db, oldKey)
+	if err != nil {
+		t.Fatalf("SetupLocalSignerWithKey(&db, oldKey) %+v", err)
+	}
+	// seeds stored before the master key id existed
+	db.Seeds[0].MasterKeyId = ""
+
+	t.Setenv("MINT_PRIVATE_KEY", newMintPrivateKey)
+	t.Setenv(PreviousPrivateKeysEnv, MintPrivateKey)
+	_, err = SetupLocalSigner(&db)
+	if err != nil {
+		t.Fatalf("SetupLocalSigner(&db) %+v", err)
+	}
+	oldId, _ := MasterKeyId(oldKey)
+	newId, _ := MasterKeyId(newKey)
+	if db.Seeds[0].MasterKeyId != oldId || db.Seeds[0].Active {
+		t.Errorf("expected the old seed tagged with %s and inactive, got %+v", oldId, db.Seeds[0])
+	}
+	if len(db.Seeds) != 2 || db.Seeds[1].MasterKeyId != newId || !db.Seeds[1].Active {
+		t.Errorf("expected a new active seed of %s, got %+v", newId, db.Seeds)
+	}
+}
+
+func TestLockedSignerUnlocksPreviousKeystores(t *testing.T) {
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	oldKey, _ := hex.DecodeString(MintPrivateKey)
+	newKey, _ := hex.DecodeString(newMintPrivateKey)
+	_, err := SetupLocalSignerWithKey(&db, oldKey)
+	if err != nil {
+		t.Fatalf("SetupLocalSignerWithKey(&db, oldKey) %+v", err)
+	}
+
+	oldPubkey, _ := SignerPubkey(oldKey)
+	newPubkey, _ := SignerPubkey(newKey)
+	oldKeystore, err := secrets.NewKeystore(oldKey, oldPubkey, "passphrase")
+	if err != nil {
+		t.Fatalf("secrets.NewKeystore(oldKey) %+v", err)
+	}
+	newKeystore, err := secrets.NewKeystore(newKey, newPubkey, "passphrase")
+	if err != nil {
+		t.Fatalf("secrets.NewKeystore(newKey) %+v", err)
+	}
+
+	locked := NewLockedSigner(&db, newKeystore, oldKeystore)
+	err = locked.Unlock("passphrase")
+	if err != nil {
+		t.Fatalf("locked.Unlock(passphrase) %+v", err)
+	}
+	keysets, err := locked.GetKeysets()
+	if err != nil || len(keysets.Keysets) != 2 {
+		t.Errorf("expected the old and the new keyset, got %+v %v", keysets, err)
+	}
+}
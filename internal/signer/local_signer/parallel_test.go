@@ -0,0 +1,178 @@
+package localsigner
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	nutmixCrypto "github.com/lescuer97/nutmix/pkg/crypto"
+	"github.com/lescuer97/nutmix/pkg/crypto/cryptotest"
+)
+
+type blindedBatch struct {
+	messages []cashu.BlindedMessage
+	secrets  []string
+	rs       []*secp256k1.PrivateKey
+}
+
+func newBlindedBatch(t testing.TB, keysetId string, size int) blindedBatch {
+	t.Helper()
+	batch := blindedBatch{
+		messages: make([]cashu.BlindedMessage, size),
+		secrets:  make([]string, size),
+		rs:       make([]*secp256k1.PrivateKey, size),
+	}
+	for i := range size {
+		batch.secrets[i] = "parallel secret " + strconv.Itoa(i)
+		r, _ := secp256k1.GeneratePrivateKey()
+		B_, r, err := nutmixCrypto.BlindMessage(batch.secrets[i], r)
+		if err != nil {
+			t.Fatalf("nutmixCrypto.BlindMessage: %v", err)
+		}
+		batch.rs[i] = r
+		batch.messages[i] = cashu.BlindedMessage{B_: cashu.WrappedPublicKey{PublicKey: B_}, Id: keysetId, Witness: "", Amount: uint64(1) << (i % 10)}
+	}
+	return batch
+}
+
+func parallelTestSigner(t testing.TB) (*LocalSigner, testKeyset) {
+	t.Helper()
+	db := mockdb.MockDB{} //nolint:exhaustruct
+	privateKey, _ := hex.DecodeString(MintPrivateKey)
+	localsigner, err := SetupLocalSignerWithKey(&db, privateKey)
+	if err != nil {
+		t.Fatalf("SetupLocalSignerWithKey(&db, privateKey) %+v", err)
+	}
+	active, err := localsigner.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 {
+		t.Fatalf("localsigner.GetActiveKeys() %+v, %v", active, err)
+	}
+	return &localsigner, testKeyset{id: active.Keysets[0].Id, keys: active.Keysets[0].Keys}
+}
+
+type testKeyset struct {
+	keys map[uint64]string
+	id   string
+}
+
+func TestParallelSigningMatchesSerial(t *testing.T) {
+	localsigner, keyset := parallelTestSigner(t)
+	batch := newBlindedBatch(t, keyset.id, 200)
+
+	cryptotest.SetWorkers(t, 1)
+	serial, serialRecover, err := localsigner.SignBlindMessages(t.Context(), batch.messages)
+	if err != nil {
+		t.Fatalf("serial SignBlindMessages %+v", err)
+	}
+	nutmixCrypto.SetWorkers(8)
+	parallel, parallelRecover, err := localsigner.SignBlindMessages(t.Context(), batch.messages)
+	if err != nil {
+		t.Fatalf("parallel SignBlindMessages %+v", err)
+	}
+
+	proofs := make([]cashu.Proof, len(parallel))
+	for i := range parallel {
+		if !serial[i].C_.IsEqual(parallel[i].C_.PublicKey) || !serialRecover[i].C_.IsEqual(parallelRecover[i].C_.PublicKey) {
+			t.Fatalf("signature %d differs between the serial and parallel path", i)
+		}
+		if parallel[i].Amount != batch.messages[i].Amount || parallel[i].Id != keyset.id {
+			t.Fatalf("signature %d is out of order", i)
+		}
+		K, _ := hex.DecodeString(keyset.keys[parallel[i].Amount])
+		pubkey, _ := secp256k1.ParsePubKey(K)
+		valid, err := parallel[i].VerifyDLEQ(batch.messages[i].B_.PublicKey, parallel[i].Dleq.E, parallel[i].Dleq.S, pubkey)
+		if err != nil || !valid {
+			t.Fatalf("dleq of signature %d does not verify: %v", i, err)
+		}
+		C := nutmixCrypto.UnblindSignature(parallel[i].C_.PublicKey, batch.rs[i], pubkey)
+		proofs[i] = cashu.Proof{C: cashu.WrappedPublicKey{PublicKey: C}, Id: keyset.id, Secret: batch.secrets[i], Amount: parallel[i].Amount} //nolint:exhaustruct
+	}
+
+	err = localsigner.VerifyProofs(t.Context(), proofs)
+	if err != nil {
+		t.Errorf("parallel VerifyProofs %+v", err)
+	}
+
+	// both paths fail with the error of the first bad message and proof
+	badMessages := append([]cashu.BlindedMessage{}, batch.messages...)
+	badMessages[150].Id = "missing-keyset"
+	badMessages[120].Amount = 3
+	proofs[150].Secret = "another secret"
+	proofs[120].Id = "missing-keyset"
+	for _, workers := range []int{1, 8} {
+		nutmixCrypto.SetWorkers(workers)
+		_, _, err = localsigner.SignBlindMessages(t.Context(), badMessages)
+		if !errors.Is(err, cashu.ErrKeysetNotKnow) {
+			t.Errorf("workers %d: expected cashu.ErrKeysetNotKnow, got %v", workers, err)
+		}
+		err = localsigner.VerifyProofs(t.Context(), proofs)
+		if !errors.Is(err, cashu.ErrKeysetNotKnow) {
+			t.Errorf("workers %d: expected the missing keyset of proof 120 before the bad secret of 150, got %v", workers, err)
+		}
+	}
+}
+
+func TestParallelRejectsNilPoints(t *testing.T) {
+	localsigner, keyset := parallelTestSigner(t)
+	batch := newBlindedBatch(t, keyset.id, 20)
+	cryptotest.SetWorkers(t, 8)
+
+	batch.messages[10].B_.PublicKey = nil
+	_, _, err := localsigner.SignBlindMessages(t.Context(), batch.messages)
+	if !errors.Is(err, cashu.ErrInvalidBlindMessage) {
+		t.Errorf("expected cashu.ErrInvalidBlindMessage, got %v", err)
+	}
+	proofs := make([]cashu.Proof, 20)
+	for i := range proofs {
+		proofs[i] = cashu.Proof{Id: keyset.id, Secret: batch.secrets[i], Amount: 1} //nolint:exhaustruct
+	}
+	err = localsigner.VerifyProofs(t.Context(), proofs)
+	if !errors.Is(err, cashu.ErrInvalidProof) {
+		t.Errorf("expected cashu.ErrInvalidProof, got %v", err)
+	}
+}
+
+func benchmarkSignBlindMessages(b *testing.B, workers int) {
+	localsigner, keyset := parallelTestSigner(b)
+	batch := newBlindedBatch(b, keyset.id, 256)
+	cryptotest.SetWorkers(b, workers)
+	for b.Loop() {
+		_, _, err := localsigner.SignBlindMessages(b.Context(), batch.messages)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignBlindMessagesSerial(b *testing.B)   { benchmarkSignBlindMessages(b, 1) }
+func BenchmarkSignBlindMessagesParallel(b *testing.B) { benchmarkSignBlindMessages(b, 0) }
+
+func benchmarkVerifyProofs(b *testing.B, workers int) {
+	localsigner, keyset := parallelTestSigner(b)
+	batch := newBlindedBatch(b, keyset.id, 256)
+	sigs, _, err := localsigner.SignBlindMessages(b.Context(), batch.messages)
+	if err != nil {
+		b.Fatal(err)
+	}
+	proofs := make([]cashu.Proof, len(sigs))
+	for i := range sigs {
+		K, _ := hex.DecodeString(keyset.keys[sigs[i].Amount])
+		pubkey, _ := secp256k1.ParsePubKey(K)
+		C := nutmixCrypto.UnblindSignature(sigs[i].C_.PublicKey, batch.rs[i], pubkey)
+		proofs[i] = cashu.Proof{C: cashu.WrappedPublicKey{PublicKey: C}, Id: keyset.id, Secret: batch.secrets[i], Amount: sigs[i].Amount} //nolint:exhaustruct
+	}
+	cryptotest.SetWorkers(b, workers)
+	for b.Loop() {
+		err := localsigner.VerifyProofs(b.Context(), proofs)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyProofsSerial(b *testing.B)   { benchmarkVerifyProofs(b, 1) }
+func BenchmarkVerifyProofsParallel(b *testing.B) { benchmarkVerifyProofs(b, 0) }
@@ -12,7 +12,9 @@ import (
 
 const PeanutUTF8 = uint32(129372)
 
-func keyDerivation(version uint, unit cashu.Unit) string {
+// KeyDerivation is the path of the keyset of unit with version under the master
+// key, it is stored in the seed.
+func KeyDerivation(version uint, unit cashu.Unit) string {
 	unitInteger := parseUnitToIntegerReference(unit.String())
 	return fmt.Sprintf("%v'/%v'/%v'", PeanutUTF8, unitInteger, version)
 }
@@ -10,6 +10,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
@@ -19,6 +20,7 @@ import (
 	"github.com/lescuer97/nutmix/internal/database"
 	"github.com/lescuer97/nutmix/internal/signer"
 	"github.com/lescuer97/nutmix/internal/utils"
+	nutmixCrypto "github.com/lescuer97/nutmix/pkg/crypto"
 )
 
 type LocalSigner struct {
@@ -26,31 +28,52 @@ type LocalSigner struct {
 	keysets       map[string]cashu.MintKeysMap
 	db            database.MintDB
 	pubkey        *secp256k1.PublicKey
+	// privateKey is the master key from an unlocked keystore. When it is nil
+	// the key is read from MINT_PRIVATE_KEY.
+	privateKey []byte
+	// previousKeys are the master keys used before privateKey. When
+	// privateKey is nil they are read from MINT_PREVIOUS_PRIVATE_KEYS.
+	previousKeys [][]byte
 }
 
 func SetupLocalSigner(db database.MintDB) (LocalSigner, error) {
+	return SetupLocalSignerWithKeys(db, nil, nil)
+}
+
+// SetupLocalSignerWithKey uses privateKey as the master key instead of
+// MINT_PRIVATE_KEY, like the key of an unlocked keystore.
+func SetupLocalSignerWithKey(db database.MintDB, privateKey []byte) (LocalSigner, error) {
+	return SetupLocalSignerWithKeys(db, privateKey, nil)
+}
+
+// SetupLocalSignerWithKeys also loads the master keys the mint used before
+// privateKey. Their keysets keep verifying, and the active keysets that still
+// belong to one of them are rotated to privateKey.
+func SetupLocalSignerWithKeys(db database.MintDB, privateKey []byte, previousKeys [][]byte) (LocalSigner, error) {
 	localsigner := LocalSigner{
 		db:            db,
 		activeKeysets: make(map[string]cashu.MintKeysMap),
 		keysets:       make(map[string]cashu.MintKeysMap),
 		pubkey:        nil,
+		privateKey:    privateKey,
+		previousKeys:  previousKeys,
 	}
 
-	masterKey, err := localsigner.getSignerPrivateKey()
+	keys, err := localsigner.masterKeys()
 	if err != nil {
-		return localsigner, fmt.Errorf("signer.getSignerPrivateKey(). %w", err)
+		return localsigner, fmt.Errorf("signer.masterKeys(). %w", err)
 	}
 
 	seeds, err := localsigner.db.GetAllSeeds()
 	if err != nil {
 		return localsigner, fmt.Errorf("signer.db.GetAllSeeds(). %w", err)
 	}
-	pubkey, err := masterKey.ECPubKey()
+	pubkey, err := keys.active.key.ECPubKey()
 	if err != nil {
 		return localsigner, fmt.Errorf(`masterKey.ECPubKey(). %w`, err)
 	}
 	if len(seeds) == 0 {
-		newSeed, err := localsigner.createNewSeed(masterKey, cashu.Sat, 0, 0, nil)
+		newSeed, err := localsigner.createNewSeed(keys.active, cashu.Sat, 0, 0, nil, nil)
 
 		if err != nil {
 			return localsigner, fmt.Errorf("signer.createNewSeed(masterKey, 1, 0). %w", err)
@@ -62,9 +85,15 @@ func SetupLocalSigner(db database.MintDB) (LocalSigner, error) {
 		}
 		seeds = append(seeds, newSeed)
 	}
-	keysets, activeKeysets, err := GetKeysetsFromSeeds(seeds, masterKey)
+	keysets, activeKeysets, tagged, err := loadSeeds(seeds, keys)
 	if err != nil {
-		return localsigner, fmt.Errorf(`signer.GetKeysetsFromSeeds(seeds, masterKey). %w`, err)
+		return localsigner, fmt.Errorf(`loadSeeds(seeds, keys). %w`, err)
+	}
+	if len(tagged) > 0 {
+		err = localsigner.saveMasterKeyIds(tagged)
+		if err != nil {
+			return localsigner, fmt.Errorf(`signer.saveMasterKeyIds(tagged). %w`, err)
+		}
 	}
 
 	localsigner.keysets = keysets
@@ -72,9 +101,88 @@ func SetupLocalSigner(db database.MintDB) (LocalSigner, error) {
 	// already stored in signer.store earlier
 	localsigner.pubkey = pubkey
 
+	err = localsigner.rotateToActiveKey(seeds, keys.ActiveId())
+	if err != nil {
+		return localsigner, fmt.Errorf(`signer.rotateToActiveKey(seeds). %w`, err)
+	}
+
 	return localsigner, nil
 }
 
+func (l *LocalSigner) saveMasterKeyIds(seeds []cashu.Seed) error {
+	ctx := context.Background()
+	tx, err := l.db.GetTx(ctx)
+	if err != nil {
+		return fmt.Errorf("l.db.GetTx(ctx). %w", err)
+	}
+	defer func() {
+		err := l.db.Rollback(ctx, tx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Warn("tag seeds sql transaction error", slog.Any("error", err))
+		}
+	}()
+	err = l.db.UpdateSeedsMasterKeyId(tx, seeds)
+	if err != nil {
+		return fmt.Errorf("l.db.UpdateSeedsMasterKeyId(tx, seeds). %w", err)
+	}
+	err = l.db.Commit(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("l.db.Commit(ctx, tx). %w", err)
+	}
+	slog.Info("Tagged the keysets with their master key", slog.Int("keysets", len(seeds)))
+	return nil
+}
+
+// rotateToActiveKey moves every unit whose active keyset comes from a previous
+// master key to a new keyset of the active key, with the same fee and amounts.
+func (l *LocalSigner) rotateToActiveKey(seeds []cashu.Seed, activeId string) error {
+	rotated := make(map[string]bool)
+	for _, seed := range seeds {
+		if !seed.Active || seed.MasterKeyId == activeId || rotated[seed.Unit] {
+			continue
+		}
+		unit, err := cashu.UnitFromString(seed.Unit)
+		if err != nil {
+			return fmt.Errorf("cashu.UnitFromString(seed.Unit). %w", err)
+		}
+		err = l.RotateKeyset(unit, seed.InputFeePpk, 0, seed.Amounts)
+		if err != nil {
+			return fmt.Errorf("l.RotateKeyset(unit, seed.InputFeePpk, 0, seed.Amounts). %w", err)
+		}
+		rotated[seed.Unit] = true
+		slog.Info("Rotated the keyset to the new master key", slog.String("unit", seed.Unit), slog.String("keyset", seed.Id), slog.String("previous_master_key", seed.MasterKeyId), slog.String("master_key", activeId))
+	}
+	return nil
+}
+
+// VerifySeeds derives the stored seeds with privateKey and previousKeys, or
+// MINT_PRIVATE_KEY and MINT_PREVIOUS_PRIVATE_KEYS when privateKey is nil, and
+// returns the signer pubkey. Unlike SetupLocalSigner it never writes to the
+// database.
+func VerifySeeds(seeds []cashu.Seed, privateKey []byte, previousKeys [][]byte) (string, error) {
+	localsigner := LocalSigner{
+		db:            nil,
+		activeKeysets: nil,
+		keysets:       nil,
+		pubkey:        nil,
+		privateKey:    privateKey,
+		previousKeys:  previousKeys,
+	}
+	keys, err := localsigner.masterKeys()
+	if err != nil {
+		return "", fmt.Errorf("signer.masterKeys(). %w", err)
+	}
+	pubkey, err := keys.active.key.ECPubKey()
+	if err != nil {
+		return "", fmt.Errorf(`masterKey.ECPubKey(). %w`, err)
+	}
+	_, _, err = GetKeysetsFromSeeds(seeds, keys)
+	if err != nil {
+		return "", fmt.Errorf(`GetKeysetsFromSeeds(seeds, keys). %w`, err)
+	}
+	return hex.EncodeToString(pubkey.SerializeCompressed()), nil
+}
+
 // gets all active keys
 func (l *LocalSigner) GetActiveKeys() (signer.GetKeysResponse, error) {
 	// convert map to slice
@@ -125,6 +233,17 @@ func (l *LocalSigner) GetKeysets() (signer.GetKeysetsResponse, error) {
 }
 
 func (l *LocalSigner) getSignerPrivateKey() (*hdkeychain.ExtendedKey, error) {
+	privateKey, err := l.signerPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return masterKeyFromBytes(privateKey)
+}
+
+func (l *LocalSigner) signerPrivateKey() ([]byte, error) {
+	if l.privateKey != nil {
+		return l.privateKey, nil
+	}
 	mint_privkey := os.Getenv("MINT_PRIVATE_KEY")
 	if mint_privkey == "" {
 		return nil, fmt.Errorf(`os.Getenv("MINT_PRIVATE_KEY")`)
@@ -137,25 +256,56 @@ func (l *LocalSigner) getSignerPrivateKey() (*hdkeychain.ExtendedKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf(`hex.DecodeString(mint_privkey). %w`, err)
 	}
-	defer func() {
-		decodedPrivKey = nil
-	}()
+	return decodedPrivKey, nil
+}
 
-	masterKey, err := hdkeychain.NewMaster(decodedPrivKey, &chaincfg.MainNetParams)
+// masterKeys loads the active master key and the previous ones.
+func (l *LocalSigner) masterKeys() (MasterKeys, error) {
+	privateKey, err := l.signerPrivateKey()
+	if err != nil {
+		return MasterKeys{}, err
+	}
+	previousKeys := l.previousKeys
+	if l.privateKey == nil {
+		previousKeys, err = previousKeysFromEnv()
+		if err != nil {
+			return MasterKeys{}, err
+		}
+	}
+	return NewMasterKeys(privateKey, previousKeys)
+}
+
+func masterKeyFromBytes(privateKey []byte) (*hdkeychain.ExtendedKey, error) {
+	masterKey, err := hdkeychain.NewMaster(privateKey, &chaincfg.MainNetParams)
 	if err != nil {
 		return nil, fmt.Errorf(`hdkeychain.NewMaster(privateKey.Serialize(), &chaincfg.MainNetParams). %w`, err)
 	}
 	return masterKey, nil
 }
 
-func (l *LocalSigner) createNewSeed(mintPrivateKey *hdkeychain.ExtendedKey, unit cashu.Unit, version uint32, fee uint, final_expiry *time.Time) (cashu.Seed, error) {
-	// rotate one level up
-	amounts := cashu.GetAmountsForKeysets(cashu.MaxKeysetAmount)
+// SignerPubkey returns the pubkey a memory signer with privateKey announces.
+func SignerPubkey(privateKey []byte) (string, error) {
+	masterKey, err := masterKeyFromBytes(privateKey)
+	if err != nil {
+		return "", err
+	}
+	pubkey, err := masterKey.ECPubKey()
+	if err != nil {
+		return "", fmt.Errorf(`masterKey.ECPubKey(). %w`, err)
+	}
+	return hex.EncodeToString(pubkey.SerializeCompressed()), nil
+}
+
+func (l *LocalSigner) createNewSeed(mintPrivateKey masterKey, unit cashu.Unit, version uint32, fee uint, final_expiry *time.Time, amounts []uint64) (cashu.Seed, error) {
+	amounts, err := cashu.KeysetAmounts(amounts)
+	if err != nil {
+		return cashu.Seed{}, fmt.Errorf("cashu.KeysetAmounts(amounts). %w", err)
+	}
 	if unit == cashu.AUTH {
 		amounts = []uint64{amounts[0]}
 	}
 
-	keyDerivation := keyDerivation(uint(version), unit)
+	keyDerivation := KeyDerivation(uint(version), unit)
 	// rotate one level up
 	newSeed := cashu.Seed{
 		CreatedAt:      time.Now().Unix(),
@@ -169,9 +319,10 @@ func (l *LocalSigner) createNewSeed(mintPrivateKey *hdkeychain.ExtendedKey, unit
 		Amounts:        amounts,
 		Legacy:         false,
 		IssuerVersion:  &utils.Version,
+		MasterKeyId:    mintPrivateKey.id,
 	}
 
-	keysets, err := DeriveKeyset(mintPrivateKey, newSeed)
+	keysets, err := DeriveKeyset(mintPrivateKey.key, newSeed)
 	if err != nil {
 		return newSeed, fmt.Errorf("DeriveKeyset(mintPrivateKey, newSeed) %w", err)
 	}
@@ -188,7 +339,7 @@ func (l *LocalSigner) createNewSeed(mintPrivateKey *hdkeychain.ExtendedKey, unit
 	return newSeed, nil
 }
 
-func (l *LocalSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours uint) error {
+func (l *LocalSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours uint, amounts []uint64) error {
 	ctx := context.Background()
 	tx, err := l.db.GetTx(ctx)
 	if err != nil {
@@ -221,23 +372,21 @@ func (l *LocalSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours
 		seeds[i].Active = false
 	}
 
-	signerMasterKey, err := l.getSignerPrivateKey()
+	keys, err := l.masterKeys()
 	if err != nil {
-		return fmt.Errorf(`l.getSignerPrivateKey() %w`, err)
+		return fmt.Errorf(`l.masterKeys() %w`, err)
 	}
-	defer func() {
-		signerMasterKey = nil
-	}()
 
-	var now *time.Time
-	// if expiry_limit_hours > 0 {
-	// 	nowTime := time.Now().Add(time.Duration(expiry_limit_hours) * time.Hour)
-	// 	now = &nowTime
-	// }
+	// a zero expiry limit keeps the keyset valid forever
+	var finalExpiry *time.Time
+	if expiry_limit_hours > 0 {
+		expiry := time.Now().Add(time.Duration(expiry_limit_hours) * time.Hour)
+		finalExpiry = &expiry
+	}
 	// Create New seed with one higher version
-	newSeed, err := l.createNewSeed(signerMasterKey, unit, highestSeedVersion, fee, now)
+	newSeed, err := l.createNewSeed(keys.active, unit, highestSeedVersion, fee, finalExpiry, amounts)
 	if err != nil {
-		return fmt.Errorf(`l.createNewSeed(signerMasterKey, unit, highestSeed.Version+1, fee) %w`, err)
+		return fmt.Errorf(`l.createNewSeed(keys.active, unit, highestSeed.Version+1, fee, finalExpiry, amounts) %w`, err)
 	}
 
 	// add new key to db
@@ -263,7 +412,7 @@ func (l *LocalSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours
 		return fmt.Errorf("signer.db.GetAllSeeds(). %w", err)
 	}
 
-	keysets, activeKeysets, err := GetKeysetsFromSeeds(seeds, signerMasterKey)
+	keysets, activeKeysets, err := GetKeysetsFromSeeds(seeds, keys)
 	if err != nil {
 		return fmt.Errorf(`m.DeriveKeysetFromSeeds(seeds, parsedPrivateKey). %w`, err)
 	}
@@ -274,28 +423,40 @@ func (l *LocalSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours
 	return nil
 }
 
-func (l *LocalSigner) SignBlindMessages(messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
+// SignBlindMessages signs the messages on the workers of crypto.ForEach, an
+// error is the one of the first message that failed.
+func (l *LocalSigner) SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
 	var blindedSignatures = make([]cashu.BlindSignature, len(messages))
 	var recoverSigDB = make([]cashu.RecoverSigDB, len(messages))
+	for _, output := range messages {
+		if output.B_.PublicKey == nil {
+			return nil, nil, cashu.ErrInvalidBlindMessage
+		}
+	}
 
-	for i, output := range messages {
+	err := nutmixCrypto.ForEach(len(messages), func(i int) error {
+		output := messages[i]
 		keysetsByAmount, exists := l.activeKeysets[output.Id]
 		if !exists {
-			return nil, nil, cashu.ErrKeysetNotKnow
+			return cashu.ErrKeysetNotKnow
 		}
 
 		correctKeyset, exists := keysetsByAmount[output.Amount]
 		if !exists || correctKeyset.PrivKey == nil {
-			return nil, nil, cashu.ErrKeysetNotKnow
+			return cashu.ErrKeysetNotKnow
 		}
 
 		if !correctKeyset.Active {
-			return nil, nil, cashu.ErrUsingInactiveKeyset
+			return cashu.ErrUsingInactiveKeyset
 		}
 
 		blindSignature, err := output.GenerateBlindSignature(correctKeyset.PrivKey)
+		if err != nil {
+			return errors.Join(cashu.ErrInvalidBlindMessage, err)
+		}
 
-		recoverySig := cashu.RecoverSigDB{
+		blindedSignatures[i] = blindSignature
+		recoverSigDB[i] = cashu.RecoverSigDB{
 			Amount:    output.Amount,
 			Id:        output.Id,
 			C_:        blindSignature.C_,
@@ -304,27 +465,27 @@ func (l *LocalSigner) SignBlindMessages(messages []cashu.BlindedMessage) ([]cash
 			CreatedAt: time.Now().Unix(),
 			MeltQuote: "",
 		}
-
-		if err != nil {
-			err = errors.Join(cashu.ErrInvalidBlindMessage, err)
-			return nil, nil, err
-		}
-
-		blindedSignatures[i] = blindSignature
-		recoverSigDB[i] = recoverySig
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 	return blindedSignatures, recoverSigDB, nil
 }
 
-func (l *LocalSigner) VerifyProofs(proofs []cashu.Proof) error {
+func (l *LocalSigner) VerifyProofs(ctx context.Context, proofs []cashu.Proof) error {
 	for _, proof := range proofs {
-		err := l.validateProof(proof)
+		if proof.C.PublicKey == nil {
+			return cashu.ErrInvalidProof
+		}
+	}
+	return nutmixCrypto.ForEach(len(proofs), func(i int) error {
+		err := l.validateProof(proofs[i])
 		if err != nil {
 			return fmt.Errorf("l.validateProof(proof, unit, &checkOutputs, &pubkeysFromProofs): %w", err)
 		}
-	}
-
-	return nil
+		return nil
+	})
 }
 
 func (l *LocalSigner) validateProof(proof cashu.Proof) error {
@@ -357,6 +518,24 @@ func (l *LocalSigner) GetSignerPubkey() (string, error) {
 	return hex.EncodeToString(l.pubkey.SerializeCompressed()), nil
 }
 
+// SignMessage signs hash with the active master key, the key of the signer
+// pubkey.
+func (l *LocalSigner) SignMessage(hash []byte) ([]byte, error) {
+	keys, err := l.masterKeys()
+	if err != nil {
+		return nil, fmt.Errorf("l.masterKeys(). %w", err)
+	}
+	privateKey, err := keys.active.key.ECPrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("keys.active.key.ECPrivKey(). %w", err)
+	}
+	signature, err := schnorr.Sign(privateKey, hash)
+	if err != nil {
+		return nil, fmt.Errorf("schnorr.Sign(privateKey, hash). %w", err)
+	}
+	return signature.Serialize(), nil
+}
+
 // gets all active keys
 func (l *LocalSigner) GetAuthActiveKeys() (signer.GetKeysResponse, error) {
 	// convert map to slice
@@ -0,0 +1,49 @@
+// Package pkcs11signer keeps the private keys of the keysets in a PKCS#11
+// token, like an HSM or SoftHSM. The keys are generated in the token and can
+// not be extracted, the signer only asks the token for ECDH with them.
+package pkcs11signer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	ModuleEnv     = "PKCS11_MODULE"
+	TokenLabelEnv = "PKCS11_TOKEN_LABEL"
+	PinEnv        = "PKCS11_PIN"
+)
+
+var (
+	ErrCgoRequired       = errors.New("the pkcs11 signer needs a nutmix built with cgo")
+	ErrTokenNotFound     = errors.New("no pkcs11 token with that label")
+	ErrMissingTokenKey   = errors.New("the token does not have a key of the keyset")
+	ErrLegacySeed        = errors.New("legacy keysets can not be served from a pkcs11 token")
+	ErrPointNotRecovered = errors.New("the ecdh result of the token does not match any point")
+)
+
+// Config says which token holds the keys.
+type Config struct {
+	// Module is the path of the PKCS#11 library, like
+	// /usr/lib/softhsm/libsofthsm2.so.
+	Module     string
+	TokenLabel string
+	Pin        string
+}
+
+// ConfigFromEnv reads PKCS11_MODULE, PKCS11_TOKEN_LABEL and PKCS11_PIN.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Module:     os.Getenv(ModuleEnv),
+		TokenLabel: os.Getenv(TokenLabelEnv),
+		Pin:        os.Getenv(PinEnv),
+	}
+	if config.Module == "" {
+		return config, fmt.Errorf("%s is empty", ModuleEnv)
+	}
+	if config.TokenLabel == "" {
+		return config, fmt.Errorf("%s is empty", TokenLabelEnv)
+	}
+	return config, nil
+}
@@ -0,0 +1,23 @@
+//go:build !cgo
+
+package pkcs11signer
+
+import (
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/signer"
+)
+
+// Pkcs11Signer needs cgo to load the PKCS#11 module, builds without it can
+// not create one.
+type Pkcs11Signer struct {
+	signer.Signer
+}
+
+func SetupPkcs11Signer(db database.MintDB, config Config) (*Pkcs11Signer, error) {
+	return nil, ErrCgoRequired
+}
+
+func VerifySeeds(config Config, seeds []cashu.Seed) (string, error) {
+	return "", ErrCgoRequired
+}
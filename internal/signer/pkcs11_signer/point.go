@@ -0,0 +1,92 @@
+package pkcs11signer
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+)
+
+// identityLabel is the CKA_LABEL of the key the signer announces as its pubkey.
+const identityLabel = "nutmix signer"
+
+// secp256k1Params is the CKA_EC_PARAMS of the keys, the DER encoded OID of
+// secp256k1.
+var secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// keyLabel is the CKA_LABEL of the keys of a seed. Keys imported with the
+// tools of the HSM vendor have to use it too.
+func keyLabel(seed cashu.Seed) string {
+	return "nutmix " + seed.DerivationPath
+}
+
+// keyId is the CKA_ID of the key for amount inside its keyset.
+func keyId(amount uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, amount)
+}
+
+func amountFromKeyId(id []byte) (uint64, error) {
+	if len(id) != 8 {
+		return 0, fmt.Errorf("CKA_ID %x is not an amount", id)
+	}
+	return binary.BigEndian.Uint64(id), nil
+}
+
+// parseECPoint reads CKA_EC_POINT, a DER octet string with the uncompressed
+// point. Some tokens return the point without the octet string.
+func parseECPoint(value []byte) (*secp256k1.PublicKey, error) {
+	if len(value) == 67 && value[0] == 0x04 && value[1] == 65 {
+		value = value[2:]
+	}
+	pubkey, err := secp256k1.ParsePubKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("secp256k1.ParsePubKey(value). %w", err)
+	}
+	return pubkey, nil
+}
+
+// plusGenerator returns P + G.
+func plusGenerator(point *secp256k1.PublicKey) (*secp256k1.PublicKey, error) {
+	var p, g, sum secp256k1.JacobianPoint
+	point.AsJacobian(&p)
+	secp256k1.ScalarBaseMultNonConst(new(secp256k1.ModNScalar).SetInt(1), &g)
+	secp256k1.AddNonConst(&p, &g, &sum)
+	if sum.Z.IsZero() {
+		return nil, cashu.ErrInvalidBlindMessage
+	}
+	sum.ToAffine()
+	return secp256k1.NewPublicKey(&sum.X, &sum.Y), nil
+}
+
+// recoverPoint finds k*P from the x coordinates of k*P and k*(P+G) the token
+// answers ECDH with, where K is k*G. The x coordinate only fixes k*P up to its
+// sign, the right point is the one where k*P + K has the x of k*(P+G).
+func recoverPoint(K *secp256k1.PublicKey, x []byte, xPlusK []byte) (*secp256k1.PublicKey, error) {
+	var fx, y, want secp256k1.FieldVal
+	if overflow := fx.SetByteSlice(x); overflow {
+		return nil, ErrPointNotRecovered
+	}
+	if overflow := want.SetByteSlice(xPlusK); overflow {
+		return nil, ErrPointNotRecovered
+	}
+	if !secp256k1.DecompressY(&fx, false, &y) {
+		return nil, ErrPointNotRecovered
+	}
+	y.Normalize()
+
+	var k, sum secp256k1.JacobianPoint
+	K.AsJacobian(&k)
+	for range 2 {
+		candidate := secp256k1.MakeJacobianPoint(&fx, &y, new(secp256k1.FieldVal).SetInt(1))
+		secp256k1.AddNonConst(&candidate, &k, &sum)
+		if !sum.Z.IsZero() {
+			sum.ToAffine()
+			if sum.X.Equals(&want) {
+				return secp256k1.NewPublicKey(&fx, &y), nil
+			}
+		}
+		y.Negate(1).Normalize()
+	}
+	return nil, ErrPointNotRecovered
+}
@@ -0,0 +1,72 @@
+package pkcs11signer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func xOf(t *testing.T, k *secp256k1.PrivateKey, point *secp256k1.PublicKey) []byte {
+	t.Helper()
+	var p, result secp256k1.JacobianPoint
+	point.AsJacobian(&p)
+	secp256k1.ScalarMultNonConst(&k.Key, &p, &result)
+	result.ToAffine()
+	x := result.X.Bytes()
+	return x[:]
+}
+
+func TestRecoverPoint(t *testing.T) {
+	for range 20 {
+		k, _ := secp256k1.GeneratePrivateKey()
+		p, _ := secp256k1.GeneratePrivateKey()
+		point := p.PubKey()
+		pointPlusG, err := plusGenerator(point)
+		if err != nil {
+			t.Fatalf("plusGenerator(point): %v", err)
+		}
+
+		recovered, err := recoverPoint(k.PubKey(), xOf(t, k, point), xOf(t, k, pointPlusG))
+		if err != nil {
+			t.Fatalf("recoverPoint: %v", err)
+		}
+		var jacobian, expected secp256k1.JacobianPoint
+		point.AsJacobian(&jacobian)
+		secp256k1.ScalarMultNonConst(&k.Key, &jacobian, &expected)
+		expected.ToAffine()
+		if !recovered.IsEqual(secp256k1.NewPublicKey(&expected.X, &expected.Y)) {
+			t.Fatalf("recovered the wrong point")
+		}
+	}
+
+	k, _ := secp256k1.GeneratePrivateKey()
+	other, _ := secp256k1.GeneratePrivateKey()
+	point := other.PubKey()
+	pointPlusG, _ := plusGenerator(point)
+	_, err := recoverPoint(other.PubKey(), xOf(t, k, point), xOf(t, k, pointPlusG))
+	if !errors.Is(err, ErrPointNotRecovered) {
+		t.Errorf("expected ErrPointNotRecovered with another key, got %v", err)
+	}
+}
+
+func TestParseECPoint(t *testing.T) {
+	k, _ := secp256k1.GeneratePrivateKey()
+	uncompressed := k.PubKey().SerializeUncompressed()
+	der := append([]byte{0x04, byte(len(uncompressed))}, uncompressed...)
+	for _, value := range [][]byte{der, uncompressed} {
+		pubkey, err := parseECPoint(value)
+		if err != nil {
+			t.Fatalf("parseECPoint(%x): %v", value, err)
+		}
+		if !pubkey.IsEqual(k.PubKey()) {
+			t.Errorf("parseECPoint(%x) returned another pubkey", value)
+		}
+	}
+
+	minusG := secp256k1.NewPrivateKey(new(secp256k1.ModNScalar).SetInt(1).Negate()).PubKey()
+	_, err := plusGenerator(minusG)
+	if err == nil {
+		t.Errorf("expected -G + G to fail")
+	}
+}
@@ -0,0 +1,477 @@
+//go:build cgo
+
+package pkcs11signer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/internal/database"
+	"github.com/lescuer97/nutmix/internal/signer"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	"github.com/lescuer97/nutmix/internal/utils"
+	"github.com/lescuer97/nutmix/pkg/crypto"
+)
+
+// keyset is a seed and the keys of its amounts in the token.
+type keyset struct {
+	keys map[uint64]tokenKey
+	seed cashu.Seed
+}
+
+// Pkcs11Signer signs with keys that live in a PKCS#11 token. The seeds in the
+// database keep the metadata of the keysets, the keys are found in the token
+// by the label of the derivation path and the amount as CKA_ID.
+//
+// PKCS#11 has no mechanism that reveals e*k for a key that can not leave the
+// token, so the signatures carry no DLEQ proof of NUT-12.
+type Pkcs11Signer struct {
+	activeKeysets map[string]keyset
+	keysets       map[string]keyset
+	db            database.MintDB
+	token         *token
+	pubkey        *secp256k1.PublicKey
+	mu            sync.RWMutex
+}
+
+func SetupPkcs11Signer(db database.MintDB, config Config) (*Pkcs11Signer, error) {
+	t, err := openToken(config)
+	if err != nil {
+		return nil, fmt.Errorf("openToken(config). %w", err)
+	}
+	p := &Pkcs11Signer{
+		activeKeysets: make(map[string]keyset),
+		keysets:       make(map[string]keyset),
+		db:            db,
+		token:         t,
+		pubkey:        nil,
+		mu:            sync.RWMutex{},
+	}
+	err = p.setup()
+	if err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pkcs11Signer) setup() error {
+	identity, err := p.token.keys(identityLabel)
+	if err != nil {
+		return fmt.Errorf("p.token.keys(identityLabel). %w", err)
+	}
+	key, ok := identity[string(keyId(0))]
+	if !ok {
+		key, err = p.token.generate(identityLabel, keyId(0))
+		if err != nil {
+			return fmt.Errorf("p.token.generate(identityLabel, keyId(0)). %w", err)
+		}
+	}
+	p.pubkey = key.pubkey
+
+	seeds, err := p.db.GetAllSeeds()
+	if err != nil {
+		return fmt.Errorf("p.db.GetAllSeeds(). %w", err)
+	}
+	if len(seeds) == 0 {
+		newSeed, err := p.createNewSeed(cashu.Sat, 0, 0, nil, nil)
+		if err != nil {
+			return fmt.Errorf("p.createNewSeed(cashu.Sat, 0, 0, nil, nil). %w", err)
+		}
+		err = p.db.SaveNewSeeds([]cashu.Seed{newSeed})
+		if err != nil {
+			return fmt.Errorf("p.db.SaveNewSeeds([]cashu.Seed{newSeed}). %w", err)
+		}
+		seeds = append(seeds, newSeed)
+	}
+	keysets, activeKeysets, err := keysetsFromSeeds(p.token, seeds)
+	if err != nil {
+		return fmt.Errorf("keysetsFromSeeds(p.token, seeds). %w", err)
+	}
+	p.keysets = keysets
+	p.activeKeysets = activeKeysets
+	return nil
+}
+
+// VerifySeeds checks the token has the keys of every seed and returns the
+// pubkey of the signer. Unlike SetupPkcs11Signer it never creates a key.
+func VerifySeeds(config Config, seeds []cashu.Seed) (string, error) {
+	t, err := openToken(config)
+	if err != nil {
+		return "", fmt.Errorf("openToken(config). %w", err)
+	}
+	defer func() {
+		_ = t.Close()
+	}()
+	identity, err := t.keys(identityLabel)
+	if err != nil {
+		return "", fmt.Errorf("t.keys(identityLabel). %w", err)
+	}
+	key, ok := identity[string(keyId(0))]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMissingTokenKey, identityLabel)
+	}
+	_, _, err = keysetsFromSeeds(t, seeds)
+	if err != nil {
+		return "", fmt.Errorf("keysetsFromSeeds(t, seeds). %w", err)
+	}
+	return hex.EncodeToString(key.pubkey.SerializeCompressed()), nil
+}
+
+// keysetsFromSeeds finds the keys of the seeds in the token and checks they
+// still make the keyset id of the seed.
+func keysetsFromSeeds(t *token, seeds []cashu.Seed) (map[string]keyset, map[string]keyset, error) {
+	keysets := make(map[string]keyset)
+	activeKeysets := make(map[string]keyset)
+	for _, seed := range seeds {
+		if seed.Legacy {
+			return nil, nil, fmt.Errorf("%w: %s", ErrLegacySeed, seed.Id)
+		}
+		tokenKeys, err := t.keys(keyLabel(seed))
+		if err != nil {
+			return nil, nil, fmt.Errorf("t.keys(keyLabel(seed)). %w", err)
+		}
+		keys := make(map[uint64]tokenKey, len(seed.Amounts))
+		pubkeys := make(map[uint64]*secp256k1.PublicKey, len(seed.Amounts))
+		for _, amount := range seed.Amounts {
+			key, ok := tokenKeys[string(keyId(amount))]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %s amount %d", ErrMissingTokenKey, seed.Id, amount)
+			}
+			keys[amount] = key
+			pubkeys[amount] = key.pubkey
+		}
+		id, err := seedId(seed, pubkeys)
+		if err != nil {
+			return nil, nil, err
+		}
+		if id != seed.Id {
+			return nil, nil, fmt.Errorf("%w. Stored: %v. Generated: %v", localsigner.ErrSeedIdMismatch, seed.Id, id)
+		}
+
+		keysets[seed.Id] = keyset{keys: keys, seed: seed}
+		if seed.Active {
+			activeKeysets[seed.Id] = keysets[seed.Id]
+		}
+	}
+	return keysets, activeKeysets, nil
+}
+
+func seedId(seed cashu.Seed, pubkeys map[uint64]*secp256k1.PublicKey) (string, error) {
+	if len(seed.Id) < 2 {
+		return "", fmt.Errorf("could not generate a seed id")
+	}
+	switch seed.Id[:2] {
+	case "00":
+		amounts := slices.Sorted(maps.Keys(pubkeys))
+		sortedPubkeys := make([]*secp256k1.PublicKey, len(amounts))
+		for i, amount := range amounts {
+			sortedPubkeys[i] = pubkeys[amount]
+		}
+		return localsigner.DeriveKeysetId(sortedPubkeys)
+	case "01":
+		var finalExpiry *time.Time
+		if seed.FinalExpiry != nil {
+			timeUnix := time.Unix(int64(*seed.FinalExpiry), 0)
+			finalExpiry = &timeUnix
+		}
+		return localsigner.DeriveKeysetIdV2(pubkeys, seed.Unit, seed.InputFeePpk, finalExpiry), nil
+	default:
+		return "", fmt.Errorf("could not generate a seed id")
+	}
+}
+
+// createNewSeed generates the keys of a new keyset in the token. Keys a failed
+// rotation left with the same label are used again.
+func (p *Pkcs11Signer) createNewSeed(unit cashu.Unit, version uint32, fee uint, finalExpiry *time.Time, amounts []uint64) (cashu.Seed, error) {
+	amounts, err := cashu.KeysetAmounts(amounts)
+	if err != nil {
+		return cashu.Seed{}, fmt.Errorf("cashu.KeysetAmounts(amounts). %w", err)
+	}
+	if unit == cashu.AUTH {
+		amounts = []uint64{amounts[0]}
+	}
+	newSeed := cashu.Seed{
+		CreatedAt:      time.Now().Unix(),
+		DerivationPath: localsigner.KeyDerivation(uint(version), unit),
+		Active:         true,
+		Version:        version,
+		Unit:           unit.String(),
+		InputFeePpk:    fee,
+		FinalExpiry:    nil,
+		Id:             "",
+		Amounts:        amounts,
+		Legacy:         false,
+		IssuerVersion:  &utils.Version,
+		MasterKeyId:    "",
+	}
+
+	label := keyLabel(newSeed)
+	existing, err := p.token.keys(label)
+	if err != nil {
+		return newSeed, fmt.Errorf("p.token.keys(label). %w", err)
+	}
+	pubkeys := make(map[uint64]*secp256k1.PublicKey, len(amounts))
+	for _, amount := range amounts {
+		key, ok := existing[string(keyId(amount))]
+		if !ok {
+			key, err = p.token.generate(label, keyId(amount))
+			if err != nil {
+				return newSeed, fmt.Errorf("p.token.generate(label, keyId(%d)). %w", amount, err)
+			}
+		}
+		pubkeys[amount] = key.pubkey
+	}
+	newSeed.Id = cashu.DeriveKeysetIdV2(pubkeys, unit.String(), fee, finalExpiry)
+	if finalExpiry != nil {
+		timestamp := uint64(finalExpiry.Unix())
+		newSeed.FinalExpiry = &timestamp
+	}
+	return newSeed, nil
+}
+
+func (p *Pkcs11Signer) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours uint, amounts []uint64) error {
+	ctx := context.Background()
+	tx, err := p.db.GetTx(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.GetTx(ctx). %w", err)
+	}
+	defer func() {
+		err := p.db.Rollback(ctx, tx)
+		if err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				slog.Warn("rotate keyset sql transaction error", slog.Any("error", err))
+			}
+		}
+	}()
+
+	highestSeedVersion := uint32(0)
+	seeds, err := p.db.GetSeedsByUnit(tx, unit)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("p.db.GetSeedsByUnit(tx, unit). %w", err)
+		}
+	}
+	for i, seed := range seeds {
+		if highestSeedVersion <= seed.Version {
+			highestSeedVersion = seed.Version + uint32(1)
+		}
+		seeds[i].Active = false
+	}
+
+	// a zero expiry limit keeps the keyset valid forever
+	var finalExpiry *time.Time
+	if expiry_limit_hours > 0 {
+		expiry := time.Now().Add(time.Duration(expiry_limit_hours) * time.Hour)
+		finalExpiry = &expiry
+	}
+	newSeed, err := p.createNewSeed(unit, highestSeedVersion, fee, finalExpiry, amounts)
+	if err != nil {
+		return fmt.Errorf(`p.createNewSeed(unit, highestSeedVersion, fee, finalExpiry, amounts) %w`, err)
+	}
+	err = p.db.SaveNewSeed(tx, newSeed)
+	if err != nil {
+		return fmt.Errorf(`p.db.SaveNewSeed(tx, newSeed). %w`, err)
+	}
+	if len(seeds) > 0 {
+		err = p.db.UpdateSeedsActiveStatus(tx, seeds)
+		if err != nil {
+			return fmt.Errorf(`p.db.UpdateSeedsActiveStatus(tx, seeds). %w`, err)
+		}
+	}
+	err = p.db.Commit(ctx, tx)
+	if err != nil {
+		return fmt.Errorf(`p.db.Commit(ctx, tx). %w`, err)
+	}
+
+	seeds, err = p.db.GetAllSeeds()
+	if err != nil {
+		return fmt.Errorf("p.db.GetAllSeeds(). %w", err)
+	}
+	keysets, activeKeysets, err := keysetsFromSeeds(p.token, seeds)
+	if err != nil {
+		return fmt.Errorf(`keysetsFromSeeds(p.token, seeds). %w`, err)
+	}
+	p.mu.Lock()
+	p.keysets = keysets
+	p.activeKeysets = activeKeysets
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Pkcs11Signer) SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
+	var blindedSignatures = make([]cashu.BlindSignature, len(messages))
+	var recoverSigDB = make([]cashu.RecoverSigDB, len(messages))
+
+	p.mu.RLock()
+	activeKeysets := p.activeKeysets
+	p.mu.RUnlock()
+	for i, output := range messages {
+		keyset, exists := activeKeysets[output.Id]
+		if !exists {
+			return nil, nil, cashu.ErrKeysetNotKnow
+		}
+		key, exists := keyset.keys[output.Amount]
+		if !exists {
+			return nil, nil, cashu.ErrKeysetNotKnow
+		}
+		if output.B_.PublicKey == nil {
+			return nil, nil, cashu.ErrInvalidBlindMessage
+		}
+
+		C_, err := p.token.multiply(key, output.B_.PublicKey)
+		if err != nil {
+			return nil, nil, errors.Join(cashu.ErrInvalidBlindMessage, err)
+		}
+		blindedSignatures[i] = cashu.BlindSignature{
+			Amount: output.Amount,
+			Id:     output.Id,
+			C_:     cashu.WrappedPublicKey{PublicKey: C_},
+			Dleq:   nil,
+		}
+		recoverSigDB[i] = cashu.RecoverSigDB{
+			Amount:    output.Amount,
+			Id:        output.Id,
+			C_:        blindedSignatures[i].C_,
+			B_:        output.B_,
+			Dleq:      nil,
+			CreatedAt: time.Now().Unix(),
+			MeltQuote: "",
+		}
+	}
+	return blindedSignatures, recoverSigDB, nil
+}
+
+func (p *Pkcs11Signer) VerifyProofs(ctx context.Context, proofs []cashu.Proof) error {
+	p.mu.RLock()
+	keysets := p.keysets
+	p.mu.RUnlock()
+	for _, proof := range proofs {
+		keyset, exists := keysets[proof.Id]
+		if !exists {
+			return cashu.ErrKeysetNotKnow
+		}
+		key, exists := keyset.keys[proof.Amount]
+		if !exists {
+			return cashu.ErrKeysetNotKnow
+		}
+		if proof.C.PublicKey == nil {
+			return cashu.ErrInvalidProof
+		}
+		Y, err := crypto.HashToCurve([]byte(proof.Secret))
+		if err != nil {
+			return fmt.Errorf("crypto.HashToCurve([]byte(proof.Secret)). %w", err)
+		}
+		C, err := p.token.multiply(key, Y)
+		if err != nil {
+			return fmt.Errorf("p.token.multiply(key, Y). %w", err)
+		}
+		if !C.IsEqual(proof.C.PublicKey) {
+			return cashu.ErrInvalidProof
+		}
+	}
+	return nil
+}
+
+func (p *Pkcs11Signer) GetSignerPubkey() (string, error) {
+	return hex.EncodeToString(p.pubkey.SerializeCompressed()), nil
+}
+
+// ProvesDleq is false, see Pkcs11Signer.
+func (p *Pkcs11Signer) ProvesDleq() bool {
+	return false
+}
+
+// Close logs out of the token.
+func (p *Pkcs11Signer) Close() error {
+	return p.token.Close()
+}
+
+// keysResponse lists the keysets, the auth ones or the others.
+func keysResponse(keysets map[string]keyset, auth bool) signer.GetKeysResponse {
+	response := signer.GetKeysResponse{Keysets: []signer.KeysetResponse{}}
+	for _, keyset := range keysets {
+		if (keyset.seed.Unit == cashu.AUTH.String()) != auth {
+			continue
+		}
+		keys := make(map[uint64]string, len(keyset.keys))
+		for amount, key := range keyset.keys {
+			keys[amount] = hex.EncodeToString(key.pubkey.SerializeCompressed())
+		}
+		response.Keysets = append(response.Keysets, signer.KeysetResponse{
+			Keys:        keys,
+			Id:          keyset.seed.Id,
+			Unit:        keyset.seed.Unit,
+			InputFeePpk: keyset.seed.InputFeePpk,
+			Active:      keyset.seed.Active,
+		})
+	}
+	slices.SortFunc(response.Keysets, func(a, b signer.KeysetResponse) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return response
+}
+
+func (p *Pkcs11Signer) keysById(id string, auth bool) (signer.GetKeysResponse, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	found, exists := p.keysets[id]
+	if !exists {
+		return signer.GetKeysResponse{}, signer.ErrNoKeysetFound
+	}
+	return keysResponse(map[string]keyset{id: found}, auth), nil
+}
+
+// basicKeysets reads the keysets from the seeds, the auth ones or the others.
+func (p *Pkcs11Signer) basicKeysets(auth bool) (signer.GetKeysetsResponse, error) {
+	response := signer.GetKeysetsResponse{Keysets: make([]cashu.BasicKeysetResponse, 0)}
+	seeds, err := p.db.GetAllSeeds()
+	if err != nil {
+		return response, fmt.Errorf("p.db.GetAllSeeds(). %w", err)
+	}
+	for _, seed := range seeds {
+		if (seed.Unit == cashu.AUTH.String()) == auth {
+			response.Keysets = append(response.Keysets, cashu.BasicKeysetResponse{Id: seed.Id, Unit: seed.Unit, Active: seed.Active, InputFeePpk: seed.InputFeePpk, Version: seed.Version, FinalExpiry: seed.FinalExpiry})
+		}
+	}
+	return response, nil
+}
+
+func (p *Pkcs11Signer) GetActiveKeys() (signer.GetKeysResponse, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return keysResponse(p.activeKeysets, false), nil
+}
+
+func (p *Pkcs11Signer) GetKeysById(id string) (signer.GetKeysResponse, error) {
+	return p.keysById(id, false)
+}
+
+func (p *Pkcs11Signer) GetKeysets() (signer.GetKeysetsResponse, error) {
+	return p.basicKeysets(false)
+}
+
+func (p *Pkcs11Signer) GetAuthActiveKeys() (signer.GetKeysResponse, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return keysResponse(p.activeKeysets, true), nil
+}
+
+func (p *Pkcs11Signer) GetAuthKeysById(id string) (signer.GetKeysResponse, error) {
+	return p.keysById(id, true)
+}
+
+func (p *Pkcs11Signer) GetAuthKeys() (signer.GetKeysetsResponse, error) {
+	return p.basicKeysets(true)
+}
@@ -0,0 +1,194 @@
+//go:build cgo
+
+//nolint:exhaustruct
+package pkcs11signer
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	"github.com/lescuer97/nutmix/pkg/crypto"
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	testTokenLabel = "nutmix"
+	testPin        = "5678"
+)
+
+var softhsmModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+}
+
+// softhsmToken initializes a new SoftHSM token in a temporary directory. The
+// test is skipped when SoftHSM is not installed, SOFTHSM2_MODULE points to
+// the library when it is somewhere else.
+func softhsmToken(t *testing.T) Config {
+	t.Helper()
+	module := os.Getenv("SOFTHSM2_MODULE")
+	for _, path := range softhsmModules {
+		if module != "" {
+			break
+		}
+		if _, err := os.Stat(path); err == nil {
+			module = path
+		}
+	}
+	if module == "" {
+		t.Skip("SoftHSM is not installed, set SOFTHSM2_MODULE to run the pkcs11 signer tests")
+	}
+
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens")
+	err := os.Mkdir(tokens, 0o700)
+	if err != nil {
+		t.Fatalf("os.Mkdir(tokens): %v", err)
+	}
+	conf := filepath.Join(dir, "softhsm2.conf")
+	err = os.WriteFile(conf, []byte("directories.tokendir = "+tokens+"\nobjectstore.backend = file\n"), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile(conf): %v", err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("could not load %s", module)
+	}
+	defer ctx.Destroy()
+	err = ctx.Initialize()
+	if err != nil {
+		t.Fatalf("ctx.Initialize(): %v", err)
+	}
+	defer func() {
+		_ = ctx.Finalize()
+	}()
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("ctx.GetSlotList(false): %v, %v", slots, err)
+	}
+	err = ctx.InitToken(slots[0], "1234", testTokenLabel)
+	if err != nil {
+		t.Fatalf("ctx.InitToken: %v", err)
+	}
+	// SoftHSM moves the initialized token to a new slot
+	slots, err = ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatalf("ctx.GetSlotList(true): %v", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil || info.Label != testTokenLabel {
+			continue
+		}
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			t.Fatalf("ctx.OpenSession: %v", err)
+		}
+		err = ctx.Login(session, pkcs11.CKU_SO, "1234")
+		if err != nil {
+			t.Fatalf("ctx.Login(session, CKU_SO): %v", err)
+		}
+		err = ctx.InitPIN(session, testPin)
+		if err != nil {
+			t.Fatalf("ctx.InitPIN: %v", err)
+		}
+		_ = ctx.Logout(session)
+		_ = ctx.CloseSession(session)
+		return Config{Module: module, TokenLabel: testTokenLabel, Pin: testPin}
+	}
+	t.Fatalf("the initialized token is not in any slot")
+	return Config{}
+}
+
+func TestPkcs11SignerWithSoftHSM(t *testing.T) {
+	config := softhsmToken(t)
+	db := mockdb.MockDB{}
+	p, err := SetupPkcs11Signer(&db, config)
+	if err != nil {
+		t.Fatalf("SetupPkcs11Signer(&db, config): %v", err)
+	}
+	pubkey, _ := p.GetSignerPubkey()
+	if len(db.Seeds) != 1 {
+		t.Fatalf("expected a new seed, got %d", len(db.Seeds))
+	}
+	active, err := p.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 {
+		t.Fatalf("expected one active keyset, got %+v, %v", active, err)
+	}
+	keyset := active.Keysets[0]
+
+	secret := "pkcs11 secret"
+	r, _ := secp256k1.GeneratePrivateKey()
+	B_, r, err := crypto.BlindMessage(secret, r)
+	if err != nil {
+		t.Fatalf("crypto.BlindMessage: %v", err)
+	}
+	sigs, recoverSigs, err := p.SignBlindMessages(t.Context(), []cashu.BlindedMessage{{B_: cashu.WrappedPublicKey{PublicKey: B_}, Id: keyset.Id, Amount: 8}})
+	if err != nil {
+		t.Fatalf("p.SignBlindMessages: %v", err)
+	}
+	if len(sigs) != 1 || len(recoverSigs) != 1 || sigs[0].Dleq != nil {
+		t.Fatalf("expected one signature without dleq, got %+v", sigs)
+	}
+	K, _ := hex.DecodeString(keyset.Keys[8])
+	amountKey, _ := secp256k1.ParsePubKey(K)
+	C := crypto.UnblindSignature(sigs[0].C_.PublicKey, r, amountKey)
+
+	proof := cashu.Proof{C: cashu.WrappedPublicKey{PublicKey: C}, Id: keyset.Id, Secret: secret, Amount: 8}
+	err = p.VerifyProofs(t.Context(), []cashu.Proof{proof})
+	if err != nil {
+		t.Errorf("p.VerifyProofs: %v", err)
+	}
+	proof.Amount = 16
+	err = p.VerifyProofs(t.Context(), []cashu.Proof{proof})
+	if !errors.Is(err, cashu.ErrInvalidProof) {
+		t.Errorf("expected a proof signed for another amount to fail, got %v", err)
+	}
+
+	err = p.RotateKeyset(cashu.Sat, 100, 0, nil)
+	if err != nil {
+		t.Fatalf("p.RotateKeyset: %v", err)
+	}
+	_, _, err = p.SignBlindMessages(t.Context(), []cashu.BlindedMessage{{B_: cashu.WrappedPublicKey{PublicKey: B_}, Id: keyset.Id, Amount: 8}})
+	if !errors.Is(err, cashu.ErrKeysetNotKnow) {
+		t.Errorf("expected the rotated keyset to stop signing, got %v", err)
+	}
+	err = p.Close()
+	if err != nil {
+		t.Fatalf("p.Close(): %v", err)
+	}
+
+	verifiedPubkey, err := VerifySeeds(config, db.Seeds)
+	if err != nil || verifiedPubkey != pubkey {
+		t.Errorf("VerifySeeds: %s, %v", verifiedPubkey, err)
+	}
+
+	// the keys stay in the token for the next start
+	reopened, err := SetupPkcs11Signer(&db, config)
+	if err != nil {
+		t.Fatalf("SetupPkcs11Signer again: %v", err)
+	}
+	defer func() {
+		_ = reopened.Close()
+	}()
+	reopenedPubkey, _ := reopened.GetSignerPubkey()
+	if reopenedPubkey != pubkey || len(db.Seeds) != 2 {
+		t.Errorf("expected the same signer with two seeds, got %s and %d seeds", reopenedPubkey, len(db.Seeds))
+	}
+	proof.Amount = 8
+	err = reopened.VerifyProofs(t.Context(), []cashu.Proof{proof})
+	if err != nil {
+		t.Errorf("reopened.VerifyProofs: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+//go:build cgo
+
+package pkcs11signer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/miekg/pkcs11"
+)
+
+// tokenKey is a private key that lives in the token and its public key.
+type tokenKey struct {
+	pubkey *secp256k1.PublicKey
+	handle pkcs11.ObjectHandle
+}
+
+// token is a logged in session with the token. Sessions can not run two
+// operations at the same time, so every call takes the lock.
+type token struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	mu      sync.Mutex
+	// another token in the process may have initialized the module first,
+	// only the one that did finalizes it
+	initialized bool
+}
+
+func openToken(config Config) (*token, error) {
+	ctx := pkcs11.New(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("could not load the pkcs11 module %s", config.Module)
+	}
+	err := ctx.Initialize()
+	if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("ctx.Initialize(). %w", err)
+	}
+	t := &token{ctx: ctx, session: 0, mu: sync.Mutex{}, initialized: err == nil}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		t.finalize()
+		return nil, fmt.Errorf("ctx.GetSlotList(true). %w", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			t.finalize()
+			return nil, fmt.Errorf("ctx.GetTokenInfo(%d). %w", slot, err)
+		}
+		if strings.TrimSpace(info.Label) != config.TokenLabel {
+			continue
+		}
+		t.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			t.finalize()
+			return nil, fmt.Errorf("ctx.OpenSession(%d). %w", slot, err)
+		}
+		err = ctx.Login(t.session, pkcs11.CKU_USER, config.Pin)
+		if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			_ = ctx.CloseSession(t.session)
+			t.finalize()
+			return nil, fmt.Errorf("ctx.Login(session, CKU_USER, pin). %w", err)
+		}
+		return t, nil
+	}
+	t.finalize()
+	return nil, fmt.Errorf("%w: %s", ErrTokenNotFound, config.TokenLabel)
+}
+
+func (t *token) finalize() {
+	if t.initialized {
+		_ = t.ctx.Finalize()
+	}
+	t.ctx.Destroy()
+}
+
+func (t *token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err := errors.Join(t.ctx.Logout(t.session), t.ctx.CloseSession(t.session))
+	t.finalize()
+	return err
+}
+
+// find returns the objects of class with label.
+func (t *token) find(class uint, label string) ([]pkcs11.ObjectHandle, error) {
+	err := t.ctx.FindObjectsInit(t.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("t.ctx.FindObjectsInit(session, %s). %w", label, err)
+	}
+	var handles []pkcs11.ObjectHandle
+	for {
+		found, _, err := t.ctx.FindObjects(t.session, 100)
+		if err != nil {
+			_ = t.ctx.FindObjectsFinal(t.session)
+			return nil, fmt.Errorf("t.ctx.FindObjects(session, 100). %w", err)
+		}
+		if len(found) == 0 {
+			break
+		}
+		handles = append(handles, found...)
+	}
+	err = t.ctx.FindObjectsFinal(t.session)
+	if err != nil {
+		return nil, fmt.Errorf("t.ctx.FindObjectsFinal(session). %w", err)
+	}
+	return handles, nil
+}
+
+func (t *token) attributes(handle pkcs11.ObjectHandle, types ...uint) ([][]byte, error) {
+	template := make([]*pkcs11.Attribute, len(types))
+	for i := range types {
+		template[i] = pkcs11.NewAttribute(types[i], nil)
+	}
+	attributes, err := t.ctx.GetAttributeValue(t.session, handle, template)
+	if err != nil {
+		return nil, fmt.Errorf("t.ctx.GetAttributeValue(session, handle, template). %w", err)
+	}
+	values := make([][]byte, len(types))
+	for _, attribute := range attributes {
+		for i := range types {
+			if attribute.Type == types[i] {
+				values[i] = attribute.Value
+			}
+		}
+	}
+	return values, nil
+}
+
+// keys returns the keys with label by their CKA_ID.
+func (t *token) keys(label string) (map[string]tokenKey, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	privateKeys, err := t.find(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := t.find(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	pubkeys := make(map[string]*secp256k1.PublicKey, len(publicKeys))
+	for _, handle := range publicKeys {
+		values, err := t.attributes(handle, pkcs11.CKA_ID, pkcs11.CKA_EC_POINT)
+		if err != nil {
+			return nil, err
+		}
+		pubkey, err := parseECPoint(values[1])
+		if err != nil {
+			return nil, fmt.Errorf("parseECPoint(CKA_EC_POINT). %w", err)
+		}
+		pubkeys[string(values[0])] = pubkey
+	}
+
+	keys := make(map[string]tokenKey, len(privateKeys))
+	for _, handle := range privateKeys {
+		values, err := t.attributes(handle, pkcs11.CKA_ID)
+		if err != nil {
+			return nil, err
+		}
+		pubkey, ok := pubkeys[string(values[0])]
+		if !ok {
+			return nil, fmt.Errorf("%w: no public key for %s %x", ErrMissingTokenKey, label, values[0])
+		}
+		keys[string(values[0])] = tokenKey{pubkey: pubkey, handle: handle}
+	}
+	return keys, nil
+}
+
+// generate creates a secp256k1 key in the token that can only be used for
+// ECDH and never leaves it.
+func (t *token) generate(label string, id []byte) (tokenKey, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, secp256k1Params),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	publicHandle, privateHandle, err := t.ctx.GenerateKeyPair(t.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		publicTemplate, privateTemplate)
+	if err != nil {
+		return tokenKey{}, fmt.Errorf("t.ctx.GenerateKeyPair(session, CKM_EC_KEY_PAIR_GEN). %w", err)
+	}
+	values, err := t.attributes(publicHandle, pkcs11.CKA_EC_POINT)
+	if err != nil {
+		return tokenKey{}, err
+	}
+	pubkey, err := parseECPoint(values[0])
+	if err != nil {
+		return tokenKey{}, fmt.Errorf("parseECPoint(CKA_EC_POINT). %w", err)
+	}
+	return tokenKey{pubkey: pubkey, handle: privateHandle}, nil
+}
+
+// ecdh returns the x coordinate of k*point, where k is the private key.
+func (t *token) ecdh(key tokenKey, point *secp256k1.PublicKey) ([]byte, error) {
+	mechanism := pkcs11.NewMechanism(pkcs11.CKM_ECDH1_DERIVE,
+		pkcs11.NewECDH1DeriveParams(pkcs11.CKD_NULL, nil, point.SerializeUncompressed()))
+	secretTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	secret, err := t.ctx.DeriveKey(t.session, []*pkcs11.Mechanism{mechanism}, key.handle, secretTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("t.ctx.DeriveKey(session, CKM_ECDH1_DERIVE). %w", err)
+	}
+	defer func() {
+		_ = t.ctx.DestroyObject(t.session, secret)
+	}()
+	values, err := t.attributes(secret, pkcs11.CKA_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// multiply returns k*point with two ECDH calls, k never leaves the token.
+func (t *token) multiply(key tokenKey, point *secp256k1.PublicKey) (*secp256k1.PublicKey, error) {
+	pointPlusG, err := plusGenerator(point)
+	if err != nil {
+		return nil, err
+	}
+	x, err := t.ecdh(key, point)
+	if err != nil {
+		return nil, err
+	}
+	xPlusK, err := t.ecdh(key, pointPlusG)
+	if err != nil {
+		return nil, err
+	}
+	return recoverPoint(key.pubkey, x, xPlusK)
+}
@@ -0,0 +1,346 @@
+package remotesigner
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lescuer97/nutmix/api/cashu"
+	sig "github.com/lescuer97/nutmix/internal/gen"
+	"github.com/lescuer97/nutmix/internal/signer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+	minRetryBackoff     = time.Second
+	maxRetryBackoff     = time.Minute
+	// a rotation is never retried on another endpoint, so it gets more time
+	rotationTimeout = 30 * time.Second
+)
+
+var (
+	ErrNoSignerReachable    = errors.New("no remote signer is reachable")
+	ErrSignerPubkeyMismatch = errors.New("the remote signer has a different pubkey than the others")
+	ErrSignerKeysetMismatch = errors.New("the remote signer is missing keysets the others have")
+)
+
+// endpoint is one address of the remote signer. Every endpoint should serve
+// the same master key, the pool sends each call to the first healthy one.
+type endpoint struct {
+	client    sig.SignatoryClient
+	conn      grpc.ClientConnInterface
+	address   string
+	lastError string
+	lastCheck time.Time
+	nextCheck time.Time
+	failures  int
+	healthy   bool
+	// an endpoint with another pubkey is never used, it would sign with a
+	// key wallets do not know
+	mismatch bool
+	// an endpoint missing keysets of the pool, like one that did not see a
+	// rotation, is not used until a check finds them
+	diverged bool
+}
+
+// splitAddresses reads the comma separated addresses of NETWORK_SIGNER_ADDRESS.
+func splitAddresses(addresses string) []string {
+	var result []string
+	for address := range strings.SplitSeq(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			result = append(result, address)
+		}
+	}
+	return result
+}
+
+func connTarget(conn grpc.ClientConnInterface) string {
+	if target, ok := conn.(interface{ Target() string }); ok {
+		return target.Target()
+	}
+	return "signer"
+}
+
+// retryBackoff doubles the wait after every failed check, up to a minute.
+func retryBackoff(failures int) time.Duration {
+	backoff := minRetryBackoff
+	for range failures - 1 {
+		backoff *= 2
+		if backoff >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return backoff
+}
+
+// isUnreachable tells the errors of a signer that could not be reached from
+// the errors it answered with. Only the first ones fail over.
+func isUnreachable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// candidates are the healthy endpoints first, then the ones marked down in
+// case they came back before their next check.
+func (s *RemoteSigner) candidates() []*endpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var healthy, down []*endpoint
+	for _, e := range s.endpoints {
+		switch {
+		case e.mismatch, e.diverged:
+		case e.healthy:
+			healthy = append(healthy, e)
+		default:
+			down = append(down, e)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// primary is the first healthy endpoint, the one that gets the calls that
+// must not be repeated on another endpoint.
+func (s *RemoteSigner) primary() (*endpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, e := range s.endpoints {
+		if e.healthy && !e.mismatch && !e.diverged {
+			return e, nil
+		}
+	}
+	return nil, ErrNoSignerReachable
+}
+
+// call runs fn against the endpoints until one of them answers.
+func (s *RemoteSigner) call(ctx context.Context, fn func(client sig.SignatoryClient) error) error {
+	var lastErr error
+	for _, e := range s.candidates() {
+		err := fn(e.client)
+		if err == nil || !isUnreachable(ctx, err) {
+			if err == nil {
+				s.markUp(e)
+			}
+			return err
+		}
+		s.markDown(e, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return ErrNoSignerReachable
+	}
+	return fmt.Errorf("%w. %w", ErrNoSignerReachable, lastErr)
+}
+
+func (s *RemoteSigner) markUp(e *endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !e.healthy {
+		slog.Info("Remote signer is reachable", slog.String("address", e.address))
+	}
+	e.healthy = true
+	e.diverged = false
+	e.failures = 0
+	e.lastError = ""
+	e.lastCheck = time.Now()
+	e.nextCheck = e.lastCheck.Add(healthCheckInterval)
+}
+
+func (s *RemoteSigner) markDown(e *endpoint, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.healthy {
+		slog.Warn("Remote signer is down", slog.String("address", e.address), slog.Any("error", err))
+	}
+	e.healthy = false
+	e.failures++
+	e.lastError = err.Error()
+	e.lastCheck = time.Now()
+	e.nextCheck = e.lastCheck.Add(retryBackoff(e.failures))
+	// wake the connection up so it dials again before the next check
+	if conn, ok := e.conn.(interface{ Connect() }); ok {
+		conn.Connect()
+	}
+}
+
+// check asks the endpoint for its keysets. The pubkey has to match the one of
+// the pool and the endpoint needs every cached keyset. The cached keysets are
+// replaced when they changed, like after a rotation made through another mint.
+func (s *RemoteSigner) check(ctx context.Context, e *endpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	keys, err := e.client.Keysets(ctx, &sig.EmptyRequest{})
+	if err == nil {
+		err = CheckIfSignerErrorExists(keys.GetError())
+	}
+	if err != nil {
+		s.markDown(e, err)
+		return err
+	}
+
+	s.mu.Lock()
+	pubkey := s.pubkey
+	s.mu.Unlock()
+	if pubkey != nil && !bytes.Equal(keys.GetKeysets().GetPubkey(), pubkey) {
+		err = fmt.Errorf("%w: %x", ErrSignerPubkeyMismatch, keys.GetKeysets().GetPubkey())
+		s.markDown(e, err)
+		s.mu.Lock()
+		e.mismatch = true
+		s.mu.Unlock()
+		return err
+	}
+	missing := s.missingKeysets(keys)
+	if len(missing) > 0 {
+		err = fmt.Errorf("%w: %s", ErrSignerKeysetMismatch, strings.Join(missing, ", "))
+		s.markDown(e, err)
+		s.mu.Lock()
+		e.diverged = true
+		s.mu.Unlock()
+		return err
+	}
+	s.markUp(e)
+
+	changed, err := s.loadKeysets(keys)
+	if err != nil {
+		return fmt.Errorf("s.loadKeysets(keys). %w", err)
+	}
+	if changed && pubkey != nil {
+		slog.Info("Reloaded the keysets of the remote signer", slog.String("address", e.address))
+	}
+	return nil
+}
+
+// missingKeysets returns the ids of the cached keysets the endpoint does not
+// serve. Keysets are never deleted, so an endpoint without one of them is
+// behind or serves another database.
+func (s *RemoteSigner) missingKeysets(keys *sig.KeysResponse) []string {
+	served := make(map[string]bool)
+	for _, keyset := range keys.GetKeysets().GetKeysets() {
+		served[hex.EncodeToString(keyset.GetId())] = true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var missing []string
+	for id := range s.keysets {
+		if !served[id] {
+			missing = append(missing, id)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// checkAll checks every endpoint and returns the first error if none of them
+// is healthy.
+func (s *RemoteSigner) checkAll(ctx context.Context) error {
+	var errs []error
+	healthy := false
+	for _, e := range s.endpoints {
+		err := s.check(ctx, e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.address, err))
+			continue
+		}
+		healthy = true
+	}
+	if !healthy {
+		return errors.Join(append([]error{ErrNoSignerReachable}, errs...)...)
+	}
+	return nil
+}
+
+// healthLoop checks the endpoints when their next check is due until Close.
+func (s *RemoteSigner) healthLoop() {
+	ticker := time.NewTicker(minRetryBackoff)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			for _, e := range s.endpoints {
+				s.mu.RLock()
+				due := !e.mismatch && !now.Before(e.nextCheck)
+				s.mu.RUnlock()
+				if due {
+					_ = s.check(context.Background(), e)
+				}
+			}
+		}
+	}
+}
+
+// refreshKeysets loads the keysets again after the signer answered with an
+// unknown or inactive keyset, it was rotated by someone else.
+func (s *RemoteSigner) refreshKeysets(ctx context.Context, cause error) {
+	if !errors.Is(cause, cashu.ErrKeysetNotFound) && !errors.Is(cause, cashu.ErrUsingInactiveKeyset) {
+		return
+	}
+	var err error
+	for _, e := range s.candidates() {
+		err = s.check(ctx, e)
+		if err == nil {
+			return
+		}
+	}
+	if err == nil {
+		err = ErrNoSignerReachable
+	}
+	slog.Warn("Could not reload the keysets of the remote signer", slog.Any("error", err))
+}
+
+// Health reports the state of every endpoint for the dashboard.
+func (s *RemoteSigner) Health() []signer.EndpointHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	health := make([]signer.EndpointHealth, len(s.endpoints))
+	for i, e := range s.endpoints {
+		health[i] = signer.EndpointHealth{
+			Address:   e.address,
+			LastError: e.lastError,
+			LastCheck: e.lastCheck,
+			Failures:  e.failures,
+			Healthy:   e.healthy,
+		}
+	}
+	return health
+}
+
+// Close stops the health checks and closes the connections.
+func (s *RemoteSigner) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	var errs []error
+	for _, e := range s.endpoints {
+		if closer, ok := e.conn.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func keysetsChanged(old map[string]MintPublicKeyset, new map[string]MintPublicKeyset) bool {
+	return !maps.EqualFunc(old, new, func(a MintPublicKeyset, b MintPublicKeyset) bool {
+		return a.Active == b.Active && a.InputFeePpk == b.InputFeePpk && hex.EncodeToString(a.Id) == hex.EncodeToString(b.Id)
+	})
+}
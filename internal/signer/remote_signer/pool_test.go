@@ -0,0 +1,180 @@
+//nolint:exhaustruct
+package remotesigner_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	remotesigner "github.com/lescuer97/nutmix/internal/signer/remote_signer"
+	signerserver "github.com/lescuer97/nutmix/internal/signer/signer_server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testEndpoint struct {
+	server *grpc.Server
+	conn   *grpc.ClientConn
+}
+
+func serveSigner(t *testing.T, local *localsigner.LocalSigner, name string) testEndpoint {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := signerserver.NewGrpcServer(signerserver.NewServer(local), insecure.NewCredentials())
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///"+name,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	return testEndpoint{server: server, conn: conn}
+}
+
+func localSigner(t *testing.T, privateKey string) *localsigner.LocalSigner {
+	t.Helper()
+	key, _ := hex.DecodeString(privateKey)
+	local, err := localsigner.SetupLocalSignerWithKey(&mockdb.MockDB{}, key)
+	if err != nil {
+		t.Fatalf("localsigner.SetupLocalSignerWithKey: %v", err)
+	}
+	return &local
+}
+
+func blindedMessage(t *testing.T, id string) cashu.BlindedMessage {
+	t.Helper()
+	key, _ := secp256k1.GeneratePrivateKey()
+	return cashu.BlindedMessage{B_: cashu.WrappedPublicKey{PublicKey: key.PubKey()}, Id: id, Amount: 1}
+}
+
+func TestRemoteSignerFailover(t *testing.T) {
+	local := localSigner(t, "0000000000000000000000000000000000000000000000000000000000000001")
+	other := localSigner(t, "0000000000000000000000000000000000000000000000000000000000000002")
+	first := serveSigner(t, local, "first")
+	second := serveSigner(t, local, "second")
+	wrongKey := serveSigner(t, other, "wrong-key")
+
+	remote, err := remotesigner.NewRemoteSigner(first.conn, wrongKey.conn, second.conn)
+	if err != nil {
+		t.Fatalf("remotesigner.NewRemoteSigner: %v", err)
+	}
+	defer func() {
+		_ = remote.Close()
+	}()
+
+	health := remote.Health()
+	if len(health) != 3 || !health[0].Healthy || health[1].Healthy || !health[2].Healthy {
+		t.Fatalf("expected the endpoint with another key to be down, got %+v", health)
+	}
+	active, err := remote.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 {
+		t.Fatalf("expected one active keyset, got %+v, %v", active, err)
+	}
+	id := active.Keysets[0].Id
+
+	first.server.Stop()
+	_, _, err = remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{blindedMessage(t, id)})
+	if err != nil {
+		t.Fatalf("expected the second signer to sign, got %v", err)
+	}
+	health = remote.Health()
+	if health[0].Healthy || health[0].Failures != 1 || health[0].LastError == "" || !health[2].Healthy {
+		t.Errorf("expected the first signer to be down, got %+v", health)
+	}
+
+	second.server.Stop()
+	_, _, err = remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{blindedMessage(t, id)})
+	if !errors.Is(err, remotesigner.ErrNoSignerReachable) {
+		t.Errorf("expected ErrNoSignerReachable, got %v", err)
+	}
+}
+
+func TestRemoteSignerReloadsRotatedKeysets(t *testing.T) {
+	local := localSigner(t, "0000000000000000000000000000000000000000000000000000000000000001")
+	endpoint := serveSigner(t, local, "signer")
+	remote, err := remotesigner.NewRemoteSigner(endpoint.conn)
+	if err != nil {
+		t.Fatalf("remotesigner.NewRemoteSigner: %v", err)
+	}
+	defer func() {
+		_ = remote.Close()
+	}()
+	active, _ := remote.GetActiveKeys()
+	oldId := active.Keysets[0].Id
+
+	// another mint rotates the keyset on the same signer
+	err = local.RotateKeyset(cashu.Sat, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("local.RotateKeyset: %v", err)
+	}
+
+	_, _, err = remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{blindedMessage(t, oldId)})
+	if !errors.Is(err, cashu.ErrKeysetNotFound) {
+		t.Fatalf("expected the old keyset to be refused, got %v", err)
+	}
+	active, err = remote.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 || active.Keysets[0].Id == oldId {
+		t.Fatalf("expected the new keyset after the refusal, got %+v, %v", active, err)
+	}
+	_, _, err = remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{blindedMessage(t, active.Keysets[0].Id)})
+	if err != nil {
+		t.Errorf("expected the new keyset to sign, got %v", err)
+	}
+}
+
+func TestRemoteSignerRotatesOnPrimaryOnly(t *testing.T) {
+	key := "0000000000000000000000000000000000000000000000000000000000000001"
+	primary := serveSigner(t, localSigner(t, key), "primary")
+	// same key but its own database, it never sees the rotation
+	lagging := serveSigner(t, localSigner(t, key), "lagging")
+
+	remote, err := remotesigner.NewRemoteSigner(primary.conn, lagging.conn)
+	if err != nil {
+		t.Fatalf("remotesigner.NewRemoteSigner: %v", err)
+	}
+	defer func() {
+		_ = remote.Close()
+	}()
+	health := remote.Health()
+	if !health[0].Healthy || !health[1].Healthy {
+		t.Fatalf("expected both endpoints to be healthy, got %+v", health)
+	}
+
+	err = remote.RotateKeyset(cashu.Sat, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("remote.RotateKeyset: %v", err)
+	}
+	health = remote.Health()
+	if !health[0].Healthy || health[1].Healthy || !strings.Contains(health[1].LastError, remotesigner.ErrSignerKeysetMismatch.Error()) {
+		t.Fatalf("expected the endpoint without the new keyset to be down, got %+v", health)
+	}
+	active, err := remote.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 {
+		t.Fatalf("expected one active keyset, got %+v, %v", active, err)
+	}
+
+	primary.server.Stop()
+	_, _, err = remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{blindedMessage(t, active.Keysets[0].Id)})
+	if !errors.Is(err, remotesigner.ErrNoSignerReachable) {
+		t.Errorf("expected the diverged endpoint to not be used, got %v", err)
+	}
+	err = remote.RotateKeyset(cashu.Sat, 0, 0, nil)
+	if !errors.Is(err, remotesigner.ErrNoSignerReachable) {
+		t.Errorf("expected the rotation to not fail over, got %v", err)
+	}
+}
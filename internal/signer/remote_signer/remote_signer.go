@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/lescuer97/nutmix/api/cashu"
 	sig "github.com/lescuer97/nutmix/internal/gen"
 	"github.com/lescuer97/nutmix/internal/signer"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
@@ -26,110 +27,148 @@ type MintPublicKeyset struct {
 	Active        bool
 }
 
+// RemoteSigner signs through one or more signatory servers that hold the same
+// master key. Calls go to the first healthy endpoint and fail over to the
+// next one when it can not be reached.
 type RemoteSigner struct {
-	grpcClient    sig.SignatoryClient
+	stop          chan struct{}
 	activeKeysets map[string]MintPublicKeyset
 	keysets       map[string]MintPublicKeyset
+	endpoints     []*endpoint
 	pubkey        []byte
+	// guards the keysets, the pubkey and the state of the endpoints
+	mu        sync.RWMutex
+	closeOnce sync.Once
 }
 
 const abstractSocket = "unix:@signer_socket"
 
-func SetupRemoteSigner(connectToNetwork bool, networkAddress string) (RemoteSigner, error) {
-	socketSigner := RemoteSigner{
-		grpcClient:    nil,
-		activeKeysets: make(map[string]MintPublicKeyset),
-		keysets:       make(map[string]MintPublicKeyset),
-		pubkey:        nil,
-	}
+// SchemaVersionHeader carries the version of the signatory protocol the
+// client speaks.
+const SchemaVersionHeader = "x-signatory-schema-version"
 
+// SetupRemoteSigner connects to the abstract socket, or to every comma
+// separated address in networkAddress.
+func SetupRemoteSigner(connectToNetwork bool, networkAddress string) (*RemoteSigner, error) {
 	certs, err := GetTlsSecurityCredential()
 	if err != nil {
-		return socketSigner, fmt.Errorf("GetTlsSecurityCredential(). %w", err)
+		return nil, fmt.Errorf("GetTlsSecurityCredential(). %w", err)
 	}
 
-	target := abstractSocket
+	targets := []string{abstractSocket}
 	if connectToNetwork {
-		target = networkAddress
+		targets = splitAddresses(networkAddress)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("NETWORK_SIGNER_ADDRESS is empty")
+		}
 	}
 
-	conn, err := grpc.NewClient(target,
-		grpc.WithTransportCredentials(certs),
-		grpc.WithUnaryInterceptor(clientVersionInterceptor()),
-	)
-
-	if err != nil {
-		log.Fatalf("grpc connection failed: %v", err)
+	conns := make([]grpc.ClientConnInterface, len(targets))
+	for i, target := range targets {
+		conn, err := grpc.NewClient(target,
+			grpc.WithTransportCredentials(certs),
+			grpc.WithUnaryInterceptor(clientVersionInterceptor()),
+			// propagates the trace of the mint request to the signer
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("grpc.NewClient(%s). %w", target, err)
+		}
+		conns[i] = conn
 	}
 
-	client := sig.NewSignatoryClient(conn)
+	return NewRemoteSigner(conns...)
+}
 
-	socketSigner.grpcClient = client
+// NewRemoteSigner uses open connections to signatory servers, checks them and
+// loads the keysets. At least one of them has to answer. The endpoints are
+// checked again in the background until Close.
+func NewRemoteSigner(conns ...grpc.ClientConnInterface) (*RemoteSigner, error) {
+	socketSigner := &RemoteSigner{
+		stop:          make(chan struct{}),
+		activeKeysets: make(map[string]MintPublicKeyset),
+		keysets:       make(map[string]MintPublicKeyset),
+		endpoints:     make([]*endpoint, len(conns)),
+		pubkey:        nil,
+		mu:            sync.RWMutex{},
+		closeOnce:     sync.Once{},
+	}
+	for i, conn := range conns {
+		socketSigner.endpoints[i] = &endpoint{
+			client:    sig.NewSignatoryClient(conn),
+			conn:      conn,
+			address:   connTarget(conn),
+			lastError: "",
+			lastCheck: time.Time{},
+			nextCheck: time.Time{},
+			failures:  0,
+			healthy:   false,
+			mismatch:  false,
+			diverged:  false,
+		}
+	}
 
-	err = socketSigner.setupSignerPubkeys()
+	err := socketSigner.checkAll(context.Background())
 	if err != nil {
-		log.Fatalf("socketSigner.setupSignerPubkeys(): %v", err)
+		_ = socketSigner.Close()
+		return nil, fmt.Errorf("socketSigner.checkAll(). %w", err)
 	}
+	go socketSigner.healthLoop()
 
 	return socketSigner, nil
 }
 
 func clientVersionInterceptor() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		ctx = metadata.AppendToOutgoingContext(ctx, "x-signatory-schema-version", strconv.FormatUint(uint64(sig.Constants_CONSTANTS_VERSION), 10))
+		ctx = metadata.AppendToOutgoingContext(ctx, SchemaVersionHeader, strconv.FormatUint(uint64(sig.Constants_CONSTANTS_VERSION), 10))
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
 
-// gets all active keys
-func (s *RemoteSigner) setupSignerPubkeys() error {
-	ctx := context.Background()
-	emptyRequest := sig.EmptyRequest{}
-
-	keys, err := s.grpcClient.Keysets(ctx, &emptyRequest)
-	if err != nil {
-		return fmt.Errorf("s.grpcClient.Keysets(ctx, &emptyRequest). %w", err)
-	}
+// loadKeysets replaces the cached keysets with the ones in keys and reports
+// if they changed.
+func (s *RemoteSigner) loadKeysets(keys *sig.KeysResponse) (bool, error) {
 	if err := signerValidator.Struct(keys); err != nil {
-		return fmt.Errorf("signer keysets response validation failed: %w", err)
+		return false, fmt.Errorf("signer keysets response validation failed: %w", err)
 	}
 
-	err = CheckIfSignerErrorExists(keys.GetError())
+	err := CheckIfSignerErrorExists(keys.GetError())
 	if err != nil {
-		return fmt.Errorf("CheckIfSignerErrorExists(keys.GetError()). %w", err)
+		return false, fmt.Errorf("CheckIfSignerErrorExists(keys.GetError()). %w", err)
 	}
 
 	if keys.GetKeysets() == nil {
-		return fmt.Errorf("no keysets on the signer: %w", err)
+		return false, fmt.Errorf("no keysets on the signer")
 	}
 
 	if err := signerValidator.Struct(keys.GetKeysets()); err != nil {
-		return fmt.Errorf("signer keysets payload validation failed: %w", err)
+		return false, fmt.Errorf("signer keysets payload validation failed: %w", err)
 	}
 
-	s.pubkey = keys.GetKeysets().Pubkey
-
+	// a rotation deactivates keysets, so the maps are built again
+	activeKeysets := make(map[string]MintPublicKeyset)
+	keysets := make(map[string]MintPublicKeyset)
 	for i, key := range keys.GetKeysets().Keysets {
 		if key == nil {
-			return fmt.Errorf("there was a nil key, index: %v", i)
+			return false, fmt.Errorf("there was a nil key, index: %v", i)
 		}
 		if err := signerValidator.Struct(key); err != nil {
-			return fmt.Errorf("signer keyset validation failed at index %d: %w", i, err)
+			return false, fmt.Errorf("signer keyset validation failed at index %d: %w", i, err)
 		}
 
 		if key.Keys == nil {
-			return fmt.Errorf("no keys on keyset, id: %v", key.Id)
+			return false, fmt.Errorf("no keys on keyset, id: %v", key.Id)
 		}
 		if err := signerValidator.Struct(key.Keys); err != nil {
-			return fmt.Errorf("signer keyset keys validation failed at index %d: %w", i, err)
+			return false, fmt.Errorf("signer keyset keys validation failed at index %d: %w", i, err)
 		}
 		if key.Unit == nil {
-			return fmt.Errorf("signer keyset missing unit at index %d", i)
+			return false, fmt.Errorf("signer keyset missing unit at index %d", i)
 		}
 
 		unit, err := ConvertSigUnitToCashuUnit(key.Unit)
 		if err != nil {
-			return fmt.Errorf("ConvertSigUnitToCashuUnit(key.Unit). %w", err)
+			return false, fmt.Errorf("ConvertSigUnitToCashuUnit(key.Unit). %w", err)
 		}
 		stringKeys := make(map[uint64]string)
 
@@ -149,17 +188,28 @@ func (s *RemoteSigner) setupSignerPubkeys() error {
 		}
 
 		if mintKeyset.Active {
-			s.activeKeysets[hex.EncodeToString(mintKeyset.Id)] = mintKeyset
+			activeKeysets[hex.EncodeToString(mintKeyset.Id)] = mintKeyset
 		}
 
-		s.keysets[hex.EncodeToString(mintKeyset.Id)] = mintKeyset
+		keysets[hex.EncodeToString(mintKeyset.Id)] = mintKeyset
 	}
 
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pubkey == nil {
+		s.pubkey = keys.GetKeysets().Pubkey
+	}
+	changed := keysetsChanged(s.keysets, keysets)
+	s.activeKeysets = activeKeysets
+	s.keysets = keysets
+
+	return changed, nil
 }
 
 // gets all active keys
 func (s *RemoteSigner) GetActiveKeys() (signer.GetKeysResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	keys := make([]MintPublicKeyset, len(s.activeKeysets))
 	indexActiveKeysets := 0
 	for _, keyset := range s.activeKeysets {
@@ -170,6 +220,8 @@ func (s *RemoteSigner) GetActiveKeys() (signer.GetKeysResponse, error) {
 }
 
 func (s *RemoteSigner) GetKeysById(id string) (signer.GetKeysResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	val, exists := s.keysets[id]
 	if exists {
 		return OrderKeysetByUnit([]MintPublicKeyset{val}), nil
@@ -179,6 +231,8 @@ func (s *RemoteSigner) GetKeysById(id string) (signer.GetKeysResponse, error) {
 
 // gets all keys from the signer
 func (s *RemoteSigner) GetKeysets() (signer.GetKeysetsResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var response signer.GetKeysetsResponse
 	for _, seed := range s.keysets {
 		if seed.Unit != cashu.AUTH.String() {
@@ -195,33 +249,49 @@ func (s *RemoteSigner) GetKeysets() (signer.GetKeysetsResponse, error) {
 	return response, nil
 }
 
-func (s *RemoteSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours uint) error {
-	ctx := context.Background()
+// RotateKeyset rotates on the primary endpoint only. A rotation that timed out
+// may still have happened, so sending it to the next endpoint could create two
+// keysets.
+func (s *RemoteSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hours uint, amounts []uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rotationTimeout)
+	defer cancel()
 
 	unitSig, err := ConvertCashuUnitToSignature(unit)
 	if err != nil {
 		return fmt.Errorf("ConvertCashuUnitToSignature(unit). %w", err)
 	}
 
-	now := time.Now()
-	now = now.Add(time.Duration(expiry_limit_hours) * time.Hour)
-
-	amounts := cashu.GetAmountsForKeysets(cashu.MaxKeysetAmount)
+	amounts, err = cashu.KeysetAmounts(amounts)
+	if err != nil {
+		return fmt.Errorf("cashu.KeysetAmounts(amounts). %w", err)
+	}
 	if unit == cashu.AUTH {
 		amounts = []uint64{amounts[0]}
 	}
 
-	unixTime := uint64(now.Unix())
+	// a zero expiry limit keeps the keyset valid forever
+	var finalExpiry *uint64
+	if expiry_limit_hours > 0 {
+		unixTime := uint64(time.Now().Add(time.Duration(expiry_limit_hours) * time.Hour).Unix())
+		finalExpiry = &unixTime
+	}
 	rotationReq := sig.RotationRequest{
 		Unit:         unitSig,
 		InputFeePpk:  uint64(fee),
 		Amounts:      amounts,
-		FinalExpiry:  &unixTime,
+		FinalExpiry:  finalExpiry,
 		KeysetIdType: sig.KeysetVersion_KEYSET_VERSION_V2,
 	}
-	rotationResponse, err := s.grpcClient.RotateKeyset(ctx, &rotationReq)
+	primary, err := s.primary()
+	if err != nil {
+		return fmt.Errorf("s.primary(). %w", err)
+	}
+	rotationResponse, err := primary.client.RotateKeyset(ctx, &rotationReq)
 	if err != nil {
-		return fmt.Errorf("s.grpcClient.RotateKeyset(ctx, &rotationReq). %w", err)
+		if isUnreachable(ctx, err) {
+			s.markDown(primary, err)
+		}
+		return fmt.Errorf("primary.client.RotateKeyset(ctx, &rotationReq). %w", err)
 	}
 	if err := signerValidator.Struct(rotationResponse); err != nil {
 		return fmt.Errorf("signer rotate keyset response validation failed: %w", err)
@@ -231,16 +301,21 @@ func (s *RemoteSigner) RotateKeyset(unit cashu.Unit, fee uint, expiry_limit_hour
 		return fmt.Errorf("CheckIfSignerErrorExists(rotationResponse.GetError()). %w", err)
 	}
 
-	err = s.setupSignerPubkeys()
+	// the new keyset comes from the primary, then the other endpoints are
+	// checked so the ones without it stop being used
+	err = s.check(ctx, primary)
+	if err != nil {
+		return fmt.Errorf("s.check(ctx, primary). %w", err)
+	}
+	err = s.checkAll(ctx)
 	if err != nil {
-		return fmt.Errorf("s.setupSignerPubkeys(). %w", err)
+		return fmt.Errorf("s.checkAll(ctx). %w", err)
 	}
 
 	return nil
 }
 
-func (s *RemoteSigner) SignBlindMessages(messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
-	ctx := context.Background()
+func (s *RemoteSigner) SignBlindMessages(ctx context.Context, messages []cashu.BlindedMessage) ([]cashu.BlindSignature, []cashu.RecoverSigDB, error) {
 	blindedMessageRequest := sig.BlindedMessages{
 		BlindedMessages: make([]*sig.BlindedMessage, len(messages)),
 	}
@@ -260,12 +335,18 @@ func (s *RemoteSigner) SignBlindMessages(messages []cashu.BlindedMessage) ([]cas
 		}
 	}
 
-	blindSigsResponse, err := s.grpcClient.BlindSign(ctx, &blindedMessageRequest)
+	var blindSigsResponse *sig.BlindSignResponse
+	err := s.call(ctx, func(client sig.SignatoryClient) error {
+		var err error
+		blindSigsResponse, err = client.BlindSign(ctx, &blindedMessageRequest)
+		return err
+	})
 	if err != nil {
-		return []cashu.BlindSignature{}, []cashu.RecoverSigDB{}, fmt.Errorf("s.grpcClient.BlindSign(ctx, &blindedMessageRequest). %w", err)
+		return []cashu.BlindSignature{}, []cashu.RecoverSigDB{}, fmt.Errorf("client.BlindSign(ctx, &blindedMessageRequest). %w", err)
 	}
 	err = CheckIfSignerErrorExists(blindSigsResponse.GetError())
 	if err != nil {
+		s.refreshKeysets(ctx, err)
 		return []cashu.BlindSignature{}, []cashu.RecoverSigDB{}, fmt.Errorf("CheckIfSignerErrorExists(blindSigsResponse.GetError()). %w", err)
 	}
 
@@ -296,8 +377,7 @@ func (s *RemoteSigner) SignBlindMessages(messages []cashu.BlindedMessage) ([]cas
 	return blindSigs, recoverySigs, nil
 }
 
-func (s *RemoteSigner) VerifyProofs(proofs []cashu.Proof) error {
-	ctx := context.Background()
+func (s *RemoteSigner) VerifyProofs(ctx context.Context, proofs []cashu.Proof) error {
 	// INFO: we verify locally if the proofs are locked and valid before sending to the crypto signer
 	proofsVericationRequest := sig.Proofs{
 		Proof: make([]*sig.Proof, len(proofs)),
@@ -318,9 +398,14 @@ func (s *RemoteSigner) VerifyProofs(proofs []cashu.Proof) error {
 		}
 	}
 
-	boolResponse, err := s.grpcClient.VerifyProofs(ctx, &proofsVericationRequest)
+	var boolResponse *sig.BooleanResponse
+	err := s.call(ctx, func(client sig.SignatoryClient) error {
+		var err error
+		boolResponse, err = client.VerifyProofs(ctx, &proofsVericationRequest)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("s.grpcClient.VerifyProofs(ctx, &proofsVericationRequest). %w", err)
+		return fmt.Errorf("client.VerifyProofs(ctx, &proofsVericationRequest). %w", err)
 	}
 	if err := signerValidator.Struct(boolResponse); err != nil {
 		return fmt.Errorf("signer verify proofs response validation failed: %w", err)
@@ -328,6 +413,7 @@ func (s *RemoteSigner) VerifyProofs(proofs []cashu.Proof) error {
 
 	err = CheckIfSignerErrorExists(boolResponse.GetError())
 	if err != nil {
+		s.refreshKeysets(ctx, err)
 		return fmt.Errorf("CheckIfSignerErrorExists(boolResponse.GetError()). %w", err)
 	}
 
@@ -338,11 +424,15 @@ func (s *RemoteSigner) VerifyProofs(proofs []cashu.Proof) error {
 }
 
 func (s *RemoteSigner) GetSignerPubkey() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return hex.EncodeToString(s.pubkey), nil
 }
 
 // gets all active keys
 func (l *RemoteSigner) GetAuthActiveKeys() (signer.GetKeysResponse, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	var keys []MintPublicKeyset
 	for _, keyset := range l.activeKeysets {
 		if keyset.Unit == cashu.AUTH.String() {
@@ -358,6 +448,8 @@ func (l *RemoteSigner) GetAuthActiveKeys() (signer.GetKeysResponse, error) {
 }
 
 func (s *RemoteSigner) GetAuthKeysById(id string) (signer.GetKeysResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	val, exists := s.keysets[id]
 	if exists {
 		if val.Unit == cashu.AUTH.String() {
@@ -369,6 +461,8 @@ func (s *RemoteSigner) GetAuthKeysById(id string) (signer.GetKeysResponse, error
 
 // gets all keys from the signer
 func (l *RemoteSigner) GetAuthKeys() (signer.GetKeysetsResponse, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	var response signer.GetKeysetsResponse
 	for _, key := range l.keysets {
 		if key.Unit == cashu.AUTH.String() {
@@ -4,28 +4,34 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
-	"log"
+	"fmt"
 	"os"
 
 	"github.com/lescuer97/nutmix/internal/signer"
 	"google.golang.org/grpc/credentials"
 )
 
+const (
+	ClientTlsCertEnv = "SIGNER_CLIENT_TLS_CERT"
+	ClientTlsKeyEnv  = "SIGNER_CLIENT_TLS_KEY"
+	CaCertEnv        = "SIGNER_CA_CERT"
+)
+
 func GetTlsSecurityCredential() (credentials.TransportCredentials, error) {
-	tlsCertPath := os.Getenv("SIGNER_CLIENT_TLS_CERT")
+	tlsCertPath := os.Getenv(ClientTlsCertEnv)
 	if tlsCertPath == "" {
-		log.Panic("SIGNER_CLIENT_TLS_KEY path not available.")
+		return nil, fmt.Errorf("%v path not available", ClientTlsCertEnv)
 	}
-	tlsKeyPath := os.Getenv("SIGNER_CLIENT_TLS_KEY")
+	tlsKeyPath := os.Getenv(ClientTlsKeyEnv)
 	if tlsKeyPath == "" {
-		log.Panic("SIGNER_CLIENT_TLS_CERT path not available.")
+		return nil, fmt.Errorf("%v path not available", ClientTlsKeyEnv)
 	}
-	caCertPath := os.Getenv("SIGNER_CA_CERT")
+	caCertPath := os.Getenv(CaCertEnv)
 
 	// Load server certificate and key
 	serverCert, err := tls.LoadX509KeyPair(tlsCertPath, tlsKeyPath)
 	if err != nil {
-		log.Fatalf("Failed to load server cert: %v", err)
+		return nil, fmt.Errorf("tls.LoadX509KeyPair(tlsCertPath, tlsKeyPath). %w", err)
 	}
 
 	certPool := x509.NewCertPool()
@@ -33,12 +39,12 @@ func GetTlsSecurityCredential() (credentials.TransportCredentials, error) {
 		// Load CA certificate
 		caCert, err := os.ReadFile(caCertPath)
 		if err != nil {
-			log.Fatalf("Failed to load CA cert: %v", err)
+			return nil, fmt.Errorf("os.ReadFile(caCertPath). %w", err)
 		}
 
 		// Create a certificate pool and add the CA certificate
 		if !certPool.AppendCertsFromPEM(caCert) {
-			log.Fatal("Failed to add CA certificate to pool")
+			return nil, fmt.Errorf("failed to add CA certificate to pool")
 		}
 	}
 
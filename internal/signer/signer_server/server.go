@@ -0,0 +1,360 @@
+// Package signerserver serves a signer over the gRPC signatory protocol, the
+// same one remotesigner speaks, so the master key can live in its own process.
+package signerserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	sig "github.com/lescuer97/nutmix/internal/gen"
+	"github.com/lescuer97/nutmix/internal/signer"
+	remotesigner "github.com/lescuer97/nutmix/internal/signer/remote_signer"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Environment variables of the signer server. Clients always need a
+// certificate signed by SIGNER_CA_CERT, also on the abstract socket.
+const (
+	ListenAddressEnv = "SIGNER_LISTEN_ADDRESS"
+	TlsCertEnv       = "SIGNER_TLS_CERT"
+	TlsKeyEnv        = "SIGNER_TLS_KEY"
+	CaCertEnv        = remotesigner.CaCertEnv
+)
+
+// AbstractSocket is where the abstract_socket signer type of the mint
+// connects.
+const AbstractSocket = "@signer_socket"
+
+var ErrCaMissing = errors.New("a CA certificate is needed to verify signer clients")
+
+// Server implements the signatory service on top of a signer, usually a
+// localsigner.LocalSigner with its own seed store.
+type Server struct {
+	sig.UnimplementedSignatoryServer
+	signer signer.Signer
+	// rotations swap the keysets of the signer, so they wait for the calls
+	// that use them
+	mu sync.RWMutex
+}
+
+func NewServer(s signer.Signer) *Server {
+	return &Server{
+		UnimplementedSignatoryServer: sig.UnimplementedSignatoryServer{},
+		signer:                       s,
+		mu:                           sync.RWMutex{},
+	}
+}
+
+// Credentials loads the server certificate and only accepts clients with a
+// certificate signed by the CA in caCertPath.
+func Credentials(certPath string, keyPath string, caCertPath string) (credentials.TransportCredentials, error) {
+	if caCertPath == "" {
+		return nil, ErrCaMissing
+	}
+	serverCert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("tls.LoadX509KeyPair(certPath, keyPath). %w", err)
+	}
+	caCert, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(caCertPath). %w", err)
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("could not add the signer CA certificate to the pool")
+	}
+
+	//nolint:exhaustruct
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Listen opens the abstract unix socket when address is empty, a unix socket
+// for unix:path and a tcp port otherwise.
+func Listen(address string) (net.Listener, error) {
+	network := "tcp"
+	switch {
+	case address == "":
+		network, address = "unix", AbstractSocket
+	case strings.HasPrefix(address, "unix:"):
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("net.Listen(%s, %s). %w", network, address, err)
+	}
+	return listener, nil
+}
+
+// NewGrpcServer registers server on a grpc server with creds. Clients that
+// send a different schema version are turned away.
+func NewGrpcServer(server *Server, creds credentials.TransportCredentials) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(schemaVersionInterceptor()),
+		// continues the trace of the mint request
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+	)
+	sig.RegisterSignatoryServer(grpcServer, server)
+	return grpcServer
+}
+
+func schemaVersionInterceptor() grpc.UnaryServerInterceptor {
+	expected := strconv.FormatUint(uint64(sig.Constants_CONSTANTS_VERSION), 10)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		versions := md.Get(remotesigner.SchemaVersionHeader)
+		if len(versions) > 0 && versions[0] != expected {
+			return nil, status.Errorf(codes.FailedPrecondition, "unsupported signatory schema version %s, this signer speaks %s", versions[0], expected)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (s *Server) Keysets(ctx context.Context, _ *sig.EmptyRequest) (*sig.KeysResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keysets, err := s.keysets()
+	if err != nil {
+		return &sig.KeysResponse{Error: signerError(err)}, nil
+	}
+	pubkey, err := s.signer.GetSignerPubkey()
+	if err != nil {
+		return &sig.KeysResponse{Error: signerError(err)}, nil
+	}
+	pubkeyBytes, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return &sig.KeysResponse{Error: signerError(fmt.Errorf("hex.DecodeString(pubkey). %w", err))}, nil
+	}
+	return &sig.KeysResponse{
+		Keysets: &sig.SignatoryKeysets{Pubkey: pubkeyBytes, Keysets: keysets},
+	}, nil
+}
+
+// keysets lists the keysets with their keys, the auth ones included.
+func (s *Server) keysets() ([]*sig.KeySet, error) {
+	keysets, err := s.signer.GetKeysets()
+	if err != nil {
+		return nil, fmt.Errorf("s.signer.GetKeysets(). %w", err)
+	}
+	authKeysets, err := s.signer.GetAuthKeys()
+	if err != nil {
+		return nil, fmt.Errorf("s.signer.GetAuthKeys(). %w", err)
+	}
+
+	result := make([]*sig.KeySet, 0, len(keysets.Keysets)+len(authKeysets.Keysets))
+	for _, keyset := range append(keysets.Keysets, authKeysets.Keysets...) {
+		getKeys := s.signer.GetKeysById
+		if keyset.Unit == cashu.AUTH.String() {
+			getKeys = s.signer.GetAuthKeysById
+		}
+		keys, err := getKeys(keyset.Id)
+		if err != nil {
+			return nil, fmt.Errorf("getKeys(%s). %w", keyset.Id, err)
+		}
+		converted, err := convertKeyset(keyset, keys)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+func convertKeyset(keyset cashu.BasicKeysetResponse, keys signer.GetKeysResponse) (*sig.KeySet, error) {
+	id, err := hex.DecodeString(keyset.Id)
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString(%s). %w", keyset.Id, err)
+	}
+	unit, err := unitToSig(keyset.Unit)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes := make(map[uint64][]byte)
+	for _, keysetKeys := range keys.Keysets {
+		for amount, pubkey := range keysetKeys.Keys {
+			keyBytes[amount], err = hex.DecodeString(pubkey)
+			if err != nil {
+				return nil, fmt.Errorf("hex.DecodeString(pubkey). %w", err)
+			}
+		}
+	}
+	return &sig.KeySet{
+		Id:            id,
+		Unit:          unit,
+		Active:        keyset.Active,
+		InputFeePpk:   uint64(keyset.InputFeePpk),
+		Keys:          &sig.Keys{Keys: keyBytes},
+		FinalExpiry:   keyset.FinalExpiry,
+		Version:       keyset.Version,
+		IssuerVersion: nil,
+	}, nil
+}
+
+func unitToSig(unitStr string) (*sig.CurrencyUnit, error) {
+	unit, err := cashu.UnitFromString(unitStr)
+	if err != nil {
+		return nil, fmt.Errorf("cashu.UnitFromString(%s). %w", unitStr, err)
+	}
+	if unit == cashu.USD {
+		return &sig.CurrencyUnit{CurrencyUnit: &sig.CurrencyUnit_Unit{Unit: sig.CurrencyUnitType_CURRENCY_UNIT_TYPE_USD}}, nil
+	}
+	converted, err := remotesigner.ConvertCashuUnitToSignature(unit)
+	if err != nil {
+		return nil, fmt.Errorf("remotesigner.ConvertCashuUnitToSignature(%s). %w", unitStr, err)
+	}
+	return converted, nil
+}
+
+func (s *Server) BlindSign(ctx context.Context, request *sig.BlindedMessages) (*sig.BlindSignResponse, error) {
+	messages := make([]cashu.BlindedMessage, len(request.GetBlindedMessages()))
+	for i, message := range request.GetBlindedMessages() {
+		B_, err := secp256k1.ParsePubKey(message.GetBlindedSecret())
+		if err != nil {
+			return &sig.BlindSignResponse{Error: signerError(errors.Join(cashu.ErrInvalidBlindMessage, err))}, nil
+		}
+		messages[i] = cashu.BlindedMessage{
+			B_:      cashu.WrappedPublicKey{PublicKey: B_},
+			Id:      hex.EncodeToString(message.GetKeysetId()),
+			Witness: "",
+			Amount:  message.GetAmount(),
+		}
+	}
+
+	s.mu.RLock()
+	blindSigs, _, err := s.signer.SignBlindMessages(ctx, messages)
+	s.mu.RUnlock()
+	if err != nil {
+		return &sig.BlindSignResponse{Error: signerError(err)}, nil
+	}
+
+	sigs := make([]*sig.BlindSignature, len(blindSigs))
+	for i, blindSig := range blindSigs {
+		id, err := hex.DecodeString(blindSig.Id)
+		if err != nil {
+			return &sig.BlindSignResponse{Error: signerError(fmt.Errorf("hex.DecodeString(blindSig.Id). %w", err))}, nil
+		}
+		sigs[i] = &sig.BlindSignature{
+			Amount:        blindSig.Amount,
+			KeysetId:      id,
+			BlindedSecret: blindSig.C_.SerializeCompressed(),
+			Dleq:          nil,
+		}
+		if blindSig.Dleq != nil {
+			sigs[i].Dleq = &sig.BlindSignatureDLEQ{
+				E: blindSig.Dleq.E.Serialize(),
+				S: blindSig.Dleq.S.Serialize(),
+			}
+		}
+	}
+	return &sig.BlindSignResponse{Sigs: &sig.BlindSignatures{BlindSignatures: sigs}}, nil
+}
+
+func (s *Server) VerifyProofs(ctx context.Context, request *sig.Proofs) (*sig.BooleanResponse, error) {
+	proofs := make([]cashu.Proof, len(request.GetProof()))
+	for i, proof := range request.GetProof() {
+		C, err := secp256k1.ParsePubKey(proof.GetC())
+		if err != nil {
+			return &sig.BooleanResponse{Error: signerError(errors.Join(cashu.ErrInvalidProof, err))}, nil
+		}
+		//nolint:exhaustruct
+		proofs[i] = cashu.Proof{
+			C:      cashu.WrappedPublicKey{PublicKey: C},
+			Id:     hex.EncodeToString(proof.GetKeysetId()),
+			Secret: string(proof.GetSecret()),
+			Amount: proof.GetAmount(),
+		}
+	}
+
+	s.mu.RLock()
+	err := s.signer.VerifyProofs(ctx, proofs)
+	s.mu.RUnlock()
+	if err != nil {
+		return &sig.BooleanResponse{Error: signerError(err)}, nil
+	}
+	return &sig.BooleanResponse{Success: true}, nil
+}
+
+// RotateKeyset creates a new active keyset for the unit with the amounts of
+// the request and returns it. The keyset version of the request is not used,
+// the signer always creates keysets with the current keyset id version.
+func (s *Server) RotateKeyset(ctx context.Context, request *sig.RotationRequest) (*sig.KeyRotationResponse, error) {
+	unit, err := remotesigner.ConvertSigUnitToCashuUnit(request.GetUnit())
+	if err != nil {
+		return &sig.KeyRotationResponse{Error: &sig.Error{Code: sig.ErrorCode_ERROR_CODE_UNIT_NOT_SUPPORTED, Detail: err.Error()}}, nil
+	}
+	expiryHours := uint(0)
+	if request.FinalExpiry != nil {
+		until := time.Until(time.Unix(int64(request.GetFinalExpiry()), 0))
+		if until > 0 {
+			expiryHours = uint(until.Hours())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.signer.RotateKeyset(unit, uint(request.GetInputFeePpk()), expiryHours, request.GetAmounts())
+	if err != nil {
+		return &sig.KeyRotationResponse{Error: &sig.Error{Code: sig.ErrorCode_ERROR_CODE_COULD_NOT_ROTATE_KEYSET, Detail: err.Error()}}, nil
+	}
+
+	keysets, err := s.keysets()
+	if err != nil {
+		return &sig.KeyRotationResponse{Error: signerError(err)}, nil
+	}
+	for _, keyset := range keysets {
+		keysetUnit, err := remotesigner.ConvertSigUnitToCashuUnit(keyset.Unit)
+		if err == nil && keysetUnit == unit && keyset.Active {
+			return &sig.KeyRotationResponse{Keyset: keyset}, nil
+		}
+	}
+	return &sig.KeyRotationResponse{Error: &sig.Error{Code: sig.ErrorCode_ERROR_CODE_COULD_NOT_ROTATE_KEYSET, Detail: "no active keyset after the rotation"}}, nil
+}
+
+// signerError maps the errors of the signer to the error codes of the
+// protocol, the reverse of remotesigner.CheckIfSignerErrorExists.
+func signerError(err error) *sig.Error {
+	code := sig.ErrorCode_ERROR_CODE_UNSPECIFIED
+	switch {
+	case errors.Is(err, cashu.ErrMessageAmountToBig):
+		code = sig.ErrorCode_ERROR_CODE_AMOUNT_OUTSIDE_LIMIT
+	case errors.Is(err, cashu.ErrRepeatedInput):
+		code = sig.ErrorCode_ERROR_CODE_DUPLICATE_INPUTS_PROVIDED
+	case errors.Is(err, cashu.ErrRepeatedOutput):
+		code = sig.ErrorCode_ERROR_CODE_DUPLICATE_OUTPUTS_PROVIDED
+	case errors.Is(err, cashu.ErrKeysetNotKnow), errors.Is(err, cashu.ErrKeysetNotFound), errors.Is(err, signer.ErrNoKeysetFound):
+		code = sig.ErrorCode_ERROR_CODE_KEYSET_NOT_KNOWN
+	case errors.Is(err, cashu.ErrUsingInactiveKeyset):
+		code = sig.ErrorCode_ERROR_CODE_KEYSET_INACTIVE
+	case errors.Is(err, cashu.ErrInvalidProof):
+		code = sig.ErrorCode_ERROR_CODE_INVALID_PROOF
+	case errors.Is(err, cashu.ErrInvalidBlindMessage):
+		code = sig.ErrorCode_ERROR_CODE_INVALID_BLIND_MESSAGE
+	case errors.Is(err, cashu.ErrUnitNotSupported):
+		code = sig.ErrorCode_ERROR_CODE_UNIT_NOT_SUPPORTED
+	}
+	return &sig.Error{Code: code, Detail: err.Error()}
+}
@@ -0,0 +1,134 @@
+//nolint:exhaustruct
+package signerserver
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/api/cashu"
+	mockdb "github.com/lescuer97/nutmix/internal/database/mock_db"
+	localsigner "github.com/lescuer97/nutmix/internal/signer/local_signer"
+	remotesigner "github.com/lescuer97/nutmix/internal/signer/remote_signer"
+	"github.com/lescuer97/nutmix/pkg/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// remoteClient serves local over an in memory connection and connects the
+// remote signer of the mint to it.
+func remoteClient(t *testing.T, local *localsigner.LocalSigner) *remotesigner.RemoteSigner {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewGrpcServer(NewServer(local), insecure.NewCredentials())
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///signer",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	remote, err := remotesigner.NewRemoteSigner(conn)
+	if err != nil {
+		t.Fatalf("remotesigner.NewRemoteSigner: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = remote.Close()
+	})
+	return remote
+}
+
+func TestServerWithRemoteSigner(t *testing.T) {
+	privateKey, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+	db := mockdb.MockDB{}
+	local, err := localsigner.SetupLocalSignerWithKey(&db, privateKey)
+	if err != nil {
+		t.Fatalf("localsigner.SetupLocalSignerWithKey: %v", err)
+	}
+	remote := remoteClient(t, &local)
+
+	localPubkey, _ := local.GetSignerPubkey()
+	remotePubkey, _ := remote.GetSignerPubkey()
+	if localPubkey != remotePubkey {
+		t.Errorf("expected the pubkey %s, got %s", localPubkey, remotePubkey)
+	}
+	active, err := remote.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 {
+		t.Fatalf("expected one active keyset, got %+v, %v", active, err)
+	}
+	keyset := active.Keysets[0]
+	localKeys, err := local.GetKeysById(keyset.Id)
+	if err != nil {
+		t.Fatalf("local.GetKeysById: %v", err)
+	}
+	if len(keyset.Keys) == 0 || keyset.Keys[8] != localKeys.Keysets[0].Keys[8] {
+		t.Errorf("the remote keys do not match the local ones")
+	}
+
+	// sign through the server and unblind the signature into a proof
+	secret := "server test secret"
+	r, _ := secp256k1.GeneratePrivateKey()
+	B_, r, err := crypto.BlindMessage(secret, r)
+	if err != nil {
+		t.Fatalf("crypto.BlindMessage: %v", err)
+	}
+	sigs, _, err := remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{{B_: cashu.WrappedPublicKey{PublicKey: B_}, Id: keyset.Id, Amount: 8}})
+	if err != nil {
+		t.Fatalf("remote.SignBlindMessages: %v", err)
+	}
+	if len(sigs) != 1 || sigs[0].Dleq == nil {
+		t.Fatalf("expected a signature with dleq, got %+v", sigs)
+	}
+	K, _ := hex.DecodeString(keyset.Keys[8])
+	pubkey, _ := secp256k1.ParsePubKey(K)
+	C := crypto.UnblindSignature(sigs[0].C_.PublicKey, r, pubkey)
+
+	proof := cashu.Proof{C: cashu.WrappedPublicKey{PublicKey: C}, Id: keyset.Id, Secret: secret, Amount: 8}
+	err = remote.VerifyProofs(t.Context(), []cashu.Proof{proof})
+	if err != nil {
+		t.Errorf("remote.VerifyProofs: %v", err)
+	}
+	proof.Secret = "another secret"
+	err = remote.VerifyProofs(t.Context(), []cashu.Proof{proof})
+	if !errors.Is(err, cashu.ErrInvalidProof) {
+		t.Errorf("expected cashu.ErrInvalidProof, got %v", err)
+	}
+
+	_, _, err = remote.SignBlindMessages(t.Context(), []cashu.BlindedMessage{{B_: cashu.WrappedPublicKey{PublicKey: B_}, Id: "00ffffffffffffff", Amount: 8}})
+	if !errors.Is(err, cashu.ErrKeysetNotFound) {
+		t.Errorf("expected an unknown keyset to fail with cashu.ErrKeysetNotFound, got %v", err)
+	}
+
+	err = remote.RotateKeyset(cashu.Sat, 100, 24, []uint64{1, 2, 5, 10})
+	if err != nil {
+		t.Fatalf("remote.RotateKeyset: %v", err)
+	}
+	active, err = remote.GetActiveKeys()
+	if err != nil || len(active.Keysets) != 1 || active.Keysets[0].Id == keyset.Id {
+		t.Errorf("expected a new active keyset, got %+v, %v", active, err)
+	}
+	if len(active.Keysets) == 1 && (len(active.Keysets[0].Keys) != 4 || active.Keysets[0].Keys[5] == "") {
+		t.Errorf("expected the amounts of the rotation in the new keyset, got %v", active.Keysets[0].Keys)
+	}
+	keysets, err := remote.GetKeysets()
+	if err != nil || len(keysets.Keysets) != 2 {
+		t.Errorf("expected both keysets, got %+v, %v", keysets, err)
+	}
+	if len(db.Seeds) != 2 {
+		t.Errorf("expected the new seed in the store of the signer, got %d", len(db.Seeds))
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"github.com/lescuer97/nutmix/api/cashu"
 )
 
-var ErrNoKeysetFound = errors.New("no keyset found")
+var (
+	ErrNoKeysetFound = errors.New("no keyset found")
+	ErrSignerLocked  = errors.New("the signer is locked")
+)
 
 type GetKeysResponse struct {
 	Keysets []KeysetResponse `json:"keysets"`
@@ -38,24 +38,11 @@ type SnapshotResult struct {
 	EndDate   int64
 }
 
-type ticker interface {
-	C() <-chan time.Time
-	Stop()
-}
-
-type realTicker struct {
-	ticker *time.Ticker
-}
-
-func (t realTicker) C() <-chan time.Time { return t.ticker.C }
-func (t realTicker) Stop()               { t.ticker.Stop() }
-
 type Service struct {
 	DB               Store
 	Now              func() time.Time
 	DecodeMintAmount func(request string) (uint64, error)
 	Logger           *slog.Logger
-	NewTicker        func(interval time.Duration) ticker
 	runSnapshot      func(ctx context.Context) (SnapshotResult, error)
 }
 
@@ -73,13 +60,6 @@ func (s Service) logger() *slog.Logger {
 	return slog.Default()
 }
 
-func (s Service) newTicker(interval time.Duration) ticker {
-	if s.NewTicker != nil {
-		return s.NewTicker(interval)
-	}
-	return realTicker{ticker: time.NewTicker(interval)}
-}
-
 func (s Service) snapshotRunner(ctx context.Context) (SnapshotResult, error) {
 	if s.runSnapshot != nil {
 		return s.runSnapshot(ctx)
@@ -265,20 +245,10 @@ func (s Service) logResult(result SnapshotResult, err error) {
 	logger.Info("stats snapshot inserted", attrs...)
 }
 
-func (s Service) Run(ctx context.Context, interval time.Duration) {
+// RunOnce creates a single snapshot. The job scheduler calls it on every
+// interval.
+func (s Service) RunOnce(ctx context.Context) error {
 	result, err := s.snapshotRunner(ctx)
 	s.logResult(result, err)
-
-	t := s.newTicker(interval)
-	defer t.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C():
-			result, err := s.snapshotRunner(ctx)
-			s.logResult(result, err)
-		}
-	}
+	return err
 }
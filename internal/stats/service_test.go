@@ -8,7 +8,6 @@ import (
 	"log/slog"
 	"slices"
 	"strings"
-	"sync"
 	"testing"
 	"time"
 
@@ -418,105 +417,8 @@ func TestCreateSnapshotRoundsFeesLikeAdmin(t *testing.T) {
 	}
 }
 
-type fakeTicker struct{ ch chan time.Time }
-
-func (f fakeTicker) C() <-chan time.Time { return f.ch }
-func (f fakeTicker) Stop()               {}
-
-func TestRunStartsWithImmediateSnapshotAttempt(t *testing.T) {
-	service := Service{} //nolint:exhaustruct
-	called := make(chan struct{}, 1)
-	service.runSnapshot = func(context.Context) (SnapshotResult, error) {
-		called <- struct{}{}
-		return SnapshotResult{}, nil //nolint:exhaustruct
-	}
-	service.NewTicker = func(time.Duration) ticker { return fakeTicker{ch: make(chan time.Time)} }
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go service.Run(ctx, time.Hour)
-
-	select {
-	case <-called:
-	case <-time.After(time.Second):
-		t.Fatal("expected immediate snapshot attempt")
-	}
-}
-
-func TestRunStopsWhenContextCancelled(t *testing.T) {
-	service := Service{} //nolint:exhaustruct
-	tickCh := make(chan time.Time)
-	service.runSnapshot = func(context.Context) (SnapshotResult, error) { return SnapshotResult{}, nil } //nolint:exhaustruct
-	service.NewTicker = func(time.Duration) ticker { return fakeTicker{ch: tickCh} }
-
-	ctx, cancel := context.WithCancel(context.Background())
-	done := make(chan struct{})
-	go func() {
-		service.Run(ctx, time.Hour)
-		close(done)
-	}()
-	cancel()
-
-	select {
-	case <-done:
-	case <-time.After(time.Second):
-		t.Fatal("expected Run to stop on cancellation")
-	}
-}
-
-func TestRunDoesNotOverlapSnapshotAttempts(t *testing.T) {
+func TestRunOnceLogsAndReturnsSnapshotErrors(t *testing.T) {
 	service := Service{} //nolint:exhaustruct
-	tickCh := make(chan time.Time, 2)
-	start := make(chan struct{}, 2)
-	release := make(chan struct{})
-	completed := make(chan struct{}, 2)
-	var running int
-	var mu sync.Mutex
-
-	service.runSnapshot = func(context.Context) (SnapshotResult, error) {
-		mu.Lock()
-		running++
-		if running > 1 {
-			mu.Unlock()
-			t.Fatal("overlapping snapshot attempts detected")
-		}
-		mu.Unlock()
-		start <- struct{}{}
-		<-release
-		mu.Lock()
-		running--
-		mu.Unlock()
-		completed <- struct{}{}
-		return SnapshotResult{}, nil //nolint:exhaustruct
-	}
-	service.NewTicker = func(time.Duration) ticker { return fakeTicker{ch: tickCh} }
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go service.Run(ctx, time.Hour)
-	<-start
-	tickCh <- time.Now()
-	tickCh <- time.Now()
-	time.Sleep(50 * time.Millisecond)
-	if len(start) != 0 {
-		t.Fatal("expected no overlapping start before first snapshot completes")
-	}
-	close(release)
-	select {
-	case <-completed:
-	case <-time.After(time.Second):
-		t.Fatal("expected first completion")
-	}
-	select {
-	case <-start:
-	case <-time.After(time.Second):
-		t.Fatal("expected queued snapshot to start after first completion")
-	}
-}
-
-func TestRunContinuesAfterSnapshotError(t *testing.T) {
-	service := Service{} //nolint:exhaustruct
-	tickCh := make(chan time.Time, 1)
 	var calls int
 	service.runSnapshot = func(context.Context) (SnapshotResult, error) {
 		calls++
@@ -525,19 +427,14 @@ func TestRunContinuesAfterSnapshotError(t *testing.T) {
 		}
 		return SnapshotResult{Outcome: SnapshotSkipped, StartDate: 0, EndDate: 10}, nil
 	}
-	service.NewTicker = func(time.Duration) ticker { return fakeTicker{ch: tickCh} }
 	var logBuf bytes.Buffer
 	service.Logger = slog.New(slog.NewJSONHandler(&logBuf, nil))
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go service.Run(ctx, time.Hour)
-	time.Sleep(50 * time.Millisecond)
-	tickCh <- time.Now()
-	time.Sleep(50 * time.Millisecond)
-
-	if calls < 2 {
-		t.Fatalf("expected service to continue after error, got %d calls", calls)
+	if err := service.RunOnce(context.Background()); err == nil {
+		t.Fatal("expected the snapshot error to be returned")
+	}
+	if err := service.RunOnce(context.Background()); err != nil {
+		t.Fatalf("expected a skipped snapshot to succeed, got %v", err)
 	}
 	if !bytes.Contains(logBuf.Bytes(), []byte("stats snapshot failed")) {
 		t.Fatalf("expected error log, got %s", logBuf.String())
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength keeps client supplied ids from bloating the logs.
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// GinMiddleware opens a span for every request and gives it a request id.
+// The id is taken from the X-Request-Id header when a proxy already set one.
+func GinMiddleware() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		otelgin.Middleware(ServiceName),
+		requestIDMiddleware,
+	}
+}
+
+func requestIDMiddleware(c *gin.Context) {
+	requestID := c.GetHeader(RequestIDHeader)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = uuid.NewString()
+	}
+	c.Header(RequestIDHeader, requestID)
+
+	ctx := WithRequestID(c.Request.Context(), requestID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// LogHandler adds the request id and the trace and span ids to records logged
+// with a context, so logs can be matched with their trace.
+type LogHandler struct {
+	base slog.Handler
+}
+
+func NewLogHandler(base slog.Handler) *LogHandler {
+	return &LogHandler{base: base}
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.base.Enabled(ctx, level)
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestID := RequestID(ctx); requestID != "" {
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+		spanContext := trace.SpanContextFromContext(ctx)
+		if spanContext.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", spanContext.TraceID().String()),
+				slog.String("span_id", spanContext.SpanID().String()),
+			)
+		}
+	}
+	return h.base.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{base: h.base.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{base: h.base.WithGroup(name)}
+}
@@ -0,0 +1,116 @@
+//nolint:exhaustruct,govet
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupStdout(t *testing.T) (*bytes.Buffer, func()) {
+	t.Helper()
+	previous := otel.GetTracerProvider()
+	var out bytes.Buffer
+	shutdown, err := Setup(context.Background(), ExporterStdout, &out)
+	if err != nil {
+		t.Fatalf("Setup(ExporterStdout): %v", err)
+	}
+	return &out, func() {
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+		otel.SetTracerProvider(previous)
+	}
+}
+
+func TestSpansAreExportedWithParent(t *testing.T) {
+	out, shutdown := setupStdout(t)
+
+	ctx, parent := Start(context.Background(), "Mint.ExecuteMelt")
+	_, child := Start(ctx, "Lightning.PayInvoice")
+	End(child, errors.New("no route"))
+	End(parent, nil)
+	shutdown()
+
+	exported := out.String()
+	if !strings.Contains(exported, `"Name":"Mint.ExecuteMelt"`) || !strings.Contains(exported, `"Name":"Lightning.PayInvoice"`) {
+		t.Fatalf("expected both spans in the export, got %s", exported)
+	}
+	if !strings.Contains(exported, parent.SpanContext().SpanID().String()) {
+		t.Fatalf("expected the child span to reference its parent")
+	}
+	if !strings.Contains(exported, "no route") {
+		t.Fatalf("expected the child error to be recorded")
+	}
+}
+
+func TestUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), "zipkin", nil)
+	if err == nil {
+		t.Fatal("expected an unknown exporter to fail")
+	}
+}
+
+func TestRequestIDIsLoggedWithTrace(t *testing.T) {
+	_, shutdown := setupStdout(t)
+	defer shutdown()
+
+	var logs bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&logs, nil)))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware()...)
+	var spanContext trace.SpanContext
+	r.GET("/v1/swap", func(c *gin.Context) {
+		spanContext = trace.SpanContextFromContext(c.Request.Context())
+		logger.InfoContext(c.Request.Context(), "swap failed")
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/swap", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get(RequestIDHeader) != "req-1" {
+		t.Fatalf("expected the request id to be echoed, got %q", w.Header().Get(RequestIDHeader))
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("json.Unmarshal(logs): %v", err)
+	}
+	if record["request_id"] != "req-1" {
+		t.Fatalf("expected request_id in the log, got %v", record)
+	}
+	if record["trace_id"] != spanContext.TraceID().String() {
+		t.Fatalf("expected trace_id %s in the log, got %v", spanContext.TraceID(), record["trace_id"])
+	}
+}
+
+func TestRequestIDIsGeneratedWhenMissing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware()...)
+	var requestID string
+	r.GET("/", func(c *gin.Context) {
+		requestID = RequestID(c.Request.Context())
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if requestID == "" || w.Header().Get(RequestIDHeader) != requestID {
+		t.Fatalf("expected a generated request id, got %q and header %q", requestID, w.Header().Get(RequestIDHeader))
+	}
+}
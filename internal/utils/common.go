@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	"github.com/lescuer97/nutmix/api/cashu"
 	"github.com/lescuer97/nutmix/internal/lightning"
@@ -14,6 +15,9 @@ const ConfigFileName string = "config.toml"
 const ConfigDirName string = "nutmix"
 const LogFileName string = "nutmix.log"
 
+// DefaultQuoteTtl is how long mint and melt quotes are valid, in seconds.
+const DefaultQuoteTtl uint64 = 15 * 60
+
 type LightningBackend string
 
 const FAKE_WALLET LightningBackend = "FakeWallet"
@@ -71,8 +75,11 @@ type Config struct {
 	MINT_AUTH_OICD_URL              string           `db:"mint_auth_oicd_url,omitempty"`
 	MINT_AUTH_CLEAR_AUTH_URLS       []string         `db:"mint_auth_clear_auth_urls,omitempty"`
 	MINT_AUTH_BLIND_AUTH_URLS       []string         `db:"mint_auth_blind_auth_urls,omitempty"`
+	URLS                            []string         `db:"urls,omitempty"`
 	MINT_AUTH_RATE_LIMIT_PER_MINUTE int              `db:"mint_auth_rate_limit_per_minute,omitempty"`
 	MINT_AUTH_MAX_BLIND_TOKENS      uint64           `db:"mint_auth_max_blind_tokens,omitempty"`
+	MINT_QUOTE_TTL                  uint64           `db:"mint_quote_ttl"`
+	MELT_QUOTE_TTL                  uint64           `db:"melt_quote_ttl"`
 	MINT_REQUIRE_AUTH               bool             `db:"mint_require_auth,omitempty"`
 	PEG_OUT_ONLY                    bool             `db:"peg_out_only"`
 }
@@ -89,6 +96,7 @@ func (c *Config) Default() {
 	c.DESCRIPTION = ""
 	c.IconUrl = nil
 	c.TosUrl = nil
+	c.URLS = []string{}
 	c.DESCRIPTION_LONG = ""
 	c.MOTD = ""
 	c.EMAIL = ""
@@ -109,6 +117,9 @@ func (c *Config) Default() {
 	c.PEG_OUT_LIMIT_SATS = nil
 	c.PEG_IN_LIMIT_SATS = nil
 
+	c.MINT_QUOTE_TTL = DefaultQuoteTtl
+	c.MELT_QUOTE_TTL = DefaultQuoteTtl
+
 	c.MINT_REQUIRE_AUTH = false
 	c.MINT_AUTH_OICD_CLIENT_ID = ""
 	c.MINT_AUTH_MAX_BLIND_TOKENS = 100
@@ -124,6 +135,7 @@ func (c *Config) UseEnviromentVars() {
 	c.DESCRIPTION = os.Getenv("DESCRIPTION")
 	c.IconUrl = nil
 	c.TosUrl = nil
+	c.URLS = []string{}
 	c.DESCRIPTION_LONG = os.Getenv("DESCRIPTION_LONG")
 	c.MOTD = os.Getenv("MOTD")
 	c.EMAIL = os.Getenv("EMAIL")
@@ -139,7 +151,26 @@ func (c *Config) UseEnviromentVars() {
 
 	c.MINT_LNBITS_ENDPOINT = os.Getenv("MINT_LNBITS_ENDPOINT")
 	c.MINT_LNBITS_KEY = os.Getenv("MINT_LNBITS_KEY")
+
+	c.MINT_QUOTE_TTL = DefaultQuoteTtl
+	c.MELT_QUOTE_TTL = DefaultQuoteTtl
 }
+
+// QuoteTtlOrDefault falls back to the default ttl for configs saved before
+// quote ttls existed.
+func QuoteTtlOrDefault(ttl uint64) uint64 {
+	if ttl == 0 {
+		return DefaultQuoteTtl
+	}
+	return ttl
+}
+
+// QuoteExpiry is the unix time a quote created at now expires, ttl is in
+// seconds.
+func QuoteExpiry(ttl uint64, now time.Time) int64 {
+	return now.Add(time.Duration(QuoteTtlOrDefault(ttl)) * time.Second).Unix()
+}
+
 func RandomHash() (string, error) {
 	// Create a byte slice of 30 random bytes
 	randomBytes := make([]byte, 30)
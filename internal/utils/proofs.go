@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/lescuer97/nutmix/api/cashu"
+	"github.com/lescuer97/nutmix/pkg/crypto"
 )
 
 func ParseErrorToCashuErrorCode(proofError error) (cashu.ErrorCode, *string) {
@@ -56,6 +57,9 @@ func ParseErrorToCashuErrorCode(proofError error) (cashu.ErrorCode, *string) {
 
 	case errors.Is(proofError, cashu.ErrUsingInactiveKeyset):
 		return cashu.INACTIVE_KEYSET, nil
+	case errors.Is(proofError, cashu.ErrKeysetExpired):
+		message := cashu.ErrKeysetExpired.Error()
+		return cashu.INACTIVE_KEYSET, &message
 	case errors.Is(proofError, cashu.ErrMeltAlreadyPaid):
 		message := cashu.ErrMeltAlreadyPaid.Error()
 		return cashu.INVOICE_ALREADY_PAID, &message
@@ -141,25 +145,36 @@ func ParseErrorToCashuErrorCode(proofError error) (cashu.ErrorCode, *string) {
 // Sets the y and seen at field in by references and returns the Y's array
 func GetAndCalculateProofsValues(proofs *cashu.Proofs) ([]cashu.WrappedPublicKey, error) {
 	now := time.Now().Unix()
-	var totalAmount uint64
 	secretsList := make([]cashu.WrappedPublicKey, len(*proofs))
-	for i, proof := range *proofs {
-		totalAmount += proof.Amount
-
-		p, err := proof.HashSecretToCurve()
-
+	for _, proof := range *proofs {
+		if proof.C.PublicKey == nil {
+			return nil, cashu.ErrInvalidProof
+		}
+	}
+	err := crypto.ForEach(len(*proofs), func(i int) error {
+		p, err := (*proofs)[i].HashSecretToCurve()
 		if err != nil {
-			return nil, fmt.Errorf("proof.HashSecretToCurve(). %w", err)
+			return fmt.Errorf("proof.HashSecretToCurve(). %w", err)
 		}
 		secretsList[i] = p.Y
 		(*proofs)[i] = p
 		(*proofs)[i].SeenAt = now
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return secretsList, nil
 }
-func GetMessagesForChange(overpaidFees uint64, outputs []cashu.BlindedMessage) []cashu.BlindedMessage {
+
+// GetMessagesForChange sets the amounts of the change in outputs, split into
+// the denominations of their keyset. Without denominations it uses powers of two.
+func GetMessagesForChange(overpaidFees uint64, outputs []cashu.BlindedMessage, denominations []uint64) []cashu.BlindedMessage {
 	amounts := cashu.AmountSplit(overpaidFees)
+	if len(denominations) > 0 {
+		amounts = cashu.AmountSplitWithDenominations(overpaidFees, denominations, len(outputs))
+	}
 	// if there are more outputs then amount to change.
 	// we size down the total amount of blind messages
 	switch {
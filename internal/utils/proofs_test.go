@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/lescuer97/nutmix/api/cashu"
 )
 
@@ -24,7 +26,7 @@ func TestGetChangeWithEnoughBlindMessages(t *testing.T) {
 	emptyBlindMessages := setListofEmptyBlindMessages(10)
 
 	// create change for value of 2
-	change := GetMessagesForChange(2, emptyBlindMessages)
+	change := GetMessagesForChange(2, emptyBlindMessages, nil)
 
 	if len(change) != 1 {
 		t.Errorf("Incorrect size for change slice %v, should be 1", len(change))
@@ -35,7 +37,7 @@ func TestGetChangeWithEnoughBlindMessages(t *testing.T) {
 	}
 
 	// create change for a 0 amount
-	change = GetMessagesForChange(0, emptyBlindMessages)
+	change = GetMessagesForChange(0, emptyBlindMessages, nil)
 
 	if len(change) != 0 {
 		t.Errorf("Incorrect size for change slice %v, should be 0", len(change))
@@ -46,7 +48,7 @@ func TestGetChangeWithOutEnoughBlindMessages(t *testing.T) {
 	emptyBlindMessages := setListofEmptyBlindMessages(1)
 
 	// create change for value of 2
-	change := GetMessagesForChange(10, emptyBlindMessages)
+	change := GetMessagesForChange(10, emptyBlindMessages, nil)
 
 	if len(change) != 1 {
 		t.Errorf("Incorrect size for change slice %v, should be 1", len(change))
@@ -57,6 +59,35 @@ func TestGetChangeWithOutEnoughBlindMessages(t *testing.T) {
 	}
 }
 
+func TestGetChangeWithDenominations(t *testing.T) {
+	emptyBlindMessages := setListofEmptyBlindMessages(10)
+
+	change := GetMessagesForChange(35, emptyBlindMessages, []uint64{1, 5, 10, 25})
+
+	if len(change) != 2 {
+		t.Fatalf("Incorrect size for change slice %v, should be 2", len(change))
+	}
+	if change[0].Amount != 10 || change[1].Amount != 25 {
+		t.Errorf("Incorrect amounts for change %v and %v, should be 10 and 25", change[0].Amount, change[1].Amount)
+	}
+}
+
+func TestGetChangeWithSparseDenominations(t *testing.T) {
+	emptyBlindMessages := setListofEmptyBlindMessages(3)
+
+	// a split into ones would take 2^40 parts
+	change := GetMessagesForChange(1<<40-1, emptyBlindMessages, []uint64{1, 1 << 40})
+
+	if len(change) != 3 {
+		t.Fatalf("Incorrect size for change slice %v, should be 3", len(change))
+	}
+	for _, message := range change {
+		if message.Amount != 1 {
+			t.Errorf("Incorrect amount for change %v, should be 1", message.Amount)
+		}
+	}
+}
+
 func MakeListofMockProofs(amounts int) []cashu.Proof {
 	var proofs []cashu.Proof
 	for i := 0; i < amounts; i++ {
@@ -78,9 +109,10 @@ func MakeListofMockProofs(amounts int) []cashu.Proof {
 }
 
 func TestGetValuesFromProofs(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
 	listOfProofs := cashu.Proofs{
 		{
-			C:       cashu.WrappedPublicKey{PublicKey: nil},
+			C:       cashu.WrappedPublicKey{PublicKey: key.PubKey()},
 			Y:       cashu.WrappedPublicKey{PublicKey: nil},
 			Quote:   nil,
 			Id:      "mockid",
@@ -91,7 +123,7 @@ func TestGetValuesFromProofs(t *testing.T) {
 			SeenAt:  0,
 		},
 		{
-			C:       cashu.WrappedPublicKey{PublicKey: nil},
+			C:       cashu.WrappedPublicKey{PublicKey: key.PubKey()},
 			Y:       cashu.WrappedPublicKey{PublicKey: nil},
 			Quote:   nil,
 			Id:      "mockid",
@@ -119,3 +151,10 @@ func TestGetValuesFromProofs(t *testing.T) {
 		t.Errorf("Incorrect Y: %v. ", listOfProofs[0].Y)
 	}
 }
+
+func TestGetValuesFromProofsRejectsNilC(t *testing.T) {
+	_, err := GetAndCalculateProofsValues(&cashu.Proofs{{Id: "mockid", Secret: "mockSecret", Amount: 2}}) //nolint:exhaustruct
+	if !errors.Is(err, cashu.ErrInvalidProof) {
+		t.Errorf("expected cashu.ErrInvalidProof, got %v", err)
+	}
+}
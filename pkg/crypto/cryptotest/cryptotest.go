@@ -0,0 +1,19 @@
+// Package cryptotest has helpers for the tests of the packages that sign and
+// verify on the workers of crypto.ForEach.
+package cryptotest
+
+import (
+	"testing"
+
+	"github.com/lescuer97/nutmix/pkg/crypto"
+)
+
+// SetWorkers sets the workers of crypto.ForEach until the end of the test.
+func SetWorkers(t testing.TB, n int) {
+	t.Helper()
+	previous := crypto.Workers()
+	crypto.SetWorkers(n)
+	t.Cleanup(func() {
+		crypto.SetWorkers(previous)
+	})
+}
@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// WorkersEnv sets how many goroutines sign and verify a batch, empty uses one
+// per CPU and 1 keeps everything on the calling goroutine.
+const WorkersEnv = "CRYPTO_WORKERS"
+
+// batches smaller than this are not worth starting goroutines for
+const minParallelBatch = 8
+
+var workers atomic.Int64
+
+var ErrWorkerPanic = errors.New("a crypto worker panicked")
+
+// SetWorkers sets the goroutines ForEach uses, n < 1 uses one per CPU.
+func SetWorkers(n int) {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	workers.Store(int64(n))
+}
+
+// SetWorkersFromEnv reads CRYPTO_WORKERS.
+func SetWorkersFromEnv() error {
+	value := os.Getenv(WorkersEnv)
+	if value == "" {
+		SetWorkers(0)
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("%s has to be a positive number, got %q", WorkersEnv, value)
+	}
+	SetWorkers(n)
+	return nil
+}
+
+// Workers returns the goroutines ForEach uses.
+func Workers() int {
+	n := int(workers.Load())
+	if n < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// call runs fn and returns a panic as ErrWorkerPanic, a panic on a worker
+// goroutine would take the whole mint down instead of failing the request.
+func call(fn func(i int) error, i int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w at index %d: %v", ErrWorkerPanic, i, r)
+		}
+	}()
+	return fn(i)
+}
+
+// ForEach calls fn for every index below n on up to Workers goroutines. It
+// returns the error of the lowest index that failed, the same one a loop that
+// stops at the first error returns, so callers get the results of the serial
+// path. Indexes after a failed one may be skipped, and a panic in fn is
+// returned as ErrWorkerPanic.
+func ForEach(n int, fn func(i int) error) error {
+	parallelism := min(Workers(), n)
+	if parallelism <= 1 || n < minParallelBatch {
+		for i := range n {
+			err := call(fn, i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	var next atomic.Int64
+	var firstFailed atomic.Int64
+	firstFailed.Store(int64(n))
+	var wg sync.WaitGroup
+	for range parallelism {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n || int64(i) > firstFailed.Load() {
+					return
+				}
+				err := call(fn, i)
+				if err == nil {
+					continue
+				}
+				errs[i] = err
+				for {
+					failed := firstFailed.Load()
+					if int64(i) >= failed || firstFailed.CompareAndSwap(failed, int64(i)) {
+						break
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
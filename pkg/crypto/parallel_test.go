@@ -0,0 +1,125 @@
+package crypto_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/lescuer97/nutmix/pkg/crypto"
+	"github.com/lescuer97/nutmix/pkg/crypto/cryptotest"
+)
+
+func TestForEachReturnsFirstError(t *testing.T) {
+	cryptotest.SetWorkers(t, 4)
+	errFirst := errors.New("first")
+	for range 50 {
+		var calls [100]atomic.Bool
+		err := crypto.ForEach(100, func(i int) error {
+			calls[i].Store(true)
+			switch i {
+			case 37:
+				return errFirst
+			case 80, 90:
+				return fmt.Errorf("later %d", i)
+			}
+			return nil
+		})
+		if !errors.Is(err, errFirst) {
+			t.Fatalf("expected the error of the lowest index, got %v", err)
+		}
+		for i := range 38 {
+			if !calls[i].Load() {
+				t.Fatalf("index %d before the error was skipped", i)
+			}
+		}
+	}
+
+	var calls atomic.Int64
+	err := crypto.ForEach(100, func(i int) error {
+		calls.Add(1)
+		return nil
+	})
+	if err != nil || calls.Load() != 100 {
+		t.Errorf("expected 100 calls without error, got %d, %v", calls.Load(), err)
+	}
+}
+
+func TestForEachReturnsPanics(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		cryptotest.SetWorkers(t, workers)
+		err := crypto.ForEach(20, func(i int) error {
+			if i == 12 {
+				var key *secp256k1.PublicKey
+				_ = key.SerializeCompressed()
+			}
+			return nil
+		})
+		if !errors.Is(err, crypto.ErrWorkerPanic) {
+			t.Errorf("workers %d: expected crypto.ErrWorkerPanic, got %v", workers, err)
+		}
+	}
+}
+
+func TestSetWorkersFromEnv(t *testing.T) {
+	cryptotest.SetWorkers(t, 1)
+	t.Setenv(crypto.WorkersEnv, "3")
+	err := crypto.SetWorkersFromEnv()
+	if err != nil || crypto.Workers() != 3 {
+		t.Errorf("expected 3 workers, got %d, %v", crypto.Workers(), err)
+	}
+	t.Setenv(crypto.WorkersEnv, "none")
+	err = crypto.SetWorkersFromEnv()
+	if err == nil {
+		t.Errorf("expected an error for a value that is not a number")
+	}
+}
+
+func benchmarkHashToCurve(b *testing.B, workers int) {
+	cryptotest.SetWorkers(b, workers)
+	secrets := make([][]byte, 256)
+	for i := range secrets {
+		secrets[i] = []byte("secret " + strconv.Itoa(i))
+	}
+	for b.Loop() {
+		err := crypto.ForEach(len(secrets), func(i int) error {
+			_, err := crypto.HashToCurve(secrets[i])
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHashToCurveSerial(b *testing.B)   { benchmarkHashToCurve(b, 1) }
+func BenchmarkHashToCurveParallel(b *testing.B) { benchmarkHashToCurve(b, 0) }
+
+func benchmarkSignBlindedMessage(b *testing.B, workers int) {
+	cryptotest.SetWorkers(b, workers)
+	k, _ := secp256k1.GeneratePrivateKey()
+	messages := make([]*secp256k1.PublicKey, 256)
+	for i := range messages {
+		r, _ := secp256k1.GeneratePrivateKey()
+		B_, _, err := crypto.BlindMessage("secret "+strconv.Itoa(i), r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		messages[i] = B_
+	}
+	signatures := make([]*secp256k1.PublicKey, len(messages))
+	for b.Loop() {
+		err := crypto.ForEach(len(messages), func(i int) error {
+			signatures[i] = crypto.SignBlindedMessage(messages[i], k)
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignBlindedMessageSerial(b *testing.B)   { benchmarkSignBlindedMessage(b, 1) }
+func BenchmarkSignBlindedMessageParallel(b *testing.B) { benchmarkSignBlindedMessage(b, 0) }